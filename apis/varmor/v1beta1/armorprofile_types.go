@@ -33,6 +33,24 @@ type PathPattern struct {
 type FileContent struct {
 	Permissions uint32      `json:"permissions"`
 	Pattern     PathPattern `json:"pattern"`
+	// RuleID identifies the policy rule that generated this rule, it's used to attribute the violations
+	RuleID string `json:"ruleID,omitempty"`
+}
+
+type RegexFileContent struct {
+	Permissions uint32 `json:"permissions"`
+	Regex       string `json:"regex"`
+	// RuleID identifies the policy rule that generated this rule, it's used to attribute the violations
+	RuleID string `json:"ruleID,omitempty"`
+}
+
+type HashProcessContent struct {
+	// Path is the absolute path of the executable
+	Path string `json:"path"`
+	// SHA256 are the hex-encoded SHA256 digests of the executables allowed to run at the path
+	SHA256 []string `json:"sha256"`
+	// RuleID identifies the policy rule that generated this rule, it's used to attribute the violations
+	RuleID string `json:"ruleID,omitempty"`
 }
 
 type NetworkContent struct {
@@ -40,11 +58,30 @@ type NetworkContent struct {
 	Address string `json:"address,omitempty"`
 	CIDR    string `json:"cidr,omitempty"`
 	Port    uint32 `json:"port,omitempty"`
+	// RuleID identifies the policy rule that generated this rule, it's used to attribute the violations
+	RuleID string `json:"ruleID,omitempty"`
+}
+
+type NetworkPeerContent struct {
+	// Namespace is the namespace of the Service or the Pods
+	Namespace string `json:"namespace"`
+	// ServiceName is the name of the Service. If it's empty, the peer is the Pods selected by the PodSelector.
+	ServiceName string `json:"serviceName,omitempty"`
+	// PodSelector is used to select the Pods
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+	// Port is the port to match, zero means all ports
+	Port uint32 `json:"port,omitempty"`
+	// Addresses are the IPs of the peer resolved by the manager
+	Addresses []string `json:"addresses,omitempty"`
+	// RuleID identifies the policy rule that generated this rule, it's used to attribute the violations
+	RuleID string `json:"ruleID,omitempty"`
 }
 
 type PtraceContent struct {
 	Permissions uint32 `json:"permissions,omitempty"`
 	Flags       uint32 `json:"flags,omitempty"`
+	// RuleID identifies the policy rule that generated this rule, it's used to attribute the violations
+	RuleID string `json:"ruleID,omitempty"`
 }
 
 type MountContent struct {
@@ -52,6 +89,8 @@ type MountContent struct {
 	ReverseMountflags uint32      `json:"reverseMountflags"`
 	Fstype            string      `json:"fstype"`
 	Pattern           PathPattern `json:"pattern"`
+	// RuleID identifies the policy rule that generated this rule, it's used to attribute the violations
+	RuleID string `json:"ruleID,omitempty"`
 }
 
 type BpfContent struct {
@@ -61,6 +100,14 @@ type BpfContent struct {
 	Networks     []NetworkContent `json:"networks,omitempty"`
 	Ptrace       *PtraceContent   `json:"ptrace,omitempty"`
 	Mounts       []MountContent   `json:"mounts,omitempty"`
+	// RegexFiles are the file and process rules with regular expression, they are expanded by the agent
+	RegexFiles []RegexFileContent `json:"regexFiles,omitempty"`
+	// HashProcesses are the process rules which only allow the executables with the SHA256 digests to run, they
+	// are expanded into the bprm rules by the agent
+	HashProcesses []HashProcessContent `json:"hashProcesses,omitempty"`
+	// NetworkPeers are the network rules with the Kubernetes Services or Pods, their addresses are resolved by
+	// the manager and they are expanded into the network rules by the agent
+	NetworkPeers []NetworkPeerContent `json:"networkPeers,omitempty"`
 }
 
 type Profile struct {
@@ -70,6 +117,17 @@ type Profile struct {
 	Content        string      `json:"content,omitempty"`
 	BpfContent     *BpfContent `json:"bpfContent,omitempty"`
 	SeccompContent string      `json:"seccompContent,omitempty"`
+	// CompressedBpfContent is the gzip-compressed JSON of the BpfContent. The large BpfContent is stored in it
+	// to keep the object under the size limit of etcd. It's managed by the JSON serialization of Profile, and
+	// it's decompressed into the BpfContent transparently when the object is decoded.
+	CompressedBpfContent []byte `json:"compressedBpfContent,omitempty"`
+	// BpfContentDigest is the SHA-256 digest of the JSON of the BpfContent, it's used to check the integrity
+	// of the CompressedBpfContent.
+	BpfContentDigest string `json:"bpfContentDigest,omitempty"`
+	// LandlockContent is the base64-encoded ruleset of the Landlock enforcer
+	LandlockContent string `json:"landlockContent,omitempty"`
+	// SELinuxContent is the base64-encoded CIL policy module of the SELinux enforcer
+	SELinuxContent string `json:"selinuxContent,omitempty"`
 }
 
 type BehaviorModeling struct {
@@ -77,6 +135,23 @@ type BehaviorModeling struct {
 	Enable bool `json:"enable"`
 	// Duration is the duration in minutes to modeling
 	Duration int `json:"duration"`
+	// PathGeneralization is the aggressiveness of collapsing the families of file paths into wildcard patterns
+	// +optional
+	PathGeneralization string `json:"pathGeneralization,omitempty"`
+}
+
+type DriftDetection struct {
+	// Enable is the switch for drift detection
+	Enable bool `json:"enable"`
+	// Action is the action to take when a drift is detected
+	Action string `json:"action,omitempty"`
+	// Executables are the executables learned during the behavior modeling
+	Executables []string `json:"executables,omitempty"`
+}
+
+type FileIntegrity struct {
+	// Paths are the critical files or directories to be monitored
+	Paths []string `json:"paths,omitempty"`
 }
 
 // ArmorProfileSpec defines the desired state of ArmorProfile
@@ -88,6 +163,13 @@ type ArmorProfileSpec struct {
 	Profile                 Profile          `json:"profile"`
 	BehaviorModeling        BehaviorModeling `json:"behaviorModeling"`
 	UpdateExistingWorkloads bool             `json:"updateExistingWorkloads"`
+	// +optional
+	DriftDetection DriftDetection `json:"driftDetection,omitempty"`
+	// +optional
+	FileIntegrity FileIntegrity `json:"fileIntegrity,omitempty"`
+	// NodeSelector limits the nodes that the profile is loaded and enforced on
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 }
 
 type ArmorProfileConditionType string
@@ -117,6 +199,20 @@ type ArmorProfileStatus struct {
 	DesiredNumberLoaded int                     `json:"desiredNumberLoaded"`
 	CurrentNumberLoaded int                     `json:"currentNumberLoaded"`
 	Conditions          []ArmorProfileCondition `json:"conditions,omitempty"`
+	// History are the last generations of the profile, the latest one comes last
+	History []ProfileRevision `json:"history,omitempty"`
+}
+
+// ProfileRevision is a generation of the profile, it's used to find out the rules changed by the policy edits
+type ProfileRevision struct {
+	// Generation is the generation of the ArmorProfile object
+	Generation int64 `json:"generation"`
+	// Hash is the SHA256 digest of the profile
+	Hash      string      `json:"hash"`
+	Timestamp metav1.Time `json:"timestamp"`
+	Mode      string      `json:"mode,omitempty"`
+	// Rules are the rules of the BPF profile in human-readable form
+	Rules []string `json:"rules,omitempty"`
 }
 
 //+genclient
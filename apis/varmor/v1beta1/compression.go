@@ -0,0 +1,122 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// bpfContentCompressionThreshold is the size of the JSON of the BpfContent above which it's compressed
+	bpfContentCompressionThreshold = 256 * 1024
+	// maxBpfContentSize limits the size of the decompressed BpfContent
+	maxBpfContentSize = 64 * 1024 * 1024
+)
+
+// profileAlias has the fields of Profile without its methods, it's used to avoid the recursion of JSON encoding
+type profileAlias Profile
+
+func bpfContentDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func compressBpfContent(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressBpfContent(compressed []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(io.LimitReader(r, maxBpfContentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxBpfContentSize {
+		return nil, fmt.Errorf("the decompressed BPF content exceeds %d bytes", maxBpfContentSize)
+	}
+	return content, nil
+}
+
+// MarshalJSON compresses the BpfContent into the CompressedBpfContent if its JSON is larger than the threshold
+func (p Profile) MarshalJSON() ([]byte, error) {
+	alias := profileAlias(p)
+
+	if p.BpfContent != nil && len(p.CompressedBpfContent) == 0 {
+		content, err := json.Marshal(p.BpfContent)
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > bpfContentCompressionThreshold {
+			compressed, err := compressBpfContent(content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compress the BPF content: %w", err)
+			}
+			alias.BpfContent = nil
+			alias.CompressedBpfContent = compressed
+			alias.BpfContentDigest = bpfContentDigest(content)
+		}
+	}
+
+	return json.Marshal(alias)
+}
+
+// UnmarshalJSON decompresses the CompressedBpfContent into the BpfContent, and checks its integrity with
+// the BpfContentDigest
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	var alias profileAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+
+	if len(alias.CompressedBpfContent) != 0 {
+		content, err := decompressBpfContent(alias.CompressedBpfContent)
+		if err != nil {
+			return fmt.Errorf("failed to decompress the BPF content: %w", err)
+		}
+		if digest := bpfContentDigest(content); digest != alias.BpfContentDigest {
+			return fmt.Errorf("the digest of the BPF content mismatches (expected: %s, actual: %s)", alias.BpfContentDigest, digest)
+		}
+
+		var bpfContent BpfContent
+		if err := json.Unmarshal(content, &bpfContent); err != nil {
+			return err
+		}
+		alias.BpfContent = &bpfContent
+		alias.CompressedBpfContent = nil
+		alias.BpfContentDigest = ""
+	}
+
+	*p = Profile(alias)
+	return nil
+}
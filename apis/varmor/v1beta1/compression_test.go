@@ -0,0 +1,101 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func largeBpfContent(count int) *BpfContent {
+	var content BpfContent
+	for i := 0; i < count; i++ {
+		content.Files = append(content.Files, FileContent{
+			Permissions: 2,
+			Pattern: PathPattern{
+				Flags:  1,
+				Prefix: fmt.Sprintf("/var/lib/app/data/%d/", i),
+			},
+			RuleID: "modeling",
+		})
+	}
+	return &content
+}
+
+func Test_ProfileJSON(t *testing.T) {
+	testCases := []struct {
+		name       string
+		profile    Profile
+		compressed bool
+	}{
+		{
+			name: "small",
+			profile: Profile{
+				Name:       "test",
+				Enforcer:   "BPF",
+				Mode:       "enforce",
+				BpfContent: largeBpfContent(10),
+			},
+			compressed: false,
+		},
+		{
+			name: "large",
+			profile: Profile{
+				Name:       "test",
+				Enforcer:   "BPF",
+				Mode:       "enforce",
+				BpfContent: largeBpfContent(10000),
+			},
+			compressed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(ArmorProfileSpec{Profile: tc.profile})
+			assert.NilError(t, err)
+			assert.Equal(t, strings.Contains(string(data), `"compressedBpfContent"`), tc.compressed)
+			assert.Equal(t, strings.Contains(string(data), `"bpfContent"`), !tc.compressed)
+			if tc.compressed {
+				assert.Assert(t, len(data) < bpfContentCompressionThreshold)
+			}
+
+			var spec ArmorProfileSpec
+			err = json.Unmarshal(data, &spec)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, spec.Profile, tc.profile)
+		})
+	}
+}
+
+func Test_ProfileJSONIntegrity(t *testing.T) {
+	data, err := json.Marshal(Profile{Name: "test", BpfContent: largeBpfContent(10000)})
+	assert.NilError(t, err)
+
+	var alias profileAlias
+	err = json.Unmarshal(data, &alias)
+	assert.NilError(t, err)
+	alias.BpfContentDigest = bpfContentDigest([]byte("tampered"))
+	data, err = json.Marshal(alias)
+	assert.NilError(t, err)
+
+	var profile Profile
+	err = json.Unmarshal(data, &profile)
+	assert.ErrorContains(t, err, "digest of the BPF content mismatches")
+}
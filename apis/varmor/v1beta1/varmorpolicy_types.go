@@ -26,10 +26,27 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // Target Structure
+type HostProcessTarget struct {
+	// Executables are used to match the host processes with the full paths of their executable files, e.g. /usr/bin/containerd.
+	// +optional
+	Executables []string `json:"executables,omitempty"`
+	// SystemdUnits are used to match the host processes with the names of the systemd units that they belong to, e.g. containerd.service.
+	// The suffix ".service" can be omitted.
+	// +optional
+	SystemdUnits []string `json:"systemdUnits,omitempty"`
+}
+
 type Target struct {
 	// Kind is used to specify the type of workloads for the protection targets.
-	// Available values: Deployment, StatefulSet, DaemonSet, Pod.
+	// Available values: Deployment, StatefulSet, DaemonSet, Job, CronJob, Pod, HostProcess.
+	// Any other kind of the pods' owners (e.g. Rollout of Argo Rollouts) can be used with the APIVersion field.
+	// The HostProcess kind is only supported by the VarmorClusterPolicy with the BPF enforcer.
 	Kind string `json:"kind"`
+	// APIVersion is used to specify the group/version of a custom owner kind, e.g. argoproj.io/v1alpha1.
+	// When it's set, the pods whose controller owner chain includes an object of the kind are protected,
+	// and the name or selector field is matched against the owner object instead of the pods.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
 	// Name is used to specify a specific workload name. Note that the name field and selector field are mutually exclusive.
 	// +optional
 	Name string `json:"name,omitempty"`
@@ -43,6 +60,19 @@ type Target struct {
 	// The type of workloads is determined by the KIND field.
 	// +optional
 	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// ServiceAccounts are used to match the workloads whose pods run as one of the service accounts. It can be used
+	// alone, or along with the name or selector field to narrow the matched workloads.
+	//
+	// Note:
+	// The pods that don't specify the service account run as the "default" service account.
+	// +optional
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+	// HostProcess is used to specify the host processes (e.g. node-level components) to protect when the Kind is HostProcess.
+	// The BPF profile is applied to the mnt ns of the matched processes, so only the processes which run in their own
+	// mnt ns (e.g. the systemd units with sandboxing options like PrivateTmp) can be protected. The policy fails on
+	// the nodes where any matched process runs in the host mnt ns.
+	// +optional
+	HostProcess *HostProcessTarget `json:"hostProcess,omitempty"`
 }
 
 type AttackProtectionRules struct {
@@ -59,20 +89,70 @@ type FileRule struct {
 	Pattern string `json:"pattern"`
 	// Permissions are used to specify the file permissions to be disabled.
 	Permissions []string `json:"permissions"`
+	// Regex is used to indicate that the pattern is a regular expression (RE2 syntax) which matches the whole path.
+	// It is only supported by the BPF enforcer. The regular expression will be expanded into the concrete paths by
+	// walking the filesystem of the target container from the longest literal directory prefix of it, and the rules
+	// will be refreshed when the entries of the walked directories change. Default is false.
+	//
+	// Note:
+	// The regular expression must start with an absolute directory, e.g. /etc/cron\.d/.*. The expanded paths
+	// are counted against the maximum number of BPF file rules and BPF bprm rules.
+	// +optional
+	Regex bool `json:"regex,omitempty"`
+	// SHA256 are the hex-encoded SHA256 digests of the executables allowed to run at the path of the pattern. If set,
+	// executing the file is denied unless its SHA256 is one of them, so a malicious binary renamed to the path can't
+	// be executed. The permissions are ignored. It is only supported by the BPF enforcer.
+	//
+	// Note:
+	// The pattern must be an absolute path without globbing. varmor-agent computes the SHA256 of the file in the
+	// target container, and refreshes the rule when the file changes.
+	// +optional
+	SHA256 []string `json:"sha256,omitempty"`
 }
 
 type NetworkEgressRule struct {
-	// IPBlock defines policy on a particular IPBlock with CIDR. If this field is set then neither of the IP field can be.
+	// IPBlock defines policy on a particular IPBlock with CIDR. If this field is set then none of the IP, Service
+	// and Pods fields can be. It also accepts the macros which are expanded by the agent with the configuration of
+	// the cluster: @cluster-pods, @cluster-services, @node-local and @private-ranges.
 	// +optional
 	IPBlock string `json:"ipBlock,omitempty"`
-	// IP defines policy on a particular IP. If this field is set then neither of the IPBlock field can be.
+	// IP defines policy on a particular IP. If this field is set then none of the IPBlock, Service and Pods fields
+	// can be.
 	// +optional
 	IP string `json:"ip,omitempty"`
+	// Service defines policy on a Kubernetes Service. It's resolved into the cluster IPs of the Service and the IPs
+	// of its ready endpoints by the manager, and the rules are updated as the endpoints change. If this field is set
+	// then none of the IPBlock, IP and Pods fields can be. It's only supported by the BPF enforcer.
+	// +optional
+	Service *EgressService `json:"service,omitempty"`
+	// Pods defines policy on the Pods selected by the label selector. It's resolved into the IPs of the Pods by the
+	// manager, and the rules are updated as the Pods change. If this field is set then none of the IPBlock, IP and
+	// Service fields can be. It's only supported by the BPF enforcer.
+	// +optional
+	Pods *EgressPods `json:"pods,omitempty"`
 	// Port defines policy on a particular port. If this field is zero or missing, this rule matches all ports.
 	// +optional
 	Port int `json:"port,omitempty"`
 }
 
+type EgressService struct {
+	// Namespace is the namespace of the Service. Default is the namespace of the VarmorPolicy. It's required by
+	// the VarmorClusterPolicy.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the Service.
+	Name string `json:"name"`
+}
+
+type EgressPods struct {
+	// Namespace is the namespace of the Pods. Default is the namespace of the VarmorPolicy. It's required by
+	// the VarmorClusterPolicy.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Selector is a label query over the Pods.
+	Selector metav1.LabelSelector `json:"selector"`
+}
+
 type NetworkRule struct {
 	// Egresses are the list of egress rules to be applied to restrict particular IPs and ports.
 	Egresses []NetworkEgressRule `json:"egresses"`
@@ -131,6 +211,41 @@ type BpfRawRules struct {
 	Mounts    []MountRule `json:"mounts,omitempty"`
 }
 
+type FileIntegrityRule struct {
+	// Paths are the critical files or directories to be monitored. They must be specified as absolute paths inside
+	// the container, and the path that ends with "/" is treated as a directory (its direct entries will be monitored).
+	Paths []string `json:"paths"`
+	// Block is used to indicate whether to disallow writing and renaming the critical paths with the enforcer.
+	// Default is false.
+	// +optional
+	Block bool `json:"block,omitempty"`
+}
+
+type ReadOnlyFilesystem struct {
+	// Enable is used to make the filesystem of the target containers read-only at the LSM layer, except for the
+	// writable paths. It provides the protection equivalent to readOnlyRootFilesystem for the workloads that can't
+	// set it, e.g. the ones that need to write some temporary directories. Default is false.
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+	// WritablePaths are the files or directories that can still be written. They must be specified as absolute
+	// paths inside the container, and the path that ends with "/" is treated as a directory (all files under it
+	// can be written). The /dev and /proc directories are always writable, while the other rules still apply to them.
+	// +optional
+	WritablePaths []string `json:"writablePaths,omitempty"`
+}
+
+type BuiltInRules struct {
+	// HardeningRules are used to specify the built-in hardening rules
+	// +optional
+	HardeningRules []string `json:"hardeningRules,omitempty"`
+	// AttackProtectionRules are used to specify the built-in attack protection rules
+	// +optional
+	AttackProtectionRules []AttackProtectionRules `json:"attackProtectionRules,omitempty"`
+	// VulMitigationRules are used to specify the built-in vulnerability mitigation rules
+	// +optional
+	VulMitigationRules []string `json:"vulMitigationRules,omitempty"`
+}
+
 type EnhanceProtect struct {
 	// HardeningRules are used to specify the built-in hardening rules
 	// +optional
@@ -141,15 +256,72 @@ type EnhanceProtect struct {
 	// VulMitigationRules are used to specify the built-in vulnerability mitigation rules
 	// +optional
 	VulMitigationRules []string `json:"vulMitigationRules,omitempty"`
+	// AppArmorRules are the built-in rules that are only enforced by the AppArmor enforcer, in addition to the
+	// rules above. They are used to tune each layer of the policy that combines multiple enforcers.
+	// +optional
+	AppArmorRules *BuiltInRules `json:"appArmorRules,omitempty"`
+	// BpfRules are the built-in rules that are only enforced by the BPF enforcer, in addition to the rules above.
+	// +optional
+	BpfRules *BuiltInRules `json:"bpfRules,omitempty"`
+	// SeccompRules are the built-in rules that are only enforced by the Seccomp enforcer, in addition to the
+	// rules above.
+	// +optional
+	SeccompRules *BuiltInRules `json:"seccompRules,omitempty"`
 	// AppArmorRawRules is used to set native AppArmor rules, each rule must end with a comma
 	// +optional
 	AppArmorRawRules []string `json:"appArmorRawRules,omitempty"`
+	// AppArmorRawSnippets are used to embed the native AppArmor snippets into the profile, e.g. the multi-line rule
+	// blocks or the include rules of the local files. Each snippet is inserted into the profile as it is, so it must
+	// be well-formed. It's only effective with the AppArmor enforcer.
+	// +optional
+	AppArmorRawSnippets []string `json:"appArmorRawSnippets,omitempty"`
+	// AppArmorAbstractions are used to specify the AppArmor abstractions to include in the profile, e.g. nameservice
+	// and ssl_certs. They must exist in the /etc/apparmor.d/abstractions directory of varmor-agent.
+	// It's only effective with the AppArmor enforcer.
+	// +optional
+	AppArmorAbstractions []string `json:"appArmorAbstractions,omitempty"`
+	// SELinuxRawRules are used to embed the native CIL statements into the block of the SELinux policy module, e.g.
+	// the allow rules of the host paths mounted into the target containers. It's only effective with the SELinux
+	// enforcer.
+	// +optional
+	SELinuxRawRules []string `json:"selinuxRawRules,omitempty"`
 	// BpfRawRules is used to set native BPF rules
 	// +optional
 	BpfRawRules BpfRawRules `json:"bpfRawRules,omitempty"`
 	// SyscallRawRules is used to set the syscalls blocklist rules with Seccomp enforcer.
 	// +optional
 	SyscallRawRules []specs.LinuxSyscall `json:"syscallRawRules,omitempty"`
+	// SyscallNotifyRules are used to make the allow/deny decisions of the syscalls with argument inspection in
+	// varmor-agent via the seccomp user notification, e.g. allow mount only for specific file system types.
+	// It's only effective with the Seccomp enforcer.
+	//
+	// Note:
+	// It requires the SeccompNotify feature of varmor-agent, Linux 5.5+ and runc 1.1+. The inspected syscalls
+	// that aren't allowed by the rules are denied with EPERM. The syscalls shouldn't be used in SyscallRawRules.
+	// +optional
+	SyscallNotifyRules []SyscallNotifyRule `json:"syscallNotifyRules,omitempty"`
+	// FileIntegrityRules are used to monitor the critical files or directories of the target containers. The writes and
+	// renames of them are recorded with the SHA256 of the file content after writing, and can optionally be blocked.
+	// +optional
+	FileIntegrityRules []FileIntegrityRule `json:"fileIntegrityRules,omitempty"`
+	// ReadOnlyFilesystem is used to disallow writing any file of the target containers except for the writable paths.
+	//
+	// Note:
+	// It only works with the AppArmor and Landlock enforcers. The policy with the BPF enforcer is rejected, since
+	// the BPF program doesn't support it yet.
+	// +optional
+	ReadOnlyFilesystem ReadOnlyFilesystem `json:"readOnlyFilesystem,omitempty"`
+	// MatchOverlayfsPaths is used to make the file and process rules of the BPF enforcer also match the paths of
+	// overlayfs layers. The LSM hooks may see the upperdir or lowerdir paths of the container rootfs (e.g.
+	// /var/lib/containerd/.../snapshots/<id>/fs/etc/shadow) instead of the paths in the container view. If set to
+	// `true`, each rule without globbing will be duplicated to also match the corresponding paths in the layers
+	// of the overlayfs snapshotter of containerd and the overlay2 storage driver of docker. Default is false.
+	//
+	// Note:
+	// Only the rules without globbing are duplicated. The duplicated rules are counted against the maximum number
+	// of BPF file rules and BPF bprm rules.
+	// +optional
+	MatchOverlayfsPaths bool `json:"matchOverlayfsPaths,omitempty"`
 	// Privileged is used to identify whether the policy is for the privileged container.
 	// If set to `nil` or `false`, the EnhanceProtect mode will build AppArmor or BPF profile on
 	// top of the RuntimeDefault mode. Otherwise, it will build AppArmor or BPF profile on top of the AlwaysAllow mode.
@@ -161,22 +333,100 @@ type EnhanceProtect struct {
 	Privileged bool `json:"privileged,omitempty"`
 }
 
+type SyscallNotifyRule struct {
+	// Syscall is the name of the syscall to inspect. Available values: mount
+	Syscall string `json:"syscall"`
+	// FsTypes are the file system types allowed to mount, e.g. tmpfs. It's only used for the mount syscall.
+	// The bind mounts, remounts, moves and propagation changes are denied since they ignore the file system type.
+	// +optional
+	FsTypes []string `json:"fsTypes,omitempty"`
+}
+
 type ModelingOptions struct {
 	// Duration is the duration in minutes to modeling
 	Duration int `json:"duration"`
+	// PathGeneralization is used to specify how aggressively the families of per-instance file paths
+	// (e.g. /tmp/worker-8f3a9c, /tmp/worker-1b2e4d) are collapsed into wildcard patterns when building
+	// the profiles with the behavior model. So the profiles don't exceed the rule limits of the enforcers.
+	// Available values: Disabled, Conservative, Aggressive. Default is Conservative.
+	//
+	// Conservative collapses 4 or more sibling files whose names only differ in the words that contain digits.
+	// Aggressive collapses 2 or more such files, and collapses the files of a directory into "<directory>/*"
+	// once the directory has more than 16 files.
+	// +optional
+	PathGeneralization string `json:"pathGeneralization,omitempty"`
+}
+
+type DriftDetectionOptions struct {
+	// Enable is used to turn on the drift detection for the target workloads. The executables launched during the
+	// behavior modeling window are used as the baseline, any executable that has never been seen before will be
+	// reported when it runs in the target containers.
+	//
+	// Note:
+	// It requires an existing ArmorProfileModel object of the policy, and the BehaviorModeling feature of varmor-agent.
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+	// Action is used to specify what to do when a drift is detected.
+	// Available values: Audit, Deny. Default is Audit.
+	//
+	// Audit reports the drifts as the violations of the drift rule type in the VarmorViolation object. Deny additionally
+	// kills the offending process with SIGKILL. The process is killed after the executable is loaded, so Deny can't
+	// prevent the executable from running briefly.
+	// +optional
+	Action string `json:"action,omitempty"`
+}
+
+type DefenseInDepthOptions struct {
+	// ComplainMode is used to load the AppArmor profile of the ArmorProfileModel object in complain mode. The behaviors
+	// violating the profile are allowed and recorded instead of being denied, and the agents feed the records back
+	// into the ArmorProfileModel object periodically. So the profile can be refined with the real behaviors of the
+	// target workloads before it's enforced. Default is false.
+	//
+	// Note:
+	// It only works with the AppArmor enforcer, and requires the BehaviorModeling feature of varmor-agent. The profile
+	// is only refined if it was built by the BehaviorModeling mode and the profile verification isn't enabled.
+	// +optional
+	ComplainMode bool `json:"complainMode,omitempty"`
+}
+
+// LifecycleHook is an HTTP callback that the manager invokes when a lifecycle event of the policy occurs.
+type LifecycleHook struct {
+	// URL is the endpoint that the manager POSTs the event to in JSON. Only http and https are supported.
+	URL string `json:"url"`
+	// Events are the lifecycle events that the hook subscribes to. Default is all of them.
+	// Available values: PreEnforce, PostEnforce, ModeChanged, EnforcementFailed
+	//
+	// PreEnforce: The profile of the policy has been created or updated, and is about to be enforced by the agents.
+	// PostEnforce: The profile of the policy has been loaded by all agents.
+	// ModeChanged: The mode of the profile changed, e.g. from complain mode to enforce mode after the behavior modeling.
+	// EnforcementFailed: The profile of the policy failed to be loaded on a node.
+	// +optional
+	Events []string `json:"events,omitempty"`
+	// TimeoutSeconds is the timeout of the callback. Default is 10.
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
 }
 
 type VarmorPolicyMode string
 
 type Policy struct {
 	// Enforcer is used to specify which LSM to use for mandatory access control.
-	// Available values: AppArmor, BPF, Seccomp, AppArmorBPF, AppArmorSeccomp, BPFSeccomp, AppArmorBPFSeccomp
+	// Available values: AppArmor, BPF, Seccomp, AppArmorBPF, AppArmorSeccomp, BPFSeccomp, AppArmorBPFSeccomp, BestAvailable,
+	// Landlock, LandlockSeccomp, SELinux, SELinuxSeccomp
+	// BestAvailable selects the BPF enforcer on the nodes that support it, and the AppArmor and Seccomp enforcers on
+	// the others. It only supports the AlwaysAllow, RuntimeDefault and EnhanceProtect modes.
+	// Landlock enforces the file and process rules of bpfRawRules, the blocking fileIntegrityRules and the
+	// readOnlyFilesystem with Landlock (Linux 5.13+). It only supports the AlwaysAllow, RuntimeDefault and
+	// EnhanceProtect modes.
+	// SELinux runs the target containers in the type derived from container_t on the RHEL-family nodes. It only
+	// supports the AlwaysAllow, RuntimeDefault and EnhanceProtect modes.
 	Enforcer string `json:"enforcer"`
 	// Available values: AlwaysAllow, RuntimeDefault, EnhanceProtect, BehaviorModeling, DefenseInDepth
 	//
 	// Note:
 	// BehaviorModeling and DefenseInDepth modes are experimental features and currently only work
-	// with AppArmor/Seccomp/AppArmorSeccomp enforcers.
+	// with AppArmor/Seccomp/AppArmorSeccomp enforcers. The DefenseInDepth mode also works with the
+	// enforcers that include BPF if the profile was imported into the ArmorProfileModel object.
 	Mode VarmorPolicyMode `json:"mode"`
 	// EnhanceProtect is used to specify which built-in or custom rules are employed to protect the target workloads.
 	// +optional
@@ -184,6 +434,31 @@ type Policy struct {
 	// ModelingOptions is used for the modeling settings.
 	// +optional
 	ModelingOptions ModelingOptions `json:"modelingOptions,omitempty"`
+	// DriftDetectionOptions is used for the drift detection settings.
+	// +optional
+	DriftDetectionOptions DriftDetectionOptions `json:"driftDetectionOptions,omitempty"`
+	// DefenseInDepthOptions is used for the settings of the DefenseInDepth mode.
+	// +optional
+	DefenseInDepthOptions DefenseInDepthOptions `json:"defenseInDepthOptions,omitempty"`
+	// LifecycleHooks are the HTTP callbacks that the manager invokes when the lifecycle events of the policy occur,
+	// so the external systems can be notified automatically, e.g. the change-management or paging systems.
+	//
+	// Note:
+	// The hooks are invoked asynchronously and only once. Their failures are logged, and don't block the enforcement.
+	// +optional
+	LifecycleHooks []LifecycleHook `json:"lifecycleHooks,omitempty"`
+	// RejectPrivilegedContainers is used to reject the target pods at admission if their target containers are
+	// privileged or share the host namespaces (hostPID, hostIPC or hostNetwork), since several rules are ineffective
+	// or misleading for them. Default is false, which means they are admitted with the warnings, and reported as
+	// partially enforceable in the coverage of the policy.
+	// +optional
+	RejectPrivilegedContainers bool `json:"rejectPrivilegedContainers,omitempty"`
+	// ConfineSandboxContainers is used to confine the sandbox (pause) containers of the target pods with a built-in
+	// minimal BPF profile, which denies all the capabilities, executions, writes, mounts, ptrace and outgoing
+	// connections. It only takes effect with the BPF enforcer. Default is false, which means the sandbox containers
+	// are excluded from the enforcement.
+	// +optional
+	ConfineSandboxContainers bool `json:"confineSandboxContainers,omitempty"`
 }
 
 // VarmorPolicySpec defines the desired state of VarmorPolicy or VarmorClusterPolicy
@@ -202,9 +477,20 @@ type VarmorPolicySpec struct {
 	//
 	// Note:
 	// vArmor only performs a rolling update on Deployment, StatefulSet, or DaemonSet type workloads.
-	// If `.spec.target.kind` is Pod, you need to rebuild the Pod yourself to enable or disable protection.
+	// If `.spec.target.kind` is CronJob, vArmor updates the job template, and the protection takes effect on the next run.
+	// If `.spec.target.kind` is Pod or Job, you need to rebuild it yourself to enable or disable protection.
 	// +optional
 	UpdateExistingWorkloads bool `json:"updateExistingWorkloads,omitempty"`
+	// NodeSelector limits the nodes that the policy applies to. The profile is only loaded and enforced on the nodes
+	// whose labels match it. Besides the labels of the node, the agent also matches it with the labels of the features
+	// it probed on the node, e.g., "varmor.org/bpf-lsm", "varmor.org/apparmor" and "varmor.org/kernel-version".
+	// Default is empty, which means all nodes.
+	//
+	// Note:
+	// The labels of the node are retrieved when the agent starts, so you need to restart the agent on the node
+	// after modifying its labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 }
 
 type VarmorPolicyConditionType string
@@ -227,6 +513,96 @@ type VarmorPolicyCondition struct {
 
 type VarmorPolicyPhase string
 
+// NodeCoverage describes how many target pods of the policy are protected on a node.
+type NodeCoverage struct {
+	NodeName string `json:"nodeName"`
+	// DesiredPods is the number of the running target pods that the agent observed on the node.
+	DesiredPods int `json:"desiredPods"`
+	// ReadyPods is the number of the target pods whose containers are all protected.
+	ReadyPods int `json:"readyPods"`
+	// FailedPods is the number of the target pods that the profile failed to apply to.
+	FailedPods int `json:"failedPods"`
+	// FailureReasons describe why the profile failed to apply to the containers.
+	// +optional
+	FailureReasons []string `json:"failureReasons,omitempty"`
+	// PartialPods is the number of the ready pods that are only partially enforceable, since some of their
+	// containers are privileged or share the host namespaces.
+	// +optional
+	PartialPods int `json:"partialPods,omitempty"`
+	// PartialReasons describe why the containers are only partially enforceable.
+	// +optional
+	PartialReasons []string `json:"partialReasons,omitempty"`
+}
+
+// EnforcementCoverage describes how many target pods of the policy are actually protected.
+// It's reported by the agents, and only the BPF enforcer is covered for now.
+type EnforcementCoverage struct {
+	// DesiredPods is the total number of the running target pods.
+	DesiredPods int `json:"desiredPods"`
+	// ReadyPods is the total number of the target pods whose containers are all protected.
+	ReadyPods int `json:"readyPods"`
+	// FailedPods is the total number of the target pods that the profile failed to apply to.
+	FailedPods int `json:"failedPods"`
+	// PartialPods is the total number of the ready pods that are only partially enforceable.
+	// +optional
+	PartialPods int `json:"partialPods,omitempty"`
+	// Nodes are the coverages of the nodes that run the target pods.
+	// +optional
+	Nodes []NodeCoverage `json:"nodes,omitempty"`
+}
+
+// NodeCompatibility describes why a node can't fully enforce the policy.
+type NodeCompatibility struct {
+	NodeName string `json:"nodeName"`
+	// KernelVersion is the kernel version of the node.
+	// +optional
+	KernelVersion string `json:"kernelVersion,omitempty"`
+	// Reasons describe the features that the policy requires but the node lacks.
+	// +optional
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// PolicyCompatibility describes which nodes can enforce the policy. It's evaluated by the manager with the kernel
+// versions, the enabled LSMs and the BPF features reported by the agents. The nodes that don't match the node
+// selector of the policy are excluded.
+type PolicyCompatibility struct {
+	// FullNodes is the number of the nodes that can fully enforce the policy.
+	FullNodes int `json:"fullNodes"`
+	// PartialNodes are the nodes that can only enforce the policy partially, e.g. some enforcers of the policy
+	// are unsupported.
+	// +optional
+	PartialNodes []NodeCompatibility `json:"partialNodes,omitempty"`
+	// UnsupportedNodes are the nodes that can't enforce the policy at all.
+	// +optional
+	UnsupportedNodes []NodeCompatibility `json:"unsupportedNodes,omitempty"`
+}
+
+// RuleSuggestion describes a rule of the BPF profile that is suggested to be removed.
+type RuleSuggestion struct {
+	// RuleID is the ID of the policy rule.
+	RuleID string `json:"ruleID"`
+	// Hits is the number of the operations matched by the rule in the observation period.
+	Hits int64 `json:"hits"`
+}
+
+// TighteningSuggestion describes how to tighten the BPF profile of the policy. It's generated periodically from
+// the hit counters of the rules, i.e. the records of the VarmorViolation object of the policy.
+type TighteningSuggestion struct {
+	// ID identifies the suggested BPF content. Annotate the policy with varmor.org/approve-suggestion=<ID> to
+	// apply it to the ArmorProfile object.
+	ID string `json:"id"`
+	// ProfileGeneration is the generation of the ArmorProfile object that the suggestion was generated from.
+	// The suggestion can't be approved once the ArmorProfile object changes.
+	ProfileGeneration int64 `json:"profileGeneration"`
+	// GeneratedTime is the time when the suggestion was generated.
+	GeneratedTime metav1.Time `json:"generatedTime"`
+	// Removals are the custom rules that never fired in the observation period.
+	// +optional
+	Removals []RuleSuggestion `json:"removals,omitempty"`
+	// BpfContent is the BPF content of the profile with the suggestions applied.
+	BpfContent *BpfContent `json:"bpfContent"`
+}
+
 // VarmorPolicyStatus defines the observed state of VarmorPolicy or VarmorClusterPolicy
 type VarmorPolicyStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -244,6 +620,15 @@ type VarmorPolicyStatus struct {
 	// You can find out which varmor-agent has an error by reading the
 	// ArmorProfile/status corresponding to the current VarmorPolicy
 	Phase VarmorPolicyPhase `json:"phase,omitempty"`
+	// Coverage is used to indicate how many target pods are actually protected.
+	// +optional
+	Coverage *EnforcementCoverage `json:"coverage,omitempty"`
+	// Compatibility is used to indicate which nodes can fully, partially or not enforce the policy.
+	// +optional
+	Compatibility *PolicyCompatibility `json:"compatibility,omitempty"`
+	// Suggestion is used to suggest tightening the BPF profile of the policy.
+	// +optional
+	Suggestion *TighteningSuggestion `json:"suggestion,omitempty"`
 }
 
 //+genclient
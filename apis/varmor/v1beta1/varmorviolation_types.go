@@ -0,0 +1,92 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ViolationRecord aggregates the operations denied by a rule in a workload
+type ViolationRecord struct {
+	// RuleID is the ID of the policy rule which denied the operations. It's empty if the rule is unknown.
+	// +optional
+	RuleID string `json:"ruleID,omitempty"`
+	// RuleType is the type of the rule, e.g. file, bprm, network, ptrace, mount or capability.
+	RuleType string `json:"ruleType"`
+	// Capability is the capability requested by the denied operations, e.g. net_raw. It's only set for the
+	// capability rule if the BPF program reports it.
+	// +optional
+	Capability string `json:"capability,omitempty"`
+	// Namespace is the namespace of the workload.
+	Namespace string `json:"namespace"`
+	// Workload is the kind and name of the workload, e.g. Deployment/nginx. It's the pod if the owner is unknown.
+	Workload string `json:"workload"`
+	// ServiceAccount is the service account of the workload. It's empty if the service account token isn't
+	// mounted into the containers.
+	// +optional
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// SPIFFEID is the SPIFFE ID of the workload derived from its service account, e.g.
+	// spiffe://cluster.local/ns/default/sa/nginx. It's used to correlate the violations with the identity-centric
+	// security tooling.
+	// +optional
+	SPIFFEID string `json:"spiffeID,omitempty"`
+	// Count is the number of the denied operations.
+	Count int64 `json:"count"`
+	// AuditCount is the number of the operations in Count that were allowed by the rule in audit mode.
+	// +optional
+	AuditCount int64 `json:"auditCount,omitempty"`
+	// Nodes are the names of the nodes where the operations were denied.
+	// +optional
+	Nodes []string `json:"nodes,omitempty"`
+	// The time when the operation was denied for the first time.
+	FirstTimestamp metav1.Time `json:"firstTimestamp"`
+	// The time when the operation was denied for the last time.
+	LastTimestamp metav1.Time `json:"lastTimestamp"`
+}
+
+//+genclient
+//+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=vvio
+//+kubebuilder:printcolumn:name="TOTAL",type=integer,JSONPath=`.totalCount`
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// VarmorViolation is the Schema for the varmorviolations API. It holds the recent violations of
+// an ArmorProfile, which are reported by agents and aggregated by rule and workload.
+type VarmorViolation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// TotalCount is the number of the denied operations of all records.
+	TotalCount int64 `json:"totalCount"`
+	// +optional
+	Records []ViolationRecord `json:"records,omitempty"`
+}
+
+//+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+
+// VarmorViolationList contains a list of VarmorViolation
+type VarmorViolationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VarmorViolation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VarmorViolation{}, &VarmorViolationList{})
+}
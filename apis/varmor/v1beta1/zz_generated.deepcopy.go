@@ -284,6 +284,15 @@ func (in *ArmorProfileSpec) DeepCopyInto(out *ArmorProfileSpec) {
 	in.Target.DeepCopyInto(&out.Target)
 	in.Profile.DeepCopyInto(&out.Profile)
 	out.BehaviorModeling = in.BehaviorModeling
+	in.DriftDetection.DeepCopyInto(&out.DriftDetection)
+	in.FileIntegrity.DeepCopyInto(&out.FileIntegrity)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArmorProfileSpec.
@@ -306,6 +315,13 @@ func (in *ArmorProfileStatus) DeepCopyInto(out *ArmorProfileStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]ProfileRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArmorProfileStatus.
@@ -386,6 +402,25 @@ func (in *BpfContent) DeepCopyInto(out *BpfContent) {
 		*out = make([]MountContent, len(*in))
 		copy(*out, *in)
 	}
+	if in.RegexFiles != nil {
+		in, out := &in.RegexFiles, &out.RegexFiles
+		*out = make([]RegexFileContent, len(*in))
+		copy(*out, *in)
+	}
+	if in.HashProcesses != nil {
+		in, out := &in.HashProcesses, &out.HashProcesses
+		*out = make([]HashProcessContent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NetworkPeers != nil {
+		in, out := &in.NetworkPeers, &out.NetworkPeers
+		*out = make([]NetworkPeerContent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BpfContent.
@@ -436,6 +471,88 @@ func (in *BpfRawRules) DeepCopy() *BpfRawRules {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuiltInRules) DeepCopyInto(out *BuiltInRules) {
+	*out = *in
+	if in.HardeningRules != nil {
+		in, out := &in.HardeningRules, &out.HardeningRules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AttackProtectionRules != nil {
+		in, out := &in.AttackProtectionRules, &out.AttackProtectionRules
+		*out = make([]AttackProtectionRules, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VulMitigationRules != nil {
+		in, out := &in.VulMitigationRules, &out.VulMitigationRules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuiltInRules.
+func (in *BuiltInRules) DeepCopy() *BuiltInRules {
+	if in == nil {
+		return nil
+	}
+	out := new(BuiltInRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DefenseInDepthOptions) DeepCopyInto(out *DefenseInDepthOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DefenseInDepthOptions.
+func (in *DefenseInDepthOptions) DeepCopy() *DefenseInDepthOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(DefenseInDepthOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftDetection) DeepCopyInto(out *DriftDetection) {
+	*out = *in
+	if in.Executables != nil {
+		in, out := &in.Executables, &out.Executables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftDetection.
+func (in *DriftDetection) DeepCopy() *DriftDetection {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftDetection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftDetectionOptions) DeepCopyInto(out *DriftDetectionOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftDetectionOptions.
+func (in *DriftDetectionOptions) DeepCopy() *DriftDetectionOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftDetectionOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DynamicResult) DeepCopyInto(out *DynamicResult) {
 	*out = *in
@@ -453,6 +570,59 @@ func (in *DynamicResult) DeepCopy() *DynamicResult {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressPods) DeepCopyInto(out *EgressPods) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressPods.
+func (in *EgressPods) DeepCopy() *EgressPods {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressPods)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressService) DeepCopyInto(out *EgressService) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressService.
+func (in *EgressService) DeepCopy() *EgressService {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnforcementCoverage) DeepCopyInto(out *EnforcementCoverage) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeCoverage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnforcementCoverage.
+func (in *EnforcementCoverage) DeepCopy() *EnforcementCoverage {
+	if in == nil {
+		return nil
+	}
+	out := new(EnforcementCoverage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnhanceProtect) DeepCopyInto(out *EnhanceProtect) {
 	*out = *in
@@ -473,17 +643,62 @@ func (in *EnhanceProtect) DeepCopyInto(out *EnhanceProtect) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AppArmorRules != nil {
+		in, out := &in.AppArmorRules, &out.AppArmorRules
+		*out = new(BuiltInRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BpfRules != nil {
+		in, out := &in.BpfRules, &out.BpfRules
+		*out = new(BuiltInRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SeccompRules != nil {
+		in, out := &in.SeccompRules, &out.SeccompRules
+		*out = new(BuiltInRules)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.AppArmorRawRules != nil {
 		in, out := &in.AppArmorRawRules, &out.AppArmorRawRules
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AppArmorRawSnippets != nil {
+		in, out := &in.AppArmorRawSnippets, &out.AppArmorRawSnippets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppArmorAbstractions != nil {
+		in, out := &in.AppArmorAbstractions, &out.AppArmorAbstractions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SELinuxRawRules != nil {
+		in, out := &in.SELinuxRawRules, &out.SELinuxRawRules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.BpfRawRules.DeepCopyInto(&out.BpfRawRules)
 	if in.SyscallRawRules != nil {
 		in, out := &in.SyscallRawRules, &out.SyscallRawRules
 		*out = make([]specs_go.LinuxSyscall, len(*in))
 		linuxSyscallDeepCopyInto(in, out)
 	}
+	if in.SyscallNotifyRules != nil {
+		in, out := &in.SyscallNotifyRules, &out.SyscallNotifyRules
+		*out = make([]SyscallNotifyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FileIntegrityRules != nil {
+		in, out := &in.FileIntegrityRules, &out.FileIntegrityRules
+		*out = make([]FileIntegrityRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.ReadOnlyFilesystem.DeepCopyInto(&out.ReadOnlyFilesystem)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnhanceProtect.
@@ -532,6 +747,46 @@ func (in *FileContent) DeepCopy() *FileContent {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileIntegrity) DeepCopyInto(out *FileIntegrity) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileIntegrity.
+func (in *FileIntegrity) DeepCopy() *FileIntegrity {
+	if in == nil {
+		return nil
+	}
+	out := new(FileIntegrity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileIntegrityRule) DeepCopyInto(out *FileIntegrityRule) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileIntegrityRule.
+func (in *FileIntegrityRule) DeepCopy() *FileIntegrityRule {
+	if in == nil {
+		return nil
+	}
+	out := new(FileIntegrityRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FileRule) DeepCopyInto(out *FileRule) {
 	*out = *in
@@ -540,6 +795,11 @@ func (in *FileRule) DeepCopyInto(out *FileRule) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.SHA256 != nil {
+		in, out := &in.SHA256, &out.SHA256
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileRule.
@@ -552,6 +812,71 @@ func (in *FileRule) DeepCopy() *FileRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HashProcessContent) DeepCopyInto(out *HashProcessContent) {
+	*out = *in
+	if in.SHA256 != nil {
+		in, out := &in.SHA256, &out.SHA256
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HashProcessContent.
+func (in *HashProcessContent) DeepCopy() *HashProcessContent {
+	if in == nil {
+		return nil
+	}
+	out := new(HashProcessContent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostProcessTarget) DeepCopyInto(out *HostProcessTarget) {
+	*out = *in
+	if in.Executables != nil {
+		in, out := &in.Executables, &out.Executables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SystemdUnits != nil {
+		in, out := &in.SystemdUnits, &out.SystemdUnits
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostProcessTarget.
+func (in *HostProcessTarget) DeepCopy() *HostProcessTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(HostProcessTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LifecycleHook) DeepCopyInto(out *LifecycleHook) {
+	*out = *in
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleHook.
+func (in *LifecycleHook) DeepCopy() *LifecycleHook {
+	if in == nil {
+		return nil
+	}
+	out := new(LifecycleHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModelingOptions) DeepCopyInto(out *ModelingOptions) {
 	*out = *in
@@ -636,6 +961,16 @@ func (in *NetworkContent) DeepCopy() *NetworkContent {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkEgressRule) DeepCopyInto(out *NetworkEgressRule) {
 	*out = *in
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(EgressService)
+		**out = **in
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(EgressPods)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkEgressRule.
@@ -648,13 +983,40 @@ func (in *NetworkEgressRule) DeepCopy() *NetworkEgressRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPeerContent) DeepCopyInto(out *NetworkPeerContent) {
+	*out = *in
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPeerContent.
+func (in *NetworkPeerContent) DeepCopy() *NetworkPeerContent {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPeerContent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkRule) DeepCopyInto(out *NetworkRule) {
 	*out = *in
 	if in.Egresses != nil {
 		in, out := &in.Egresses, &out.Egresses
 		*out = make([]NetworkEgressRule, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -668,6 +1030,51 @@ func (in *NetworkRule) DeepCopy() *NetworkRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeCompatibility) DeepCopyInto(out *NodeCompatibility) {
+	*out = *in
+	if in.Reasons != nil {
+		in, out := &in.Reasons, &out.Reasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeCompatibility.
+func (in *NodeCompatibility) DeepCopy() *NodeCompatibility {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeCompatibility)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeCoverage) DeepCopyInto(out *NodeCoverage) {
+	*out = *in
+	if in.FailureReasons != nil {
+		in, out := &in.FailureReasons, &out.FailureReasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PartialReasons != nil {
+		in, out := &in.PartialReasons, &out.PartialReasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeCoverage.
+func (in *NodeCoverage) DeepCopy() *NodeCoverage {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeCoverage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PathPattern) DeepCopyInto(out *PathPattern) {
 	*out = *in
@@ -688,6 +1095,15 @@ func (in *Policy) DeepCopyInto(out *Policy) {
 	*out = *in
 	in.EnhanceProtect.DeepCopyInto(&out.EnhanceProtect)
 	out.ModelingOptions = in.ModelingOptions
+	out.DriftDetectionOptions = in.DriftDetectionOptions
+	out.DefenseInDepthOptions = in.DefenseInDepthOptions
+	if in.LifecycleHooks != nil {
+		in, out := &in.LifecycleHooks, &out.LifecycleHooks
+		*out = make([]LifecycleHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
@@ -700,6 +1116,35 @@ func (in *Policy) DeepCopy() *Policy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyCompatibility) DeepCopyInto(out *PolicyCompatibility) {
+	*out = *in
+	if in.PartialNodes != nil {
+		in, out := &in.PartialNodes, &out.PartialNodes
+		*out = make([]NodeCompatibility, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UnsupportedNodes != nil {
+		in, out := &in.UnsupportedNodes, &out.UnsupportedNodes
+		*out = make([]NodeCompatibility, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyCompatibility.
+func (in *PolicyCompatibility) DeepCopy() *PolicyCompatibility {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyCompatibility)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Profile) DeepCopyInto(out *Profile) {
 	*out = *in
@@ -708,6 +1153,11 @@ func (in *Profile) DeepCopyInto(out *Profile) {
 		*out = new(BpfContent)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CompressedBpfContent != nil {
+		in, out := &in.CompressedBpfContent, &out.CompressedBpfContent
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Profile.
@@ -720,6 +1170,27 @@ func (in *Profile) DeepCopy() *Profile {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileRevision) DeepCopyInto(out *ProfileRevision) {
+	*out = *in
+	in.Timestamp.DeepCopyInto(&out.Timestamp)
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileRevision.
+func (in *ProfileRevision) DeepCopy() *ProfileRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Ptrace) DeepCopyInto(out *Ptrace) {
 	*out = *in
@@ -775,6 +1246,56 @@ func (in *PtraceRule) DeepCopy() *PtraceRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadOnlyFilesystem) DeepCopyInto(out *ReadOnlyFilesystem) {
+	*out = *in
+	if in.WritablePaths != nil {
+		in, out := &in.WritablePaths, &out.WritablePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadOnlyFilesystem.
+func (in *ReadOnlyFilesystem) DeepCopy() *ReadOnlyFilesystem {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadOnlyFilesystem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegexFileContent) DeepCopyInto(out *RegexFileContent) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegexFileContent.
+func (in *RegexFileContent) DeepCopy() *RegexFileContent {
+	if in == nil {
+		return nil
+	}
+	out := new(RegexFileContent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSuggestion) DeepCopyInto(out *RuleSuggestion) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSuggestion.
+func (in *RuleSuggestion) DeepCopy() *RuleSuggestion {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSuggestion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Seccomp) DeepCopyInto(out *Seccomp) {
 	*out = *in
@@ -835,6 +1356,26 @@ func (in *StaticResult) DeepCopy() *StaticResult {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyscallNotifyRule) DeepCopyInto(out *SyscallNotifyRule) {
+	*out = *in
+	if in.FsTypes != nil {
+		in, out := &in.FsTypes, &out.FsTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyscallNotifyRule.
+func (in *SyscallNotifyRule) DeepCopy() *SyscallNotifyRule {
+	if in == nil {
+		return nil
+	}
+	out := new(SyscallNotifyRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Target) DeepCopyInto(out *Target) {
 	*out = *in
@@ -848,6 +1389,16 @@ func (in *Target) DeepCopyInto(out *Target) {
 		*out = new(v1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HostProcess != nil {
+		in, out := &in.HostProcess, &out.HostProcess
+		*out = new(HostProcessTarget)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Target.
@@ -860,6 +1411,32 @@ func (in *Target) DeepCopy() *Target {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TighteningSuggestion) DeepCopyInto(out *TighteningSuggestion) {
+	*out = *in
+	in.GeneratedTime.DeepCopyInto(&out.GeneratedTime)
+	if in.Removals != nil {
+		in, out := &in.Removals, &out.Removals
+		*out = make([]RuleSuggestion, len(*in))
+		copy(*out, *in)
+	}
+	if in.BpfContent != nil {
+		in, out := &in.BpfContent, &out.BpfContent
+		*out = new(BpfContent)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TighteningSuggestion.
+func (in *TighteningSuggestion) DeepCopy() *TighteningSuggestion {
+	if in == nil {
+		return nil
+	}
+	out := new(TighteningSuggestion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VarmorClusterPolicy) DeepCopyInto(out *VarmorClusterPolicy) {
 	*out = *in
@@ -999,6 +1576,13 @@ func (in *VarmorPolicySpec) DeepCopyInto(out *VarmorPolicySpec) {
 	*out = *in
 	in.Target.DeepCopyInto(&out.Target)
 	in.Policy.DeepCopyInto(&out.Policy)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarmorPolicySpec.
@@ -1021,6 +1605,21 @@ func (in *VarmorPolicyStatus) DeepCopyInto(out *VarmorPolicyStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Coverage != nil {
+		in, out := &in.Coverage, &out.Coverage
+		*out = new(EnforcementCoverage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Compatibility != nil {
+		in, out := &in.Compatibility, &out.Compatibility
+		*out = new(PolicyCompatibility)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Suggestion != nil {
+		in, out := &in.Suggestion, &out.Suggestion
+		*out = new(TighteningSuggestion)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarmorPolicyStatus.
@@ -1032,3 +1631,89 @@ func (in *VarmorPolicyStatus) DeepCopy() *VarmorPolicyStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarmorViolation) DeepCopyInto(out *VarmorViolation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Records != nil {
+		in, out := &in.Records, &out.Records
+		*out = make([]ViolationRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarmorViolation.
+func (in *VarmorViolation) DeepCopy() *VarmorViolation {
+	if in == nil {
+		return nil
+	}
+	out := new(VarmorViolation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarmorViolation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarmorViolationList) DeepCopyInto(out *VarmorViolationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VarmorViolation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarmorViolationList.
+func (in *VarmorViolationList) DeepCopy() *VarmorViolationList {
+	if in == nil {
+		return nil
+	}
+	out := new(VarmorViolationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarmorViolationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ViolationRecord) DeepCopyInto(out *ViolationRecord) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.FirstTimestamp.DeepCopyInto(&out.FirstTimestamp)
+	in.LastTimestamp.DeepCopyInto(&out.LastTimestamp)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ViolationRecord.
+func (in *ViolationRecord) DeepCopy() *ViolationRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(ViolationRecord)
+	in.DeepCopyInto(out)
+	return out
+}
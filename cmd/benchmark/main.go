@@ -0,0 +1,76 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The benchmark generates the synthetic load of the BPF enforcer on the node, and reports the throughput of
+// applying and deleting the profiles, the memory of the inner maps and the latency deltas of the LSM hooks.
+// It must be run as root on a node whose BPF LSM is enabled, and the agent of vArmor should not run on it.
+//
+//	benchmark --profiles 100 --rules 50 --namespaces 10 --workers 4
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/bytedance/vArmor/pkg/benchmark"
+	"github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+)
+
+func main() {
+	var config benchmark.Config
+	var mapMemoryLimit uint64
+	var objectPath string
+	var workers int
+
+	flag.IntVar(&config.Profiles, "profiles", 10, "The count of the BPF profiles.")
+	flag.IntVar(&config.Rules, "rules", 50, "The count of the file rules of each profile.")
+	flag.IntVar(&config.NamespacesPerProfile, "namespaces", 10, "The count of the synthetic mnt namespaces that each profile is applied to.")
+	flag.IntVar(&config.Operations, "operations", 1000, "The count of the file opens run in each mnt ns to measure the latencies. The measurement is skipped if it's negative.")
+	flag.Uint64Var(&mapMemoryLimit, "mapMemoryLimit", 0, "The limit in MiB of the memory consumed by the inner maps, no limit if zero.")
+	flag.IntVar(&workers, "workers", 1, "The count of the goroutines that apply and delete the profiles concurrently, which stands for the --bpfWorkers of the agent.")
+	flag.StringVar(&objectPath, "objectPath", "", "The path of a custom BPF object file, the embedded one is used if it's empty.")
+	flag.Parse()
+	config.Options = bpfenforcer.Options{
+		MapMemoryLimit: mapMemoryLimit << 20,
+		ObjectPath:     objectPath,
+		Workers:        workers,
+	}
+
+	result, err := benchmark.Run(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "mnt namespaces\t%d\n", result.Namespaces)
+	fmt.Fprintf(w, "workers\t%d\n", result.Workers)
+	fmt.Fprintf(w, "apply\t%v\t%.1f/s\n", result.ApplyDuration, result.ApplyThroughput)
+	fmt.Fprintf(w, "delete\t%v\t%.1f/s\n", result.DeleteDuration, result.DeleteThroughput)
+	fmt.Fprintf(w, "inner maps\t%d\t%d bytes\n", result.InnerMaps, result.MapMemoryBytes)
+	w.Flush()
+
+	if result.OpenLatency == nil {
+		return
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATIONS\tBASELINE\tENFORCED\tDELTA")
+	l := result.OpenLatency
+	fmt.Fprintf(w, "%d\t%v\t%v\t%v\n", l.Operations, l.Baseline, l.Enforced, l.Delta())
+	w.Flush()
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The conformance command certifies that a node of the cluster enforces the BPF profiles of vArmor. It deploys
+// the canary pods of each rule class to the node with a generated test policy, validates that their operations are
+// denied and the violations are reported, and exits with 1 if any rule class fails.
+//
+//	conformance --node node-1 --namespace varmor-conformance
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	varmorclient "github.com/bytedance/vArmor/pkg/client/clientset/versioned"
+	"github.com/bytedance/vArmor/pkg/conformance"
+)
+
+func main() {
+	var config conformance.Config
+	var kubeconfig, classes string
+
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file, the default loading rules of kubectl are used if it's empty.")
+	flag.StringVar(&config.NodeName, "node", "", "The name of the node to certify.")
+	flag.StringVar(&config.Namespace, "namespace", "", "The namespace of the test policy and the canary pods, it must exist.")
+	flag.StringVar(&config.Image, "image", "", "The image of the canary pods, it must provide the busybox-compatible commands. busybox:1.36 is used if it's empty.")
+	flag.StringVar(&config.WebhookMatchLabel, "webhookMatchLabel", "", "The --webhookMatchLabel of the manager. sandbox.varmor.org/enable=true is used if it's empty.")
+	flag.StringVar(&classes, "classes", "", "The comma-separated rule classes to certify (file, bprm, capability, network, mount and ptrace), all of them are certified if it's empty.")
+	flag.DurationVar(&config.Timeout, "timeout", 2*time.Minute, "The timeout of waiting for the profile, the denials and the violations respectively.")
+	flag.BoolVar(&config.SkipViolations, "skipViolations", false, "Skip validating the violations of the denied operations. The rule classes are reported as SKIP, which doesn't pass.")
+	flag.Parse()
+
+	if config.NodeName == "" || config.Namespace == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if classes != "" {
+		config.Classes = strings.Split(classes, ",")
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+	kubeClient := kubernetes.NewForConfigOrDie(restConfig)
+	varmorClient := varmorclient.NewForConfigOrDie(restConfig)
+
+	report, err := conformance.Run(context.Background(), kubeClient, varmorClient, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conformance failed: %v\n", err)
+		if report == nil {
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("node: %s, kernel: %s, OS image: %s\n\n", report.NodeName, report.KernelVersion, report.OSImage)
+	if report.ViolationsUnsupported {
+		fmt.Printf("the violations are not validated since the BPF enforcer of the agent on the node doesn't emit the violation events\n\n")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CLASS\tDENIED\tREPORTED\tRESULT\tMESSAGE")
+	for _, result := range report.Results {
+		status := "FAIL"
+		if result.Passed {
+			status = "PASS"
+		} else if result.Skipped {
+			status = "SKIP"
+		}
+		message := strings.ReplaceAll(strings.TrimSpace(result.Message), "\n", " ")
+		fmt.Fprintf(w, "%s\t%v\t%v\t%s\t%s\n", result.Class, result.Denied, result.Reported, status, message)
+	}
+	w.Flush()
+
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The model-diff reports the behaviors added and removed between the behavior models of two releases of the
+// workloads, so the behavior changes of the dependencies can be reviewed before promoting the profile. It exits
+// with 1 if the new release has new behaviors.
+//
+//	kubectl get apm -n demo varmor-demo-v1 -o yaml > v1.yaml
+//	kubectl get apm -n demo varmor-demo-v2 -o yaml > v2.yaml
+//	model-diff --from v1.yaml --to v2.yaml
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/internal/profile/export"
+)
+
+var (
+	fromPath string
+	toPath   string
+	format   string
+)
+
+func decodeFile(path string, obj interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(obj)
+}
+
+// loadReport reads the behavior model from the manifest of an ArmorProfileModel object, or from the JSON report
+// exported with the status service
+func loadReport(path string) (*export.ModelReport, error) {
+	var header struct {
+		metav1.TypeMeta `json:",inline"`
+		SchemaVersion   string `json:"schemaVersion"`
+	}
+	if err := decodeFile(path, &header); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case header.SchemaVersion == export.SchemaVersion:
+		var report export.ModelReport
+		if err := decodeFile(path, &report); err != nil {
+			return nil, err
+		}
+		return &report, nil
+	case header.Kind == "ArmorProfileModel":
+		var apm varmor.ArmorProfileModel
+		if err := decodeFile(path, &apm); err != nil {
+			return nil, err
+		}
+		return export.NewModelReport(&apm)
+	case header.SchemaVersion != "":
+		return nil, fmt.Errorf("unsupported schema version '%s' of the report", header.SchemaVersion)
+	default:
+		return nil, fmt.Errorf("unknown kind '%s' of the behavior model", header.Kind)
+	}
+}
+
+func main() {
+	flag.StringVar(&fromPath, "from", "", "The ArmorProfileModel manifest or the JSON report of the previous release.")
+	flag.StringVar(&toPath, "to", "", "The ArmorProfileModel manifest or the JSON report of the new release.")
+	flag.StringVar(&format, "format", "text", "The output format, text or json.")
+	flag.Parse()
+
+	if fromPath == "" || toPath == "" || (format != "text" && format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	from, err := loadReport(fromPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the behavior model of the previous release: %v\n", err)
+		os.Exit(2)
+	}
+
+	to, err := loadReport(toPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the behavior model of the new release: %v\n", err)
+		os.Exit(2)
+	}
+
+	diff := export.DiffModelReports(from, to)
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	} else {
+		err = diff.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write the diff: %v\n", err)
+		os.Exit(2)
+	}
+
+	if diff.HasNewBehaviors() {
+		os.Exit(1)
+	}
+}
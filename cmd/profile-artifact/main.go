@@ -0,0 +1,264 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The profile-artifact command exports the profiles of a cluster as OCI artifacts, and imports them into
+// the ArmorProfileModel objects of another cluster, so they can be enforced with the DefenseInDepth mode.
+//
+//	profile-artifact export --namespace demo --name varmor-demo-demo --ref registry.example.com/profiles/demo:v1 --key cosign.key
+//	profile-artifact import --namespace demo --policy demo --ref registry.example.com/profiles/demo@sha256:... --key cosign.pub
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/internal/artifact"
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmorprofile "github.com/bytedance/vArmor/internal/profile"
+	varmorclient "github.com/bytedance/vArmor/pkg/client/clientset/versioned"
+)
+
+type options struct {
+	kubeconfig string
+	namespace  string
+	ref        string
+	username   string
+	password   string
+	plainHTTP  bool
+	insecure   bool
+}
+
+func (o *options) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file, the default loading rules of kubectl are used if it's empty.")
+	fs.StringVar(&o.namespace, "namespace", "", "The namespace of the object.")
+	fs.StringVar(&o.ref, "ref", "", "The reference of the artifact, e.g. registry.example.com/profiles/demo:v1 or registry.example.com/profiles/demo@sha256:...")
+	fs.StringVar(&o.username, "username", os.Getenv("VARMOR_REGISTRY_USERNAME"), "The username of the registry, it defaults to $VARMOR_REGISTRY_USERNAME.")
+	fs.StringVar(&o.password, "password", os.Getenv("VARMOR_REGISTRY_PASSWORD"), "The password of the registry, it defaults to $VARMOR_REGISTRY_PASSWORD.")
+	fs.BoolVar(&o.plainHTTP, "plainHTTP", false, "Access the registry with HTTP instead of HTTPS.")
+	fs.BoolVar(&o.insecure, "insecure", false, "Skip the verification of the registry's certificate.")
+}
+
+func (o *options) newClient() (*varmorclient.Clientset, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return varmorclient.NewForConfig(config)
+}
+
+func (o *options) newRegistry() *artifact.Registry {
+	return artifact.NewRegistry(o.username, o.password, o.plainHTTP, o.insecure)
+}
+
+// exportProfile pushes the profile of the ArmorProfile or ArmorProfileModel object to the registry
+func exportProfile(args []string) error {
+	var o options
+	var name, source, keyPath string
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	o.addFlags(fs)
+	fs.StringVar(&name, "name", "", "The name of the ArmorProfile or ArmorProfileModel object.")
+	fs.StringVar(&source, "source", "model", "Export the profile of the ArmorProfileModel object (model) or the ArmorProfile object (profile).")
+	fs.StringVar(&keyPath, "key", "", "Path to the PEM-encoded private key (ECDSA, Ed25519 or RSA) to sign the profile. The profile isn't signed if it's empty.")
+	fs.Parse(args)
+
+	if o.namespace == "" || name == "" || o.ref == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	client, err := o.newClient()
+	if err != nil {
+		return err
+	}
+
+	var profile varmor.Profile
+	switch source {
+	case "model":
+		apm, err := client.CrdV1beta1().ArmorProfileModels(o.namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		profile = apm.Data.Profile
+		if profile.Name == "" {
+			profile.Name = apm.Name
+		}
+	case "profile":
+		ap, err := client.CrdV1beta1().ArmorProfiles(o.namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		profile = ap.Spec.Profile
+	default:
+		return fmt.Errorf("unknown source '%s'", source)
+	}
+
+	if profile.Content == "" && profile.BpfContent == nil && profile.SeccompContent == "" {
+		return fmt.Errorf("no profile found in %s/%s", o.namespace, name)
+	}
+
+	var signature string
+	if keyPath != "" {
+		key, err := artifact.LoadPrivateKey(keyPath)
+		if err != nil {
+			return err
+		}
+		signature, err = artifact.SignProfile(&profile, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	d, err := artifact.Push(context.Background(), o.newRegistry(), o.ref, &profile, signature)
+	if err != nil {
+		return err
+	}
+
+	r, _ := artifact.ParseReference(o.ref)
+	r.Tag = ""
+	r.Digest = d
+	fmt.Printf("pushed %s\n", r.String())
+	return nil
+}
+
+// importProfile pulls the profile from the registry, and saves it to the ArmorProfileModel object of the policy
+func importProfile(args []string) error {
+	var o options
+	var policyName, keyPath string
+	var clusterScope bool
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	o.addFlags(fs)
+	fs.StringVar(&policyName, "policy", "", "The name of the VarmorPolicy or VarmorClusterPolicy object which will use the profile.")
+	fs.BoolVar(&clusterScope, "cluster", false, "Import the profile for the VarmorClusterPolicy object.")
+	fs.StringVar(&keyPath, "key", "", "Path to the PEM-encoded public key to verify the signature of the profile before importing it. The verification is skipped if it's empty.")
+	fs.StringVar(&varmorconfig.Namespace, "varmorNamespace", varmorconfig.Namespace, "The namespace where vArmor is installed, it's used for the VarmorClusterPolicy object.")
+	fs.Parse(args)
+
+	if policyName == "" || o.ref == "" || (o.namespace == "" && !clusterScope) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	client, err := o.newClient()
+	if err != nil {
+		return err
+	}
+
+	a, err := artifact.Pull(context.Background(), o.newRegistry(), o.ref)
+	if err != nil {
+		return err
+	}
+	profile := a.Profile
+
+	if keyPath != "" {
+		key, err := artifact.LoadPublicKey(keyPath)
+		if err != nil {
+			return err
+		}
+		err = artifact.VerifyProfile(profile, a.Signature, key)
+		if err != nil {
+			return fmt.Errorf("failed to verify the profile: %v", err)
+		}
+	}
+
+	name := varmorprofile.GenerateArmorProfileName(o.namespace, policyName, clusterScope)
+	namespace := o.namespace
+	if clusterScope {
+		namespace = varmorconfig.Namespace
+	}
+
+	// The name of AppArmor profile is embedded in the content
+	if profile.Name != "" && profile.Name != name {
+		profile.Content = strings.ReplaceAll(profile.Content, profile.Name, name)
+	}
+	profile.Name = name
+
+	apms := client.CrdV1beta1().ArmorProfileModels(namespace)
+	apm, err := apms.Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		if !k8errors.IsNotFound(err) {
+			return err
+		}
+		apm = &varmor.ArmorProfileModel{}
+		apm.Name = name
+		apm.Namespace = namespace
+		apm.Data.Profile = *profile
+		markImported(apm, a.Signature)
+		_, err = apms.Create(context.Background(), apm, metav1.CreateOptions{})
+	} else {
+		apm.Data.Profile = *profile
+		markImported(apm, a.Signature)
+		_, err = apms.Update(context.Background(), apm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %s into %s/%s (digest: %s)\n", o.ref, namespace, name, a.Digest)
+	if !strings.Contains(o.ref, "@") {
+		fmt.Printf("the reference isn't pinned by digest, use %s@%s to import the same artifact reproducibly\n", o.ref, a.Digest)
+	}
+	if a.Signature == "" {
+		fmt.Println("the profile isn't signed, it will be rejected by the manager if the profile verification is enabled")
+	}
+	return nil
+}
+
+// markImported marks the profile of the ArmorProfileModel object as imported, and saves its signature in the
+// annotations, so the manager can verify the profile before using it.
+func markImported(apm *varmor.ArmorProfileModel, signature string) {
+	if apm.Annotations == nil {
+		apm.Annotations = make(map[string]string)
+	}
+	apm.Annotations[artifact.ProfileSourceAnnotation] = artifact.ProfileSourceArtifact
+	if signature == "" {
+		delete(apm.Annotations, artifact.ProfileSignatureAnnotation)
+		return
+	}
+	apm.Annotations[artifact.ProfileSignatureAnnotation] = signature
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: profile-artifact export|import [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = exportProfile(os.Args[2:])
+	case "import":
+		err = importProfile(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, "usage: profile-artifact export|import [flags]")
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
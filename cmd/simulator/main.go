@@ -0,0 +1,116 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The simulator reports which behaviors recorded by the behavior modeling would have been denied by
+// a candidate VarmorPolicy or VarmorClusterPolicy, so the policy can be tuned before enforcing.
+//
+//	kubectl get apm -n demo varmor-demo-modeling -o yaml > model.yaml
+//	simulator --policy policy.yaml --model model.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorprofile "github.com/bytedance/vArmor/internal/profile"
+)
+
+var (
+	policyPath string
+	modelPath  string
+)
+
+func decodeFile(path string, obj interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(obj)
+}
+
+// loadPolicy reads the policy from the manifest of a VarmorPolicy or VarmorClusterPolicy object
+func loadPolicy(path string) (*varmor.Policy, error) {
+	var typeMeta metav1.TypeMeta
+	if err := decodeFile(path, &typeMeta); err != nil {
+		return nil, err
+	}
+
+	switch typeMeta.Kind {
+	case "VarmorPolicy":
+		var vp varmor.VarmorPolicy
+		if err := decodeFile(path, &vp); err != nil {
+			return nil, err
+		}
+		return &vp.Spec.Policy, nil
+	case "VarmorClusterPolicy":
+		var vcp varmor.VarmorClusterPolicy
+		if err := decodeFile(path, &vcp); err != nil {
+			return nil, err
+		}
+		return &vcp.Spec.Policy, nil
+	default:
+		return nil, fmt.Errorf("unknown kind '%s' of the policy", typeMeta.Kind)
+	}
+}
+
+func main() {
+	flag.StringVar(&policyPath, "policy", "", "The manifest of the candidate VarmorPolicy or VarmorClusterPolicy object.")
+	flag.StringVar(&modelPath, "model", "", "The manifest of the ArmorProfileModel object which holds the recorded behaviors.")
+	flag.Parse()
+
+	if policyPath == "" || modelPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	policy, err := loadPolicy(policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the policy: %v\n", err)
+		os.Exit(2)
+	}
+
+	var apm varmor.ArmorProfileModel
+	if err := decodeFile(modelPath, &apm); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the behavior model: %v\n", err)
+		os.Exit(2)
+	}
+
+	denied, err := varmorprofile.SimulatePolicy(*policy, &apm.Data.DynamicResult)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to simulate the policy: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(denied) == 0 {
+		fmt.Println("No recorded behavior would be denied by the policy.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tSUBJECT\tPERMISSIONS\tRULE ID")
+	for _, d := range denied {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Type, d.Subject, strings.Join(d.Permissions, ","), d.RuleID)
+	}
+	w.Flush()
+	os.Exit(1)
+}
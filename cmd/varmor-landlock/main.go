@@ -0,0 +1,73 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The varmor-landlock command is the launcher of the Landlock enforcer. It's injected into the target containers
+// by the mutation webhook to wrap their commands. It restricts itself with the Landlock profile saved by the agent,
+// then executes the original command, so the restriction is inherited by all the processes of the container.
+//
+//	varmor-landlock --profile /var/run/varmor/landlock/varmor-demo-demo -- nginx -g "daemon off;"
+//
+// It must be built statically (CGO_ENABLED=0), since it runs with the libraries of the target containers.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	varmorlandlock "github.com/bytedance/vArmor/pkg/lsm/landlock"
+)
+
+func main() {
+	var profile string
+
+	fs := flag.NewFlagSet("varmor-landlock", flag.ExitOnError)
+	fs.StringVar(&profile, "profile", "", "The path of the Landlock profile.")
+	fs.Parse(os.Args[1:])
+
+	args := fs.Args()
+	if profile == "" || len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: varmor-landlock --profile <path> -- <command> [args...]")
+		os.Exit(2)
+	}
+
+	// Fail closed if the profile hasn't been saved by the agent, the container restarts until it's available.
+	ruleset, err := varmorlandlock.LoadRuleset(profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "varmor-landlock: failed to load the Landlock profile: %v\n", err)
+		os.Exit(1)
+	}
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "varmor-landlock: %v\n", err)
+		os.Exit(127)
+	}
+
+	// The Landlock domain is set on the calling thread, so the command is executed on the same thread.
+	runtime.LockOSThread()
+
+	err = varmorlandlock.Restrict(ruleset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "varmor-landlock: failed to restrict the container: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = syscall.Exec(path, args, os.Environ())
+	fmt.Fprintf(os.Stderr, "varmor-landlock: failed to execute %s: %v\n", args[0], err)
+	os.Exit(126)
+}
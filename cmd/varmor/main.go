@@ -16,32 +16,43 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/kyverno/kyverno/pkg/leaderelection"
+	"k8s.io/client-go/discovery/cached/memory"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	log "sigs.k8s.io/controller-runtime/pkg/log"
 
 	varmoragent "github.com/bytedance/vArmor/internal/agent"
+	"github.com/bytedance/vArmor/internal/artifact"
 	"github.com/bytedance/vArmor/internal/config"
 	"github.com/bytedance/vArmor/internal/policy"
 	"github.com/bytedance/vArmor/internal/policycacher"
 	"github.com/bytedance/vArmor/internal/status"
 	varmortls "github.com/bytedance/vArmor/internal/tls"
+	varmortracing "github.com/bytedance/vArmor/internal/tracing"
 	varmorutils "github.com/bytedance/vArmor/internal/utils"
 	"github.com/bytedance/vArmor/internal/webhookconfig"
 	"github.com/bytedance/vArmor/internal/webhooks"
 	varmorclient "github.com/bytedance/vArmor/pkg/client/clientset/versioned"
 	varmorinformer "github.com/bytedance/vArmor/pkg/client/informers/externalversions"
+	varmorbpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+	varmorruntime "github.com/bytedance/vArmor/pkg/runtime"
 	"github.com/bytedance/vArmor/pkg/signal"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
 )
 
 const (
@@ -50,24 +61,65 @@ const (
 )
 
 var (
-	kubeconfig               string
-	webhookTimeout           int
-	agent                    bool
-	restartExistWorkloads    bool
-	enableBehaviorModeling   bool
-	enableBpfEnforcer        bool
-	unloadAllAaProfiles      bool
-	removeAllSeccompProfiles bool
-	clientRateLimitQPS       float64
-	clientRateLimitBurst     int
-	managerIP                string
-	webhookMatchLabel        string
-	bpfExclusiveMode         bool
-	statusUpdateCycle        time.Duration
-	setupLog                 = log.Log.WithName("SETUP")
+	kubeconfig                    string
+	webhookTimeout                int
+	agent                         bool
+	restartExistWorkloads         bool
+	enableBehaviorModeling        bool
+	enableBpfEnforcer             bool
+	enableLandlockEnforcer        bool
+	enableSELinuxEnforcer         bool
+	enableSeccompNotify           bool
+	enableNriPlugin               bool
+	unloadAllAaProfiles           bool
+	removeAllSeccompProfiles      bool
+	keepBpfEnforcement            bool
+	annotateEnforcements          bool
+	maxEnforcementSuspension      time.Duration
+	enableAgentMTLS               bool
+	clientRateLimitQPS            float64
+	clientRateLimitBurst          int
+	managerIP                     string
+	webhookMatchLabel             string
+	bpfExclusiveMode              bool
+	bpfProfileLayering            bool
+	statusUpdateCycle             time.Duration
+	metricsPort                   int
+	debugPort                     int
+	taskChannelCapacity           int
+	bpfWorkers                    int
+	bpfJournalPath                string
+	bpfMapOpLogRate               int
+	bpfMapOpLogSampling           int
+	bpfMapMemoryLimit             uint64
+	bpfPressureStallThreshold     float64
+	bpfMapWarmUp                  bool
+	bpfApplyLatencySLO            time.Duration
+	bpfDefaultProfile             string
+	bpfDefaultExcludedNamespaces  string
+	bpfViolationAggregationWindow time.Duration
+	clusterPodCIDRs               string
+	clusterServiceCIDRs           string
+	containerdEndpoints           string
+	monitorNamespaces             string
+	monitorExcludedNamespaces     string
+	monitorPodSelector            string
+	monitorAnnotationPrefixes     string
+	spiffeTrustDomain             string
+	enableTracing                 bool
+	profileVerificationKey        string
+	gatekeeperClientCA            string
+	ruleExceptionAllowList        string
+	ruleGroupsFile                string
+	enableAnomalyDetection        bool
+	enableSelfDefense             bool
+	setupLog                      = log.Log.WithName("SETUP")
 )
 
 func main() {
+	// Run as the helper process if the agent was re-executed by the self-test of the BPF enforcer.
+	varmorbpfenforcer.RunSelfTestHelper()
+
 	klog.InitFlags(nil)
 	log.SetLogger(klogr.New())
 
@@ -77,14 +129,52 @@ func main() {
 	flag.BoolVar(&restartExistWorkloads, "restartExistWorkloads", false, "Set this flag to allow users control whether or not to restart existing workloads with the .spec.updateExistingWorkloads feild.")
 	flag.BoolVar(&enableBehaviorModeling, "enableBehaviorModeling", false, "Set this flag to enable BehaviorModeling feature (Note: this is an experimental feature, please do not enable it in production environment).")
 	flag.BoolVar(&enableBpfEnforcer, "enableBpfEnforcer", false, "Set this flag to enable BPF enforcer.")
+	flag.BoolVar(&enableLandlockEnforcer, "enableLandlockEnforcer", false, "Set this flag to enable Landlock enforcer, which is the lighter alternative of the BPF enforcer for the file rules.")
+	flag.BoolVar(&enableSELinuxEnforcer, "enableSELinuxEnforcer", false, "Set this flag to enable SELinux enforcer, which is used on the RHEL-family nodes that disable AppArmor.")
+	flag.BoolVar(&enableSeccompNotify, "enableSeccompNotify", false, "Set this flag to enable the seccomp user notification handler of agent, which is required by the syscallNotifyRules of policies.")
+	flag.BoolVar(&enableNriPlugin, "enableNriPlugin", false, "Set this flag to register the agent as an NRI (Node Resource Interface) plugin of the container runtime, so the BPF profiles are enforced before the entrypoints of the containers run, and the containers fail to start if their profiles can't be applied. It requires containerd 1.7+ with NRI enabled.")
 	flag.BoolVar(&unloadAllAaProfiles, "unloadAllAaProfiles", false, "Unload all AppArmor profiles when the agent exits.")
 	flag.BoolVar(&removeAllSeccompProfiles, "removeAllSeccompProfiles", false, "Remove all Seccomp profiles when the agent exits.")
+	flag.BoolVar(&keepBpfEnforcement, "keepBpfEnforcementOnShutdown", false, "Leave the BPF enforcement in place when the agent exits. The BPF programs are pinned to /sys/fs/bpf/varmor, and they're detached after the restarted agent reapplies the profiles to the existing containers.")
+	flag.BoolVar(&annotateEnforcements, "annotateEnforcements", false, "Write the BPF profiles enforced for the containers back to the 'enforcement.varmor.org/containers' annotation of the pods. The agent requires the permission to patch the pods.")
+	flag.DurationVar(&maxEnforcementSuspension, "maxEnforcementSuspension", 0, "Allow suspending the BPF enforcement of a container temporarily for debugging with the 'suspend.varmor.org/<container name>' annotation of the pod, whose value is the time in RFC 3339 to resume the enforcement. The total suspension of a container is capped by the duration, and it's disabled if zero. The agent requires the permission to list and watch the pods.")
 	flag.Float64Var(&clientRateLimitQPS, "clientRateLimitQPS", 0, "Configure the maximum QPS to the master from vArmor. Uses the client default if zero.")
+	flag.BoolVar(&enableAgentMTLS, "enableAgentMTLS", false, "Set this flag to enable the mutual TLS between agents and manager. The manager issues the client certificates of agents and rotates them, it must be set for both of them.")
 	flag.IntVar(&clientRateLimitBurst, "clientRateLimitBurst", 0, "Configure the maximum burst for throttle. Uses the client default if zero.")
 	flag.StringVar(&managerIP, "managerIP", "0.0.0.0", "Configure the IP address of manager.")
 	flag.StringVar(&webhookMatchLabel, "webhookMatchLabel", "sandbox.varmor.org/enable=true", "Configure the matchLabel of webhook configuration, the valid format is key=value or nil")
 	flag.BoolVar(&bpfExclusiveMode, "bpfExclusiveMode", false, "Set this flag to enable exclusive mode for the BPF enforcer. It will disable the AppArmor confinement when using the BPF enforcer.")
+	flag.BoolVar(&bpfProfileLayering, "bpfProfileLayering", false, "Set this flag to layer the BPF profile of the VarmorClusterPolicy object under the one of the VarmorPolicy object when both of them match a workload. The rules of the VarmorClusterPolicy object take precedence.")
 	flag.DurationVar(&statusUpdateCycle, "statusUpdateCycle", time.Hour*2, "Configure the status update cycle for VarmorPolicy and ArmorProfile")
+	flag.IntVar(&taskChannelCapacity, "taskChannelCapacity", varmortypes.DefaultTaskChannelCapacity, "Configure the capacity of the channels which send the container events from the runtime monitor to the BPF enforcer.")
+	flag.IntVar(&bpfWorkers, "bpfWorkers", 1, "Configure the count of the workers that apply and delete the BPF profiles of the containers in parallel. The events of a container are always handled by the same worker in order. Tune it with the benchmark command on the nodes with thousands of containers.")
+	flag.StringVar(&bpfJournalPath, "bpfJournalPath", "", "Configure the path of the journal of the BPF profile operations. The operations of the agent that crashed are replayed from it after restarting. It should be on the host. Leave it empty to disable the journal.")
+	flag.IntVar(&bpfMapOpLogRate, "bpfMapOpLogRate", 0, "Configure the maximum count of the operations on the entries of the BPF maps that are logged per second, with the rule class, the key and the mnt ns of the entries. It's disabled if zero.")
+	flag.IntVar(&bpfMapOpLogSampling, "bpfMapOpLogSampling", 1, "Configure the sampling of the logging of the BPF map operations, one of every N operations is logged.")
+	flag.Uint64Var(&bpfMapMemoryLimit, "bpfMapMemoryLimit", 0, "Configure the maximum memory in MiB consumed by the inner maps of the BPF enforcer on the node. The BPF profiles that would exceed it fail to apply. It's unlimited if zero.")
+	flag.Float64Var(&bpfPressureStallThreshold, "bpfPressureStallThreshold", 0, "Configure the percentage of the memory stall of the node (the full avg10 of /proc/pressure/memory) at which the BPF enforcer stops onboarding the new containers until the pressure subsides. The repeated ENOMEM failures of the BPF map allocations also trigger it. It's disabled if zero.")
+	flag.BoolVar(&bpfMapWarmUp, "bpfMapWarmUp", false, "Stage the inner maps of the BPF profiles for the containers of the pods on the node before their tasks are created, e.g. while their images are being pulled, so only the outer maps are updated when the containers start. The agent requires the permission to list and watch the pods.")
+	flag.DurationVar(&bpfApplyLatencySLO, "bpfApplyLatencySLO", time.Second, "Configure the objective of the time from the container creation to the BPF profile being enforced. The breaches are counted in the metrics and logged.")
+	flag.DurationVar(&bpfViolationAggregationWindow, "bpfViolationAggregationWindow", 10*time.Second, "Configure the window of aggregating the identical violations of the BPF enforcer into one with the count. A negative value disables the aggregation.")
+	flag.StringVar(&clusterPodCIDRs, "clusterPodCIDRs", "", "Configure the comma-separated list of the pod CIDRs of the cluster, e.g. 10.244.0.0/16,fd00:10:244::/56. They are matched by the @cluster-pods macro of the network rules of the BPF enforcer.")
+	flag.StringVar(&clusterServiceCIDRs, "clusterServiceCIDRs", "", "Configure the comma-separated list of the service CIDRs of the cluster, e.g. 10.96.0.0/12. They are matched by the @cluster-services macro of the network rules of the BPF enforcer.")
+	flag.StringVar(&containerdEndpoints, "containerdEndpoints", "", "Configure the comma-separated list of the containerd endpoints watched by the runtime monitor in the format of SOCKET[@NAMESPACE], e.g. /run/containerd/containerd.sock,/run/k3s/containerd/containerd.sock@k8s.io. The namespace defaults to k8s.io. It watches /run/containerd/containerd.sock if empty.")
+	flag.StringVar(&monitorNamespaces, "monitorNamespaces", "", "Configure the comma-separated list of the namespaces of the pods whose containers are handled by the runtime monitor of agent. All namespaces are handled if empty.")
+	flag.StringVar(&monitorExcludedNamespaces, "monitorExcludedNamespaces", "", "Configure the comma-separated list of the namespaces of the pods whose containers are skipped by the runtime monitor of agent.")
+	flag.StringVar(&monitorPodSelector, "monitorPodSelector", "", "Configure the label selector of the pods whose containers are handled by the runtime monitor of agent, e.g. tier in (web, api). All pods are handled if empty.")
+	flag.StringVar(&monitorAnnotationPrefixes, "monitorAnnotationPrefixes", "", "Configure the comma-separated list of the annotation key prefixes, the runtime monitor of agent skips the pods that have no annotation with them, e.g. container.bpf.security.beta.varmor.org/. All pods are handled if empty.")
+	flag.StringVar(&spiffeTrustDomain, "spiffeTrustDomain", "cluster.local", "Configure the trust domain of the SPIFFE IDs which are derived from the service accounts of the workloads and attached to the violations. It's disabled if empty.")
+	flag.StringVar(&bpfDefaultProfile, "bpfDefaultProfile", "", "Configure the name of the BPF profile that the containers without any profile are enforced with, e.g. varmor-cluster-varmor-baseline for the VarmorClusterPolicy named baseline. It enables the default-deny mode of the node. It's disabled if empty.")
+	flag.StringVar(&bpfDefaultExcludedNamespaces, "bpfDefaultProfileExcludedNamespaces", "kube-system", "Configure the comma-separated list of the namespaces that the default BPF profile isn't enforced on. The namespace of vArmor is always excluded.")
+	flag.IntVar(&metricsPort, "metricsPort", 0, "Configure the port of agent to expose the metrics at /debug/vars. It's disabled if zero.")
+	flag.IntVar(&debugPort, "debugPort", 0, "Configure the port of agent to expose the enforcements at /debug/enforcements and the suspensions at /debug/suspensions. It only listens on the loopback interface, and it's disabled if zero.")
+	flag.StringVar(&profileVerificationKey, "profileVerificationKey", "", "Path to the PEM-encoded public key. The manager verifies the signatures of the profiles imported into the ArmorProfileModel objects with it before using them. It's disabled if empty.")
+	flag.StringVar(&gatekeeperClientCA, "gatekeeperClientCA", "", "Path to the PEM-encoded CA certificate of OPA Gatekeeper. The manager serves the external data provider API for Gatekeeper and authenticates its client certificates with it. It's disabled if empty.")
+	flag.StringVar(&ruleExceptionAllowList, "ruleExceptionAllowList", "", "Configure the comma-separated list of the built-in rules which are allowed to be excepted for pods with the exception.varmor.org/rules annotation. It's disabled if empty.")
+	flag.StringVar(&ruleGroupsFile, "ruleGroupsFile", "", "Path to the YAML or JSON file that lists the rule groups of the platform. The manager registers them as the built-in rules of the BPF enforcer at startup, so the policies can reference them by their names. It's disabled if empty.")
+	flag.BoolVar(&enableAnomalyDetection, "enableAnomalyDetection", false, "Set this flag to baseline the violation rates per workload, and raise the unusual bursts and the rules that never fired before as the warning events of ArmorProfile objects.")
+	flag.BoolVar(&enableSelfDefense, "enableSelfDefense", false, "Set this flag to add the disallow-tamper-varmor rule to the profiles of the EnhanceProtect mode, which prohibits the target workloads from tampering with the pinned BPF programs, the sockets and the profiles of vArmor on the host.")
+	flag.BoolVar(&enableTracing, "enableTracing", false, "Set this flag to trace the profile lifecycle operations with OpenTelemetry, the spans are exported to stdout.")
 
 	if err := flag.Set("v", "2"); err != nil {
 		setupLog.Error(err, "flag.Set()")
@@ -102,9 +192,48 @@ func main() {
 		config.WebhookSelectorLabel[labelKvs[0]] = labelKvs[1]
 	}
 
+	// Load the public key to verify the signatures of the imported profiles.
+	if profileVerificationKey != "" {
+		key, err := artifact.LoadPublicKey(profileVerificationKey)
+		if err != nil {
+			setupLog.Error(err, "artifact.LoadPublicKey()")
+			os.Exit(1)
+		}
+		config.ProfileVerificationKey = key
+	}
+
+	config.EnableViolationAnomalyDetection = enableAnomalyDetection
+	config.EnableSelfDefense = enableSelfDefense
+
+	exceptionAllowList := splitList(ruleExceptionAllowList)
+
+	// Load the CA certificate to authenticate the requests of OPA Gatekeeper.
+	var gatekeeperCA []byte
+	if gatekeeperClientCA != "" {
+		ca, err := os.ReadFile(gatekeeperClientCA)
+		if err != nil {
+			setupLog.Error(err, "os.ReadFile()")
+			os.Exit(1)
+		}
+		gatekeeperCA = ca
+	}
+
 	debug := kubeconfig != ""
 	stopCh := signal.SetupSignalHandler()
 
+	if enableTracing {
+		serviceName := "varmor-manager"
+		if agent {
+			serviceName = "varmor-agent"
+		}
+		shutdown, err := varmortracing.Init(serviceName)
+		if err != nil {
+			setupLog.Error(err, "varmortracing.Init()")
+			os.Exit(1)
+		}
+		defer shutdown(context.Background())
+	}
+
 	clientConfig, err := config.CreateClientConfig(kubeconfig, clientRateLimitQPS, clientRateLimitBurst, log.Log)
 	if err != nil {
 		setupLog.Error(err, "config.CreateClientConfig()")
@@ -130,14 +259,53 @@ func main() {
 	if agent {
 		setupLog.Info("vArmor agent startup")
 
+		endpoints, err := varmorruntime.ParseEndpoints(containerdEndpoints)
+		if err != nil {
+			setupLog.Error(err, "varmorruntime.ParseEndpoints()")
+			os.Exit(1)
+		}
+
+		eventFilter, err := varmorruntime.ParseEventFilter(monitorNamespaces, monitorExcludedNamespaces, monitorPodSelector, monitorAnnotationPrefixes)
+		if err != nil {
+			setupLog.Error(err, "varmorruntime.ParseEventFilter()")
+			os.Exit(1)
+		}
+
 		agentCtrl, err := varmoragent.NewAgent(
 			kubeClient.CoreV1().Pods(config.Namespace),
+			kubeClient.CoreV1().Nodes(),
+			kubeClient.CoreV1(),
 			varmorClient.CrdV1beta1(),
 			varmorInformer.Crd().V1beta1().ArmorProfiles(),
 			enableBehaviorModeling,
 			enableBpfEnforcer,
+			enableLandlockEnforcer,
+			enableSELinuxEnforcer,
+			enableSeccompNotify,
+			enableNriPlugin,
+			taskChannelCapacity,
+			bpfWorkers,
+			bpfJournalPath,
+			bpfMapOpLogRate,
+			bpfMapOpLogSampling,
+			bpfMapMemoryLimit<<20,
+			bpfPressureStallThreshold,
+			bpfMapWarmUp,
+			bpfApplyLatencySLO,
+			bpfViolationAggregationWindow,
+			bpfDefaultProfile,
+			splitList(bpfDefaultExcludedNamespaces),
+			splitList(clusterPodCIDRs),
+			splitList(clusterServiceCIDRs),
+			endpoints,
+			eventFilter,
+			spiffeTrustDomain,
 			unloadAllAaProfiles,
 			removeAllSeccompProfiles,
+			keepBpfEnforcement,
+			annotateEnforcements,
+			maxEnforcementSuspension,
+			enableAgentMTLS,
 			debug,
 			managerIP,
 			config.StatusServicePort,
@@ -150,6 +318,30 @@ func main() {
 			os.Exit(1)
 		}
 
+		if metricsPort != 0 {
+			go func() {
+				mux := http.NewServeMux()
+				mux.Handle("/debug/vars", expvar.Handler())
+				err := http.ListenAndServe(fmt.Sprintf(":%d", metricsPort), mux)
+				if err != nil {
+					setupLog.Error(err, "failed to serve the metrics")
+				}
+			}()
+		}
+
+		if debugPort != 0 {
+			go func() {
+				// The pod names and the suspensions are only exposed to the local users of the node
+				mux := http.NewServeMux()
+				mux.HandleFunc("/debug/enforcements", agentCtrl.ServeEnforcements)
+				mux.HandleFunc("/debug/suspensions", agentCtrl.ServeSuspensions)
+				err := http.ListenAndServe(fmt.Sprintf("127.0.0.1:%d", debugPort), mux)
+				if err != nil {
+					setupLog.Error(err, "failed to serve the debug endpoints")
+				}
+			}()
+		}
+
 		go agentCtrl.Run(1, stopCh)
 		varmorInformer.Start(stopCh)
 
@@ -163,6 +355,21 @@ func main() {
 	} else {
 		setupLog.Info("vArmor manager startup")
 
+		// Register the rule groups of the platform before the webhook server and the controllers start.
+		if ruleGroupsFile != "" {
+			plugin, err := profilebuilder.LoadRuleGroupPlugin(ruleGroupsFile)
+			if err != nil {
+				setupLog.Error(err, "profilebuilder.LoadRuleGroupPlugin()")
+				os.Exit(1)
+			}
+			err = profilebuilder.Register(plugin)
+			if err != nil {
+				setupLog.Error(err, "profilebuilder.Register()")
+				os.Exit(1)
+			}
+			setupLog.Info("the rule groups are registered", "rules", plugin.Rules())
+		}
+
 		// leader election context
 		leaderCtx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -202,6 +409,7 @@ func main() {
 		webhookRegister := webhookconfig.NewRegister(
 			clientConfig,
 			kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations(),
+			kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations(),
 			kubeClient.CoreV1().Secrets(config.Namespace),
 			kubeClient.AppsV1().Deployments(config.Namespace),
 			kubeClient.CoordinationV1().Leases(config.Namespace),
@@ -219,7 +427,7 @@ func main() {
 		registerWebhookConfigurations := func() {
 			// Only leader init the secrets of CA cert and TLS pair.
 			certManager.InitTLSPemPair()
-			// Only leader register MutatingWebhookConfiguration.
+			// Only leader register MutatingWebhookConfiguration and ValidatingWebhookConfiguration.
 			err = webhookRegister.Register()
 			if err != nil {
 				setupLog.Error(err, "webhookRegister.Register()")
@@ -239,13 +447,26 @@ func main() {
 			setupLog.Error(err, "Failed to get TLS key/certificate pair")
 			os.Exit(1)
 		}
+		// The metadata client and the REST mapper are used to resolve the owners of pods for the custom owner kinds
+		metadataClient, err := metadata.NewForConfig(clientConfig)
+		if err != nil {
+			setupLog.Error(err, "metadata.NewForConfig()")
+			os.Exit(1)
+		}
+		mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(kubeClient.Discovery()))
+
 		webhookServer, err := webhooks.NewWebhookServer(
 			webhookRegister,
 			cacher,
+			metadataClient,
+			mapper,
+			kubeClient.AuthorizationV1(),
 			tlsPair,
 			managerIP,
 			config.WebhookServicePort,
 			bpfExclusiveMode,
+			bpfProfileLayering,
+			exceptionAllowList,
 			log.Log.WithName("WEBHOOK-SERVER"))
 		if err != nil {
 			setupLog.Error(err, "Failed to create webhook webhookServer")
@@ -253,16 +474,36 @@ func main() {
 		}
 		go webhookServer.Run()
 
+		// The CA issues the client certificates of agents for the mutual TLS.
+		var agentCA *varmortls.KeyPair
+		var rootCA []byte
+		if enableAgentMTLS {
+			agentCA, err = certRenewer.InitAgentCA()
+			if err != nil {
+				setupLog.Error(err, "certRenewer.InitAgentCA()")
+				os.Exit(1)
+			}
+			rootCA, err = certRenewer.ReadRootCA()
+			if err != nil {
+				setupLog.Error(err, "certRenewer.ReadRootCA()")
+				os.Exit(1)
+			}
+		}
+
 		// The service is used for state synchronization. It only works with leader.
 		statusSvc, err := status.NewStatusService(
 			managerIP,
 			config.StatusServicePort,
 			tlsPair,
+			gatekeeperCA,
+			agentCA,
+			rootCA,
 			debug,
 			kubeClient.CoreV1(),
 			kubeClient.AppsV1(),
 			varmorClient.CrdV1beta1(),
 			kubeClient.AuthenticationV1(),
+			kubeClient.AuthorizationV1(),
 			statusUpdateCycle,
 			log.Log.WithName("STATUS-SERVICE"),
 		)
@@ -274,6 +515,7 @@ func main() {
 		clusterPolicyCtrl, err := policy.NewClusterPolicyController(
 			kubeClient.CoreV1().Pods(config.Namespace),
 			kubeClient.AppsV1(),
+			kubeClient.BatchV1(),
 			varmorClient.CrdV1beta1(),
 			varmorInformer.Crd().V1beta1().VarmorClusterPolicies(),
 			statusSvc.StatusManager,
@@ -291,6 +533,7 @@ func main() {
 		policyCtrl, err := policy.NewPolicyController(
 			kubeClient.CoreV1().Pods(config.Namespace),
 			kubeClient.AppsV1(),
+			kubeClient.BatchV1(),
 			varmorClient.CrdV1beta1(),
 			varmorInformer.Crd().V1beta1().VarmorPolicies(),
 			statusSvc.StatusManager,
@@ -305,6 +548,17 @@ func main() {
 			os.Exit(1)
 		}
 
+		profileSuggester := policy.NewProfileSuggester(varmorClient.CrdV1beta1(), log.Log.WithName("PROFILE-SUGGESTER"))
+
+		networkPeerResolver := policy.NewNetworkPeerResolver(
+			varmorClient.CrdV1beta1(),
+			kubeInformer.Core().V1().Services(),
+			kubeInformer.Discovery().V1().EndpointSlices(),
+			kubeInformer.Core().V1().Pods(),
+			varmorInformer.Crd().V1beta1().ArmorProfiles(),
+			log.Log.WithName("NETWORK-PEER"),
+		)
+
 		retriable := func(err error) bool {
 			return err != nil
 		}
@@ -318,6 +572,10 @@ func main() {
 			// Only the leader run as the VarmorClusterPolicy & VarmorPolicy controller.
 			go clusterPolicyCtrl.Run(1, stopCh)
 			go policyCtrl.Run(1, stopCh)
+			// Only the leader suggests tightening the BPF profiles.
+			go profileSuggester.Run(stopCh)
+			// Only the leader resolves the Services and Pods referenced by the network rules.
+			go networkPeerResolver.Run(stopCh)
 			// Tag the leader Pod with "identity: leader" label so that agents can use varmor-status-svc for state synchronization.
 			if !debug {
 				tag := func() error {
@@ -364,3 +622,14 @@ func main() {
 		setupLog.Info("vArmor manager shutdown successful")
 	}
 }
+
+// splitList splits the comma-separated list of the argument, the empty items are skipped
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
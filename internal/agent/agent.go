@@ -18,15 +18,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -38,6 +42,11 @@ import (
 	varmorbehavior "github.com/bytedance/vArmor/internal/behavior"
 	varmortracer "github.com/bytedance/vArmor/internal/behavior/tracer"
 	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmorintegrity "github.com/bytedance/vArmor/internal/integrity"
+	apparmorprofile "github.com/bytedance/vArmor/internal/profile/apparmor"
+	seccompprofile "github.com/bytedance/vArmor/internal/profile/seccomp"
+	selinuxprofile "github.com/bytedance/vArmor/internal/profile/selinux"
+	varmortracing "github.com/bytedance/vArmor/internal/tracing"
 	varmortypes "github.com/bytedance/vArmor/internal/types"
 	varmorutils "github.com/bytedance/vArmor/internal/utils"
 	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned/typed/varmor/v1beta1"
@@ -45,26 +54,43 @@ import (
 	varmorlister "github.com/bytedance/vArmor/pkg/client/listers/varmor/v1beta1"
 	varmorapparmor "github.com/bytedance/vArmor/pkg/lsm/apparmor"
 	varmorbpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	varmorlandlock "github.com/bytedance/vArmor/pkg/lsm/landlock"
+	varmorselinux "github.com/bytedance/vArmor/pkg/lsm/selinux"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
 	varmorruntime "github.com/bytedance/vArmor/pkg/runtime"
 	varmorseccomp "github.com/bytedance/vArmor/pkg/seccomp"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
 )
 
 const (
 	// maxRetries used for setting the retry times of sync failed
 	maxRetries = 10
+	// bpfEnforcerShutdownTimeout is the maximum time to wait for the BPF enforcer to drain the pending events
+	bpfEnforcerShutdownTimeout = 10 * time.Second
+	// bpfPreviousGenerationReleaseTimeout is the maximum time to wait for the BPF enforcer to detach the BPF programs
+	// taken over from the previous agent
+	bpfPreviousGenerationReleaseTimeout = time.Minute
 )
 
 type Agent struct {
 	varmorInterface          varmorinterface.CrdV1beta1Interface
+	podsGetter               corev1.PodsGetter
 	apInformer               varmorinformer.ArmorProfileInformer
 	apLister                 varmorlister.ArmorProfileLister
 	apInformerSynced         cache.InformerSynced
 	queue                    workqueue.RateLimitingInterface
 	appArmorSupported        bool
 	bpfLsmSupported          bool
+	landlockSupported        bool
+	selinuxSupported         bool
+	unsupportedReason        string
 	appArmorProfileDir       string
 	seccompProfileDir        string
+	landlockProfileDir       string
+	selinuxProfileDir        string
 	bpfEnforcer              *varmorbpfenforcer.BpfEnforcer
+	notifyServer             *varmorseccomp.NotifyServer
+	nriPlugin                *varmorruntime.NriPlugin
 	monitor                  *varmorruntime.RuntimeMonitor
 	waitExistingApSync       sync.WaitGroup
 	existingApCount          int
@@ -73,9 +99,22 @@ type Agent struct {
 	enableBpfEnforcer        bool
 	unloadAllAaProfiles      bool
 	removeAllSeccompProfiles bool
+	keepBpfEnforcement       bool
+	annotateEnforcements     bool
+	maxEnforcementSuspension time.Duration
+	bpfMapWarmUp             bool
+	annotatedSuspensions     map[string]annotatedSuspension // <containerID: annotatedSuspension>
+	spiffeTrustDomain        string
+	bpfDefaultProfile        string
+	profileVersions          map[string]profileVersion // <profileName: profileVersion>
+	profileVersionsLock      sync.RWMutex
 	tracer                   *varmortracer.Tracer
 	modellers                map[string]*varmorbehavior.BehaviorModeller
+	detectors                map[string]*varmorbehavior.DriftDetector
+	feedbacks                map[string]*varmorbehavior.ComplainFeedback
+	integrityMonitors        map[string]*varmorintegrity.IntegrityMonitor
 	nodeName                 string
+	nodeLabels               map[string]string
 	debug                    bool
 	managerIP                string
 	managerPort              int
@@ -86,12 +125,39 @@ type Agent struct {
 
 func NewAgent(
 	podInterface corev1.PodInterface,
+	nodeInterface corev1.NodeInterface,
+	podsGetter corev1.PodsGetter,
 	varmorInterface varmorinterface.CrdV1beta1Interface,
 	apInformer varmorinformer.ArmorProfileInformer,
 	enableBehaviorModeling bool,
 	enableBpfEnforcer bool,
+	enableLandlockEnforcer bool,
+	enableSELinuxEnforcer bool,
+	enableSeccompNotify bool,
+	enableNriPlugin bool,
+	taskChCapacity int,
+	bpfWorkers int,
+	bpfJournalPath string,
+	bpfMapOpLogRate int,
+	bpfMapOpLogSampling int,
+	bpfMapMemoryLimit uint64,
+	bpfPressureStallThreshold float64,
+	bpfMapWarmUp bool,
+	bpfApplyLatencySLO time.Duration,
+	bpfViolationAggregationWindow time.Duration,
+	bpfDefaultProfile string,
+	bpfDefaultProfileExcludedNamespaces []string,
+	clusterPodCIDRs []string,
+	clusterServiceCIDRs []string,
+	runtimeEndpoints []varmorruntime.Endpoint,
+	runtimeEventFilter varmorruntime.EventFilter,
+	spiffeTrustDomain string,
 	unloadAllAaProfiles bool,
 	removeAllSeccompProfiles bool,
+	keepBpfEnforcement bool,
+	annotateEnforcements bool,
+	maxEnforcementSuspension time.Duration,
+	enableMTLS bool,
 	debug bool,
 	managerIP string,
 	managerPort int,
@@ -103,19 +169,33 @@ func NewAgent(
 
 	agent := Agent{
 		varmorInterface:          varmorInterface,
+		podsGetter:               podsGetter,
 		apInformer:               apInformer,
 		apLister:                 apInformer.Lister(),
 		apInformerSynced:         apInformer.Informer().HasSynced,
 		queue:                    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "agent"),
 		appArmorProfileDir:       varmorconfig.AppArmorProfileDir,
 		seccompProfileDir:        varmorconfig.SeccompProfileDir,
+		landlockProfileDir:       varmorconfig.LandlockProfileDir,
+		selinuxProfileDir:        varmorconfig.SELinuxProfileDir,
 		existingApCount:          0,
 		processedApCount:         0,
 		enableBehaviorModeling:   enableBehaviorModeling,
 		enableBpfEnforcer:        enableBpfEnforcer,
 		unloadAllAaProfiles:      unloadAllAaProfiles,
 		removeAllSeccompProfiles: removeAllSeccompProfiles,
+		keepBpfEnforcement:       keepBpfEnforcement,
+		annotateEnforcements:     annotateEnforcements,
+		maxEnforcementSuspension: maxEnforcementSuspension,
+		bpfMapWarmUp:             bpfMapWarmUp,
+		annotatedSuspensions:     make(map[string]annotatedSuspension),
+		spiffeTrustDomain:        spiffeTrustDomain,
+		bpfDefaultProfile:        bpfDefaultProfile,
+		profileVersions:          make(map[string]profileVersion),
 		modellers:                make(map[string]*varmorbehavior.BehaviorModeller),
+		detectors:                make(map[string]*varmorbehavior.DriftDetector),
+		feedbacks:                make(map[string]*varmorbehavior.ComplainFeedback),
+		integrityMonitors:        make(map[string]*varmorintegrity.IntegrityMonitor),
 		debug:                    debug,
 		managerIP:                managerIP,
 		managerPort:              managerPort,
@@ -142,10 +222,20 @@ func NewAgent(
 		agent.bpfLsmSupported = false
 		log.Info("the BPF enforcer is not enabled (use --enableBpfEnforcer to enable it)")
 	}
-
-	if !agent.appArmorSupported && !agent.bpfLsmSupported {
-		log.Error(fmt.Errorf("neither the BPF LSM nor the AppArmor LSM is supported"), "unsupported system")
-		return nil, err
+	if enableLandlockEnforcer {
+		abi, err := varmorlandlock.ABIVersion()
+		if err != nil {
+			log.Info("the Landlock LSM is not supported", "error", err)
+		} else {
+			agent.landlockSupported = true
+			log.Info("the Landlock LSM is supported", "ABI version", abi)
+		}
+	}
+	if enableSELinuxEnforcer {
+		agent.selinuxSupported = varmorselinux.IsSELinuxEnabled()
+		if !agent.selinuxSupported {
+			log.Info("the SELinux LSM is not enabled")
+		}
 	}
 
 	// Retrieve the node name where the agent is located.
@@ -155,13 +245,39 @@ func NewAgent(
 	}
 	log.Info("NewAgent", "nodeName", agent.nodeName)
 
+	// Retrieve the labels of the node, and add the labels of the features probed on the node.
+	// They are used to match the node selector of the policies.
+	agent.nodeLabels, err = retrieveNodeLabels(nodeInterface, agent.nodeName, debug)
+	if err != nil {
+		return nil, err
+	}
+	kernelRelease, _ := exec.Command("uname", "-r").CombinedOutput()
+	for k, v := range probeFeatureLabels(agent.appArmorSupported, agent.bpfLsmSupported, agent.landlockSupported, agent.selinuxSupported, string(kernelRelease)) {
+		agent.nodeLabels[k] = v
+	}
+
+	// Request the client certificate for the mutual TLS with manager, and rotate it.
+	if enableMTLS {
+		varmorutils.InitAndStartCertRotation(agent.nodeName, debug, managerIP, managerPort, log)
+	}
+
+	// The agent keeps running in the unsupported state on the node that can't enforce any profile instead of
+	// crash-looping. It reports the state of the profiles and the inventory of the node to the manager, so the
+	// node is excluded from the desired nodes of the policies.
+	if !agent.appArmorSupported && !agent.bpfLsmSupported && !agent.landlockSupported && !agent.selinuxSupported {
+		agent.unsupportedReason = "none of the BPF LSM, the AppArmor LSM, the Landlock LSM and the SELinux LSM is supported by the node"
+		log.Error(fmt.Errorf("%s", agent.unsupportedReason), "unsupported system, the agent runs in the unsupported state")
+		return &agent, nil
+	}
+
 	// Initialize the runtime monitor for BehaviorModeling mode or BPF enforcer.
 	if agent.enableBehaviorModeling || agent.bpfLsmSupported {
 		log.Info("initialize the RuntimeMonitor")
-		agent.monitor, err = varmorruntime.NewRuntimeMonitor(log.WithName("RUNTIME-MONITOR"))
+		agent.monitor, err = varmorruntime.NewRuntimeMonitorWithEndpoints(runtimeEndpoints, log.WithName("RUNTIME-MONITOR"))
 		if err != nil {
 			return nil, err
 		}
+		agent.monitor.SetEventFilter(runtimeEventFilter)
 	}
 
 	// [Experimental feature] Initialize the tracer for BehaviorModeling mode.
@@ -201,16 +317,76 @@ func NewAgent(
 	// BPF LSM initialization
 	if agent.bpfLsmSupported {
 		log.Info("initialize the BPF LSM")
-		agent.bpfEnforcer, err = varmorbpfenforcer.NewBpfEnforcer(log.WithName("BPF-ENFORCER"))
+		// The addresses of the node are matched by the @node-local macro of the network rules
+		nodeAddresses, err := retrieveNodeAddresses(nodeInterface, agent.nodeName, debug)
+		if err != nil {
+			return nil, err
+		}
+		agent.bpfEnforcer, err = varmorbpfenforcer.New(varmorbpfenforcer.Options{
+			TaskChannelCapacity:        taskChCapacity,
+			Workers:                    bpfWorkers,
+			JournalPath:                bpfJournalPath,
+			MapOpLogRate:               bpfMapOpLogRate,
+			MapOpLogSampling:           bpfMapOpLogSampling,
+			MapMemoryLimit:             bpfMapMemoryLimit,
+			PressureStallThreshold:     bpfPressureStallThreshold,
+			ApplyLatencySLO:            bpfApplyLatencySLO,
+			ViolationAggregationWindow: bpfViolationAggregationWindow,
+			KeepEnforcementOnShutdown:  keepBpfEnforcement,
+			ClusterPodCIDRs:            clusterPodCIDRs,
+			ClusterServiceCIDRs:        clusterServiceCIDRs,
+			NodeAddresses:              nodeAddresses,
+			Log:                        log.WithName("BPF-ENFORCER"),
+
+			// The components of vArmor are never enforced with the default profile
+			DefaultProfile:                   bpfDefaultProfile,
+			DefaultProfileExcludedNamespaces: append(bpfDefaultProfileExcludedNamespaces, varmorconfig.Namespace),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Validate that the enforcement is live on the node.
+		err = agent.bpfEnforcer.SelfTest()
+		if err != nil {
+			log.Error(err, "the self-test of the BPF enforcer failed, the BPF profiles may not be enforced on the node")
+		} else {
+			log.Info("the self-test of the BPF enforcer passed")
+		}
+
+		// Save the minimal profile of the sandbox containers, which are confined by the policies optionally. The
+		// agent keeps running without it if it fails, since only the sandbox containers are affected.
+		sandboxContent, err := profilebuilder.GenerateSandboxProfile()
 		if err != nil {
 			return nil, err
 		}
+		_, err = agent.bpfEnforcer.SaveAndApplyBpfProfile(context.Background(), pkgtypes.SandboxProfileName, *sandboxContent)
+		if err != nil {
+			log.Error(err, "failed to save the profile of the sandbox containers, they can't be confined on the node")
+		}
 
 		agent.monitor.SetTaskNotifyChs(
 			agent.bpfEnforcer.TaskCreateCh,
 			agent.bpfEnforcer.TaskDeleteCh,
 			agent.bpfEnforcer.TaskDeleteSyncCh)
 
+		// Enforce the containers synchronously before their entrypoints run. The runtime monitor still sends the
+		// task events, and the containers which have been enforced by the plugin are skipped.
+		if enableNriPlugin {
+			log.Info("initialize the NRI plugin", "socket", varmorconfig.NriSocketPath)
+			agent.nriPlugin = varmorruntime.NewNriPlugin(varmorconfig.NriSocketPath, agent.bpfEnforcer.EnforceContainer, log.WithName("NRI-PLUGIN"))
+			agent.nriPlugin.SetEventFilter(runtimeEventFilter)
+		}
+
+		// The containers without any profile are enforced with the default profile in the default-deny mode
+		if bpfDefaultProfile != "" {
+			log.Info("the node runs in the default-deny mode", "default profile", bpfDefaultProfile)
+			agent.monitor.ForwardAllContainers()
+			if agent.nriPlugin != nil {
+				agent.nriPlugin.ForwardAllContainers()
+			}
+		}
+
 		// Retrieve the count of existing ArmorProfile objects.
 		apList, err := agent.varmorInterface.ArmorProfiles(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
 		if err != nil {
@@ -224,6 +400,44 @@ func NewAgent(
 		}
 	}
 
+	// Landlock LSM initialization
+	if agent.landlockSupported {
+		log.Info("initialize the Landlock LSM")
+
+		// The launcher is mounted into the target containers along with the profiles, it applies the profiles
+		// before executing the commands of the containers.
+		if !agent.debug {
+			log.Info(fmt.Sprintf("setup the Landlock launcher to %s", agent.landlockProfileDir))
+			err = os.MkdirAll(agent.landlockProfileDir, 0755)
+			if err != nil {
+				return nil, err
+			}
+			ret, err := exec.Command("cp", varmorconfig.PackagedLandlockLauncher, agent.landlockProfileDir).CombinedOutput()
+			if err != nil {
+				log.Info(string(ret))
+				return nil, err
+			}
+		}
+	}
+
+	// SELinux LSM initialization
+	if agent.selinuxSupported && !agent.debug {
+		log.Info("initialize the SELinux LSM")
+		err = os.MkdirAll(agent.selinuxProfileDir, 0700)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Seccomp user notification initialization
+	if enableSeccompNotify {
+		log.Info("initialize the seccomp notify server", "socket", varmorconfig.SeccompNotifySocketPath)
+		agent.notifyServer, err = varmorseccomp.NewNotifyServer(varmorconfig.SeccompNotifySocketPath, log.WithName("SECCOMP-NOTIFIER"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &agent, nil
 }
 
@@ -283,12 +497,18 @@ func (agent *Agent) updateArmorProfile(oldObj interface{}, newObj interface{}) {
 }
 
 func (agent *Agent) sendStatus(ap *varmor.ArmorProfile, status varmortypes.Status, message string) error {
+	return agent.sendStatusWithWarning(ap, status, message, "")
+}
+
+// sendStatusWithWarning sends the status along with a warning, e.g. the profile was truncated to fit the limits of enforcer.
+func (agent *Agent) sendStatusWithWarning(ap *varmor.ArmorProfile, status varmortypes.Status, message string, warning string) error {
 	s := varmortypes.ProfileStatus{
 		Namespace:   ap.Namespace,
 		ProfileName: ap.Name,
 		NodeName:    agent.nodeName,
 		Status:      status,
 		Message:     message,
+		Warning:     warning,
 	}
 	reqBody, _ := json.Marshal(&s)
 	return varmorutils.PostStatusToStatusService(reqBody, agent.debug, agent.managerIP, agent.managerPort)
@@ -297,6 +517,10 @@ func (agent *Agent) sendStatus(ap *varmor.ArmorProfile, status varmortypes.Statu
 func (agent *Agent) selectEnforcer(ap *varmor.ArmorProfile, logger logr.Logger) (varmortypes.Enforcer, error) {
 	e := varmortypes.GetEnforcerType(ap.Spec.Profile.Enforcer)
 
+	if (e & varmortypes.BestAvailable) != 0 {
+		return agent.selectBestAvailableEnforcer(ap, logger), nil
+	}
+
 	if (e&varmortypes.AppArmor != 0) && !agent.appArmorSupported {
 		agent.sendStatus(ap, varmortypes.Failed, "the AppArmor LSM feature is not supported by the host, or the AppArmor enforcer has been disabled in vArmor.")
 		return e, fmt.Errorf("the AppArmor LSM feature is not supported by the host, or the AppArmor enforcer has been disabled in vArmor")
@@ -307,6 +531,16 @@ func (agent *Agent) selectEnforcer(ap *varmor.ArmorProfile, logger logr.Logger)
 		return e, fmt.Errorf("the BPF LSM feature is not supported by the host, or the BPF enforcer has not been enabled in vArmor")
 	}
 
+	if (e&varmortypes.Landlock != 0) && !agent.landlockSupported {
+		agent.sendStatus(ap, varmortypes.Failed, "the Landlock LSM feature is not supported by the host, or the Landlock enforcer has not been enabled in vArmor.")
+		return e, fmt.Errorf("the Landlock LSM feature is not supported by the host, or the Landlock enforcer has not been enabled in vArmor")
+	}
+
+	if (e&varmortypes.SELinux != 0) && !agent.selinuxSupported {
+		agent.sendStatus(ap, varmortypes.Failed, "the SELinux LSM is not enabled on the host, or the SELinux enforcer has not been enabled in vArmor.")
+		return e, fmt.Errorf("the SELinux LSM is not enabled on the host, or the SELinux enforcer has not been enabled in vArmor")
+	}
+
 	if (e&varmortypes.BPF != 0) && ap.Spec.BehaviorModeling.Enable {
 		agent.sendStatus(ap, varmortypes.Failed, "the BPF enforcer does not support the BehaviorModeling mode.")
 		return e, fmt.Errorf("the BPF enforcer does not support the BehaviorModeling mode")
@@ -320,8 +554,19 @@ func (agent *Agent) selectEnforcer(ap *varmor.ArmorProfile, logger logr.Logger)
 	return e, nil
 }
 
+// selectBestAvailableEnforcer selects the BPF enforcer for the BestAvailable enforcer if it's available on the node,
+// otherwise the AppArmor and Seccomp enforcers.
+func (agent *Agent) selectBestAvailableEnforcer(ap *varmor.ArmorProfile, logger logr.Logger) varmortypes.Enforcer {
+	if agent.bpfLsmSupported {
+		logger.Info("the BPF enforcer is selected for the BestAvailable enforcer", "profile name", ap.Spec.Profile.Name)
+		return varmortypes.BPF | varmortypes.BestAvailable
+	}
+	logger.Info("the AppArmor and Seccomp enforcers are selected for the BestAvailable enforcer", "profile name", ap.Spec.Profile.Name)
+	return varmortypes.AppArmor | varmortypes.Seccomp | varmortypes.BestAvailable
+}
+
 // handleCreateOrUpdateArmorProfile load or reload AppArmor Profile for containers.
-func (agent *Agent) handleCreateOrUpdateArmorProfile(ap *varmor.ArmorProfile, key string) error {
+func (agent *Agent) handleCreateOrUpdateArmorProfile(ctx context.Context, ap *varmor.ArmorProfile, key string) error {
 	logger := agent.log.WithName("handleCreateOrUpdateArmorProfile()")
 
 	logger.Info("ArmorProfile created or updated", "namespace", ap.Namespace, "name", ap.Name,
@@ -337,6 +582,20 @@ func (agent *Agent) handleCreateOrUpdateArmorProfile(ap *varmor.ArmorProfile, ke
 		}
 	}()
 
+	// Unload the profile and skip it if the node doesn't match the node selector of the policy.
+	if !matchNodeSelector(ap.Spec.NodeSelector, agent.nodeLabels) {
+		logger.Info("the node doesn't match the node selector, skip the profile", "node selector", ap.Spec.NodeSelector)
+		err := agent.handleDeleteArmorProfile(ctx, ap.Namespace, ap.Name, key)
+		if err != nil {
+			return err
+		}
+		return agent.sendStatus(ap, varmortypes.Skipped, "the node doesn't match the node selector of the policy")
+	}
+
+	if agent.unsupportedReason != "" {
+		return agent.sendStatus(ap, varmortypes.Unsupported, agent.unsupportedReason)
+	}
+
 	enforcer, err := agent.selectEnforcer(ap, logger)
 	if err != nil {
 		return nil
@@ -391,13 +650,24 @@ func (agent *Agent) handleCreateOrUpdateArmorProfile(ap *varmor.ArmorProfile, ke
 		}
 	}
 
+	// The target containers of the BestAvailable enforcer reference the AppArmor and Seccomp profiles on every
+	// node, so the ones that allow everything are loaded along with the BPF profile.
+	loadEnforcer := enforcer
+	appArmorContent := ap.Spec.Profile.Content
+	seccompContent := ap.Spec.Profile.SeccompContent
+	if (enforcer&varmortypes.BestAvailable) != 0 && (enforcer&varmortypes.BPF) != 0 {
+		loadEnforcer |= varmortypes.AppArmor | varmortypes.Seccomp
+		appArmorContent = apparmorprofile.GenerateAlwaysAllowProfile(ap.Spec.Profile.Name)
+		seccompContent = seccompprofile.GenerateAlwaysAllowProfile()
+	}
+
 	// AppArmor
-	if (enforcer & varmortypes.AppArmor) != 0 {
+	if (loadEnforcer & varmortypes.AppArmor) != 0 {
 		// Save and load AppArmor profile.
 		if agent.appArmorSupported && needLoadApparmor {
 			logger.Info(fmt.Sprintf("saving the AppArmor profile ('%s') to Node/%s", ap.Spec.Profile.Name, agent.nodeName))
 			profilePath := filepath.Join(agent.appArmorProfileDir, ap.Spec.Profile.Name)
-			err := varmorapparmor.SaveAppArmorProfile(profilePath, ap.Spec.Profile.Content)
+			err := varmorapparmor.SaveAppArmorProfile(profilePath, appArmorContent)
 			if err != nil {
 				logger.Error(err, "saveAppArmorProfile()")
 				return agent.sendStatus(ap, varmortypes.Failed, "saveAppArmorProfile(): "+err.Error())
@@ -424,33 +694,253 @@ func (agent *Agent) handleCreateOrUpdateArmorProfile(ap *varmor.ArmorProfile, ke
 	}
 
 	// BPF
+	var bpfWarning string
+	var bpfFailed []string
 	if (enforcer & varmortypes.BPF) != 0 {
 		// Save BPF profile.
 		logger.Info(fmt.Sprintf("saving and applying the BPF profile ('%s')", ap.Spec.Profile.Name))
-		err := agent.bpfEnforcer.SaveAndApplyBpfProfile(ap.Spec.Profile.Name, *ap.Spec.Profile.BpfContent)
+		newProfile := !agent.bpfEnforcer.IsBpfProfileExist(ap.Spec.Profile.Name)
+		warning, err := agent.bpfEnforcer.SaveAndApplyBpfProfile(ctx, ap.Spec.Profile.Name, *ap.Spec.Profile.BpfContent)
+		// The profile is saved even if it failed to apply to some containers, which are reported as not enforced
+		agent.recordProfileVersion(ap)
 		if err != nil {
 			logger.Error(err, "SaveAndApplyBpfProfile()")
 			return agent.sendStatus(ap, varmortypes.Failed, "SaveBpfProfile(): "+err.Error())
 		}
+		bpfWarning = warning
+		if err := agent.bpfEnforcer.SelfTestError(); err != nil {
+			bpfWarning = appendWarning(bpfWarning, "the self-test of the BPF enforcer failed on the node: "+err.Error())
+		}
+		if pressure := agent.bpfEnforcer.MapMemoryPressure(); pressure != "" {
+			logger.Info(pressure)
+			bpfWarning = appendWarning(bpfWarning, pressure)
+		}
+		if pending := agent.bpfEnforcer.Pending(ap.Spec.Profile.Name); len(pending) != 0 {
+			pressure := agent.bpfEnforcer.NodePressure()
+			if pressure == "" {
+				pressure = "the BPF profile is pending to apply to the containers"
+			}
+			bpfWarning = appendWarning(bpfWarning, fmt.Sprintf("%s (pending containers: %s)", pressure, strings.Join(pending, ", ")))
+		}
+
+		// Protect the host processes for the HostProcess target. The processes that it failed to apply to
+		// are reported with the dead letters, and the ones in the host mnt ns are rejected.
+		if ap.Spec.Target.Kind == "HostProcess" && ap.Spec.Target.HostProcess != nil {
+			err := agent.bpfEnforcer.SetHostProcessTarget(ap.Spec.Profile.Name, ap.Spec.Target.HostProcess)
+			if err != nil {
+				logger.Error(err, "SetHostProcessTarget()")
+				return agent.sendStatus(ap, varmortypes.Failed, "SetHostProcessTarget(): "+err.Error())
+			}
+		}
+
+		// Protect the containers that were started before the agent received the profile. The existing
+		// containers will be collected after all existing ArmorProfile objects are processed during startup.
+		if newProfile && agent.existingApCount <= agent.processedApCount {
+			go func(profileName string) {
+				// The default profile is enforced on all the containers without any profile
+				if profileName == agent.bpfDefaultProfile {
+					err := agent.monitor.CollectExistingTargetContainers()
+					if err != nil {
+						agent.log.Error(err, "CollectExistingTargetContainers() failed")
+					}
+					return
+				}
+				err := agent.monitor.CollectTargetContainersOfProfile(profileName)
+				if err != nil {
+					agent.log.Error(err, "CollectTargetContainersOfProfile() failed", "profile name", profileName)
+				}
+			}(ap.Spec.Profile.Name)
+		}
+
+		bpfFailed = agent.bpfEnforcer.DeadLetters(ap.Spec.Profile.Name)
 	}
 
 	// Seccomp
-	if (enforcer & varmortypes.Seccomp) != 0 {
+	if (loadEnforcer & varmortypes.Seccomp) != 0 {
 		// Save Seccomp profile.
 		logger.Info(fmt.Sprintf("saving the Seccomp profile ('%s') to Node/%s", ap.Spec.Profile.Name, agent.nodeName))
 		profilePath := filepath.Join(agent.seccompProfileDir, ap.Spec.Profile.Name)
-		err := varmorseccomp.SaveSeccompProfile(profilePath, ap.Spec.Profile.SeccompContent)
+		err := varmorseccomp.SaveSeccompProfile(profilePath, seccompContent)
 		if err != nil {
 			logger.Error(err, "SaveSeccompProfile()")
 			return agent.sendStatus(ap, varmortypes.Failed, "SaveSeccompProfile(): "+err.Error())
 		}
 	}
 
+	// Landlock
+	if (enforcer & varmortypes.Landlock) != 0 {
+		// Save Landlock profile, it's applied by the launcher when the target containers start.
+		logger.Info(fmt.Sprintf("saving the Landlock profile ('%s') to Node/%s", ap.Spec.Profile.Name, agent.nodeName))
+		profilePath := filepath.Join(agent.landlockProfileDir, ap.Spec.Profile.Name)
+		err := varmorlandlock.SaveLandlockProfile(profilePath, ap.Spec.Profile.LandlockContent)
+		if err != nil {
+			logger.Error(err, "SaveLandlockProfile()")
+			return agent.sendStatus(ap, varmortypes.Failed, "SaveLandlockProfile(): "+err.Error())
+		}
+	}
+
+	// SELinux
+	if (enforcer & varmortypes.SELinux) != 0 {
+		// Save and install the SELinux policy module, the target containers run in the type defined by it.
+		logger.Info(fmt.Sprintf("installing the SELinux profile ('%s') to Node/%s", ap.Spec.Profile.Name, agent.nodeName))
+		profilePath := filepath.Join(agent.selinuxProfileDir, selinuxprofile.BlockName(ap.Spec.Profile.Name)+".cil")
+		err := varmorselinux.SaveSELinuxProfile(profilePath, ap.Spec.Profile.SELinuxContent)
+		if err != nil {
+			logger.Error(err, "SaveSELinuxProfile()")
+			return agent.sendStatus(ap, varmortypes.Failed, "SaveSELinuxProfile(): "+err.Error())
+		}
+		output, err := varmorselinux.InstallSELinuxProfile(profilePath)
+		if err != nil {
+			logger.Error(err, "InstallSELinuxProfile()", "output", output)
+			return agent.sendStatus(ap, varmortypes.Failed, "InstallSELinuxProfile(): "+err.Error()+" "+output)
+		}
+	}
+
+	// Drift detection
+	agent.handleDriftDetection(ap, key, logger)
+
+	// Complain feedback
+	agent.handleComplainFeedback(ap, enforcer, key, logger)
+
+	// File integrity monitoring
+	agent.handleFileIntegrity(ap, key, logger)
+
+	// Report the containers that the BPF profile persistently failed to apply to.
+	if len(bpfFailed) != 0 {
+		logger.Info("send failed status to manager, the BPF profile failed to apply to some containers", "containers", bpfFailed)
+		return agent.sendStatus(ap, varmortypes.Failed, "failed to apply the BPF profile to the containers: "+strings.Join(bpfFailed, "; "))
+	}
+
 	logger.Info("send succeeded status to manager")
-	return agent.sendStatus(ap, varmortypes.Succeeded, string(varmortypes.ArmorProfileReady))
+	return agent.sendStatusWithWarning(ap, varmortypes.Succeeded, string(varmortypes.ArmorProfileReady), bpfWarning)
+}
+
+// handleDriftDetection start, update or stop the drift detector of the ArmorProfile.
+// It reuses the tracer of BehaviorModeling mode to observe the executions of containers.
+func (agent *Agent) handleDriftDetection(ap *varmor.ArmorProfile, key string, logger logr.Logger) {
+	detector, ok := agent.detectors[key]
+
+	if !ap.Spec.DriftDetection.Enable {
+		if ok {
+			logger.Info("stop the drift detector", "profile name", ap.Name)
+			detector.DetectorStopCh <- true
+			delete(agent.detectors, key)
+		}
+		return
+	}
+
+	if !agent.enableBehaviorModeling {
+		logger.Info("the drift detection is ignored because the BehaviorModeling feature is not enabled (use --enableBehaviorModeling to enable it)",
+			"profile name", ap.Name)
+		return
+	}
+
+	if ok {
+		detector.UpdateBaseline(ap.Spec.DriftDetection.Action, ap.Spec.DriftDetection.Executables)
+		return
+	}
+
+	detector = varmorbehavior.NewDriftDetector(
+		agent.tracer,
+		agent.monitor,
+		agent.nodeName,
+		ap.Namespace,
+		ap.Name,
+		ap.Spec.DriftDetection.Action,
+		ap.Spec.DriftDetection.Executables,
+		agent.stopCh,
+		agent.managerIP,
+		agent.managerPort,
+		agent.debug,
+		agent.log.WithName("DRIFT-DETECTOR"))
+	agent.detectors[key] = detector
+	detector.Run()
+}
+
+// handleComplainFeedback start or stop the complain feedback of the ArmorProfile. The complain records are only
+// collected for the AppArmor profile of the DefenseInDepth mode which is loaded in complain mode.
+// It reuses the tracer of BehaviorModeling mode to receive the audit records.
+func (agent *Agent) handleComplainFeedback(ap *varmor.ArmorProfile, enforcer varmortypes.Enforcer, key string, logger logr.Logger) {
+	feedback, ok := agent.feedbacks[key]
+
+	if (enforcer&varmortypes.AppArmor) == 0 || ap.Spec.Profile.Mode != "complain" || ap.Spec.BehaviorModeling.Enable {
+		if ok {
+			logger.Info("stop the complain feedback", "profile name", ap.Name)
+			feedback.FeedbackStopCh <- true
+			delete(agent.feedbacks, key)
+		}
+		return
+	}
+
+	if ok {
+		return
+	}
+
+	if !agent.enableBehaviorModeling || !agent.appArmorSupported {
+		logger.Info("the complain feedback is ignored because the BehaviorModeling feature is not enabled (use --enableBehaviorModeling to enable it)",
+			"profile name", ap.Name)
+		return
+	}
+
+	feedback = varmorbehavior.NewComplainFeedback(
+		agent.tracer,
+		agent.nodeName,
+		ap.Namespace,
+		ap.Name,
+		ap.Spec.Profile.Enforcer,
+		agent.stopCh,
+		agent.managerIP,
+		agent.managerPort,
+		agent.classifierPort,
+		agent.debug,
+		agent.log.WithName("COMPLAIN-FEEDBACK"))
+	if feedback == nil {
+		return
+	}
+	agent.feedbacks[key] = feedback
+	feedback.Run()
+}
+
+// handleFileIntegrity start, update or stop the file integrity monitor of the ArmorProfile.
+func (agent *Agent) handleFileIntegrity(ap *varmor.ArmorProfile, key string, logger logr.Logger) {
+	m, ok := agent.integrityMonitors[key]
+
+	if len(ap.Spec.FileIntegrity.Paths) == 0 {
+		if ok {
+			logger.Info("stop the file integrity monitor", "profile name", ap.Name)
+			m.MonitorStopCh <- true
+			delete(agent.integrityMonitors, key)
+		}
+		return
+	}
+
+	if agent.monitor == nil {
+		logger.Info("the file integrity monitoring is ignored because the RuntimeMonitor is not initialized (use --enableBehaviorModeling or --enableBpfEnforcer to enable it)",
+			"profile name", ap.Name)
+		return
+	}
+
+	if ok {
+		m.UpdatePaths(ap.Spec.FileIntegrity.Paths)
+		return
+	}
+
+	m = varmorintegrity.NewIntegrityMonitor(
+		agent.monitor,
+		ap.Namespace,
+		ap.Name,
+		ap.Spec.FileIntegrity.Paths,
+		agent.stopCh,
+		agent.log.WithName("INTEGRITY-MONITOR"))
+	err := m.Run()
+	if err != nil {
+		logger.Error(err, "failed to start the file integrity monitor", "profile name", ap.Name)
+		return
+	}
+	agent.integrityMonitors[key] = m
 }
 
-func (agent *Agent) handleDeleteArmorProfile(namespace, name, key string) error {
+func (agent *Agent) handleDeleteArmorProfile(ctx context.Context, namespace, name, key string) error {
 	logger := agent.log.WithName("handleDeleteArmorProfile()")
 
 	logger.Info("ArmorProfile deleted", "namespace", namespace, "name", name)
@@ -464,13 +954,29 @@ func (agent *Agent) handleDeleteArmorProfile(namespace, name, key string) error
 		delete(agent.modellers, key)
 	}
 
+	if detector, ok := agent.detectors[key]; ok {
+		detector.DetectorStopCh <- true
+		delete(agent.detectors, key)
+	}
+
+	if feedback, ok := agent.feedbacks[key]; ok {
+		feedback.FeedbackStopCh <- true
+		delete(agent.feedbacks, key)
+	}
+
+	if m, ok := agent.integrityMonitors[key]; ok {
+		m.MonitorStopCh <- true
+		delete(agent.integrityMonitors, key)
+	}
+
 	// BPF
 	if agent.bpfLsmSupported && agent.bpfEnforcer.IsBpfProfileExist(name) {
 		logger.Info(fmt.Sprintf("unloading the BPF profile ('%s')", name))
-		err := agent.bpfEnforcer.DeleteBpfProfile(name)
+		err := agent.bpfEnforcer.DeleteBpfProfile(ctx, name)
 		if err != nil {
 			logger.Error(err, "DeleteBpfProfile()")
 		}
+		agent.forgetProfileVersion(name)
 	}
 
 	// AppArmor
@@ -504,10 +1010,40 @@ func (agent *Agent) handleDeleteArmorProfile(namespace, name, key string) error
 		}
 	}
 
+	// Landlock
+	profilePath = filepath.Join(agent.landlockProfileDir, name)
+	if agent.landlockSupported && varmorlandlock.LandlockProfileExist(profilePath) {
+		logger.Info(fmt.Sprintf("removing the Landlock profile ('%s') from Node/%s", name, agent.nodeName))
+		err := varmorlandlock.RemoveLandlockProfile(profilePath)
+		if err != nil {
+			logger.Error(err, "RemoveLandlockProfile()")
+			return err
+		}
+	}
+
+	// SELinux
+	moduleName := selinuxprofile.BlockName(name)
+	if agent.selinuxSupported {
+		installed, err := varmorselinux.IsSELinuxProfileInstalled(moduleName)
+		if err != nil {
+			logger.Error(err, "IsSELinuxProfileInstalled()")
+			return err
+		}
+		if installed {
+			logger.Info(fmt.Sprintf("uninstalling the SELinux profile ('%s') from Node/%s", name, agent.nodeName))
+			output, err := varmorselinux.UninstallSELinuxProfile(moduleName)
+			if err != nil {
+				logger.Error(err, "UninstallSELinuxProfile()", "output", output)
+				return err
+			}
+		}
+		varmorselinux.RemoveSELinuxProfile(filepath.Join(agent.selinuxProfileDir, moduleName+".cil"))
+	}
+
 	return nil
 }
 
-func (agent *Agent) syncProfile(key string) error {
+func (agent *Agent) syncProfile(key string) (err error) {
 	logger := agent.log.WithName("syncProfile()")
 
 	startTime := time.Now()
@@ -527,7 +1063,10 @@ func (agent *Agent) syncProfile(key string) error {
 		if k8errors.IsNotFound(err) {
 			// ArmorProfile delete event
 			logger.V(3).Info("processing ArmorProfile delete event")
-			return agent.handleDeleteArmorProfile(namespace, name, key)
+			ctx, span := varmortracing.StartSpan(context.Background(), "Agent.handleDeleteArmorProfile",
+				attribute.String("key", key), attribute.String("node", agent.nodeName))
+			defer func() { varmortracing.EndSpan(span, err) }()
+			return agent.handleDeleteArmorProfile(ctx, namespace, name, key)
 		} else {
 			logger.Error(err, "agent.varmorInterface.ArmorProfiles().Get()")
 			return err
@@ -535,7 +1074,11 @@ func (agent *Agent) syncProfile(key string) error {
 	} else {
 		// ArmorProfile create or update event
 		logger.V(3).Info("processing ArmorProfile create or update event")
-		return agent.handleCreateOrUpdateArmorProfile(ap, key)
+		// Continue the trace of the manager which created or updated the ArmorProfile object
+		ctx, span := varmortracing.StartSpan(varmortracing.ExtractFromObject(context.Background(), ap), "Agent.handleCreateOrUpdateArmorProfile",
+			attribute.String("key", key), attribute.String("node", agent.nodeName))
+		defer func() { varmortracing.EndSpan(span, err) }()
+		return agent.handleCreateOrUpdateArmorProfile(ctx, ap, key)
 	}
 }
 
@@ -574,6 +1117,32 @@ func (agent *Agent) worker() {
 	}
 }
 
+// handleDeadLetters re-processes the ArmorProfile objects when the containers that their BPF profiles failed
+// to apply to are changed, so that the latest status can be reported to the manager.
+func (agent *Agent) handleDeadLetters(stopCh <-chan struct{}) {
+	logger := agent.log.WithName("handleDeadLetters()")
+
+	for {
+		select {
+		case profileName := <-agent.bpfEnforcer.DeadLetterCh:
+			aps, err := agent.apLister.List(labels.Everything())
+			if err != nil {
+				logger.Error(err, "agent.apLister.List()")
+				break
+			}
+			for _, ap := range aps {
+				if ap.Spec.Profile.Name == profileName {
+					logger.V(3).Info("enqueue ArmorPolicy", "profile name", profileName)
+					agent.enqueuePolicy(ap, logger)
+				}
+			}
+
+		case <-stopCh:
+			return
+		}
+	}
+}
+
 func (agent *Agent) Run(workers int, stopCh <-chan struct{}) {
 	logger := agent.log
 	logger.Info("starting")
@@ -595,12 +1164,41 @@ func (agent *Agent) Run(workers int, stopCh <-chan struct{}) {
 		go wait.Until(agent.worker, time.Second, stopCh)
 	}
 
+	if agent.unsupportedReason != "" {
+		go agent.handleInventory(stopCh)
+		<-stopCh
+		return
+	}
+
 	if agent.enableBehaviorModeling || agent.bpfLsmSupported {
 		go agent.monitor.Run(stopCh)
 	}
 
+	if agent.notifyServer != nil {
+		go agent.notifyServer.Run(stopCh)
+	}
+
+	go agent.handleInventory(stopCh)
+
 	if agent.bpfLsmSupported {
 		go agent.bpfEnforcer.Run(stopCh)
+		if agent.nriPlugin != nil {
+			go agent.nriPlugin.Run(stopCh)
+		}
+		go agent.handleDeadLetters(stopCh)
+		go agent.handleViolations(stopCh)
+		go agent.handleCoverages(stopCh)
+		go agent.handleTampers(stopCh)
+		go agent.handleSuspensions(stopCh)
+		if agent.annotateEnforcements {
+			go agent.handleEnforcementAnnotations(stopCh)
+		}
+		if agent.maxEnforcementSuspension > 0 {
+			go agent.handleSuspendAnnotations(stopCh)
+		}
+		if agent.bpfMapWarmUp {
+			go agent.handleMapWarmUps(stopCh)
+		}
 
 		// Wait for all existing ArmorProfile objects have been processed.
 		if agent.existingApCount > 0 {
@@ -610,6 +1208,14 @@ func (agent *Agent) Run(workers int, stopCh <-chan struct{}) {
 				logger.Error(err, "CollectExistingTargetContainers() failed")
 			}
 		}
+
+		// Detach the BPF programs kept by the previous agent after the existing containers are enforced again.
+		ctx, cancel := context.WithTimeout(context.Background(), bpfPreviousGenerationReleaseTimeout)
+		err := agent.bpfEnforcer.ReleasePreviousGeneration(ctx)
+		cancel()
+		if err != nil {
+			logger.Error(err, "ReleasePreviousGeneration() failed")
+		}
 	}
 
 	<-stopCh
@@ -619,6 +1225,10 @@ func (agent *Agent) CleanUp() {
 	agent.log.Info("cleaning up")
 	agent.queue.ShutDown()
 
+	if agent.unsupportedReason != "" {
+		return
+	}
+
 	if agent.appArmorSupported && agent.enableBehaviorModeling {
 		agent.tracer.Close()
 	}
@@ -638,6 +1248,12 @@ func (agent *Agent) CleanUp() {
 	}
 
 	if agent.bpfLsmSupported {
-		agent.bpfEnforcer.Close()
+		// Wait for the BPF enforcer to drain the pending events before releasing the BPF resources
+		ctx, cancel := context.WithTimeout(context.Background(), bpfEnforcerShutdownTimeout)
+		defer cancel()
+		err := agent.bpfEnforcer.Shutdown(ctx)
+		if err != nil {
+			agent.log.WithName("BPF-ENFORCER").Error(err, "failed to shut down the BPF enforcer gracefully")
+		}
 	}
 }
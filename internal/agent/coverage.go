@@ -0,0 +1,110 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+const (
+	// coverageReportInterval is the interval for reporting the changed coverages to the manager
+	coverageReportInterval = time.Minute
+	// coverageResyncInterval is the interval for reporting all coverages to the manager, so the manager can
+	// rebuild its cache after the leader changes
+	coverageResyncInterval = 10 * time.Minute
+)
+
+// reportCoverages sends the enforcement coverages of the BPF profiles on the node to the manager. Only the
+// changed ones are sent unless resync is true. It returns the coverages that were sent successfully.
+func (agent *Agent) reportCoverages(reported map[string]varmortypes.CoverageData, resync bool) map[string]varmortypes.CoverageData {
+	logger := agent.log.WithName("reportCoverages()")
+
+	aps, err := agent.apLister.List(labels.Everything())
+	if err != nil {
+		logger.Error(err, "agent.apLister.List()")
+		return reported
+	}
+
+	latest := make(map[string]varmortypes.CoverageData, len(aps))
+	for _, ap := range aps {
+		if (varmortypes.GetEnforcerType(ap.Spec.Profile.Enforcer) & varmortypes.BPF) == 0 {
+			continue
+		}
+		if !agent.bpfEnforcer.IsBpfProfileExist(ap.Spec.Profile.Name) {
+			continue
+		}
+
+		coverage := agent.bpfEnforcer.Coverage(ap.Spec.Profile.Name)
+		data := varmortypes.CoverageData{
+			Namespace:      ap.Namespace,
+			ProfileName:    ap.Name,
+			NodeName:       agent.nodeName,
+			DesiredPods:    coverage.DesiredPods,
+			ReadyPods:      coverage.ReadyPods,
+			FailedPods:     coverage.FailedPods,
+			FailureReasons: coverage.FailureReasons,
+			PartialPods:    coverage.PartialPods,
+			PartialReasons: coverage.PartialReasons,
+		}
+		key := ap.Namespace + "/" + ap.Name
+
+		old, ok := reported[key]
+		if !resync && ((ok && reflect.DeepEqual(old, data)) || (!ok && data.DesiredPods == 0)) {
+			latest[key] = old
+			continue
+		}
+
+		reqBody, _ := json.Marshal(&data)
+		err := varmorutils.PostCoverageToStatusService(reqBody, agent.debug, agent.managerIP, agent.managerPort)
+		if err != nil {
+			logger.Error(err, "PostCoverageToStatusService()", "profile name", ap.Spec.Profile.Name)
+			if ok {
+				latest[key] = old
+			}
+			continue
+		}
+		latest[key] = data
+	}
+	return latest
+}
+
+// handleCoverages reports the enforcement coverages of the BPF profiles on the node to the manager periodically.
+func (agent *Agent) handleCoverages(stopCh <-chan struct{}) {
+	reported := make(map[string]varmortypes.CoverageData)
+	ticker := time.NewTicker(coverageReportInterval)
+	defer ticker.Stop()
+	lastResync := time.Now()
+
+	for {
+		select {
+		case <-ticker.C:
+			resync := time.Since(lastResync) >= coverageResyncInterval
+			if resync {
+				lastResync = time.Now()
+			}
+			reported = agent.reportCoverages(reported, resync)
+
+		case <-stopCh:
+			return
+		}
+	}
+}
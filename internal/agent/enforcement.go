@@ -0,0 +1,168 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorbpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+)
+
+// enforcementAnnotationInterval is the interval for writing the enforcements back to the annotations of the pods
+const enforcementAnnotationInterval = time.Minute
+
+// profileVersion describes the ArmorProfile object that the BPF profile was loaded from
+type profileVersion struct {
+	armorProfile string
+	generation   int64
+	mode         string
+}
+
+// recordProfileVersion records the version of the ArmorProfile object whose BPF profile was saved to the enforcer
+func (agent *Agent) recordProfileVersion(ap *varmor.ArmorProfile) {
+	agent.profileVersionsLock.Lock()
+	defer agent.profileVersionsLock.Unlock()
+	agent.profileVersions[ap.Spec.Profile.Name] = profileVersion{
+		armorProfile: ap.Namespace + "/" + ap.Name,
+		generation:   ap.Generation,
+		mode:         ap.Spec.Profile.Mode,
+	}
+}
+
+func (agent *Agent) forgetProfileVersion(profileName string) {
+	agent.profileVersionsLock.Lock()
+	defer agent.profileVersionsLock.Unlock()
+	delete(agent.profileVersions, profileName)
+}
+
+// containerEnforcements joins the enforcements of the BPF enforcer with the versions of the profiles
+func containerEnforcements(enforcements []varmorbpfenforcer.Enforcement, versions map[string]profileVersion) []varmortypes.ContainerEnforcement {
+	result := make([]varmortypes.ContainerEnforcement, 0, len(enforcements))
+	for _, e := range enforcements {
+		version := versions[e.ProfileName]
+		result = append(result, varmortypes.ContainerEnforcement{
+			PodNamespace:  e.PodNamespace,
+			PodName:       e.PodName,
+			ContainerName: e.ContainerName,
+			ContainerID:   e.ContainerID,
+			EnforcementState: varmortypes.EnforcementState{
+				ArmorProfile: version.armorProfile,
+				Generation:   version.generation,
+				Mode:         version.mode,
+				Enforced:     !e.Failed,
+				Message:      e.Error,
+			},
+		})
+	}
+	return result
+}
+
+// enforcements returns the BPF profiles enforced for the containers on the node
+func (agent *Agent) enforcements() []varmortypes.ContainerEnforcement {
+	if !agent.bpfLsmSupported {
+		return []varmortypes.ContainerEnforcement{}
+	}
+
+	agent.profileVersionsLock.RLock()
+	defer agent.profileVersionsLock.RUnlock()
+	return containerEnforcements(agent.bpfEnforcer.Enforcements(), agent.profileVersions)
+}
+
+// ServeEnforcements is an HTTP handler that responds with the BPF profiles enforced for the containers on the
+// node in JSON, so the live state can be audited against the policies.
+func (agent *Agent) ServeEnforcements(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(agent.enforcements())
+	if err != nil {
+		agent.log.Error(err, "failed to encode the enforcements")
+	}
+}
+
+// podEnforcementAnnotations groups the enforcements by pod, and returns the values of the annotation of the pods.
+// The host processes are skipped.
+func podEnforcementAnnotations(enforcements []varmortypes.ContainerEnforcement) map[types.NamespacedName]string {
+	pods := make(map[types.NamespacedName]map[string]varmortypes.EnforcementState)
+	for _, e := range enforcements {
+		if e.PodName == "" {
+			continue
+		}
+		pod := types.NamespacedName{Namespace: e.PodNamespace, Name: e.PodName}
+		if pods[pod] == nil {
+			pods[pod] = make(map[string]varmortypes.EnforcementState)
+		}
+		pods[pod][e.ContainerName] = e.EnforcementState
+	}
+
+	annotations := make(map[types.NamespacedName]string, len(pods))
+	for pod, containers := range pods {
+		// The keys of the map are sorted by json.Marshal, so the value is stable
+		value, _ := json.Marshal(containers)
+		annotations[pod] = string(value)
+	}
+	return annotations
+}
+
+// annotatePods writes the enforcements back to the annotations of the pods whose enforcements changed. It returns
+// the annotations that were written successfully.
+func (agent *Agent) annotatePods(written map[types.NamespacedName]string) map[types.NamespacedName]string {
+	logger := agent.log.WithName("annotatePods()")
+
+	latest := podEnforcementAnnotations(agent.enforcements())
+	for pod, value := range latest {
+		if written[pod] == value {
+			continue
+		}
+
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{varmortypes.EnforcementAnnotation: value},
+			},
+		})
+		_, err := agent.podsGetter.Pods(pod.Namespace).Patch(context.Background(), pod.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			if !k8errors.IsNotFound(err) {
+				logger.Error(err, "failed to patch the pod", "namespace", pod.Namespace, "name", pod.Name)
+			}
+			delete(latest, pod)
+		}
+	}
+	return latest
+}
+
+// handleEnforcementAnnotations writes the enforcements back to the annotations of the pods periodically
+func (agent *Agent) handleEnforcementAnnotations(stopCh <-chan struct{}) {
+	written := make(map[types.NamespacedName]string)
+	ticker := time.NewTicker(enforcementAnnotationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			written = agent.annotatePods(written)
+
+		case <-stopCh:
+			return
+		}
+	}
+}
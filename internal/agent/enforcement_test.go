@@ -0,0 +1,47 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/types"
+
+	varmorbpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+)
+
+func Test_podEnforcementAnnotations(t *testing.T) {
+	versions := map[string]profileVersion{
+		"varmor-demo-p1": {armorProfile: "demo/varmor-demo-p1", generation: 3, mode: "enforce"},
+	}
+	enforcements := containerEnforcements([]varmorbpfenforcer.Enforcement{
+		{ContainerID: "c1", ContainerName: "app", PodNamespace: "demo", PodName: "web-0", ProfileName: "varmor-demo-p1"},
+		{ContainerID: "c2", ContainerName: "sidecar", PodNamespace: "demo", PodName: "web-0", ProfileName: "varmor-demo-p1", Failed: true, Error: "no space left on device"},
+		{ContainerID: "hostprocess://kubelet.service", ProfileName: "varmor-demo-p1"},
+	}, versions)
+
+	assert.Equal(t, len(enforcements), 3)
+	assert.Equal(t, enforcements[0].ArmorProfile, "demo/varmor-demo-p1")
+	assert.Equal(t, enforcements[0].Generation, int64(3))
+	assert.Assert(t, enforcements[0].Enforced)
+	assert.Assert(t, !enforcements[1].Enforced)
+
+	annotations := podEnforcementAnnotations(enforcements)
+	assert.DeepEqual(t, annotations, map[types.NamespacedName]string{
+		{Namespace: "demo", Name: "web-0"}: `{"app":{"armorProfile":"demo/varmor-demo-p1","generation":3,"mode":"enforce","enforced":true},` +
+			`"sidecar":{"armorProfile":"demo/varmor-demo-p1","generation":3,"mode":"enforce","enforced":false,"message":"no space left on device"}}`,
+	})
+}
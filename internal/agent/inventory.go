@@ -0,0 +1,117 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+const (
+	// inventoryReportInterval is the interval for reporting the inventory of the node to the manager, so the
+	// manager can rebuild its cache after the leader changes
+	inventoryReportInterval = 10 * time.Minute
+
+	// btfFeature means the kernel exposes the BTF of vmlinux
+	btfFeature = "btf"
+)
+
+// parseEnabledLSMs parses the content of /sys/kernel/security/lsm
+func parseEnabledLSMs(content string) []string {
+	var lsms []string
+	for _, lsm := range strings.Split(strings.TrimSpace(content), ",") {
+		if lsm != "" {
+			lsms = append(lsms, lsm)
+		}
+	}
+	return lsms
+}
+
+func isSeccompSupported() bool {
+	content, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(content), "Seccomp:")
+}
+
+// buildNodeInventory collects the kernel version, the enabled LSMs and the BPF features of the node
+func (agent *Agent) buildNodeInventory() varmortypes.NodeInventory {
+	inventory := varmortypes.NodeInventory{
+		NodeName: agent.nodeName,
+		AppArmor: agent.appArmorSupported,
+		BPF:      agent.bpfLsmSupported,
+		Seccomp:  isSeccompSupported(),
+		Landlock: agent.landlockSupported,
+		SELinux:  agent.selinuxSupported,
+		Labels:   agent.nodeLabels,
+	}
+
+	kernelRelease, err := exec.Command("uname", "-r").CombinedOutput()
+	if err == nil {
+		inventory.KernelVersion = strings.TrimSpace(string(kernelRelease))
+	}
+
+	content, err := os.ReadFile("/sys/kernel/security/lsm")
+	if err == nil {
+		inventory.LSMs = parseEnabledLSMs(string(content))
+	}
+
+	inventory.BpfFeatures = make(map[string]bool)
+	_, err = os.Stat("/sys/kernel/btf/vmlinux")
+	inventory.BpfFeatures[btfFeature] = err == nil
+	if agent.bpfEnforcer != nil {
+		for feature, supported := range agent.bpfEnforcer.Features() {
+			inventory.BpfFeatures[feature] = supported
+		}
+	}
+
+	return inventory
+}
+
+// reportInventory sends the inventory of the node to the manager
+func (agent *Agent) reportInventory() {
+	logger := agent.log.WithName("reportInventory()")
+
+	inventory := agent.buildNodeInventory()
+	reqBody, _ := json.Marshal(&inventory)
+	err := varmorutils.PostInventoryToStatusService(reqBody, agent.debug, agent.managerIP, agent.managerPort)
+	if err != nil {
+		logger.Error(err, "PostInventoryToStatusService()")
+	}
+}
+
+// handleInventory reports the inventory of the node to the manager periodically.
+func (agent *Agent) handleInventory(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(inventoryReportInterval)
+	defer ticker.Stop()
+
+	agent.reportInventory()
+	for {
+		select {
+		case <-ticker.C:
+			agent.reportInventory()
+
+		case <-stopCh:
+			return
+		}
+	}
+}
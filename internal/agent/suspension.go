@@ -0,0 +1,295 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// suspendAnnotationSyncInterval is the interval for syncing the suspension annotations of the pods to the
+	// BPF enforcer
+	suspendAnnotationSyncInterval = 10 * time.Second
+	// suspendTimeout is the maximum time to wait for the BPF enforcer to suspend or resume the enforcement
+	suspendTimeout = 10 * time.Second
+)
+
+// annotatedSuspension is the suspension requested by the annotation of the pod
+type annotatedSuspension struct {
+	container string // <namespace>/<pod>/<container>
+	value     string // empty if the annotation was removed or expired
+	since     time.Time
+	until     time.Time
+	// used is the suspension time consumed by the previous annotations of the container
+	used time.Duration
+}
+
+// consumed returns the total suspension time of the container that the annotations consumed at the time
+func (s annotatedSuspension) consumed(now time.Time) time.Duration {
+	if s.value == "" {
+		return s.used
+	}
+	end := now
+	if s.until.Before(end) {
+		end = s.until
+	}
+	if !end.After(s.since) {
+		return s.used
+	}
+	return s.used + end.Sub(s.since)
+}
+
+func containerKey(namespace, podName, containerName string) string {
+	return namespace + "/" + podName + "/" + containerName
+}
+
+// SuspendContainer suspends the enforcement of the container of the pod for the duration, e.g. for debugging an
+// incident. The duration is capped by the maximum suspension, and the enforcement is resumed automatically when it
+// passes. It returns an error if the suspension is disabled or the container isn't enforced.
+func (agent *Agent) SuspendContainer(ctx context.Context, namespace, podName, containerName string, duration time.Duration, reason string) error {
+	if !agent.bpfLsmSupported || agent.maxEnforcementSuspension <= 0 {
+		return fmt.Errorf("the suspension of the enforcement is disabled")
+	}
+
+	containerID, ok := agent.enforcedContainerID(namespace, podName, containerName)
+	if !ok {
+		return fmt.Errorf("the container %s isn't enforced", containerKey(namespace, podName, containerName))
+	}
+
+	if duration > agent.maxEnforcementSuspension {
+		duration = agent.maxEnforcementSuspension
+	}
+	return agent.bpfEnforcer.SuspendContainer(ctx, containerID, time.Now().Add(duration), reason)
+}
+
+// ResumeContainer resumes the enforcement of the container of the pod before its suspension expires
+func (agent *Agent) ResumeContainer(ctx context.Context, namespace, podName, containerName string, reason string) error {
+	if !agent.bpfLsmSupported {
+		return fmt.Errorf("the suspension of the enforcement is disabled")
+	}
+
+	for _, s := range agent.bpfEnforcer.Suspensions() {
+		if containerKey(s.PodNamespace, s.PodName, s.ContainerName) == containerKey(namespace, podName, containerName) {
+			return agent.bpfEnforcer.ResumeContainer(ctx, s.ContainerID, reason)
+		}
+	}
+	return fmt.Errorf("the enforcement of the container %s isn't suspended", containerKey(namespace, podName, containerName))
+}
+
+// enforcedContainerID returns the id of the container of the pod that the BPF profile is enforced for
+func (agent *Agent) enforcedContainerID(namespace, podName, containerName string) (string, bool) {
+	key := containerKey(namespace, podName, containerName)
+	for _, e := range agent.bpfEnforcer.Enforcements() {
+		if !e.Failed && containerKey(e.PodNamespace, e.PodName, e.ContainerName) == key {
+			return e.ContainerID, true
+		}
+	}
+	return "", false
+}
+
+// ServeSuspensions is an HTTP handler that responds with the containers whose enforcement is suspended in JSON
+func (agent *Agent) ServeSuspensions(w http.ResponseWriter, r *http.Request) {
+	suspensions := []pkgtypes.Suspension{}
+	if agent.bpfLsmSupported {
+		suspensions = agent.bpfEnforcer.Suspensions()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(suspensions)
+	if err != nil {
+		agent.log.Error(err, "failed to encode the suspensions")
+	}
+}
+
+// suspendAnnotations returns the values of the suspension annotations of the pods, keyed by the containers.
+// The ones that expired at the time are skipped.
+func suspendAnnotations(pods []*v1.Pod, now time.Time) (map[string]string, []error) {
+	values := make(map[string]string)
+	var errs []error
+	for _, pod := range pods {
+		for k, v := range pod.Annotations {
+			if !strings.HasPrefix(k, varmortypes.SuspendAnnotationPrefix) {
+				continue
+			}
+			until, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("the annotation %s of the pod %s/%s is invalid: %w", k, pod.Namespace, pod.Name, err))
+				continue
+			}
+			if !until.After(now) {
+				continue
+			}
+			values[containerKey(pod.Namespace, pod.Name, strings.TrimPrefix(k, varmortypes.SuspendAnnotationPrefix))] = v
+		}
+	}
+	return values, errs
+}
+
+// syncSuspendAnnotations suspends the enforcement of the containers until the time of their annotations. The total
+// suspension of a container is capped by the maximum suspension, so rewriting the annotation can't renew it. The
+// enforcement is resumed when the annotation is removed.
+func (agent *Agent) syncSuspendAnnotations(pods []*v1.Pod, now time.Time) {
+	logger := agent.log.WithName("syncSuspendAnnotations()")
+
+	values, errs := suspendAnnotations(pods, now)
+	for _, err := range errs {
+		logger.Error(err, "ignore the suspension annotation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), suspendTimeout)
+	defer cancel()
+
+	// The containers that are enforced or suspended
+	containerIDs := make(map[string]string)
+	suspended := make(map[string]bool)
+	for _, e := range agent.bpfEnforcer.Enforcements() {
+		if !e.Failed {
+			containerIDs[containerKey(e.PodNamespace, e.PodName, e.ContainerName)] = e.ContainerID
+		}
+	}
+	for _, s := range agent.bpfEnforcer.Suspensions() {
+		containerIDs[containerKey(s.PodNamespace, s.PodName, s.ContainerName)] = s.ContainerID
+		suspended[s.ContainerID] = true
+	}
+
+	for container, value := range values {
+		containerID, ok := containerIDs[container]
+		if !ok {
+			// The container isn't enforced yet
+			continue
+		}
+		prev := agent.annotatedSuspensions[containerID]
+		if prev.value == value {
+			// The container has been suspended, or the suspension has been denied
+			continue
+		}
+
+		s := annotatedSuspension{container: container, value: value, since: now, until: now, used: prev.consumed(now)}
+		remaining := agent.maxEnforcementSuspension - s.used
+		if remaining <= 0 {
+			logger.Info("the suspension is denied since the container has been suspended for the maximum duration", "container", container)
+			agent.annotatedSuspensions[containerID] = s
+			continue
+		}
+
+		parts := strings.SplitN(container, "/", 3)
+
+		until, _ := time.Parse(time.RFC3339, value)
+		if max := now.Add(remaining); until.After(max) {
+			until = max
+		}
+		err := agent.bpfEnforcer.SuspendContainer(ctx, containerID, until, "requested by the annotation "+varmortypes.SuspendAnnotationPrefix+parts[2])
+		if err != nil {
+			logger.Error(err, "SuspendContainer()", "container", container)
+			continue
+		}
+		s.until = until
+		agent.annotatedSuspensions[containerID] = s
+		suspended[containerID] = true
+	}
+
+	for containerID, s := range agent.annotatedSuspensions {
+		if containerIDs[s.container] != containerID {
+			// The container was deleted
+			delete(agent.annotatedSuspensions, containerID)
+			continue
+		}
+		if _, ok := values[s.container]; ok || s.value == "" {
+			continue
+		}
+		// Keep the consumed suspension time, so re-adding the annotation can't renew the suspension
+		agent.annotatedSuspensions[containerID] = annotatedSuspension{container: s.container, used: s.consumed(now)}
+		if !suspended[containerID] {
+			continue
+		}
+		err := agent.bpfEnforcer.ResumeContainer(ctx, containerID, "the annotation was removed")
+		if err != nil {
+			logger.Error(err, "ResumeContainer()", "container", s.container)
+		}
+	}
+}
+
+// handleSuspendAnnotations watches the pods on the node, and syncs their suspension annotations to the BPF
+// enforcer periodically
+func (agent *Agent) handleSuspendAnnotations(stopCh <-chan struct{}) {
+	informer := agent.newNodePodInformer()
+	go informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		return
+	}
+
+	ticker := time.NewTicker(suspendAnnotationSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			var pods []*v1.Pod
+			for _, obj := range informer.GetStore().List() {
+				if pod, ok := obj.(*v1.Pod); ok {
+					pods = append(pods, pod)
+				}
+			}
+			agent.syncSuspendAnnotations(pods, time.Now())
+
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// handleSuspensions reports the suspensions of the enforcement to the manager, so that it can record them as
+// the events of the pods.
+func (agent *Agent) handleSuspensions(stopCh <-chan struct{}) {
+	logger := agent.log.WithName("handleSuspensions()")
+
+	for {
+		select {
+		case suspension := <-agent.bpfEnforcer.SuspensionCh:
+			report := varmortypes.SuspensionReport{
+				NodeName:      agent.nodeName,
+				ProfileName:   suspension.ProfileName,
+				PodNamespace:  suspension.PodNamespace,
+				PodName:       suspension.PodName,
+				ContainerID:   suspension.ContainerID,
+				ContainerName: suspension.ContainerName,
+				Reason:        suspension.Reason,
+				Until:         suspension.Until,
+				Resumed:       suspension.Resumed,
+				Timestamp:     suspension.Timestamp,
+			}
+			reqBody, _ := json.Marshal(&report)
+			err := varmorutils.PostSuspensionToStatusService(reqBody, agent.debug, agent.managerIP, agent.managerPort)
+			if err != nil {
+				logger.Error(err, "PostSuspensionToStatusService()")
+			}
+
+		case <-stopCh:
+			return
+		}
+	}
+}
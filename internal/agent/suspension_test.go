@@ -0,0 +1,86 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_suspendAnnotations(t *testing.T) {
+	now := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	pods := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "demo",
+				Name:      "web-0",
+				Annotations: map[string]string{
+					"suspend.varmor.org/nginx":   "2024-06-01T08:30:00Z",
+					"suspend.varmor.org/sidecar": "2024-06-01T07:30:00Z",
+					"suspend.varmor.org/debug":   "30m",
+					"enforcement.varmor.org/app": "{}",
+				},
+			},
+		},
+	}
+
+	values, errs := suspendAnnotations(pods, now)
+	assert.DeepEqual(t, values, map[string]string{"demo/web-0/nginx": "2024-06-01T08:30:00Z"})
+	assert.Equal(t, len(errs), 1)
+}
+
+func Test_annotatedSuspensionConsumed(t *testing.T) {
+	since := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		suspension annotatedSuspension
+		now        time.Time
+		expected   time.Duration
+	}{
+		{
+			name:       "active",
+			suspension: annotatedSuspension{value: "2024-06-01T09:00:00Z", since: since, until: since.Add(time.Hour), used: 10 * time.Minute},
+			now:        since.Add(20 * time.Minute),
+			expected:   30 * time.Minute,
+		},
+		{
+			name:       "expired",
+			suspension: annotatedSuspension{value: "2024-06-01T09:00:00Z", since: since, until: since.Add(time.Hour)},
+			now:        since.Add(2 * time.Hour),
+			expected:   time.Hour,
+		},
+		{
+			name:       "denied",
+			suspension: annotatedSuspension{value: "2024-06-01T09:00:00Z", since: since, until: since, used: time.Hour},
+			now:        since.Add(time.Hour),
+			expected:   time.Hour,
+		},
+		{
+			name:       "removed",
+			suspension: annotatedSuspension{used: 40 * time.Minute},
+			now:        since,
+			expected:   40 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.suspension.consumed(tt.now), tt.expected)
+		})
+	}
+}
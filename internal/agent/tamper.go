@@ -0,0 +1,53 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"encoding/json"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+// handleTampers reports the tampering with the maps of the BPF enforcer to the manager, so that it can raise
+// the critical events.
+func (agent *Agent) handleTampers(stopCh <-chan struct{}) {
+	logger := agent.log.WithName("handleTampers()")
+
+	for {
+		select {
+		case tamper := <-agent.bpfEnforcer.TamperCh:
+			report := varmortypes.TamperReport{
+				NodeName:     agent.nodeName,
+				MntNsID:      tamper.MntNsID,
+				ProfileName:  tamper.ProfileName,
+				PodNamespace: tamper.PodNamespace,
+				PodName:      tamper.PodName,
+				ContainerID:  tamper.ContainerID,
+				Maps:         tamper.Maps,
+				Restored:     tamper.Restored,
+				Timestamp:    tamper.Timestamp,
+			}
+			reqBody, _ := json.Marshal(&report)
+			err := varmorutils.PostTamperToStatusService(reqBody, agent.debug, agent.managerIP, agent.managerPort)
+			if err != nil {
+				logger.Error(err, "PostTamperToStatusService()")
+			}
+
+		case <-stopCh:
+			return
+		}
+	}
+}
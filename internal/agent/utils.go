@@ -20,11 +20,18 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 
 	version "github.com/hashicorp/go-version"
+	coreV1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	varmorTypes "github.com/bytedance/vArmor/internal/types"
 )
@@ -127,6 +134,67 @@ func retrieveNodeName(podInterface corev1.PodInterface, debug bool) (string, err
 	}
 }
 
+// retrieveNodeLabels retrieve the labels of the node where the agent is located.
+func retrieveNodeLabels(nodeInterface corev1.NodeInterface, nodeName string, debug bool) (map[string]string, error) {
+	nodeLabels := make(map[string]string)
+	if debug {
+		return nodeLabels, nil
+	}
+
+	node, err := nodeInterface.Get(context.Background(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range node.Labels {
+		nodeLabels[k] = v
+	}
+	return nodeLabels, nil
+}
+
+// retrieveNodeAddresses retrieve the internal and external IP addresses of the node where the agent is located.
+func retrieveNodeAddresses(nodeInterface corev1.NodeInterface, nodeName string, debug bool) ([]string, error) {
+	if debug {
+		return nil, nil
+	}
+
+	node, err := nodeInterface.Get(context.Background(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == coreV1.NodeInternalIP || address.Type == coreV1.NodeExternalIP {
+			addresses = append(addresses, address.Address)
+		}
+	}
+	return addresses, nil
+}
+
+// probeFeatureLabels returns the labels of the features probed on the node, the kernel version only contains
+// the major and minor version numbers, e.g. "5.15".
+func probeFeatureLabels(appArmorSupported, bpfLsmSupported, landlockSupported, selinuxSupported bool, kernelRelease string) map[string]string {
+	featureLabels := map[string]string{
+		varmorTypes.AppArmorFeatureLabel: strconv.FormatBool(appArmorSupported),
+		varmorTypes.BpfLsmFeatureLabel:   strconv.FormatBool(bpfLsmSupported),
+		varmorTypes.LandlockFeatureLabel: strconv.FormatBool(landlockSupported),
+		varmorTypes.SELinuxFeatureLabel:  strconv.FormatBool(selinuxSupported),
+	}
+
+	regex := regexp.MustCompile(regexVersion)
+	v, err := version.NewVersion(regex.FindString(kernelRelease))
+	if err == nil {
+		segments := v.Segments()
+		featureLabels[varmorTypes.KernelVersionFeatureLabel] = fmt.Sprintf("%d.%d", segments[0], segments[1])
+	}
+	return featureLabels
+}
+
+// matchNodeSelector checks whether the labels of the node match the node selector of the policy.
+// An empty node selector matches all nodes.
+func matchNodeSelector(nodeSelector map[string]string, nodeLabels map[string]string) bool {
+	return labels.SelectorFromSet(nodeSelector).Matches(labels.Set(nodeLabels))
+}
+
 func newProfileStatus(namespace, name, nodeName string, status varmorTypes.Status, message string) *varmorTypes.ProfileStatus {
 	s := varmorTypes.ProfileStatus{
 		Namespace:   namespace,
@@ -150,3 +218,30 @@ func saveMockAppArmorProfile(fileName string, content string) error {
 	_, err = f.WriteString(content)
 	return err
 }
+
+// appendWarning joins the warnings of the status with semicolons
+func appendWarning(warning string, msg string) string {
+	if msg == "" {
+		return warning
+	}
+	if warning == "" {
+		return msg
+	}
+	return warning + "; " + msg
+}
+
+// newNodePodInformer creates an informer of the pods on the node
+func (agent *Agent) newNodePodInformer() cache.SharedIndexInformer {
+	selector := fields.OneTermEqualSelector("spec.nodeName", agent.nodeName).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return agent.podsGetter.Pods(metav1.NamespaceAll).List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return agent.podsGetter.Pods(metav1.NamespaceAll).Watch(context.Background(), options)
+		},
+	}
+	return cache.NewSharedIndexInformer(lw, &coreV1.Pod{}, 0, cache.Indexers{})
+}
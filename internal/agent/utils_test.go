@@ -19,6 +19,8 @@ import (
 	"testing"
 
 	"gotest.tools/assert"
+
+	varmorTypes "github.com/bytedance/vArmor/internal/types"
 )
 
 func Test_versionGreaterThanOrEqual(t *testing.T) {
@@ -130,3 +132,65 @@ func Test_versionGreaterThanOrEqual(t *testing.T) {
 		})
 	}
 }
+
+func Test_matchNodeSelector(t *testing.T) {
+	nodeLabels := map[string]string{
+		"kubernetes.io/hostname":              "node-1",
+		"nvidia.com/gpu.present":              "true",
+		varmorTypes.BpfLsmFeatureLabel:        "true",
+		varmorTypes.KernelVersionFeatureLabel: "5.15",
+	}
+
+	testCases := []struct {
+		name           string
+		nodeSelector   map[string]string
+		expectedResult bool
+	}{
+		{
+			name:           "empty",
+			nodeSelector:   nil,
+			expectedResult: true,
+		},
+		{
+			name: "match",
+			nodeSelector: map[string]string{
+				"nvidia.com/gpu.present":       "true",
+				varmorTypes.BpfLsmFeatureLabel: "true",
+			},
+			expectedResult: true,
+		},
+		{
+			name: "mismatch",
+			nodeSelector: map[string]string{
+				varmorTypes.KernelVersionFeatureLabel: "6.1",
+			},
+			expectedResult: false,
+		},
+		{
+			name: "missing",
+			nodeSelector: map[string]string{
+				varmorTypes.AppArmorFeatureLabel: "true",
+			},
+			expectedResult: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, matchNodeSelector(tc.nodeSelector, nodeLabels), tc.expectedResult)
+		})
+	}
+}
+
+func Test_probeFeatureLabels(t *testing.T) {
+	featureLabels := probeFeatureLabels(false, true, true, false, "5.15.0-91-generic\n")
+	assert.Equal(t, featureLabels[varmorTypes.AppArmorFeatureLabel], "false")
+	assert.Equal(t, featureLabels[varmorTypes.BpfLsmFeatureLabel], "true")
+	assert.Equal(t, featureLabels[varmorTypes.LandlockFeatureLabel], "true")
+	assert.Equal(t, featureLabels[varmorTypes.SELinuxFeatureLabel], "false")
+	assert.Equal(t, featureLabels[varmorTypes.KernelVersionFeatureLabel], "5.15")
+
+	featureLabels = probeFeatureLabels(true, false, false, false, "")
+	_, ok := featureLabels[varmorTypes.KernelVersionFeatureLabel]
+	assert.Equal(t, ok, false)
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	varmorbpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// violationReportInterval is the interval for reporting the aggregated violations to the manager
+const violationReportInterval = time.Minute
+
+type violationKey struct {
+	profileName  string
+	podNamespace string
+	podName      string
+	ruleID       string
+	ruleType     string
+	capability   string
+}
+
+// aggregateViolation merges the violation into the pending entries. The SPIFFE ID of the workload is derived from
+// its service account in the trust domain.
+func aggregateViolation(pending map[violationKey]*varmortypes.ViolationEntry, v *pkgtypes.Violation, trustDomain string) {
+	key := violationKey{
+		profileName:  v.ProfileName,
+		podNamespace: v.PodNamespace,
+		podName:      v.PodName,
+		ruleID:       v.RuleID,
+		ruleType:     v.RuleType,
+		capability:   varmorbpfenforcer.CapabilityName(v.Capability),
+	}
+
+	// The identical violations may have been aggregated by the BPF enforcer
+	count := v.Count
+	if count < 1 {
+		count = 1
+	}
+	last := v.LastTimestamp
+	if last.IsZero() {
+		last = v.Timestamp
+	}
+
+	if entry, ok := pending[key]; ok {
+		entry.Count += count
+		entry.LastTimestamp = last
+		return
+	}
+
+	pending[key] = &varmortypes.ViolationEntry{
+		PodNamespace:   v.PodNamespace,
+		PodName:        v.PodName,
+		RuleID:         v.RuleID,
+		RuleType:       v.RuleType,
+		Capability:     key.capability,
+		Count:          count,
+		FirstTimestamp: v.Timestamp,
+		LastTimestamp:  last,
+		ServiceAccount: v.ServiceAccount,
+		SPIFFEID:       pkgtypes.SPIFFEID(trustDomain, v.PodNamespace, v.ServiceAccount),
+	}
+}
+
+// reportViolations sends the pending entries to the manager grouped by the ArmorProfile objects
+func (agent *Agent) reportViolations(pending map[violationKey]*varmortypes.ViolationEntry) {
+	logger := agent.log.WithName("reportViolations()")
+
+	entries := make(map[string][]varmortypes.ViolationEntry)
+	for key, entry := range pending {
+		entries[key.profileName] = append(entries[key.profileName], *entry)
+	}
+
+	aps, err := agent.apLister.List(labels.Everything())
+	if err != nil {
+		logger.Error(err, "agent.apLister.List()")
+		return
+	}
+
+	for _, ap := range aps {
+		if _, ok := entries[ap.Spec.Profile.Name]; !ok {
+			continue
+		}
+
+		data := varmortypes.ViolationData{
+			Namespace:   ap.Namespace,
+			ProfileName: ap.Name,
+			NodeName:    agent.nodeName,
+			Entries:     entries[ap.Spec.Profile.Name],
+		}
+		reqBody, _ := json.Marshal(&data)
+		err := varmorutils.PostViolationToStatusService(reqBody, agent.debug, agent.managerIP, agent.managerPort)
+		if err != nil {
+			logger.Error(err, "PostViolationToStatusService()", "profile name", ap.Spec.Profile.Name)
+		}
+	}
+}
+
+// handleViolations aggregates the violations reported by the BPF enforcer by rule and pod,
+// and reports them to the manager periodically.
+func (agent *Agent) handleViolations(stopCh <-chan struct{}) {
+	pending := make(map[violationKey]*varmortypes.ViolationEntry)
+	ticker := time.NewTicker(violationReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case v := <-agent.bpfEnforcer.ViolationCh:
+			if !v.Enriched {
+				// The violation of an unknown container can't be attributed to a policy
+				break
+			}
+			aggregateViolation(pending, &v, agent.spiffeTrustDomain)
+
+		case <-ticker.C:
+			if len(pending) == 0 {
+				break
+			}
+			go agent.reportViolations(pending)
+			pending = make(map[violationKey]*varmortypes.ViolationEntry)
+
+		case <-stopCh:
+			return
+		}
+	}
+}
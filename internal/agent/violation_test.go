@@ -0,0 +1,57 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_aggregateViolation(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	pending := make(map[violationKey]*varmortypes.ViolationEntry)
+
+	violation := pkgtypes.Violation{
+		ProfileName:    "varmor-demo-web",
+		PodNamespace:   "demo",
+		PodName:        "web-1",
+		ServiceAccount: "web",
+		RuleID:         "bpfRawRules.files/0",
+		RuleType:       "file",
+		Capability:     -1,
+		Timestamp:      now,
+	}
+	aggregateViolation(pending, &violation, "cluster.local")
+
+	// The violations aggregated by the BPF enforcer are counted
+	violation.Timestamp = now.Add(time.Second)
+	violation.LastTimestamp = now.Add(time.Minute)
+	violation.Count = 3
+	aggregateViolation(pending, &violation, "cluster.local")
+
+	assert.Equal(t, len(pending), 1)
+	for _, entry := range pending {
+		assert.Equal(t, entry.Count, int64(4))
+		assert.Equal(t, entry.FirstTimestamp, now)
+		assert.Equal(t, entry.LastTimestamp, now.Add(time.Minute))
+		assert.Equal(t, entry.Capability, "")
+		assert.Equal(t, entry.SPIFFEID, "spiffe://cluster.local/ns/demo/sa/web")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// warmUpContainers returns the containers of the pod whose tasks haven't been created, e.g. their images are being
+// pulled, or they're waiting to restart. The BPF profiles of them can be staged ahead of time.
+func warmUpContainers(pod *v1.Pod) []pkgtypes.ContainerInfo {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return nil
+	}
+
+	started := make(map[string]bool)
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.State.Running != nil || status.State.Terminated != nil {
+				started[status.Name] = true
+			}
+		}
+	}
+
+	var infos []pkgtypes.ContainerInfo
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if started[container.Name] {
+				continue
+			}
+			infos = append(infos, pkgtypes.ContainerInfo{
+				ContainerName:  container.Name,
+				PodName:        pod.Name,
+				PodNamespace:   pod.Namespace,
+				PodUID:         string(pod.UID),
+				PodAnnotations: pod.Annotations,
+				PodLabels:      pod.Labels,
+				Image:          container.Image,
+			})
+		}
+	}
+	return infos
+}
+
+// warmUpPod stages the BPF profiles of the containers of the pod that haven't been created
+func (agent *Agent) warmUpPod(pod *v1.Pod) {
+	logger := agent.log.WithName("warmUpPod()")
+
+	for _, info := range warmUpContainers(pod) {
+		err := agent.bpfEnforcer.WarmUp(info)
+		if err != nil {
+			// The profile may not be saved yet, the container is enforced as usual when it's created anyway
+			logger.V(3).Info("WarmUp() failed", "namespace", pod.Namespace, "pod", pod.Name, "container", info.ContainerName, "error", err.Error())
+		}
+	}
+}
+
+// handleMapWarmUps watches the pods on the node, and stages the BPF profiles of their containers before the tasks
+// of them are created, so only the entries of the outer maps are inserted when the tasks are created. The staged
+// profiles are released when the pods are deleted.
+func (agent *Agent) handleMapWarmUps(stopCh <-chan struct{}) {
+	informer := agent.newNodePodInformer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				agent.warmUpPod(pod)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				agent.warmUpPod(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				agent.bpfEnforcer.DiscardWarmUps(string(pod.UID))
+			}
+		},
+	})
+	informer.Run(stopCh)
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_warmUpContainers(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "demo",
+			Name:        "web-0",
+			UID:         "uid-1",
+			Annotations: map[string]string{"container.bpf.security.beta.varmor.org/nginx": "localhost/varmor-demo-web"},
+		},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "init", Image: "busybox"}},
+			Containers:     []v1.Container{{Name: "nginx", Image: "nginx"}, {Name: "sidecar", Image: "envoy"}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodPending,
+			InitContainerStatuses: []v1.ContainerStatus{
+				{Name: "init", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{}}},
+			},
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "nginx", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+				{Name: "sidecar", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			},
+		},
+	}
+
+	infos := warmUpContainers(pod)
+	assert.Equal(t, len(infos), 1)
+	assert.Equal(t, infos[0].ContainerName, "nginx")
+	assert.Equal(t, infos[0].PodUID, "uid-1")
+	assert.Equal(t, infos[0].Image, "nginx")
+
+	pod.Status.Phase = v1.PodSucceeded
+	assert.Equal(t, len(warmUpContainers(pod)), 0)
+}
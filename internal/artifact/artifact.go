@@ -0,0 +1,196 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package artifact packages the profiles as OCI artifacts, and pushes them to or pulls them from the
+// OCI registries. It's used to promote the profiles generated in one cluster to another one reproducibly.
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	// ConfigMediaType is the media type of the config blob, it identifies the type of the artifact
+	ConfigMediaType = "application/vnd.varmor.profile.config.v1+json"
+	// ProfileMediaType is the media type of the layer which holds the profile
+	ProfileMediaType = "application/vnd.varmor.profile.v1+json"
+)
+
+// profileConfig is the config blob of the artifact
+type profileConfig struct {
+	Name     string `json:"name"`
+	Enforcer string `json:"enforcer"`
+	Mode     string `json:"mode"`
+}
+
+// Artifact is the pulled artifact
+type Artifact struct {
+	Profile *varmor.Profile
+	// Signature is the signature of the profile, it's empty if the profile isn't signed
+	Signature string
+	// Digest is the digest of the manifest
+	Digest digest.Digest
+}
+
+type blob struct {
+	descriptor ocispec.Descriptor
+	content    []byte
+}
+
+func newBlob(mediaType string, content []byte) blob {
+	return blob{
+		descriptor: ocispec.Descriptor{
+			MediaType: mediaType,
+			Digest:    digest.FromBytes(content),
+			Size:      int64(len(content)),
+		},
+		content: content,
+	}
+}
+
+// pack builds the manifest, the config blob and the layer of the artifact from the profile
+func pack(profile *varmor.Profile, signature string) (manifest []byte, config blob, layer blob, err error) {
+	c, err := json.Marshal(profileConfig{
+		Name:     profile.Name,
+		Enforcer: profile.Enforcer,
+		Mode:     profile.Mode,
+	})
+	if err != nil {
+		return nil, config, layer, err
+	}
+	config = newBlob(ConfigMediaType, c)
+
+	l, err := json.Marshal(profile)
+	if err != nil {
+		return nil, config, layer, err
+	}
+	layer = newBlob(ProfileMediaType, l)
+
+	m := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config.descriptor,
+		Layers:    []ocispec.Descriptor{layer.descriptor},
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle:   profile.Name,
+			ocispec.AnnotationCreated: time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	if signature != "" {
+		m.Annotations[SignatureAnnotation] = signature
+	}
+	manifest, err = json.Marshal(m)
+	return manifest, config, layer, err
+}
+
+// Push packages the profile as an OCI artifact, and pushes it to the tag of the reference.
+// The signature is saved in the annotations of the manifest if it isn't empty. It returns the digest of
+// the manifest, which can be used to pin the artifact when importing.
+func Push(ctx context.Context, registry *Registry, ref string, profile *varmor.Profile, signature string) (digest.Digest, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.Tag == "" {
+		return "", fmt.Errorf("the reference '%s' must have a tag to push", ref)
+	}
+
+	manifest, config, layer, err := pack(profile, signature)
+	if err != nil {
+		return "", err
+	}
+
+	for _, b := range []blob{config, layer} {
+		err = registry.pushBlob(ctx, r, b.descriptor.Digest, b.content)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = registry.pushManifest(ctx, r, r.Tag, ocispec.MediaTypeImageManifest, manifest)
+	if err != nil {
+		return "", err
+	}
+
+	return digest.FromBytes(manifest), nil
+}
+
+// Pull pulls the OCI artifact of the reference, and returns the profile in it with its signature and the digest
+// of the manifest. If the reference is pinned by digest, the manifest is verified against it.
+func Pull(ctx context.Context, registry *Registry, ref string) (*Artifact, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	reference := r.Tag
+	if r.Digest != "" {
+		reference = r.Digest.String()
+	}
+
+	content, err := registry.pullManifest(ctx, r, reference, ocispec.MediaTypeImageManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestDigest := digest.FromBytes(content)
+	if r.Digest != "" && r.Digest != manifestDigest {
+		return nil, fmt.Errorf("the digest of the manifest (%s) doesn't match the reference", manifestDigest)
+	}
+
+	var manifest ocispec.Manifest
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Config.MediaType != ConfigMediaType {
+		return nil, fmt.Errorf("the artifact isn't a profile of vArmor (config media type: %s)", manifest.Config.MediaType)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ProfileMediaType {
+			continue
+		}
+
+		content, err := registry.pullBlob(ctx, r, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if digest.FromBytes(content) != layer.Digest {
+			return nil, fmt.Errorf("the digest of the layer doesn't match %s", layer.Digest)
+		}
+
+		var profile varmor.Profile
+		err = json.Unmarshal(content, &profile)
+		if err != nil {
+			return nil, err
+		}
+		return &Artifact{
+			Profile:   &profile,
+			Signature: manifest.Annotations[SignatureAnnotation],
+			Digest:    manifestDigest,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no profile found in the artifact")
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_ParseReference(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ref         string
+		expected    Reference
+		expectedErr bool
+	}{
+		{
+			name:     "tag",
+			ref:      "localhost:5000/profiles/demo:v1",
+			expected: Reference{Registry: "localhost:5000", Repository: "profiles/demo", Tag: "v1"},
+		},
+		{
+			name: "digest",
+			ref:  "registry.example.com/demo@sha256:" + strings.Repeat("a", 64),
+			expected: Reference{Registry: "registry.example.com", Repository: "demo",
+				Digest: digest.Digest("sha256:" + strings.Repeat("a", 64))},
+		},
+		{
+			name:        "noRegistry",
+			ref:         "demo:v1",
+			expectedErr: true,
+		},
+		{
+			name:        "noTagOrDigest",
+			ref:         "registry.example.com/demo",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseReference(tc.ref)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, r, tc.expected)
+		})
+	}
+}
+
+// fakeRegistry is an in-memory registry which implements the APIs used by Push and Pull
+func fakeRegistry() *httptest.Server {
+	contents := make(map[string][]byte)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v2/demo")
+		switch {
+		case r.Method == http.MethodPost && path == "/blobs/uploads/":
+			w.Header().Set("Location", "/v2/demo/blobs/uploads/1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && path == "/blobs/uploads/1":
+			contents["/blobs/"+r.URL.Query().Get("digest")], _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			content, _ := io.ReadAll(r.Body)
+			contents[path] = content
+			contents["/manifests/"+digest.FromBytes(content).String()] = content
+			w.WriteHeader(http.StatusCreated)
+		default:
+			content, ok := contents[path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodGet {
+				w.Write(content)
+			}
+		}
+	}))
+}
+
+func Test_PushAndPull(t *testing.T) {
+	server := fakeRegistry()
+	defer server.Close()
+
+	registry := NewRegistry("", "", true, false)
+	host := strings.TrimPrefix(server.URL, "http://")
+	profile := varmor.Profile{
+		Name:           "varmor-demo-demo",
+		Enforcer:       "BPFSeccomp",
+		Mode:           "enforce",
+		BpfContent:     &varmor.BpfContent{Capabilities: 1},
+		SeccompContent: "{}",
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	signature, err := SignProfile(&profile, key)
+	assert.NilError(t, err)
+
+	d, err := Push(context.Background(), registry, host+"/demo:v1", &profile, signature)
+	assert.NilError(t, err)
+
+	a, err := Pull(context.Background(), registry, host+"/demo@"+d.String())
+	assert.NilError(t, err)
+	assert.Equal(t, a.Digest, d)
+	assert.Equal(t, a.Signature, signature)
+	assert.DeepEqual(t, *a.Profile, profile)
+
+	_, err = Pull(context.Background(), registry, host+"/demo:v1@"+digest.FromString("other").String())
+	assert.Assert(t, err != nil)
+}
+
+func Test_VerifyProfile(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	profile := varmor.Profile{
+		Name:     "varmor-cluster-varmor-demo",
+		Enforcer: "AppArmor",
+		Mode:     "enforce",
+		Content:  "profile varmor-cluster-varmor-demo flags=(attach_disconnected,mediate_deleted) {\n}\n",
+	}
+
+	// The profile is renamed when it's imported for another policy
+	renamed := profile
+	renamed.Name = "varmor-demo-demo"
+	renamed.Content = strings.ReplaceAll(profile.Content, profile.Name, renamed.Name)
+
+	// The profile is loosened after it's signed
+	tampered := renamed
+	tampered.Content = "profile varmor-demo-demo flags=(attach_disconnected,mediate_deleted) {\n  file,\n}\n"
+
+	testCases := []struct {
+		name        string
+		signer      crypto.Signer
+		verifier    crypto.PublicKey
+		profile     varmor.Profile
+		expectedErr bool
+	}{
+		{
+			name:     "ecdsa",
+			signer:   ecdsaKey,
+			verifier: ecdsaKey.Public(),
+			profile:  profile,
+		},
+		{
+			name:     "ed25519",
+			signer:   ed25519Key,
+			verifier: ed25519Key.Public(),
+			profile:  profile,
+		},
+		{
+			name:     "renamed",
+			signer:   ecdsaKey,
+			verifier: ecdsaKey.Public(),
+			profile:  renamed,
+		},
+		{
+			name:        "tampered",
+			signer:      ecdsaKey,
+			verifier:    ecdsaKey.Public(),
+			profile:     tampered,
+			expectedErr: true,
+		},
+		{
+			name:        "otherKey",
+			signer:      ecdsaKey,
+			verifier:    otherKey.Public(),
+			profile:     profile,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			signature, err := SignProfile(&profile, tc.signer)
+			assert.NilError(t, err)
+
+			err = VerifyProfile(&tc.profile, signature, tc.verifier)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func Test_IsImportedProfile(t *testing.T) {
+	apm := &varmor.ArmorProfileModel{}
+	assert.Equal(t, IsImportedProfile(apm), false)
+
+	apm.Annotations = map[string]string{ProfileSourceAnnotation: ProfileSourceArtifact}
+	assert.Equal(t, IsImportedProfile(apm), true)
+
+	// The signed profile is imported even if the source marker is missing
+	apm.Annotations = map[string]string{ProfileSignatureAnnotation: "c2lnbmF0dXJl"}
+	assert.Equal(t, IsImportedProfile(apm), true)
+
+	apm.Annotations[ProfileSourceAnnotation] = ProfileSourceArtifact
+	ClearImportedProfile(apm)
+	assert.Equal(t, IsImportedProfile(apm), false)
+	assert.Equal(t, len(apm.Annotations), 0)
+}
@@ -0,0 +1,338 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// maxContentSize is the max size of the manifest or the blob to pull
+const maxContentSize = 4 << 20
+
+// Reference is the parsed reference of an artifact, e.g. registry.example.com/profiles/demo:v1@sha256:...
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     digest.Digest
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest.String()
+	}
+	return s
+}
+
+// ParseReference parses the reference of an artifact. The registry must be specified explicitly.
+func ParseReference(ref string) (Reference, error) {
+	var r Reference
+
+	if i := strings.Index(ref, "@"); i >= 0 {
+		d, err := digest.Parse(ref[i+1:])
+		if err != nil {
+			return r, fmt.Errorf("the digest of the reference '%s' is invalid: %v", ref, err)
+		}
+		r.Digest = d
+		ref = ref[:i]
+	}
+
+	i := strings.Index(ref, "/")
+	if i <= 0 {
+		return r, fmt.Errorf("the reference '%s' must start with the registry", ref)
+	}
+	r.Registry, r.Repository = ref[:i], ref[i+1:]
+
+	if i := strings.LastIndex(r.Repository, ":"); i >= 0 {
+		r.Repository, r.Tag = r.Repository[:i], r.Repository[i+1:]
+	}
+
+	if r.Repository == "" {
+		return r, fmt.Errorf("the repository of the reference '%s' is empty", ref)
+	}
+	if r.Tag == "" && r.Digest == "" {
+		return r, fmt.Errorf("the reference '%s' must have a tag or a digest", ref)
+	}
+
+	return r, nil
+}
+
+// Registry is a minimal client of the OCI distribution API. It supports the basic authentication and
+// the bearer token authentication.
+type Registry struct {
+	client    *http.Client
+	username  string
+	password  string
+	plainHTTP bool
+	tokens    map[string]string // <scope: token>
+}
+
+// NewRegistry creates a registry client with the credentials. Set plainHTTP to access the registry with HTTP,
+// and set insecure to skip the verification of the registry's certificate.
+func NewRegistry(username, password string, plainHTTP bool, insecure bool) *Registry {
+	return &Registry{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+			},
+		},
+		username:  username,
+		password:  password,
+		plainHTTP: plainHTTP,
+		tokens:    make(map[string]string),
+	}
+}
+
+func (reg *Registry) baseURL(r Reference) string {
+	scheme := "https"
+	if reg.plainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s", scheme, r.Registry, r.Repository)
+}
+
+// parseChallenge parses the parameters of the WWW-Authenticate header, e.g. Bearer realm="...",service="..."
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	scheme, rest, _ := strings.Cut(header, " ")
+	for _, param := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok {
+			params[strings.ToLower(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToLower(scheme), params
+}
+
+// fetchToken requests a bearer token from the authorization service
+func (reg *Registry) fetchToken(ctx context.Context, params map[string]string, scope string) (string, error) {
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if reg.username != "" {
+		req.SetBasicAuth(reg.username, reg.password)
+	}
+
+	resp, err := reg.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request the token from %s: %s", u.Host, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxContentSize)).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// do sends the request created by newRequest, and authenticates it with the challenge if it's required
+func (reg *Registry) do(ctx context.Context, scope string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	authorize := func(req *http.Request) {
+		if token, ok := reg.tokens[scope]; ok {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if reg.username != "" {
+			req.SetBasicAuth(reg.username, reg.password)
+		}
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	authorize(req)
+
+	resp, err := reg.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	if scheme != "bearer" {
+		return nil, fmt.Errorf("unauthorized to access %s", req.URL.Host)
+	}
+	token, err := reg.fetchToken(ctx, params, scope)
+	if err != nil {
+		return nil, err
+	}
+	reg.tokens[scope] = token
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	authorize(req)
+	return reg.client.Do(req)
+}
+
+func checkResponse(resp *http.Response, expected ...int) error {
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected response from %s %s: %s %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, string(body))
+}
+
+func pushScope(r Reference) string {
+	return fmt.Sprintf("repository:%s:pull,push", r.Repository)
+}
+
+func pullScope(r Reference) string {
+	return fmt.Sprintf("repository:%s:pull", r.Repository)
+}
+
+// pushBlob uploads the blob with a monolithic upload if it doesn't exist in the repository
+func (reg *Registry) pushBlob(ctx context.Context, r Reference, d digest.Digest, content []byte) error {
+	resp, err := reg.do(ctx, pushScope(r), func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodHead, reg.baseURL(r)+"/blobs/"+d.String(), nil)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = reg.do(ctx, pushScope(r), func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, reg.baseURL(r)+"/blobs/uploads/", nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return err
+	}
+	q := location.Query()
+	q.Set("digest", d.String())
+	location.RawQuery = q.Encode()
+
+	resp, err = reg.do(ctx, pushScope(r), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp, http.StatusCreated)
+}
+
+func (reg *Registry) pushManifest(ctx context.Context, r Reference, reference string, mediaType string, content []byte) error {
+	resp, err := reg.do(ctx, pushScope(r), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, reg.baseURL(r)+"/manifests/"+reference, bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", mediaType)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp, http.StatusCreated)
+}
+
+func (reg *Registry) pull(ctx context.Context, r Reference, path string, accept string) ([]byte, error) {
+	resp, err := reg.do(ctx, pullScope(r), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reg.baseURL(r)+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxContentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxContentSize {
+		return nil, fmt.Errorf("the size of %s exceeds the maximum (%d bytes)", path, maxContentSize)
+	}
+	return content, nil
+}
+
+func (reg *Registry) pullManifest(ctx context.Context, r Reference, reference string, mediaType string) ([]byte, error) {
+	return reg.pull(ctx, r, "/manifests/"+reference, mediaType)
+}
+
+func (reg *Registry) pullBlob(ctx context.Context, r Reference, d digest.Digest) ([]byte, error) {
+	return reg.pull(ctx, r, "/blobs/"+d.String(), "")
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	// SignatureAnnotation is the annotation of the manifest which holds the signature of the profile
+	SignatureAnnotation = "org.varmor.profile.signature"
+	// ProfileSignatureAnnotation is the annotation of the ArmorProfileModel object which holds the signature
+	// of the imported profile. The manager verifies it before generating the ArmorProfile object.
+	ProfileSignatureAnnotation = "profile.varmor.org/signature"
+	// ProfileSourceAnnotation is the annotation of the ArmorProfileModel object which marks where the profile
+	// came from. It's set to ProfileSourceArtifact when the profile is imported from an OCI artifact.
+	ProfileSourceAnnotation = "profile.varmor.org/source"
+	// ProfileSourceArtifact marks the profile imported from an OCI artifact
+	ProfileSourceArtifact = "artifact"
+	// profileNamePlaceholder replaces the name of the profile in the signed payload
+	profileNamePlaceholder = "{{varmor.profile.name}}"
+)
+
+// signingPayload returns the payload of the profile to sign. The name of the profile is replaced with a
+// placeholder, so the signature is still valid after the profile is renamed for the target policy.
+func signingPayload(profile *varmor.Profile) ([]byte, error) {
+	p := profile.DeepCopy()
+	if p.Name != "" {
+		p.Content = strings.ReplaceAll(p.Content, p.Name, profileNamePlaceholder)
+		p.SeccompContent = strings.ReplaceAll(p.SeccompContent, p.Name, profileNamePlaceholder)
+	}
+	p.Name = ""
+	return json.Marshal(p)
+}
+
+// SignProfile signs the profile with the private key, and returns the signature encoded in base64.
+// The ECDSA, Ed25519 and RSA keys are supported.
+func SignProfile(profile *varmor.Profile, key crypto.Signer) (string, error) {
+	payload, err := signingPayload(profile)
+	if err != nil {
+		return "", err
+	}
+
+	var signature []byte
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, payload)
+	default:
+		digest := sha256.Sum256(payload)
+		signature, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyProfile verifies the signature of the profile with the public key
+func VerifyProfile(profile *varmor.Profile, signature string, key crypto.PublicKey) error {
+	if signature == "" {
+		return fmt.Errorf("the profile isn't signed")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("the signature is malformed: %v", err)
+	}
+
+	payload, err := signingPayload(profile)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(payload)
+
+	valid := false
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, payload, sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	if !valid {
+		return fmt.Errorf("the signature of the profile is invalid")
+	}
+	return nil
+}
+
+// LoadPrivateKey loads the PEM-encoded private key (PKCS #8, SEC 1 or PKCS #1) from the file
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type '%s' (the encrypted keys aren't supported)", block.Type)
+	}
+}
+
+// LoadPublicKey loads the PEM-encoded public key (PKIX) from the file
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("no PEM-encoded public key found in %s", path)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// IsImportedProfile reports whether the profile of the ArmorProfileModel object was imported, i.e. it has the source
+// marker or the signature. The manager only verifies the imported profiles, the ones built by the behavior modeling
+// are trusted.
+func IsImportedProfile(apm *varmor.ArmorProfileModel) bool {
+	if apm.Annotations[ProfileSourceAnnotation] == ProfileSourceArtifact {
+		return true
+	}
+	_, ok := apm.Annotations[ProfileSignatureAnnotation]
+	return ok
+}
+
+// ClearImportedProfile removes the source marker and the signature of the imported profile from the ArmorProfileModel
+// object. It's used when the profile is replaced by the one built by the behavior modeling.
+func ClearImportedProfile(apm *varmor.ArmorProfileModel) {
+	delete(apm.Annotations, ProfileSourceAnnotation)
+	delete(apm.Annotations, ProfileSignatureAnnotation)
+}
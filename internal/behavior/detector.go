@@ -0,0 +1,314 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package behavior
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	varmortracer "github.com/bytedance/vArmor/internal/behavior/tracer"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	varmormonitor "github.com/bytedance/vArmor/pkg/runtime"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+	"github.com/bytedance/vArmor/pkg/utils"
+)
+
+const (
+	// driftReportInterval is the interval for reporting the drifts to the manager and forgetting the exited containers
+	driftReportInterval = time.Minute
+	// driftRuleType and driftRuleID identify the drifts in the VarmorViolation object
+	driftRuleType = "drift"
+	driftRuleID   = "driftDetection"
+)
+
+type driftBaseline struct {
+	action      string
+	executables map[string]struct{}
+}
+
+type driftKey struct {
+	podNamespace string
+	podName      string
+	audit        bool
+}
+
+// DriftDetector watches the executions of the target containers, and reports the executables
+// that were never seen during the behavior modeling.
+type DriftDetector struct {
+	tracer      *varmortracer.Tracer
+	monitor     *varmormonitor.RuntimeMonitor
+	nodeName    string
+	namespace   string
+	name        string
+	action      string
+	executables map[string]struct{}
+	containerCh chan pkgtypes.ContainerInfo
+	bpfEventCh  chan varmortypes.BpfTraceEvent
+	baselineCh  chan driftBaseline
+	// targetMnts are the target containers indexed by the id of their mnt ns
+	targetMnts map[uint32]pkgtypes.ContainerInfo
+	// pending are the drifts that haven't been reported to the manager
+	pending        map[driftKey]*varmortypes.ViolationEntry
+	managerIP      string
+	managerPort    int
+	debug          bool
+	DetectorStopCh chan bool
+	stopCh         <-chan struct{}
+	log            logr.Logger
+}
+
+func NewDriftDetector(
+	tracer *varmortracer.Tracer,
+	monitor *varmormonitor.RuntimeMonitor,
+	nodeName string,
+	namespace string,
+	name string,
+	action string,
+	executables []string,
+	stopCh <-chan struct{},
+	managerIP string,
+	managerPort int,
+	debug bool,
+	log logr.Logger) *DriftDetector {
+
+	log.Info("create a drift detector", "profile name", name, "action", action, "executables", len(executables))
+
+	detector := DriftDetector{
+		tracer:         tracer,
+		monitor:        monitor,
+		nodeName:       nodeName,
+		namespace:      namespace,
+		name:           name,
+		action:         action,
+		executables:    make(map[string]struct{}, len(executables)),
+		containerCh:    make(chan pkgtypes.ContainerInfo, 30),
+		bpfEventCh:     make(chan varmortypes.BpfTraceEvent, 500),
+		baselineCh:     make(chan driftBaseline, 1),
+		targetMnts:     make(map[uint32]pkgtypes.ContainerInfo, 30),
+		pending:        make(map[driftKey]*varmortypes.ViolationEntry),
+		managerIP:      managerIP,
+		managerPort:    managerPort,
+		debug:          debug,
+		DetectorStopCh: make(chan bool, 1),
+		stopCh:         stopCh,
+		log:            log,
+	}
+
+	for _, exe := range executables {
+		detector.executables[exe] = struct{}{}
+	}
+
+	return &detector
+}
+
+// UpdateBaseline replaces the learned executables and the action of a running detector.
+func (detector *DriftDetector) UpdateBaseline(action string, executables []string) {
+	detector.log.Info("update the baseline of drift detector", "profile name", detector.name,
+		"action", action, "executables", len(executables))
+
+	baseline := make(map[string]struct{}, len(executables))
+	for _, exe := range executables {
+		baseline[exe] = struct{}{}
+	}
+
+	detector.baselineCh <- driftBaseline{action: action, executables: baseline}
+}
+
+// isLearned checks whether the executable was seen during the behavior modeling. The filename
+// reported by the tracer is truncated to 63 bytes, so the long paths are matched with prefix.
+func (detector *DriftDetector) isLearned(filename string) bool {
+	if _, ok := detector.executables[filename]; ok {
+		return true
+	}
+
+	if len(filename) >= len(varmortypes.BpfTraceEvent{}.Filename)-1 {
+		for exe := range detector.executables {
+			if strings.HasPrefix(exe, filename) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (detector *DriftDetector) handleExecEvent(event *varmortypes.BpfTraceEvent) {
+	if event.Type != varmortypes.SchedProcessExec {
+		return
+	}
+
+	info, ok := detector.targetMnts[event.MntNsId]
+	if !ok {
+		return
+	}
+
+	filename := string(event.Filename[:])
+	if i := bytes.IndexByte(event.Filename[:], 0); i != -1 {
+		filename = string(event.Filename[:i])
+	}
+
+	if detector.isLearned(filename) {
+		return
+	}
+
+	detector.log.Info("drift detected, an unexpected executable was launched",
+		"profile name", detector.name,
+		"profile namespace", detector.namespace,
+		"action", detector.action,
+		"executable", filename,
+		"pid", event.ChildTgid,
+		"mnt ns id", event.MntNsId,
+		"pod namespace", info.PodNamespace,
+		"pod name", info.PodName)
+
+	// The process can only be killed after the executable is loaded, since the tracer observes the executions
+	// with the sched_process_exec tracepoint.
+	if detector.action == varmortypes.DriftDenyAction {
+		err := syscall.Kill(int(event.ChildTgid), syscall.SIGKILL)
+		if err != nil {
+			detector.log.Error(err, "failed to kill the unexpected process", "pid", event.ChildTgid, "executable", filename)
+		}
+	}
+
+	detector.recordDrift(&info, detector.action != varmortypes.DriftDenyAction, time.Now())
+}
+
+// recordDrift aggregates the drift of the container into the pending entries by pod
+func (detector *DriftDetector) recordDrift(info *pkgtypes.ContainerInfo, audit bool, now time.Time) {
+	key := driftKey{
+		podNamespace: info.PodNamespace,
+		podName:      info.PodName,
+		audit:        audit,
+	}
+
+	if entry, ok := detector.pending[key]; ok {
+		entry.Count++
+		entry.LastTimestamp = now
+		return
+	}
+
+	detector.pending[key] = &varmortypes.ViolationEntry{
+		PodNamespace:   info.PodNamespace,
+		PodName:        info.PodName,
+		RuleID:         driftRuleID,
+		RuleType:       driftRuleType,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		ServiceAccount: info.ServiceAccount,
+		Audit:          audit,
+	}
+}
+
+// flushDrifts returns the pending drifts as the violations of the ArmorProfile, or nil if there is none
+func (detector *DriftDetector) flushDrifts() *varmortypes.ViolationData {
+	if len(detector.pending) == 0 {
+		return nil
+	}
+
+	data := varmortypes.ViolationData{
+		Namespace:   detector.namespace,
+		ProfileName: detector.name,
+		NodeName:    detector.nodeName,
+	}
+	for _, entry := range detector.pending {
+		data.Entries = append(data.Entries, *entry)
+	}
+	detector.pending = make(map[driftKey]*varmortypes.ViolationEntry)
+
+	return &data
+}
+
+func (detector *DriftDetector) reportDrifts(data *varmortypes.ViolationData) {
+	reqBody, _ := json.Marshal(data)
+	err := varmorutils.PostViolationToStatusService(reqBody, detector.debug, detector.managerIP, detector.managerPort)
+	if err != nil {
+		detector.log.Error(err, "PostViolationToStatusService()", "profile name", detector.name)
+	}
+}
+
+// pruneTargetMnts forgets the containers that have exited, i.e. their init processes are gone or have been
+// replaced by the processes of other mnt ns.
+func (detector *DriftDetector) pruneTargetMnts() {
+	for nsID, info := range detector.targetMnts {
+		id, err := utils.ReadMntNsID(info.PID)
+		if err != nil || id != nsID {
+			delete(detector.targetMnts, nsID)
+		}
+	}
+}
+
+func (detector *DriftDetector) eventHandler() {
+	ticker := time.NewTicker(driftReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case info := <-detector.containerCh:
+			detector.log.Info("the init process of the target container is created",
+				"pid", info.PID, "profile name", detector.name, "profile namespace", detector.namespace)
+			nsID, err := utils.ReadMntNsID(info.PID)
+			if err == nil {
+				detector.targetMnts[nsID] = info
+			}
+
+		case event := <-detector.bpfEventCh:
+			detector.handleExecEvent(&event)
+
+		case baseline := <-detector.baselineCh:
+			detector.action = baseline.action
+			detector.executables = baseline.executables
+
+		case <-ticker.C:
+			detector.pruneTargetMnts()
+			if data := detector.flushDrifts(); data != nil {
+				go detector.reportDrifts(data)
+			}
+
+		case <-detector.stopCh:
+			detector.stop()
+			detector.log.Info("drift detection is stopped", "profile name", detector.name)
+			return
+
+		case <-detector.DetectorStopCh:
+			detector.stop()
+			detector.log.Info("drift detection is stopped", "profile name", detector.name)
+			return
+		}
+	}
+}
+
+func (detector *DriftDetector) Run() {
+	detector.log.Info("start drift detection", "profile name", detector.name)
+
+	go detector.eventHandler()
+
+	detector.monitor.AddDetectorChs("drift", detector.name, detector.containerCh)
+	detector.tracer.AddEventCh(detector.name+"-drift", detector.bpfEventCh, nil)
+}
+
+func (detector *DriftDetector) stop() {
+	detector.monitor.DeleteDetectorChs("drift", detector.name)
+	detector.tracer.DeleteEventCh(detector.name + "-drift")
+	if data := detector.flushDrifts(); data != nil {
+		go detector.reportDrifts(data)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package behavior
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+	"github.com/bytedance/vArmor/pkg/utils"
+)
+
+func newTestDetector(action string, executables []string) *DriftDetector {
+	return NewDriftDetector(nil, nil, "node-1", "demo", "varmor-demo-test", action, executables,
+		nil, "", 0, false, logr.Discard())
+}
+
+func newExecEvent(mntNsID uint32, filename string) varmortypes.BpfTraceEvent {
+	event := varmortypes.BpfTraceEvent{
+		Type:      varmortypes.SchedProcessExec,
+		ChildTgid: 100,
+		MntNsId:   mntNsID,
+	}
+	copy(event.Filename[:], filename)
+	return event
+}
+
+func Test_isLearned(t *testing.T) {
+	long := "/usr/local/lib/python3.11/site-packages/some/very/long/path/to/the/tool"
+	detector := newTestDetector(varmortypes.DriftAuditAction, []string{"/bin/sh", long})
+
+	testCases := []struct {
+		name     string
+		filename string
+		expected bool
+	}{
+		{name: "learned", filename: "/bin/sh", expected: true},
+		{name: "unknown", filename: "/tmp/xmrig", expected: false},
+		{name: "truncated", filename: long[:63], expected: true},
+		{name: "short prefix", filename: "/usr/local/lib", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, detector.isLearned(tc.filename), tc.expected)
+		})
+	}
+}
+
+func Test_handleExecEvent(t *testing.T) {
+	detector := newTestDetector(varmortypes.DriftAuditAction, []string{"/bin/sh"})
+	detector.targetMnts[1] = pkgtypes.ContainerInfo{PodNamespace: "demo", PodName: "demo-0", ServiceAccount: "demo"}
+
+	fork := newExecEvent(1, "/tmp/xmrig")
+	fork.Type = 0
+	events := []varmortypes.BpfTraceEvent{
+		fork,
+		newExecEvent(2, "/tmp/xmrig"),
+		newExecEvent(1, "/bin/sh"),
+		newExecEvent(1, "/tmp/xmrig"),
+		newExecEvent(1, "/tmp/nc"),
+	}
+	for i := range events {
+		detector.handleExecEvent(&events[i])
+	}
+
+	assert.Equal(t, len(detector.pending), 1)
+	entry := detector.pending[driftKey{podNamespace: "demo", podName: "demo-0", audit: true}]
+	assert.Assert(t, entry != nil)
+	assert.Equal(t, entry.Count, int64(2))
+	assert.Equal(t, entry.RuleType, driftRuleType)
+	assert.Equal(t, entry.RuleID, driftRuleID)
+	assert.Equal(t, entry.ServiceAccount, "demo")
+}
+
+func Test_flushDrifts(t *testing.T) {
+	detector := newTestDetector(varmortypes.DriftDenyAction, nil)
+	assert.Assert(t, detector.flushDrifts() == nil)
+
+	now := time.Now()
+	info := pkgtypes.ContainerInfo{PodNamespace: "demo", PodName: "demo-0"}
+	detector.recordDrift(&info, false, now)
+	detector.recordDrift(&info, false, now.Add(time.Second))
+	detector.recordDrift(&info, true, now)
+
+	data := detector.flushDrifts()
+	assert.Assert(t, data != nil)
+	assert.Equal(t, data.Namespace, "demo")
+	assert.Equal(t, data.ProfileName, "varmor-demo-test")
+	assert.Equal(t, data.NodeName, "node-1")
+	assert.Equal(t, len(data.Entries), 2)
+	for _, entry := range data.Entries {
+		if entry.Audit {
+			assert.Equal(t, entry.Count, int64(1))
+		} else {
+			assert.Equal(t, entry.Count, int64(2))
+			assert.Equal(t, entry.LastTimestamp, now.Add(time.Second))
+		}
+	}
+
+	assert.Equal(t, len(detector.pending), 0)
+	assert.Assert(t, detector.flushDrifts() == nil)
+}
+
+func Test_pruneTargetMnts(t *testing.T) {
+	pid := uint32(os.Getpid())
+	nsID, err := utils.ReadMntNsID(pid)
+	assert.NilError(t, err)
+
+	detector := newTestDetector(varmortypes.DriftAuditAction, nil)
+	detector.targetMnts[nsID] = pkgtypes.ContainerInfo{PID: pid}
+	// The init process has been replaced by a process of another mnt ns
+	detector.targetMnts[nsID+1] = pkgtypes.ContainerInfo{PID: pid}
+	// The init process has exited
+	detector.targetMnts[nsID+2] = pkgtypes.ContainerInfo{PID: 1 << 30}
+
+	detector.pruneTargetMnts()
+
+	assert.Equal(t, len(detector.targetMnts), 1)
+	_, ok := detector.targetMnts[nsID]
+	assert.Assert(t, ok)
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package behavior
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+
+	varmorpreprocessor "github.com/bytedance/vArmor/internal/behavior/preprocessor"
+	varmortracer "github.com/bytedance/vArmor/internal/behavior/tracer"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+// feedbackInterval is the interval to send the collected complain records to manager
+const feedbackInterval = 5 * time.Minute
+
+// ComplainFeedback collects the AppArmor complain records of the profile in the DefenseInDepth mode, and feeds
+// them back into the behavior model periodically, so the profile can be refined with the real behaviors of the
+// target containers rather than only the behaviors recorded during the modeling.
+type ComplainFeedback struct {
+	tracer         *varmortracer.Tracer
+	preprocessor   *varmorpreprocessor.DataPreprocessor
+	name           string
+	auditEventCh   chan string
+	pending        int
+	FeedbackStopCh chan bool
+	stopCh         <-chan struct{}
+	managerIP      string
+	managerPort    int
+	debug          bool
+	log            logr.Logger
+}
+
+func NewComplainFeedback(
+	tracer *varmortracer.Tracer,
+	nodeName string,
+	namespace string,
+	name string,
+	enforcer string,
+	stopCh <-chan struct{},
+	managerIP string,
+	managerPort int,
+	classifierPort int,
+	debug bool,
+	log logr.Logger) *ComplainFeedback {
+
+	log.Info("create a complain feedback", "profile name", name)
+
+	preprocessor := varmorpreprocessor.NewDataPreprocessor(
+		nodeName,
+		namespace,
+		name,
+		enforcer,
+		nil,
+		nil,
+		managerIP,
+		classifierPort,
+		debug,
+		log.WithName("DATA-PREPROCESSOR"))
+	if preprocessor == nil {
+		return nil
+	}
+
+	feedback := ComplainFeedback{
+		tracer:         tracer,
+		preprocessor:   preprocessor,
+		name:           name,
+		auditEventCh:   make(chan string, 500),
+		FeedbackStopCh: make(chan bool, 1),
+		stopCh:         stopCh,
+		managerIP:      managerIP,
+		managerPort:    managerPort,
+		debug:          debug,
+		log:            log,
+	}
+
+	return &feedback
+}
+
+// sendFeedback sends the complain records collected since the last feedback to manager
+func (feedback *ComplainFeedback) sendFeedback() {
+	if feedback.pending == 0 {
+		return
+	}
+
+	data := feedback.preprocessor.Flush()
+	feedback.log.Info("send the complain records to manager", "profile name", feedback.name, "records", feedback.pending)
+	feedback.pending = 0
+	if data == nil {
+		return
+	}
+
+	err := varmorutils.PostDataToStatusService(data, feedback.debug, feedback.managerIP, feedback.managerPort)
+	if err != nil {
+		feedback.log.Error(err, "PostDataToStatusService()")
+	}
+}
+
+func (feedback *ComplainFeedback) eventHandler() {
+	ticker := time.NewTicker(feedbackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-feedback.auditEventCh:
+			if feedback.preprocessor.ProcessComplainRecord(event) {
+				feedback.pending++
+			}
+
+		case <-ticker.C:
+			feedback.sendFeedback()
+
+		case <-feedback.stopCh:
+			feedback.stop()
+			feedback.log.Info("complain feedback is stopped", "profile name", feedback.name)
+			return
+
+		case <-feedback.FeedbackStopCh:
+			feedback.stop()
+			feedback.sendFeedback()
+			feedback.log.Info("complain feedback is stopped", "profile name", feedback.name)
+			return
+		}
+	}
+}
+
+func (feedback *ComplainFeedback) Run() {
+	feedback.log.Info("start complain feedback", "profile name", feedback.name)
+
+	go feedback.eventHandler()
+
+	feedback.tracer.AddEventCh(feedback.name+"-feedback", nil, feedback.auditEventCh)
+}
+
+func (feedback *ComplainFeedback) stop() {
+	feedback.tracer.DeleteEventCh(feedback.name + "-feedback")
+}
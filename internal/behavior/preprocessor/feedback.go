@@ -0,0 +1,74 @@
+// Copyright 2022 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"encoding/json"
+	"strings"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// isComplainRecordOf returns true if the record was issued by the AppArmor profile or its child profiles
+// in complain mode.
+func isComplainRecordOf(event *varmortypes.AaLogRecord, profileName string) bool {
+	if event.AaMode != "ALLOWED" {
+		return false
+	}
+	return event.Profile == profileName || strings.HasPrefix(event.Profile, profileName+"//")
+}
+
+// ProcessComplainRecord parses the audit record, and merges it into the behavior data if it was issued by the
+// profile in complain mode. It returns true if the record is merged.
+//
+// It's used to feed the behaviors violating the profile of the DefenseInDepth mode back into the behavior model.
+// Unlike the records of the behavior modeling, the records are attributed to the profile by the profile name
+// instead of the pids of the target containers.
+func (p *DataPreprocessor) ProcessComplainRecord(line string) bool {
+	if !strings.Contains(line, "type=1400") && !strings.Contains(line, "type=AVC") {
+		return false
+	}
+
+	event, err := parseAppArmorEvent(line)
+	if err != nil {
+		return false
+	}
+
+	if !isComplainRecordOf(event, p.profileName) {
+		return false
+	}
+
+	err = p.parseAppArmorEventForTree(event)
+	if err != nil {
+		p.log.Error(err, "p.parseAppArmorEventForTree() failed", "event", event)
+		return false
+	}
+	return true
+}
+
+// Flush returns the behavior data merged since the last flush, and resets it
+func (p *DataPreprocessor) Flush() []byte {
+	p.behaviorData.Status = varmortypes.Succeeded
+	p.behaviorData.Message = ""
+	p.behaviorData.Feedback = true
+
+	data, err := json.Marshal(p.behaviorData)
+	p.resetDynamicResult()
+	if err != nil {
+		p.log.Error(err, "json.Marshal() failed")
+		return nil
+	}
+	return data
+}
@@ -78,15 +78,7 @@ func NewDataPreprocessor(
 		log:             log,
 	}
 
-	p.behaviorData.DynamicResult.AppArmor.Profiles = make([]string, 0)
-	p.behaviorData.DynamicResult.AppArmor.Executions = make([]string, 0)
-	p.behaviorData.DynamicResult.AppArmor.Files = make([]varmor.File, 0)
-	p.behaviorData.DynamicResult.AppArmor.Capabilities = make([]string, 0)
-	p.behaviorData.DynamicResult.AppArmor.Networks = make([]varmor.Network, 0)
-	p.behaviorData.DynamicResult.AppArmor.Ptraces = make([]varmor.Ptrace, 0)
-	p.behaviorData.DynamicResult.AppArmor.Signals = make([]varmor.Signal, 0)
-	p.behaviorData.DynamicResult.AppArmor.Unhandled = make([]string, 0)
-	p.behaviorData.DynamicResult.Seccomp.Syscall = make([]string, 0)
+	p.resetDynamicResult()
 	p.behaviorData.Namespace = namespace
 	p.behaviorData.NodeName = nodeName
 	p.behaviorData.ProfileName = name
@@ -100,6 +92,18 @@ func NewDataPreprocessor(
 	return &p
 }
 
+func (p *DataPreprocessor) resetDynamicResult() {
+	p.behaviorData.DynamicResult.AppArmor.Profiles = make([]string, 0)
+	p.behaviorData.DynamicResult.AppArmor.Executions = make([]string, 0)
+	p.behaviorData.DynamicResult.AppArmor.Files = make([]varmor.File, 0)
+	p.behaviorData.DynamicResult.AppArmor.Capabilities = make([]string, 0)
+	p.behaviorData.DynamicResult.AppArmor.Networks = make([]varmor.Network, 0)
+	p.behaviorData.DynamicResult.AppArmor.Ptraces = make([]varmor.Ptrace, 0)
+	p.behaviorData.DynamicResult.AppArmor.Signals = make([]varmor.Signal, 0)
+	p.behaviorData.DynamicResult.AppArmor.Unhandled = make([]string, 0)
+	p.behaviorData.DynamicResult.Seccomp.Syscall = make([]string, 0)
+}
+
 func (p *DataPreprocessor) containTargetPID(pid uint32) bool {
 	_, exists := p.targetPIDs[pid]
 	return exists
@@ -20,6 +20,8 @@ import (
 
 	"gotest.tools/assert"
 	log "sigs.k8s.io/controller-runtime/pkg/log"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
 )
 
 func Test_parseAppArmorEvent(t *testing.T) {
@@ -67,3 +69,42 @@ func Test_parseSeccompEvent(t *testing.T) {
 	err = p.parseSeccompEventForTree(event)
 	assert.NilError(t, err)
 }
+
+func Test_isComplainRecordOf(t *testing.T) {
+	testCases := []struct {
+		name     string
+		aaMode   string
+		profile  string
+		expected bool
+	}{
+		{
+			name:     "profile",
+			aaMode:   "ALLOWED",
+			profile:  "varmor-demo-demo-4",
+			expected: true,
+		},
+		{
+			name:     "childProfile",
+			aaMode:   "ALLOWED",
+			profile:  "varmor-demo-demo-4//null-/bin/ping",
+			expected: true,
+		},
+		{
+			name:    "anotherProfile",
+			aaMode:  "ALLOWED",
+			profile: "varmor-demo-demo-40",
+		},
+		{
+			name:    "denied",
+			aaMode:  "DENIED",
+			profile: "varmor-demo-demo-4",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := &varmortypes.AaLogRecord{AaMode: tc.aaMode, Profile: tc.profile}
+			assert.Equal(t, isComplainRecordOf(event, "varmor-demo-demo-4"), tc.expected)
+		})
+	}
+}
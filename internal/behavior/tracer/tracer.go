@@ -269,7 +269,9 @@ func (tracer *Tracer) handleAuditEvents() {
 			event := string(buf[:num])
 			if tracer.auditRegex.FindString(event) != "" {
 				for _, eventCh := range tracer.auditEventChs {
-					eventCh <- event
+					if eventCh != nil {
+						eventCh <- event
+					}
 				}
 			}
 		}
@@ -350,7 +352,9 @@ func (tracer *Tracer) handleBpfEvents() {
 		}
 
 		for _, eventCh := range tracer.bpfEventChs {
-			eventCh <- event
+			if eventCh != nil {
+				eventCh <- event
+			}
 		}
 	}
 }
@@ -15,6 +15,7 @@
 package config
 
 import (
+	"crypto"
 	"fmt"
 	"math"
 	"os"
@@ -60,6 +61,54 @@ var (
 	// DataSyncPath is the path for syncing data
 	DataSyncPath = "/api/v1/data"
 
+	// ViolationSyncPath is the path for syncing violations
+	ViolationSyncPath = "/api/v1/violation"
+
+	// CoverageSyncPath is the path for syncing the enforcement coverage
+	CoverageSyncPath = "/api/v1/coverage"
+
+	// InventorySyncPath is the path for syncing the kernel and LSM features of nodes
+	InventorySyncPath = "/api/v1/inventory"
+
+	// TamperSyncPath is the path for reporting the tampering with the maps of the BPF enforcer
+	TamperSyncPath = "/api/v1/tamper"
+
+	// SuspensionSyncPath is the path for reporting the suspensions of the enforcement of containers
+	SuspensionSyncPath = "/api/v1/suspension"
+
+	// CertificatePath is the path for issuing the client certificates of agents
+	CertificatePath = "/api/v1/certificate"
+
+	// QueryPoliciesPath is the path for querying the policies and their enforcement
+	QueryPoliciesPath = "/api/v1/query/policies"
+
+	// QueryProfilePath is the path for querying the effective profile of an ArmorProfile
+	QueryProfilePath = "/api/v1/query/profiles/:namespace/:name"
+
+	// QueryProfileReportPath is the path for querying the human-readable report of the BPF profile of an ArmorProfile
+	QueryProfileReportPath = "/api/v1/query/profiles/:namespace/:name/report"
+
+	// QueryProfileDiffPath is the path for querying the rules changed between two generations of an ArmorProfile
+	QueryProfileDiffPath = "/api/v1/query/profiles/:namespace/:name/diff"
+
+	// QueryModelReportPath is the path for exporting the behavior model of an ArmorProfileModel as a report
+	QueryModelReportPath = "/api/v1/query/models/:namespace/:name/report"
+
+	// QueryModelDiffPath is the path for querying the behaviors changed between the behavior models of two releases
+	QueryModelDiffPath = "/api/v1/query/models/:namespace/:name/diff"
+
+	// QueryViolationsPath is the path for querying the recent violations
+	QueryViolationsPath = "/api/v1/query/violations"
+
+	// ConvertKubeArmorPolicyPath is the path for converting the KubeArmorPolicy objects into the VarmorPolicy objects
+	ConvertKubeArmorPolicyPath = "/api/v1/convert/kubearmor"
+
+	// GeneratePSSPolicyPath is the path for generating the VarmorClusterPolicy objects from the Pod Security Standards levels
+	GeneratePSSPolicyPath = "/api/v1/generate/pss/:level"
+
+	// GatekeeperProviderPath is the path for providing the external data to OPA Gatekeeper
+	GatekeeperProviderPath = "/api/v1/gatekeeper/provider"
+
 	// WebhookServiceName is the name of webhook service
 	WebhookServiceName = "varmor-webhook-svc"
 
@@ -69,12 +118,31 @@ var (
 	// CertRenewalInterval is the renewal interval for rootCA
 	CertRenewalInterval time.Duration = 12 * time.Hour
 
+	// TighteningSuggestionInterval is the interval for suggesting how to tighten the BPF profiles of the policies
+	TighteningSuggestionInterval time.Duration = time.Hour
+
+	// TighteningObservationPeriod is the period that the hit counters of the rules are observed for since the
+	// policy was created or updated, before the rules are suggested to be removed
+	TighteningObservationPeriod time.Duration = 24 * time.Hour
+
+	// NetworkPeerResyncInterval is the interval for resolving the addresses of the network peers of all ArmorProfile objects
+	NetworkPeerResyncInterval time.Duration = 5 * time.Minute
+
 	// CertValidityDuration is the valid duration for a new cert
 	CertValidityDuration time.Duration = 365 * 24 * time.Hour
 
 	// CertCommonName is the Common Name of CA cert
 	CertCommonName = "*.varmor.svc"
 
+	// AgentCACommonName is the Common Name of the CA cert which issues the client certificates of agents
+	AgentCACommonName = "varmor-agent-ca"
+
+	// AgentCertCommonNamePrefix is the prefix of the Common Name of agent client certs, followed by the node name
+	AgentCertCommonNamePrefix = "varmor-agent:"
+
+	// AgentCertValidityDuration is the valid duration for a new agent client cert
+	AgentCertValidityDuration time.Duration = 24 * time.Hour
+
 	// MutatingWebhookConfigurationName default resource mutating webhook configuration name
 	MutatingWebhookConfigurationName = "varmor-resource-mutating-webhook-cfg"
 
@@ -84,12 +152,27 @@ var (
 	// MutatingWorkloadWebhookName is the name of workload resource mutating webhook
 	MutatingWorkloadWebhookName = "mutateworkload.varmor.org"
 
+	// MutatingBatchWorkloadWebhookName is the name of batch workload resource mutating webhook
+	MutatingBatchWorkloadWebhookName = "mutatebatchworkload.varmor.org"
+
 	// MutatingWorkloadWebhookName is the name of pod resource mutating webhook
 	MutatingPodWebhookName = "mutatepod.varmor.org"
 
 	// MutatingWebhookServicePath is the path for mutation webhook
 	MutatingWebhookServicePath = "/mutate"
 
+	// ValidatingWebhookConfigurationName default policy validating webhook configuration name
+	ValidatingWebhookConfigurationName = "varmor-policy-validating-webhook-cfg"
+
+	// ValidatingWebhookConfigurationDebugName default policy validating webhook configuration name for debug mode
+	ValidatingWebhookConfigurationDebugName = "varmor-policy-validating-webhook-cfg-debug"
+
+	// ValidatingPolicyWebhookName is the name of policy validating webhook
+	ValidatingPolicyWebhookName = "validatepolicy.varmor.org"
+
+	// ValidatingWebhookServicePath is the path for validation webhook
+	ValidatingWebhookServicePath = "/validate"
+
 	// WebhookTimeout specifies the timeout seconds for the mutation webhook
 	WebhookTimeout = 10
 
@@ -108,9 +191,45 @@ var (
 	// SeccompProfileDir is the path of Seccomp profiles in the host
 	SeccompProfileDir = "/var/lib/kubelet/seccomp"
 
+	// LandlockProfileDir is the path of Landlock profiles and the launcher in the host
+	LandlockProfileDir = "/var/lib/varmor/landlock"
+
+	// LandlockContainerDir is the path that the LandlockProfileDir is mounted to in the target containers
+	LandlockContainerDir = "/var/run/varmor/landlock"
+
+	// LandlockVolumeName is the name of the volume which mounts the LandlockProfileDir into the target containers
+	LandlockVolumeName = "varmor-landlock"
+
+	// LandlockLauncherName is the name of the launcher which applies the Landlock profiles in the target containers
+	LandlockLauncherName = "varmor-landlock"
+
+	// PackagedLandlockLauncher is the launcher packaged in the image of agent, it's copied to the LandlockProfileDir
+	PackagedLandlockLauncher = "/varmor/varmor-landlock"
+
+	// SELinuxProfileDir is the path of the CIL policy modules of the SELinux enforcer in the host
+	SELinuxProfileDir = "/var/lib/varmor/selinux"
+
 	// WebhookSelectorLabel is used for matching the admission requests
 	WebhookSelectorLabel = map[string]string{}
 
+	// ProfileVerificationKey is used for verifying the signatures of the profiles imported into the ArmorProfileModel
+	// objects. The verification is disabled if it's nil.
+	ProfileVerificationKey crypto.PublicKey
+
+	// EnableViolationAnomalyDetection is used for baselining the violation rates per workload, and raising the
+	// statistically unusual bursts and the rule classes which never fired before as warning events.
+	EnableViolationAnomalyDetection = false
+
+	// EnableSelfDefense is used for adding the disallow-tamper-varmor rule to the EnhanceProtect profiles, so the
+	// target workloads with some host access can't tamper with the components of vArmor to disable the enforcement.
+	EnableSelfDefense = false
+
+	// SeccompNotifySocketPath is used for receiving the seccomp notify fds of the containers from the container runtime
+	SeccompNotifySocketPath = "/var/run/varmor/seccomp/notify.sock"
+
+	// NriSocketPath is used for registering the NRI plugin of agent with the container runtime
+	NriSocketPath = "/var/run/nri/nri.sock"
+
 	// OmuxSocketPath is used for recieving the audit logs of AppArmor from rsyslog
 	OmuxSocketPath = "/var/run/varmor/audit/omuxsock.sock"
 )
@@ -0,0 +1,365 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package integrity implements the file integrity monitoring of the critical paths inside the target containers
+package integrity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+
+	varmormonitor "github.com/bytedance/vArmor/pkg/runtime"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+	"github.com/bytedance/vArmor/pkg/utils"
+)
+
+const (
+	watchMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM |
+		unix.IN_CREATE | unix.IN_DELETE | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+)
+
+// watch describes a directory watched in the container
+type watch struct {
+	pid uint32
+	// root is the root directory of the container
+	root string
+	// dir is the path of the watched directory inside the container
+	dir string
+	// names are the entries of the directory that need to be monitored, nil means all entries
+	names map[string]struct{}
+}
+
+type IntegrityMonitor struct {
+	monitor       *varmormonitor.RuntimeMonitor
+	namespace     string
+	name          string
+	paths         []string
+	containersCh  chan varmortypes.ContainerInfo
+	pathsCh       chan []string
+	targetPIDs    map[uint32]struct{}
+	inotifyFd     int
+	inotifyFile   *os.File
+	watches       map[int]*watch
+	watchesLock   sync.RWMutex
+	MonitorStopCh chan bool
+	stopCh        <-chan struct{}
+	log           logr.Logger
+}
+
+func NewIntegrityMonitor(
+	monitor *varmormonitor.RuntimeMonitor,
+	namespace string,
+	name string,
+	paths []string,
+	stopCh <-chan struct{},
+	log logr.Logger) *IntegrityMonitor {
+
+	log.Info("create a file integrity monitor", "profile name", name, "paths", paths)
+
+	m := IntegrityMonitor{
+		monitor:       monitor,
+		namespace:     namespace,
+		name:          name,
+		paths:         paths,
+		containersCh:  make(chan varmortypes.ContainerInfo, 30),
+		pathsCh:       make(chan []string, 1),
+		targetPIDs:    make(map[uint32]struct{}, 30),
+		watches:       make(map[int]*watch),
+		MonitorStopCh: make(chan bool, 1),
+		stopCh:        stopCh,
+		log:           log,
+	}
+
+	return &m
+}
+
+// UpdatePaths replaces the critical paths of a running monitor.
+func (m *IntegrityMonitor) UpdatePaths(paths []string) {
+	m.log.Info("update the paths of file integrity monitor", "profile name", m.name, "paths", paths)
+	m.pathsCh <- paths
+}
+
+// addWatches watches the critical paths inside the container whose init process is pid
+func (m *IntegrityMonitor) addWatches(pid uint32) error {
+	return m.addWatchesInRoot(pid, fmt.Sprintf("/proc/%d/root", pid))
+}
+
+// addWatchesInRoot watches the critical paths inside the root directory of the container. The directories are
+// resolved inside the root, so the symlinks of the container can't redirect the watches onto the host paths.
+func (m *IntegrityMonitor) addWatchesInRoot(pid uint32, rootPath string) error {
+	root, err := utils.OpenRoot(rootPath)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	m.watchesLock.Lock()
+	defer m.watchesLock.Unlock()
+
+	for _, path := range m.paths {
+		var dir string
+		var names map[string]struct{}
+
+		if strings.HasSuffix(path, "/") {
+			dir = filepath.Clean(path)
+		} else {
+			dir = filepath.Dir(path)
+			names = map[string]struct{}{filepath.Base(path): {}}
+		}
+
+		wd, err := m.addWatch(root, dir)
+		if err != nil {
+			m.log.Error(err, "failed to watch the path", "pid", pid, "path", path)
+			continue
+		}
+
+		if w, ok := m.watches[wd]; ok && w.dir == dir {
+			// The directory is shared with another path or container (e.g. a volume).
+			if w.names != nil && names != nil {
+				for name := range names {
+					w.names[name] = struct{}{}
+				}
+			} else {
+				w.names = nil
+			}
+			continue
+		}
+
+		m.watches[wd] = &watch{
+			pid:   pid,
+			root:  rootPath,
+			dir:   dir,
+			names: names,
+		}
+	}
+
+	return nil
+}
+
+// addWatch watches the directory inside the root through the file descriptor of the resolved directory
+func (m *IntegrityMonitor) addWatch(root *utils.Root, dir string) (int, error) {
+	file, err := root.Open(dir, unix.O_PATH|unix.O_DIRECTORY)
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+
+	wd, err := unix.InotifyAddWatch(m.inotifyFd, utils.ProcPath(file), watchMask)
+	if err != nil {
+		return -1, fmt.Errorf("unix.InotifyAddWatch() failed: %w", err)
+	}
+	return wd, nil
+}
+
+func (m *IntegrityMonitor) removeWatches() {
+	m.watchesLock.Lock()
+	defer m.watchesLock.Unlock()
+
+	for wd := range m.watches {
+		unix.InotifyRmWatch(m.inotifyFd, uint32(wd))
+		delete(m.watches, wd)
+	}
+}
+
+// hashFile calculates the SHA256 of the file inside the root directory of the container
+func hashFile(rootPath string, path string) (string, error) {
+	root, err := utils.OpenRoot(rootPath)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+
+	file, err := root.Open(path, unix.O_RDONLY|unix.O_NONBLOCK)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func operation(mask uint32) string {
+	switch {
+	case mask&unix.IN_CLOSE_WRITE != 0:
+		return "write"
+	case mask&unix.IN_MOVED_TO != 0:
+		return "rename_to"
+	case mask&unix.IN_MOVED_FROM != 0, mask&unix.IN_MOVE_SELF != 0:
+		return "rename_from"
+	case mask&unix.IN_CREATE != 0:
+		return "create"
+	case mask&unix.IN_DELETE != 0, mask&unix.IN_DELETE_SELF != 0:
+		return "delete"
+	case mask&unix.IN_ATTRIB != 0:
+		return "attrib"
+	}
+	return ""
+}
+
+func (m *IntegrityMonitor) handleInotifyEvent(event *unix.InotifyEvent, name string) {
+	if event.Mask&unix.IN_IGNORED != 0 {
+		m.watchesLock.Lock()
+		delete(m.watches, int(event.Wd))
+		m.watchesLock.Unlock()
+		return
+	}
+
+	m.watchesLock.RLock()
+	w, ok := m.watches[int(event.Wd)]
+	m.watchesLock.RUnlock()
+	if !ok {
+		return
+	}
+
+	if w.names != nil && name != "" {
+		if _, ok := w.names[name]; !ok {
+			return
+		}
+	}
+
+	op := operation(event.Mask)
+	if op == "" {
+		return
+	}
+
+	path := filepath.Join(w.dir, name)
+	var sha256 string
+	if op == "write" || op == "rename_to" {
+		var err error
+		sha256, err = hashFile(w.root, path)
+		if err != nil {
+			m.log.V(3).Info("failed to calculate the hash of the file", "path", path, "error", err)
+		}
+	}
+
+	m.log.Info("file integrity event, a critical path was modified",
+		"profile name", m.name,
+		"profile namespace", m.namespace,
+		"operation", op,
+		"path", path,
+		"sha256", sha256,
+		"pid", w.pid)
+}
+
+// readInotifyEvents reads and dispatches the inotify events until the inotify file is closed
+func (m *IntegrityMonitor) readInotifyEvents(file *os.File) {
+	var buf [unix.SizeofInotifyEvent * 4096]byte
+
+	for {
+		n, err := file.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				m.log.Error(err, "failed to read the inotify events")
+			}
+			return
+		}
+
+		offset := 0
+		for offset+unix.SizeofInotifyEvent <= n {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+
+			var name string
+			if event.Len > 0 && nameEnd <= n {
+				name = string(bytes.TrimRight(buf[nameStart:nameEnd], "\x00"))
+			}
+
+			m.handleInotifyEvent(event, name)
+			offset = nameEnd
+		}
+	}
+}
+
+func (m *IntegrityMonitor) eventHandler() {
+	for {
+		select {
+		case info := <-m.containersCh:
+			pid := info.PID
+			m.log.Info("the init process of the target container is created",
+				"pid", pid, "profile name", m.name, "profile namespace", m.namespace)
+			if err := m.addWatches(pid); err == nil {
+				m.targetPIDs[pid] = struct{}{}
+			}
+
+		case paths := <-m.pathsCh:
+			m.removeWatches()
+			m.paths = paths
+			for pid := range m.targetPIDs {
+				if err := m.addWatches(pid); err != nil {
+					// The container has exited.
+					delete(m.targetPIDs, pid)
+				}
+			}
+
+		case <-m.stopCh:
+			m.stop()
+			m.log.Info("file integrity monitoring is stopped", "profile name", m.name)
+			return
+
+		case <-m.MonitorStopCh:
+			m.stop()
+			m.log.Info("file integrity monitoring is stopped", "profile name", m.name)
+			return
+		}
+	}
+}
+
+func (m *IntegrityMonitor) Run() error {
+	m.log.Info("start file integrity monitoring", "profile name", m.name)
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("unix.InotifyInit1() failed: %v", err)
+	}
+	// Keep the raw fd for adding watches, calling File.Fd() would put the file into blocking mode.
+	m.inotifyFd = fd
+	m.inotifyFile = os.NewFile(uintptr(fd), "inotify")
+
+	go m.readInotifyEvents(m.inotifyFile)
+	go m.eventHandler()
+
+	m.monitor.AddDetectorChs("integrity", m.name, m.containersCh)
+
+	return nil
+}
+
+func (m *IntegrityMonitor) stop() {
+	m.monitor.DeleteDetectorChs("integrity", m.name)
+	m.inotifyFile.Close()
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+)
+
+// newTestRoot creates the root directory of a container whose /escape and /var symlinks point to the host paths
+func newTestRoot(t *testing.T) (string, string) {
+	host := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(host, "secret"), []byte("host"), 0600))
+
+	root := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, "etc"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("root:x:0:0::/root:/bin/sh\n"), 0644))
+	assert.NilError(t, os.Symlink(filepath.Join(host, "secret"), filepath.Join(root, "escape")))
+	assert.NilError(t, os.Symlink(host, filepath.Join(root, "var")))
+	return root, host
+}
+
+func skipIfNoOpenat2(t *testing.T, err error) {
+	if errors.Is(err, unix.ENOSYS) {
+		t.Skip("openat2(2) is not supported by the kernel")
+	}
+}
+
+func Test_hashFile(t *testing.T) {
+	root, _ := newTestRoot(t)
+	sum := sha256.Sum256([]byte("root:x:0:0::/root:/bin/sh\n"))
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+		isErr    bool
+	}{
+		{name: "regular file", path: "/etc/passwd", expected: hex.EncodeToString(sum[:])},
+		{name: "symlink to host", path: "/escape", isErr: true},
+		{name: "directory", path: "/etc", isErr: true},
+		{name: "not exist", path: "/etc/shadow", isErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hash, err := hashFile(root, tc.path)
+			skipIfNoOpenat2(t, err)
+			if tc.isErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, hash, tc.expected)
+		})
+	}
+}
+
+func Test_addWatchesInRoot(t *testing.T) {
+	root, host := newTestRoot(t)
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	assert.NilError(t, err)
+	defer unix.Close(fd)
+
+	paths := []string{"/etc/passwd", "/etc/shadow", "/var/", "/var/secret"}
+	m := NewIntegrityMonitor(nil, "demo", "varmor-demo-test", paths, nil, logr.Discard())
+	m.inotifyFd = fd
+
+	err = m.addWatchesInRoot(1, root)
+	skipIfNoOpenat2(t, err)
+	assert.NilError(t, err)
+
+	// The /var symlink is resolved inside the root, so the host directory isn't watched
+	assert.Equal(t, len(m.watches), 1)
+	for _, w := range m.watches {
+		assert.Equal(t, w.dir, "/etc")
+		assert.Equal(t, w.root, root)
+		assert.DeepEqual(t, w.names, map[string]struct{}{"passwd": {}, "shadow": {}})
+	}
+
+	// The modification of the host file doesn't raise any event
+	assert.NilError(t, os.WriteFile(filepath.Join(host, "secret"), []byte("modified"), 0600))
+	var buf [unix.SizeofInotifyEvent * 16]byte
+	_, err = unix.Read(fd, buf[:])
+	assert.Assert(t, errors.Is(err, unix.EAGAIN))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("modified"), 0644))
+	n, err := unix.Read(fd, buf[:])
+	assert.NilError(t, err)
+	assert.Assert(t, n >= unix.SizeofInotifyEvent)
+}
+
+func Test_operation(t *testing.T) {
+	testCases := []struct {
+		mask     uint32
+		expected string
+	}{
+		{mask: unix.IN_CLOSE_WRITE, expected: "write"},
+		{mask: unix.IN_MOVED_TO, expected: "rename_to"},
+		{mask: unix.IN_MOVED_FROM, expected: "rename_from"},
+		{mask: unix.IN_MOVE_SELF, expected: "rename_from"},
+		{mask: unix.IN_CREATE, expected: "create"},
+		{mask: unix.IN_DELETE, expected: "delete"},
+		{mask: unix.IN_DELETE_SELF, expected: "delete"},
+		{mask: unix.IN_ATTRIB, expected: "attrib"},
+		{mask: unix.IN_OPEN, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, operation(tc.mask), tc.expected)
+	}
+}
@@ -21,12 +21,14 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
 	apicorev1 "k8s.io/api/core/v1"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	appsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+	batchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"k8s.io/client-go/tools/cache"
@@ -38,6 +40,7 @@ import (
 	varmorconfig "github.com/bytedance/vArmor/internal/config"
 	varmorprofile "github.com/bytedance/vArmor/internal/profile"
 	statusmanager "github.com/bytedance/vArmor/internal/status/api/v1"
+	varmortracing "github.com/bytedance/vArmor/internal/tracing"
 	varmortypes "github.com/bytedance/vArmor/internal/types"
 	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned/typed/varmor/v1beta1"
 	varmorinformer "github.com/bytedance/vArmor/pkg/client/informers/externalversions/varmor/v1beta1"
@@ -47,6 +50,7 @@ import (
 type ClusterPolicyController struct {
 	podInterface           corev1.PodInterface
 	appsInterface          appsv1.AppsV1Interface
+	batchInterface         batchv1.BatchV1Interface
 	varmorInterface        varmorinterface.CrdV1beta1Interface
 	vcpInformer            varmorinformer.VarmorClusterPolicyInformer
 	vcpLister              varmorlister.VarmorClusterPolicyLister
@@ -64,6 +68,7 @@ type ClusterPolicyController struct {
 func NewClusterPolicyController(
 	podInterface corev1.PodInterface,
 	appsInterface appsv1.AppsV1Interface,
+	batchInterface batchv1.BatchV1Interface,
 	varmorInterface varmorinterface.CrdV1beta1Interface,
 	vcpInformer varmorinformer.VarmorClusterPolicyInformer,
 	statusManager *statusmanager.StatusManager,
@@ -76,6 +81,7 @@ func NewClusterPolicyController(
 	c := ClusterPolicyController{
 		podInterface:           podInterface,
 		appsInterface:          appsInterface,
+		batchInterface:         batchInterface,
 		varmorInterface:        varmorInterface,
 		vcpInformer:            vcpInformer,
 		vcpLister:              vcpInformer.Lister(),
@@ -164,10 +170,11 @@ func (c *ClusterPolicyController) handleDeleteVarmorClusterPolicy(name string) e
 		logger.Info("delete annotations of target workloads to trigger a rolling upgrade asynchronously")
 		go updateWorkloadAnnotationsAndEnv(
 			c.appsInterface,
+			c.batchInterface,
 			metav1.NamespaceAll,
 			ap.Spec.Profile.Enforcer,
 			ap.Spec.Target,
-			"", false, logger)
+			"", false, false, logger)
 	}
 
 	// Cleanup the PolicyStatus and ModelingStatus of status manager for the deleted VarmorClusterPolicy/ArmorProfile object
@@ -229,7 +236,11 @@ func (c *ClusterPolicyController) updateVarmorClusterPolicyStatus(
 }
 
 func (c *ClusterPolicyController) ignoreAdd(vcp *varmor.VarmorClusterPolicy, logger logr.Logger) bool {
-	if vcp.Spec.Target.Kind != "Deployment" && vcp.Spec.Target.Kind != "StatefulSet" && vcp.Spec.Target.Kind != "DaemonSet" && vcp.Spec.Target.Kind != "Pod" {
+	// The custom owner kinds are specified with the APIVersion field
+	if vcp.Spec.Target.APIVersion == "" &&
+		vcp.Spec.Target.Kind != "Deployment" && vcp.Spec.Target.Kind != "StatefulSet" && vcp.Spec.Target.Kind != "DaemonSet" &&
+		vcp.Spec.Target.Kind != "Job" && vcp.Spec.Target.Kind != "CronJob" && vcp.Spec.Target.Kind != "Pod" &&
+		vcp.Spec.Target.Kind != "HostProcess" {
 		err := fmt.Errorf("Target.Kind is not supported")
 		logger.Error(err, "update VarmorClusterPolicy/status with forbidden info")
 		err = c.updateVarmorClusterPolicyStatus(vcp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
@@ -241,12 +252,24 @@ func (c *ClusterPolicyController) ignoreAdd(vcp *varmor.VarmorClusterPolicy, log
 		return true
 	}
 
-	if vcp.Spec.Target.Name == "" && vcp.Spec.Target.Selector == nil {
-		err := fmt.Errorf("target.Name and target.Selector are empty")
+	if vcp.Spec.Target.Kind == "HostProcess" {
+		err := validateHostProcessTarget(vcp.Spec.Target, vcp.Spec.Policy)
+		if err != nil {
+			logger.Error(err, "update VarmorClusterPolicy/status with forbidden info")
+			err = c.updateVarmorClusterPolicyStatus(vcp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
+				"Forbidden",
+				err.Error())
+			if err != nil {
+				logger.Error(err, "updateVarmorClusterPolicyStatus()")
+			}
+			return true
+		}
+	} else if vcp.Spec.Target.Name == "" && vcp.Spec.Target.Selector == nil && len(vcp.Spec.Target.ServiceAccounts) == 0 {
+		err := fmt.Errorf("target.Name, target.Selector and target.ServiceAccounts are empty")
 		logger.Error(err, "update VarmorClusterPolicy/status with forbidden info")
 		err = c.updateVarmorClusterPolicyStatus(vcp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
 			"Forbidden",
-			"You should specify the target workload by name or selector.")
+			"You should specify the target workload by name, selector or service accounts.")
 		if err != nil {
 			logger.Error(err, "updateVarmorClusterPolicyStatus()")
 		}
@@ -296,7 +319,7 @@ func (c *ClusterPolicyController) ignoreAdd(vcp *varmor.VarmorClusterPolicy, log
 	return false
 }
 
-func (c *ClusterPolicyController) handleAddVarmorClusterPolicy(vcp *varmor.VarmorClusterPolicy) error {
+func (c *ClusterPolicyController) handleAddVarmorClusterPolicy(ctx context.Context, vcp *varmor.VarmorClusterPolicy) error {
 	logger := c.log.WithName("handleAddVarmorClusterPolicy()")
 
 	logger.Info("VarmorClusterPolicy created", "name", vcp.Name, "labels", vcp.Labels, "target", vcp.Spec.Target)
@@ -335,22 +358,31 @@ func (c *ClusterPolicyController) handleAddVarmorClusterPolicy(vcp *varmor.Varmo
 	c.statusManager.UpdateDesiredNumber = true
 
 	logger.Info("create ArmorProfile")
-	ap, err = c.varmorInterface.ArmorProfiles(varmorconfig.Namespace).Create(context.Background(), ap, metav1.CreateOptions{})
+	varmortracing.InjectIntoObject(ctx, ap)
+	ap, err = c.varmorInterface.ArmorProfiles(varmorconfig.Namespace).Create(ctx, ap, metav1.CreateOptions{})
 	if err != nil {
 		logger.Error(err, "ArmorProfile().Create()")
 		return err
 	}
 
+	err = varmorprofile.RecordProfileHistory(c.varmorInterface, ap.Namespace, ap.Name)
+	if err != nil {
+		logger.Error(err, "RecordProfileHistory()")
+	}
+	c.statusManager.NotifyLifecycleHooks(vcp.Spec.Policy.LifecycleHooks, statusmanager.NewLifecycleEvent(varmortypes.PreEnforceEvent, "", vcp.Name, ap))
+
 	if c.restartExistWorkloads && vcp.Spec.UpdateExistingWorkloads {
 		// This will trigger the rolling upgrade of the target workloads
 		logger.Info("add annotations to target workloads to trigger a rolling upgrade asynchronously")
 		go updateWorkloadAnnotationsAndEnv(
 			c.appsInterface,
+			c.batchInterface,
 			metav1.NamespaceAll,
 			vcp.Spec.Policy.Enforcer,
 			vcp.Spec.Target,
 			ap.Name,
 			c.bpfExclusiveMode,
+			vcp.Spec.Policy.ConfineSandboxContainers,
 			logger)
 	}
 
@@ -404,8 +436,9 @@ func (c *ClusterPolicyController) ignoreUpdate(newVp *varmor.VarmorClusterPolicy
 	// Nothing need to be updated if VarmorClusterPolicy is in the modeling phase and its duration is not changed.
 	if newVp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode &&
 		newVp.Status.Phase == varmortypes.VarmorPolicyModeling &&
-		newVp.Spec.Policy.ModelingOptions.Duration == oldAp.Spec.BehaviorModeling.Duration {
-		logger.Info("nothing need to be updated (duration is not changed)")
+		newVp.Spec.Policy.ModelingOptions.Duration == oldAp.Spec.BehaviorModeling.Duration &&
+		newVp.Spec.Policy.ModelingOptions.PathGeneralization == oldAp.Spec.BehaviorModeling.PathGeneralization {
+		logger.Info("nothing need to be updated (modeling options are not changed)")
 		return true, nil
 	}
 
@@ -422,7 +455,7 @@ func (c *ClusterPolicyController) ignoreUpdate(newVp *varmor.VarmorClusterPolicy
 	return false, nil
 }
 
-func (c *ClusterPolicyController) handleUpdateVarmorClusterPolicy(newVp *varmor.VarmorClusterPolicy, oldAp *varmor.ArmorProfile) error {
+func (c *ClusterPolicyController) handleUpdateVarmorClusterPolicy(ctx context.Context, newVp *varmor.VarmorClusterPolicy, oldAp *varmor.ArmorProfile) error {
 	logger := c.log.WithName("handleUpdateVarmorClusterPolicy()")
 
 	logger.Info("VarmorClusterPolicy updated", "name", newVp.Name, "labels", newVp.Labels, "target", newVp.Spec.Target)
@@ -456,10 +489,40 @@ func (c *ClusterPolicyController) handleUpdateVarmorClusterPolicy(newVp *varmor.
 		}
 		return nil
 	}
+	varmorprofile.InheritNetworkPeerAddresses(newProfile, &oldAp.Spec)
 	newApSpec.Profile = *newProfile
 	newApSpec.UpdateExistingWorkloads = newVp.Spec.UpdateExistingWorkloads
+	newApSpec.NodeSelector = newVp.Spec.NodeSelector
+
+	newDriftDetection, err := varmorprofile.GenerateDriftDetection(newVp.Spec.Policy.DriftDetectionOptions, oldAp.Name, oldAp.Namespace, c.varmorInterface)
+	if err != nil {
+		logger.Error(err, "GenerateDriftDetection() failed")
+		err = c.updateVarmorClusterPolicyStatus(newVp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
+			"Error",
+			err.Error())
+		if err != nil {
+			logger.Error(err, "updateVarmorClusterPolicyStatus()")
+			return err
+		}
+		return nil
+	}
+	newApSpec.DriftDetection = *newDriftDetection
+	newApSpec.FileIntegrity = *varmorprofile.GenerateFileIntegrity(newVp.Spec.Policy)
 	if newVp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode {
-		newApSpec.BehaviorModeling.Duration = newVp.Spec.Policy.ModelingOptions.Duration
+		newBehaviorModeling, err := varmorprofile.GenerateBehaviorModeling(newVp.Spec.Policy.ModelingOptions)
+		if err != nil {
+			logger.Error(err, "GenerateBehaviorModeling() failed")
+			err = c.updateVarmorClusterPolicyStatus(newVp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
+				"Error",
+				err.Error())
+			if err != nil {
+				logger.Error(err, "updateVarmorClusterPolicyStatus()")
+				return err
+			}
+			return nil
+		}
+		newApSpec.BehaviorModeling.Duration = newBehaviorModeling.Duration
+		newApSpec.BehaviorModeling.PathGeneralization = newBehaviorModeling.PathGeneralization
 	}
 
 	// Last, do update
@@ -489,12 +552,27 @@ func (c *ClusterPolicyController) handleUpdateVarmorClusterPolicy(newVp *varmor.
 		c.statusManager.ResetCh <- statusKey
 
 		logger.Info("2.3. update ArmorProfile")
+		previousMode := oldAp.Spec.Profile.Mode
 		oldAp.Spec = *newApSpec
-		_, err = c.varmorInterface.ArmorProfiles(oldAp.Namespace).Update(context.Background(), oldAp, metav1.UpdateOptions{})
+		varmortracing.InjectIntoObject(ctx, oldAp)
+		ap, err := c.varmorInterface.ArmorProfiles(oldAp.Namespace).Update(ctx, oldAp, metav1.UpdateOptions{})
 		if err != nil {
 			logger.Error(err, "ArmorProfile().Update()")
 			return err
 		}
+
+		err = varmorprofile.RecordProfileHistory(c.varmorInterface, ap.Namespace, ap.Name)
+		if err != nil {
+			logger.Error(err, "RecordProfileHistory()")
+		}
+
+		hooks := newVp.Spec.Policy.LifecycleHooks
+		c.statusManager.NotifyLifecycleHooks(hooks, statusmanager.NewLifecycleEvent(varmortypes.PreEnforceEvent, "", newVp.Name, ap))
+		if ap.Spec.Profile.Mode != previousMode {
+			event := statusmanager.NewLifecycleEvent(varmortypes.ModeChangedEvent, "", newVp.Name, ap)
+			event.PreviousMode = previousMode
+			c.statusManager.NotifyLifecycleHooks(hooks, event)
+		}
 	} else {
 		// Update status
 		logger.Info("2. update the object' status")
@@ -505,9 +583,12 @@ func (c *ClusterPolicyController) handleUpdateVarmorClusterPolicy(newVp *varmor.
 	return nil
 }
 
-func (c *ClusterPolicyController) syncClusterPolicy(key string) error {
+func (c *ClusterPolicyController) syncClusterPolicy(key string) (err error) {
 	logger := c.log.WithName("syncClusterPolicy()")
 
+	ctx, span := varmortracing.StartSpan(context.Background(), "ClusterPolicyController.syncClusterPolicy", attribute.String("key", key))
+	defer func() { varmortracing.EndSpan(span, err) }()
+
 	startTime := time.Now()
 	logger.V(3).Info("started syncing policy", "key", key, "startTime", startTime)
 	defer func() {
@@ -538,7 +619,7 @@ func (c *ClusterPolicyController) syncClusterPolicy(key string) error {
 		if k8errors.IsNotFound(err) {
 			// VarmorClusterPolicy create event
 			logger.V(3).Info("processing VarmorClusterPolicy create event")
-			return c.handleAddVarmorClusterPolicy(vcp)
+			return c.handleAddVarmorClusterPolicy(ctx, vcp)
 		} else {
 			logger.Error(err, "c.varmorInterface.ArmorProfiles().Get()")
 			return err
@@ -546,7 +627,7 @@ func (c *ClusterPolicyController) syncClusterPolicy(key string) error {
 	} else {
 		// VarmorClusterPolicy update event
 		logger.V(3).Info("processing VarmorClusterPolicy update event")
-		return c.handleUpdateVarmorClusterPolicy(vcp, ap)
+		return c.handleUpdateVarmorClusterPolicy(ctx, vcp, ap)
 	}
 }
 
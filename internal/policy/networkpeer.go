@@ -0,0 +1,325 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned/typed/varmor/v1beta1"
+	varmorinformer "github.com/bytedance/vArmor/pkg/client/informers/externalversions/varmor/v1beta1"
+	varmorlister "github.com/bytedance/vArmor/pkg/client/listers/varmor/v1beta1"
+)
+
+// networkPeerResolveDelay is the delay for batching the changes of the Services, EndpointSlices and Pods
+const networkPeerResolveDelay = 2 * time.Second
+
+// NetworkPeerResolver resolves the Services and Pods referenced by the network rules of the ArmorProfile objects
+// into their IPs. It watches the changes of them and updates the addresses in the ArmorProfile objects, so the
+// agents reprogram the network rules of the containers.
+type NetworkPeerResolver struct {
+	varmorInterface       varmorinterface.CrdV1beta1Interface
+	serviceInformer       coreinformers.ServiceInformer
+	endpointSliceInformer discoveryinformers.EndpointSliceInformer
+	podInformer           coreinformers.PodInformer
+	apInformer            varmorinformer.ArmorProfileInformer
+	serviceLister         corelisters.ServiceLister
+	endpointSliceLister   discoverylisters.EndpointSliceLister
+	podLister             corelisters.PodLister
+	apLister              varmorlister.ArmorProfileLister
+	synced                []cache.InformerSynced
+	changed               chan struct{}
+	log                   logr.Logger
+}
+
+// NewNetworkPeerResolver create a NetworkPeerResolver
+func NewNetworkPeerResolver(
+	varmorInterface varmorinterface.CrdV1beta1Interface,
+	serviceInformer coreinformers.ServiceInformer,
+	endpointSliceInformer discoveryinformers.EndpointSliceInformer,
+	podInformer coreinformers.PodInformer,
+	apInformer varmorinformer.ArmorProfileInformer,
+	log logr.Logger) *NetworkPeerResolver {
+
+	return &NetworkPeerResolver{
+		varmorInterface:       varmorInterface,
+		serviceInformer:       serviceInformer,
+		endpointSliceInformer: endpointSliceInformer,
+		podInformer:           podInformer,
+		apInformer:            apInformer,
+		serviceLister:         serviceInformer.Lister(),
+		endpointSliceLister:   endpointSliceInformer.Lister(),
+		podLister:             podInformer.Lister(),
+		apLister:              apInformer.Lister(),
+		synced: []cache.InformerSynced{
+			serviceInformer.Informer().HasSynced,
+			endpointSliceInformer.Informer().HasSynced,
+			podInformer.Informer().HasSynced,
+			apInformer.Informer().HasSynced,
+		},
+		changed: make(chan struct{}, 1),
+		log:     log,
+	}
+}
+
+// notify triggers the resolution, the notifications are merged until the resolution starts
+func (r *NetworkPeerResolver) notify() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (r *NetworkPeerResolver) eventHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { r.notify() },
+		UpdateFunc: func(interface{}, interface{}) { r.notify() },
+		DeleteFunc: func(interface{}) { r.notify() },
+	}
+}
+
+// apEventHandler only notifies the changes of the ArmorProfile objects that reference Services or Pods
+func (r *NetworkPeerResolver) apEventHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if hasNetworkPeers(obj.(*varmor.ArmorProfile)) {
+				r.notify()
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if hasNetworkPeers(newObj.(*varmor.ArmorProfile)) {
+				r.notify()
+			}
+		},
+	}
+}
+
+func hasNetworkPeers(ap *varmor.ArmorProfile) bool {
+	return ap.Spec.Profile.BpfContent != nil && len(ap.Spec.Profile.BpfContent.NetworkPeers) != 0
+}
+
+// addressSet returns the sorted addresses of the set
+func addressSet(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	addresses := make([]string, 0, len(set))
+	for address := range set {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// serviceAddresses returns the cluster IPs of the Service and the IPs of its ready endpoints. The endpoints are
+// included since the clients may connect to them directly, e.g. with a headless Service.
+func serviceAddresses(service *corev1.Service, endpointSlices []*discoveryv1.EndpointSlice) []string {
+	set := make(map[string]bool)
+	for _, ip := range service.Spec.ClusterIPs {
+		if ip != "" && ip != corev1.ClusterIPNone {
+			set[ip] = true
+		}
+	}
+	for _, endpointSlice := range endpointSlices {
+		if endpointSlice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+		for _, endpoint := range endpointSlice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				set[address] = true
+			}
+		}
+	}
+	return addressSet(set)
+}
+
+// podAddresses returns the IPs of the running Pods, the Pods in the host network are skipped since their IPs are
+// the ones of the nodes
+func podAddresses(pods []*corev1.Pod) []string {
+	set := make(map[string]bool)
+	for _, pod := range pods {
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			set[podIP.IP] = true
+		}
+		if pod.Status.PodIP != "" {
+			set[pod.Status.PodIP] = true
+		}
+	}
+	return addressSet(set)
+}
+
+// peerAddresses resolves the addresses of the network peer with the informer caches
+func (r *NetworkPeerResolver) peerAddresses(peer *varmor.NetworkPeerContent) ([]string, error) {
+	if peer.ServiceName != "" {
+		service, err := r.serviceLister.Services(peer.Namespace).Get(peer.ServiceName)
+		if k8errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: peer.ServiceName})
+		endpointSlices, err := r.endpointSliceLister.EndpointSlices(peer.Namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return serviceAddresses(service, endpointSlices), nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := r.podLister.Pods(peer.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return podAddresses(pods), nil
+}
+
+// resolveNetworkPeers updates the addresses of the network peers in the BPF content, and returns whether any of
+// them changed
+func (r *NetworkPeerResolver) resolveNetworkPeers(bpfContent *varmor.BpfContent) (bool, error) {
+	changed := false
+	for i := range bpfContent.NetworkPeers {
+		peer := &bpfContent.NetworkPeers[i]
+		addresses, err := r.peerAddresses(peer)
+		if err != nil {
+			return false, err
+		}
+		if !reflect.DeepEqual(peer.Addresses, addresses) {
+			peer.Addresses = addresses
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// updateArmorProfile updates the addresses of the network peers in the ArmorProfile object
+func (r *NetworkPeerResolver) updateArmorProfile(namespace, name string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry,
+		func() error {
+			ap, err := r.varmorInterface.ArmorProfiles(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if !hasNetworkPeers(ap) {
+				return nil
+			}
+
+			changed, err := r.resolveNetworkPeers(ap.Spec.Profile.BpfContent)
+			if err != nil || !changed {
+				return err
+			}
+
+			r.log.Info("update the addresses of the network peers", "namespace", namespace, "name", name)
+			_, err = r.varmorInterface.ArmorProfiles(namespace).Update(context.Background(), ap, metav1.UpdateOptions{})
+			return err
+		})
+}
+
+func (r *NetworkPeerResolver) resolve() {
+	aps, err := r.apLister.List(labels.Everything())
+	if err != nil {
+		r.log.Error(err, "apLister.List()")
+		return
+	}
+
+	for _, ap := range aps {
+		if !hasNetworkPeers(ap) {
+			continue
+		}
+
+		// Check the cached object first to avoid the unnecessary requests
+		bpfContent := ap.Spec.Profile.BpfContent.DeepCopy()
+		changed, err := r.resolveNetworkPeers(bpfContent)
+		if err != nil {
+			r.log.Error(err, "failed to resolve the network peers", "namespace", ap.Namespace, "name", ap.Name)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		err = r.updateArmorProfile(ap.Namespace, ap.Name)
+		if err != nil && !k8errors.IsNotFound(err) {
+			r.log.Error(err, "failed to update the addresses of the network peers", "namespace", ap.Namespace, "name", ap.Name)
+		}
+	}
+}
+
+// Run resolves the network peers when the Services, EndpointSlices, Pods or ArmorProfile objects change, and
+// resolves all of them periodically
+func (r *NetworkPeerResolver) Run(stopCh <-chan struct{}) {
+	r.log.Info("starting")
+
+	defer utilruntime.HandleCrash()
+
+	if !cache.WaitForCacheSync(stopCh, r.synced...) {
+		r.log.Error(fmt.Errorf("failed to sync informer cache"), "cache.WaitForCacheSync()")
+		return
+	}
+
+	r.serviceInformer.Informer().AddEventHandler(r.eventHandler())
+	r.endpointSliceInformer.Informer().AddEventHandler(r.eventHandler())
+	r.podInformer.Informer().AddEventHandler(r.eventHandler())
+	r.apInformer.Informer().AddEventHandler(r.apEventHandler())
+
+	ticker := time.NewTicker(varmorconfig.NetworkPeerResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.changed:
+			// Wait for a while to batch the changes, e.g. the rolling update of a Deployment
+			select {
+			case <-time.After(networkPeerResolveDelay):
+			case <-stopCh:
+				return
+			}
+			r.resolve()
+		case <-ticker.C:
+			r.resolve()
+		case <-stopCh:
+			return
+		}
+	}
+}
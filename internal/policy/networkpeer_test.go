@@ -0,0 +1,56 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+func Test_serviceAddresses(t *testing.T) {
+	ready, notReady := true, false
+	service := &corev1.Service{Spec: corev1.ServiceSpec{ClusterIPs: []string{"10.96.0.10", "fd00::10"}}}
+	endpointSlices := []*discoveryv1.EndpointSlice{
+		{
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"172.16.0.5"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+				{Addresses: []string{"172.16.0.6"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+				{Addresses: []string{"172.16.0.7"}},
+			},
+		},
+		{
+			AddressType: discoveryv1.AddressTypeFQDN,
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"db.example.com"}}},
+		},
+	}
+	assert.DeepEqual(t, serviceAddresses(service, endpointSlices), []string{"10.96.0.10", "172.16.0.5", "172.16.0.7", "fd00::10"})
+
+	headless := &corev1.Service{Spec: corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone, ClusterIPs: []string{corev1.ClusterIPNone}}}
+	assert.Assert(t, serviceAddresses(headless, nil) == nil)
+}
+
+func Test_podAddresses(t *testing.T) {
+	pods := []*corev1.Pod{
+		{Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "172.16.0.5", PodIPs: []corev1.PodIP{{IP: "172.16.0.5"}, {IP: "fd00::5"}}}},
+		{Status: corev1.PodStatus{Phase: corev1.PodPending, PodIP: "172.16.0.6"}},
+		{Status: corev1.PodStatus{Phase: corev1.PodSucceeded, PodIP: "172.16.0.7"}},
+		{Spec: corev1.PodSpec{HostNetwork: true}, Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "192.168.0.2"}},
+	}
+	assert.DeepEqual(t, podAddresses(pods), []string{"172.16.0.5", "172.16.0.6", "fd00::5"})
+}
@@ -21,12 +21,14 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
 	apicorev1 "k8s.io/api/core/v1"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	appsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+	batchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/util/retry"
 
@@ -38,6 +40,7 @@ import (
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
 	varmorprofile "github.com/bytedance/vArmor/internal/profile"
 	statusmanager "github.com/bytedance/vArmor/internal/status/api/v1"
+	varmortracing "github.com/bytedance/vArmor/internal/tracing"
 	varmortypes "github.com/bytedance/vArmor/internal/types"
 	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned/typed/varmor/v1beta1"
 	varmorinformer "github.com/bytedance/vArmor/pkg/client/informers/externalversions/varmor/v1beta1"
@@ -52,6 +55,7 @@ const (
 type PolicyController struct {
 	podInterface           corev1.PodInterface
 	appsInterface          appsv1.AppsV1Interface
+	batchInterface         batchv1.BatchV1Interface
 	varmorInterface        varmorinterface.CrdV1beta1Interface
 	vpInformer             varmorinformer.VarmorPolicyInformer
 	vpLister               varmorlister.VarmorPolicyLister
@@ -69,6 +73,7 @@ type PolicyController struct {
 func NewPolicyController(
 	podInterface corev1.PodInterface,
 	appsInterface appsv1.AppsV1Interface,
+	batchInterface batchv1.BatchV1Interface,
 	varmorInterface varmorinterface.CrdV1beta1Interface,
 	vpInformer varmorinformer.VarmorPolicyInformer,
 	statusManager *statusmanager.StatusManager,
@@ -81,6 +86,7 @@ func NewPolicyController(
 	c := PolicyController{
 		podInterface:           podInterface,
 		appsInterface:          appsInterface,
+		batchInterface:         batchInterface,
 		varmorInterface:        varmorInterface,
 		vpInformer:             vpInformer,
 		vpLister:               vpInformer.Lister(),
@@ -169,10 +175,11 @@ func (c *PolicyController) handleDeleteVarmorPolicy(namespace, name string) erro
 		logger.Info("delete annotations of target workloads to trigger a rolling upgrade asynchronously")
 		go updateWorkloadAnnotationsAndEnv(
 			c.appsInterface,
+			c.batchInterface,
 			namespace,
 			ap.Spec.Profile.Enforcer,
 			ap.Spec.Target,
-			"", false, logger)
+			"", false, false, logger)
 	}
 
 	// Cleanup the PolicyStatus and ModelingStatus of status manager for the deleted VarmorPolicy/ArmorProfile object
@@ -235,7 +242,10 @@ func (c *PolicyController) updateVarmorPolicyStatus(
 }
 
 func (c *PolicyController) ignoreAdd(vp *varmor.VarmorPolicy, logger logr.Logger) bool {
-	if vp.Spec.Target.Kind != "Deployment" && vp.Spec.Target.Kind != "StatefulSet" && vp.Spec.Target.Kind != "DaemonSet" && vp.Spec.Target.Kind != "Pod" {
+	// The custom owner kinds are specified with the APIVersion field
+	if vp.Spec.Target.APIVersion == "" &&
+		vp.Spec.Target.Kind != "Deployment" && vp.Spec.Target.Kind != "StatefulSet" && vp.Spec.Target.Kind != "DaemonSet" &&
+		vp.Spec.Target.Kind != "Job" && vp.Spec.Target.Kind != "CronJob" && vp.Spec.Target.Kind != "Pod" {
 		err := fmt.Errorf("Target.Kind is not supported")
 		logger.Error(err, "update VarmorPolicy/status with forbidden info")
 		err = c.updateVarmorPolicyStatus(vp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
@@ -247,12 +257,12 @@ func (c *PolicyController) ignoreAdd(vp *varmor.VarmorPolicy, logger logr.Logger
 		return true
 	}
 
-	if vp.Spec.Target.Name == "" && vp.Spec.Target.Selector == nil {
-		err := fmt.Errorf("target.Name and target.Selector are empty")
+	if vp.Spec.Target.Name == "" && vp.Spec.Target.Selector == nil && len(vp.Spec.Target.ServiceAccounts) == 0 {
+		err := fmt.Errorf("target.Name, target.Selector and target.ServiceAccounts are empty")
 		logger.Error(err, "update VarmorPolicy/status with forbidden info")
 		err = c.updateVarmorPolicyStatus(vp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
 			"Forbidden",
-			"You should specify the target workload by name or selector.")
+			"You should specify the target workload by name, selector or service accounts.")
 		if err != nil {
 			logger.Error(err, "updateVarmorPolicyStatus()")
 		}
@@ -320,7 +330,7 @@ func resetArmorProfileModelStatus(varmorInterface varmorinterface.CrdV1beta1Inte
 		})
 }
 
-func (c *PolicyController) handleAddVarmorPolicy(vp *varmor.VarmorPolicy) error {
+func (c *PolicyController) handleAddVarmorPolicy(ctx context.Context, vp *varmor.VarmorPolicy) error {
 	logger := c.log.WithName("handleAddVarmorPolicy()")
 
 	logger.Info("VarmorPolicy created", "namespace", vp.Namespace, "name", vp.Name, "labels", vp.Labels, "target", vp.Spec.Target)
@@ -359,22 +369,31 @@ func (c *PolicyController) handleAddVarmorPolicy(vp *varmor.VarmorPolicy) error
 	c.statusManager.UpdateDesiredNumber = true
 
 	logger.Info("create ArmorProfile")
-	ap, err = c.varmorInterface.ArmorProfiles(vp.Namespace).Create(context.Background(), ap, metav1.CreateOptions{})
+	varmortracing.InjectIntoObject(ctx, ap)
+	ap, err = c.varmorInterface.ArmorProfiles(vp.Namespace).Create(ctx, ap, metav1.CreateOptions{})
 	if err != nil {
 		logger.Error(err, "ArmorProfile().Create()")
 		return err
 	}
 
+	err = varmorprofile.RecordProfileHistory(c.varmorInterface, ap.Namespace, ap.Name)
+	if err != nil {
+		logger.Error(err, "RecordProfileHistory()")
+	}
+	c.statusManager.NotifyLifecycleHooks(vp.Spec.Policy.LifecycleHooks, statusmanager.NewLifecycleEvent(varmortypes.PreEnforceEvent, vp.Namespace, vp.Name, ap))
+
 	if c.restartExistWorkloads && vp.Spec.UpdateExistingWorkloads {
 		// This will trigger the rolling upgrade of the target workload.
 		logger.Info("add annotations to target workloads to trigger a rolling upgrade asynchronously")
 		go updateWorkloadAnnotationsAndEnv(
 			c.appsInterface,
+			c.batchInterface,
 			vp.Namespace,
 			vp.Spec.Policy.Enforcer,
 			vp.Spec.Target,
 			ap.Name,
 			c.bpfExclusiveMode,
+			vp.Spec.Policy.ConfineSandboxContainers,
 			logger)
 	}
 
@@ -428,8 +447,9 @@ func (c *PolicyController) ignoreUpdate(newVp *varmor.VarmorPolicy, oldAp *varmo
 	// Nothing need to be updated if VarmorPolicy is in the modeling phase and its duration is not changed.
 	if newVp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode &&
 		newVp.Status.Phase == varmortypes.VarmorPolicyModeling &&
-		newVp.Spec.Policy.ModelingOptions.Duration == oldAp.Spec.BehaviorModeling.Duration {
-		logger.Info("nothing need to be updated (duration is not changed)")
+		newVp.Spec.Policy.ModelingOptions.Duration == oldAp.Spec.BehaviorModeling.Duration &&
+		newVp.Spec.Policy.ModelingOptions.PathGeneralization == oldAp.Spec.BehaviorModeling.PathGeneralization {
+		logger.Info("nothing need to be updated (modeling options are not changed)")
 		return true, nil
 	}
 
@@ -446,7 +466,7 @@ func (c *PolicyController) ignoreUpdate(newVp *varmor.VarmorPolicy, oldAp *varmo
 	return false, nil
 }
 
-func (c *PolicyController) handleUpdateVarmorPolicy(newVp *varmor.VarmorPolicy, oldAp *varmor.ArmorProfile) error {
+func (c *PolicyController) handleUpdateVarmorPolicy(ctx context.Context, newVp *varmor.VarmorPolicy, oldAp *varmor.ArmorProfile) error {
 	logger := c.log.WithName("handleUpdateVarmorPolicy()")
 
 	logger.Info("VarmorPolicy updated", "namespace", newVp.Namespace, "name", newVp.Name, "labels", newVp.Labels, "target", newVp.Spec.Target)
@@ -480,10 +500,40 @@ func (c *PolicyController) handleUpdateVarmorPolicy(newVp *varmor.VarmorPolicy,
 		}
 		return nil
 	}
+	varmorprofile.InheritNetworkPeerAddresses(newProfile, &oldAp.Spec)
 	newApSpec.Profile = *newProfile
 	newApSpec.UpdateExistingWorkloads = newVp.Spec.UpdateExistingWorkloads
+	newApSpec.NodeSelector = newVp.Spec.NodeSelector
+
+	newDriftDetection, err := varmorprofile.GenerateDriftDetection(newVp.Spec.Policy.DriftDetectionOptions, oldAp.Name, oldAp.Namespace, c.varmorInterface)
+	if err != nil {
+		logger.Error(err, "GenerateDriftDetection() failed")
+		err = c.updateVarmorPolicyStatus(newVp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
+			"Error",
+			err.Error())
+		if err != nil {
+			logger.Error(err, "updateVarmorPolicyStatus()")
+			return err
+		}
+		return nil
+	}
+	newApSpec.DriftDetection = *newDriftDetection
+	newApSpec.FileIntegrity = *varmorprofile.GenerateFileIntegrity(newVp.Spec.Policy)
 	if newVp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode {
-		newApSpec.BehaviorModeling.Duration = newVp.Spec.Policy.ModelingOptions.Duration
+		newBehaviorModeling, err := varmorprofile.GenerateBehaviorModeling(newVp.Spec.Policy.ModelingOptions)
+		if err != nil {
+			logger.Error(err, "GenerateBehaviorModeling() failed")
+			err = c.updateVarmorPolicyStatus(newVp, "", true, varmortypes.VarmorPolicyError, varmortypes.VarmorPolicyCreated, apicorev1.ConditionFalse,
+				"Error",
+				err.Error())
+			if err != nil {
+				logger.Error(err, "updateVarmorPolicyStatus()")
+				return err
+			}
+			return nil
+		}
+		newApSpec.BehaviorModeling.Duration = newBehaviorModeling.Duration
+		newApSpec.BehaviorModeling.PathGeneralization = newBehaviorModeling.PathGeneralization
 	}
 
 	// Last, do update
@@ -513,12 +563,27 @@ func (c *PolicyController) handleUpdateVarmorPolicy(newVp *varmor.VarmorPolicy,
 		c.statusManager.ResetCh <- statusKey
 
 		logger.Info("2.3. update ArmorProfile")
+		previousMode := oldAp.Spec.Profile.Mode
 		oldAp.Spec = *newApSpec
-		_, err = c.varmorInterface.ArmorProfiles(newVp.Namespace).Update(context.Background(), oldAp, metav1.UpdateOptions{})
+		varmortracing.InjectIntoObject(ctx, oldAp)
+		ap, err := c.varmorInterface.ArmorProfiles(newVp.Namespace).Update(ctx, oldAp, metav1.UpdateOptions{})
 		if err != nil {
 			logger.Error(err, "ArmorProfile().Update()")
 			return err
 		}
+
+		err = varmorprofile.RecordProfileHistory(c.varmorInterface, ap.Namespace, ap.Name)
+		if err != nil {
+			logger.Error(err, "RecordProfileHistory()")
+		}
+
+		hooks := newVp.Spec.Policy.LifecycleHooks
+		c.statusManager.NotifyLifecycleHooks(hooks, statusmanager.NewLifecycleEvent(varmortypes.PreEnforceEvent, newVp.Namespace, newVp.Name, ap))
+		if ap.Spec.Profile.Mode != previousMode {
+			event := statusmanager.NewLifecycleEvent(varmortypes.ModeChangedEvent, newVp.Namespace, newVp.Name, ap)
+			event.PreviousMode = previousMode
+			c.statusManager.NotifyLifecycleHooks(hooks, event)
+		}
 	} else {
 		// Update status
 		logger.Info("2. update the object' status")
@@ -529,9 +594,12 @@ func (c *PolicyController) handleUpdateVarmorPolicy(newVp *varmor.VarmorPolicy,
 	return nil
 }
 
-func (c *PolicyController) syncPolicy(key string) error {
+func (c *PolicyController) syncPolicy(key string) (err error) {
 	logger := c.log.WithName("syncPolicy()")
 
+	ctx, span := varmortracing.StartSpan(context.Background(), "PolicyController.syncPolicy", attribute.String("key", key))
+	defer func() { varmortracing.EndSpan(span, err) }()
+
 	startTime := time.Now()
 	logger.V(3).Info("started syncing policy", "key", key, "startTime", startTime)
 	defer func() {
@@ -562,7 +630,7 @@ func (c *PolicyController) syncPolicy(key string) error {
 		if k8errors.IsNotFound(err) {
 			// VarmorPolicy create event
 			logger.V(3).Info("processing VarmorPolicy create event")
-			return c.handleAddVarmorPolicy(vp)
+			return c.handleAddVarmorPolicy(ctx, vp)
 		} else {
 			logger.Error(err, "c.varmorInterface.ArmorProfiles().Get()")
 			return err
@@ -570,7 +638,7 @@ func (c *PolicyController) syncPolicy(key string) error {
 	} else {
 		// VarmorPolicy update event
 		logger.V(3).Info("processing VarmorPolicy update event")
-		return c.handleUpdateVarmorPolicy(vp, ap)
+		return c.handleUpdateVarmorPolicy(ctx, vp, ap)
 	}
 }
 
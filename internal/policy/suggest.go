@@ -0,0 +1,237 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/util/retry"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmorprofile "github.com/bytedance/vArmor/internal/profile"
+	bpfprofile "github.com/bytedance/vArmor/internal/profile/bpf"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned/typed/varmor/v1beta1"
+	bpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+)
+
+// ProfileSuggester suggests how to tighten the BPF profiles of the policies with the hit counters of their rules
+// periodically, and applies the suggestions approved by the operators.
+type ProfileSuggester struct {
+	varmorInterface varmorinterface.CrdV1beta1Interface
+	log             logr.Logger
+}
+
+// NewProfileSuggester create a ProfileSuggester
+func NewProfileSuggester(varmorInterface varmorinterface.CrdV1beta1Interface, log logr.Logger) *ProfileSuggester {
+	return &ProfileSuggester{
+		varmorInterface: varmorInterface,
+		log:             log,
+	}
+}
+
+// observedSince returns the last time when the policy was created or updated, the rules of the profile are
+// observed since then
+func observedSince(conditions []varmor.VarmorPolicyCondition) time.Time {
+	var since time.Time
+	for _, c := range conditions {
+		if c.Type != varmortypes.VarmorPolicyCreated && c.Type != varmortypes.VarmorPolicyUpdated {
+			continue
+		}
+		if c.LastTransitionTime.Time.After(since) {
+			since = c.LastTransitionTime.Time
+		}
+	}
+	return since
+}
+
+// ruleHits returns the hit counters of the rules with the violation records updated since the time. The records
+// aren't reset when the policy is updated, so the counters may include the hits before it.
+func ruleHits(records []varmor.ViolationRecord, since time.Time) map[string]bpfprofile.RuleHits {
+	hits := make(map[string]bpfprofile.RuleHits)
+	for _, record := range records {
+		if record.RuleID == "" || record.LastTimestamp.Time.Before(since) {
+			continue
+		}
+		h := hits[record.RuleID]
+		h.Hits += record.Count
+		hits[record.RuleID] = h
+	}
+	return hits
+}
+
+// suggest generates the tightening suggestion of the BPF profile once the policy has been observed for long enough.
+// It returns nil if there is nothing to suggest.
+func (s *ProfileSuggester) suggest(status *varmor.VarmorPolicyStatus, ap *varmor.ArmorProfile, now time.Time) (*varmor.TighteningSuggestion, error) {
+	since := observedSince(status.Conditions)
+	if since.IsZero() || now.Sub(since) < varmorconfig.TighteningObservationPeriod {
+		return nil, nil
+	}
+
+	var records []varmor.ViolationRecord
+	vv, err := s.varmorInterface.VarmorViolations(ap.Namespace).Get(context.Background(), ap.Name, metav1.GetOptions{})
+	if err == nil {
+		records = vv.Records
+	} else if !k8errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	suggestion := bpfprofile.SuggestTightening(ap.Spec.Profile.BpfContent, ruleHits(records, since))
+	if suggestion == nil {
+		return nil, nil
+	}
+	suggestion.ProfileGeneration = ap.Generation
+	suggestion.GeneratedTime = metav1.NewTime(now)
+	return suggestion, nil
+}
+
+// applySuggestion replaces the BPF profile of the ArmorProfile object with the suggested one. It fails with the
+// conflict error if the ArmorProfile object was changed after the suggestion was generated.
+func (s *ProfileSuggester) applySuggestion(ap *varmor.ArmorProfile, suggestion *varmor.TighteningSuggestion) error {
+	ap.Spec.Profile.BpfContent = suggestion.BpfContent.DeepCopy()
+	_, err := s.varmorInterface.ArmorProfiles(ap.Namespace).Update(context.Background(), ap, metav1.UpdateOptions{})
+	return err
+}
+
+// updateSuggestion saves the suggestion into the status of the policy
+func (s *ProfileSuggester) updateSuggestion(namespace, name string, clusterScope bool, suggestion *varmor.TighteningSuggestion) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if clusterScope {
+			vcp, err := s.varmorInterface.VarmorClusterPolicies().Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			vcp.Status.Suggestion = suggestion
+			_, err = s.varmorInterface.VarmorClusterPolicies().UpdateStatus(context.Background(), vcp, metav1.UpdateOptions{})
+			return err
+		}
+
+		vp, err := s.varmorInterface.VarmorPolicies(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		vp.Status.Suggestion = suggestion
+		_, err = s.varmorInterface.VarmorPolicies(namespace).UpdateStatus(context.Background(), vp, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// sync applies the suggestion of the policy if it was approved, or generates the suggestion again
+func (s *ProfileSuggester) sync(namespace, name string, clusterScope bool, annotations map[string]string, status *varmor.VarmorPolicyStatus, now time.Time) error {
+	apNamespace := namespace
+	if clusterScope {
+		apNamespace = varmorconfig.Namespace
+	}
+	apName := varmorprofile.GenerateArmorProfileName(namespace, name, clusterScope)
+
+	ap, err := s.varmorInterface.ArmorProfiles(apNamespace).Get(context.Background(), apName, metav1.GetOptions{})
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	var suggestion *varmor.TighteningSuggestion
+	current := status.Suggestion
+	if current != nil && current.BpfContent != nil && current.ProfileGeneration == ap.Generation &&
+		annotations[varmortypes.ApproveSuggestionAnnotation] == current.ID {
+		err = s.applySuggestion(ap, current)
+		if err != nil {
+			return err
+		}
+		s.log.Info("the tightening suggestion was approved and applied", "namespace", namespace, "name", name,
+			"id", current.ID, "removals", len(current.Removals))
+	} else if ap.Spec.Profile.BpfContent != nil {
+		suggestion, err = s.suggest(status, ap, now)
+		if err != nil {
+			return err
+		}
+		// Only update the status when the suggested profile changes
+		if suggestion != nil && current != nil &&
+			suggestion.ID == current.ID && suggestion.ProfileGeneration == current.ProfileGeneration {
+			return nil
+		}
+	}
+
+	if suggestion == nil && current == nil {
+		return nil
+	}
+	return s.updateSuggestion(namespace, name, clusterScope, suggestion)
+}
+
+func (s *ProfileSuggester) check() {
+	now := time.Now()
+
+	vpList, err := s.varmorInterface.VarmorPolicies(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		s.log.Error(err, "VarmorPolicies().List()")
+	} else {
+		for _, vp := range vpList.Items {
+			err = s.sync(vp.Namespace, vp.Name, false, vp.Annotations, &vp.Status, now)
+			if err != nil {
+				s.log.Error(err, "failed to suggest tightening the profile", "namespace", vp.Namespace, "name", vp.Name)
+			}
+		}
+	}
+
+	vcpList, err := s.varmorInterface.VarmorClusterPolicies().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		s.log.Error(err, "VarmorClusterPolicies().List()")
+		return
+	}
+	for _, vcp := range vcpList.Items {
+		err = s.sync(varmorconfig.Namespace, vcp.Name, true, vcp.Annotations, &vcp.Status, now)
+		if err != nil {
+			s.log.Error(err, "failed to suggest tightening the profile", "name", vcp.Name)
+		}
+	}
+}
+
+// Run suggests tightening the BPF profiles of the policies periodically. It does nothing if the BPF program doesn't
+// report the violation events, since the rules would never fire and all of them would be suggested to be removed.
+func (s *ProfileSuggester) Run(stopCh <-chan struct{}) {
+	s.log.Info("starting")
+
+	defer utilruntime.HandleCrash()
+
+	features, err := bpfenforcer.ProgramFeatures("")
+	if err != nil {
+		s.log.Error(err, "failed to inspect the BPF program, stop suggesting")
+		return
+	}
+	if !features[bpfenforcer.FeatureViolationEvents] {
+		s.log.Info("the violation events are not supported by the BPF program of vArmor, stop suggesting")
+		return
+	}
+
+	ticker := time.NewTicker(varmorconfig.TighteningSuggestionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.check()
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	bpfprofile "github.com/bytedance/vArmor/internal/profile/bpf"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_observedSince(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	assert.Assert(t, observedSince(nil).IsZero())
+	assert.Equal(t, observedSince([]varmor.VarmorPolicyCondition{
+		{Type: varmortypes.VarmorPolicyCreated, LastTransitionTime: metav1.NewTime(created)},
+		{Type: varmortypes.VarmorPolicyUpdated, LastTransitionTime: metav1.NewTime(updated)},
+	}), updated)
+}
+
+func Test_ruleHits(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []varmor.ViolationRecord{
+		{RuleID: "bpfRawRules.files/0", Workload: "Deployment/a", Count: 3, LastTimestamp: metav1.NewTime(since.Add(time.Minute))},
+		{RuleID: "bpfRawRules.files/0", Workload: "Deployment/b", Count: 5, LastTimestamp: metav1.NewTime(since.Add(time.Hour))},
+		// The records which weren't updated since the policy changed are ignored
+		{RuleID: "bpfRawRules.files/1", Count: 7, LastTimestamp: metav1.NewTime(since.Add(-time.Minute))},
+		{RuleType: "file", Count: 1, LastTimestamp: metav1.NewTime(since.Add(time.Minute))},
+	}
+
+	assert.DeepEqual(t, ruleHits(records, since), map[string]bpfprofile.RuleHits{
+		"bpfRawRules.files/0": {Hits: 8},
+	})
+}
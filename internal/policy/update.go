@@ -23,294 +23,246 @@ import (
 
 	"github.com/go-logr/logr"
 
-	appsV1 "k8s.io/api/apps/v1"
 	coreV1 "k8s.io/api/core/v1"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	appsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+	batchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
 	"k8s.io/client-go/util/retry"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
 	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	selinuxprofile "github.com/bytedance/vArmor/internal/profile/selinux"
 	varmortypes "github.com/bytedance/vArmor/internal/types"
 	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
 )
 
-func modifyDeploymentAnnotationsAndEnv(enforcer string, target varmor.Target, deploy *appsV1.Deployment, profileName string, bpfExclusiveMode bool) {
+// validateHostProcessTarget checks whether the policy with the HostProcess target can be enforced
+func validateHostProcessTarget(target varmor.Target, policy varmor.Policy) error {
+	if target.HostProcess == nil || (len(target.HostProcess.Executables) == 0 && len(target.HostProcess.SystemdUnits) == 0) {
+		return fmt.Errorf("you should specify the host processes by executables or systemd units")
+	}
+	if target.Name != "" || target.Selector != nil || len(target.Containers) != 0 || len(target.ServiceAccounts) != 0 {
+		return fmt.Errorf("the name, selector, containers and service accounts of the target are not supported by the HostProcess kind")
+	}
+	if varmortypes.GetEnforcerType(policy.Enforcer) != varmortypes.BPF {
+		return fmt.Errorf("the HostProcess target is only supported by the BPF enforcer")
+	}
+	if policy.Mode == varmortypes.BehaviorModelingMode {
+		return fmt.Errorf("the HostProcess target does not support the BehaviorModeling mode")
+	}
+	return nil
+}
+
+// modifyPodTemplateAnnotationsAndEnv cleans up the settings of vArmor in the pod template of the workload,
+// and then sets the new ones with the profile. Only the clean up is performed if the profileName is empty.
+func modifyPodTemplateAnnotationsAndEnv(enforcer string, target varmor.Target, template *coreV1.PodTemplateSpec, profileName string, bpfExclusiveMode bool, confineSandbox bool) {
 	e := varmortypes.GetEnforcerType(enforcer)
 
 	// Clean up the annotations
-	for key, value := range deploy.Spec.Template.Annotations {
+	for key, value := range template.Annotations {
 		// BPF, BPFSeccomp
 		if (e & varmortypes.BPF) != 0 {
-			if strings.HasPrefix(key, "container.bpf.security.beta.varmor.org/") && value != "unconfined" {
-				delete(deploy.Spec.Template.Annotations, key)
+			if (strings.HasPrefix(key, "container.bpf.security.beta.varmor.org/") || key == pkgtypes.SandboxBpfAnnotation) && value != "unconfined" {
+				delete(template.Annotations, key)
 			}
 		}
 		// AppArmor, AppArmorSeccomp
 		if (e & varmortypes.AppArmor) != 0 {
 			if strings.HasPrefix(key, "container.apparmor.security.beta.kubernetes.io/") && value != "unconfined" {
-				delete(deploy.Spec.Template.Annotations, key)
+				delete(template.Annotations, key)
 			}
 		}
 		// Seccomp, BPFSeccomp, AppArmorSeccomp
 		if (e & varmortypes.Seccomp) != 0 {
 			if strings.HasPrefix(key, "container.seccomp.security.beta.varmor.org/") && value != "unconfined" {
-				delete(deploy.Spec.Template.Annotations, key)
+				delete(template.Annotations, key)
+			}
+		}
+		// Landlock, LandlockSeccomp
+		if (e & varmortypes.Landlock) != 0 {
+			if strings.HasPrefix(key, "container.landlock.security.beta.varmor.org/") && value != "unconfined" {
+				delete(template.Annotations, key)
+			}
+		}
+		// SELinux, SELinuxSeccomp
+		if (e & varmortypes.SELinux) != 0 {
+			if strings.HasPrefix(key, "container.selinux.security.beta.varmor.org/") && value != "unconfined" {
+				delete(template.Annotations, key)
 			}
 		}
 	}
 
 	// Clean up the seccomp settings
-	for index, container := range deploy.Spec.Template.Spec.Containers {
+	for index, container := range template.Spec.Containers {
 		if container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil &&
 			strings.HasPrefix(*container.SecurityContext.SeccompProfile.LocalhostProfile, "varmor-") {
-			deploy.Spec.Template.Spec.Containers[index].SecurityContext.SeccompProfile = nil
+			template.Spec.Containers[index].SecurityContext.SeccompProfile = nil
 		}
 	}
 
-	// Add the modification time to annotation
-	if deploy.Spec.Template.Annotations == nil {
-		deploy.Spec.Template.Annotations = make(map[string]string)
-	}
-
-	if profileName == "" {
-		return
-	}
-
-	// Setting new annotations and seccomp context
-	for index, container := range deploy.Spec.Template.Spec.Containers {
-		if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
-			continue
-		}
-
-		// BPF, BPFSeccomp
-		if (e & varmortypes.BPF) != 0 {
-			key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
-			if value, ok := deploy.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-				continue
-			}
-			deploy.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
-
-			if bpfExclusiveMode {
-				key = fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-				deploy.Spec.Template.Annotations[key] = "unconfined"
-			}
-		}
-		// AppArmor, AppArmorSeccomp
-		if (e & varmortypes.AppArmor) != 0 {
-			key := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-			if value, ok := deploy.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-				continue
-			}
-			deploy.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
-		}
-		// Seccomp, BPFSeccomp, AppArmorSeccomp
-		if (e & varmortypes.Seccomp) != 0 {
-			if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
-				(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
-				(deploy.Spec.Template.Spec.SecurityContext != nil && deploy.Spec.Template.Spec.SecurityContext.SeccompProfile != nil) {
-				continue
-			}
-			key := fmt.Sprintf("container.seccomp.security.beta.varmor.org/%s", container.Name)
-			deploy.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
-			if deploy.Spec.Template.Spec.Containers[index].SecurityContext == nil {
-				deploy.Spec.Template.Spec.Containers[index].SecurityContext = &coreV1.SecurityContext{}
-			}
-			deploy.Spec.Template.Spec.Containers[index].SecurityContext.SeccompProfile = &coreV1.SeccompProfile{
-				Type:             "Localhost",
-				LocalhostProfile: &profileName,
-			}
+	// Clean up the SELinux settings
+	for index, container := range template.Spec.Containers {
+		if container.SecurityContext != nil && container.SecurityContext.SELinuxOptions != nil &&
+			strings.HasPrefix(container.SecurityContext.SELinuxOptions.Type, "varmor_") {
+			template.Spec.Containers[index].SecurityContext.SELinuxOptions = nil
 		}
 	}
-}
-
-func modifyStatefulSetAnnotationsAndEnv(enforcer string, target varmor.Target, stateful *appsV1.StatefulSet, profileName string, bpfExclusiveMode bool) {
-	e := varmortypes.GetEnforcerType(enforcer)
 
-	// Clean up the annotations
-	for key, value := range stateful.Spec.Template.Annotations {
-		// BPF, BPFSeccomp
-		if (e & varmortypes.BPF) != 0 {
-			if strings.HasPrefix(key, "container.bpf.security.beta.varmor.org/") && value != "unconfined" {
-				delete(stateful.Spec.Template.Annotations, key)
-			}
-		}
-		// AppArmor, AppArmorSeccomp
-		if (e & varmortypes.AppArmor) != 0 {
-			if strings.HasPrefix(key, "container.apparmor.security.beta.kubernetes.io/") && value != "unconfined" {
-				delete(stateful.Spec.Template.Annotations, key)
-			}
-		}
-		// Seccomp, BPFSeccomp, AppArmorSeccomp
-		if (e & varmortypes.Seccomp) != 0 {
-			if strings.HasPrefix(key, "container.seccomp.security.beta.varmor.org/") && value != "unconfined" {
-				delete(stateful.Spec.Template.Annotations, key)
+	// Clean up the launcher of the Landlock enforcer
+	for index, container := range template.Spec.Containers {
+		template.Spec.Containers[index].Command = varmorutils.UnwrapLandlockCommand(container.Command)
+		var volumeMounts []coreV1.VolumeMount
+		for _, volumeMount := range container.VolumeMounts {
+			if volumeMount.Name != varmorconfig.LandlockVolumeName {
+				volumeMounts = append(volumeMounts, volumeMount)
 			}
 		}
+		template.Spec.Containers[index].VolumeMounts = volumeMounts
 	}
-
-	// Clean up the seccomp settings
-	for index, container := range stateful.Spec.Template.Spec.Containers {
-		if container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil &&
-			strings.HasPrefix(*container.SecurityContext.SeccompProfile.LocalhostProfile, "varmor-") {
-			stateful.Spec.Template.Spec.Containers[index].SecurityContext.SeccompProfile = nil
+	var volumes []coreV1.Volume
+	for _, volume := range template.Spec.Volumes {
+		if volume.Name != varmorconfig.LandlockVolumeName {
+			volumes = append(volumes, volume)
 		}
 	}
+	template.Spec.Volumes = volumes
 
 	// Add the modification time to annotation
-	if stateful.Spec.Template.Annotations == nil {
-		stateful.Spec.Template.Annotations = make(map[string]string)
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
 	}
 
 	if profileName == "" {
 		return
 	}
 
+	// The workloads whose pods run as the other service accounts aren't the targets
+	if !varmorutils.MatchServiceAccount(template.Spec.ServiceAccountName, target.ServiceAccounts) {
+		return
+	}
+
 	// Setting new annotations and seccomp context
-	for index, container := range stateful.Spec.Template.Spec.Containers {
+	for index, container := range template.Spec.Containers {
 		if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
 			continue
 		}
 
-		// BPF
+		// BPF, BPFSeccomp
 		if (e & varmortypes.BPF) != 0 {
 			key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
-			if value, ok := stateful.Spec.Template.Annotations[key]; ok && value == "unconfined" {
+			if value, ok := template.Annotations[key]; ok && value == "unconfined" {
 				continue
 			}
-			stateful.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
+			template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
 
 			if bpfExclusiveMode {
 				key = fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-				stateful.Spec.Template.Annotations[key] = "unconfined"
+				template.Annotations[key] = "unconfined"
 			}
 		}
-		// AppArmor
+		// AppArmor, AppArmorSeccomp
 		if (e & varmortypes.AppArmor) != 0 {
 			key := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-			if value, ok := stateful.Spec.Template.Annotations[key]; ok && value == "unconfined" {
+			if value, ok := template.Annotations[key]; ok && value == "unconfined" {
 				continue
 			}
-			stateful.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
+			template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
 		}
-		// Seccomp
-		if (e & varmortypes.Seccomp) != 0 {
-			if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
-				(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
-				(stateful.Spec.Template.Spec.SecurityContext != nil && stateful.Spec.Template.Spec.SecurityContext.SeccompProfile != nil) {
+		// Landlock, LandlockSeccomp
+		if (e & varmortypes.Landlock) != 0 {
+			key := fmt.Sprintf("container.landlock.security.beta.varmor.org/%s", container.Name)
+			if value, ok := template.Annotations[key]; ok && value == "unconfined" {
 				continue
 			}
-			key := fmt.Sprintf("container.seccomp.security.beta.varmor.org/%s", container.Name)
-			stateful.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
-			if stateful.Spec.Template.Spec.Containers[index].SecurityContext == nil {
-				stateful.Spec.Template.Spec.Containers[index].SecurityContext = &coreV1.SecurityContext{}
-			}
-			stateful.Spec.Template.Spec.Containers[index].SecurityContext.SeccompProfile = &coreV1.SeccompProfile{
-				Type:             "Localhost",
-				LocalhostProfile: &profileName,
-			}
-		}
-	}
-}
-
-func modifyDaemonSetAnnotationsAndEnv(enforcer string, target varmor.Target, daemon *appsV1.DaemonSet, profileName string, bpfExclusiveMode bool) {
-	e := varmortypes.GetEnforcerType(enforcer)
-
-	// Clean up the annotations
-	for key, value := range daemon.Spec.Template.Annotations {
-		// BPF
-		if (e & varmortypes.BPF) != 0 {
-			if strings.HasPrefix(key, "container.bpf.security.beta.varmor.org/") && value != "unconfined" {
-				delete(daemon.Spec.Template.Annotations, key)
-			}
-		}
-		// AppArmor
-		if (e & varmortypes.AppArmor) != 0 {
-			if strings.HasPrefix(key, "container.apparmor.security.beta.kubernetes.io/") && value != "unconfined" {
-				delete(daemon.Spec.Template.Annotations, key)
-			}
-		}
-		// Seccomp
-		if (e & varmortypes.Seccomp) != 0 {
-			if strings.HasPrefix(key, "container.seccomp.security.beta.varmor.org/") && value != "unconfined" {
-				delete(daemon.Spec.Template.Annotations, key)
+			// The container without the command can't be wrapped by the launcher, the update of the workload is
+			// rejected by the webhook
+			if len(container.Command) != 0 {
+				template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
+				addLandlockLauncher(template, index, profileName)
 			}
 		}
-	}
-
-	// Clean up the seccomp settings
-	for index, container := range daemon.Spec.Template.Spec.Containers {
-		if container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil &&
-			strings.HasPrefix(*container.SecurityContext.SeccompProfile.LocalhostProfile, "varmor-") {
-			daemon.Spec.Template.Spec.Containers[index].SecurityContext.SeccompProfile = nil
-		}
-	}
-
-	// Add the modification time to annotation
-	if daemon.Spec.Template.Annotations == nil {
-		daemon.Spec.Template.Annotations = make(map[string]string)
-	}
-
-	if profileName == "" {
-		return
-	}
-
-	// Setting new annotations and seccomp context
-	for index, container := range daemon.Spec.Template.Spec.Containers {
-		if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
-			continue
-		}
-
-		// BPF
-		if (e & varmortypes.BPF) != 0 {
-			key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
-			if value, ok := daemon.Spec.Template.Annotations[key]; ok && value == "unconfined" {
+		// SELinux, SELinuxSeccomp
+		if (e & varmortypes.SELinux) != 0 {
+			key := fmt.Sprintf("container.selinux.security.beta.varmor.org/%s", container.Name)
+			if value, ok := template.Annotations[key]; ok && value == "unconfined" {
 				continue
 			}
-			daemon.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
-
-			if bpfExclusiveMode {
-				key = fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-				daemon.Spec.Template.Annotations[key] = "unconfined"
-			}
-		}
-		// AppArmor
-		if (e & varmortypes.AppArmor) != 0 {
-			key := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-			if value, ok := daemon.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-				continue
+			if !(container.SecurityContext != nil && container.SecurityContext.SELinuxOptions != nil) &&
+				!(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) &&
+				!(template.Spec.SecurityContext != nil && template.Spec.SecurityContext.SELinuxOptions != nil) {
+				template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
+				if template.Spec.Containers[index].SecurityContext == nil {
+					template.Spec.Containers[index].SecurityContext = &coreV1.SecurityContext{}
+				}
+				template.Spec.Containers[index].SecurityContext.SELinuxOptions = &coreV1.SELinuxOptions{
+					Type: selinuxprofile.ProcessType(profileName),
+				}
 			}
-			daemon.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
 		}
-		// Seccomp
+		// Seccomp, BPFSeccomp, AppArmorSeccomp
 		if (e & varmortypes.Seccomp) != 0 {
 			if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
 				(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
-				(daemon.Spec.Template.Spec.SecurityContext != nil && daemon.Spec.Template.Spec.SecurityContext.SeccompProfile != nil) {
+				(template.Spec.SecurityContext != nil && template.Spec.SecurityContext.SeccompProfile != nil) {
 				continue
 			}
 			key := fmt.Sprintf("container.seccomp.security.beta.varmor.org/%s", container.Name)
-			daemon.Spec.Template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
-			if daemon.Spec.Template.Spec.Containers[index].SecurityContext == nil {
-				daemon.Spec.Template.Spec.Containers[index].SecurityContext = &coreV1.SecurityContext{}
+			template.Annotations[key] = fmt.Sprintf("localhost/%s", profileName)
+			if template.Spec.Containers[index].SecurityContext == nil {
+				template.Spec.Containers[index].SecurityContext = &coreV1.SecurityContext{}
 			}
-			daemon.Spec.Template.Spec.Containers[index].SecurityContext.SeccompProfile = &coreV1.SeccompProfile{
+			template.Spec.Containers[index].SecurityContext.SeccompProfile = &coreV1.SeccompProfile{
 				Type:             "Localhost",
 				LocalhostProfile: &profileName,
 			}
 		}
 	}
+
+	// BPF, BPFSeccomp
+	if confineSandbox && (e&varmortypes.BPF) != 0 && template.Annotations[pkgtypes.SandboxBpfAnnotation] != "unconfined" {
+		template.Annotations[pkgtypes.SandboxBpfAnnotation] = fmt.Sprintf("localhost/%s", pkgtypes.SandboxProfileName)
+	}
+}
+
+// addLandlockLauncher mounts the LandlockProfileDir into the container and wraps its command with the launcher
+func addLandlockLauncher(template *coreV1.PodTemplateSpec, index int, profileName string) {
+	container := &template.Spec.Containers[index]
+	container.Command = varmorutils.WrapLandlockCommand(container.Command, profileName)
+	container.VolumeMounts = append(container.VolumeMounts, coreV1.VolumeMount{
+		Name:      varmorconfig.LandlockVolumeName,
+		MountPath: varmorconfig.LandlockContainerDir,
+		ReadOnly:  true,
+	})
+
+	for _, volume := range template.Spec.Volumes {
+		if volume.Name == varmorconfig.LandlockVolumeName {
+			return
+		}
+	}
+	hostPathType := coreV1.HostPathDirectory
+	template.Spec.Volumes = append(template.Spec.Volumes, coreV1.Volume{
+		Name: varmorconfig.LandlockVolumeName,
+		VolumeSource: coreV1.VolumeSource{
+			HostPath: &coreV1.HostPathVolumeSource{
+				Path: varmorconfig.LandlockProfileDir,
+				Type: &hostPathType,
+			},
+		},
+	})
 }
 
 func updateWorkloadAnnotationsAndEnv(
 	appsInterface appsv1.AppsV1Interface,
+	batchInterface batchv1.BatchV1Interface,
 	namespace string,
 	enforcer string,
 	target varmor.Target,
 	profileName string,
 	bpfExclusiveMode bool,
+	confineSandbox bool,
 	logger logr.Logger) {
 
 	matchFields := make(map[string]string)
@@ -366,7 +318,7 @@ func updateWorkloadAnnotationsAndEnv(
 				}
 
 				deployOld := deploy.DeepCopy()
-				modifyDeploymentAnnotationsAndEnv(enforcer, target, deploy, profileName, bpfExclusiveMode)
+				modifyPodTemplateAnnotationsAndEnv(enforcer, target, &deploy.Spec.Template, profileName, bpfExclusiveMode, confineSandbox)
 				if reflect.DeepEqual(deployOld, deploy) {
 					return nil
 				}
@@ -410,7 +362,7 @@ func updateWorkloadAnnotationsAndEnv(
 				}
 
 				statefulOld := stateful.DeepCopy()
-				modifyStatefulSetAnnotationsAndEnv(enforcer, target, stateful, profileName, bpfExclusiveMode)
+				modifyPodTemplateAnnotationsAndEnv(enforcer, target, &stateful.Spec.Template, profileName, bpfExclusiveMode, confineSandbox)
 				if reflect.DeepEqual(statefulOld, stateful) {
 					return nil
 				}
@@ -458,7 +410,7 @@ func updateWorkloadAnnotationsAndEnv(
 				}
 
 				daemonOld := daemon.DeepCopy()
-				modifyDaemonSetAnnotationsAndEnv(enforcer, target, daemon, profileName, bpfExclusiveMode)
+				modifyPodTemplateAnnotationsAndEnv(enforcer, target, &daemon.Spec.Template, profileName, bpfExclusiveMode, confineSandbox)
 				if reflect.DeepEqual(daemonOld, &daemon) {
 					return nil
 				}
@@ -477,5 +429,59 @@ func updateWorkloadAnnotationsAndEnv(
 				logger.Error(err, "failed to update the target workload")
 			}
 		}
+
+	case "CronJob":
+		cronJobs, err := batchInterface.CronJobs(namespace).List(context.Background(), listOpt)
+		if err != nil {
+			logger.Error(err, "CronJobs().List()")
+			return
+		}
+
+		for _, item := range cronJobs.Items {
+			needRegain := false
+			cronJob := &item
+
+			// The Jobs created by the CronJob inherit the pod template of it, so there is no need to restart
+			// anything. The protection takes effect on the next run.
+			updateCronJob := func() error {
+				if needRegain {
+					cronJob, err = batchInterface.CronJobs(cronJob.Namespace).Get(context.Background(), cronJob.Name, metav1.GetOptions{})
+					if err != nil {
+						if k8errors.IsNotFound(err) {
+							return nil
+						}
+						return err
+					}
+					needRegain = false
+				}
+
+				cronJobOld := cronJob.DeepCopy()
+				modifyPodTemplateAnnotationsAndEnv(enforcer, target, &cronJob.Spec.JobTemplate.Spec.Template, profileName, bpfExclusiveMode, confineSandbox)
+				if reflect.DeepEqual(cronJobOld, cronJob) {
+					return nil
+				}
+				cronJob, err = batchInterface.CronJobs(cronJob.Namespace).Update(context.Background(), cronJob, metav1.UpdateOptions{})
+				if err == nil {
+					logger.Info("the target workload has been updated", "Kind", "CronJobs", "namespace", cronJob.Namespace, "name", cronJob.Name)
+				} else {
+					needRegain = true
+				}
+				return err
+			}
+
+			err := retry.RetryOnConflict(retry.DefaultRetry, updateCronJob)
+			if err != nil {
+				logger.Error(err, "failed to update the target workload")
+			}
+		}
+
+	case "Job":
+		// The pod template of Job is immutable, the existing Jobs must be recreated to enable or disable the protection.
+		logger.Info("the existing Jobs can't be updated, please recreate them if needed", "namespace", namespace)
+
+	default:
+		// The pod templates of the custom owner kinds are managed by their own controllers.
+		logger.Info("the existing workloads of the custom owner kind can't be updated, please recreate their pods if needed",
+			"kind", target.Kind, "apiVersion", target.APIVersion, "namespace", namespace)
 	}
 }
@@ -37,8 +37,14 @@ type PolicyCacher struct {
 	ClusterPolicyEnforcer map[string]string
 	PolicyTargets         map[string]varmor.Target
 	PolicyEnforcer        map[string]string
-	debug                 bool
-	log                   logr.Logger
+	// ClusterPolicyRejectPrivileged and PolicyRejectPrivileged save the policies that reject the privileged containers
+	ClusterPolicyRejectPrivileged map[string]bool
+	PolicyRejectPrivileged        map[string]bool
+	// ClusterPolicyConfineSandbox and PolicyConfineSandbox save the policies that confine the sandbox containers
+	ClusterPolicyConfineSandbox map[string]bool
+	PolicyConfineSandbox        map[string]bool
+	debug                       bool
+	log                         logr.Logger
 }
 
 func NewPolicyCacher(
@@ -58,8 +64,14 @@ func NewPolicyCacher(
 		ClusterPolicyEnforcer: make(map[string]string),
 		PolicyTargets:         make(map[string]varmor.Target),
 		PolicyEnforcer:        make(map[string]string),
-		debug:                 debug,
-		log:                   log,
+		// The policies that reject the privileged containers
+		ClusterPolicyRejectPrivileged: make(map[string]bool),
+		PolicyRejectPrivileged:        make(map[string]bool),
+		// The policies that confine the sandbox containers
+		ClusterPolicyConfineSandbox: make(map[string]bool),
+		PolicyConfineSandbox:        make(map[string]bool),
+		debug:                       debug,
+		log:                         log,
 	}
 
 	return &cacher, nil
@@ -75,6 +87,8 @@ func (c *PolicyCacher) addVarmorClusterPolicy(obj interface{}) {
 	}
 	c.ClusterPolicyTargets[key] = vcp.Spec.DeepCopy().Target
 	c.ClusterPolicyEnforcer[key] = vcp.Spec.Policy.Enforcer
+	c.ClusterPolicyRejectPrivileged[key] = vcp.Spec.Policy.RejectPrivilegedContainers
+	c.ClusterPolicyConfineSandbox[key] = vcp.Spec.Policy.ConfineSandboxContainers
 }
 
 func (c *PolicyCacher) updateVarmorClusterPolicy(oldObj, newObj interface{}) {
@@ -87,6 +101,8 @@ func (c *PolicyCacher) updateVarmorClusterPolicy(oldObj, newObj interface{}) {
 	}
 	c.ClusterPolicyTargets[key] = vcp.Spec.DeepCopy().Target
 	c.ClusterPolicyEnforcer[key] = vcp.Spec.Policy.Enforcer
+	c.ClusterPolicyRejectPrivileged[key] = vcp.Spec.Policy.RejectPrivilegedContainers
+	c.ClusterPolicyConfineSandbox[key] = vcp.Spec.Policy.ConfineSandboxContainers
 }
 
 func (c *PolicyCacher) deleteVarmorClusterPolicy(obj interface{}) {
@@ -99,6 +115,8 @@ func (c *PolicyCacher) deleteVarmorClusterPolicy(obj interface{}) {
 	}
 	delete(c.ClusterPolicyTargets, key)
 	delete(c.ClusterPolicyEnforcer, key)
+	delete(c.ClusterPolicyRejectPrivileged, key)
+	delete(c.ClusterPolicyConfineSandbox, key)
 }
 
 func (c *PolicyCacher) addVarmorPolicy(obj interface{}) {
@@ -111,6 +129,8 @@ func (c *PolicyCacher) addVarmorPolicy(obj interface{}) {
 	}
 	c.PolicyTargets[key] = vp.Spec.DeepCopy().Target
 	c.PolicyEnforcer[key] = vp.Spec.Policy.Enforcer
+	c.PolicyRejectPrivileged[key] = vp.Spec.Policy.RejectPrivilegedContainers
+	c.PolicyConfineSandbox[key] = vp.Spec.Policy.ConfineSandboxContainers
 }
 
 func (c *PolicyCacher) updateVarmorPolicy(oldObj, newObj interface{}) {
@@ -123,6 +143,8 @@ func (c *PolicyCacher) updateVarmorPolicy(oldObj, newObj interface{}) {
 	}
 	c.PolicyTargets[key] = vp.Spec.DeepCopy().Target
 	c.PolicyEnforcer[key] = vp.Spec.Policy.Enforcer
+	c.PolicyRejectPrivileged[key] = vp.Spec.Policy.RejectPrivilegedContainers
+	c.PolicyConfineSandbox[key] = vp.Spec.Policy.ConfineSandboxContainers
 }
 
 func (c *PolicyCacher) deleteVarmorPolicy(obj interface{}) {
@@ -135,6 +157,8 @@ func (c *PolicyCacher) deleteVarmorPolicy(obj interface{}) {
 	}
 	delete(c.PolicyTargets, key)
 	delete(c.PolicyEnforcer, key)
+	delete(c.PolicyRejectPrivileged, key)
+	delete(c.PolicyConfineSandbox, key)
 }
 
 func (c *PolicyCacher) Run(stopCh <-chan struct{}) {
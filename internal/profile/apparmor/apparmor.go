@@ -17,6 +17,7 @@ package apparmor
 import (
 	"encoding/base64"
 	"fmt"
+	"regexp"
 	"strings"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
@@ -91,6 +92,12 @@ func generateHardeningRules(rule string) (rules string) {
 	// disallow access to the root of the task through procfs
 	case "disallow-access-procfs-root":
 		rules += "  deny ptrace read,\n"
+	// disallow tampering with the pinned BPF programs, the sockets and the profiles of vArmor
+	case "disallow-tamper-varmor":
+		rules += "  deny /sys/fs/bpf/varmor{,/**} rwlk,\n"
+		rules += "  deny /{,var/}run/varmor/** rwlk,\n"
+		rules += "  deny /var/lib/kubelet/seccomp/varmor-* wl,\n"
+		rules += "  deny /sys/kernel/security/apparmor/** wl,\n"
 
 	//// 2. Disable capabilities
 	// disable all capabilities
@@ -272,9 +279,92 @@ func generateVulMitigationRules(rule string) (rules string) {
 	return rules
 }
 
+func generateFileIntegrityRules(rule varmor.FileIntegrityRule) (rules string) {
+	if !rule.Block {
+		return rules
+	}
+
+	for _, path := range rule.Paths {
+		if strings.HasSuffix(path, "/") {
+			path += "**"
+		}
+		rules += fmt.Sprintf("  deny %s wl,\n", path)
+	}
+	return rules
+}
+
+// builtinWritablePaths are always writable in the read-only filesystem, the same as they are mounted
+// when readOnlyRootFilesystem is set
+var builtinWritablePaths = []string{"/dev/**", "/proc/**"}
+
+// generateReadOnlyFilesystemRules generates the rules to replace the "file," rule of the templates. They allow
+// all file accesses except writing the files that don't match the writable paths. The deny rules still apply
+// to the writable paths.
+func generateReadOnlyFilesystemRules(rule varmor.ReadOnlyFilesystem) (rules string) {
+	rules += "  /** rmkix,\n"
+	for _, path := range append(builtinWritablePaths, rule.WritablePaths...) {
+		if strings.HasSuffix(path, "/") {
+			path += "**"
+		}
+		rules += fmt.Sprintf("  %s wl,\n", path)
+	}
+	return rules
+}
+
+// abstractionNameRegex matches the names of the abstractions, it disallows the path traversal
+var abstractionNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateAbstraction checks whether the name of the abstraction is valid
+func ValidateAbstraction(name string) error {
+	if !abstractionNameRegex.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("the name of the abstraction '%s' is invalid", name)
+	}
+	return nil
+}
+
+// ValidateRawSnippet checks whether the braces of the raw snippet are balanced, so the snippet can't break out of
+// the profile it's embedded in
+func ValidateRawSnippet(snippet string) error {
+	depth := 0
+	for _, c := range snippet {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("the braces of the raw snippet are unbalanced")
+	}
+	return nil
+}
+
+// generateRawSnippet indents the lines of the raw snippet
+func generateRawSnippet(snippet string) (rules string) {
+	for _, line := range strings.Split(snippet, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+		rules += "  " + line + "\n"
+	}
+	return rules
+}
+
 func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect, profileName string) string {
 	var baseRules string
 
+	// Abstractions
+	for _, abstraction := range enhanceProtect.AppArmorAbstractions {
+		if ValidateAbstraction(abstraction) == nil {
+			baseRules += fmt.Sprintf("  #include <abstractions/%s>\n", abstraction)
+		}
+	}
+
 	// Hardening
 	for _, rule := range enhanceProtect.HardeningRules {
 		baseRules += generateHardeningRules(rule)
@@ -285,12 +375,22 @@ func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect, profil
 		baseRules += generateVulMitigationRules(rule)
 	}
 
+	// File Integrity
+	for _, rule := range enhanceProtect.FileIntegrityRules {
+		baseRules += generateFileIntegrityRules(rule)
+	}
+
 	// Custom
 	for _, rule := range enhanceProtect.AppArmorRawRules {
 		if strings.HasSuffix(rule, ",") {
 			baseRules += "  " + rule + "\n"
 		}
 	}
+	for _, snippet := range enhanceProtect.AppArmorRawSnippets {
+		if ValidateRawSnippet(snippet) == nil {
+			baseRules += generateRawSnippet(snippet)
+		}
+	}
 
 	// Attack Protection
 	for _, attackProtectionRule := range enhanceProtect.AttackProtectionRules {
@@ -338,15 +438,21 @@ func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect, profil
 		}
 	}
 
+	var p string
 	if enhanceProtect.Privileged {
 		// Create profile for privileged container based on the AlwaysAllow template
-		p := fmt.Sprintf(alwaysAllowTemplate, profileName, baseRules)
-		return base64.StdEncoding.EncodeToString([]byte(p))
+		p = fmt.Sprintf(alwaysAllowTemplate, profileName, baseRules)
 	} else {
 		// Create profile for unprivileged container based on the RuntimeDefault template
-		p := fmt.Sprintf(runtimeDefaultTemplate, profileName, profileName, profileName, baseRules)
-		return base64.StdEncoding.EncodeToString([]byte(p))
+		p = fmt.Sprintf(runtimeDefaultTemplate, profileName, profileName, profileName, baseRules)
+	}
+
+	// Read-only Filesystem, the "file," rules of the profile and its child profiles are replaced
+	if enhanceProtect.ReadOnlyFilesystem.Enable {
+		p = strings.ReplaceAll(p, "  file,\n", generateReadOnlyFilesystemRules(enhanceProtect.ReadOnlyFilesystem))
 	}
+
+	return base64.StdEncoding.EncodeToString([]byte(p))
 }
 
 func GenerateBehaviorModelingProfile(profileName string) string {
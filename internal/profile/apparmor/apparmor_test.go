@@ -0,0 +1,89 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apparmor
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_GenerateEnhanceProtectProfile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		abstraction string
+		snippet     string
+		expected    []string
+		unexpected  []string
+	}{
+		{
+			name:        "abstractionAndSnippet",
+			abstraction: "nameservice",
+			snippet:     "/data/** {\n  rw,\n}\n",
+			expected:    []string{"  #include <abstractions/nameservice>\n", "  /data/** {\n    rw,\n  }\n"},
+		},
+		{
+			name:        "pathTraversal",
+			abstraction: "../../etc/passwd",
+			unexpected:  []string{"passwd"},
+		},
+		{
+			name:       "unbalancedBraces",
+			snippet:    "}\nprofile escape {",
+			unexpected: []string{"escape"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enhanceProtect := &varmor.EnhanceProtect{}
+			if tc.abstraction != "" {
+				enhanceProtect.AppArmorAbstractions = []string{tc.abstraction}
+			}
+			if tc.snippet != "" {
+				enhanceProtect.AppArmorRawSnippets = []string{tc.snippet}
+			}
+
+			content, err := base64.StdEncoding.DecodeString(GenerateEnhanceProtectProfile(enhanceProtect, "test"))
+			assert.NilError(t, err)
+			for _, s := range tc.expected {
+				assert.Assert(t, strings.Contains(string(content), s))
+			}
+			for _, s := range tc.unexpected {
+				assert.Assert(t, !strings.Contains(string(content), s))
+			}
+		})
+	}
+}
+
+func Test_GenerateEnhanceProtectProfileReadOnlyFilesystem(t *testing.T) {
+	enhanceProtect := &varmor.EnhanceProtect{
+		ReadOnlyFilesystem: varmor.ReadOnlyFilesystem{
+			Enable:        true,
+			WritablePaths: []string{"/tmp/", "/var/log/app.log"},
+		},
+	}
+
+	content, err := base64.StdEncoding.DecodeString(GenerateEnhanceProtectProfile(enhanceProtect, "test"))
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(content), "  file,\n"))
+	for _, rule := range []string{"  /** rmkix,\n", "  /dev/** wl,\n", "  /tmp/** wl,\n", "  /var/log/app.log wl,\n"} {
+		assert.Assert(t, strings.Contains(string(content), rule), rule)
+	}
+}
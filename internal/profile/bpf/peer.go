@@ -0,0 +1,81 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"fmt"
+	"net"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func validateNetworkPeerContent(field string, peer varmor.NetworkPeerContent) error {
+	if (peer.ServiceName == "") == (peer.PodSelector == nil) {
+		return fmt.Errorf("%s: exactly one of the serviceName and the podSelector should be set", field)
+	}
+	if peer.PodSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(peer.PodSelector); err != nil {
+			return fmt.Errorf("%s.podSelector: %v", field, err)
+		}
+	}
+	if peer.Port > 65535 {
+		return fmt.Errorf("%s.port: %d is not a valid port, it should be in the range of 1-65535", field, peer.Port)
+	}
+	for i, address := range peer.Addresses {
+		if net.ParseIP(address) == nil {
+			return fmt.Errorf("%s.addresses[%d]: '%s' is not a valid IP address", field, i, address)
+		}
+	}
+	return nil
+}
+
+// SetNetworkPeerNamespace sets the namespace of the network peers which don't specify it
+func SetNetworkPeerNamespace(bpfContent *varmor.BpfContent, namespace string) {
+	for i := range bpfContent.NetworkPeers {
+		if bpfContent.NetworkPeers[i].Namespace == "" {
+			bpfContent.NetworkPeers[i].Namespace = namespace
+		}
+	}
+}
+
+// networkPeerKey identifies the Service or Pods that the network peer references
+func networkPeerKey(peer *varmor.NetworkPeerContent) string {
+	if peer.ServiceName != "" {
+		return fmt.Sprintf("service/%s/%s", peer.Namespace, peer.ServiceName)
+	}
+	return fmt.Sprintf("pods/%s/%s", peer.Namespace, metav1.FormatLabelSelector(peer.PodSelector))
+}
+
+// InheritNetworkPeerAddresses copies the resolved addresses of the network peers in the old BPF profile to the
+// ones referencing the same Service or Pods in the new BPF profile. So the connections to the peers aren't
+// interrupted by the update of the policy before the manager resolves the addresses again.
+func InheritNetworkPeerAddresses(newContent *varmor.BpfContent, oldContent *varmor.BpfContent) {
+	if newContent == nil || oldContent == nil {
+		return
+	}
+
+	addresses := make(map[string][]string, len(oldContent.NetworkPeers))
+	for i := range oldContent.NetworkPeers {
+		addresses[networkPeerKey(&oldContent.NetworkPeers[i])] = oldContent.NetworkPeers[i].Addresses
+	}
+	for i := range newContent.NetworkPeers {
+		peer := &newContent.NetworkPeers[i]
+		if peer.Addresses == nil {
+			peer.Addresses = append([]string(nil), addresses[networkPeerKey(peer)]...)
+		}
+	}
+}
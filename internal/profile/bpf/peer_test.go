@@ -0,0 +1,79 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+)
+
+func Test_GenerateEnhanceProtectProfileNetworkPeers(t *testing.T) {
+	enhanceProtect := varmor.EnhanceProtect{
+		BpfRawRules: varmor.BpfRawRules{
+			Network: varmor.NetworkRule{
+				Egresses: []varmor.NetworkEgressRule{
+					{IP: "10.0.0.1"},
+					{Service: &varmor.EgressService{Name: "redis"}, Port: 6379},
+					{Pods: &varmor.EgressPods{Namespace: "db", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "mysql"}}}},
+				},
+			},
+		},
+	}
+
+	var bpfContent varmor.BpfContent
+	err := profilebuilder.Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+	SetNetworkPeerNamespace(&bpfContent, "demo")
+	assert.NilError(t, ValidateBpfContent(&bpfContent))
+	assert.Equal(t, len(bpfContent.Networks), 1)
+	assert.DeepEqual(t, bpfContent.NetworkPeers, []varmor.NetworkPeerContent{
+		{Namespace: "demo", ServiceName: "redis", Port: 6379, RuleID: "bpfRawRules.network.egresses/1"},
+		{Namespace: "db", PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "mysql"}}, RuleID: "bpfRawRules.network.egresses/2"},
+	})
+
+	// The resolved addresses are inherited by the updated profile, and they don't make the rules new
+	oldContent := bpfContent.DeepCopy()
+	oldContent.NetworkPeers[0].Addresses = []string{"10.96.0.10", "172.16.0.5"}
+	newContent := bpfContent.DeepCopy()
+	InheritNetworkPeerAddresses(newContent, oldContent)
+	assert.DeepEqual(t, newContent.NetworkPeers[0].Addresses, []string{"10.96.0.10", "172.16.0.5"})
+	assert.Assert(t, newContent.NetworkPeers[1].Addresses == nil)
+
+	var networks []ReportRule
+	for _, rule := range GenerateReport(oldContent).Rules {
+		if rule.Type == "network" {
+			networks = append(networks, rule)
+		}
+	}
+	assert.Equal(t, len(networks), 3)
+	assert.Equal(t, networks[1].Subject, "service demo/redis:6379")
+	assert.Equal(t, networks[1].Details, "addresses: 10.96.0.10, 172.16.0.5")
+	assert.Equal(t, networks[2].Subject, "pods db/{app=mysql}")
+	assert.Equal(t, networks[2].Details, "no address resolved")
+
+	oldContent.NetworkPeers[0].Addresses = []string{"redis"}
+	assert.ErrorContains(t, ValidateBpfContent(oldContent), "networkPeers[0].addresses[0]")
+
+	enhanceProtect.BpfRawRules.Network.Egresses = []varmor.NetworkEgressRule{
+		{IP: "10.0.0.1", Service: &varmor.EgressService{Name: "redis"}},
+	}
+	err = profilebuilder.Build(&enhanceProtect, &varmor.BpfContent{})
+	assert.ErrorContains(t, err, "only one of the ipBlock, ip, service and pods can be set")
+}
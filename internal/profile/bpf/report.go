@@ -0,0 +1,281 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+)
+
+// ReportRule is a rule of the BPF profile in human-readable form
+type ReportRule struct {
+	// Type is the type of the rule, e.g. capability, file, process, network, ptrace and mount
+	Type string `json:"type"`
+	// Subject is the path pattern, the capability, the network address or the ptrace peer that the rule matches
+	Subject string `json:"subject"`
+	// Permissions are the permissions denied by the rule
+	Permissions []string `json:"permissions,omitempty"`
+	// Details describes the other conditions of the rule
+	Details string `json:"details,omitempty"`
+	// RuleID identifies the policy rule that generated the rule
+	RuleID string `json:"ruleID,omitempty"`
+}
+
+// ProfileReport is the human-readable form of a BPF profile, it's used for the security review and audits
+type ProfileReport struct {
+	Rules []ReportRule `json:"rules"`
+	// RuleIDs are the policy rules that generated the rules, e.g. the built-in rules and the raw rules
+	RuleIDs []string `json:"ruleIDs,omitempty"`
+}
+
+// patternString converts the path pattern back into the form of the policy
+func patternString(pattern *varmor.PathPattern) string {
+	if pattern.Flags&profilebuilder.PreciseMatch != 0 {
+		return pattern.Prefix
+	}
+	if pattern.Flags&profilebuilder.GreedyMatch != 0 {
+		return pattern.Prefix + "**" + reverseString(pattern.Suffix)
+	}
+	return pattern.Prefix + "*" + reverseString(pattern.Suffix)
+}
+
+func reportPermissionNames(permissions uint32) []string {
+	names := filePermissionNames(permissions)
+	if permissions&profilebuilder.AaMayExec != 0 {
+		names = append(names, "x")
+	}
+	return names
+}
+
+func networkSubject(network *varmor.NetworkContent) string {
+	address := "*"
+	switch {
+	case network.Flags&profilebuilder.CidrMatch != 0:
+		address = network.CIDR
+	case network.Flags&profilebuilder.PreciseMatch != 0:
+		address = network.Address
+	}
+
+	if network.Flags&profilebuilder.PortMatch == 0 {
+		return address
+	}
+	if network.Flags&profilebuilder.Ipv6Match != 0 && network.Flags&profilebuilder.PreciseMatch != 0 {
+		address = "[" + address + "]"
+	}
+	return fmt.Sprintf("%s:%d", address, network.Port)
+}
+
+func networkPeerSubject(peer *varmor.NetworkPeerContent) string {
+	subject := fmt.Sprintf("service %s/%s", peer.Namespace, peer.ServiceName)
+	if peer.ServiceName == "" {
+		subject = fmt.Sprintf("pods %s/{%s}", peer.Namespace, metav1.FormatLabelSelector(peer.PodSelector))
+	}
+	if peer.Port == 0 {
+		return subject
+	}
+	return fmt.Sprintf("%s:%d", subject, peer.Port)
+}
+
+func ptracePermissionNames(permissions uint32) []string {
+	var names []string
+	if permissions&profilebuilder.AaPtraceTrace != 0 {
+		names = append(names, "trace")
+	}
+	if permissions&profilebuilder.AaPtraceRead != 0 {
+		names = append(names, "read")
+	}
+	if permissions&profilebuilder.AaMayBeTraced != 0 {
+		names = append(names, "tracedby")
+	}
+	if permissions&profilebuilder.AaMayBeRead != 0 {
+		names = append(names, "readby")
+	}
+	return names
+}
+
+// capabilityNames returns the names of the capabilities in the order of their numbers
+func capabilityNames(capabilities uint64) []string {
+	var names []string
+	for name, n := range profilebuilder.CapabilityNumbers {
+		if capabilities&(1<<n) != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return profilebuilder.CapabilityNumbers[names[i]] < profilebuilder.CapabilityNumbers[names[j]]
+	})
+	return names
+}
+
+// GenerateReport renders the BPF profile into a report which lists the paths, permissions, capabilities,
+// networks, mounts, ptrace settings of the rules, and the policy rules that generated them.
+func GenerateReport(bpfContent *varmor.BpfContent) *ProfileReport {
+	report := ProfileReport{}
+
+	for _, name := range capabilityNames(bpfContent.Capabilities) {
+		report.Rules = append(report.Rules, ReportRule{
+			Type:    "capability",
+			Subject: name,
+		})
+	}
+
+	for _, file := range bpfContent.Files {
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        "file",
+			Subject:     patternString(&file.Pattern),
+			Permissions: reportPermissionNames(file.Permissions),
+			RuleID:      file.RuleID,
+		})
+	}
+
+	for _, process := range bpfContent.Processes {
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        "process",
+			Subject:     patternString(&process.Pattern),
+			Permissions: reportPermissionNames(process.Permissions),
+			RuleID:      process.RuleID,
+		})
+	}
+
+	for _, regexFile := range bpfContent.RegexFiles {
+		t := "file"
+		if regexFile.Permissions&profilebuilder.AaMayExec != 0 {
+			t = "process"
+		}
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        t,
+			Subject:     regexFile.Regex,
+			Permissions: reportPermissionNames(regexFile.Permissions),
+			Details:     "regular expression",
+			RuleID:      regexFile.RuleID,
+		})
+	}
+
+	for _, hashProcess := range bpfContent.HashProcesses {
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        "process",
+			Subject:     hashProcess.Path,
+			Permissions: []string{"x"},
+			Details:     "unless SHA256 is one of: " + strings.Join(hashProcess.SHA256, ", "),
+			RuleID:      hashProcess.RuleID,
+		})
+	}
+
+	for _, network := range bpfContent.Networks {
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        "network",
+			Subject:     networkSubject(&network),
+			Permissions: []string{"connect"},
+			RuleID:      network.RuleID,
+		})
+	}
+
+	for _, peer := range bpfContent.NetworkPeers {
+		details := "no address resolved"
+		if len(peer.Addresses) != 0 {
+			details = "addresses: " + strings.Join(peer.Addresses, ", ")
+		}
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        "network",
+			Subject:     networkPeerSubject(&peer),
+			Permissions: []string{"connect"},
+			Details:     details,
+			RuleID:      peer.RuleID,
+		})
+	}
+
+	if bpfContent.Ptrace != nil && bpfContent.Ptrace.Permissions != 0 {
+		subject := "processes outside the container"
+		if bpfContent.Ptrace.Flags&profilebuilder.GreedyMatch != 0 {
+			subject = "all processes"
+		}
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        "ptrace",
+			Subject:     subject,
+			Permissions: ptracePermissionNames(bpfContent.Ptrace.Permissions),
+			RuleID:      bpfContent.Ptrace.RuleID,
+		})
+	}
+
+	for _, mount := range bpfContent.Mounts {
+		var permissions []string
+		if mount.MountFlags&^profilebuilder.AaMayUmount != 0 || mount.ReverseMountflags != 0 {
+			permissions = append(permissions, "mount")
+		}
+		if mount.MountFlags&profilebuilder.AaMayUmount != 0 {
+			permissions = append(permissions, "umount")
+		}
+		report.Rules = append(report.Rules, ReportRule{
+			Type:        "mount",
+			Subject:     patternString(&mount.Pattern),
+			Permissions: permissions,
+			Details:     fmt.Sprintf("fstype: %s, flags: %#x, reverse flags: %#x", mount.Fstype, mount.MountFlags, mount.ReverseMountflags),
+			RuleID:      mount.RuleID,
+		})
+	}
+
+	ruleIDs := make(map[string]bool)
+	for _, rule := range report.Rules {
+		for _, id := range strings.Split(rule.RuleID, ",") {
+			if id != "" && !ruleIDs[id] {
+				ruleIDs[id] = true
+				report.RuleIDs = append(report.RuleIDs, id)
+			}
+		}
+	}
+	sort.Strings(report.RuleIDs)
+
+	return &report
+}
+
+// String returns the rule in one line, e.g. "file /etc/shadow r,w [disallow-read-shadow]"
+func (rule *ReportRule) String() string {
+	fields := []string{rule.Type, rule.Subject}
+	if len(rule.Permissions) != 0 {
+		fields = append(fields, strings.Join(rule.Permissions, ","))
+	}
+	if rule.Details != "" {
+		fields = append(fields, rule.Details)
+	}
+	if rule.RuleID != "" {
+		fields = append(fields, "["+rule.RuleID+"]")
+	}
+	return strings.Join(fields, " ")
+}
+
+// WriteText writes the report in the form of a table
+func (report *ProfileReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tSUBJECT\tPERMISSIONS\tDETAILS\tRULE ID")
+	for _, rule := range report.Rules {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", rule.Type, rule.Subject, strings.Join(rule.Permissions, ","), rule.Details, rule.RuleID)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.RuleIDs) != 0 {
+		fmt.Fprintf(w, "\nPolicy rules: %s\n", strings.Join(report.RuleIDs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+)
+
+func Test_GenerateReport(t *testing.T) {
+	file, err := profilebuilder.NewPathRule("/etc/**.conf", profilebuilder.AaMayWrite|profilebuilder.AaMayAppend)
+	assert.NilError(t, err)
+	file.RuleID = "bpfRawRules.files/0"
+	process, err := profilebuilder.NewPathRule("*sh", profilebuilder.AaMayExec)
+	assert.NilError(t, err)
+	process.RuleID = "attackProtectionRules/disable-shell"
+	network, err := profilebuilder.NewNetworkRule("", "2001:db8::1", 443)
+	assert.NilError(t, err)
+	network.RuleID = "attackProtectionRules/disallow-metadata-service"
+
+	bpfContent := varmor.BpfContent{
+		Capabilities: 1<<unix.CAP_SYS_ADMIN | 1<<unix.CAP_NET_RAW,
+		Files:        []varmor.FileContent{*file},
+		Processes:    []varmor.FileContent{*process},
+		Networks:     []varmor.NetworkContent{*network},
+		Ptrace: &varmor.PtraceContent{
+			Permissions: profilebuilder.AaPtraceTrace | profilebuilder.AaPtraceRead,
+			Flags:       profilebuilder.PreciseMatch,
+			RuleID:      "runtimeDefault,bpfRawRules.ptrace",
+		},
+	}
+
+	report := GenerateReport(&bpfContent)
+	assert.DeepEqual(t, report.Rules, []ReportRule{
+		{Type: "capability", Subject: "net_raw"},
+		{Type: "capability", Subject: "sys_admin"},
+		{Type: "file", Subject: "/etc/**.conf", Permissions: []string{"w", "a"}, RuleID: "bpfRawRules.files/0"},
+		{Type: "process", Subject: "*sh", Permissions: []string{"x"}, RuleID: "attackProtectionRules/disable-shell"},
+		{Type: "network", Subject: "[2001:db8::1]:443", Permissions: []string{"connect"}, RuleID: "attackProtectionRules/disallow-metadata-service"},
+		{Type: "ptrace", Subject: "processes outside the container", Permissions: []string{"trace", "read"}, RuleID: "runtimeDefault,bpfRawRules.ptrace"},
+	})
+	assert.DeepEqual(t, report.RuleIDs, []string{
+		"attackProtectionRules/disable-shell",
+		"attackProtectionRules/disallow-metadata-service",
+		"bpfRawRules.files/0",
+		"bpfRawRules.ptrace",
+		"runtimeDefault",
+	})
+
+	var buf bytes.Buffer
+	assert.NilError(t, report.WriteText(&buf))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Assert(t, strings.HasPrefix(lines[0], "TYPE"))
+	assert.Assert(t, strings.Contains(buf.String(), "attackProtectionRules/disable-shell"))
+}
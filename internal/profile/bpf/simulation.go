@@ -0,0 +1,198 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+)
+
+// DeniedBehavior is a recorded behavior that would have been denied by the BPF profile
+type DeniedBehavior struct {
+	// Type is the type of the behavior, e.g. file, process, capability and ptrace
+	Type string `json:"type"`
+	// Subject is the file path, the executable, the capability or the ptrace peer
+	Subject string `json:"subject"`
+	// Permissions are the denied permissions of the behavior
+	Permissions []string `json:"permissions,omitempty"`
+	// RuleID identifies the policy rule that denies the behavior
+	RuleID string `json:"ruleID,omitempty"`
+}
+
+func reverseString(s string) string {
+	bytes := []byte(s)
+	len := len(bytes)
+
+	for i := 0; i < len/2; i++ {
+		bytes[i], bytes[len-i-1] = bytes[len-i-1], bytes[i]
+	}
+
+	return string(bytes)
+}
+
+// matchPathPattern reports whether the path matches the pattern in the same way as the BPF programs
+func matchPathPattern(pattern *varmor.PathPattern, path string) bool {
+	suffix := reverseString(pattern.Suffix)
+
+	switch {
+	case pattern.Flags&profilebuilder.PreciseMatch != 0:
+		return path == pattern.Prefix
+	case pattern.Flags&profilebuilder.GreedyMatch != 0:
+	default:
+		// The globbing * only matches the file name
+		path = filepath.Base(path)
+	}
+
+	if len(path) < len(pattern.Prefix)+len(suffix) {
+		return false
+	}
+	if pattern.Flags&profilebuilder.PrefixMatch != 0 && !strings.HasPrefix(path, pattern.Prefix) {
+		return false
+	}
+	if pattern.Flags&profilebuilder.SuffixMatch != 0 && !strings.HasSuffix(path, suffix) {
+		return false
+	}
+	return true
+}
+
+// matchFileRules returns the denied permissions and the rule ID of the first rule that denies the access
+func matchFileRules(rules []varmor.FileContent, regexRules []varmor.RegexFileContent, path string, permissions uint32) (uint32, string) {
+	for _, rule := range rules {
+		if denied := rule.Permissions & permissions; denied != 0 && matchPathPattern(&rule.Pattern, path) {
+			return denied, rule.RuleID
+		}
+	}
+
+	for _, rule := range regexRules {
+		denied := rule.Permissions & permissions
+		if denied == 0 {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + rule.Regex + ")$")
+		if err == nil && re.MatchString(path) {
+			return denied, rule.RuleID
+		}
+	}
+
+	return 0, ""
+}
+
+func filePermissionNames(permissions uint32) []string {
+	var names []string
+	if permissions&profilebuilder.AaMayRead != 0 {
+		names = append(names, "r")
+	}
+	if permissions&profilebuilder.AaMayWrite != 0 {
+		names = append(names, "w")
+	}
+	if permissions&profilebuilder.AaMayAppend != 0 {
+		names = append(names, "a")
+	}
+	return names
+}
+
+// SimulateBehaviors evaluates the behaviors recorded by the behavior modeling against the BPF profile,
+// and returns the ones that would have been denied. The profile names in behaviors.Profiles are regarded
+// as the processes of the container. The network behaviors are skipped since they don't carry the
+// addresses and ports which the BPF network rules are matched on.
+func SimulateBehaviors(bpfContent *varmor.BpfContent, behaviors *varmor.AppArmor) []DeniedBehavior {
+	var denied []DeniedBehavior
+
+	for _, file := range behaviors.Files {
+		var permissions uint32
+		for _, perm := range file.Permissions {
+			switch perm {
+			case "r":
+				permissions |= profilebuilder.AaMayRead
+			case "w":
+				permissions |= profilebuilder.AaMayWrite
+			case "a":
+				permissions |= profilebuilder.AaMayAppend
+			}
+		}
+
+		if d, ruleID := matchFileRules(bpfContent.Files, bpfContent.RegexFiles, file.Path, permissions); d != 0 {
+			denied = append(denied, DeniedBehavior{
+				Type:        "file",
+				Subject:     file.Path,
+				Permissions: filePermissionNames(d),
+				RuleID:      ruleID,
+			})
+		}
+	}
+
+	for _, exec := range behaviors.Executions {
+		if d, ruleID := matchFileRules(bpfContent.Processes, bpfContent.RegexFiles, exec, profilebuilder.AaMayExec); d != 0 {
+			denied = append(denied, DeniedBehavior{
+				Type:        "process",
+				Subject:     exec,
+				Permissions: []string{"x"},
+				RuleID:      ruleID,
+			})
+		}
+	}
+
+	for _, capability := range behaviors.Capabilities {
+		if n, ok := profilebuilder.CapabilityNumbers[capability]; ok && bpfContent.Capabilities&(1<<n) != 0 {
+			denied = append(denied, DeniedBehavior{
+				Type:    "capability",
+				Subject: capability,
+			})
+		}
+	}
+
+	if bpfContent.Ptrace != nil {
+		for _, ptrace := range behaviors.Ptraces {
+			// The PreciseMatch mode only denies the operations with the processes outside the container
+			if bpfContent.Ptrace.Flags&profilebuilder.GreedyMatch == 0 && varmorutils.InStringArray(ptrace.Peer, behaviors.Profiles) {
+				continue
+			}
+
+			var permissions []string
+			for _, perm := range ptrace.Permissions {
+				var mask uint32
+				switch perm {
+				case "trace":
+					mask = profilebuilder.AaPtraceTrace
+				case "read":
+					mask = profilebuilder.AaPtraceRead
+				case "tracedby":
+					mask = profilebuilder.AaMayBeTraced
+				case "readby":
+					mask = profilebuilder.AaMayBeRead
+				}
+				if bpfContent.Ptrace.Permissions&mask != 0 {
+					permissions = append(permissions, perm)
+				}
+			}
+
+			if len(permissions) != 0 {
+				denied = append(denied, DeniedBehavior{
+					Type:        "ptrace",
+					Subject:     ptrace.Peer,
+					Permissions: permissions,
+					RuleID:      bpfContent.Ptrace.RuleID,
+				})
+			}
+		}
+	}
+
+	return denied
+}
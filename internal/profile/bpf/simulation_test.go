@@ -0,0 +1,75 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+)
+
+func Test_SimulateBehaviors(t *testing.T) {
+	enhanceProtect := varmor.EnhanceProtect{
+		HardeningRules: []string{"disallow-write-core-pattern", "disable-cap-net-raw"},
+		BpfRawRules: varmor.BpfRawRules{
+			Processes: []varmor.FileRule{
+				{
+					Pattern:     "/**/ping",
+					Permissions: []string{"exec"},
+				},
+			},
+		},
+	}
+
+	var bpfContent varmor.BpfContent
+	err := profilebuilder.Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+
+	behaviors := varmor.AppArmor{
+		Profiles:   []string{"varmor-demo-demo"},
+		Executions: []string{"/bin/sh", "/usr/bin/ping"},
+		Files: []varmor.File{
+			{Path: "/proc/sys/kernel/core_pattern", Permissions: []string{"r", "w"}},
+			{Path: "/etc/hosts", Permissions: []string{"r"}},
+		},
+		Capabilities: []string{"net_raw", "chown"},
+		Ptraces: []varmor.Ptrace{
+			{Peer: "varmor-demo-demo", Permissions: []string{"read"}},
+		},
+	}
+
+	denied := SimulateBehaviors(&bpfContent, &behaviors)
+	assert.DeepEqual(t, denied, []DeniedBehavior{
+		{
+			Type:        "file",
+			Subject:     "/proc/sys/kernel/core_pattern",
+			Permissions: []string{"w"},
+			RuleID:      "hardeningRules/disallow-write-core-pattern",
+		},
+		{
+			Type:        "process",
+			Subject:     "/usr/bin/ping",
+			Permissions: []string{"x"},
+			RuleID:      "bpfRawRules.processes/0",
+		},
+		{
+			Type:    "capability",
+			Subject: "net_raw",
+		},
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// customRulePrefix is the prefix of the IDs of the custom rules
+const customRulePrefix = "bpfRawRules."
+
+// RuleHits are the hit counters of a rule
+type RuleHits struct {
+	// Hits is the number of the operations matched by the rule
+	Hits int64
+}
+
+// ruleIDs returns the sorted IDs of the rules in the BPF profile. The capability and ptrace rules aren't included
+// since they don't have the rule IDs of their own.
+func ruleIDs(bpfContent *varmor.BpfContent) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(ruleID string) {
+		if ruleID == "" || seen[ruleID] {
+			return
+		}
+		seen[ruleID] = true
+		ids = append(ids, ruleID)
+	}
+
+	for _, file := range bpfContent.Files {
+		add(file.RuleID)
+	}
+	for _, process := range bpfContent.Processes {
+		add(process.RuleID)
+	}
+	for _, network := range bpfContent.Networks {
+		add(network.RuleID)
+	}
+	for _, mount := range bpfContent.Mounts {
+		add(mount.RuleID)
+	}
+	for _, regexFile := range bpfContent.RegexFiles {
+		add(regexFile.RuleID)
+	}
+	for _, hashProcess := range bpfContent.HashProcesses {
+		add(hashProcess.RuleID)
+	}
+	for _, networkPeer := range bpfContent.NetworkPeers {
+		add(networkPeer.RuleID)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// removeRules removes the rules with the IDs from the BPF profile
+func removeRules(bpfContent *varmor.BpfContent, ruleIDs map[string]bool) {
+	files := bpfContent.Files[:0]
+	for _, file := range bpfContent.Files {
+		if !ruleIDs[file.RuleID] {
+			files = append(files, file)
+		}
+	}
+	bpfContent.Files = files
+
+	processes := bpfContent.Processes[:0]
+	for _, process := range bpfContent.Processes {
+		if !ruleIDs[process.RuleID] {
+			processes = append(processes, process)
+		}
+	}
+	bpfContent.Processes = processes
+
+	networks := bpfContent.Networks[:0]
+	for _, network := range bpfContent.Networks {
+		if !ruleIDs[network.RuleID] {
+			networks = append(networks, network)
+		}
+	}
+	bpfContent.Networks = networks
+
+	mounts := bpfContent.Mounts[:0]
+	for _, mount := range bpfContent.Mounts {
+		if !ruleIDs[mount.RuleID] {
+			mounts = append(mounts, mount)
+		}
+	}
+	bpfContent.Mounts = mounts
+
+	regexFiles := bpfContent.RegexFiles[:0]
+	for _, regexFile := range bpfContent.RegexFiles {
+		if !ruleIDs[regexFile.RuleID] {
+			regexFiles = append(regexFiles, regexFile)
+		}
+	}
+	bpfContent.RegexFiles = regexFiles
+
+	hashProcesses := bpfContent.HashProcesses[:0]
+	for _, hashProcess := range bpfContent.HashProcesses {
+		if !ruleIDs[hashProcess.RuleID] {
+			hashProcesses = append(hashProcesses, hashProcess)
+		}
+	}
+	bpfContent.HashProcesses = hashProcesses
+
+	networkPeers := bpfContent.NetworkPeers[:0]
+	for _, networkPeer := range bpfContent.NetworkPeers {
+		if !ruleIDs[networkPeer.RuleID] {
+			networkPeers = append(networkPeers, networkPeer)
+		}
+	}
+	bpfContent.NetworkPeers = networkPeers
+}
+
+// suggestionID returns the ID of the suggested BPF profile, it's the prefix of the SHA256 of its JSON. So the same
+// suggestion keeps its ID when it's generated again.
+func suggestionID(bpfContent *varmor.BpfContent) string {
+	data, _ := json.Marshal(bpfContent)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SuggestTightening suggests how to tighten the BPF profile with the hit counters of its rules. The custom rules
+// that never fired are suggested to be removed. It returns nil if there is nothing to suggest.
+func SuggestTightening(bpfContent *varmor.BpfContent, hits map[string]RuleHits) *varmor.TighteningSuggestion {
+	var suggestion varmor.TighteningSuggestion
+	removed := make(map[string]bool)
+	for _, ruleID := range ruleIDs(bpfContent) {
+		if hits[ruleID].Hits == 0 && strings.HasPrefix(ruleID, customRulePrefix) {
+			suggestion.Removals = append(suggestion.Removals, varmor.RuleSuggestion{RuleID: ruleID})
+			removed[ruleID] = true
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	content := bpfContent.DeepCopy()
+	removeRules(content, removed)
+
+	suggestion.ID = suggestionID(content)
+	suggestion.BpfContent = content
+	return &suggestion
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_SuggestTightening(t *testing.T) {
+	bpfContent := &varmor.BpfContent{
+		Files: []varmor.FileContent{
+			{RuleID: "hardeningRules/disallow-write-core-pattern"},
+			{RuleID: "bpfRawRules.files/0"},
+			{RuleID: "bpfRawRules.files/1"},
+		},
+		Networks: []varmor.NetworkContent{
+			{RuleID: "bpfRawRules.network.egresses/0", Port: 6443},
+			{RuleID: "bpfRawRules.network.egresses/1", Port: 2379},
+		},
+	}
+	hits := map[string]RuleHits{
+		"bpfRawRules.files/1":            {Hits: 3},
+		"bpfRawRules.network.egresses/0": {Hits: 12},
+	}
+
+	suggestion := SuggestTightening(bpfContent, hits)
+	assert.Assert(t, suggestion != nil)
+	// The built-in rules are expected to never fire
+	assert.DeepEqual(t, suggestion.Removals, []varmor.RuleSuggestion{
+		{RuleID: "bpfRawRules.files/0"},
+		{RuleID: "bpfRawRules.network.egresses/1"},
+	})
+
+	assert.Equal(t, len(suggestion.BpfContent.Files), 2)
+	assert.Equal(t, suggestion.BpfContent.Files[1].RuleID, "bpfRawRules.files/1")
+	assert.Equal(t, len(suggestion.BpfContent.Networks), 1)
+	// The original profile is unchanged
+	assert.Equal(t, len(bpfContent.Files), 3)
+	assert.Equal(t, len(bpfContent.Networks), 2)
+
+	// The ID only depends on the suggested profile
+	assert.Equal(t, SuggestTightening(bpfContent, hits).ID, suggestion.ID)
+
+	// Nothing to suggest once all the custom rules fired
+	hits["bpfRawRules.files/0"] = RuleHits{Hits: 1}
+	hits["bpfRawRules.network.egresses/1"] = RuleHits{Hits: 1}
+	assert.Assert(t, SuggestTightening(bpfContent, hits) == nil)
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func validatePathPattern(field string, pattern varmor.PathPattern) error {
+	if pattern.Flags == 0 {
+		return fmt.Errorf("%s.flags: the match flags are missing", field)
+	}
+	if len(pattern.Prefix) >= varmortypes.MaxFilePathPatternLength {
+		return fmt.Errorf("%s.prefix: the length of '%s' should be less than the maximum (%d), use a shorter pattern or a wildcard",
+			field, pattern.Prefix, varmortypes.MaxFilePathPatternLength)
+	}
+	if len(pattern.Suffix) >= varmortypes.MaxFilePathPatternLength {
+		return fmt.Errorf("%s.suffix: the length of '%s' should be less than the maximum (%d), use a shorter pattern or a wildcard",
+			field, pattern.Suffix, varmortypes.MaxFilePathPatternLength)
+	}
+	return nil
+}
+
+func validateNetworkContent(field string, network varmor.NetworkContent) error {
+	if network.Flags&profilebuilder.CidrMatch != 0 && strings.HasPrefix(network.CIDR, "@") {
+		if !varmortypes.IsNetworkMacro(network.CIDR) {
+			return fmt.Errorf("%s.cidr: '%s' is not a known macro, available macros: %s, %s, %s, %s", field, network.CIDR,
+				varmortypes.ClusterPodsMacro, varmortypes.ClusterServicesMacro, varmortypes.NodeLocalMacro, varmortypes.PrivateRangesMacro)
+		}
+	} else if network.Flags&profilebuilder.CidrMatch != 0 {
+		_, ipNet, err := net.ParseCIDR(network.CIDR)
+		if err != nil {
+			return fmt.Errorf("%s.cidr: '%s' is not a valid CIDR, e.g. 10.0.0.0/8 or 2001:db8::/32", field, network.CIDR)
+		}
+		if ip := net.ParseIP(network.Address); ip == nil || !ipNet.IP.Equal(ip) {
+			return fmt.Errorf("%s.address: '%s' should be the network address of the CIDR '%s'", field, network.Address, network.CIDR)
+		}
+	} else if network.Flags&profilebuilder.PreciseMatch != 0 {
+		if net.ParseIP(network.Address) == nil {
+			return fmt.Errorf("%s.address: '%s' is not a valid IP address", field, network.Address)
+		}
+	} else if network.Flags&profilebuilder.PortMatch == 0 {
+		return fmt.Errorf("%s.flags: at least one of the CIDR, the IP address and the port should be matched", field)
+	}
+
+	if network.Flags&profilebuilder.PortMatch != 0 && (network.Port == 0 || network.Port > 65535) {
+		return fmt.Errorf("%s.port: %d is not a valid port, it should be in the range of 1-65535", field, network.Port)
+	}
+	return nil
+}
+
+// ValidateBpfContent checks whether the rules of the BPF content can be loaded into the maps of the BPF enforcer,
+// so the invalid content is rejected with actionable messages instead of failing on the agents. The rule counts
+// aren't checked here, the rules that exceed the limits are dropped by the agents with a warning.
+func ValidateBpfContent(bpfContent *varmor.BpfContent) error {
+	if unknown := bpfContent.Capabilities >> (unix.CAP_LAST_CAP + 1); unknown != 0 {
+		return fmt.Errorf("capabilities: the bits 0x%x beyond the last capability (%d) are unknown", unknown<<(unix.CAP_LAST_CAP+1), unix.CAP_LAST_CAP)
+	}
+
+	for i, file := range bpfContent.Files {
+		if err := validatePathPattern(fmt.Sprintf("files[%d].pattern", i), file.Pattern); err != nil {
+			return err
+		}
+		if file.Permissions == 0 {
+			return fmt.Errorf("files[%d].permissions: the permissions are missing", i)
+		}
+	}
+
+	for i, process := range bpfContent.Processes {
+		if err := validatePathPattern(fmt.Sprintf("processes[%d].pattern", i), process.Pattern); err != nil {
+			return err
+		}
+	}
+
+	for i, hashProcess := range bpfContent.HashProcesses {
+		if _, err := profilebuilder.NewHashRule(hashProcess.Path, hashProcess.SHA256); err != nil {
+			return fmt.Errorf("hashProcesses[%d]: %v", i, err)
+		}
+		if len(hashProcess.SHA256) == 0 {
+			return fmt.Errorf("hashProcesses[%d].sha256: the SHA256 digests are missing", i)
+		}
+	}
+
+	for i, network := range bpfContent.Networks {
+		if err := validateNetworkContent(fmt.Sprintf("networks[%d]", i), network); err != nil {
+			return err
+		}
+	}
+
+	for i, peer := range bpfContent.NetworkPeers {
+		if err := validateNetworkPeerContent(fmt.Sprintf("networkPeers[%d]", i), peer); err != nil {
+			return err
+		}
+	}
+
+	for i, mount := range bpfContent.Mounts {
+		if err := validatePathPattern(fmt.Sprintf("mounts[%d].pattern", i), mount.Pattern); err != nil {
+			return err
+		}
+		if len(mount.Fstype) >= varmortypes.MaxFileSystemTypeLength {
+			return fmt.Errorf("mounts[%d].fstype: the length of '%s' should be less than the maximum (%d)", i, mount.Fstype, varmortypes.MaxFileSystemTypeLength)
+		}
+	}
+
+	for i, regexFile := range bpfContent.RegexFiles {
+		re, err := regexp.Compile(regexFile.Regex)
+		if err != nil {
+			return fmt.Errorf("regexFiles[%d].regex: '%s' is invalid: %v", i, regexFile.Regex, err)
+		}
+		if prefix, _ := re.LiteralPrefix(); !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("regexFiles[%d].regex: '%s' must start with an absolute directory", i, regexFile.Regex)
+		}
+	}
+
+	return nil
+}
+
+// ValidateCapabilityRules checks whether the capabilities of the disable-cap-* rules are known, so the misspelled
+// rules aren't ignored silently.
+func ValidateCapabilityRules(rules []string) error {
+	for _, rule := range rules {
+		rule = strings.ToLower(rule)
+		if !strings.HasPrefix(rule, "disable-cap-") {
+			continue
+		}
+
+		name := strings.TrimPrefix(rule, "disable-cap-")
+		if name == "all" || name == "privileged" {
+			continue
+		}
+		if _, ok := profilebuilder.CapabilityNumbers[strings.ReplaceAll(name, "-", "_")]; !ok {
+			return fmt.Errorf("hardeningRules: the capability of '%s' is unknown, the rule should be like disable-cap-sys-admin", rule)
+		}
+	}
+	return nil
+}
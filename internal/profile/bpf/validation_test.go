@@ -0,0 +1,163 @@
+// Copyright 2023 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+)
+
+func Test_ValidateBpfContentOfBuiltinRules(t *testing.T) {
+	enhanceProtect := varmor.EnhanceProtect{
+		HardeningRules: []string{"disallow-write-core-pattern", "disallow-mount", "disallow-umount", "disallow-insmod",
+			"disallow-load-ebpf", "disallow-access-procfs-root", "disallow-tamper-varmor", "disable-cap-privileged", "disallow-abuse-user-ns"},
+		VulMitigationRules: []string{"cgroups-lxcfs-escape-mitigation"},
+		AttackProtectionRules: []varmor.AttackProtectionRules{
+			{
+				Rules: []string{"mitigate-sa-leak", "mitigate-host-ip-leak", "disallow-metadata-service", "disable-shell"},
+			},
+		},
+		Privileged: true,
+	}
+
+	var bpfContent varmor.BpfContent
+	err := profilebuilder.Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+	assert.NilError(t, ValidateBpfContent(&bpfContent))
+}
+
+func Test_ValidateBpfContentOfRawRules(t *testing.T) {
+	enhanceProtect := varmor.EnhanceProtect{
+		BpfRawRules: varmor.BpfRawRules{
+			Network: varmor.NetworkRule{
+				Egresses: []varmor.NetworkEgressRule{
+					{IPBlock: "@private-ranges"},
+					{IPBlock: "@node-local", Port: 10250},
+				},
+			},
+		},
+	}
+
+	var bpfContent varmor.BpfContent
+	err := profilebuilder.Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+	assert.NilError(t, ValidateBpfContent(&bpfContent))
+}
+
+func Test_ValidateBpfContent(t *testing.T) {
+	testCases := []struct {
+		name          string
+		bpfContent    varmor.BpfContent
+		expectedError string
+	}{
+		{
+			name: "valid",
+			bpfContent: varmor.BpfContent{
+				Capabilities: 1 << 21,
+				Files: []varmor.FileContent{
+					{Permissions: profilebuilder.AaMayWrite, Pattern: varmor.PathPattern{Flags: profilebuilder.PreciseMatch | profilebuilder.PrefixMatch, Prefix: "/etc/"}},
+				},
+				Networks: []varmor.NetworkContent{
+					{Flags: profilebuilder.CidrMatch | profilebuilder.Ipv4Match, Address: "10.0.0.0", CIDR: "10.0.0.0/8"},
+					{Flags: profilebuilder.PortMatch, Port: 22},
+					{Flags: profilebuilder.CidrMatch | profilebuilder.PortMatch, CIDR: "@cluster-services", Port: 443},
+				},
+				RegexFiles: []varmor.RegexFileContent{
+					{Permissions: profilebuilder.AaMayRead, Regex: "/etc/[a-z]+"},
+				},
+			},
+		},
+		{
+			name:          "unknown capability",
+			bpfContent:    varmor.BpfContent{Capabilities: 1 << 63},
+			expectedError: "capabilities: the bits",
+		},
+		{
+			name: "long prefix",
+			bpfContent: varmor.BpfContent{
+				Files: []varmor.FileContent{
+					{Permissions: profilebuilder.AaMayWrite, Pattern: varmor.PathPattern{Flags: profilebuilder.PreciseMatch, Prefix: "/etc/"}},
+					{Permissions: profilebuilder.AaMayWrite, Pattern: varmor.PathPattern{Flags: profilebuilder.PreciseMatch, Prefix: "/var/lib/a/very/long/path/that/exceeds/the/limit/of/the/bpf/maps/"}},
+				},
+			},
+			expectedError: "files[1].pattern.prefix: the length",
+		},
+		{
+			name: "invalid cidr",
+			bpfContent: varmor.BpfContent{
+				Networks: []varmor.NetworkContent{
+					{Flags: profilebuilder.CidrMatch | profilebuilder.Ipv4Match, Address: "10.0.0.0", CIDR: "10.0.0.0/33"},
+				},
+			},
+			expectedError: "networks[0].cidr: '10.0.0.0/33' is not a valid CIDR",
+		},
+		{
+			name: "unknown macro",
+			bpfContent: varmor.BpfContent{
+				Networks: []varmor.NetworkContent{
+					{Flags: profilebuilder.CidrMatch, CIDR: "@cluster-nodes"},
+				},
+			},
+			expectedError: "networks[0].cidr: '@cluster-nodes' is not a known macro",
+		},
+		{
+			name: "invalid port",
+			bpfContent: varmor.BpfContent{
+				Networks: []varmor.NetworkContent{
+					{Flags: profilebuilder.PortMatch, Port: 70000},
+				},
+			},
+			expectedError: "networks[0].port: 70000 is not a valid port",
+		},
+		{
+			name: "long fstype",
+			bpfContent: varmor.BpfContent{
+				Mounts: []varmor.MountContent{
+					{Fstype: "averyveryverylongfstype", Pattern: varmor.PathPattern{Flags: profilebuilder.GreedyMatch}},
+				},
+			},
+			expectedError: "mounts[0].fstype: the length",
+		},
+		{
+			name: "relative regex",
+			bpfContent: varmor.BpfContent{
+				RegexFiles: []varmor.RegexFileContent{
+					{Permissions: profilebuilder.AaMayRead, Regex: "etc/.*"},
+				},
+			},
+			expectedError: "regexFiles[0].regex: 'etc/.*' must start with an absolute directory",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateBpfContent(&tc.bpfContent)
+			if tc.expectedError == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func Test_ValidateCapabilityRules(t *testing.T) {
+	assert.NilError(t, ValidateCapabilityRules([]string{"disable-cap-all", "disable-cap-sys-admin", "DISABLE-CAP-NET-RAW", "disallow-mount"}))
+	assert.ErrorContains(t, ValidateCapabilityRules([]string{"disable-cap-sys-admn"}), "the capability of 'disable-cap-sys-admn' is unknown")
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// BehaviorChanges are the behaviors of a category that were added and removed between two behavior models
+type BehaviorChanges struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ModelDiff is the difference between the behavior models of two releases of the workloads. The behaviors of
+// the files, ptrace and signals are compared with their permissions, so the new permissions of a path are added
+// even if the path was accessed before.
+//
+// Note:
+// The behavior model only records the families and types of the sockets, so the new egress destinations can't be
+// detected, only the new kinds of sockets.
+type ModelDiff struct {
+	From         string          `json:"from"`
+	To           string          `json:"to"`
+	Executions   BehaviorChanges `json:"executions"`
+	Files        BehaviorChanges `json:"files"`
+	Capabilities BehaviorChanges `json:"capabilities"`
+	Networks     BehaviorChanges `json:"networks"`
+	Ptraces      BehaviorChanges `json:"ptraces"`
+	Signals      BehaviorChanges `json:"signals"`
+	Syscalls     BehaviorChanges `json:"syscalls"`
+}
+
+// permissionSets maps the subjects of the behaviors to their permissions
+type permissionSets map[string]map[string]bool
+
+func (sets permissionSets) add(subject string, permissions []string) {
+	if sets[subject] == nil {
+		sets[subject] = make(map[string]bool)
+	}
+	for _, permission := range permissions {
+		sets[subject][permission] = true
+	}
+}
+
+// subtract returns the subjects of the sets that have the permissions that aren't in the other sets, along with
+// the permissions. The subject without any permission is returned if it isn't in the other sets.
+func (sets permissionSets) subtract(other permissionSets) []string {
+	var results []string
+	for subject, permissions := range sets {
+		otherPermissions, ok := other[subject]
+		var extra []string
+		for permission := range permissions {
+			if !otherPermissions[permission] {
+				extra = append(extra, permission)
+			}
+		}
+		switch {
+		case len(extra) != 0:
+			sort.Strings(extra)
+			results = append(results, fmt.Sprintf("%s (%s)", subject, strings.Join(extra, ",")))
+		case !ok:
+			results = append(results, subject)
+		}
+	}
+	sort.Strings(results)
+	return results
+}
+
+func diffPermissionSets(from, to permissionSets) BehaviorChanges {
+	return BehaviorChanges{Added: to.subtract(from), Removed: from.subtract(to)}
+}
+
+func diffStrings(from, to []string) BehaviorChanges {
+	fromSets := make(permissionSets)
+	for _, s := range from {
+		fromSets.add(s, nil)
+	}
+	toSets := make(permissionSets)
+	for _, s := range to {
+		toSets.add(s, nil)
+	}
+	return diffPermissionSets(fromSets, toSets)
+}
+
+func filePermissionSets(files []varmor.File) permissionSets {
+	sets := make(permissionSets)
+	for _, file := range files {
+		sets.add(file.Path, file.Permissions)
+	}
+	return sets
+}
+
+func ptracePermissionSets(ptraces []varmor.Ptrace) permissionSets {
+	sets := make(permissionSets)
+	for _, ptrace := range ptraces {
+		sets.add(ptrace.Peer, ptrace.Permissions)
+	}
+	return sets
+}
+
+func signalPermissionSets(signals []varmor.Signal) permissionSets {
+	sets := make(permissionSets)
+	for _, signal := range signals {
+		sets.add(signal.Peer, signal.Signals)
+	}
+	return sets
+}
+
+func networkStrings(networks []varmor.Network) []string {
+	var results []string
+	for _, network := range networks {
+		results = append(results, networkString(network))
+	}
+	return results
+}
+
+// DiffModelReports compares the behaviors of the report of release N with the ones of release N+1
+func DiffModelReports(from, to *ModelReport) *ModelDiff {
+	f := &from.Behaviors.AppArmor
+	t := &to.Behaviors.AppArmor
+
+	return &ModelDiff{
+		From:         from.Namespace + "/" + from.Name,
+		To:           to.Namespace + "/" + to.Name,
+		Executions:   diffStrings(f.Executions, t.Executions),
+		Files:        diffPermissionSets(filePermissionSets(f.Files), filePermissionSets(t.Files)),
+		Capabilities: diffStrings(f.Capabilities, t.Capabilities),
+		Networks:     diffStrings(networkStrings(f.Networks), networkStrings(t.Networks)),
+		Ptraces:      diffPermissionSets(ptracePermissionSets(f.Ptraces), ptracePermissionSets(t.Ptraces)),
+		Signals:      diffPermissionSets(signalPermissionSets(f.Signals), signalPermissionSets(t.Signals)),
+		Syscalls:     diffStrings(from.Behaviors.Seccomp.Syscall, to.Behaviors.Seccomp.Syscall),
+	}
+}
+
+func (diff *ModelDiff) categories() []struct {
+	name    string
+	changes *BehaviorChanges
+} {
+	return []struct {
+		name    string
+		changes *BehaviorChanges
+	}{
+		{"execution", &diff.Executions},
+		{"file", &diff.Files},
+		{"capability", &diff.Capabilities},
+		{"network", &diff.Networks},
+		{"ptrace", &diff.Ptraces},
+		{"signal", &diff.Signals},
+		{"syscall", &diff.Syscalls},
+	}
+}
+
+// HasNewBehaviors returns whether release N+1 has the behaviors that release N doesn't have, they are the
+// regressions that need to be reviewed before promoting the profile
+func (diff *ModelDiff) HasNewBehaviors() bool {
+	for _, category := range diff.categories() {
+		if len(category.changes.Added) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes the changes in the form of a unified diff, e.g. "+ file /tmp/cache (w)"
+func (diff *ModelDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", diff.From, diff.To)
+	for _, category := range diff.categories() {
+		for _, behavior := range category.changes.Added {
+			fmt.Fprintf(&b, "+ %s %s\n", category.name, behavior)
+		}
+		for _, behavior := range category.changes.Removed {
+			fmt.Fprintf(&b, "- %s %s\n", category.name, behavior)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export renders the completed behavior models and the profiles built with them into the reports that can
+// be attached to the security reports of CI, i.e. the JSON report of vArmor and the SARIF log.
+package export
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	bpfprofile "github.com/bytedance/vArmor/internal/profile/bpf"
+)
+
+// SchemaVersion is the version of the JSON report, it's changed when the report is changed incompatibly
+const SchemaVersion = "varmor.org/model-report/v1"
+
+// ProfileSummary is the profile built with the behavior model in readable form
+type ProfileSummary struct {
+	Name     string `json:"name"`
+	Enforcer string `json:"enforcer"`
+	Mode     string `json:"mode"`
+	// BpfRules are the rules of the BPF profile
+	BpfRules []bpfprofile.ReportRule `json:"bpfRules,omitempty"`
+	// AppArmor is the text of the AppArmor profile
+	AppArmor string `json:"apparmor,omitempty"`
+	// Seccomp is the Seccomp profile
+	Seccomp *specs.LinuxSeccomp `json:"seccomp,omitempty"`
+}
+
+// ModelReport is the JSON report of a behavior model. The behaviors and the rules are sorted, so the reports of
+// different releases can be compared programmatically.
+type ModelReport struct {
+	SchemaVersion string `json:"schemaVersion"`
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	// CompletedNodes and DesiredNodes are the counts of the nodes that completed the modeling and that should do it
+	CompletedNodes int `json:"completedNodes"`
+	DesiredNodes   int `json:"desiredNodes"`
+	// Behaviors are the behaviors of the target workloads collected during the modeling
+	Behaviors varmor.DynamicResult `json:"behaviors"`
+	// Profile is the profile built with the behavior model, it's empty if the profile hasn't been built
+	Profile ProfileSummary `json:"profile"`
+}
+
+// sortPermissions returns the sorted copy of the permissions
+func sortPermissions(permissions []string) []string {
+	sorted := append([]string{}, permissions...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// normalizeBehaviors returns the sorted copy of the behaviors, the order of the behaviors depends on the order of
+// the events collected on the nodes
+func normalizeBehaviors(result *varmor.DynamicResult) varmor.DynamicResult {
+	var behaviors varmor.DynamicResult
+	aa := &behaviors.AppArmor
+
+	aa.Profiles = sortPermissions(result.AppArmor.Profiles)
+	aa.Executions = sortPermissions(result.AppArmor.Executions)
+	aa.Capabilities = sortPermissions(result.AppArmor.Capabilities)
+	aa.Unhandled = sortPermissions(result.AppArmor.Unhandled)
+	behaviors.Seccomp.Syscall = sortPermissions(result.Seccomp.Syscall)
+
+	for _, file := range result.AppArmor.Files {
+		file.Permissions = sortPermissions(file.Permissions)
+		aa.Files = append(aa.Files, file)
+	}
+	sort.Slice(aa.Files, func(i, j int) bool {
+		if aa.Files[i].Path != aa.Files[j].Path {
+			return aa.Files[i].Path < aa.Files[j].Path
+		}
+		return aa.Files[i].OldPath < aa.Files[j].OldPath
+	})
+
+	aa.Networks = append(aa.Networks, result.AppArmor.Networks...)
+	sort.Slice(aa.Networks, func(i, j int) bool {
+		return networkString(aa.Networks[i]) < networkString(aa.Networks[j])
+	})
+
+	for _, ptrace := range result.AppArmor.Ptraces {
+		ptrace.Permissions = sortPermissions(ptrace.Permissions)
+		aa.Ptraces = append(aa.Ptraces, ptrace)
+	}
+	sort.Slice(aa.Ptraces, func(i, j int) bool { return aa.Ptraces[i].Peer < aa.Ptraces[j].Peer })
+
+	for _, signal := range result.AppArmor.Signals {
+		signal.Permissions = sortPermissions(signal.Permissions)
+		signal.Signals = sortPermissions(signal.Signals)
+		aa.Signals = append(aa.Signals, signal)
+	}
+	sort.Slice(aa.Signals, func(i, j int) bool { return aa.Signals[i].Peer < aa.Signals[j].Peer })
+
+	return behaviors
+}
+
+func networkString(network varmor.Network) string {
+	return fmt.Sprintf("%s %s %s", network.Family, network.SockType, network.Protocol)
+}
+
+// summarizeProfile decodes the profile built with the behavior model
+func summarizeProfile(profile *varmor.Profile) (ProfileSummary, error) {
+	summary := ProfileSummary{
+		Name:     profile.Name,
+		Enforcer: profile.Enforcer,
+		Mode:     profile.Mode,
+	}
+
+	if profile.BpfContent != nil {
+		summary.BpfRules = bpfprofile.GenerateReport(profile.BpfContent).Rules
+	}
+
+	if profile.Content != "" {
+		content, err := base64.StdEncoding.DecodeString(profile.Content)
+		if err != nil {
+			return summary, fmt.Errorf("failed to decode the AppArmor profile: %w", err)
+		}
+		summary.AppArmor = string(content)
+	}
+
+	if profile.SeccompContent != "" {
+		content, err := base64.StdEncoding.DecodeString(profile.SeccompContent)
+		if err != nil {
+			return summary, fmt.Errorf("failed to decode the Seccomp profile: %w", err)
+		}
+		var seccomp specs.LinuxSeccomp
+		err = json.Unmarshal(content, &seccomp)
+		if err != nil {
+			return summary, fmt.Errorf("failed to parse the Seccomp profile: %w", err)
+		}
+		summary.Seccomp = &seccomp
+	}
+
+	return summary, nil
+}
+
+// NewModelReport renders the behavior model of the ArmorProfileModel object into the JSON report
+func NewModelReport(apm *varmor.ArmorProfileModel) (*ModelReport, error) {
+	profile, err := summarizeProfile(&apm.Data.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ModelReport{
+		SchemaVersion:  SchemaVersion,
+		Namespace:      apm.Namespace,
+		Name:           apm.Name,
+		CompletedNodes: apm.Status.CompletedNumber,
+		DesiredNodes:   apm.Status.DesiredNumber,
+		Behaviors:      normalizeBehaviors(&apm.Data.DynamicResult),
+		Profile:        profile,
+	}, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func newArmorProfileModel() *varmor.ArmorProfileModel {
+	return &varmor.ArmorProfileModel{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "demo", Name: "varmor-demo-test"},
+		Data: varmor.ArmorProfileModelData{
+			DynamicResult: varmor.DynamicResult{
+				AppArmor: varmor.AppArmor{
+					Executions:   []string{"/usr/bin/sh", "/usr/bin/curl"},
+					Capabilities: []string{"net_raw", "chown"},
+					Files: []varmor.File{
+						{Path: "/tmp/b", Permissions: []string{"w", "r"}},
+						{Path: "/etc/hosts", Permissions: []string{"r"}},
+					},
+					Networks: []varmor.Network{
+						{Family: "inet", SockType: "stream", Protocol: "tcp"},
+						{Family: "inet", SockType: "dgram", Protocol: "udp"},
+					},
+				},
+				Seccomp: varmor.Seccomp{Syscall: []string{"write", "read"}},
+			},
+			Profile: varmor.Profile{
+				Name:           "varmor-demo-test",
+				Enforcer:       "BPFSeccomp",
+				Mode:           "enforce",
+				BpfContent:     &varmor.BpfContent{Capabilities: 1 << 13},
+				SeccompContent: base64.StdEncoding.EncodeToString([]byte(`{"defaultAction":"SCMP_ACT_ERRNO","syscalls":[{"names":["read","write"],"action":"SCMP_ACT_ALLOW"}]}`)),
+			},
+		},
+		Status: varmor.ArmorProfileModelStatus{DesiredNumber: 2, CompletedNumber: 2, Ready: true},
+	}
+}
+
+func Test_NewModelReport(t *testing.T) {
+	report, err := NewModelReport(newArmorProfileModel())
+	assert.NilError(t, err)
+
+	assert.Equal(t, report.SchemaVersion, SchemaVersion)
+	assert.Equal(t, report.CompletedNodes, 2)
+	assert.DeepEqual(t, report.Behaviors.AppArmor.Executions, []string{"/usr/bin/curl", "/usr/bin/sh"})
+	assert.DeepEqual(t, report.Behaviors.AppArmor.Capabilities, []string{"chown", "net_raw"})
+	assert.DeepEqual(t, report.Behaviors.AppArmor.Files, []varmor.File{
+		{Path: "/etc/hosts", Permissions: []string{"r"}},
+		{Path: "/tmp/b", Permissions: []string{"r", "w"}},
+	})
+	assert.Equal(t, report.Behaviors.AppArmor.Networks[0].Protocol, "udp")
+	assert.DeepEqual(t, report.Behaviors.Seccomp.Syscall, []string{"read", "write"})
+
+	assert.Equal(t, len(report.Profile.BpfRules), 1)
+	assert.Equal(t, report.Profile.BpfRules[0].Subject, "net_raw")
+	assert.Equal(t, string(report.Profile.Seccomp.DefaultAction), "SCMP_ACT_ERRNO")
+
+	// The behavior model is unchanged
+	assert.DeepEqual(t, newArmorProfileModel().Data.DynamicResult.AppArmor.Executions, []string{"/usr/bin/sh", "/usr/bin/curl"})
+
+	apm := newArmorProfileModel()
+	apm.Data.Profile.SeccompContent = "invalid"
+	_, err = NewModelReport(apm)
+	assert.Assert(t, err != nil)
+}
+
+func Test_WriteSARIF(t *testing.T) {
+	report, err := NewModelReport(newArmorProfileModel())
+	assert.NilError(t, err)
+
+	var buf bytes.Buffer
+	assert.NilError(t, report.WriteSARIF(&buf))
+
+	var log sarifLog
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, log.Version, "2.1.0")
+	assert.Equal(t, len(log.Runs), 1)
+
+	counts := make(map[string]int)
+	for _, result := range log.Runs[0].Results {
+		counts[result.RuleID]++
+		assert.Equal(t, result.Locations[0].LogicalLocations[0].FullyQualifiedName, "demo/varmor-demo-test")
+		assert.Equal(t, len(result.PartialFingerprints[fingerprintKey]), 64)
+	}
+	assert.DeepEqual(t, counts, map[string]int{
+		"behavior/execution":  2,
+		"behavior/file":       2,
+		"behavior/capability": 2,
+		"behavior/network":    2,
+		"behavior/syscall":    2,
+		"profile/bpf":         1,
+		"profile/seccomp":     1,
+	})
+
+	// The fingerprints are stable regardless of the order of the behaviors
+	apm := newArmorProfileModel()
+	apm.Data.DynamicResult.AppArmor.Executions = []string{"/usr/bin/curl", "/usr/bin/sh"}
+	another, err := NewModelReport(apm)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, another.sarifResults(), report.sarifResults())
+}
+
+func Test_DiffModelReports(t *testing.T) {
+	from, err := NewModelReport(newArmorProfileModel())
+	assert.NilError(t, err)
+
+	apm := newArmorProfileModel()
+	apm.Name = "varmor-demo-test-v2"
+	aa := &apm.Data.DynamicResult.AppArmor
+	aa.Executions = []string{"/usr/bin/sh", "/usr/bin/wget"}
+	aa.Files = []varmor.File{
+		{Path: "/tmp/b", Permissions: []string{"r"}},
+		{Path: "/tmp/b", Permissions: []string{"w", "a"}},
+		{Path: "/etc/hosts", Permissions: []string{"r"}},
+		{Path: "/root/.ssh/id_rsa", Permissions: []string{"r"}},
+	}
+	aa.Networks = append(aa.Networks, varmor.Network{Family: "inet6", SockType: "stream", Protocol: "tcp"})
+	apm.Data.DynamicResult.Seccomp.Syscall = []string{"read", "write", "ptrace"}
+	to, err := NewModelReport(apm)
+	assert.NilError(t, err)
+
+	diff := DiffModelReports(from, to)
+	assert.Equal(t, diff.To, "demo/varmor-demo-test-v2")
+	assert.DeepEqual(t, diff.Executions, BehaviorChanges{Added: []string{"/usr/bin/wget"}, Removed: []string{"/usr/bin/curl"}})
+	assert.DeepEqual(t, diff.Files, BehaviorChanges{Added: []string{"/root/.ssh/id_rsa (r)", "/tmp/b (a)"}})
+	assert.DeepEqual(t, diff.Networks, BehaviorChanges{Added: []string{"inet6 stream tcp"}})
+	assert.DeepEqual(t, diff.Syscalls, BehaviorChanges{Added: []string{"ptrace"}})
+	assert.DeepEqual(t, diff.Capabilities, BehaviorChanges{})
+	assert.Equal(t, diff.HasNewBehaviors(), true)
+
+	var buf bytes.Buffer
+	assert.NilError(t, diff.WriteText(&buf))
+	assert.Equal(t, buf.String(), `--- demo/varmor-demo-test
++++ demo/varmor-demo-test-v2
++ execution /usr/bin/wget
+- execution /usr/bin/curl
++ file /root/.ssh/id_rsa (r)
++ file /tmp/b (a)
++ network inet6 stream tcp
++ syscall ptrace
+`)
+
+	// The removed behaviors are the new ones of the reversed diff
+	assert.DeepEqual(t, DiffModelReports(to, from).Executions.Added, []string{"/usr/bin/curl"})
+	assert.Equal(t, DiffModelReports(from, from).HasNewBehaviors(), false)
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// fingerprintKey is the key of the partial fingerprints of the results, they are stable across the releases
+	// as long as the behavior or the rule is unchanged
+	fingerprintKey = "varmorFingerprint/v1"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifRules are the rules of the results, the behaviors are reported with the "behavior/" rules, and the rules
+// of the profile are reported with the "profile/" rules
+var sarifRules = []sarifRule{
+	{ID: "behavior/execution", ShortDescription: sarifMessage{Text: "The executable was run by the target workloads during the modeling."}},
+	{ID: "behavior/file", ShortDescription: sarifMessage{Text: "The file was accessed by the target workloads during the modeling."}},
+	{ID: "behavior/capability", ShortDescription: sarifMessage{Text: "The capability was used by the target workloads during the modeling."}},
+	{ID: "behavior/network", ShortDescription: sarifMessage{Text: "The socket was created by the target workloads during the modeling."}},
+	{ID: "behavior/ptrace", ShortDescription: sarifMessage{Text: "The ptrace operation was performed by the target workloads during the modeling."}},
+	{ID: "behavior/signal", ShortDescription: sarifMessage{Text: "The signal was sent by the target workloads during the modeling."}},
+	{ID: "behavior/syscall", ShortDescription: sarifMessage{Text: "The syscall was invoked by the target workloads during the modeling."}},
+	{ID: "profile/bpf", ShortDescription: sarifMessage{Text: "The rule of the BPF profile built with the behavior model."}},
+	{ID: "profile/apparmor", ShortDescription: sarifMessage{Text: "The AppArmor profile built with the behavior model."}},
+	{ID: "profile/seccomp", ShortDescription: sarifMessage{Text: "The Seccomp profile built with the behavior model."}},
+}
+
+func (report *ModelReport) newResult(ruleID string, text string) sarifResult {
+	name := report.Namespace + "/" + report.Name
+	digest := sha256.Sum256([]byte(ruleID + "\x00" + text))
+	return sarifResult{
+		RuleID:  ruleID,
+		Level:   "note",
+		Message: sarifMessage{Text: text},
+		Locations: []sarifLocation{{
+			LogicalLocations: []sarifLogicalLocation{{Name: report.Name, FullyQualifiedName: name, Kind: "object"}},
+		}},
+		PartialFingerprints: map[string]string{fingerprintKey: hex.EncodeToString(digest[:])},
+	}
+}
+
+// sarifResults converts the behaviors and the rules of the profile into the results
+func (report *ModelReport) sarifResults() []sarifResult {
+	results := []sarifResult{}
+	aa := &report.Behaviors.AppArmor
+
+	for _, execution := range aa.Executions {
+		results = append(results, report.newResult("behavior/execution", "execute "+execution))
+	}
+	for _, file := range aa.Files {
+		text := fmt.Sprintf("access %s (%s)", file.Path, strings.Join(file.Permissions, ","))
+		if file.OldPath != "" {
+			text += " renamed from " + file.OldPath
+		}
+		results = append(results, report.newResult("behavior/file", text))
+	}
+	for _, capability := range aa.Capabilities {
+		results = append(results, report.newResult("behavior/capability", "use the capability "+capability))
+	}
+	for _, network := range aa.Networks {
+		results = append(results, report.newResult("behavior/network", "create the socket "+networkString(network)))
+	}
+	for _, ptrace := range aa.Ptraces {
+		text := fmt.Sprintf("ptrace %s (%s)", ptrace.Peer, strings.Join(ptrace.Permissions, ","))
+		results = append(results, report.newResult("behavior/ptrace", text))
+	}
+	for _, signal := range aa.Signals {
+		text := fmt.Sprintf("send the signals %s to %s (%s)", strings.Join(signal.Signals, ","), signal.Peer, strings.Join(signal.Permissions, ","))
+		results = append(results, report.newResult("behavior/signal", text))
+	}
+	for _, syscall := range report.Behaviors.Seccomp.Syscall {
+		results = append(results, report.newResult("behavior/syscall", "invoke the syscall "+syscall))
+	}
+
+	for _, rule := range report.Profile.BpfRules {
+		results = append(results, report.newResult("profile/bpf", rule.String()))
+	}
+	if report.Profile.AppArmor != "" {
+		digest := sha256.Sum256([]byte(report.Profile.AppArmor))
+		text := fmt.Sprintf("the AppArmor profile %s (sha256: %s)", report.Profile.Name, hex.EncodeToString(digest[:]))
+		results = append(results, report.newResult("profile/apparmor", text))
+	}
+	if seccomp := report.Profile.Seccomp; seccomp != nil {
+		count := 0
+		for _, syscall := range seccomp.Syscalls {
+			count += len(syscall.Names)
+		}
+		text := fmt.Sprintf("the Seccomp profile %s (default action: %s, syscalls with the specific actions: %d)", report.Profile.Name, seccomp.DefaultAction, count)
+		results = append(results, report.newResult("profile/seccomp", text))
+	}
+
+	return results
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log. Each behavior and each rule of the profile is a result with
+// the "note" level, and the partial fingerprints of the results identify them across the releases.
+func (report *ModelReport) WriteSARIF(w io.Writer) error {
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "vArmor",
+				InformationURI: "https://github.com/bytedance/vArmor",
+				Rules:          sarifRules,
+			}},
+			Results: report.sarifResults(),
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&log)
+}
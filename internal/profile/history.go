@@ -0,0 +1,158 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	bpfprofile "github.com/bytedance/vArmor/internal/profile/bpf"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned/typed/varmor/v1beta1"
+)
+
+// MaxProfileHistory is the max count of the generations kept in ArmorProfile/status
+const MaxProfileHistory = 5
+
+// NewProfileRevision summarizes the profile of the ArmorProfile object
+func NewProfileRevision(ap *varmor.ArmorProfile) (*varmor.ProfileRevision, error) {
+	content, err := json.Marshal(ap.Spec.Profile)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+
+	revision := varmor.ProfileRevision{
+		Generation: ap.Generation,
+		Hash:       hex.EncodeToString(sum[:]),
+		Timestamp:  metav1.Now(),
+		Mode:       ap.Spec.Profile.Mode,
+	}
+	if ap.Spec.Profile.BpfContent != nil {
+		report := bpfprofile.GenerateReport(ap.Spec.Profile.BpfContent)
+		for i := range report.Rules {
+			revision.Rules = append(revision.Rules, report.Rules[i].String())
+		}
+	}
+	return &revision, nil
+}
+
+// AppendProfileHistory appends the revision to the history and drops the oldest ones beyond the limit. The
+// revision is skipped if the profile didn't change.
+func AppendProfileHistory(history []varmor.ProfileRevision, revision *varmor.ProfileRevision) []varmor.ProfileRevision {
+	if len(history) != 0 && history[len(history)-1].Hash == revision.Hash {
+		return history
+	}
+	history = append(history, *revision)
+	if len(history) > MaxProfileHistory {
+		history = history[len(history)-MaxProfileHistory:]
+	}
+	return history
+}
+
+// RecordProfileHistory saves the current profile of the ArmorProfile object in ArmorProfile/status
+func RecordProfileHistory(varmorInterface varmorinterface.CrdV1beta1Interface, namespace, name string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry,
+		func() error {
+			ap, err := varmorInterface.ArmorProfiles(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			revision, err := NewProfileRevision(ap)
+			if err != nil {
+				return err
+			}
+			if n := len(ap.Status.History); n != 0 && ap.Status.History[n-1].Hash == revision.Hash {
+				return nil
+			}
+
+			ap.Status.History = AppendProfileHistory(ap.Status.History, revision)
+			_, err = varmorInterface.ArmorProfiles(namespace).UpdateStatus(context.Background(), ap, metav1.UpdateOptions{})
+			return err
+		})
+}
+
+// DiffProfileRevisions returns the rules added and removed from the revision to another one. The generations
+// are looked up in the history, zero means the latest one for to and the one before to for from.
+func DiffProfileRevisions(history []varmor.ProfileRevision, fromGeneration, toGeneration int64) (*varmortypes.ProfileDiff, error) {
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no history of the profile")
+	}
+
+	to := len(history) - 1
+	if toGeneration != 0 {
+		to = findRevision(history, toGeneration)
+		if to == -1 {
+			return nil, fmt.Errorf("the generation %d isn't found in the history", toGeneration)
+		}
+	}
+
+	from := to - 1
+	if fromGeneration != 0 {
+		from = findRevision(history, fromGeneration)
+		if from == -1 {
+			return nil, fmt.Errorf("the generation %d isn't found in the history", fromGeneration)
+		}
+	}
+
+	diff := varmortypes.ProfileDiff{
+		ToGeneration: history[to].Generation,
+		ToHash:       history[to].Hash,
+	}
+	var fromRules []string
+	if from >= 0 {
+		diff.FromGeneration = history[from].Generation
+		diff.FromHash = history[from].Hash
+		fromRules = history[from].Rules
+	}
+	diff.Added = subtractRules(history[to].Rules, fromRules)
+	diff.Removed = subtractRules(fromRules, history[to].Rules)
+	return &diff, nil
+}
+
+func findRevision(history []varmor.ProfileRevision, generation int64) int {
+	for i := range history {
+		if history[i].Generation == generation {
+			return i
+		}
+	}
+	return -1
+}
+
+// subtractRules returns the sorted rules that are in a but not in b
+func subtractRules(a, b []string) []string {
+	exist := make(map[string]bool, len(b))
+	for _, rule := range b {
+		exist[rule] = true
+	}
+
+	var rules []string
+	for _, rule := range a {
+		if !exist[rule] {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Strings(rules)
+	return rules
+}
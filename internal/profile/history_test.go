@@ -0,0 +1,61 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_DiffProfileRevisions(t *testing.T) {
+	var history []varmor.ProfileRevision
+	for i := 1; i <= MaxProfileHistory+2; i++ {
+		history = AppendProfileHistory(history, &varmor.ProfileRevision{
+			Generation: int64(i),
+			Hash:       fmt.Sprintf("%d", i),
+			Rules:      []string{"capability sys_admin", fmt.Sprintf("file /tmp/%d r", i)},
+		})
+	}
+	assert.Equal(t, len(history), MaxProfileHistory)
+	assert.Equal(t, history[0].Generation, int64(3))
+
+	// The unchanged profile is skipped
+	history = AppendProfileHistory(history, &varmor.ProfileRevision{Generation: 8, Hash: "7"})
+	assert.Equal(t, history[len(history)-1].Generation, int64(7))
+
+	diff, err := DiffProfileRevisions(history, 0, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, diff.FromGeneration, int64(6))
+	assert.Equal(t, diff.ToGeneration, int64(7))
+	assert.DeepEqual(t, diff.Added, []string{"file /tmp/7 r"})
+	assert.DeepEqual(t, diff.Removed, []string{"file /tmp/6 r"})
+
+	diff, err = DiffProfileRevisions(history, 3, 5)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, diff.Added, []string{"file /tmp/5 r"})
+	assert.DeepEqual(t, diff.Removed, []string{"file /tmp/3 r"})
+
+	// The first generation is compared with an empty profile
+	diff, err = DiffProfileRevisions(history[:1], 0, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(diff.Added), 2)
+
+	_, err = DiffProfileRevisions(history, 1, 0)
+	assert.ErrorContains(t, err, "isn't found")
+}
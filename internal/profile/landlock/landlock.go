@@ -0,0 +1,165 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package landlock generates the rulesets of the Landlock enforcer from the file rules of the policies
+package landlock
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorlandlock "github.com/bytedance/vArmor/pkg/lsm/landlock"
+)
+
+const (
+	// fileWriteAccess is used for the write permission of the files
+	fileWriteAccess = unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	// dirWriteAccess is used for the write permission of the directories, it also covers creating and removing
+	// the entries of them
+	dirWriteAccess = fileWriteAccess |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+)
+
+// GenerateAlwaysAllowProfile generates a Landlock profile that allows everything
+func GenerateAlwaysAllowProfile() string {
+	content, _ := varmorlandlock.EncodeRuleset(&varmorlandlock.Ruleset{})
+	return content
+}
+
+// parsePattern converts the pattern of the file rule into the path of the Landlock rule. Landlock restricts the
+// file hierarchies, so only the absolute paths and the directories ending with "/**" are supported.
+func parsePattern(pattern string) (string, bool, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return "", false, fmt.Errorf("the pattern '%s' must be an absolute path for the Landlock enforcer", pattern)
+	}
+
+	dir := false
+	path := pattern
+	if strings.HasSuffix(pattern, "/**") {
+		dir = true
+		path = strings.TrimSuffix(pattern, "**")
+	}
+
+	if strings.ContainsAny(path, "*?[]{}") {
+		return "", false, fmt.Errorf("the pattern '%s' can't be expressed by the Landlock enforcer, only the absolute paths and the directories ending with '/**' are supported", pattern)
+	}
+	return filepath.Clean(path), dir, nil
+}
+
+func generateFileRule(rule varmor.FileRule, ruleset *varmorlandlock.Ruleset, process bool) error {
+	if rule.Regex || len(rule.SHA256) != 0 {
+		return fmt.Errorf("the regex and sha256 of the rule '%s' aren't supported by the Landlock enforcer", rule.Pattern)
+	}
+
+	path, dir, err := parsePattern(rule.Pattern)
+	if err != nil {
+		return err
+	}
+
+	var access uint64
+	for _, permission := range rule.Permissions {
+		switch strings.ToLower(permission) {
+		case "read", "r":
+			if !process {
+				access |= unix.LANDLOCK_ACCESS_FS_READ_FILE
+				if dir {
+					access |= unix.LANDLOCK_ACCESS_FS_READ_DIR
+				}
+			}
+		case "write", "w":
+			if !process {
+				access |= fileWriteAccess
+				if dir {
+					access |= dirWriteAccess
+				}
+			}
+		case "append", "a":
+			if !process {
+				access |= unix.LANDLOCK_ACCESS_FS_WRITE_FILE
+			}
+		case "exec", "x":
+			if process {
+				access |= unix.LANDLOCK_ACCESS_FS_EXECUTE
+			}
+		}
+	}
+
+	if access != 0 {
+		ruleset.Rules = append(ruleset.Rules, varmorlandlock.Rule{Path: path, Access: access})
+	}
+	return nil
+}
+
+// GenerateEnhanceProtectProfile generates the Landlock profile from the file and process rules of bpfRawRules,
+// the blocking rules of fileIntegrityRules and the read-only filesystem of the policy. The other rules aren't
+// supported by the Landlock enforcer.
+func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect) (string, error) {
+	var ruleset varmorlandlock.Ruleset
+
+	if enhanceProtect.Privileged {
+		return GenerateAlwaysAllowProfile(), nil
+	}
+
+	for _, rule := range enhanceProtect.BpfRawRules.Files {
+		err := generateFileRule(rule, &ruleset, false)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, rule := range enhanceProtect.BpfRawRules.Processes {
+		err := generateFileRule(rule, &ruleset, true)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, rule := range enhanceProtect.FileIntegrityRules {
+		if !rule.Block {
+			continue
+		}
+		for _, path := range rule.Paths {
+			access := uint64(fileWriteAccess)
+			if strings.HasSuffix(path, "/") {
+				access = dirWriteAccess
+			}
+			ruleset.Rules = append(ruleset.Rules, varmorlandlock.Rule{Path: filepath.Clean(path), Access: access})
+		}
+	}
+
+	if enhanceProtect.ReadOnlyFilesystem.Enable {
+		ruleset.Rules = append(ruleset.Rules, varmorlandlock.Rule{Path: "/", Access: dirWriteAccess})
+		for _, path := range append([]string{"/dev/", "/proc/"}, enhanceProtect.ReadOnlyFilesystem.WritablePaths...) {
+			access := uint64(fileWriteAccess)
+			if strings.HasSuffix(path, "/") {
+				access = dirWriteAccess
+			}
+			ruleset.Exceptions = append(ruleset.Exceptions, varmorlandlock.Rule{Path: filepath.Clean(path), Access: access})
+		}
+	}
+
+	return varmorlandlock.EncodeRuleset(&ruleset)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landlock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorlandlock "github.com/bytedance/vArmor/pkg/lsm/landlock"
+)
+
+func decodeRuleset(t *testing.T, content string) varmorlandlock.Ruleset {
+	c, err := base64.StdEncoding.DecodeString(content)
+	assert.NilError(t, err)
+
+	var ruleset varmorlandlock.Ruleset
+	assert.NilError(t, json.Unmarshal(c, &ruleset))
+	return ruleset
+}
+
+func Test_GenerateEnhanceProtectProfile(t *testing.T) {
+	testCases := []struct {
+		name           string
+		enhanceProtect varmor.EnhanceProtect
+		expectedRules  []varmorlandlock.Rule
+		expectedErr    bool
+	}{
+		{
+			name: "file and process rules",
+			enhanceProtect: varmor.EnhanceProtect{
+				BpfRawRules: varmor.BpfRawRules{
+					Files: []varmor.FileRule{
+						{Pattern: "/etc/shadow", Permissions: []string{"read", "write"}},
+						{Pattern: "/root/.ssh/**", Permissions: []string{"read"}},
+					},
+					Processes: []varmor.FileRule{
+						{Pattern: "/usr/bin/curl", Permissions: []string{"exec"}},
+					},
+				},
+			},
+			expectedRules: []varmorlandlock.Rule{
+				{Path: "/etc/shadow", Access: unix.LANDLOCK_ACCESS_FS_READ_FILE | fileWriteAccess},
+				{Path: "/root/.ssh", Access: unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR},
+				{Path: "/usr/bin/curl", Access: unix.LANDLOCK_ACCESS_FS_EXECUTE},
+			},
+		},
+		{
+			name: "blocking file integrity rules",
+			enhanceProtect: varmor.EnhanceProtect{
+				FileIntegrityRules: []varmor.FileIntegrityRule{
+					{Paths: []string{"/etc/passwd", "/etc/cron.d/"}, Block: true},
+					{Paths: []string{"/etc/hosts"}},
+				},
+			},
+			expectedRules: []varmorlandlock.Rule{
+				{Path: "/etc/passwd", Access: fileWriteAccess},
+				{Path: "/etc/cron.d", Access: dirWriteAccess},
+			},
+		},
+		{
+			name: "globbing in the middle",
+			enhanceProtect: varmor.EnhanceProtect{
+				BpfRawRules: varmor.BpfRawRules{
+					Files: []varmor.FileRule{{Pattern: "/etc/*/shadow", Permissions: []string{"read"}}},
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "regex",
+			enhanceProtect: varmor.EnhanceProtect{
+				BpfRawRules: varmor.BpfRawRules{
+					Files: []varmor.FileRule{{Pattern: "/etc/cron\\.d/.*", Regex: true, Permissions: []string{"write"}}},
+				},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, err := GenerateEnhanceProtectProfile(&tc.enhanceProtect)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, decodeRuleset(t, content).Rules, tc.expectedRules)
+		})
+	}
+}
+
+func Test_GenerateEnhanceProtectProfileWithReadOnlyFilesystem(t *testing.T) {
+	content, err := GenerateEnhanceProtectProfile(&varmor.EnhanceProtect{
+		ReadOnlyFilesystem: varmor.ReadOnlyFilesystem{Enable: true, WritablePaths: []string{"/tmp/", "/var/run/app.pid"}},
+	})
+	assert.NilError(t, err)
+
+	ruleset := decodeRuleset(t, content)
+	assert.DeepEqual(t, ruleset.Rules, []varmorlandlock.Rule{{Path: "/", Access: dirWriteAccess}})
+	assert.DeepEqual(t, ruleset.Exceptions, []varmorlandlock.Rule{
+		{Path: "/dev", Access: dirWriteAccess},
+		{Path: "/proc", Access: dirWriteAccess},
+		{Path: "/tmp", Access: dirWriteAccess},
+		{Path: "/var/run/app.pid", Access: fileWriteAccess},
+	})
+}
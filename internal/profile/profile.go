@@ -22,12 +22,17 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/internal/artifact"
 	varmorconfig "github.com/bytedance/vArmor/internal/config"
 	apparmorprofile "github.com/bytedance/vArmor/internal/profile/apparmor"
 	bpfprofile "github.com/bytedance/vArmor/internal/profile/bpf"
+	landlockprofile "github.com/bytedance/vArmor/internal/profile/landlock"
 	seccompprofile "github.com/bytedance/vArmor/internal/profile/seccomp"
+	selinuxprofile "github.com/bytedance/vArmor/internal/profile/selinux"
 	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
 	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned/typed/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
 )
 
 // profileNameTemplate is the name of ArmorProfile object in k8s and AppArmor profile in host machine.
@@ -51,6 +56,41 @@ func GenerateArmorProfileName(ns string, name string, clusterScope bool) string
 	return strings.ToLower(profileName)
 }
 
+// selfDefenseRule is the built-in rule added to the profiles of the AppArmor and BPF enforcers when the self-defense
+// is enabled
+const selfDefenseRule = "disallow-tamper-varmor"
+
+// enhanceProtectForEnforcer returns the EnhanceProtect settings for the enforcer, in which the built-in rules
+// dedicated to the enforcer are merged with the shared ones. The selfDefenseRule is also added if it's enabled.
+func enhanceProtectForEnforcer(enhanceProtect *varmor.EnhanceProtect, e varmortypes.Enforcer) *varmor.EnhanceProtect {
+	var rules *varmor.BuiltInRules
+	selfDefense := false
+	switch e {
+	case varmortypes.AppArmor:
+		rules = enhanceProtect.AppArmorRules
+		selfDefense = varmorconfig.EnableSelfDefense
+	case varmortypes.BPF:
+		rules = enhanceProtect.BpfRules
+		selfDefense = varmorconfig.EnableSelfDefense
+	case varmortypes.Seccomp:
+		rules = enhanceProtect.SeccompRules
+	}
+	if rules == nil && !selfDefense {
+		return enhanceProtect
+	}
+
+	ep := enhanceProtect.DeepCopy()
+	if rules != nil {
+		ep.HardeningRules = append(ep.HardeningRules, rules.HardeningRules...)
+		ep.AttackProtectionRules = append(ep.AttackProtectionRules, rules.AttackProtectionRules...)
+		ep.VulMitigationRules = append(ep.VulMitigationRules, rules.VulMitigationRules...)
+	}
+	if selfDefense && !varmorutils.InStringArray(selfDefenseRule, ep.HardeningRules) {
+		ep.HardeningRules = append(ep.HardeningRules, selfDefenseRule)
+	}
+	return ep
+}
+
 func GenerateProfile(policy varmor.Policy, name string, namespace string, varmorInterface varmorinterface.CrdV1beta1Interface, complete bool) (*varmor.Profile, error) {
 	var err error
 
@@ -62,6 +102,21 @@ func GenerateProfile(policy varmor.Policy, name string, namespace string, varmor
 
 	e := varmortypes.GetEnforcerType(policy.Enforcer)
 
+	err = validateBestAvailableMode(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateLandlockMode(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateSELinuxMode(policy)
+	if err != nil {
+		return nil, err
+	}
+
 	switch policy.Mode {
 	case varmortypes.AlwaysAllowMode:
 		if e == varmortypes.Unknown {
@@ -76,6 +131,14 @@ func GenerateProfile(policy varmor.Policy, name string, namespace string, varmor
 			var bpfContent varmor.BpfContent
 			profile.BpfContent = &bpfContent
 		}
+		// Landlock
+		if (e & varmortypes.Landlock) != 0 {
+			profile.LandlockContent = landlockprofile.GenerateAlwaysAllowProfile()
+		}
+		// SELinux
+		if (e & varmortypes.SELinux) != 0 {
+			profile.SELinuxContent = selinuxprofile.GenerateAlwaysAllowProfile(name)
+		}
 
 	case varmortypes.RuntimeDefaultMode:
 		if e == varmortypes.Unknown {
@@ -88,12 +151,20 @@ func GenerateProfile(policy varmor.Policy, name string, namespace string, varmor
 		// BPF
 		if (e & varmortypes.BPF) != 0 {
 			var bpfContent varmor.BpfContent
-			err = bpfprofile.GenerateRuntimeDefaultProfile(&bpfContent)
+			err = profilebuilder.GenerateRuntimeDefaultProfile(&bpfContent)
 			if err != nil {
 				return nil, err
 			}
 			profile.BpfContent = &bpfContent
 		}
+		// Landlock
+		if (e & varmortypes.Landlock) != 0 {
+			profile.LandlockContent = landlockprofile.GenerateAlwaysAllowProfile()
+		}
+		// SELinux
+		if (e & varmortypes.SELinux) != 0 {
+			profile.SELinuxContent = selinuxprofile.GenerateRuntimeDefaultProfile(name)
+		}
 
 	case varmortypes.EnhanceProtectMode:
 		if e == varmortypes.Unknown {
@@ -101,20 +172,35 @@ func GenerateProfile(policy varmor.Policy, name string, namespace string, varmor
 		}
 		// AppArmor
 		if (e & varmortypes.AppArmor) != 0 {
-			profile.Content = apparmorprofile.GenerateEnhanceProtectProfile(&policy.EnhanceProtect, name)
+			profile.Content = apparmorprofile.GenerateEnhanceProtectProfile(enhanceProtectForEnforcer(&policy.EnhanceProtect, varmortypes.AppArmor), name)
 		}
 		// BPF
 		if (e & varmortypes.BPF) != 0 {
 			var bpfContent varmor.BpfContent
-			err = bpfprofile.GenerateEnhanceProtectProfile(&policy.EnhanceProtect, &bpfContent)
+			err = profilebuilder.Build(enhanceProtectForEnforcer(&policy.EnhanceProtect, varmortypes.BPF), &bpfContent)
 			if err != nil {
 				return nil, err
 			}
+			bpfprofile.SetNetworkPeerNamespace(&bpfContent, namespace)
 			profile.BpfContent = &bpfContent
 		}
+		// Landlock
+		if (e & varmortypes.Landlock) != 0 {
+			profile.LandlockContent, err = landlockprofile.GenerateEnhanceProtectProfile(&policy.EnhanceProtect)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// SELinux
+		if (e & varmortypes.SELinux) != 0 {
+			profile.SELinuxContent, err = selinuxprofile.GenerateEnhanceProtectProfile(&policy.EnhanceProtect, name)
+			if err != nil {
+				return nil, err
+			}
+		}
 		// Seccomp
 		if (e & varmortypes.Seccomp) != 0 {
-			profile.SeccompContent, err = seccompprofile.GenerateEnhanceProtectProfile(&policy.EnhanceProtect, name)
+			profile.SeccompContent, err = seccompprofile.GenerateEnhanceProtectProfile(enhanceProtectForEnforcer(&policy.EnhanceProtect, varmortypes.Seccomp), name)
 			if err != nil {
 				return nil, err
 			}
@@ -148,23 +234,41 @@ func GenerateProfile(policy varmor.Policy, name string, namespace string, varmor
 		if e == varmortypes.Unknown {
 			return nil, fmt.Errorf("unknown enforcer")
 		}
+		apm, err := varmorInterface.ArmorProfileModels(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			apm = &varmor.ArmorProfileModel{}
+		} else if varmorconfig.ProfileVerificationKey != nil && artifact.IsImportedProfile(apm) {
+			// Reject the imported profile if it's unsigned or tampered, so it can't be used to loosen the enforcement.
+			// The profile built by the behavior modeling isn't signed, it's trusted.
+			err = artifact.VerifyProfile(&apm.Data.Profile, apm.Annotations[artifact.ProfileSignatureAnnotation], varmorconfig.ProfileVerificationKey)
+			if err != nil {
+				return nil, fmt.Errorf("fatal error: failed to verify the profile of ArmorProfileModel: %v", err)
+			}
+		}
 		// BPF
 		if (e & varmortypes.BPF) != 0 {
-			return nil, fmt.Errorf("fatal error: not supported by the enforcer")
+			// The BPF profile can only be imported into the ArmorProfileModel object, since the BPF enforcer
+			// doesn't support the behavior modeling.
+			if apm.Data.Profile.BpfContent != nil {
+				profile.BpfContent = apm.Data.Profile.BpfContent.DeepCopy()
+			} else {
+				return nil, fmt.Errorf("fatal error: no existing BPF profile found")
+			}
 		}
 		// AppArmor
 		if (e & varmortypes.AppArmor) != 0 {
-			apm, err := varmorInterface.ArmorProfileModels(namespace).Get(context.Background(), name, metav1.GetOptions{})
-			if err == nil && apm.Data.Profile.Content != "" {
+			if apm.Data.Profile.Content != "" {
 				profile.Content = apm.Data.Profile.Content
+				if policy.DefenseInDepthOptions.ComplainMode {
+					profile.Mode = "complain"
+				}
 			} else {
 				return nil, fmt.Errorf("fatal error: no existing AppArmor model found")
 			}
 		}
 		// Seccomp
 		if (e & varmortypes.Seccomp) != 0 {
-			apm, err := varmorInterface.ArmorProfileModels(namespace).Get(context.Background(), name, metav1.GetOptions{})
-			if err == nil && apm.Data.Profile.SeccompContent != "" {
+			if apm.Data.Profile.SeccompContent != "" {
 				profile.SeccompContent = apm.Data.Profile.SeccompContent
 			} else {
 				return nil, fmt.Errorf("fatal error: no existing Seccomp model found")
@@ -175,9 +279,309 @@ func GenerateProfile(policy varmor.Policy, name string, namespace string, varmor
 		return nil, fmt.Errorf("unknown mode")
 	}
 
+	// The Seccomp profile is referenced by the target containers on every node when the BestAvailable enforcer
+	// is used, so generate one that allows all syscalls if the rules don't need it.
+	if (e&varmortypes.BestAvailable) != 0 && profile.SeccompContent == "" {
+		profile.SeccompContent = seccompprofile.GenerateAlwaysAllowProfile()
+	}
+
 	return &profile, nil
 }
 
+// validateBestAvailableMode checks whether the mode of the policy is supported by the BestAvailable enforcer.
+// The profile variants of the enforcers must be generated from the same rules, so the modes that rely on the
+// behavior modeling aren't supported.
+func validateBestAvailableMode(policy varmor.Policy) error {
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e & varmortypes.BestAvailable) == 0 {
+		return nil
+	}
+
+	switch policy.Mode {
+	case varmortypes.AlwaysAllowMode, varmortypes.RuntimeDefaultMode, varmortypes.EnhanceProtectMode:
+		return nil
+	default:
+		return fmt.Errorf("the BestAvailable enforcer doesn't support the %s mode", policy.Mode)
+	}
+}
+
+// validateLandlockMode checks whether the mode of the policy is supported by the Landlock enforcer. The Landlock
+// enforcer can't observe the behaviors of the containers, so the modes that rely on the behavior modeling aren't
+// supported.
+func validateLandlockMode(policy varmor.Policy) error {
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e & varmortypes.Landlock) == 0 {
+		return nil
+	}
+
+	switch policy.Mode {
+	case varmortypes.AlwaysAllowMode, varmortypes.RuntimeDefaultMode, varmortypes.EnhanceProtectMode:
+		return nil
+	default:
+		return fmt.Errorf("the Landlock enforcer doesn't support the %s mode", policy.Mode)
+	}
+}
+
+// ValidateLandlockProfile builds the Landlock profile of the policy to check whether its file rules can be
+// expressed by the Landlock enforcer.
+func ValidateLandlockProfile(policy varmor.Policy) error {
+	err := validateLandlockMode(policy)
+	if err != nil {
+		return err
+	}
+
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e&varmortypes.Landlock) == 0 || policy.Mode != varmortypes.EnhanceProtectMode {
+		return nil
+	}
+
+	err = validateLandlockBuiltInRules(&policy.EnhanceProtect, e)
+	if err != nil {
+		return err
+	}
+
+	_, err = landlockprofile.GenerateEnhanceProtectProfile(&policy.EnhanceProtect)
+	return err
+}
+
+// validateLandlockBuiltInRules rejects the built-in rules that none of the enforcers of the Landlock policy
+// implements, so they aren't ignored silently. The Landlock enforcer doesn't implement any built-in rule, and
+// the Seccomp enforcer only implements several hardening rules.
+func validateLandlockBuiltInRules(enhanceProtect *varmor.EnhanceProtect, e varmortypes.Enforcer) error {
+	for _, rule := range enhanceProtect.HardeningRules {
+		if (e&varmortypes.Seccomp) == 0 || !seccompprofile.SupportsHardeningRule(rule) {
+			return fmt.Errorf("the hardening rule '%s' isn't supported by the Landlock enforcer", rule)
+		}
+	}
+	if len(enhanceProtect.AttackProtectionRules) != 0 {
+		return fmt.Errorf("the attackProtectionRules aren't supported by the Landlock enforcer")
+	}
+	if len(enhanceProtect.VulMitigationRules) != 0 {
+		return fmt.Errorf("the vulMitigationRules aren't supported by the Landlock enforcer")
+	}
+	return nil
+}
+
+// validateSELinuxMode checks whether the mode of the policy is supported by the SELinux enforcer. The behavior
+// modeling isn't implemented with the SELinux enforcer yet.
+func validateSELinuxMode(policy varmor.Policy) error {
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e & varmortypes.SELinux) == 0 {
+		return nil
+	}
+
+	switch policy.Mode {
+	case varmortypes.AlwaysAllowMode, varmortypes.RuntimeDefaultMode, varmortypes.EnhanceProtectMode:
+		return nil
+	default:
+		return fmt.Errorf("the SELinux enforcer doesn't support the %s mode", policy.Mode)
+	}
+}
+
+// ValidateSELinuxProfile builds the SELinux profile of the policy to check whether its built-in rules can be
+// enforced by the SELinux enforcer, and whether the raw rules are well-formed.
+func ValidateSELinuxProfile(policy varmor.Policy) error {
+	err := validateSELinuxMode(policy)
+	if err != nil {
+		return err
+	}
+
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e&varmortypes.SELinux) == 0 || policy.Mode != varmortypes.EnhanceProtectMode {
+		return nil
+	}
+
+	_, err = selinuxprofile.GenerateEnhanceProtectProfile(&policy.EnhanceProtect, "")
+	return err
+}
+
+// ValidateBpfProfile builds the BPF profile of the policy to check whether it can be applied by the BPF enforcer,
+// e.g. the custom rules are well-formed and the count of rules doesn't exceed the limits.
+func ValidateBpfProfile(policy varmor.Policy) error {
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e & varmortypes.BPF) == 0 {
+		return nil
+	}
+
+	if policy.Mode != varmortypes.EnhanceProtectMode {
+		return nil
+	}
+
+	err := bpfprofile.ValidateCapabilityRules(policy.EnhanceProtect.HardeningRules)
+	if err != nil {
+		return err
+	}
+
+	// The BPF program doesn't support the read-only filesystem, so reject it instead of ignoring it silently
+	if policy.EnhanceProtect.ReadOnlyFilesystem.Enable {
+		return fmt.Errorf("the readOnlyFilesystem isn't supported by the BPF enforcer")
+	}
+
+	var bpfContent varmor.BpfContent
+	err = profilebuilder.Build(enhanceProtectForEnforcer(&policy.EnhanceProtect, varmortypes.BPF), &bpfContent)
+	if err != nil {
+		return err
+	}
+	return bpfprofile.ValidateBpfContent(&bpfContent)
+}
+
+// ValidateClusterNetworkPeers checks whether the namespaces of the Services and Pods referenced by the network rules
+// of the VarmorClusterPolicy are specified, since there is no namespace to default to.
+func ValidateClusterNetworkPeers(policy varmor.Policy) error {
+	for i, rule := range policy.EnhanceProtect.BpfRawRules.Network.Egresses {
+		if (rule.Service != nil && rule.Service.Namespace == "") || (rule.Pods != nil && rule.Pods.Namespace == "") {
+			return fmt.Errorf("bpfRawRules.network.egresses[%d]: the namespace of the service or the pods is required by the cluster policy", i)
+		}
+	}
+	return nil
+}
+
+// ValidateEnforcerRules checks whether the built-in rules dedicated to the enforcers are only specified for the
+// enforcers used by the policy, and whether the mode is supported by the BestAvailable enforcer.
+func ValidateEnforcerRules(policy varmor.Policy) error {
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+
+	err := validateBestAvailableMode(policy)
+	if err != nil {
+		return err
+	}
+
+	if policy.Mode != varmortypes.EnhanceProtectMode {
+		return nil
+	}
+
+	if policy.EnhanceProtect.AppArmorRules != nil && (e&varmortypes.AppArmor) == 0 {
+		return fmt.Errorf("the appArmorRules are specified, but the AppArmor enforcer isn't used")
+	}
+	if policy.EnhanceProtect.BpfRules != nil && (e&varmortypes.BPF) == 0 {
+		return fmt.Errorf("the bpfRules are specified, but the BPF enforcer isn't used")
+	}
+	if policy.EnhanceProtect.SeccompRules != nil && (e&varmortypes.Seccomp) == 0 {
+		return fmt.Errorf("the seccompRules are specified, but the Seccomp enforcer isn't used")
+	}
+	return nil
+}
+
+// ValidateAppArmorProfile checks whether the abstractions and the raw snippets of the policy can be embedded into
+// the AppArmor profile.
+func ValidateAppArmorProfile(policy varmor.Policy) error {
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e&varmortypes.AppArmor) == 0 || policy.Mode != varmortypes.EnhanceProtectMode {
+		return nil
+	}
+
+	for _, abstraction := range policy.EnhanceProtect.AppArmorAbstractions {
+		err := apparmorprofile.ValidateAbstraction(abstraction)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, snippet := range policy.EnhanceProtect.AppArmorRawSnippets {
+		err := apparmorprofile.ValidateRawSnippet(snippet)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SimulatePolicy builds the BPF profile of the candidate policy, and reports which of the behaviors recorded
+// by the behavior modeling would have been denied by it. It's used to tune the policy offline before enforcing.
+func SimulatePolicy(policy varmor.Policy, behaviors *varmor.DynamicResult) ([]bpfprofile.DeniedBehavior, error) {
+	e := varmortypes.GetEnforcerType(policy.Enforcer)
+	if (e & varmortypes.BPF) == 0 {
+		return nil, fmt.Errorf("only the policy of the BPF enforcer can be simulated")
+	}
+
+	switch policy.Mode {
+	case varmortypes.AlwaysAllowMode, varmortypes.RuntimeDefaultMode, varmortypes.EnhanceProtectMode:
+	default:
+		return nil, fmt.Errorf("the %s mode can't be simulated", policy.Mode)
+	}
+
+	profile, err := GenerateProfile(policy, "", "", nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return bpfprofile.SimulateBehaviors(profile.BpfContent, &behaviors.AppArmor), nil
+}
+
+// GenerateBehaviorModeling builds the behavior modeling settings of ArmorProfile with the modeling options
+func GenerateBehaviorModeling(options varmor.ModelingOptions) (*varmor.BehaviorModeling, error) {
+	if options.Duration == 0 {
+		return nil, fmt.Errorf("invalid parameter: .Spec.Policy.ModelingOptions.Duration == 0")
+	}
+
+	switch options.PathGeneralization {
+	case "", varmortypes.PathGeneralizationDisabled, varmortypes.PathGeneralizationConservative, varmortypes.PathGeneralizationAggressive:
+	default:
+		return nil, fmt.Errorf("invalid parameter: unknown path generalization level %s", options.PathGeneralization)
+	}
+
+	return &varmor.BehaviorModeling{
+		Enable:             true,
+		Duration:           options.Duration,
+		PathGeneralization: options.PathGeneralization,
+	}, nil
+}
+
+// GenerateDriftDetection builds the drift detection settings of ArmorProfile with the executables
+// learned by the ArmorProfileModel object of the policy.
+func GenerateDriftDetection(options varmor.DriftDetectionOptions, name string, namespace string, varmorInterface varmorinterface.CrdV1beta1Interface) (*varmor.DriftDetection, error) {
+	var driftDetection varmor.DriftDetection
+
+	if !options.Enable {
+		return &driftDetection, nil
+	}
+
+	switch options.Action {
+	case "", varmortypes.DriftAuditAction:
+		driftDetection.Action = varmortypes.DriftAuditAction
+	case varmortypes.DriftDenyAction:
+		driftDetection.Action = varmortypes.DriftDenyAction
+	default:
+		return nil, fmt.Errorf("invalid parameter: unknown drift detection action %s", options.Action)
+	}
+
+	apm, err := varmorInterface.ArmorProfileModels(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil || len(apm.Data.DynamicResult.AppArmor.Executions) == 0 {
+		return nil, fmt.Errorf("fatal error: no existing behavior model found for drift detection")
+	}
+
+	driftDetection.Enable = true
+	driftDetection.Executables = make([]string, len(apm.Data.DynamicResult.AppArmor.Executions))
+	copy(driftDetection.Executables, apm.Data.DynamicResult.AppArmor.Executions)
+
+	return &driftDetection, nil
+}
+
+// GenerateFileIntegrity collects the critical paths that need to be monitored by the agents.
+func GenerateFileIntegrity(policy varmor.Policy) *varmor.FileIntegrity {
+	var fileIntegrity varmor.FileIntegrity
+
+	if policy.Mode != varmortypes.EnhanceProtectMode {
+		return &fileIntegrity
+	}
+
+	for _, rule := range policy.EnhanceProtect.FileIntegrityRules {
+		for _, path := range rule.Paths {
+			if !varmorutils.InStringArray(path, fileIntegrity.Paths) {
+				fileIntegrity.Paths = append(fileIntegrity.Paths, path)
+			}
+		}
+	}
+
+	return &fileIntegrity
+}
+
+// InheritNetworkPeerAddresses keeps the resolved addresses of the network peers which are still referenced by the
+// new profile, until the manager resolves them again.
+func InheritNetworkPeerAddresses(newProfile *varmor.Profile, oldApSpec *varmor.ArmorProfileSpec) {
+	bpfprofile.InheritNetworkPeerAddresses(newProfile.BpfContent, oldApSpec.Profile.BpfContent)
+}
+
 func NewArmorProfile(obj interface{}, varmorInterface varmorinterface.CrdV1beta1Interface, clusterScope bool) (*varmor.ArmorProfile, error) {
 	ap := varmor.ArmorProfile{}
 
@@ -196,13 +600,24 @@ func NewArmorProfile(obj interface{}, varmorInterface varmorinterface.CrdV1beta1
 		ap.Spec.Profile = *profile
 		ap.Spec.Target = *vcp.Spec.Target.DeepCopy()
 		ap.Spec.UpdateExistingWorkloads = vcp.Spec.UpdateExistingWorkloads
+		ap.Spec.NodeSelector = vcp.Spec.NodeSelector
+
+		if vcp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode && vcp.Spec.Policy.DriftDetectionOptions.Enable {
+			return &ap, fmt.Errorf("invalid parameter: drift detection is not supported by the BehaviorModeling mode")
+		}
+		driftDetection, err := GenerateDriftDetection(vcp.Spec.Policy.DriftDetectionOptions, ap.Name, ap.Namespace, varmorInterface)
+		if err != nil {
+			return nil, err
+		}
+		ap.Spec.DriftDetection = *driftDetection
+		ap.Spec.FileIntegrity = *GenerateFileIntegrity(vcp.Spec.Policy)
 
 		if vcp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode {
-			if vcp.Spec.Policy.ModelingOptions.Duration == 0 {
-				return &ap, fmt.Errorf("invalid parameter: .Spec.Policy.ModelingOptions.Duration == 0")
+			behaviorModeling, err := GenerateBehaviorModeling(vcp.Spec.Policy.ModelingOptions)
+			if err != nil {
+				return &ap, err
 			}
-			ap.Spec.BehaviorModeling.Enable = true
-			ap.Spec.BehaviorModeling.Duration = vcp.Spec.Policy.ModelingOptions.Duration
+			ap.Spec.BehaviorModeling = *behaviorModeling
 		}
 
 	} else {
@@ -220,13 +635,24 @@ func NewArmorProfile(obj interface{}, varmorInterface varmorinterface.CrdV1beta1
 		ap.Spec.Profile = *profile
 		ap.Spec.Target = *vp.Spec.Target.DeepCopy()
 		ap.Spec.UpdateExistingWorkloads = vp.Spec.UpdateExistingWorkloads
+		ap.Spec.NodeSelector = vp.Spec.NodeSelector
+
+		if vp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode && vp.Spec.Policy.DriftDetectionOptions.Enable {
+			return &ap, fmt.Errorf("invalid parameter: drift detection is not supported by the BehaviorModeling mode")
+		}
+		driftDetection, err := GenerateDriftDetection(vp.Spec.Policy.DriftDetectionOptions, ap.Name, ap.Namespace, varmorInterface)
+		if err != nil {
+			return nil, err
+		}
+		ap.Spec.DriftDetection = *driftDetection
+		ap.Spec.FileIntegrity = *GenerateFileIntegrity(vp.Spec.Policy)
 
 		if vp.Spec.Policy.Mode == varmortypes.BehaviorModelingMode {
-			if vp.Spec.Policy.ModelingOptions.Duration == 0 {
-				return &ap, fmt.Errorf("invalid parameter: .Spec.Policy.ModelingOptions.Duration == 0")
+			behaviorModeling, err := GenerateBehaviorModeling(vp.Spec.Policy.ModelingOptions)
+			if err != nil {
+				return &ap, err
 			}
-			ap.Spec.BehaviorModeling.Enable = true
-			ap.Spec.BehaviorModeling.Duration = vp.Spec.Policy.ModelingOptions.Duration
+			ap.Spec.BehaviorModeling = *behaviorModeling
 		}
 	}
 
@@ -0,0 +1,135 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_ValidateBpfProfile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      varmor.Policy
+		expectedErr bool
+	}{
+		{
+			name: "file rules",
+			policy: varmor.Policy{
+				Enforcer: "BPF",
+				Mode:     "EnhanceProtect",
+				EnhanceProtect: varmor.EnhanceProtect{
+					BpfRawRules: varmor.BpfRawRules{
+						Files: []varmor.FileRule{{Pattern: "/etc/shadow", Permissions: []string{"read"}}},
+					},
+				},
+			},
+		},
+		{
+			name: "sandbox containers",
+			policy: varmor.Policy{
+				Enforcer:                 "BPF",
+				Mode:                     "AlwaysAllow",
+				ConfineSandboxContainers: true,
+			},
+		},
+		{
+			name: "read-only filesystem",
+			policy: varmor.Policy{
+				Enforcer: "BPF",
+				Mode:     "EnhanceProtect",
+				EnhanceProtect: varmor.EnhanceProtect{
+					ReadOnlyFilesystem: varmor.ReadOnlyFilesystem{Enable: true, WritablePaths: []string{"/tmp/"}},
+				},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateBpfProfile(tc.policy)
+			assert.Equal(t, err != nil, tc.expectedErr, "%v", err)
+		})
+	}
+}
+
+func Test_ValidateLandlockProfile(t *testing.T) {
+	testCases := []struct {
+		name           string
+		enforcer       string
+		enhanceProtect varmor.EnhanceProtect
+		expectedErr    string
+	}{
+		{
+			name:     "file rules",
+			enforcer: "Landlock",
+			enhanceProtect: varmor.EnhanceProtect{
+				BpfRawRules: varmor.BpfRawRules{
+					Files: []varmor.FileRule{{Pattern: "/etc/shadow", Permissions: []string{"read"}}},
+				},
+			},
+		},
+		{
+			name:           "hardening rules",
+			enforcer:       "Landlock",
+			enhanceProtect: varmor.EnhanceProtect{HardeningRules: []string{"disallow-mount"}},
+			expectedErr:    "the hardening rule 'disallow-mount' isn't supported by the Landlock enforcer",
+		},
+		{
+			name:           "hardening rules implemented by the Seccomp enforcer",
+			enforcer:       "LandlockSeccomp",
+			enhanceProtect: varmor.EnhanceProtect{HardeningRules: []string{"disallow-mount", "disable_cap_net_raw"}},
+		},
+		{
+			name:           "hardening rules unimplemented by the Seccomp enforcer",
+			enforcer:       "LandlockSeccomp",
+			enhanceProtect: varmor.EnhanceProtect{HardeningRules: []string{"disallow-mount", "disable-cap-all"}},
+			expectedErr:    "the hardening rule 'disable-cap-all' isn't supported by the Landlock enforcer",
+		},
+		{
+			name:     "attack protection rules",
+			enforcer: "LandlockSeccomp",
+			enhanceProtect: varmor.EnhanceProtect{
+				AttackProtectionRules: []varmor.AttackProtectionRules{{Rules: []string{"disable-write-etc"}}},
+			},
+			expectedErr: "the attackProtectionRules aren't supported by the Landlock enforcer",
+		},
+		{
+			name:           "vulnerability mitigation rules",
+			enforcer:       "Landlock",
+			enhanceProtect: varmor.EnhanceProtect{VulMitigationRules: []string{"cgroups-lxcfs-escape-mitigation"}},
+			expectedErr:    "the vulMitigationRules aren't supported by the Landlock enforcer",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateLandlockProfile(varmor.Policy{
+				Enforcer:       tc.enforcer,
+				Mode:           "EnhanceProtect",
+				EnhanceProtect: tc.enhanceProtect,
+			})
+			if tc.expectedErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -17,11 +17,15 @@ package seccomp
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"golang.org/x/sys/unix"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	varmorseccomp "github.com/bytedance/vArmor/pkg/seccomp"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
@@ -49,6 +53,16 @@ func GenerateBehaviorModelingProfile() string {
 	return base64.StdEncoding.EncodeToString(p)
 }
 
+// GenerateAlwaysAllowProfile generates a Seccomp profile that allows all syscalls
+func GenerateAlwaysAllowProfile() string {
+	profile := specs.LinuxSeccomp{
+		DefaultAction: specs.ActAllow,
+	}
+
+	p, _ := json.Marshal(profile)
+	return base64.StdEncoding.EncodeToString(p)
+}
+
 func GenerateProfileWithBehaviorModel(dynamicResult *varmor.DynamicResult) (string, error) {
 	if len(dynamicResult.Seccomp.Syscall) == 0 {
 		return "", nil
@@ -71,6 +85,76 @@ func GenerateProfileWithBehaviorModel(dynamicResult *varmor.DynamicResult) (stri
 	return base64.StdEncoding.EncodeToString(p), nil
 }
 
+// errnoRule denies the syscalls with EPERM if all the argument conditions are met
+func errnoRule(names []string, args ...specs.LinuxSeccompArg) specs.LinuxSyscall {
+	return specs.LinuxSyscall{
+		Names:  names,
+		Action: specs.ActErrno,
+		Args:   args,
+	}
+}
+
+// generateHardeningRules generates the syscall rules of the built-in hardening rule. The argument conditions are
+// used to only deny the dangerous usage of the syscalls. Note that the argument indexes follow the x86_64 and
+// arm64 ABIs.
+func generateHardeningRules(rule string) []specs.LinuxSyscall {
+	switch rule {
+	// disallow creating user namespace
+	case "disallow-create-user-ns":
+		enosys := uint(unix.ENOSYS)
+		return []specs.LinuxSyscall{
+			errnoRule([]string{"unshare"},
+				specs.LinuxSeccompArg{Index: 0, Value: unix.CLONE_NEWUSER, ValueTwo: unix.CLONE_NEWUSER, Op: specs.OpMaskedEqual}),
+			errnoRule([]string{"clone"},
+				specs.LinuxSeccompArg{Index: 0, Value: unix.CLONE_NEWUSER, ValueTwo: unix.CLONE_NEWUSER, Op: specs.OpMaskedEqual}),
+			// The flags of clone3 are passed in a struct which can't be inspected by seccomp. Return ENOSYS
+			// to make the libc fall back to clone.
+			{
+				Names:    []string{"clone3"},
+				Action:   specs.ActErrno,
+				ErrnoRet: &enosys,
+			},
+		}
+	// disallow load ebpf program
+	case "disallow-load-ebpf":
+		return []specs.LinuxSyscall{
+			errnoRule([]string{"bpf"},
+				specs.LinuxSeccompArg{Index: 0, Value: unix.BPF_PROG_LOAD, Op: specs.OpEqualTo}),
+		}
+	// disallow insmod
+	case "disallow-insmod":
+		return []specs.LinuxSyscall{
+			errnoRule([]string{"init_module", "finit_module"}),
+		}
+	// disallow mount
+	case "disallow-mount":
+		return []specs.LinuxSyscall{
+			errnoRule([]string{"mount", "fsopen", "fsmount", "move_mount", "open_tree"}),
+		}
+	// disallow umount
+	case "disallow-umount":
+		return []specs.LinuxSyscall{
+			errnoRule([]string{"umount", "umount2"}),
+		}
+	// disable creating raw and packet sockets
+	case "disable-cap-net-raw":
+		return []specs.LinuxSyscall{
+			errnoRule([]string{"socket"},
+				specs.LinuxSeccompArg{Index: 1, Value: 0xf, ValueTwo: unix.SOCK_RAW, Op: specs.OpMaskedEqual}),
+			errnoRule([]string{"socket"},
+				specs.LinuxSeccompArg{Index: 0, Value: unix.AF_PACKET, Op: specs.OpEqualTo}),
+		}
+	}
+	return nil
+}
+
+// SupportsHardeningRule returns whether the built-in hardening rule is implemented by the Seccomp enforcer
+func SupportsHardeningRule(rule string) bool {
+	rule = strings.ToLower(rule)
+	rule = strings.ReplaceAll(rule, "_", "-")
+	return generateHardeningRules(rule) != nil
+}
+
 func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect, profileName string) (string, error) {
 	if enhanceProtect.Privileged {
 		return "", nil
@@ -85,28 +169,38 @@ func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect, profil
 	for _, rule := range enhanceProtect.HardeningRules {
 		rule = strings.ToLower(rule)
 		rule = strings.ReplaceAll(rule, "_", "-")
-
-		switch rule {
-		case "disallow-create-user-ns":
-			syscall := specs.LinuxSyscall{
-				Names:  []string{"unshare"},
-				Action: specs.ActErrno,
-				Args: []specs.LinuxSeccompArg{
-					{
-						Index:    0,
-						Value:    unix.CLONE_NEWUSER,
-						ValueTwo: unix.CLONE_NEWUSER,
-						Op:       specs.OpMaskedEqual,
-					},
-				},
-			}
-			profile.Syscalls = append(profile.Syscalls, syscall)
-		}
+		profile.Syscalls = append(profile.Syscalls, generateHardeningRules(rule)...)
 	}
 
 	// Custom
 	profile.Syscalls = append(profile.Syscalls, enhanceProtect.SyscallRawRules...)
 
+	// Notify
+	if len(enhanceProtect.SyscallNotifyRules) > 0 {
+		syscall := specs.LinuxSyscall{
+			Action: specs.ActNotify,
+		}
+		for _, rule := range enhanceProtect.SyscallNotifyRules {
+			if !varmorutils.InStringArray(rule.Syscall, varmorseccomp.NotifySupportedSyscalls) {
+				return "", fmt.Errorf("the syscall '%s' isn't supported by the notify rules", rule.Syscall)
+			}
+			if !varmorutils.InStringArray(rule.Syscall, syscall.Names) {
+				syscall.Names = append(syscall.Names, rule.Syscall)
+			}
+		}
+		profile.Syscalls = append(profile.Syscalls, syscall)
+
+		metadata, err := json.Marshal(varmorseccomp.NotifyMetadata{
+			Profile: profileName,
+			Rules:   enhanceProtect.SyscallNotifyRules,
+		})
+		if err != nil {
+			return "", err
+		}
+		profile.ListenerPath = varmorconfig.SeccompNotifySocketPath
+		profile.ListenerMetadata = string(metadata)
+	}
+
 	p, err := json.Marshal(profile)
 	if err != nil {
 		return "", err
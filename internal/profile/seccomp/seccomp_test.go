@@ -0,0 +1,82 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package seccomp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_GenerateEnhanceProtectProfile(t *testing.T) {
+	testCases := []struct {
+		name             string
+		hardeningRules   []string
+		expectedSyscalls []string
+		expectedArgs     int
+	}{
+		{
+			name:             "disallowCreateUserNs",
+			hardeningRules:   []string{"disallow-create-user-ns"},
+			expectedSyscalls: []string{"unshare", "clone", "clone3"},
+			expectedArgs:     2,
+		},
+		{
+			name:             "disallowLoadEbpf",
+			hardeningRules:   []string{"disallow_load_ebpf"},
+			expectedSyscalls: []string{"bpf"},
+			expectedArgs:     1,
+		},
+		{
+			name:             "disableCapNetRaw",
+			hardeningRules:   []string{"disable-cap-net-raw"},
+			expectedSyscalls: []string{"socket", "socket"},
+			expectedArgs:     2,
+		},
+		{
+			name:             "unsupported",
+			hardeningRules:   []string{"disallow-write-core-pattern"},
+			expectedSyscalls: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enhanceProtect := &varmor.EnhanceProtect{HardeningRules: tc.hardeningRules}
+			content, err := GenerateEnhanceProtectProfile(enhanceProtect, "test")
+			assert.NilError(t, err)
+
+			p, err := base64.StdEncoding.DecodeString(content)
+			assert.NilError(t, err)
+			var profile specs.LinuxSeccomp
+			assert.NilError(t, json.Unmarshal(p, &profile))
+
+			syscalls := []string{}
+			args := 0
+			for _, s := range profile.Syscalls {
+				assert.Equal(t, s.Action, specs.ActErrno)
+				syscalls = append(syscalls, s.Names...)
+				args += len(s.Args)
+			}
+			assert.DeepEqual(t, syscalls, tc.expectedSyscalls)
+			assert.Equal(t, args, tc.expectedArgs)
+		})
+	}
+}
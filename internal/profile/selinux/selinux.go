@@ -0,0 +1,138 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selinux generates the CIL policy modules of the SELinux enforcer. Each module defines a block named after
+// the profile, and the target containers run in the process type of the block. It's derived from the container_t
+// domain of container-selinux in the way of udica, so the modules only depend on the policy of the RHEL-family nodes.
+package selinux
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	// aliasTemplate aliases the process type of the block to an existing domain
+	aliasTemplate = `(block %s
+    (typealias process)
+    (typealiasactual process %s)
+)
+`
+	// enhanceProtectTemplate defines a new domain that has the same attributes as container_t, so it's granted the
+	// same permissions. The raw rules are appended to the block to allow the extra accesses.
+	enhanceProtectTemplate = `(block %s
+    (type process)
+    (roletype system_r process)
+    (typeattributeset domain (process))
+    (typeattributeset container_domain (process))
+    (typeattributeset container_net_domain (process))
+    (typeattributeset svirt_sandbox_domain (process))
+    (typeattributeset mcs_constrained_type (process))
+%s)
+`
+)
+
+// confinedRules are the built-in rules that are enforced by the container_t domain already, e.g. it's not allowed
+// to mount filesystems, load kernel modules or access the files of the host.
+var confinedRules = []string{
+	"disallow-mount",
+	"disallow-umount",
+	"disallow-insmod",
+	"disallow-load-ebpf",
+	"disallow-write-core-pattern",
+	"disallow-write-release-agent",
+	"disallow-mount-securityfs",
+	"disallow-mount-procfs",
+	"disallow-mount-cgroupfs",
+	"disallow-mount-disk-device",
+	"disallow-debug-disk-device",
+	"disallow-access-procfs-root",
+	"disallow-tamper-varmor",
+}
+
+// BlockName returns the name of the CIL block (also the name of the module) of the profile
+func BlockName(profileName string) string {
+	return strings.NewReplacer("-", "_", ".", "_").Replace(profileName)
+}
+
+// ProcessType returns the SELinux type that the target containers of the profile run in
+func ProcessType(profileName string) string {
+	return BlockName(profileName) + ".process"
+}
+
+// GenerateAlwaysAllowProfile generates a SELinux profile that runs the target containers in the spc_t domain,
+// which is unconfined
+func GenerateAlwaysAllowProfile(profileName string) string {
+	c := []byte(fmt.Sprintf(aliasTemplate, BlockName(profileName), "spc_t"))
+	return base64.StdEncoding.EncodeToString(c)
+}
+
+// GenerateRuntimeDefaultProfile generates a SELinux profile that runs the target containers in the container_t
+// domain, which is the default domain of the containers
+func GenerateRuntimeDefaultProfile(profileName string) string {
+	c := []byte(fmt.Sprintf(aliasTemplate, BlockName(profileName), "container_t"))
+	return base64.StdEncoding.EncodeToString(c)
+}
+
+func isConfinedRule(rule string) bool {
+	rule = strings.ReplaceAll(strings.ToLower(rule), "_", "-")
+	for _, r := range confinedRules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateEnhanceProtectProfile generates the SELinux profile from the built-in hardening rules and the raw rules
+// of the policy. Only the built-in rules that are enforced by the container_t domain are supported, the others
+// can't be expressed by the SELinux enforcer.
+func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect, profileName string) (string, error) {
+	if enhanceProtect.Privileged {
+		return GenerateAlwaysAllowProfile(profileName), nil
+	}
+
+	for _, rule := range enhanceProtect.HardeningRules {
+		if !isConfinedRule(rule) {
+			return "", fmt.Errorf("the built-in rule '%s' isn't supported by the SELinux enforcer", rule)
+		}
+	}
+
+	for _, rules := range enhanceProtect.AttackProtectionRules {
+		for _, rule := range rules.Rules {
+			if !isConfinedRule(rule) {
+				return "", fmt.Errorf("the built-in rule '%s' isn't supported by the SELinux enforcer", rule)
+			}
+		}
+	}
+
+	if len(enhanceProtect.VulMitigationRules) != 0 {
+		return "", fmt.Errorf("the vulMitigationRules aren't supported by the SELinux enforcer")
+	}
+
+	var rules string
+	for _, rule := range enhanceProtect.SELinuxRawRules {
+		rule = strings.TrimSpace(rule)
+		if !strings.HasPrefix(rule, "(") || !strings.HasSuffix(rule, ")") || strings.Count(rule, "(") != strings.Count(rule, ")") {
+			return "", fmt.Errorf("the raw rule '%s' isn't a well-formed CIL statement", rule)
+		}
+		rules += "    " + rule + "\n"
+	}
+
+	c := []byte(fmt.Sprintf(enhanceProtectTemplate, BlockName(profileName), rules))
+	return base64.StdEncoding.EncodeToString(c), nil
+}
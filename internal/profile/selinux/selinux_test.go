@@ -0,0 +1,99 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selinux
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_GenerateEnhanceProtectProfile(t *testing.T) {
+	testCases := []struct {
+		name            string
+		enhanceProtect  varmor.EnhanceProtect
+		expectedProfile string
+		expectedErr     bool
+	}{
+		{
+			name: "confined rules and raw rules",
+			enhanceProtect: varmor.EnhanceProtect{
+				HardeningRules: []string{"disallow-mount", "DISALLOW_WRITE_CORE_PATTERN"},
+				SELinuxRawRules: []string{
+					"(allow process var_log_t (dir (read search)))",
+				},
+			},
+			expectedProfile: `(block varmor_demo_demo_v1
+    (type process)
+    (roletype system_r process)
+    (typeattributeset domain (process))
+    (typeattributeset container_domain (process))
+    (typeattributeset container_net_domain (process))
+    (typeattributeset svirt_sandbox_domain (process))
+    (typeattributeset mcs_constrained_type (process))
+    (allow process var_log_t (dir (read search)))
+)
+`,
+		},
+		{
+			name: "privileged",
+			enhanceProtect: varmor.EnhanceProtect{
+				Privileged:     true,
+				HardeningRules: []string{"disallow-create-user-ns"},
+			},
+			expectedProfile: `(block varmor_demo_demo_v1
+    (typealias process)
+    (typealiasactual process spc_t)
+)
+`,
+		},
+		{
+			name: "unsupported built-in rule",
+			enhanceProtect: varmor.EnhanceProtect{
+				HardeningRules: []string{"disallow-create-user-ns"},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "malformed raw rule",
+			enhanceProtect: varmor.EnhanceProtect{
+				SELinuxRawRules: []string{"(allow process var_log_t (dir (read search))"},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, err := GenerateEnhanceProtectProfile(&tc.enhanceProtect, "varmor-demo-demo.v1")
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+
+			profile, err := base64.StdEncoding.DecodeString(content)
+			assert.NilError(t, err)
+			assert.Equal(t, string(profile), tc.expectedProfile)
+		})
+	}
+}
+
+func Test_ProcessType(t *testing.T) {
+	assert.Equal(t, ProcessType("varmor-cluster-varmor-demo"), "varmor_cluster_varmor_demo.process")
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+const (
+	// anomalyWindow is the window of the violation rate, it's the same as the report interval of agents
+	anomalyWindow = time.Minute
+	// anomalyWarmupWindows is the number of the windows to establish the baseline before flagging anomalies
+	anomalyWarmupWindows = 30
+	// anomalyAlpha is the smoothing factor of the exponentially weighted moving average of the violation rate
+	anomalyAlpha = 0.1
+	// anomalyScoreThreshold is the minimum z-score of the violation rate to be flagged as a burst
+	anomalyScoreThreshold = 3.0
+	// anomalyMinBurstCount is the minimum count of the violations in a window to be flagged as a burst
+	anomalyMinBurstCount = 10
+	// anomalyBaselineRetention is the period to keep a baseline since it was updated the last time
+	anomalyBaselineRetention = 24 * time.Hour
+
+	// Reasons of the events
+	violationBurstReason   = "ViolationBurst"
+	newViolationRuleReason = "NewViolationRule"
+)
+
+// violationAnomaly is a statistically unusual change of the violations of a workload
+type violationAnomaly struct {
+	Reason    string
+	Namespace string
+	Workload  string
+	NodeName  string
+	Message   string
+}
+
+type workloadBaselineKey struct {
+	profile   string
+	nodeName  string
+	namespace string
+	workload  string
+}
+
+// workloadBaseline is the baseline of the violations of a workload on one node
+type workloadBaseline struct {
+	windows  int
+	mean     float64
+	variance float64
+	rules    map[string]bool
+	lastSeen time.Time
+}
+
+// update updates the exponentially weighted moving average and variance with the count of a window
+func (b *workloadBaseline) update(count float64) {
+	diff := count - b.mean
+	incr := anomalyAlpha * diff
+	b.mean += incr
+	b.variance = (1 - anomalyAlpha) * (b.variance + diff*incr)
+	b.windows++
+}
+
+// score returns the z-score of the count against the baseline
+func (b *workloadBaseline) score(count float64) float64 {
+	// Use a floor of the standard deviation, so the workloads that rarely violate the policy are not too sensitive
+	return (count - b.mean) / math.Max(math.Sqrt(b.variance), 1)
+}
+
+// anomalyDetector baselines the violation rates and the rule classes per workload, and flags the unusual
+// bursts and the rule classes which never fired before. It's only used by the violation worker.
+type anomalyDetector struct {
+	baselines map[workloadBaselineKey]*workloadBaseline
+}
+
+func newAnomalyDetector() *anomalyDetector {
+	return &anomalyDetector{
+		baselines: make(map[workloadBaselineKey]*workloadBaseline),
+	}
+}
+
+// observe scores the violations of a report against the baselines, and then updates the baselines with them.
+func (d *anomalyDetector) observe(profile string, nodeName string, entries []varmortypes.ViolationEntry, workloads map[string]string, now time.Time) []violationAnomaly {
+	counts := make(map[workloadBaselineKey]int64)
+	rules := make(map[workloadBaselineKey][]string)
+	for _, entry := range entries {
+		key := workloadBaselineKey{
+			profile:   profile,
+			nodeName:  nodeName,
+			namespace: entry.PodNamespace,
+			workload:  workloads[entry.PodNamespace+"/"+entry.PodName],
+		}
+		counts[key] += entry.Count
+		rules[key] = append(rules[key], entry.RuleType+"/"+entry.RuleID)
+	}
+
+	var anomalies []violationAnomaly
+	for key, count := range counts {
+		b, ok := d.baselines[key]
+		if !ok {
+			b = &workloadBaseline{rules: make(map[string]bool), lastSeen: now}
+			d.baselines[key] = b
+		}
+
+		// The agents don't report the windows without violations, so they're counted as zero.
+		idle := int(now.Sub(b.lastSeen)/anomalyWindow) - 1
+		for i := 0; i < idle && i < anomalyWarmupWindows*10; i++ {
+			b.update(0)
+		}
+
+		warm := b.windows >= anomalyWarmupWindows
+		if warm && count >= anomalyMinBurstCount {
+			if score := b.score(float64(count)); score >= anomalyScoreThreshold {
+				anomalies = append(anomalies, violationAnomaly{
+					Reason:    violationBurstReason,
+					Namespace: key.namespace,
+					Workload:  key.workload,
+					NodeName:  key.nodeName,
+					Message: fmt.Sprintf("%s in namespace %s on node %s triggered %d violations in one minute, the baseline is %.1f (z-score: %.1f)",
+						key.workload, key.namespace, key.nodeName, count, b.mean, score),
+				})
+			}
+		}
+
+		for _, rule := range rules[key] {
+			if b.rules[rule] {
+				continue
+			}
+			b.rules[rule] = true
+			if warm {
+				anomalies = append(anomalies, violationAnomaly{
+					Reason:    newViolationRuleReason,
+					Namespace: key.namespace,
+					Workload:  key.workload,
+					NodeName:  key.nodeName,
+					Message: fmt.Sprintf("%s in namespace %s on node %s triggered the %s rule that never fired before",
+						key.workload, key.namespace, key.nodeName, rule),
+				})
+			}
+		}
+
+		b.update(float64(count))
+		b.lastSeen = now
+	}
+
+	for key, b := range d.baselines {
+		if now.Sub(b.lastSeen) > anomalyBaselineRetention {
+			delete(d.baselines, key)
+		}
+	}
+
+	return anomalies
+}
+
+// reportAnomalies raises the anomalies as the warning events of the ArmorProfile object
+func (m *StatusManager) reportAnomalies(ap *varmor.ArmorProfile, anomalies []violationAnomaly) {
+	logger := m.log.WithName("reportAnomalies()")
+
+	for _, anomaly := range anomalies {
+		logger.Info("anomalous violations detected", "profile", ap.Name, "reason", anomaly.Reason,
+			"namespace", anomaly.Namespace, "workload", anomaly.Workload, "node", anomaly.NodeName, "message", anomaly.Message)
+
+		now := metav1.Now()
+		event := v1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: ap.Name + "-",
+				Namespace:    ap.Namespace,
+			},
+			InvolvedObject: v1.ObjectReference{
+				APIVersion: varmor.GroupVersion.String(),
+				Kind:       "ArmorProfile",
+				Namespace:  ap.Namespace,
+				Name:       ap.Name,
+				UID:        ap.UID,
+			},
+			Reason:         anomaly.Reason,
+			Message:        anomaly.Message,
+			Type:           v1.EventTypeWarning,
+			Source:         v1.EventSource{Component: "varmor-manager"},
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Count:          1,
+		}
+		_, err := m.coreInterface.Events(ap.Namespace).Create(context.Background(), &event, metav1.CreateOptions{})
+		if err != nil {
+			logger.Error(err, "m.coreInterface.Events().Create()")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_anomalyDetector(t *testing.T) {
+	d := newAnomalyDetector()
+	workloads := map[string]string{"demo/web-1": "Deployment/web"}
+	now := time.Unix(1714558830, 0)
+
+	entries := func(ruleID string, count int64) []varmortypes.ViolationEntry {
+		return []varmortypes.ViolationEntry{
+			{PodNamespace: "demo", PodName: "web-1", RuleType: "file", RuleID: ruleID, Count: count},
+		}
+	}
+
+	// Nothing is flagged during the warm-up
+	for i := 0; i < anomalyWarmupWindows; i++ {
+		anomalies := d.observe("varmor-demo-web", "node-a", entries("rule-1", 2), workloads, now)
+		assert.Equal(t, len(anomalies), 0)
+		now = now.Add(anomalyWindow)
+	}
+
+	// The steady rate is not flagged
+	anomalies := d.observe("varmor-demo-web", "node-a", entries("rule-1", 3), workloads, now)
+	assert.Equal(t, len(anomalies), 0)
+	now = now.Add(anomalyWindow)
+
+	// The burst is flagged
+	anomalies = d.observe("varmor-demo-web", "node-a", entries("rule-1", 50), workloads, now)
+	assert.Equal(t, len(anomalies), 1)
+	assert.Equal(t, anomalies[0].Reason, violationBurstReason)
+	assert.Equal(t, anomalies[0].Workload, "Deployment/web")
+	now = now.Add(anomalyWindow)
+
+	// The rule that never fired before is flagged only once
+	anomalies = d.observe("varmor-demo-web", "node-a", entries("rule-2", 1), workloads, now)
+	assert.Equal(t, len(anomalies), 1)
+	assert.Equal(t, anomalies[0].Reason, newViolationRuleReason)
+	now = now.Add(anomalyWindow)
+
+	anomalies = d.observe("varmor-demo-web", "node-a", entries("rule-2", 1), workloads, now)
+	assert.Equal(t, len(anomalies), 0)
+
+	// The idle baseline is dropped
+	now = now.Add(anomalyBaselineRetention + time.Minute)
+	d.observe("varmor-demo-other", "node-a", entries("rule-1", 1), workloads, now)
+	assert.Equal(t, len(d.baselines), 1)
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+// dynamicTokenRegexps match the tokens of a path segment that are generated at runtime, so they differ between
+// the replicas of a workload and between the time windows of one replica. The order matters, the more specific
+// token must be replaced before the generic one.
+var dynamicTokenRegexps = []*regexp.Regexp{
+	// UUID, e.g. 8f3a0c1e-5b7d-4c2a-9e6f-0123456789ab
+	regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`),
+	// ISO 8601 timestamp, e.g. 2024-05-01T10:20:30.123Z, 20240501T102030
+	regexp.MustCompile(`[0-9]{4}-?[0-9]{2}-?[0-9]{2}[T_][0-9]{2}:?[0-9]{2}:?[0-9]{2}(\.[0-9]+)?Z?`),
+	// Date, e.g. 2024-05-01
+	regexp.MustCompile(`[0-9]{4}-[0-9]{2}-[0-9]{2}`),
+	// Hexadecimal identifier, e.g. the container ID and the checksum
+	regexp.MustCompile(`[0-9a-fA-F]{16,}`),
+	// Unix timestamp, PID, sequence number and so on
+	regexp.MustCompile(`[0-9]{6,}`),
+}
+
+var asterisksRegexp = regexp.MustCompile(`\*+`)
+
+// normalizeDynamicPath replaces the dynamically generated tokens of every path segment with the AppArmor wildcard
+// "*". The segments that contain "**" have been generalized already, so they are kept as they are.
+func normalizeDynamicPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "" || strings.Contains(segment, "**") {
+			continue
+		}
+
+		normalized := segment
+		for _, r := range dynamicTokenRegexps {
+			normalized = r.ReplaceAllString(normalized, "*")
+		}
+		if normalized != segment {
+			// "**" matches across directories, so the adjacent wildcards must be collapsed.
+			segments[i] = asterisksRegexp.ReplaceAllString(normalized, "*")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// unionStrings appends the items of src that don't exist in dst to dst.
+func unionStrings(dst []string, src []string) []string {
+	for _, s := range src {
+		if !varmorutils.InStringArray(s, dst) {
+			dst = append(dst, s)
+		}
+	}
+	return dst
+}
+
+// consolidateAppArmorResult dedupes the file and execution rules of the merged behavior data. The rules whose
+// paths only differ in the dynamically generated tokens are merged into one rule, and the permissions of them
+// are unioned. So the behavior data collected from different replicas and nodes produces one consolidated model.
+func consolidateAppArmorResult(apparmor *varmor.AppArmor) {
+	if len(apparmor.Executions) != 0 {
+		executions := make([]string, 0, len(apparmor.Executions))
+		for _, execution := range apparmor.Executions {
+			executions = unionStrings(executions, []string{normalizeDynamicPath(execution)})
+		}
+		apparmor.Executions = executions
+	}
+
+	if len(apparmor.Files) != 0 {
+		files := make([]varmor.File, 0, len(apparmor.Files))
+		for _, file := range apparmor.Files {
+			path := normalizeDynamicPath(file.Path)
+			oldPath := file.OldPath
+			if oldPath != "" {
+				oldPath = normalizeDynamicPath(oldPath)
+			}
+
+			find := false
+			for index := range files {
+				if files[index].Path == path && files[index].Owner == file.Owner {
+					find = true
+					files[index].Permissions = unionStrings(files[index].Permissions, file.Permissions)
+					if files[index].OldPath == "" && oldPath != "" {
+						files[index].OldPath = oldPath
+					}
+					break
+				}
+			}
+			if !find {
+				files = append(files, varmor.File{
+					Path:        path,
+					Owner:       file.Owner,
+					Permissions: unionStrings(make([]string, 0, len(file.Permissions)), file.Permissions),
+					OldPath:     oldPath,
+				})
+			}
+		}
+
+		// Sort the permissions so the model stays the same regardless of the order in which the agents report.
+		for index := range files {
+			sort.Strings(files[index].Permissions)
+		}
+		apparmor.Files = files
+	}
+}
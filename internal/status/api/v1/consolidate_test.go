@@ -0,0 +1,115 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_normalizeDynamicPath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "uuid",
+			path:     "/tmp/worker-8f3a0c1e-5b7d-4c2a-9e6f-0123456789ab/lock",
+			expected: "/tmp/worker-*/lock",
+		},
+		{
+			name:     "iso8601",
+			path:     "/var/log/app/access-2024-05-01T10:20:30.123Z.log",
+			expected: "/var/log/app/access-*.log",
+		},
+		{
+			name:     "date",
+			path:     "/var/log/app/2024-05-01/error.log",
+			expected: "/var/log/app/*/error.log",
+		},
+		{
+			name:     "container id",
+			path:     "/run/containerd/io.containerd.runtime.v2.task/k8s.io/0f6ad4bb8c1e41f0a4f4a0cb7cb5e3e1d2e5f4c3b2a1e0d9c8b7a6f5e4d3c2b1/rootfs",
+			expected: "/run/containerd/io.containerd.runtime.v2.task/k8s.io/*/rootfs",
+		},
+		{
+			name:     "unix timestamp",
+			path:     "/data/cache/1714558830_segment.bin",
+			expected: "/data/cache/*_segment.bin",
+		},
+		{
+			name:     "static",
+			path:     "/usr/lib/x86_64-linux-gnu/libc.so.6",
+			expected: "/usr/lib/x86_64-linux-gnu/libc.so.6",
+		},
+		{
+			name:     "generalized",
+			path:     "/tmp/**",
+			expected: "/tmp/**",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, normalizeDynamicPath(tc.path), tc.expected)
+		})
+	}
+}
+
+func Test_mergeAppArmorResultAcrossReplicas(t *testing.T) {
+	apm := &varmor.ArmorProfileModel{}
+
+	replicas := []varmortypes.BehaviorData{
+		{
+			NodeName: "node-a",
+			DynamicResult: varmor.DynamicResult{
+				AppArmor: varmor.AppArmor{
+					Executions: []string{"/app/bin/server"},
+					Files: []varmor.File{
+						{Path: "/etc/app.conf", Permissions: []string{"r"}},
+						{Path: "/data/8f3a0c1e-5b7d-4c2a-9e6f-0123456789ab/state", Owner: true, Permissions: []string{"w", "r"}},
+					},
+				},
+			},
+		},
+		{
+			NodeName: "node-b",
+			DynamicResult: varmor.DynamicResult{
+				AppArmor: varmor.AppArmor{
+					Executions: []string{"/app/bin/server"},
+					Files: []varmor.File{
+						{Path: "/etc/app.conf", Permissions: []string{"r"}},
+						{Path: "/data/0b9d3e4f-1a2b-4c3d-8e9f-a0b1c2d3e4f5/state", Owner: true, Permissions: []string{"k"}},
+					},
+				},
+			},
+		},
+	}
+
+	for i := range replicas {
+		mergeAppArmorResult(apm, &replicas[i])
+	}
+
+	assert.DeepEqual(t, apm.Data.DynamicResult.AppArmor.Executions, []string{"/app/bin/server"})
+	assert.DeepEqual(t, apm.Data.DynamicResult.AppArmor.Files, []varmor.File{
+		{Path: "/etc/app.conf", Permissions: []string{"r"}},
+		{Path: "/data/*/state", Owner: true, Permissions: []string{"k", "r", "w"}},
+	})
+}
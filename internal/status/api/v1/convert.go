@@ -0,0 +1,93 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+
+	varmorprofile "github.com/bytedance/vArmor/internal/profile"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorconverter "github.com/bytedance/vArmor/pkg/converter"
+)
+
+// ConvertKubeArmorPolicy is an HTTP interface used for converting a KubeArmorPolicy object in the request
+// body into a VarmorPolicy object. Use the kind query parameter to specify the kind of the target workloads,
+// it defaults to Pod. The rules that can't be converted are reported in the warnings of the result.
+func (m *StatusManager) ConvertKubeArmorPolicy(c *gin.Context) {
+	logger := m.log.WithName("ConvertKubeArmorPolicy()")
+
+	reqBody, err := getHttpBody(c)
+	if err != nil {
+		logger.Error(err, "getHttpBody()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	kp, err := varmorconverter.ParseKubeArmorPolicy(reqBody)
+	if err != nil {
+		logger.Error(err, "ParseKubeArmorPolicy()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	kind := c.DefaultQuery("kind", "Pod")
+	vp, warnings := varmorconverter.ConvertKubeArmorPolicy(kp, kind)
+
+	err = varmorprofile.ValidateBpfProfile(vp.Spec.Policy)
+	if err != nil {
+		logger.Error(err, "ValidateBpfProfile()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, varmortypes.ConversionResult{
+		Policy:   vp,
+		Warnings: warnings,
+	})
+}
+
+// GeneratePSSPolicy is an HTTP interface used for generating a VarmorClusterPolicy object which enforces the
+// requirements of the Pod Security Standards level (baseline or restricted) at runtime. Use the enforcer query
+// parameter to specify the enforcer, it defaults to BPF. Use the kind and selector query parameters to specify
+// the target workloads, e.g. kind=Deployment&selector=app=nginx. The kind defaults to Pod.
+func (m *StatusManager) GeneratePSSPolicy(c *gin.Context) {
+	logger := m.log.WithName("GeneratePSSPolicy()")
+
+	selector, err := metav1.ParseToLabelSelector(c.Query("selector"))
+	if err != nil {
+		logger.Error(err, "ParseToLabelSelector()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	target := varmor.Target{
+		Kind:     c.DefaultQuery("kind", "Pod"),
+		Selector: selector,
+	}
+
+	vcp, err := varmorconverter.GeneratePSSPolicy(c.Param("level"), c.DefaultQuery("enforcer", "BPF"), target)
+	if err != nil {
+		logger.Error(err, "GeneratePSSPolicy()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, vcp)
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// Coverage is an HTTP interface used for receiving the CoverageData come from agents
+func (m *StatusManager) Coverage(c *gin.Context) {
+	logger := m.log.WithName("Coverage()")
+
+	reqBody, err := getHttpBody(c)
+	if err != nil {
+		logger.Error(err, "getHttpBody()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	var data varmortypes.CoverageData
+	err = json.Unmarshal(reqBody, &data)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	if data.Namespace == "" || data.ProfileName == "" || data.NodeName == "" {
+		err = fmt.Errorf("request is illegal")
+		logger.Error(err, "bad request body")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	logger.V(3).Info("enqueue CoverageData from agent")
+	m.coverageQueue.Add(string(reqBody))
+}
+
+// aggregateCoverage sums up the coverages of the nodes, it returns nil if there is no target pod
+func aggregateCoverage(nodes map[string]varmor.NodeCoverage) *varmor.EnforcementCoverage {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	coverage := varmor.EnforcementCoverage{}
+	for _, node := range nodes {
+		coverage.DesiredPods += node.DesiredPods
+		coverage.ReadyPods += node.ReadyPods
+		coverage.FailedPods += node.FailedPods
+		coverage.PartialPods += node.PartialPods
+		coverage.Nodes = append(coverage.Nodes, node)
+	}
+	sort.Slice(coverage.Nodes, func(i, j int) bool {
+		return coverage.Nodes[i].NodeName < coverage.Nodes[j].NodeName
+	})
+	return &coverage
+}
+
+// updateCoverageCache updates the coverage of the node in StatusManager.PolicyCoverages, the node is removed if
+// it has no target pod. It returns false if nothing changed.
+func (m *StatusManager) updateCoverageCache(statusKey string, data *varmortypes.CoverageData) bool {
+	nodes := m.PolicyCoverages[statusKey]
+
+	if data.DesiredPods == 0 {
+		if _, ok := nodes[data.NodeName]; !ok {
+			return false
+		}
+		delete(nodes, data.NodeName)
+		return true
+	}
+
+	coverage := varmor.NodeCoverage{
+		NodeName:       data.NodeName,
+		DesiredPods:    data.DesiredPods,
+		ReadyPods:      data.ReadyPods,
+		FailedPods:     data.FailedPods,
+		FailureReasons: data.FailureReasons,
+		PartialPods:    data.PartialPods,
+		PartialReasons: data.PartialReasons,
+	}
+	if old, ok := nodes[data.NodeName]; ok && reflect.DeepEqual(old, coverage) {
+		return false
+	}
+
+	if nodes == nil {
+		nodes = make(map[string]varmor.NodeCoverage)
+		m.PolicyCoverages[statusKey] = nodes
+	}
+	nodes[data.NodeName] = coverage
+	return true
+}
+
+// updatePolicyCoverage updates the coverage in VarmorPolicy/status or VarmorClusterPolicy/status with the cache
+func (m *StatusManager) updatePolicyCoverage(statusKey string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(statusKey)
+	if err != nil {
+		return err
+	}
+	coverage := aggregateCoverage(m.PolicyCoverages[statusKey])
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if namespace == "" {
+			vcp, err := m.varmorInterface.VarmorClusterPolicies().Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if reflect.DeepEqual(vcp.Status.Coverage, coverage) {
+				return nil
+			}
+			vcp.Status.Coverage = coverage
+			_, err = m.varmorInterface.VarmorClusterPolicies().UpdateStatus(context.Background(), vcp, metav1.UpdateOptions{})
+			return err
+		}
+
+		vp, err := m.varmorInterface.VarmorPolicies(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if reflect.DeepEqual(vp.Status.Coverage, coverage) {
+			return nil
+		}
+		vp.Status.Coverage = coverage
+		_, err = m.varmorInterface.VarmorPolicies(namespace).UpdateStatus(context.Background(), vp, metav1.UpdateOptions{})
+		return err
+	})
+	if k8errors.IsNotFound(err) {
+		// The policy has been deleted
+		return nil
+	}
+	return err
+}
+
+func (m *StatusManager) syncCoverage(data string) error {
+	logger := m.log.WithName("syncCoverage()")
+
+	var coverageData varmortypes.CoverageData
+	err := json.Unmarshal([]byte(data), &coverageData)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal() coverageData failed")
+		return nil
+	}
+	logger.V(3).Info("receive coverage data from agent", "profile", coverageData.ProfileName, "node", coverageData.NodeName)
+
+	m.UpdateCoverageCh <- coverageData
+	return nil
+}
+
+func (m *StatusManager) handleCoverageErr(err error, data interface{}) {
+	logger := m.log
+	if err == nil {
+		m.coverageQueue.Forget(data)
+		return
+	}
+
+	if m.coverageQueue.NumRequeues(data) < maxRetries {
+		logger.Error(err, "failed to sync coverage", "data", data)
+		m.coverageQueue.AddRateLimited(data)
+		return
+	}
+
+	utilruntime.HandleError(err)
+	logger.V(3).Info("dropping data out of coverageQueue", "key", data)
+	m.coverageQueue.Forget(data)
+}
+
+func (m *StatusManager) processNextCoverageWorkItem() bool {
+	data, quit := m.coverageQueue.Get()
+	if quit {
+		return false
+	}
+	defer m.coverageQueue.Done(data)
+
+	err := m.syncCoverage(data.(string))
+	m.handleCoverageErr(err, data)
+
+	return true
+}
+
+func (m *StatusManager) coverageWorker() {
+	for m.processNextCoverageWorkItem() {
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_updateCoverageCache(t *testing.T) {
+	m := &StatusManager{
+		PolicyCoverages: make(map[string]map[string]varmor.NodeCoverage),
+	}
+	key := "default/test"
+
+	data := varmortypes.CoverageData{NodeName: "node-b", DesiredPods: 3, ReadyPods: 2, FailedPods: 1, FailureReasons: []string{"default/pod/c: no space left on device"}}
+	assert.Equal(t, m.updateCoverageCache(key, &data), true)
+	assert.Equal(t, m.updateCoverageCache(key, &data), false)
+
+	data = varmortypes.CoverageData{NodeName: "node-a", DesiredPods: 2, ReadyPods: 2}
+	assert.Equal(t, m.updateCoverageCache(key, &data), true)
+
+	coverage := aggregateCoverage(m.PolicyCoverages[key])
+	assert.Equal(t, coverage.DesiredPods, 5)
+	assert.Equal(t, coverage.ReadyPods, 4)
+	assert.Equal(t, coverage.FailedPods, 1)
+	assert.Equal(t, len(coverage.Nodes), 2)
+	assert.Equal(t, coverage.Nodes[0].NodeName, "node-a")
+	assert.Equal(t, coverage.Nodes[1].FailureReasons[0], "default/pod/c: no space left on device")
+
+	// The node which has no target pod is removed
+	data = varmortypes.CoverageData{NodeName: "node-a"}
+	assert.Equal(t, m.updateCoverageCache(key, &data), true)
+	assert.Equal(t, m.updateCoverageCache(key, &data), false)
+	data = varmortypes.CoverageData{NodeName: "node-b"}
+	assert.Equal(t, m.updateCoverageCache(key, &data), true)
+	assert.Assert(t, aggregateCoverage(m.PolicyCoverages[key]) == nil)
+}
@@ -23,12 +23,14 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/internal/artifact"
 	apparmorprofile "github.com/bytedance/vArmor/internal/profile/apparmor"
 	seccompprofile "github.com/bytedance/vArmor/internal/profile/seccomp"
 	varmortypes "github.com/bytedance/vArmor/internal/types"
@@ -72,6 +74,20 @@ func (m *StatusManager) retrieveArmorProfileModel(namespace, name string) (*varm
 	return apm, nil
 }
 
+// retrievePathGeneralizationLevel returns the path generalization level of the ArmorProfile object. The default
+// level is used if the object can't be retrieved.
+func (m *StatusManager) retrievePathGeneralizationLevel(namespace, name string) string {
+	ap, err := m.varmorInterface.ArmorProfiles(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		m.log.Error(err, "m.varmorInterface.ArmorProfiles().Get()", "namespace", namespace, "name", name)
+		return varmortypes.PathGeneralizationConservative
+	}
+	if ap.Spec.BehaviorModeling.PathGeneralization == "" {
+		return varmortypes.PathGeneralizationConservative
+	}
+	return ap.Spec.BehaviorModeling.PathGeneralization
+}
+
 func mergeAppArmorResult(apm *varmor.ArmorProfileModel, data *varmortypes.BehaviorData) {
 	if apm.Data.DynamicResult.AppArmor.Profiles == nil && len(data.DynamicResult.AppArmor.Profiles) != 0 {
 		apm.Data.DynamicResult.AppArmor.Profiles = make([]string, 0)
@@ -274,6 +290,9 @@ func mergeAppArmorResult(apm *varmor.ArmorProfileModel, data *varmortypes.Behavi
 			}
 		}
 	}
+
+	// Dedupe the rules that only differ in the dynamically generated tokens of paths
+	consolidateAppArmorResult(&apm.Data.DynamicResult.AppArmor)
 }
 
 func mergeSeccompResult(apm *varmor.ArmorProfileModel, data *varmortypes.BehaviorData) {
@@ -379,6 +398,10 @@ func (m *StatusManager) syncData(data string) error {
 	}
 	logger.Info("1. receive behavior data from agent", "profile", behaviorData.ProfileName, "node", behaviorData.NodeName)
 
+	if behaviorData.Feedback {
+		return m.syncFeedback(&behaviorData, logger)
+	}
+
 	// Merge the behavior data to the ArmorProfileModel objet
 	apm, err := m.retrieveArmorProfileModel(behaviorData.Namespace, behaviorData.ProfileName)
 	if err != nil {
@@ -412,39 +435,48 @@ func (m *StatusManager) syncData(data string) error {
 		logger.Error(err, "updateModelingStatus()")
 		return nil
 	}
-	logger.Info("3. modeling status cache updated", "key", statusKey, "value", m.ModelingStatuses[statusKey], "desired number", m.desiredNumber)
+	logger.Info("3. modeling status cache updated", "key", statusKey, "value", m.ModelingStatuses[statusKey], "desired number", m.policyDesiredNumber(statusKey))
 
 	modelingStatus := m.ModelingStatuses[statusKey]
 	complete := false
 
 	// Build profiles and Update ArmorProfile for the BehaviorModeling mode.
-	if modelingStatus.CompletedNumber >= m.desiredNumber {
+	if modelingStatus.CompletedNumber >= m.policyDesiredNumber(statusKey) {
 		complete = true
 
 		logger.Info("3.1 all modeller completed")
 
 		if needUpdateAPM {
+			// Collapse the families of the per-instance file paths into wildcard patterns
+			level := m.retrievePathGeneralizationLevel(behaviorData.Namespace, behaviorData.ProfileName)
+			logger.Info("3.1.1 generalize the file paths of behavior model", "level", level)
+			generalizeAppArmorResult(&apm.Data.DynamicResult.AppArmor, level)
+
 			// Build the final AppArmor Profile
-			logger.Info("3.1.1 build AppArmor profile with behavior model")
+			logger.Info("3.1.2 build AppArmor profile with behavior model")
 			apparmorProfile, err := apparmorprofile.GenerateProfileWithBehaviorModel(&apm.Data.DynamicResult, m.debug)
 			if err != nil {
-				logger.Info("3.1.1 no AppArmor profile built", "info", err)
+				logger.Info("3.1.2 no AppArmor profile built", "info", err)
 			}
 
 			// Build the final Seccomp Profile
-			logger.Info("3.1.2 build AppArmor profile with behavior model")
+			logger.Info("3.1.3 build AppArmor profile with behavior model")
 			seccompProfile, err := seccompprofile.GenerateProfileWithBehaviorModel(&apm.Data.DynamicResult)
 			if err != nil {
-				logger.Info("3.1.2 no Seccomp profile built", "info", err)
+				logger.Info("3.1.3 no Seccomp profile built", "info", err)
 			}
 
 			// Update ArmorProfileModel object
 			logger.Info("3.2 update profile to ArmorProfileModel", "namespace", behaviorData.Namespace, "name", behaviorData.ProfileName)
 			apm.Data.Profile.Content = apparmorProfile
 			apm.Data.Profile.SeccompContent = seccompProfile
+			// The BPF enforcer doesn't support the behavior modeling, so the imported BPF profile is dropped too
+			apm.Data.Profile.BpfContent = nil
 			apm.Data.Profile.Name = behaviorData.ProfileName
 			apm.Data.Profile.Enforcer = ""
 			apm.Data.Profile.Mode = ""
+			// The profile built by the behavior modeling replaces the imported one
+			artifact.ClearImportedProfile(apm)
 			apm, err = m.updateArmorProfileModel(apm)
 			if err != nil {
 				logger.Error(err, "m.updateArmorProfileModel()")
@@ -465,6 +497,64 @@ func (m *StatusManager) syncData(data string) error {
 	return nil
 }
 
+// syncFeedback merges the AppArmor complain records of the DefenseInDepth mode into the ArmorProfileModel object,
+// and rebuilds the profile with the behavior model. The ArmorProfile object will be updated with the refined profile.
+func (m *StatusManager) syncFeedback(behaviorData *varmortypes.BehaviorData, logger logr.Logger) error {
+	apm, err := m.varmorInterface.ArmorProfileModels(behaviorData.Namespace).Get(context.Background(), behaviorData.ProfileName, metav1.GetOptions{})
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			logger.Info("2. no ArmorProfileModel found, the feedback is ignored", "profile", behaviorData.ProfileName)
+			return nil
+		}
+		logger.Error(err, "m.varmorInterface.ArmorProfileModels().Get()")
+		return err
+	}
+
+	// The imported profile can't be refined, and the rebuilt profile can't pass the verification since it's no
+	// longer the signed one
+	if artifact.IsImportedProfile(apm) {
+		logger.Info("2. the profile was imported, the feedback is ignored", "profile", behaviorData.ProfileName)
+		return nil
+	}
+
+	// Only the profile built by the behavior modeling can be refined, otherwise the rebuilt profile would only
+	// contain the behaviors of the feedback.
+	if len(apm.Data.DynamicResult.AppArmor.Profiles) == 0 {
+		logger.Info("2. the profile wasn't built by the behavior modeling, the feedback is ignored", "profile", behaviorData.ProfileName)
+		return nil
+	}
+
+	oldDynamicResult := apm.Data.DynamicResult.DeepCopy()
+	mergeAppArmorResult(apm, behaviorData)
+	if reflect.DeepEqual(oldDynamicResult, &apm.Data.DynamicResult) {
+		logger.Info("2. no new behavior data in the feedback", "profile", behaviorData.ProfileName, "node", behaviorData.NodeName)
+		return nil
+	}
+
+	logger.Info("2. rebuild AppArmor profile with the feedback", "namespace", behaviorData.Namespace, "name", behaviorData.ProfileName)
+	apparmorProfile, err := apparmorprofile.GenerateProfileWithBehaviorModel(&apm.Data.DynamicResult, m.debug)
+	if err != nil {
+		logger.Error(err, "apparmorprofile.GenerateProfileWithBehaviorModel()")
+		return nil
+	}
+	apm.Data.Profile.Content = apparmorProfile
+	_, err = m.updateArmorProfileModel(apm)
+	if err != nil {
+		logger.Error(err, "updateArmorProfileModel()")
+		return err
+	}
+
+	statusKey, err := generateModelingStatusKey(behaviorData)
+	if err != nil {
+		logger.Error(err, "generateModelingStatusKey()", "behavior data", behaviorData)
+		return nil
+	}
+	logger.Info("3. send signal to UpdateModeCh", "status key", statusKey)
+	m.UpdateModeCh <- statusKey
+
+	return nil
+}
+
 func (m *StatusManager) handleDataErr(err error, data interface{}) {
 	logger := m.log
 	if err == nil {
@@ -0,0 +1,179 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+const (
+	gatekeeperAPIVersion = "externaldata.gatekeeper.sh/v1beta1"
+	gatekeeperResponse   = "ProviderResponse"
+)
+
+// gatekeeperWorkload is the workload described by the key of the Gatekeeper provider request
+type gatekeeperWorkload struct {
+	namespace string
+	kind      string
+	name      string
+	labels    labels.Set
+}
+
+// parseGatekeeperKey parses the key with the format "<namespace>/<kind>/<name>:<labels>"
+func parseGatekeeperKey(key string) (*gatekeeperWorkload, error) {
+	ref, labelStr, _ := strings.Cut(key, ":")
+
+	fields := strings.Split(ref, "/")
+	if len(fields) != 3 || fields[1] == "" || fields[2] == "" {
+		return nil, fmt.Errorf("the key must have the format <namespace>/<kind>/<name>:<labels>")
+	}
+
+	set, err := labels.ConvertSelectorToLabelsMap(labelStr)
+	if err != nil {
+		return nil, fmt.Errorf("the labels of the key are invalid: %w", err)
+	}
+
+	return &gatekeeperWorkload{
+		namespace: fields[0],
+		kind:      fields[1],
+		name:      fields[2],
+		labels:    set,
+	}, nil
+}
+
+// matchTarget returns true if the target of the policy matches the workload
+func matchTarget(target *varmor.Target, w *gatekeeperWorkload) bool {
+	if target.Kind != w.kind {
+		return false
+	}
+
+	if target.Name != "" {
+		return target.Name == w.name
+	}
+
+	if target.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(target.Selector)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(w.labels)
+	}
+
+	return false
+}
+
+// matchPolicies returns the VarmorPolicy and VarmorClusterPolicy objects which match the workload
+func matchPolicies(vps []varmor.VarmorPolicy, vcps []varmor.VarmorClusterPolicy, w *gatekeeperWorkload) []varmortypes.PolicyMatch {
+	matches := []varmortypes.PolicyMatch{}
+
+	for _, vcp := range vcps {
+		if matchTarget(&vcp.Spec.Target, w) {
+			matches = append(matches, varmortypes.PolicyMatch{
+				Name:         vcp.Name,
+				ClusterScope: true,
+				Enforcer:     vcp.Spec.Policy.Enforcer,
+				Mode:         vcp.Spec.Policy.Mode,
+				Ready:        vcp.Status.Ready,
+			})
+		}
+	}
+
+	for _, vp := range vps {
+		if vp.Namespace == w.namespace && matchTarget(&vp.Spec.Target, w) {
+			matches = append(matches, varmortypes.PolicyMatch{
+				Namespace: vp.Namespace,
+				Name:      vp.Name,
+				Enforcer:  vp.Spec.Policy.Enforcer,
+				Mode:      vp.Spec.Policy.Mode,
+				Ready:     vp.Status.Ready,
+			})
+		}
+	}
+
+	return matches
+}
+
+func gatekeeperSystemError(c *gin.Context, err error) {
+	var resp varmortypes.GatekeeperProviderResponse
+	resp.APIVersion = gatekeeperAPIVersion
+	resp.Kind = gatekeeperResponse
+	resp.Response.SystemError = err.Error()
+	c.JSON(http.StatusOK, resp)
+}
+
+// GatekeeperProvider is an HTTP interface that implements the external data provider of OPA Gatekeeper.
+// It returns the policies matching the workloads in the request, so the constraints can require the
+// workloads to be protected by vArmor, e.g. "every Deployment in namespace X must be matched by an
+// EnhanceProtect policy".
+func (m *StatusManager) GatekeeperProvider(c *gin.Context) {
+	logger := m.log.WithName("GatekeeperProvider()")
+
+	reqBody, err := getHttpBody(c)
+	if err != nil {
+		logger.Error(err, "getHttpBody()")
+		gatekeeperSystemError(c, err)
+		return
+	}
+
+	var req varmortypes.GatekeeperProviderRequest
+	err = json.Unmarshal(reqBody, &req)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal()")
+		gatekeeperSystemError(c, err)
+		return
+	}
+
+	vps, err := m.varmorInterface.VarmorPolicies(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		logger.Error(err, "VarmorPolicies().List()")
+		gatekeeperSystemError(c, err)
+		return
+	}
+
+	vcps, err := m.varmorInterface.VarmorClusterPolicies().List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		logger.Error(err, "VarmorClusterPolicies().List()")
+		gatekeeperSystemError(c, err)
+		return
+	}
+
+	var resp varmortypes.GatekeeperProviderResponse
+	resp.APIVersion = gatekeeperAPIVersion
+	resp.Kind = gatekeeperResponse
+	resp.Response.Idempotent = true
+	for _, key := range req.Request.Keys {
+		item := varmortypes.GatekeeperItem{Key: key}
+		w, err := parseGatekeeperKey(key)
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			item.Value = matchPolicies(vps.Items, vcps.Items, w)
+		}
+		resp.Response.Items = append(resp.Response.Items, item)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
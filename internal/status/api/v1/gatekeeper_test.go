@@ -0,0 +1,100 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_matchPolicies(t *testing.T) {
+	vps := []varmor.VarmorPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "demo", Name: "by-name"},
+			Spec: varmor.VarmorPolicySpec{
+				Target: varmor.Target{Kind: "Deployment", Name: "nginx"},
+				Policy: varmor.Policy{Enforcer: "BPF", Mode: "EnhanceProtect"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "other-namespace"},
+			Spec: varmor.VarmorPolicySpec{
+				Target: varmor.Target{Kind: "Deployment", Name: "nginx"},
+				Policy: varmor.Policy{Enforcer: "BPF", Mode: "EnhanceProtect"},
+			},
+		},
+	}
+	vcps := []varmor.VarmorClusterPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "by-selector"},
+			Spec: varmor.VarmorPolicySpec{
+				Target: varmor.Target{
+					Kind:     "Deployment",
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}},
+				},
+				Policy: varmor.Policy{Enforcer: "AppArmor", Mode: "AlwaysAllow"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		key             string
+		expectedErr     bool
+		expectedMatches []string
+	}{
+		{
+			name:            "nameAndSelector",
+			key:             "demo/Deployment/nginx:app=nginx,tier=web",
+			expectedMatches: []string{"by-selector", "by-name"},
+		},
+		{
+			name:            "nameOnly",
+			key:             "demo/Deployment/nginx",
+			expectedMatches: []string{"by-name"},
+		},
+		{
+			name:            "kindMismatch",
+			key:             "demo/StatefulSet/nginx:app=nginx",
+			expectedMatches: []string{},
+		},
+		{
+			name:        "invalidKey",
+			key:         "demo/nginx",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := parseGatekeeperKey(tc.key)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+
+			names := []string{}
+			for _, m := range matchPolicies(vps, vcps, w) {
+				names = append(names, m.Name)
+			}
+			assert.DeepEqual(t, names, tc.expectedMatches)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// generalizationOptions are the thresholds of collapsing the families of file paths into wildcard patterns
+type generalizationOptions struct {
+	// minFamilySize is the minimum number of the sibling files that share the same shape
+	minFamilySize int
+	// minLiteralLength is the minimum number of the letters left in the shape
+	minLiteralLength int
+	// maxDirectoryEntries is the maximum number of the files in one directory, the files of the directory are
+	// collapsed into "<directory>/*" once it's exceeded. Zero means no limit.
+	maxDirectoryEntries int
+}
+
+var generalizationLevels = map[string]generalizationOptions{
+	varmortypes.PathGeneralizationConservative: {minFamilySize: 4, minLiteralLength: 3},
+	varmortypes.PathGeneralizationAggressive:   {minFamilySize: 2, minLiteralLength: 1, maxDirectoryEntries: 16},
+}
+
+var (
+	wordRegexp = regexp.MustCompile(`[0-9A-Za-z]+`)
+	hexRegexp  = regexp.MustCompile(`^[0-9a-fA-F]{6,}$`)
+)
+
+// fileShape replaces the variable part of the words that contain digits in the file name with "*". The hexadecimal
+// word is replaced entirely, the other word keeps its leading letters, e.g. "worker-8f3a9c.log" -> "worker-*.log",
+// "log12.txt" -> "log*.txt".
+func fileShape(name string) string {
+	shape := wordRegexp.ReplaceAllStringFunc(name, func(word string) string {
+		i := strings.IndexAny(word, "0123456789")
+		if i == -1 {
+			return word
+		}
+		if hexRegexp.MatchString(word) {
+			return "*"
+		}
+		return word[:i] + "*"
+	})
+	return asterisksRegexp.ReplaceAllString(shape, "*")
+}
+
+// literalLength returns the number of the letters in the shape
+func literalLength(shape string) int {
+	n := 0
+	for _, c := range shape {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			n++
+		}
+	}
+	return n
+}
+
+// splitFilePath returns the directory and the file name of the path. It returns false if the path can't be
+// generalized, e.g. the path of a directory, the path contains "**" or the AppArmor globbing syntax except "*".
+func splitFilePath(path string) (string, string, bool) {
+	if strings.Contains(path, "**") || strings.ContainsAny(path, "?[]{}") {
+		return "", "", false
+	}
+	i := strings.LastIndex(path, "/")
+	if i == -1 || i == len(path)-1 {
+		return "", "", false
+	}
+	return path[:i+1], path[i+1:], true
+}
+
+// collapseFiles replaces the paths of the files which have the same key with the pattern returned by the keyFunc,
+// and unions their permissions. The files with an empty key or a key of less than minCount files are left as they
+// are. The order of the files is kept.
+func collapseFiles(files []varmor.File, minCount int, keyFunc func(varmor.File) (string, string)) []varmor.File {
+	counts := make(map[string]int)
+	for _, file := range files {
+		if key, _ := keyFunc(file); key != "" {
+			counts[key]++
+		}
+	}
+
+	collapsed := make([]varmor.File, 0, len(files))
+	indexes := make(map[string]int)
+	for _, file := range files {
+		key, pattern := keyFunc(file)
+		if key == "" || counts[key] < minCount {
+			collapsed = append(collapsed, file)
+			continue
+		}
+
+		if index, ok := indexes[key]; ok {
+			collapsed[index].Permissions = unionStrings(collapsed[index].Permissions, file.Permissions)
+			sort.Strings(collapsed[index].Permissions)
+			if collapsed[index].OldPath == "" && file.OldPath != "" {
+				collapsed[index].OldPath = file.OldPath
+			}
+			continue
+		}
+
+		indexes[key] = len(collapsed)
+		collapsed = append(collapsed, varmor.File{
+			Path:        pattern,
+			Owner:       file.Owner,
+			Permissions: unionStrings(make([]string, 0, len(file.Permissions)), file.Permissions),
+			OldPath:     file.OldPath,
+		})
+	}
+	return collapsed
+}
+
+// generalizeAppArmorResult collapses the families of the file paths that are generated per instance into wildcard
+// patterns, so the profiles built with the behavior model don't exceed the rule limits of the enforcers. A family
+// is the sibling files whose names have the same shape, e.g. "/tmp/worker-8f3a9c" and "/tmp/worker-1b2e4d" are
+// collapsed into "/tmp/worker-*". The aggressiveness is controlled by the level. The execution rules are never
+// generalized, since they determine which programs can be launched.
+func generalizeAppArmorResult(apparmor *varmor.AppArmor, level string) {
+	if level == "" {
+		level = varmortypes.PathGeneralizationConservative
+	}
+	options, ok := generalizationLevels[level]
+	if !ok || len(apparmor.Files) == 0 {
+		return
+	}
+
+	files := collapseFiles(apparmor.Files, options.minFamilySize, func(file varmor.File) (string, string) {
+		dir, name, ok := splitFilePath(file.Path)
+		if !ok {
+			return "", ""
+		}
+		shape := fileShape(name)
+		if literalLength(shape) < options.minLiteralLength {
+			return "", ""
+		}
+		return fmt.Sprintf("%s%s|%t", dir, shape, file.Owner), dir + shape
+	})
+
+	if options.maxDirectoryEntries > 0 {
+		files = collapseFiles(files, options.maxDirectoryEntries+1, func(file varmor.File) (string, string) {
+			dir, _, ok := splitFilePath(file.Path)
+			if !ok {
+				return "", ""
+			}
+			return fmt.Sprintf("%s*|%t", dir, file.Owner), dir + "*"
+		})
+	}
+
+	apparmor.Files = files
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_fileShape(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "worker-8f3a9c", expected: "worker-*"},
+		{name: "worker-8f3a9c.lock", expected: "worker-*.lock"},
+		{name: "log12.txt", expected: "log*.txt"},
+		{name: "tmpa1b2c3d4", expected: "tmpa*"},
+		{name: "libc.so.6", expected: "libc.so.*"},
+		{name: "config.yaml", expected: "config.yaml"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, fileShape(tc.name), tc.expected)
+		})
+	}
+}
+
+func Test_generalizeAppArmorResult(t *testing.T) {
+	workers := []varmor.File{
+		{Path: "/etc/app.conf", Permissions: []string{"r"}},
+		{Path: "/tmp/worker-8f3a9c", Permissions: []string{"w"}},
+		{Path: "/tmp/worker-1b2e4d", Permissions: []string{"r"}},
+		{Path: "/tmp/worker-77aa01", Owner: true, Permissions: []string{"w"}},
+		{Path: "/tmp/worker-c0ffee1", Permissions: []string{"w"}},
+		{Path: "/tmp/worker-0d15ea5e", Permissions: []string{"k"}},
+		{Path: "/tmp/**", Permissions: []string{"r"}},
+	}
+
+	testCases := []struct {
+		name     string
+		level    string
+		files    []varmor.File
+		expected []varmor.File
+	}{
+		{
+			name:     "disabled",
+			level:    varmortypes.PathGeneralizationDisabled,
+			files:    workers,
+			expected: workers,
+		},
+		{
+			name:  "conservative",
+			level: varmortypes.PathGeneralizationConservative,
+			files: workers,
+			expected: []varmor.File{
+				{Path: "/etc/app.conf", Permissions: []string{"r"}},
+				{Path: "/tmp/worker-*", Permissions: []string{"k", "r", "w"}},
+				{Path: "/tmp/worker-77aa01", Owner: true, Permissions: []string{"w"}},
+				{Path: "/tmp/**", Permissions: []string{"r"}},
+			},
+		},
+		{
+			name:  "conservative with small family",
+			level: "",
+			files: workers[:3],
+			expected: []varmor.File{
+				{Path: "/etc/app.conf", Permissions: []string{"r"}},
+				{Path: "/tmp/worker-8f3a9c", Permissions: []string{"w"}},
+				{Path: "/tmp/worker-1b2e4d", Permissions: []string{"r"}},
+			},
+		},
+		{
+			name:  "aggressive",
+			level: varmortypes.PathGeneralizationAggressive,
+			files: workers[:3],
+			expected: []varmor.File{
+				{Path: "/etc/app.conf", Permissions: []string{"r"}},
+				{Path: "/tmp/worker-*", Permissions: []string{"r", "w"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apparmor := varmor.AppArmor{Files: append([]varmor.File{}, tc.files...)}
+			generalizeAppArmorResult(&apparmor, tc.level)
+			assert.DeepEqual(t, apparmor.Files, tc.expected)
+		})
+	}
+}
+
+func Test_generalizeAppArmorResultDirectory(t *testing.T) {
+	apparmor := varmor.AppArmor{}
+	for i := 0; i < 17; i++ {
+		apparmor.Files = append(apparmor.Files, varmor.File{Path: fmt.Sprintf("/var/cache/%c.idx", 'a'+i), Permissions: []string{"r"}})
+	}
+
+	generalizeAppArmorResult(&apparmor, varmortypes.PathGeneralizationAggressive)
+	assert.DeepEqual(t, apparmor.Files, []varmor.File{{Path: "/var/cache/*", Permissions: []string{"r"}}})
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+// defaultLifecycleHookTimeout is the timeout of the lifecycle hooks that don't specify it
+const defaultLifecycleHookTimeout = 10 * time.Second
+
+// NewLifecycleEvent returns the lifecycle event of the policy whose profile is the ArmorProfile object.
+// The policyNamespace is empty for VarmorClusterPolicy.
+func NewLifecycleEvent(eventType varmortypes.LifecycleEventType, policyNamespace, policyName string, ap *varmor.ArmorProfile) varmortypes.LifecycleEvent {
+	return varmortypes.LifecycleEvent{
+		Type:            eventType,
+		PolicyNamespace: policyNamespace,
+		PolicyName:      policyName,
+		ArmorProfile:    ap.Name,
+		Enforcer:        ap.Spec.Profile.Enforcer,
+		Mode:            ap.Spec.Profile.Mode,
+		Timestamp:       time.Now(),
+	}
+}
+
+// subscribes returns whether the lifecycle hook subscribes to the event
+func subscribes(hook *varmor.LifecycleHook, eventType varmortypes.LifecycleEventType) bool {
+	return len(hook.Events) == 0 || varmorutils.InStringArray(string(eventType), hook.Events)
+}
+
+// invokeLifecycleHook POSTs the event to the lifecycle hook
+func invokeLifecycleHook(hook *varmor.LifecycleHook, event *varmortypes.LifecycleEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	timeout := defaultLifecycleHookTimeout
+	if hook.TimeoutSeconds > 0 {
+		timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("the lifecycle hook responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NotifyLifecycleHooks invokes the lifecycle hooks that subscribe to the event asynchronously.
+// The failures are logged, and they don't block the enforcement.
+func (m *StatusManager) NotifyLifecycleHooks(hooks []varmor.LifecycleHook, event varmortypes.LifecycleEvent) {
+	logger := m.log.WithName("NotifyLifecycleHooks()")
+
+	for i := range hooks {
+		hook := hooks[i]
+		if !subscribes(&hook, event.Type) {
+			continue
+		}
+
+		go func() {
+			err := invokeLifecycleHook(&hook, &event)
+			if err != nil {
+				logger.Error(err, "failed to invoke the lifecycle hook", "url", hook.URL, "event", event.Type,
+					"policy namespace", event.PolicyNamespace, "policy name", event.PolicyName)
+				return
+			}
+			logger.V(3).Info("the lifecycle hook invoked", "url", hook.URL, "event", event.Type,
+				"policy namespace", event.PolicyNamespace, "policy name", event.PolicyName)
+		}()
+	}
+}
+
+// newEnforcementFailures returns the nodes that failed to load the profile of the policy since the last call, and
+// their messages. The failures that have been returned are skipped unless their messages changed.
+func (m *StatusManager) newEnforcementFailures(statusKey string, policyStatus *varmortypes.PolicyStatus) map[string]string {
+	notified, ok := m.enforcementFailures[statusKey]
+	if !ok {
+		notified = make(map[string]string)
+		m.enforcementFailures[statusKey] = notified
+	}
+
+	failures := make(map[string]string)
+	for nodeName, message := range policyStatus.NodeMessages {
+		if message == string(varmortypes.ArmorProfileReady) {
+			delete(notified, nodeName)
+			continue
+		}
+		if notified[nodeName] != message {
+			notified[nodeName] = message
+			failures[nodeName] = message
+		}
+	}
+	for nodeName := range notified {
+		if _, ok := policyStatus.NodeMessages[nodeName]; !ok {
+			delete(notified, nodeName)
+		}
+	}
+	return failures
+}
+
+// notifyEnforcementEvents notifies the lifecycle hooks of the policy that the profile has been loaded by all agents,
+// or failed to be loaded on some nodes.
+func (m *StatusManager) notifyEnforcementEvents(statusKey string, policyNamespace, policyName string, policy *varmor.Policy,
+	ap *varmor.ArmorProfile, policyStatus *varmortypes.PolicyStatus, wasReady bool, ready bool) {
+
+	failures := m.newEnforcementFailures(statusKey, policyStatus)
+	if len(policy.LifecycleHooks) == 0 {
+		return
+	}
+
+	for nodeName, message := range failures {
+		event := NewLifecycleEvent(varmortypes.EnforcementFailedEvent, policyNamespace, policyName, ap)
+		event.NodeName = nodeName
+		event.Message = message
+		m.NotifyLifecycleHooks(policy.LifecycleHooks, event)
+	}
+
+	if ready && !wasReady && policyStatus.FailedNumber == 0 {
+		event := NewLifecycleEvent(varmortypes.PostEnforceEvent, policyNamespace, policyName, ap)
+		event.Message = fmt.Sprintf("the profile has been loaded by %d agents", policyStatus.SuccessedNumber)
+		m.NotifyLifecycleHooks(policy.LifecycleHooks, event)
+	}
+}
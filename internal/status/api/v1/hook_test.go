@@ -0,0 +1,81 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_invokeLifecycleHook(t *testing.T) {
+	var received varmortypes.LifecycleEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	ap := &varmor.ArmorProfile{}
+	ap.Name = "varmor-default-test"
+	ap.Spec.Profile.Enforcer = "BPF"
+	ap.Spec.Profile.Mode = "enforce"
+	event := NewLifecycleEvent(varmortypes.PreEnforceEvent, "default", "test", ap)
+
+	err := invokeLifecycleHook(&varmor.LifecycleHook{URL: server.URL + "/hook"}, &event)
+	assert.NilError(t, err)
+	assert.Equal(t, received.Type, varmortypes.PreEnforceEvent)
+	assert.Equal(t, received.PolicyName, "test")
+	assert.Equal(t, received.ArmorProfile, "varmor-default-test")
+	assert.Equal(t, received.Mode, "enforce")
+
+	err = invokeLifecycleHook(&varmor.LifecycleHook{URL: server.URL + "/fail"}, &event)
+	assert.ErrorContains(t, err, "status code 500")
+
+	assert.Equal(t, subscribes(&varmor.LifecycleHook{}, varmortypes.PostEnforceEvent), true)
+	assert.Equal(t, subscribes(&varmor.LifecycleHook{Events: []string{"PreEnforce"}}, varmortypes.PostEnforceEvent), false)
+}
+
+func Test_newEnforcementFailures(t *testing.T) {
+	m := &StatusManager{
+		enforcementFailures: make(map[string]map[string]string),
+	}
+	key := "default/test"
+	policyStatus := varmortypes.PolicyStatus{
+		NodeMessages: map[string]string{
+			"node-a": string(varmortypes.ArmorProfileReady),
+			"node-b": "failed to load the profile",
+		},
+	}
+
+	assert.DeepEqual(t, m.newEnforcementFailures(key, &policyStatus), map[string]string{"node-b": "failed to load the profile"})
+	// The failures are only returned once
+	assert.DeepEqual(t, m.newEnforcementFailures(key, &policyStatus), map[string]string{})
+
+	// The node fails again after it recovered
+	policyStatus.NodeMessages["node-b"] = string(varmortypes.ArmorProfileReady)
+	assert.DeepEqual(t, m.newEnforcementFailures(key, &policyStatus), map[string]string{})
+	policyStatus.NodeMessages["node-b"] = "failed to load the profile"
+	assert.DeepEqual(t, m.newEnforcementFailures(key, &policyStatus), map[string]string{"node-b": "failed to load the profile"})
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	bpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// compatibilityUpdateInterval is the interval for evaluating the compatibilities of the policies
+const compatibilityUpdateInterval = 5 * time.Minute
+
+// Inventory is an HTTP interface used for receiving the NodeInventory come from agents
+func (m *StatusManager) Inventory(c *gin.Context) {
+	logger := m.log.WithName("Inventory()")
+
+	reqBody, err := getHttpBody(c)
+	if err != nil {
+		logger.Error(err, "getHttpBody()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	var inventory varmortypes.NodeInventory
+	err = json.Unmarshal(reqBody, &inventory)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	if inventory.NodeName == "" {
+		err = fmt.Errorf("request is illegal")
+		logger.Error(err, "bad request body")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	select {
+	case m.UpdateInventoryCh <- inventory:
+		logger.V(3).Info("receive inventory from agent", "node", inventory.NodeName)
+	default:
+		// The agent reports it again later
+		logger.Info("the inventory channel is full, drop the inventory", "node", inventory.NodeName)
+	}
+}
+
+// evaluateNodeCompatibility returns the reasons why the node can't fully enforce the policy. The node can't
+// enforce the policy at all if supported is false.
+func evaluateNodeCompatibility(policy *varmor.Policy, inventory *varmortypes.NodeInventory) (supported bool, reasons []string) {
+	if !inventory.AppArmor && !inventory.BPF {
+		return false, []string{fmt.Sprintf("the agent can't run since neither the AppArmor LSM nor the BPF LSM is supported (enabled LSMs: %s)", strings.Join(inventory.LSMs, ","))}
+	}
+
+	enforcer := varmortypes.GetEnforcerType(policy.Enforcer)
+
+	if (enforcer & varmortypes.BestAvailable) != 0 {
+		// The target containers reference the AppArmor profile on every node, even if the BPF enforcer is selected.
+		if !inventory.AppArmor {
+			return false, []string{fmt.Sprintf("the AppArmor LSM is required by the BestAvailable enforcer (enabled LSMs: %s)", strings.Join(inventory.LSMs, ","))}
+		}
+		if inventory.BPF && !inventory.BpfFeatures[bpfenforcer.FeatureSelfTest] {
+			reasons = append(reasons, "the self-test of the BPF enforcer failed")
+		}
+		return true, reasons
+	}
+
+	if (enforcer & varmortypes.AppArmor) != 0 {
+		if inventory.AppArmor {
+			supported = true
+		} else {
+			reasons = append(reasons, fmt.Sprintf("the AppArmor enforcer is unsupported (enabled LSMs: %s)", strings.Join(inventory.LSMs, ",")))
+		}
+	}
+
+	if (enforcer & varmortypes.BPF) != 0 {
+		if inventory.BPF {
+			supported = true
+			if !inventory.BpfFeatures[bpfenforcer.FeatureSelfTest] {
+				reasons = append(reasons, "the self-test of the BPF enforcer failed")
+			}
+			if !inventory.BpfFeatures[bpfenforcer.FeatureViolationEvents] {
+				reasons = append(reasons, pkgtypes.ViolationEventsUnsupportedReason)
+			}
+		} else {
+			reasons = append(reasons, "the BPF enforcer is disabled or unsupported")
+		}
+	}
+
+	if (enforcer & varmortypes.Seccomp) != 0 {
+		if inventory.Seccomp {
+			supported = true
+		} else {
+			reasons = append(reasons, "the Seccomp enforcer is unsupported")
+		}
+	}
+
+	if (enforcer & varmortypes.Landlock) != 0 {
+		if inventory.Landlock {
+			supported = true
+		} else {
+			reasons = append(reasons, "the Landlock enforcer is disabled or unsupported")
+		}
+	}
+
+	if (enforcer & varmortypes.SELinux) != 0 {
+		if inventory.SELinux {
+			supported = true
+		} else {
+			reasons = append(reasons, "the SELinux enforcer is disabled or unsupported")
+		}
+	}
+
+	return supported, reasons
+}
+
+// evaluateCompatibility evaluates which nodes can fully, partially or not enforce the policy. The nodes that
+// don't match the node selector are excluded. It returns nil if no inventory has been reported.
+func evaluateCompatibility(policy *varmor.Policy, nodeSelector map[string]string, inventories map[string]varmortypes.NodeInventory) *varmor.PolicyCompatibility {
+	if len(inventories) == 0 {
+		return nil
+	}
+
+	compatibility := varmor.PolicyCompatibility{}
+	selector := labels.SelectorFromSet(nodeSelector)
+	for _, inventory := range inventories {
+		if !selector.Matches(labels.Set(inventory.Labels)) {
+			continue
+		}
+
+		supported, reasons := evaluateNodeCompatibility(policy, &inventory)
+		node := varmor.NodeCompatibility{
+			NodeName:      inventory.NodeName,
+			KernelVersion: inventory.KernelVersion,
+			Reasons:       reasons,
+		}
+		switch {
+		case !supported:
+			compatibility.UnsupportedNodes = append(compatibility.UnsupportedNodes, node)
+		case len(reasons) != 0:
+			compatibility.PartialNodes = append(compatibility.PartialNodes, node)
+		default:
+			compatibility.FullNodes++
+		}
+	}
+
+	sort.Slice(compatibility.PartialNodes, func(i, j int) bool {
+		return compatibility.PartialNodes[i].NodeName < compatibility.PartialNodes[j].NodeName
+	})
+	sort.Slice(compatibility.UnsupportedNodes, func(i, j int) bool {
+		return compatibility.UnsupportedNodes[i].NodeName < compatibility.UnsupportedNodes[j].NodeName
+	})
+	return &compatibility
+}
+
+// updatePolicyCompatibilities evaluates the compatibilities of all policies with the inventories of the nodes,
+// and updates them in VarmorPolicy/status and VarmorClusterPolicy/status.
+func (m *StatusManager) updatePolicyCompatibilities() error {
+	vcps, err := m.varmorInterface.VarmorClusterPolicies().List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		return err
+	}
+	for _, vcp := range vcps.Items {
+		compatibility := evaluateCompatibility(&vcp.Spec.Policy, vcp.Spec.NodeSelector, m.NodeInventories)
+		if reflect.DeepEqual(vcp.Status.Compatibility, compatibility) {
+			continue
+		}
+
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest, err := m.varmorInterface.VarmorClusterPolicies().Get(context.Background(), vcp.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			latest.Status.Compatibility = compatibility
+			_, err = m.varmorInterface.VarmorClusterPolicies().UpdateStatus(context.Background(), latest, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil && !k8errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	vps, err := m.varmorInterface.VarmorPolicies(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		return err
+	}
+	for _, vp := range vps.Items {
+		compatibility := evaluateCompatibility(&vp.Spec.Policy, vp.Spec.NodeSelector, m.NodeInventories)
+		if reflect.DeepEqual(vp.Status.Compatibility, compatibility) {
+			continue
+		}
+
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest, err := m.varmorInterface.VarmorPolicies(vp.Namespace).Get(context.Background(), vp.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			latest.Status.Compatibility = compatibility
+			_, err = m.varmorInterface.VarmorPolicies(vp.Namespace).UpdateStatus(context.Background(), latest, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil && !k8errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
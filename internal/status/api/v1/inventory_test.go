@@ -0,0 +1,124 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	bpfenforcer "github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_evaluateCompatibility(t *testing.T) {
+	inventories := map[string]varmortypes.NodeInventory{
+		"node-a": {
+			NodeName:      "node-a",
+			KernelVersion: "6.1.0",
+			LSMs:          []string{"lockdown", "capability", "apparmor", "bpf"},
+			AppArmor:      true,
+			BPF:           true,
+			Seccomp:       true,
+			BpfFeatures:   map[string]bool{bpfenforcer.FeatureSelfTest: true, bpfenforcer.FeatureViolationEvents: true},
+			Labels:        map[string]string{"pool": "general"},
+		},
+		"node-b": {
+			NodeName:      "node-b",
+			KernelVersion: "5.4.0",
+			LSMs:          []string{"capability", "apparmor"},
+			AppArmor:      true,
+			Seccomp:       true,
+			Labels:        map[string]string{"pool": "general"},
+		},
+		"node-c": {
+			NodeName:      "node-c",
+			KernelVersion: "4.19.0",
+			LSMs:          []string{"capability", "selinux"},
+			Seccomp:       true,
+			Labels:        map[string]string{"pool": "legacy"},
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		policy       varmor.Policy
+		nodeSelector map[string]string
+		expected     *varmor.PolicyCompatibility
+	}{
+		{
+			name:   "AppArmorSeccomp",
+			policy: varmor.Policy{Enforcer: "AppArmorSeccomp"},
+			expected: &varmor.PolicyCompatibility{
+				FullNodes: 2,
+				UnsupportedNodes: []varmor.NodeCompatibility{
+					{NodeName: "node-c", KernelVersion: "4.19.0", Reasons: []string{"the agent can't run since neither the AppArmor LSM nor the BPF LSM is supported (enabled LSMs: capability,selinux)"}},
+				},
+			},
+		},
+		{
+			name:   "AppArmorBPF",
+			policy: varmor.Policy{Enforcer: "AppArmorBPF"},
+			expected: &varmor.PolicyCompatibility{
+				FullNodes: 1,
+				PartialNodes: []varmor.NodeCompatibility{
+					{NodeName: "node-b", KernelVersion: "5.4.0", Reasons: []string{"the BPF enforcer is disabled or unsupported"}},
+				},
+				UnsupportedNodes: []varmor.NodeCompatibility{
+					{NodeName: "node-c", KernelVersion: "4.19.0", Reasons: []string{"the agent can't run since neither the AppArmor LSM nor the BPF LSM is supported (enabled LSMs: capability,selinux)"}},
+				},
+			},
+		},
+		{
+			name:   "BestAvailable",
+			policy: varmor.Policy{Enforcer: "BestAvailable"},
+			expected: &varmor.PolicyCompatibility{
+				FullNodes: 2,
+				UnsupportedNodes: []varmor.NodeCompatibility{
+					{NodeName: "node-c", KernelVersion: "4.19.0", Reasons: []string{"the agent can't run since neither the AppArmor LSM nor the BPF LSM is supported (enabled LSMs: capability,selinux)"}},
+				},
+			},
+		},
+		{
+			name:         "BPF with node selector",
+			policy:       varmor.Policy{Enforcer: "BPF"},
+			nodeSelector: map[string]string{"pool": "general"},
+			expected: &varmor.PolicyCompatibility{
+				FullNodes: 1,
+				UnsupportedNodes: []varmor.NodeCompatibility{
+					{NodeName: "node-b", KernelVersion: "5.4.0", Reasons: []string{"the BPF enforcer is disabled or unsupported"}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			compatibility := evaluateCompatibility(&tc.policy, tc.nodeSelector, inventories)
+			assert.DeepEqual(t, compatibility, tc.expected)
+		})
+	}
+
+	assert.Assert(t, evaluateCompatibility(&varmor.Policy{Enforcer: "BPF"}, nil, nil) == nil)
+
+	// The violations aren't reported if the BPF program of the agent doesn't emit the violation events
+	inventory := inventories["node-a"]
+	inventory.BpfFeatures = map[string]bool{bpfenforcer.FeatureSelfTest: true}
+	supported, reasons := evaluateNodeCompatibility(&varmor.Policy{Enforcer: "BPF"}, &inventory)
+	assert.Assert(t, supported)
+	assert.DeepEqual(t, reasons, []string{pkgtypes.ViolationEventsUnsupportedReason})
+}
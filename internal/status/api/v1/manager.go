@@ -50,35 +50,58 @@ type StatusManager struct {
 	PolicyStatuses map[string]varmortypes.PolicyStatus
 	// Use "namespace/VarmorPolicyName" as key. One VarmorPolicy object corresponds to one ModelingStatus
 	// TODO: Rebuild ModelingStatuses from ArmorProfile object when leader change occurs.
-	ModelingStatuses  map[string]varmortypes.ModelingStatus
+	ModelingStatuses map[string]varmortypes.ModelingStatus
+	// Use "namespace/VarmorPolicyName" or "VarmorClusterPolicyName" as key, and NodeName as the key of the value.
+	PolicyCoverages map[string]map[string]varmor.NodeCoverage
+	// Use NodeName as key. One node corresponds to one NodeInventory
+	NodeInventories   map[string]varmortypes.NodeInventory
 	ResetCh           chan string
 	DeleteCh          chan string
 	UpdateStatusCh    chan string
 	UpdateModeCh      chan string
+	UpdateCoverageCh  chan varmortypes.CoverageData
+	UpdateInventoryCh chan varmortypes.NodeInventory
 	statusQueue       workqueue.RateLimitingInterface
 	dataQueue         workqueue.RateLimitingInterface
+	violationQueue    workqueue.RateLimitingInterface
+	coverageQueue     workqueue.RateLimitingInterface
 	statusUpdateCycle time.Duration
-	debug             bool
-	log               logr.Logger
+	// Use "namespace/VarmorPolicyName" or "VarmorClusterPolicyName" as key, and NodeName as the key of the value.
+	// They are the failures of the nodes that have been notified to the lifecycle hooks.
+	enforcementFailures map[string]map[string]string
+	// anomalyDetector is nil if the anomaly detection of violations is disabled
+	anomalyDetector *anomalyDetector
+	debug           bool
+	log             logr.Logger
 }
 
 func NewStatusManager(coreInterface corev1.CoreV1Interface, appsInterface appsv1.AppsV1Interface, varmorInterface varmorinterface.CrdV1beta1Interface, statusUpdateCycle time.Duration, debug bool, log logr.Logger) *StatusManager {
 	m := StatusManager{
-		coreInterface:     coreInterface,
-		appsInterface:     appsInterface,
-		varmorInterface:   varmorInterface,
-		desiredNumber:     0,
-		PolicyStatuses:    make(map[string]varmortypes.PolicyStatus),
-		ModelingStatuses:  make(map[string]varmortypes.ModelingStatus),
-		ResetCh:           make(chan string, 50),
-		DeleteCh:          make(chan string, 50),
-		UpdateStatusCh:    make(chan string, 100),
-		UpdateModeCh:      make(chan string, 50),
-		statusQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "status"),
-		dataQueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "data"),
-		statusUpdateCycle: statusUpdateCycle,
-		debug:             debug,
-		log:               log,
+		coreInterface:       coreInterface,
+		appsInterface:       appsInterface,
+		varmorInterface:     varmorInterface,
+		desiredNumber:       0,
+		PolicyStatuses:      make(map[string]varmortypes.PolicyStatus),
+		ModelingStatuses:    make(map[string]varmortypes.ModelingStatus),
+		PolicyCoverages:     make(map[string]map[string]varmor.NodeCoverage),
+		NodeInventories:     make(map[string]varmortypes.NodeInventory),
+		enforcementFailures: make(map[string]map[string]string),
+		ResetCh:             make(chan string, 50),
+		DeleteCh:            make(chan string, 50),
+		UpdateStatusCh:      make(chan string, 100),
+		UpdateModeCh:        make(chan string, 50),
+		UpdateCoverageCh:    make(chan varmortypes.CoverageData, 100),
+		UpdateInventoryCh:   make(chan varmortypes.NodeInventory, 100),
+		statusQueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "status"),
+		dataQueue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "data"),
+		violationQueue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "violation"),
+		coverageQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "coverage"),
+		statusUpdateCycle:   statusUpdateCycle,
+		debug:               debug,
+		log:                 log,
+	}
+	if varmorconfig.EnableViolationAnomalyDetection {
+		m.anomalyDetector = newAnomalyDetector()
 	}
 	return &m
 }
@@ -110,6 +133,16 @@ func (m *StatusManager) retrieveDesiredNumber() error {
 	return err
 }
 
+// policyDesiredNumber returns the desired number of agents for the policy. The nodes that don't match
+// the node selector of the policy, and the nodes that can't enforce any profile are excluded.
+func (m *StatusManager) policyDesiredNumber(statusKey string) int {
+	desired := m.desiredNumber - len(m.PolicyStatuses[statusKey].SkippedNodes) - len(m.PolicyStatuses[statusKey].UnsupportedNodes)
+	if desired < 0 {
+		return 0
+	}
+	return desired
+}
+
 // retrieveNodeNameList retrieves the list of nodes where the agent is running.
 func (m *StatusManager) retrieveNodeNameList() ([]string, error) {
 	var nodes []string
@@ -167,8 +200,32 @@ func (m *StatusManager) rebuildPolicyStatuses() error {
 
 			var policyStatus varmortypes.PolicyStatus
 			policyStatus.NodeMessages = make(map[string]string, m.desiredNumber)
+			policyStatus.NodeWarnings = make(map[string]string)
+			policyStatus.SkippedNodes = make(map[string]string)
+			policyStatus.UnsupportedNodes = make(map[string]string)
 
 			for _, condition := range ap.Status.Conditions {
+				if condition.Type == varmortypes.ArmorProfileTruncated {
+					if varmorutils.InStringArray(condition.NodeName, nodes) {
+						policyStatus.NodeWarnings[condition.NodeName] = condition.Message
+					}
+					continue
+				}
+
+				if condition.Type == varmortypes.ArmorProfileSkipped {
+					if varmorutils.InStringArray(condition.NodeName, nodes) {
+						policyStatus.SkippedNodes[condition.NodeName] = condition.Message
+					}
+					continue
+				}
+
+				if condition.Type == varmortypes.ArmorProfileUnsupported {
+					if varmorutils.InStringArray(condition.NodeName, nodes) {
+						policyStatus.UnsupportedNodes[condition.NodeName] = condition.Message
+					}
+					continue
+				}
+
 				if varmorutils.InStringArray(condition.NodeName, nodes) {
 					policyStatus.FailedNumber += 1
 					policyStatus.NodeMessages[condition.NodeName] = condition.Message
@@ -176,6 +233,12 @@ func (m *StatusManager) rebuildPolicyStatuses() error {
 			}
 
 			for _, node := range nodes {
+				if _, ok := policyStatus.SkippedNodes[node]; ok {
+					continue
+				}
+				if _, ok := policyStatus.UnsupportedNodes[node]; ok {
+					continue
+				}
 				if _, ok := policyStatus.NodeMessages[node]; !ok {
 					policyStatus.SuccessedNumber += 1
 					policyStatus.NodeMessages[node] = string(varmortypes.ArmorProfileReady)
@@ -190,7 +253,8 @@ func (m *StatusManager) rebuildPolicyStatuses() error {
 
 func (m *StatusManager) updateArmorProfileStatus(
 	ap *varmor.ArmorProfile,
-	policyStatus *varmortypes.PolicyStatus) (*varmor.ArmorProfile, error) {
+	policyStatus *varmortypes.PolicyStatus,
+	desiredNumber int) (*varmor.ArmorProfile, error) {
 
 	var conditions []varmor.ArmorProfileCondition
 	for nodeName, message := range policyStatus.NodeMessages {
@@ -200,6 +264,21 @@ func (m *StatusManager) updateArmorProfileStatus(
 		}
 	}
 
+	for nodeName, warning := range policyStatus.NodeWarnings {
+		c := newArmorProfileCondition(nodeName, varmortypes.ArmorProfileTruncated, v1.ConditionTrue, "RuleLimitExceeded", warning)
+		conditions = append(conditions, *c)
+	}
+
+	for nodeName, message := range policyStatus.SkippedNodes {
+		c := newArmorProfileCondition(nodeName, varmortypes.ArmorProfileSkipped, v1.ConditionTrue, "NodeSelectorMismatch", message)
+		conditions = append(conditions, *c)
+	}
+
+	for nodeName, message := range policyStatus.UnsupportedNodes {
+		c := newArmorProfileCondition(nodeName, varmortypes.ArmorProfileUnsupported, v1.ConditionTrue, "EnforcerUnavailable", message)
+		conditions = append(conditions, *c)
+	}
+
 	regain := false
 	update := func() (err error) {
 		if regain {
@@ -211,10 +290,10 @@ func (m *StatusManager) updateArmorProfileStatus(
 		// Nothing needs to be updated.
 		if reflect.DeepEqual(ap.Status.Conditions, conditions) &&
 			ap.Status.CurrentNumberLoaded == policyStatus.SuccessedNumber &&
-			ap.Status.DesiredNumberLoaded == m.desiredNumber {
+			ap.Status.DesiredNumberLoaded == desiredNumber {
 			return nil
 		}
-		ap.Status.DesiredNumberLoaded = m.desiredNumber
+		ap.Status.DesiredNumberLoaded = desiredNumber
 		ap.Status.CurrentNumberLoaded = policyStatus.SuccessedNumber
 		if len(conditions) > 0 {
 			ap.Status.Conditions = conditions
@@ -233,10 +312,11 @@ func (m *StatusManager) updateArmorProfileStatus(
 func (m *StatusManager) updateVarmorPolicyStatus(
 	vp *varmor.VarmorPolicy,
 	ready bool,
-	phase varmor.VarmorPolicyPhase) (*varmor.VarmorPolicy, error) {
+	phase varmor.VarmorPolicyPhase,
+	compatibility *varmor.PolicyCompatibility) (*varmor.VarmorPolicy, error) {
 
 	// Nothing need to be updated.
-	if vp.Status.Ready == ready && vp.Status.Phase == phase {
+	if vp.Status.Ready == ready && vp.Status.Phase == phase && compatibility == nil {
 		return vp, nil
 	}
 
@@ -252,6 +332,9 @@ func (m *StatusManager) updateVarmorPolicyStatus(
 		if phase != varmortypes.VarmorPolicyUnchanged {
 			vp.Status.Phase = phase
 		}
+		if compatibility != nil {
+			vp.Status.Compatibility = compatibility
+		}
 		vp, err = m.varmorInterface.VarmorPolicies(vp.Namespace).UpdateStatus(context.Background(), vp, metav1.UpdateOptions{})
 		if err != nil {
 			regain = true
@@ -264,10 +347,11 @@ func (m *StatusManager) updateVarmorPolicyStatus(
 func (m *StatusManager) updateVarmorClusterPolicyStatus(
 	vcp *varmor.VarmorClusterPolicy,
 	ready bool,
-	phase varmor.VarmorPolicyPhase) (*varmor.VarmorClusterPolicy, error) {
+	phase varmor.VarmorPolicyPhase,
+	compatibility *varmor.PolicyCompatibility) (*varmor.VarmorClusterPolicy, error) {
 
 	// Nothing need to be updated.
-	if vcp.Status.Ready == ready && vcp.Status.Phase == phase {
+	if vcp.Status.Ready == ready && vcp.Status.Phase == phase && compatibility == nil {
 		return vcp, nil
 	}
 
@@ -283,6 +367,9 @@ func (m *StatusManager) updateVarmorClusterPolicyStatus(
 		if phase != varmortypes.VarmorPolicyUnchanged {
 			vcp.Status.Phase = phase
 		}
+		if compatibility != nil {
+			vcp.Status.Compatibility = compatibility
+		}
 		vcp, err = m.varmorInterface.VarmorClusterPolicies().UpdateStatus(context.Background(), vcp, metav1.UpdateOptions{})
 		if err != nil {
 			regain = true
@@ -324,11 +411,46 @@ func (m *StatusManager) updateAllCRStatus(logger logr.Logger) {
 				}
 			} else {
 				delete(policyStatus.NodeMessages, nodeName)
+				delete(policyStatus.NodeWarnings, nodeName)
+			}
+		}
+		for nodeName := range policyStatus.SkippedNodes {
+			if !varmorutils.InStringArray(nodeName, nodes) {
+				delete(policyStatus.SkippedNodes, nodeName)
+			}
+		}
+		for nodeName := range policyStatus.UnsupportedNodes {
+			if !varmorutils.InStringArray(nodeName, nodes) {
+				delete(policyStatus.UnsupportedNodes, nodeName)
 			}
 		}
 		m.PolicyStatuses[statusKey] = policyStatus
 		m.UpdateStatusCh <- statusKey
 	}
+
+	// Remove the inventories of offline nodes.
+	for nodeName := range m.NodeInventories {
+		if !varmorutils.InStringArray(nodeName, nodes) {
+			delete(m.NodeInventories, nodeName)
+		}
+	}
+
+	// Remove the coverages of offline nodes, and update the policies' coverages.
+	for statusKey, coverages := range m.PolicyCoverages {
+		changed := false
+		for nodeName := range coverages {
+			if !varmorutils.InStringArray(nodeName, nodes) {
+				delete(coverages, nodeName)
+				changed = true
+			}
+		}
+		if changed {
+			err = m.updatePolicyCoverage(statusKey)
+			if err != nil {
+				logger.Error(err, "m.updatePolicyCoverage()", "key", statusKey)
+			}
+		}
+	}
 }
 
 func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
@@ -336,6 +458,8 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 
 	ticker := time.NewTicker(m.statusUpdateCycle)
 	defer ticker.Stop()
+	compatibilityTicker := time.NewTicker(compatibilityUpdateInterval)
+	defer compatibilityTicker.Stop()
 
 	// Reconcile loop
 	for {
@@ -346,6 +470,9 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 				policyStatus.SuccessedNumber = 0
 				policyStatus.FailedNumber = 0
 				policyStatus.NodeMessages = make(map[string]string, m.desiredNumber)
+				policyStatus.NodeWarnings = make(map[string]string)
+				policyStatus.SkippedNodes = make(map[string]string)
+				policyStatus.UnsupportedNodes = make(map[string]string)
 				m.PolicyStatuses[statusKey] = policyStatus
 			}
 
@@ -353,11 +480,42 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 				modelingStatus.CompletedNumber = 0
 				m.ModelingStatuses[statusKey] = modelingStatus
 			}
+			delete(m.enforcementFailures, statusKey)
 
 		// Delete the specified status cache.
 		case statusKey := <-m.DeleteCh:
 			delete(m.PolicyStatuses, statusKey)
 			delete(m.ModelingStatuses, statusKey)
+			delete(m.PolicyCoverages, statusKey)
+			delete(m.enforcementFailures, statusKey)
+
+		// Update the coverage of the specified object.
+		case data := <-m.UpdateCoverageCh:
+			statusKey, err := generateCoverageStatusKey(&data)
+			if err != nil {
+				logger.Error(err, "generateCoverageStatusKey()")
+				break
+			}
+			if !m.updateCoverageCache(statusKey, &data) {
+				break
+			}
+			logger.V(3).Info("update the coverage of the policy", "key", statusKey, "node", data.NodeName,
+				"desired", data.DesiredPods, "ready", data.ReadyPods, "failed", data.FailedPods)
+			err = m.updatePolicyCoverage(statusKey)
+			if err != nil {
+				logger.Error(err, "m.updatePolicyCoverage()", "key", statusKey)
+			}
+
+		// Update the inventory of the node.
+		case inventory := <-m.UpdateInventoryCh:
+			m.NodeInventories[inventory.NodeName] = inventory
+
+		// Evaluate and update the compatibilities of the policies.
+		case <-compatibilityTicker.C:
+			err := m.updatePolicyCompatibilities()
+			if err != nil {
+				logger.Error(err, "m.updatePolicyCompatibilities()")
+			}
 
 		// Update the specified object status.
 		case statusKey := <-m.UpdateStatusCh:
@@ -404,7 +562,7 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 				logger.Error(err, "m.varmorInterface.ArmorProfiles().Get()")
 				break
 			}
-			ap, err = m.updateArmorProfileStatus(ap, &policyStatus)
+			ap, err = m.updateArmorProfileStatus(ap, &policyStatus, m.policyDesiredNumber(statusKey))
 			if err != nil {
 				logger.Error(err, "m.updateArmorProfileStatus()")
 				break
@@ -437,7 +595,7 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 				phase = varmortypes.VarmorPolicyModeling
 
 				if modelingStatus, ok := m.ModelingStatuses[statusKey]; ok {
-					if modelingStatus.CompletedNumber >= m.desiredNumber {
+					if modelingStatus.CompletedNumber >= m.policyDesiredNumber(statusKey) {
 						complete = true
 					}
 				} else {
@@ -456,22 +614,34 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 				phase = varmortypes.VarmorPolicyError
 			}
 			ready := false
-			if policyStatus.SuccessedNumber >= m.desiredNumber {
+			if policyStatus.SuccessedNumber >= m.policyDesiredNumber(statusKey) {
 				ready = true
 			}
 
+			policyNamespace := namespace
+			if clusterScope {
+				policyNamespace = ""
+			}
+			m.notifyEnforcementEvents(statusKey, policyNamespace, vpName, &vSpec.Policy, ap, &policyStatus, vStatus.Ready, ready)
+
+			// Evaluate the compatibility of the new policy without waiting for the periodic evaluation
+			var compatibility *varmor.PolicyCompatibility
+			if vStatus.Compatibility == nil {
+				compatibility = evaluateCompatibility(&vSpec.Policy, vSpec.NodeSelector, m.NodeInventories)
+			}
+
 			// Update VarmorPolicy/status or VarmorClusterPolicy/status
 			if clusterScope {
 				vcp := v.(*varmor.VarmorClusterPolicy)
 				logger.Info("2. update VarmorClusterPolicy/status", "name", vcp.Name)
-				_, err = m.updateVarmorClusterPolicyStatus(vcp, ready, phase)
+				_, err = m.updateVarmorClusterPolicyStatus(vcp, ready, phase, compatibility)
 				if err != nil {
 					logger.Error(err, "m.updateVarmorClusterPolicyStatus()")
 				}
 			} else {
 				vp := v.(*varmor.VarmorPolicy)
 				logger.Info("2. update VarmorPolicy/status", "namespace", vp.Namespace, "name", vp.Name)
-				_, err = m.updateVarmorPolicyStatus(vp, ready, phase)
+				_, err = m.updateVarmorPolicyStatus(vp, ready, phase, compatibility)
 				if err != nil {
 					logger.Error(err, "m.updateVarmorPolicyStatus()")
 				}
@@ -482,7 +652,7 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 			logger.Info("periodically update all of the objects' statuses")
 			m.updateAllCRStatus(logger)
 
-		// Update ArmorProfile for the BehaviorModeling mode.
+		// Update ArmorProfile for the BehaviorModeling mode, or the complain feedback of the DefenseInDepth mode.
 		case statusKey := <-m.UpdateModeCh:
 			namespace, vpName, err := cache.SplitMetaNamespaceKey(statusKey)
 			if err != nil {
@@ -501,11 +671,18 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 				policyStatus.FailedNumber = 0
 				policyStatus.SuccessedNumber = 0
 				policyStatus.NodeMessages = make(map[string]string, m.desiredNumber)
+				policyStatus.NodeWarnings = make(map[string]string)
+				policyStatus.SkippedNodes = make(map[string]string)
+				policyStatus.UnsupportedNodes = make(map[string]string)
 				m.PolicyStatuses[statusKey] = policyStatus
 			}
 
 			var v interface{}
 			var vPolicy varmor.Policy
+			policyNamespace := namespace
+			if clusterScope {
+				policyNamespace = ""
+			}
 			if clusterScope {
 				v, err = m.varmorInterface.VarmorClusterPolicies().Get(context.Background(), vpName, metav1.GetOptions{})
 				if err != nil {
@@ -530,19 +707,35 @@ func (m *StatusManager) reconcileStatus(stopCh <-chan struct{}) {
 			}
 
 			logger.Info("update ArmorProfile (complain mode --> enforce mode)", "namespace", namespace, "name", apName)
+			var previousMode string
+			var ap *varmor.ArmorProfile
 			err = retry.RetryOnConflict(retry.DefaultRetry,
 				func() error {
-					ap, err := m.varmorInterface.ArmorProfiles(namespace).Get(context.Background(), apName, metav1.GetOptions{})
+					ap, err = m.varmorInterface.ArmorProfiles(namespace).Get(context.Background(), apName, metav1.GetOptions{})
 					if err != nil {
 						return err
 					}
+					previousMode = ap.Spec.Profile.Mode
 					ap.Spec.Profile = *profile
 					ap.Spec.BehaviorModeling.Enable = false
-					_, err = m.varmorInterface.ArmorProfiles(ap.Namespace).Update(context.Background(), ap, metav1.UpdateOptions{})
+					ap, err = m.varmorInterface.ArmorProfiles(ap.Namespace).Update(context.Background(), ap, metav1.UpdateOptions{})
 					return err
 				})
 			if err != nil {
 				logger.Error(err, "update ArmorProfile failed")
+				break
+			}
+
+			err = varmorprofile.RecordProfileHistory(m.varmorInterface, ap.Namespace, ap.Name)
+			if err != nil {
+				logger.Error(err, "varmorprofile.RecordProfileHistory()")
+			}
+
+			m.NotifyLifecycleHooks(vPolicy.LifecycleHooks, NewLifecycleEvent(varmortypes.PreEnforceEvent, policyNamespace, vpName, ap))
+			if ap.Spec.Profile.Mode != previousMode {
+				event := NewLifecycleEvent(varmortypes.ModeChangedEvent, policyNamespace, vpName, ap)
+				event.PreviousMode = previousMode
+				m.NotifyLifecycleHooks(vPolicy.LifecycleHooks, event)
 			}
 
 		// Break out the status reconcile loop.
@@ -573,6 +766,8 @@ func (m *StatusManager) Run(stopCh <-chan struct{}) {
 	go m.reconcileStatus(stopCh)
 	go wait.Until(m.statusWorker, time.Second, stopCh)
 	go wait.Until(m.dataWorker, time.Second, stopCh)
+	go wait.Until(m.violationWorker, time.Second, stopCh)
+	go wait.Until(m.coverageWorker, time.Second, stopCh)
 
 	<-stopCh
 }
@@ -580,4 +775,6 @@ func (m *StatusManager) Run(stopCh <-chan struct{}) {
 func (m *StatusManager) CleanUp() {
 	m.statusQueue.ShutDown()
 	m.dataQueue.ShutDown()
+	m.violationQueue.ShutDown()
+	m.coverageQueue.ShutDown()
 }
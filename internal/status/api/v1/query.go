@@ -0,0 +1,347 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmorprofile "github.com/bytedance/vArmor/internal/profile"
+	bpfprofile "github.com/bytedance/vArmor/internal/profile/bpf"
+	"github.com/bytedance/vArmor/internal/profile/export"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// listViolationCounts returns the total count of violations of the ArmorProfile objects in the namespace
+func (m *StatusManager) listViolationCounts(namespace string) (map[string]int64, error) {
+	vvs, err := m.varmorInterface.VarmorViolations(namespace).List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(vvs.Items))
+	for _, vv := range vvs.Items {
+		counts[vv.Namespace+"/"+vv.Name] = vv.TotalCount
+	}
+	return counts, nil
+}
+
+func newPolicyEnforcement(ap *varmor.ArmorProfile, violationCounts map[string]int64) varmortypes.PolicyEnforcement {
+	return varmortypes.PolicyEnforcement{
+		Target:              ap.Spec.Target,
+		ArmorProfile:        ap.Name,
+		Enforcer:            ap.Spec.Profile.Enforcer,
+		Mode:                ap.Spec.Profile.Mode,
+		DesiredNumberLoaded: ap.Status.DesiredNumberLoaded,
+		CurrentNumberLoaded: ap.Status.CurrentNumberLoaded,
+		ViolationCount:      violationCounts[ap.Namespace+"/"+ap.Name],
+	}
+}
+
+// QueryPolicies is an HTTP interface used for listing the policies, the workloads protected by them,
+// their enforcement mode and the count of recent violations. Use the namespace query parameter to
+// list the VarmorPolicy objects of a namespace only.
+func (m *StatusManager) QueryPolicies(c *gin.Context) {
+	logger := m.log.WithName("QueryPolicies()")
+	namespace := c.Query("namespace")
+
+	aps, err := m.varmorInterface.ArmorProfiles(namespace).List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		logger.Error(err, "ArmorProfiles().List()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+	apMap := make(map[string]*varmor.ArmorProfile, len(aps.Items))
+	for i := range aps.Items {
+		apMap[aps.Items[i].Namespace+"/"+aps.Items[i].Name] = &aps.Items[i]
+	}
+
+	violationCounts, err := m.listViolationCounts(namespace)
+	if err != nil {
+		logger.Error(err, "listViolationCounts()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+
+	var items []varmortypes.PolicyEnforcement
+
+	vps, err := m.varmorInterface.VarmorPolicies(namespace).List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		logger.Error(err, "VarmorPolicies().List()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+	for _, vp := range vps.Items {
+		ap, ok := apMap[vp.Namespace+"/"+vp.Status.ProfileName]
+		if !ok {
+			continue
+		}
+		item := newPolicyEnforcement(ap, violationCounts)
+		item.Namespace = vp.Namespace
+		item.Name = vp.Name
+		item.Phase = vp.Status.Phase
+		item.Ready = vp.Status.Ready
+		items = append(items, item)
+	}
+
+	if namespace == "" || namespace == varmorconfig.Namespace {
+		vcps, err := m.varmorInterface.VarmorClusterPolicies().List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+		if err != nil {
+			logger.Error(err, "VarmorClusterPolicies().List()")
+			c.JSON(http.StatusInternalServerError, nil)
+			return
+		}
+		for _, vcp := range vcps.Items {
+			ap, ok := apMap[varmorconfig.Namespace+"/"+vcp.Status.ProfileName]
+			if !ok {
+				continue
+			}
+			item := newPolicyEnforcement(ap, violationCounts)
+			item.Name = vcp.Name
+			item.ClusterScope = true
+			item.Phase = vcp.Status.Phase
+			item.Ready = vcp.Status.Ready
+			items = append(items, item)
+		}
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// QueryProfile is an HTTP interface used for retrieving the effective profile of an ArmorProfile object
+func (m *StatusManager) QueryProfile(c *gin.Context) {
+	logger := m.log.WithName("QueryProfile()")
+
+	ap, err := m.varmorInterface.ArmorProfiles(c.Param("namespace")).Get(context.Background(), c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, nil)
+			return
+		}
+		logger.Error(err, "ArmorProfiles().Get()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, ap.Spec.Profile)
+}
+
+// QueryProfileReport is an HTTP interface used for rendering the BPF profile of an ArmorProfile object into
+// a human-readable report for the security review and audits. Use the format query parameter with "text"
+// to retrieve the report in the form of a table instead of JSON.
+func (m *StatusManager) QueryProfileReport(c *gin.Context) {
+	logger := m.log.WithName("QueryProfileReport()")
+
+	ap, err := m.varmorInterface.ArmorProfiles(c.Param("namespace")).Get(context.Background(), c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, nil)
+			return
+		}
+		logger.Error(err, "ArmorProfiles().Get()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+
+	if ap.Spec.Profile.BpfContent == nil {
+		// Only the BPF profile can be rendered
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	report := bpfprofile.GenerateReport(ap.Spec.Profile.BpfContent)
+	if c.Query("format") != "text" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = report.WriteText(&buf)
+	if err != nil {
+		logger.Error(err, "WriteText()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+}
+
+// QueryProfileDiff is an HTTP interface used for retrieving the rules added and removed between two generations
+// of an ArmorProfile object. Use the from and to query parameters to specify the generations, by default it
+// compares the latest generation with the previous one.
+func (m *StatusManager) QueryProfileDiff(c *gin.Context) {
+	logger := m.log.WithName("QueryProfileDiff()")
+
+	var generations [2]int64
+	for i, param := range []string{"from", "to"} {
+		if value := c.Query(param); value != "" {
+			generation, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, nil)
+				return
+			}
+			generations[i] = generation
+		}
+	}
+
+	ap, err := m.varmorInterface.ArmorProfiles(c.Param("namespace")).Get(context.Background(), c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, nil)
+			return
+		}
+		logger.Error(err, "ArmorProfiles().Get()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+
+	diff, err := varmorprofile.DiffProfileRevisions(ap.Status.History, generations[0], generations[1])
+	if err != nil {
+		c.JSON(http.StatusNotFound, nil)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// getModelReport retrieves the ArmorProfileModel object and renders its behavior model into the JSON report. It
+// writes the response and returns nil if the object can't be retrieved or the behavior model isn't completed.
+func (m *StatusManager) getModelReport(c *gin.Context, logger logr.Logger, namespace, name string) *export.ModelReport {
+	apm, err := m.varmorInterface.ArmorProfileModels(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, nil)
+			return nil
+		}
+		logger.Error(err, "ArmorProfileModels().Get()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return nil
+	}
+
+	if !apm.Status.Ready {
+		// Only the completed behavior model can be exported
+		c.JSON(http.StatusConflict, nil)
+		return nil
+	}
+
+	report, err := export.NewModelReport(apm)
+	if err != nil {
+		logger.Error(err, "export.NewModelReport()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return nil
+	}
+	return report
+}
+
+// QueryModelReport is an HTTP interface used for exporting the completed behavior model of an ArmorProfileModel
+// object and the profile built with it, so they can be attached to the security reports of CI. Use the format query
+// parameter with "sarif" to retrieve the report as a SARIF log instead of the JSON report.
+func (m *StatusManager) QueryModelReport(c *gin.Context) {
+	logger := m.log.WithName("QueryModelReport()")
+
+	report := m.getModelReport(c, logger, c.Param("namespace"), c.Param("name"))
+	if report == nil {
+		return
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+		c.JSON(http.StatusOK, report)
+	case "sarif":
+		var buf bytes.Buffer
+		err := report.WriteSARIF(&buf)
+		if err != nil {
+			logger.Error(err, "WriteSARIF()")
+			c.JSON(http.StatusInternalServerError, nil)
+			return
+		}
+		c.Data(http.StatusOK, "application/sarif+json", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, nil)
+	}
+}
+
+// QueryModelDiff is an HTTP interface used for retrieving the behaviors added and removed between the completed
+// behavior models of two releases of the workloads. The ArmorProfileModel object in the path is the new release,
+// use the base query parameter with "<namespace>/<name>" to specify the ArmorProfileModel object of the previous
+// release. Use the format query parameter with "text" to retrieve the changes in the form of a unified diff.
+func (m *StatusManager) QueryModelDiff(c *gin.Context) {
+	logger := m.log.WithName("QueryModelDiff()")
+
+	baseNamespace, baseName, found := strings.Cut(c.Query("base"), "/")
+	if !found || baseNamespace == "" || baseName == "" {
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	base := m.getModelReport(c, logger, baseNamespace, baseName)
+	if base == nil {
+		return
+	}
+	target := m.getModelReport(c, logger, c.Param("namespace"), c.Param("name"))
+	if target == nil {
+		return
+	}
+
+	diff := export.DiffModelReports(base, target)
+	switch c.Query("format") {
+	case "", "json":
+		c.JSON(http.StatusOK, diff)
+	case "text":
+		var buf bytes.Buffer
+		diff.WriteText(&buf)
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, nil)
+	}
+}
+
+// QueryViolations is an HTTP interface used for listing the recent violations, the latest ones come first.
+// Use the namespace query parameter to list the violations of the ArmorProfile objects in a namespace only.
+func (m *StatusManager) QueryViolations(c *gin.Context) {
+	logger := m.log.WithName("QueryViolations()")
+
+	vvs, err := m.varmorInterface.VarmorViolations(c.Query("namespace")).List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		logger.Error(err, "VarmorViolations().List()")
+		c.JSON(http.StatusInternalServerError, nil)
+		return
+	}
+
+	var items []varmortypes.ViolationItem
+	for _, vv := range vvs.Items {
+		for _, record := range vv.Records {
+			items = append(items, varmortypes.ViolationItem{
+				ProfileNamespace: vv.Namespace,
+				ArmorProfile:     vv.Name,
+				ViolationRecord:  record,
+			})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].LastTimestamp.After(items[j].LastTimestamp.Time)
+	})
+
+	c.JSON(http.StatusOK, items)
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	"github.com/bytedance/vArmor/pkg/client/clientset/versioned/fake"
+)
+
+func newQueryContext(target string, params ...gin.Param) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	c.Params = params
+	return c, w
+}
+
+func newArmorProfile(namespace, name string, desired, current int) *varmor.ArmorProfile {
+	ap := &varmor.ArmorProfile{}
+	ap.Namespace = namespace
+	ap.Name = name
+	ap.Spec.Profile.Enforcer = "BPF"
+	ap.Spec.Profile.Mode = "EnhanceProtect"
+	ap.Status.DesiredNumberLoaded = desired
+	ap.Status.CurrentNumberLoaded = current
+	return ap
+}
+
+func Test_QueryPolicies(t *testing.T) {
+	vp := &varmor.VarmorPolicy{}
+	vp.Namespace = "demo"
+	vp.Name = "test"
+	vp.Status.ProfileName = "varmor-demo-test"
+	vp.Status.Phase = varmortypes.VarmorPolicyProtecting
+	vp.Status.Ready = true
+
+	// The policy without the ArmorProfile object isn't listed
+	pending := &varmor.VarmorPolicy{}
+	pending.Namespace = "demo"
+	pending.Name = "pending"
+	pending.Status.ProfileName = "varmor-demo-pending"
+
+	vcp := &varmor.VarmorClusterPolicy{}
+	vcp.Name = "cluster"
+	vcp.Status.ProfileName = "varmor-cluster-varmor-cluster"
+
+	vv := &varmor.VarmorViolation{}
+	vv.Namespace = "demo"
+	vv.Name = "varmor-demo-test"
+	vv.TotalCount = 7
+
+	client := fake.NewSimpleClientset(vp, pending, vcp, vv,
+		newArmorProfile("demo", "varmor-demo-test", 2, 1),
+		newArmorProfile(varmorconfig.Namespace, "varmor-cluster-varmor-cluster", 3, 3))
+	m := &StatusManager{varmorInterface: client.CrdV1beta1(), log: logr.Discard()}
+
+	testCases := []struct {
+		name      string
+		namespace string
+		expected  []varmortypes.PolicyEnforcement
+	}{
+		{
+			name: "all namespaces",
+			expected: []varmortypes.PolicyEnforcement{
+				{
+					Namespace:           "demo",
+					Name:                "test",
+					ArmorProfile:        "varmor-demo-test",
+					Enforcer:            "BPF",
+					Mode:                "EnhanceProtect",
+					Phase:               varmortypes.VarmorPolicyProtecting,
+					Ready:               true,
+					DesiredNumberLoaded: 2,
+					CurrentNumberLoaded: 1,
+					ViolationCount:      7,
+				},
+				{
+					Name:                "cluster",
+					ClusterScope:        true,
+					ArmorProfile:        "varmor-cluster-varmor-cluster",
+					Enforcer:            "BPF",
+					Mode:                "EnhanceProtect",
+					DesiredNumberLoaded: 3,
+					CurrentNumberLoaded: 3,
+				},
+			},
+		},
+		{
+			name:      "the cluster policies are skipped in other namespaces",
+			namespace: "demo",
+			expected: []varmortypes.PolicyEnforcement{
+				{
+					Namespace:           "demo",
+					Name:                "test",
+					ArmorProfile:        "varmor-demo-test",
+					Enforcer:            "BPF",
+					Mode:                "EnhanceProtect",
+					Phase:               varmortypes.VarmorPolicyProtecting,
+					Ready:               true,
+					DesiredNumberLoaded: 2,
+					CurrentNumberLoaded: 1,
+					ViolationCount:      7,
+				},
+			},
+		},
+		{
+			name:      "no policy",
+			namespace: "empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, w := newQueryContext("/apis/query/policies?namespace=" + tc.namespace)
+			m.QueryPolicies(c)
+			assert.Equal(t, w.Code, http.StatusOK)
+
+			var items []varmortypes.PolicyEnforcement
+			assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &items))
+			assert.DeepEqual(t, items, tc.expected)
+		})
+	}
+}
+
+func Test_QueryProfile(t *testing.T) {
+	client := fake.NewSimpleClientset(newArmorProfile("demo", "varmor-demo-test", 1, 1))
+	m := &StatusManager{varmorInterface: client.CrdV1beta1(), log: logr.Discard()}
+
+	c, w := newQueryContext("/", gin.Param{Key: "namespace", Value: "demo"}, gin.Param{Key: "name", Value: "varmor-demo-test"})
+	m.QueryProfile(c)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var profile varmor.Profile
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &profile))
+	assert.Equal(t, profile.Mode, "EnhanceProtect")
+
+	c, w = newQueryContext("/", gin.Param{Key: "namespace", Value: "demo"}, gin.Param{Key: "name", Value: "unknown"})
+	m.QueryProfile(c)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+
+	// Only the BPF profile can be rendered
+	c, w = newQueryContext("/", gin.Param{Key: "namespace", Value: "demo"}, gin.Param{Key: "name", Value: "varmor-demo-test"})
+	m.QueryProfileReport(c)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+
+	c, w = newQueryContext("/?from=latest", gin.Param{Key: "namespace", Value: "demo"}, gin.Param{Key: "name", Value: "varmor-demo-test"})
+	m.QueryProfileDiff(c)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func Test_QueryViolations(t *testing.T) {
+	now := time.Now()
+	older := &varmor.VarmorViolation{}
+	older.Namespace = "demo"
+	older.Name = "varmor-demo-a"
+	older.Records = []varmor.ViolationRecord{
+		{RuleType: "file", Count: 1, LastTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+		{RuleType: "network", Count: 2, LastTimestamp: metav1.NewTime(now)},
+	}
+	newer := &varmor.VarmorViolation{}
+	newer.Namespace = "other"
+	newer.Name = "varmor-other-b"
+	newer.Records = []varmor.ViolationRecord{
+		{RuleType: "bprm", Count: 3, LastTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+	}
+
+	client := fake.NewSimpleClientset(older, newer)
+	m := &StatusManager{varmorInterface: client.CrdV1beta1(), log: logr.Discard()}
+
+	c, w := newQueryContext("/apis/query/violations")
+	m.QueryViolations(c)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var items []varmortypes.ViolationItem
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &items))
+	assert.Equal(t, len(items), 3)
+	// The latest violations come first
+	assert.Equal(t, items[0].RuleType, "network")
+	assert.Equal(t, items[0].ArmorProfile, "varmor-demo-a")
+	assert.Equal(t, items[1].RuleType, "bprm")
+	assert.Equal(t, items[1].ProfileNamespace, "other")
+	assert.Equal(t, items[2].RuleType, "file")
+
+	c, w = newQueryContext("/apis/query/violations?namespace=other")
+	m.QueryViolations(c)
+	assert.Equal(t, w.Code, http.StatusOK)
+	items = nil
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &items))
+	assert.Equal(t, len(items), 1)
+	assert.Equal(t, items[0].ArmorProfile, "varmor-other-b")
+}
@@ -65,7 +65,10 @@ func (m *StatusManager) Status(c *gin.Context) {
 // updatePolicyStatus update StatusManager.PolicyStatuses[statusKey] with profileStatus which comes from agent.
 func (m *StatusManager) updatePolicyStatus(statusKey string, profileStatus *varmortypes.ProfileStatus) error {
 
-	if profileStatus.Status != varmortypes.Failed && profileStatus.Status != varmortypes.Succeeded {
+	if profileStatus.Status != varmortypes.Failed &&
+		profileStatus.Status != varmortypes.Succeeded &&
+		profileStatus.Status != varmortypes.Skipped &&
+		profileStatus.Status != varmortypes.Unsupported {
 		return fmt.Errorf("profileStatus.Status is illegal")
 	}
 
@@ -77,6 +80,25 @@ func (m *StatusManager) updatePolicyStatus(statusKey string, profileStatus *varm
 	}
 
 	policyStatus = m.PolicyStatuses[statusKey]
+	if policyStatus.NodeWarnings == nil {
+		policyStatus.NodeWarnings = make(map[string]string)
+	}
+	if policyStatus.SkippedNodes == nil {
+		policyStatus.SkippedNodes = make(map[string]string)
+	}
+	if policyStatus.UnsupportedNodes == nil {
+		policyStatus.UnsupportedNodes = make(map[string]string)
+	}
+	delete(policyStatus.SkippedNodes, profileStatus.NodeName)
+	delete(policyStatus.UnsupportedNodes, profileStatus.NodeName)
+
+	// Only the profile that was loaded successfully can have a warning, e.g. it was truncated on the node.
+	if profileStatus.Status == varmortypes.Succeeded && profileStatus.Warning != "" {
+		policyStatus.NodeWarnings[profileStatus.NodeName] = profileStatus.Warning
+	} else {
+		delete(policyStatus.NodeWarnings, profileStatus.NodeName)
+	}
+
 	switch profileStatus.Status {
 	case varmortypes.Failed:
 		if nodeMessage, ok := policyStatus.NodeMessages[profileStatus.NodeName]; ok {
@@ -112,6 +134,23 @@ func (m *StatusManager) updatePolicyStatus(statusKey string, profileStatus *varm
 			policyStatus.SuccessedNumber += 1
 			policyStatus.NodeMessages[profileStatus.NodeName] = string(varmortypes.ArmorProfileReady)
 		}
+
+	case varmortypes.Skipped, varmortypes.Unsupported:
+		// The node doesn't match the node selector of the policy, or it can't enforce any profile.
+		// Exclude it from the desired nodes.
+		if nodeMessage, ok := policyStatus.NodeMessages[profileStatus.NodeName]; ok {
+			if nodeMessage == string(varmortypes.ArmorProfileReady) {
+				policyStatus.SuccessedNumber -= 1
+			} else {
+				policyStatus.FailedNumber -= 1
+			}
+			delete(policyStatus.NodeMessages, profileStatus.NodeName)
+		}
+		if profileStatus.Status == varmortypes.Skipped {
+			policyStatus.SkippedNodes[profileStatus.NodeName] = profileStatus.Message
+		} else {
+			policyStatus.UnsupportedNodes[profileStatus.NodeName] = profileStatus.Message
+		}
 	}
 
 	m.PolicyStatuses[statusKey] = policyStatus
@@ -142,7 +181,7 @@ func (m *StatusManager) syncStatus(profileStatus varmortypes.ProfileStatus) erro
 		return nil
 	}
 
-	status := fmt.Sprintf("successed/failed/desired (%d/%d/%d)", m.PolicyStatuses[statusKey].SuccessedNumber, m.PolicyStatuses[statusKey].FailedNumber, m.desiredNumber)
+	status := fmt.Sprintf("successed/failed/desired (%d/%d/%d)", m.PolicyStatuses[statusKey].SuccessedNumber, m.PolicyStatuses[statusKey].FailedNumber, m.policyDesiredNumber(statusKey))
 	logger.Info("2. policy status cache updated", "key", statusKey, "status", status)
 
 	logger.Info("3. send signal to UpdateStatusCh", "status key", statusKey)
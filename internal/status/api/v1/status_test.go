@@ -0,0 +1,57 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_updatePolicyStatusWithUnsupportedNode(t *testing.T) {
+	m := &StatusManager{
+		desiredNumber:  3,
+		PolicyStatuses: make(map[string]varmortypes.PolicyStatus),
+	}
+	key := "default/test"
+
+	for _, node := range []string{"node-a", "node-b"} {
+		err := m.updatePolicyStatus(key, &varmortypes.ProfileStatus{NodeName: node, Status: varmortypes.Succeeded})
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, m.PolicyStatuses[key].SuccessedNumber, 2)
+	assert.Equal(t, m.policyDesiredNumber(key), 3)
+
+	// The node that can't enforce any profile is excluded from the desired nodes
+	err := m.updatePolicyStatus(key, &varmortypes.ProfileStatus{NodeName: "node-c", Status: varmortypes.Unsupported, Message: "unsupported"})
+	assert.NilError(t, err)
+	assert.Equal(t, m.PolicyStatuses[key].UnsupportedNodes["node-c"], "unsupported")
+	assert.Equal(t, m.policyDesiredNumber(key), 2)
+
+	// The node that was ready becomes unsupported after the agent restarts
+	err = m.updatePolicyStatus(key, &varmortypes.ProfileStatus{NodeName: "node-b", Status: varmortypes.Unsupported, Message: "unsupported"})
+	assert.NilError(t, err)
+	assert.Equal(t, m.PolicyStatuses[key].SuccessedNumber, 1)
+	assert.Equal(t, m.policyDesiredNumber(key), 1)
+
+	// The node that supports the enforcers again is desired
+	err = m.updatePolicyStatus(key, &varmortypes.ProfileStatus{NodeName: "node-c", Status: varmortypes.Succeeded})
+	assert.NilError(t, err)
+	assert.Equal(t, m.PolicyStatuses[key].SuccessedNumber, 2)
+	assert.Equal(t, len(m.PolicyStatuses[key].UnsupportedNodes), 1)
+	assert.Equal(t, m.policyDesiredNumber(key), 2)
+}
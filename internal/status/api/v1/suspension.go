@@ -0,0 +1,106 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// The reasons of the events raised for the suspensions of the enforcement
+const (
+	enforcementSuspendedReason = "EnforcementSuspended"
+	enforcementResumedReason   = "EnforcementResumed"
+)
+
+// Suspension is an HTTP interface used for receiving the SuspensionReport come from agents
+func (m *StatusManager) Suspension(c *gin.Context) {
+	logger := m.log.WithName("Suspension()")
+
+	reqBody, err := getHttpBody(c)
+	if err != nil {
+		logger.Error(err, "getHttpBody()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	var report varmortypes.SuspensionReport
+	err = json.Unmarshal(reqBody, &report)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	if report.NodeName == "" || report.PodName == "" || report.ContainerID == "" {
+		err = fmt.Errorf("request is illegal")
+		logger.Error(err, "bad request body")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	event := newSuspensionEvent(&report, metav1.Now())
+	_, err = m.coreInterface.Events(event.Namespace).Create(context.Background(), &event, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(err, "m.coreInterface.Events().Create()")
+	}
+}
+
+// newSuspensionEvent returns the event of the pod that records the suspension or the resumption of the
+// enforcement of its container
+func newSuspensionEvent(report *varmortypes.SuspensionReport, now metav1.Time) v1.Event {
+	reason := enforcementSuspendedReason
+	eventType := v1.EventTypeWarning
+	message := fmt.Sprintf("the enforcement of the container %s (id: %s, profile: %s) on node %s was suspended until %s",
+		report.ContainerName, report.ContainerID, report.ProfileName, report.NodeName, report.Until.UTC().Format(time.RFC3339))
+	if report.Resumed {
+		reason = enforcementResumedReason
+		eventType = v1.EventTypeNormal
+		message = fmt.Sprintf("the enforcement of the container %s (id: %s, profile: %s) on node %s was resumed",
+			report.ContainerName, report.ContainerID, report.ProfileName, report.NodeName)
+	}
+	if report.Reason != "" {
+		message += ", reason: " + report.Reason
+	}
+
+	return v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: report.PodName + "-",
+			Namespace:    report.PodNamespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  report.PodNamespace,
+			Name:       report.PodName,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: "varmor-manager", Host: report.NodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_newSuspensionEvent(t *testing.T) {
+	report := varmortypes.SuspensionReport{
+		NodeName:      "node-1",
+		ProfileName:   "varmor-demo-web",
+		PodNamespace:  "demo",
+		PodName:       "web-1",
+		ContainerID:   "c1",
+		ContainerName: "nginx",
+		Reason:        "debugging",
+		Until:         time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC),
+	}
+
+	event := newSuspensionEvent(&report, metav1.Now())
+	assert.Equal(t, event.Namespace, "demo")
+	assert.Equal(t, event.InvolvedObject.Name, "web-1")
+	assert.Equal(t, event.Reason, enforcementSuspendedReason)
+	assert.Equal(t, event.Type, v1.EventTypeWarning)
+	assert.Equal(t, event.Message, "the enforcement of the container nginx (id: c1, profile: varmor-demo-web) on node node-1 was suspended until 2024-06-01T08:00:00Z, reason: debugging")
+
+	report.Resumed = true
+	report.Reason = ""
+	event = newSuspensionEvent(&report, metav1.Now())
+	assert.Equal(t, event.Reason, enforcementResumedReason)
+	assert.Equal(t, event.Type, v1.EventTypeNormal)
+	assert.Equal(t, event.Message, "the enforcement of the container nginx (id: c1, profile: varmor-demo-web) on node node-1 was resumed")
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// enforcementTamperedReason is the reason of the events raised for the tampering with the BPF enforcer
+const enforcementTamperedReason = "EnforcementTampered"
+
+// Tamper is an HTTP interface used for receiving the TamperReport come from agents
+func (m *StatusManager) Tamper(c *gin.Context) {
+	logger := m.log.WithName("Tamper()")
+
+	reqBody, err := getHttpBody(c)
+	if err != nil {
+		logger.Error(err, "getHttpBody()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	var report varmortypes.TamperReport
+	err = json.Unmarshal(reqBody, &report)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	if report.NodeName == "" || len(report.Maps) == 0 {
+		err = fmt.Errorf("request is illegal")
+		logger.Error(err, "bad request body")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	m.reportTamper(&report)
+}
+
+// tamperMessage returns the message of the event raised for the tampering
+func tamperMessage(report *varmortypes.TamperReport) string {
+	target := fmt.Sprintf("mnt ns %d", report.MntNsID)
+	if report.PodName != "" {
+		target = fmt.Sprintf("pod %s/%s (profile: %s, mnt ns: %d)", report.PodNamespace, report.PodName, report.ProfileName, report.MntNsID)
+	}
+
+	result := "the enforcement couldn't be restored"
+	if report.Restored {
+		result = "the enforcement was restored"
+	}
+
+	return fmt.Sprintf("the maps (%s) of the BPF enforcer on node %s were modified by anything other than the agent for %s, %s",
+		strings.Join(report.Maps, ","), report.NodeName, target, result)
+}
+
+// reportTamper raises a warning event of the tampered pod, or of the node if the pod is unknown
+func (m *StatusManager) reportTamper(report *varmortypes.TamperReport) {
+	logger := m.log.WithName("reportTamper()")
+
+	message := tamperMessage(report)
+	logger.Error(fmt.Errorf("enforcement tampered"), message, "node", report.NodeName)
+
+	involvedObject := v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       report.NodeName,
+	}
+	namespace := metav1.NamespaceDefault
+	if report.PodName != "" {
+		involvedObject = v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  report.PodNamespace,
+			Name:       report.PodName,
+		}
+		namespace = report.PodNamespace
+	}
+
+	now := metav1.Now()
+	event := v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: involvedObject.Name + "-",
+			Namespace:    namespace,
+		},
+		InvolvedObject: involvedObject,
+		Reason:         enforcementTamperedReason,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "varmor-manager", Host: report.NodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := m.coreInterface.Events(namespace).Create(context.Background(), &event, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(err, "m.coreInterface.Events().Create()")
+	}
+}
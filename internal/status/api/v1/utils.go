@@ -97,6 +97,26 @@ func generateModelingStatusKey(behaviorData *varmortypes.BehaviorData) (string,
 	}
 }
 
+// generateCoverageStatusKey build the key of StatusManager.PolicyCoverages from CoverageData.
+//
+// Its format is "namespace/VarmorPolicyName" or "VarmorClusterPolicyName".
+func generateCoverageStatusKey(coverageData *varmortypes.CoverageData) (string, error) {
+	clusterProfileNamePrefix := fmt.Sprintf(varmorprofile.ClusterProfileNameTemplate, coverageData.Namespace, "")
+	profileNamePrefix := fmt.Sprintf(varmorprofile.ProfileNameTemplate, coverageData.Namespace, "")
+
+	if strings.HasPrefix(coverageData.ProfileName, clusterProfileNamePrefix) {
+		// cluster-scope profile
+		policyName := coverageData.ProfileName[len(clusterProfileNamePrefix):]
+		return policyName, nil
+	} else if strings.HasPrefix(coverageData.ProfileName, profileNamePrefix) {
+		// namespace-scope profile
+		policyName := coverageData.ProfileName[len(profileNamePrefix):]
+		return coverageData.Namespace + "/" + policyName, nil
+	} else {
+		return "", fmt.Errorf("coverageData.ProfileName is illegal")
+	}
+}
+
 func newArmorProfileCondition(
 	nodeName string,
 	condType varmor.ArmorProfileConditionType,
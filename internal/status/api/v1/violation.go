@@ -0,0 +1,281 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/util/retry"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+const (
+	// violationRetention is the period to keep a violation record since it was updated the last time
+	violationRetention = 7 * 24 * time.Hour
+	// maxViolationRecords is the max count of the records in a VarmorViolation object, the oldest ones are dropped
+	maxViolationRecords = 200
+)
+
+// Violation is an HTTP interface used for receiving the ViolationData come from agents
+func (m *StatusManager) Violation(c *gin.Context) {
+	logger := m.log.WithName("Violation()")
+
+	reqBody, err := getHttpBody(c)
+	if err != nil {
+		logger.Error(err, "getHttpBody()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	var data varmortypes.ViolationData
+	err = json.Unmarshal(reqBody, &data)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	if data.Namespace == "" || data.ProfileName == "" || data.NodeName == "" {
+		err = fmt.Errorf("request is illegal")
+		logger.Error(err, "bad request body")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	logger.V(3).Info("enqueue ViolationData from agent")
+	m.violationQueue.Add(string(reqBody))
+}
+
+// resolveWorkload returns the kind and name of the workload which the pod belongs to
+func (m *StatusManager) resolveWorkload(namespace, podName string) string {
+	pod, err := m.coreInterface.Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{ResourceVersion: "0"})
+	if err != nil {
+		return "Pod/" + podName
+	}
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "Pod/" + podName
+	}
+
+	if owner.Kind == "ReplicaSet" {
+		rs, err := m.appsInterface.ReplicaSets(namespace).Get(context.Background(), owner.Name, metav1.GetOptions{ResourceVersion: "0"})
+		if err == nil {
+			if o := metav1.GetControllerOf(rs); o != nil {
+				return o.Kind + "/" + o.Name
+			}
+		}
+	}
+	return owner.Kind + "/" + owner.Name
+}
+
+// mergeViolations merges the entries into the records of the VarmorViolation object. The entries of the same
+// rule and workload are deduplicated into one record. The records that exceed the retention are dropped.
+func mergeViolations(vv *varmor.VarmorViolation, nodeName string, entries []varmortypes.ViolationEntry, workloads map[string]string, now time.Time) {
+	for _, entry := range entries {
+		workload := workloads[entry.PodNamespace+"/"+entry.PodName]
+
+		var record *varmor.ViolationRecord
+		for i := range vv.Records {
+			r := &vv.Records[i]
+			if r.RuleID == entry.RuleID && r.RuleType == entry.RuleType && r.Capability == entry.Capability &&
+				r.Namespace == entry.PodNamespace && r.Workload == workload {
+				record = r
+				break
+			}
+		}
+
+		if record == nil {
+			vv.Records = append(vv.Records, varmor.ViolationRecord{
+				RuleID:         entry.RuleID,
+				RuleType:       entry.RuleType,
+				Capability:     entry.Capability,
+				Namespace:      entry.PodNamespace,
+				Workload:       workload,
+				FirstTimestamp: metav1.NewTime(entry.FirstTimestamp),
+				LastTimestamp:  metav1.NewTime(entry.LastTimestamp),
+			})
+			record = &vv.Records[len(vv.Records)-1]
+		}
+
+		// The service account of the workload may be unknown to some of the agents
+		if entry.ServiceAccount != "" {
+			record.ServiceAccount = entry.ServiceAccount
+			record.SPIFFEID = entry.SPIFFEID
+		}
+
+		record.Count += entry.Count
+		if entry.Audit {
+			record.AuditCount += entry.Count
+		}
+		if entry.FirstTimestamp.Before(record.FirstTimestamp.Time) {
+			record.FirstTimestamp = metav1.NewTime(entry.FirstTimestamp)
+		}
+		if entry.LastTimestamp.After(record.LastTimestamp.Time) {
+			record.LastTimestamp = metav1.NewTime(entry.LastTimestamp)
+		}
+		if !varmorutils.InStringArray(nodeName, record.Nodes) {
+			record.Nodes = append(record.Nodes, nodeName)
+		}
+	}
+
+	// Drop the expired records, and keep the latest ones
+	records := vv.Records[:0]
+	for _, record := range vv.Records {
+		if now.Sub(record.LastTimestamp.Time) <= violationRetention {
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].LastTimestamp.After(records[j].LastTimestamp.Time)
+	})
+	if len(records) > maxViolationRecords {
+		records = records[:maxViolationRecords]
+	}
+	vv.Records = records
+
+	vv.TotalCount = 0
+	for _, record := range vv.Records {
+		vv.TotalCount += record.Count
+	}
+}
+
+// newVarmorViolation creates a VarmorViolation object which is owned by the ArmorProfile object,
+// so it will be garbage collected along with the policy.
+func newVarmorViolation(ap *varmor.ArmorProfile) *varmor.VarmorViolation {
+	vv := varmor.VarmorViolation{}
+	vv.Name = ap.Name
+	vv.Namespace = ap.Namespace
+	vv.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion: varmor.GroupVersion.String(),
+			Kind:       "ArmorProfile",
+			Name:       ap.Name,
+			UID:        ap.UID,
+		},
+	}
+	return &vv
+}
+
+func (m *StatusManager) syncViolation(data string) error {
+	logger := m.log.WithName("syncViolation()")
+
+	startTime := time.Now()
+	logger.V(3).Info("started syncing violation", "startTime", startTime)
+	defer func() {
+		logger.V(3).Info("finished syncing violation", "processingTime", time.Since(startTime).String())
+	}()
+
+	// Unmarshal the violation data comes from agent
+	var violationData varmortypes.ViolationData
+	err := json.Unmarshal([]byte(data), &violationData)
+	if err != nil {
+		logger.Error(err, "json.Unmarshal() violationData failed")
+		return nil
+	}
+	logger.V(3).Info("receive violation data from agent", "profile", violationData.ProfileName, "node", violationData.NodeName)
+
+	ap, err := m.varmorInterface.ArmorProfiles(violationData.Namespace).Get(context.Background(), violationData.ProfileName, metav1.GetOptions{})
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			// The policy has been deleted
+			return nil
+		}
+		return err
+	}
+
+	workloads := make(map[string]string)
+	for _, entry := range violationData.Entries {
+		key := entry.PodNamespace + "/" + entry.PodName
+		if _, ok := workloads[key]; !ok {
+			workloads[key] = m.resolveWorkload(entry.PodNamespace, entry.PodName)
+		}
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		vv, err := m.varmorInterface.VarmorViolations(ap.Namespace).Get(context.Background(), ap.Name, metav1.GetOptions{})
+		if err != nil {
+			if !k8errors.IsNotFound(err) {
+				return err
+			}
+			vv = newVarmorViolation(ap)
+			mergeViolations(vv, violationData.NodeName, violationData.Entries, workloads, time.Now())
+			_, err = m.varmorInterface.VarmorViolations(ap.Namespace).Create(context.Background(), vv, metav1.CreateOptions{})
+			return err
+		}
+
+		mergeViolations(vv, violationData.NodeName, violationData.Entries, workloads, time.Now())
+		_, err = m.varmorInterface.VarmorViolations(ap.Namespace).Update(context.Background(), vv, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Score the violations after they're merged, so the retried data isn't observed twice
+	if m.anomalyDetector != nil {
+		anomalies := m.anomalyDetector.observe(ap.Name, violationData.NodeName, violationData.Entries, workloads, time.Now())
+		m.reportAnomalies(ap, anomalies)
+	}
+	return nil
+}
+
+func (m *StatusManager) handleViolationErr(err error, data interface{}) {
+	logger := m.log
+	if err == nil {
+		m.violationQueue.Forget(data)
+		return
+	}
+
+	if m.violationQueue.NumRequeues(data) < maxRetries {
+		logger.Error(err, "failed to sync violation", "data", data)
+		m.violationQueue.AddRateLimited(data)
+		return
+	}
+
+	utilruntime.HandleError(err)
+	logger.V(3).Info("dropping data out of violationQueue", "key", data)
+	m.violationQueue.Forget(data)
+}
+
+func (m *StatusManager) processNextViolationWorkItem() bool {
+	data, quit := m.violationQueue.Get()
+	if quit {
+		return false
+	}
+	defer m.violationQueue.Done(data)
+
+	err := m.syncViolation(data.(string))
+	m.handleViolationErr(err, data)
+
+	return true
+}
+
+func (m *StatusManager) violationWorker() {
+	for m.processNextViolationWorkItem() {
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusmanagerv1
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+func Test_mergeViolations(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	workloads := map[string]string{
+		"demo/web-1": "Deployment/web",
+		"demo/web-2": "Deployment/web",
+		"demo/job-1": "Job/job",
+	}
+	vv := &varmor.VarmorViolation{}
+
+	mergeViolations(vv, "node-a", []varmortypes.ViolationEntry{
+		{
+			PodNamespace:   "demo",
+			PodName:        "web-1",
+			RuleID:         "bpfRawRules.files/0",
+			RuleType:       "file",
+			Count:          2,
+			FirstTimestamp: now.Add(-time.Hour),
+			LastTimestamp:  now.Add(-30 * time.Minute),
+		},
+		{
+			PodNamespace:   "demo",
+			PodName:        "job-1",
+			RuleID:         "bpfRawRules.files/0",
+			RuleType:       "file",
+			Count:          1,
+			FirstTimestamp: now.Add(-2 * time.Hour),
+			LastTimestamp:  now.Add(-2 * time.Hour),
+		},
+	}, workloads, now)
+
+	// The violations of the same rule and workload are merged across the pods and the nodes
+	mergeViolations(vv, "node-b", []varmortypes.ViolationEntry{
+		{
+			PodNamespace:   "demo",
+			PodName:        "web-2",
+			RuleID:         "bpfRawRules.files/0",
+			RuleType:       "file",
+			Count:          3,
+			FirstTimestamp: now.Add(-2 * time.Hour),
+			LastTimestamp:  now.Add(-time.Minute),
+			ServiceAccount: "web",
+			SPIFFEID:       "spiffe://cluster.local/ns/demo/sa/web",
+			Audit:          true,
+		},
+	}, workloads, now)
+
+	assert.Equal(t, len(vv.Records), 2)
+	assert.Equal(t, vv.TotalCount, int64(6))
+
+	web := vv.Records[0]
+	assert.Equal(t, web.Workload, "Deployment/web")
+	assert.Equal(t, web.Count, int64(5))
+	assert.Equal(t, web.AuditCount, int64(3))
+	assert.Equal(t, web.FirstTimestamp.Time, now.Add(-2*time.Hour))
+	assert.Equal(t, web.LastTimestamp.Time, now.Add(-time.Minute))
+	assert.DeepEqual(t, web.Nodes, []string{"node-a", "node-b"})
+	assert.Equal(t, web.ServiceAccount, "web")
+	assert.Equal(t, web.SPIFFEID, "spiffe://cluster.local/ns/demo/sa/web")
+
+	job := vv.Records[1]
+	assert.Equal(t, job.Workload, "Job/job")
+
+	// The expired records are dropped
+	mergeViolations(vv, "node-a", nil, workloads, now.Add(violationRetention-30*time.Minute))
+	assert.Equal(t, len(vv.Records), 1)
+	assert.Equal(t, vv.Records[0].Workload, "Deployment/web")
+	assert.Equal(t, vv.TotalCount, int64(5))
+}
+
+func Test_mergeViolationsLimits(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	vv := &varmor.VarmorViolation{}
+
+	var entries []varmortypes.ViolationEntry
+	for i := 0; i < maxViolationRecords+1; i++ {
+		entries = append(entries, varmortypes.ViolationEntry{
+			PodNamespace:   "demo",
+			PodName:        "web-1",
+			RuleID:         fmt.Sprintf("bpfRawRules.files/%d", i),
+			RuleType:       "file",
+			Count:          1,
+			FirstTimestamp: now.Add(time.Duration(i) * time.Second),
+			LastTimestamp:  now.Add(time.Duration(i) * time.Second),
+		})
+	}
+	mergeViolations(vv, "node-a", entries, nil, now)
+
+	// The latest records are kept
+	assert.Equal(t, len(vv.Records), maxViolationRecords)
+	assert.Equal(t, vv.Records[0].RuleID, fmt.Sprintf("bpfRawRules.files/%d", maxViolationRecords))
+	assert.Equal(t, vv.Records[maxViolationRecords-1].RuleID, "bpfRawRules.files/1")
+	assert.Equal(t, vv.TotalCount, int64(maxViolationRecords))
+
+}
+
+func Test_newVarmorViolation(t *testing.T) {
+	ap := &varmor.ArmorProfile{ObjectMeta: metav1.ObjectMeta{Namespace: "demo", Name: "varmor-demo-web", UID: "uid"}}
+	vv := newVarmorViolation(ap)
+
+	assert.Equal(t, vv.Namespace, "demo")
+	assert.Equal(t, vv.Name, "varmor-demo-web")
+	assert.Equal(t, len(vv.OwnerReferences), 1)
+	assert.Equal(t, vv.OwnerReferences[0].Kind, "ArmorProfile")
+	assert.Equal(t, string(vv.OwnerReferences[0].UID), "uid")
+}
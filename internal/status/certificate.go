@@ -0,0 +1,84 @@
+// Copyright 2022 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	varmortls "github.com/bytedance/vArmor/internal/tls"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// CheckAgentCert authenticates the client certificate of the request, which must be issued by the agent CA.
+// It's used to protect the APIs for agents when the mutual TLS is enabled.
+func CheckAgentCert(agentCAPool *x509.CertPool, debug bool) gin.HandlerFunc {
+	if debug || agentCAPool == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		if err := varmortls.VerifyAgentCert(c.Request.TLS, agentCAPool); err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// certificateIssuer issues the client certificates of agents with the agent CA
+type certificateIssuer struct {
+	agentCA          *varmortls.KeyPair
+	serverCA         []byte
+	validityDuration time.Duration
+}
+
+// issueAgentCertificate signs the certificate signing request of an agent. The request is authenticated with
+// the token of agent, so the agent can bootstrap or renew its certificate.
+func (s *StatusService) issueAgentCertificate(c *gin.Context) {
+	logger := s.log.WithName("issueAgentCertificate()")
+
+	var req varmortypes.AgentCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(err, "c.ShouldBindJSON()")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	if req.NodeName == "" || len(req.CSR) == 0 {
+		logger.Error(fmt.Errorf("request is illegal"), "bad request body")
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	cert, err := varmortls.SignAgentCSR(s.issuer.agentCA, req.CSR, s.issuer.validityDuration)
+	if err != nil {
+		logger.Error(err, "varmortls.SignAgentCSR()", "node name", req.NodeName)
+		c.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	logger.V(3).Info("issue the client certificate for agent", "node name", req.NodeName)
+	c.JSON(http.StatusOK, varmortypes.AgentCertificateResponse{
+		Certificate: cert,
+		ServerCA:    s.issuer.serverCA,
+	})
+}
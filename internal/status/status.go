@@ -17,17 +17,21 @@ package status
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
 	authv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	appsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
 	authclientv1 "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authzclientv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	varmorconfig "github.com/bytedance/vArmor/internal/config"
@@ -44,6 +48,7 @@ type StatusService struct {
 	router        *gin.Engine
 	addr          string
 	port          int
+	issuer        *certificateIssuer
 	debug         bool
 	log           logr.Logger
 }
@@ -81,6 +86,92 @@ func CheckAgentToken(authInterface authclientv1.AuthenticationV1Interface, debug
 		c.Next()
 	}
 }
+
+// CheckReaderToken authenticates the bearer token of the request, and authorizes the user to
+// list the ArmorProfile objects. It's used to protect the query API.
+func CheckReaderToken(authInterface authclientv1.AuthenticationV1Interface, authzInterface authzclientv1.AuthorizationV1Interface, debug bool) gin.HandlerFunc {
+	if debug {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		tr := &authv1.TokenReview{
+			Spec: authv1.TokenReviewSpec{
+				Token: token,
+			},
+		}
+		result, err := authInterface.TokenReviews().Create(context.Background(), tr, metav1.CreateOptions{})
+		if err != nil || !result.Status.Authenticated {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		extra := make(map[string]authzv1.ExtraValue, len(result.Status.User.Extra))
+		for k, v := range result.Status.User.Extra {
+			extra[k] = authzv1.ExtraValue(v)
+		}
+		sar := &authzv1.SubjectAccessReview{
+			Spec: authzv1.SubjectAccessReviewSpec{
+				User:   result.Status.User.Username,
+				Groups: result.Status.User.Groups,
+				UID:    result.Status.User.UID,
+				Extra:  extra,
+				ResourceAttributes: &authzv1.ResourceAttributes{
+					Group:    "crd.varmor.org",
+					Resource: "armorprofiles",
+					Verb:     "list",
+				},
+			},
+		}
+		review, err := authzInterface.SubjectAccessReviews().Create(context.Background(), sar, metav1.CreateOptions{})
+		if err != nil || !review.Status.Allowed {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CheckClientCert authenticates the client certificate of the request, which must be verified with the
+// client CA configured by the --gatekeeperClientCA argument. It's used to protect the Gatekeeper provider API.
+func CheckClientCert(clientCAPool *x509.CertPool, debug bool) gin.HandlerFunc {
+	if debug {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		// The client CAs of the server may include the agent CA, so verify it with the client CA of Gatekeeper only.
+		state := c.Request.TLS
+		if state == nil || len(state.PeerCertificates) == 0 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         clientCAPool,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
 func health(c *gin.Context) {
 	c.JSON(http.StatusOK, "ok")
 }
@@ -89,11 +180,15 @@ func NewStatusService(
 	addr string,
 	port int,
 	tlsPair *varmortls.PemPair,
+	gatekeeperClientCA []byte,
+	agentCA *varmortls.KeyPair,
+	serverCA []byte,
 	debug bool,
 	coreInterface corev1.CoreV1Interface,
 	appsInterface appsv1.AppsV1Interface,
 	varmorInterface varmorinterface.CrdV1beta1Interface,
 	authInterface authclientv1.AuthenticationV1Interface,
+	authzInterface authzclientv1.AuthorizationV1Interface,
 	statusUpdateCycle time.Duration,
 	log logr.Logger) (*StatusService, error) {
 
@@ -119,8 +214,43 @@ func NewStatusService(
 	}
 	s.router.SetTrustedProxies(nil)
 
-	s.router.POST(varmorconfig.StatusSyncPath, CheckAgentToken(authInterface, debug), statusManager.Status)
-	s.router.POST(varmorconfig.DataSyncPath, CheckAgentToken(authInterface, debug), statusManager.Data)
+	// The agents must present the client certificates issued by the agent CA if the mutual TLS is enabled.
+	var agentCAPool *x509.CertPool
+	if agentCA != nil {
+		agentCAPool = x509.NewCertPool()
+		agentCAPool.AddCert(agentCA.Cert)
+		s.issuer = &certificateIssuer{
+			agentCA:          agentCA,
+			serverCA:         serverCA,
+			validityDuration: varmorconfig.AgentCertValidityDuration,
+		}
+		s.router.POST(varmorconfig.CertificatePath, CheckAgentToken(authInterface, debug), s.issueAgentCertificate)
+	}
+
+	s.router.POST(varmorconfig.StatusSyncPath, CheckAgentToken(authInterface, debug), CheckAgentCert(agentCAPool, debug), statusManager.Status)
+	s.router.POST(varmorconfig.DataSyncPath, CheckAgentToken(authInterface, debug), CheckAgentCert(agentCAPool, debug), statusManager.Data)
+	s.router.POST(varmorconfig.ViolationSyncPath, CheckAgentToken(authInterface, debug), CheckAgentCert(agentCAPool, debug), statusManager.Violation)
+	s.router.POST(varmorconfig.CoverageSyncPath, CheckAgentToken(authInterface, debug), CheckAgentCert(agentCAPool, debug), statusManager.Coverage)
+	s.router.POST(varmorconfig.InventorySyncPath, CheckAgentToken(authInterface, debug), CheckAgentCert(agentCAPool, debug), statusManager.Inventory)
+	s.router.POST(varmorconfig.TamperSyncPath, CheckAgentToken(authInterface, debug), CheckAgentCert(agentCAPool, debug), statusManager.Tamper)
+	s.router.POST(varmorconfig.SuspensionSyncPath, CheckAgentToken(authInterface, debug), CheckAgentCert(agentCAPool, debug), statusManager.Suspension)
+	s.router.GET(varmorconfig.QueryPoliciesPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.QueryPolicies)
+	s.router.GET(varmorconfig.QueryProfilePath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.QueryProfile)
+	s.router.GET(varmorconfig.QueryProfileReportPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.QueryProfileReport)
+	s.router.GET(varmorconfig.QueryProfileDiffPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.QueryProfileDiff)
+	s.router.GET(varmorconfig.QueryModelReportPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.QueryModelReport)
+	s.router.GET(varmorconfig.QueryModelDiffPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.QueryModelDiff)
+	s.router.GET(varmorconfig.QueryViolationsPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.QueryViolations)
+	s.router.POST(varmorconfig.ConvertKubeArmorPolicyPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.ConvertKubeArmorPolicy)
+	s.router.GET(varmorconfig.GeneratePSSPolicyPath, CheckReaderToken(authInterface, authzInterface, debug), statusManager.GeneratePSSPolicy)
+	var gatekeeperCAPool *x509.CertPool
+	if gatekeeperClientCA != nil {
+		gatekeeperCAPool = x509.NewCertPool()
+		if !gatekeeperCAPool.AppendCertsFromPEM(gatekeeperClientCA) {
+			return nil, fmt.Errorf("failed to parse the client CA of Gatekeeper")
+		}
+		s.router.POST(varmorconfig.GatekeeperProviderPath, CheckClientCert(gatekeeperCAPool, debug), statusManager.GatekeeperProvider)
+	}
 	s.router.GET("/healthz", health)
 
 	cert, err := tls.X509KeyPair(tlsPair.Certificate, tlsPair.PrivateKey)
@@ -131,6 +261,19 @@ func NewStatusService(
 	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{cert},
 	}
+	if gatekeeperCAPool != nil || agentCAPool != nil {
+		// The client certificates are only verified if given, because the readers of the query API and the
+		// agents which are bootstrapping don't send them. The APIs check the issuers of them respectively.
+		pool := x509.NewCertPool()
+		if gatekeeperClientCA != nil {
+			pool.AppendCertsFromPEM(gatekeeperClientCA)
+		}
+		if agentCA != nil {
+			pool.AddCert(agentCA.Cert)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
 	s.srv = &http.Server{
 		Addr:      fmt.Sprintf("%s:%d", s.addr, s.port),
 		Handler:   s.router,
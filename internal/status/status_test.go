@@ -0,0 +1,99 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gotest.tools/assert"
+	authv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_CheckReaderToken(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tr := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview)
+		switch tr.Spec.Token {
+		case "reader", "writer":
+			tr.Status.Authenticated = true
+			tr.Status.User.Username = tr.Spec.Token
+		}
+		return true, tr, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		attributes := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "reader" &&
+			attributes.Group == "crd.varmor.org" && attributes.Resource == "armorprofiles" && attributes.Verb == "list"
+		return true, sar, nil
+	})
+
+	testCases := []struct {
+		name          string
+		authorization string
+		debug         bool
+		expected      int
+	}{
+		{
+			name:     "no token",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:          "unauthenticated",
+			authorization: "Bearer unknown",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:          "unauthorized",
+			authorization: "Bearer writer",
+			expected:      http.StatusForbidden,
+		},
+		{
+			name:          "authorized",
+			authorization: "Bearer reader",
+			expected:      http.StatusOK,
+		},
+		{
+			name:     "debug",
+			debug:    true,
+			expected: http.StatusOK,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", CheckReaderToken(client.AuthenticationV1(), client.AuthorizationV1(), tc.debug), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, w.Code, tc.expected)
+		})
+	}
+}
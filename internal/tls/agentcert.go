@@ -0,0 +1,194 @@
+// This file may have been modified by vArmor Authors. ("vArmor Modifications").
+// All vArmor Modifications are Copyright 2022 vArmor Authors.
+//
+// Copyright 2021 Kyverno Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+
+package tls
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/bytedance/vArmor/internal/config"
+)
+
+// GenerateAgentCASecretName returns the name of the secret which stores the CA of the agent client certificates
+func GenerateAgentCASecretName(props CertificateProps) string {
+	return props.Service + "." + props.Namespace + ".varmor-agent-ca"
+}
+
+// InitAgentCA loads or creates the CA which issues the client certificates of agents. The CA is stored
+// in the secret with its private key, so the agent certificates stay valid when the leader changes.
+// A new CA is created if the existing one will expire within the renewal interval.
+func (c *CertRenewer) InitAgentCA() (*KeyPair, error) {
+	logger := c.log.WithName("InitAgentCA")
+
+	certProps, err := GetTLSCertProps(c.clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	secretName := GenerateAgentCASecretName(certProps)
+
+	pemPair, err := ReadTLSPair(c.secretInterface, secretName)
+	if err == nil {
+		ca, err := ParseKeyPair(pemPair)
+		if err == nil && time.Now().Add(c.certRenewalInterval).Before(ca.Cert.NotAfter) {
+			logger.Info("using existing agent CA")
+			return ca, nil
+		}
+	}
+
+	logger.Info("building new agent CA")
+	ca, caPEM, err := generateCACert(config.AgentCACommonName, c.certValidityDuration)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.WriteTLSPairToSecret(caPEM, secretName); err != nil {
+		return nil, fmt.Errorf("unable to save the agent CA to the secret %s: %v", secretName, err)
+	}
+	return ca, nil
+}
+
+// ParseKeyPair parses the PEM-encoded certificate and private key
+func ParseKeyPair(pemPair *PemPair) (*KeyPair, error) {
+	certPem, _ := pem.Decode(pemPair.Certificate)
+	if certPem == nil {
+		return nil, fmt.Errorf("bad certificate")
+	}
+	cert, err := x509.ParseCertificate(certPem.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cert: %v", err)
+	}
+
+	keyPem, _ := pem.Decode(pemPair.PrivateKey)
+	if keyPem == nil {
+		return nil, fmt.Errorf("bad private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyPem.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+
+	return &KeyPair{Cert: cert, Key: key}, nil
+}
+
+// GenerateAgentCSR creates the private key and the PEM-encoded certificate signing request of the agent
+// running on the node.
+func GenerateAgentCSR(nodeName string) ([]byte, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating key: %v", err)
+	}
+
+	templ := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: config.AgentCertCommonNamePrefix + nodeName,
+		},
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, templ, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating certificate request: %v", err)
+	}
+
+	csr := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: der,
+	})
+	return csr, key, nil
+}
+
+// SignAgentCSR issues the client certificate for the PEM-encoded certificate signing request of an agent
+// with the agent CA. Returns the certificate in PEM format.
+func SignAgentCSR(ca *KeyPair, csrPEM []byte, certValidityDuration time.Duration) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("bad certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate request: %v", err)
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid signature of certificate request: %v", err)
+	}
+	if !strings.HasPrefix(csr.Subject.CommonName, config.AgentCertCommonNamePrefix) {
+		return nil, fmt.Errorf("the common name %q of certificate request is not allowed", csr.Subject.CommonName)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("error generating serial number: %v", err)
+	}
+
+	now := time.Now()
+	end := now.Add(certValidityDuration)
+	if end.After(ca.Cert.NotAfter) {
+		end = ca.Cert.NotAfter
+	}
+	templ := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName: csr.Subject.CommonName,
+		},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              end,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, templ, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating certificate for agent: %v", err)
+	}
+	return CertificateToPem(der), nil
+}
+
+// VerifyAgentCert verifies the client certificate of an agent with the pool of the agent CA
+func VerifyAgentCert(state *tls.ConnectionState, pool *x509.CertPool) error {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("no client certificate")
+	}
+	cert := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(cert.Subject.CommonName, config.AgentCertCommonNamePrefix) {
+		return fmt.Errorf("the common name %q of client certificate is not allowed", cert.Subject.CommonName)
+	}
+	return nil
+}
+
+// ReadRootCA returns the root CA which issues the TLS certificate of the webhook server and the status service
+func (c *CertRenewer) ReadRootCA() ([]byte, error) {
+	certProps, err := GetTLSCertProps(c.clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return ReadRootCASecret(c.secretInterface, GenerateRootCASecretName(certProps))
+}
@@ -0,0 +1,88 @@
+// This file may have been modified by vArmor Authors. ("vArmor Modifications").
+// All vArmor Modifications are Copyright 2022 vArmor Authors.
+//
+// Copyright 2021 Kyverno Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+
+package tls
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	"github.com/bytedance/vArmor/internal/config"
+)
+
+func Test_SignAgentCSR(t *testing.T) {
+	ca, caPEM, err := generateCACert(config.AgentCACommonName, time.Hour)
+	assert.NilError(t, err)
+
+	parsed, err := ParseKeyPair(caPEM)
+	assert.NilError(t, err)
+	assert.Equal(t, parsed.Cert.Subject.CommonName, config.AgentCACommonName)
+
+	csr, key, err := GenerateAgentCSR("node-1")
+	assert.NilError(t, err)
+
+	certPEM, err := SignAgentCSR(ca, csr, 24*time.Hour)
+	assert.NilError(t, err)
+
+	cert, err := tls.X509KeyPair(certPEM, PrivateKeyToPem(key))
+	assert.NilError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NilError(t, err)
+	assert.Equal(t, leaf.Subject.CommonName, "varmor-agent:node-1")
+	// The certificate doesn't outlive the CA
+	assert.Equal(t, leaf.NotAfter.After(ca.Cert.NotAfter), false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	err = VerifyAgentCert(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}, pool)
+	assert.NilError(t, err)
+
+	// The certificates issued by other CAs are rejected
+	otherCA, _, err := generateCACert(config.AgentCACommonName, time.Hour)
+	assert.NilError(t, err)
+	otherPool := x509.NewCertPool()
+	otherPool.AddCert(otherCA.Cert)
+	err = VerifyAgentCert(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}, otherPool)
+	assert.Assert(t, err != nil)
+
+	err = VerifyAgentCert(&tls.ConnectionState{}, pool)
+	assert.Assert(t, err != nil)
+}
+
+func Test_SignAgentCSRIllegal(t *testing.T) {
+	ca, _, err := generateCACert(config.AgentCACommonName, time.Hour)
+	assert.NilError(t, err)
+
+	_, err = SignAgentCSR(ca, []byte("bad"), time.Hour)
+	assert.Assert(t, err != nil)
+
+	// The common name must have the prefix of agents
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "system:admin"},
+	}, key)
+	assert.NilError(t, err)
+	csr := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+	_, err = SignAgentCSR(ca, csr, time.Hour)
+	assert.Assert(t, err != nil)
+}
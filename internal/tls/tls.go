@@ -75,6 +75,10 @@ func PrivateKeyToPem(rsaKey *rsa.PrivateKey) []byte {
 // GenerateCACert creates the self-signed CA cert and private key.
 // It will be used to sign the webhook server certificate.
 func GenerateCACert(certValidityDuration time.Duration) (*KeyPair, *PemPair, error) {
+	return generateCACert(config.CertCommonName, certValidityDuration)
+}
+
+func generateCACert(commonName string, certValidityDuration time.Duration) (*KeyPair, *PemPair, error) {
 	now := time.Now()
 	begin := now.Add(-1 * time.Hour)
 	end := now.Add(certValidityDuration)
@@ -82,7 +86,7 @@ func GenerateCACert(certValidityDuration time.Duration) (*KeyPair, *PemPair, err
 	templ := &x509.Certificate{
 		SerialNumber: big.NewInt(0),
 		Subject: pkix.Name{
-			CommonName: config.CertCommonName,
+			CommonName: commonName,
 		},
 		NotBefore:             begin,
 		NotAfter:              end,
@@ -131,6 +135,8 @@ func GenerateCertPem(caCert *KeyPair, props CertificateProps, certValidityDurati
 	dnsNames[0] = csCommonName
 	dnsNames[1] = fmt.Sprintf("%s.%s", props.Service, props.Namespace)
 	dnsNames[2] = GenerateInClusterServiceName(props)
+	// The status service shares the certificate, so the external clients (e.g. OPA Gatekeeper) can verify it
+	dnsNames = append(dnsNames, fmt.Sprintf("%s.%s.svc", config.StatusServiceName, props.Namespace))
 
 	var ips []net.IP
 	apiServerIP := net.ParseIP(props.APIServerHost)
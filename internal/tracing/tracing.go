@@ -0,0 +1,123 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing instruments the profile lifecycle operations with OpenTelemetry. The trace context
+// is propagated from the manager to the agents with the annotations of the ArmorProfile objects.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	tracerName = "github.com/bytedance/vArmor"
+
+	// annotationPrefix is the prefix of the annotations which carry the trace context
+	annotationPrefix = "tracing.varmor.org/"
+)
+
+// Init sets up the global tracer provider which exports the spans to stdout. The spans are
+// dropped by the default no-op provider if it's not called.
+func Init(serviceName string) (func(context.Context) error, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span with the tracer of vArmor
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records the error if any, and ends the span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// annotationCarrier adapts the annotations of an object to propagation.TextMapCarrier
+type annotationCarrier struct {
+	obj metav1.Object
+}
+
+func (c annotationCarrier) Get(key string) string {
+	return c.obj.GetAnnotations()[annotationPrefix+key]
+}
+
+func (c annotationCarrier) Set(key string, value string) {
+	annotations := c.obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[annotationPrefix+key] = value
+	c.obj.SetAnnotations(annotations)
+}
+
+func (c annotationCarrier) Keys() []string {
+	var keys []string
+	for key := range c.obj.GetAnnotations() {
+		if strings.HasPrefix(key, annotationPrefix) {
+			keys = append(keys, strings.TrimPrefix(key, annotationPrefix))
+		}
+	}
+	return keys
+}
+
+// InjectIntoObject writes the trace context of ctx into the annotations of the object
+func InjectIntoObject(ctx context.Context, obj metav1.Object) {
+	otel.GetTextMapPropagator().Inject(ctx, annotationCarrier{obj: obj})
+}
+
+// ExtractFromHTTPHeader returns a copy of ctx with the trace context read from the HTTP request header
+func ExtractFromHTTPHeader(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// ExtractFromObject returns a copy of ctx with the trace context read from the annotations of the object
+func ExtractFromObject(ctx context.Context, obj metav1.Object) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, annotationCarrier{obj: obj})
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// withRecorder replaces the global tracer provider and propagator with the ones that record the spans in memory
+func withRecorder(t *testing.T) *tracetest.SpanRecorder {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func Test_annotationCarrier(t *testing.T) {
+	ap := &varmor.ArmorProfile{}
+	carrier := annotationCarrier{obj: ap}
+	assert.Equal(t, carrier.Get("traceparent"), "")
+	assert.Equal(t, len(carrier.Keys()), 0)
+
+	carrier.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	carrier.Set("tracestate", "varmor=1")
+	ap.Annotations["other"] = "value"
+
+	assert.Equal(t, ap.Annotations[annotationPrefix+"traceparent"], "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Equal(t, carrier.Get("tracestate"), "varmor=1")
+	keys := carrier.Keys()
+	sort.Strings(keys)
+	assert.DeepEqual(t, keys, []string{"traceparent", "tracestate"})
+}
+
+func Test_propagation(t *testing.T) {
+	recorder := withRecorder(t)
+
+	ctx, span := StartSpan(context.Background(), "manager")
+	ap := &varmor.ArmorProfile{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	InjectIntoObject(ctx, ap)
+	EndSpan(span, nil)
+
+	// The span of the agent is a child of the span of the manager
+	ctx = ExtractFromObject(context.Background(), ap)
+	_, child := StartSpan(ctx, "agent")
+	EndSpan(child, errors.New("failed"))
+
+	spans := recorder.Ended()
+	assert.Equal(t, len(spans), 2)
+	assert.Equal(t, spans[1].Parent().SpanID(), spans[0].SpanContext().SpanID())
+	assert.Equal(t, spans[1].SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Equal(t, spans[0].Status().Code, codes.Unset)
+	assert.Equal(t, spans[1].Status().Code, codes.Error)
+	assert.Equal(t, spans[1].Status().Description, "failed")
+
+	// The trace context is also read from the HTTP header
+	header := http.Header{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), spans[0].SpanContext()), propagation.HeaderCarrier(header))
+	ctx = ExtractFromHTTPHeader(context.Background(), header)
+	assert.Equal(t, trace.SpanContextFromContext(ctx).TraceID(), spans[0].SpanContext().TraceID())
+}
@@ -16,6 +16,7 @@ package types
 
 import (
 	"strings"
+	"time"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
 )
@@ -28,6 +29,14 @@ const (
 	BPF      Enforcer = 0x00000002
 	Seccomp  Enforcer = 0x00000004
 	Unknown  Enforcer = 0x00000008
+	// BestAvailable is set along with all the enforcers, the agent selects the enforcers for each node
+	BestAvailable Enforcer = 0x00000010
+	// Landlock is the lighter alternative of the BPF enforcer for the file rules, it's applied by the launcher
+	// which wraps the commands of the target containers
+	Landlock Enforcer = 0x00000020
+	// SELinux confines the target containers with the SELinux policy modules, it's used on the RHEL-family nodes
+	// which disable AppArmor
+	SELinux Enforcer = 0x00000040
 
 	// VarmorPolicy Mode
 	AlwaysAllowMode      varmor.VarmorPolicyMode = "AlwaysAllow"
@@ -36,6 +45,15 @@ const (
 	BehaviorModelingMode varmor.VarmorPolicyMode = "BehaviorModeling"
 	DefenseInDepthMode   varmor.VarmorPolicyMode = "DefenseInDepth"
 
+	// Drift Detection Action
+	DriftAuditAction string = "Audit"
+	DriftDenyAction  string = "Deny"
+
+	// Path Generalization Level
+	PathGeneralizationDisabled     string = "Disabled"
+	PathGeneralizationConservative string = "Conservative"
+	PathGeneralizationAggressive   string = "Aggressive"
+
 	// VarmorPolicy Phase
 	VarmorPolicyPending    varmor.VarmorPolicyPhase = "Pending"
 	VarmorPolicyModeling   varmor.VarmorPolicyPhase = "Modeling"
@@ -52,11 +70,46 @@ const (
 
 	// ArmorProfile Condition Type
 	ArmorProfileReady      varmor.ArmorProfileConditionType      = "Ready"
+	ArmorProfileTruncated  varmor.ArmorProfileConditionType      = "Truncated"
+	ArmorProfileSkipped    varmor.ArmorProfileConditionType      = "Skipped"
 	ArmorProfileModelReady varmor.ArmorProfileModelConditionType = "Ready"
+	// ArmorProfileUnsupported indicates that the node can't enforce any profile, e.g. neither the BPF LSM nor
+	// the AppArmor LSM is enabled
+	ArmorProfileUnsupported varmor.ArmorProfileConditionType = "Unsupported"
 
 	// AppArmor Profile process Status
 	Succeeded Status = "succeeded"
 	Failed    Status = "failed"
+	// Skipped indicates that the node doesn't match the node selector of the policy
+	Skipped Status = "skipped"
+	// Unsupported indicates that the agent runs in the unsupported state since the node can't enforce any profile
+	Unsupported Status = "unsupported"
+
+	// The labels of the features that agents probed on the node, they are used to match the node selector of the policy.
+	AppArmorFeatureLabel      string = "varmor.org/apparmor"
+	BpfLsmFeatureLabel        string = "varmor.org/bpf-lsm"
+	LandlockFeatureLabel      string = "varmor.org/landlock"
+	SELinuxFeatureLabel       string = "varmor.org/selinux"
+	KernelVersionFeatureLabel string = "varmor.org/kernel-version"
+
+	// EnforcementAnnotation is the annotation that agents write back to the pods, it describes the BPF profiles
+	// enforced for the containers of the pod.
+	EnforcementAnnotation string = "enforcement.varmor.org/containers"
+
+	// ApproveSuggestionAnnotation is the annotation of the policy to approve the tightening suggestion of its BPF
+	// profile, its value is the ID of the suggestion
+	ApproveSuggestionAnnotation string = "varmor.org/approve-suggestion"
+
+	// SuspendAnnotationPrefix is the prefix of the pod annotations that suspend the enforcement of the containers
+	// temporarily, e.g. "suspend.varmor.org/nginx: 2024-06-01T08:00:00Z". The value is the time in RFC 3339 when
+	// the enforcement of the container is resumed.
+	SuspendAnnotationPrefix string = "suspend.varmor.org/"
+
+	// Lifecycle Event Type
+	PreEnforceEvent        LifecycleEventType = "PreEnforce"
+	PostEnforceEvent       LifecycleEventType = "PostEnforce"
+	ModeChangedEvent       LifecycleEventType = "ModeChanged"
+	EnforcementFailedEvent LifecycleEventType = "EnforcementFailed"
 
 	// AgentLabelSelector is the label selector for agents.
 	AgentLabelSelector string = "app.kubernetes.io/component=varmor-agent"
@@ -68,6 +121,22 @@ const (
 
 type Status string
 
+type LifecycleEventType string
+
+// LifecycleEvent describes a lifecycle event of the policy, it's sent to the lifecycle hooks of the policy by manager.
+type LifecycleEvent struct {
+	Type            LifecycleEventType `json:"type"`
+	PolicyNamespace string             `json:"policyNamespace,omitempty"` // Empty for VarmorClusterPolicy
+	PolicyName      string             `json:"policyName"`
+	ArmorProfile    string             `json:"armorProfile"`
+	Enforcer        string             `json:"enforcer"`
+	Mode            string             `json:"mode"`                   // The mode of the profile, e.g. enforce or complain
+	PreviousMode    string             `json:"previousMode,omitempty"` // Only for the ModeChanged event
+	NodeName        string             `json:"nodeName,omitempty"`     // Only for the EnforcementFailed event
+	Message         string             `json:"message,omitempty"`
+	Timestamp       time.Time          `json:"timestamp"`
+}
+
 // ProfileStatus describes the process result of an ArmorProfile object by agents.
 type ProfileStatus struct {
 	Namespace   string `json:"namespace"`
@@ -75,6 +144,7 @@ type ProfileStatus struct {
 	NodeName    string `json:"nodeName"`
 	Status      Status `json:"status"`
 	Message     string `json:"message"`
+	Warning     string `json:"warning,omitempty"`
 }
 
 // PolicyStatus used to cache the status of ArmorProfile and VarmorProfile objects.
@@ -82,6 +152,10 @@ type PolicyStatus struct {
 	SuccessedNumber int
 	FailedNumber    int
 	NodeMessages    map[string]string // Use NodeName as its key
+	NodeWarnings    map[string]string // Use NodeName as its key
+	SkippedNodes    map[string]string // Use NodeName as its key
+	// UnsupportedNodes are the nodes that can't enforce any profile, they are excluded from the desired nodes
+	UnsupportedNodes map[string]string // Use NodeName as its key
 }
 
 // BehaviorData describes the behavior data of the target container that collected by agents.
@@ -92,6 +166,201 @@ type BehaviorData struct {
 	NodeName      string               `json:"nodeName"`
 	Status        Status               `json:"status"`
 	Message       string               `json:"message"`
+	// Feedback indicates that the data comes from the AppArmor complain records of the DefenseInDepth mode
+	// instead of the behavior modeling.
+	Feedback bool `json:"feedback,omitempty"`
+}
+
+// ViolationEntry describes the violations of a rule in a pod that aggregated by agents.
+type ViolationEntry struct {
+	PodNamespace   string    `json:"podNamespace"`
+	PodName        string    `json:"podName"`
+	RuleID         string    `json:"ruleID,omitempty"`
+	RuleType       string    `json:"ruleType"`
+	Capability     string    `json:"capability,omitempty"`
+	Count          int64     `json:"count"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+	// ServiceAccount is the service account of the pod, and SPIFFEID is the workload identity derived from it
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	SPIFFEID       string `json:"spiffeID,omitempty"`
+	// Audit is true if the operations were allowed by the rule in audit mode
+	Audit bool `json:"audit,omitempty"`
+}
+
+// ViolationData describes the violations of an ArmorProfile object that reported by agents.
+type ViolationData struct {
+	Namespace   string           `json:"namespace"`
+	ProfileName string           `json:"armorProfile"` //  varmor-{namespace}-{name} or varmor-cluster-{namespace}-{name}
+	NodeName    string           `json:"nodeName"`
+	Entries     []ViolationEntry `json:"entries"`
+}
+
+// CoverageData describes how many target pods of an ArmorProfile object are protected on the node,
+// it's reported by agents.
+type CoverageData struct {
+	Namespace      string   `json:"namespace"`
+	ProfileName    string   `json:"armorProfile"` //  varmor-{namespace}-{name} or varmor-cluster-{namespace}-{name}
+	NodeName       string   `json:"nodeName"`
+	DesiredPods    int      `json:"desiredPods"`
+	ReadyPods      int      `json:"readyPods"`
+	FailedPods     int      `json:"failedPods"`
+	FailureReasons []string `json:"failureReasons,omitempty"`
+	PartialPods    int      `json:"partialPods,omitempty"`
+	PartialReasons []string `json:"partialReasons,omitempty"`
+}
+
+// EnforcementState describes the BPF profile that an agent enforces for a container
+type EnforcementState struct {
+	ArmorProfile string `json:"armorProfile"` // {namespace}/{name} of the ArmorProfile object
+	Generation   int64  `json:"generation"`   // The generation of the ArmorProfile object that the profile was loaded from
+	Mode         string `json:"mode"`
+	Enforced     bool   `json:"enforced"` // False if the latest profile failed to apply, the previous one may be enforced
+	Message      string `json:"message,omitempty"`
+}
+
+// ContainerEnforcement describes the BPF profile enforced for a container on the node, it's exposed by agents.
+type ContainerEnforcement struct {
+	PodNamespace     string `json:"podNamespace,omitempty"`
+	PodName          string `json:"podName,omitempty"`
+	ContainerName    string `json:"containerName,omitempty"`
+	ContainerID      string `json:"containerID"`
+	EnforcementState `json:",inline"`
+}
+
+// NodeInventory describes the kernel and the LSM features of a node, it's reported by agents.
+type NodeInventory struct {
+	NodeName      string            `json:"nodeName"`
+	KernelVersion string            `json:"kernelVersion"`
+	LSMs          []string          `json:"lsms,omitempty"` // The LSMs enabled in /sys/kernel/security/lsm
+	AppArmor      bool              `json:"appArmor"`       // The AppArmor enforcer is supported
+	BPF           bool              `json:"bpf"`            // The BPF enforcer is enabled and supported
+	Seccomp       bool              `json:"seccomp"`        // The Seccomp enforcer is supported
+	Landlock      bool              `json:"landlock"`       // The Landlock enforcer is enabled and supported
+	SELinux       bool              `json:"selinux"`        // The SELinux enforcer is enabled and supported
+	BpfFeatures   map[string]bool   `json:"bpfFeatures,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"` // The labels used to match the node selector of policies
+}
+
+// TamperReport describes the entries of a mnt ns in the maps of the BPF enforcer that were modified by anything
+// other than the agent, it's reported by agents.
+type TamperReport struct {
+	NodeName     string    `json:"nodeName"`
+	MntNsID      uint32    `json:"mntNsID"`
+	ProfileName  string    `json:"profileName,omitempty"`
+	PodNamespace string    `json:"podNamespace,omitempty"`
+	PodName      string    `json:"podName,omitempty"`
+	ContainerID  string    `json:"containerID,omitempty"`
+	Maps         []string  `json:"maps"`
+	Restored     bool      `json:"restored"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// SuspensionReport describes a container whose enforcement was suspended temporarily, or resumed, it's reported
+// by agents.
+type SuspensionReport struct {
+	NodeName      string    `json:"nodeName"`
+	ProfileName   string    `json:"profileName"`
+	PodNamespace  string    `json:"podNamespace"`
+	PodName       string    `json:"podName"`
+	ContainerID   string    `json:"containerID"`
+	ContainerName string    `json:"containerName"`
+	Reason        string    `json:"reason,omitempty"`
+	Until         time.Time `json:"until"`
+	Resumed       bool      `json:"resumed"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// AgentCertificateRequest is sent by agents to request a client certificate for the mutual TLS.
+type AgentCertificateRequest struct {
+	NodeName string `json:"nodeName"`
+	CSR      []byte `json:"csr"` // PEM-encoded certificate signing request
+}
+
+// AgentCertificateResponse returns the client certificate of the agent, and the CA to verify the status service.
+type AgentCertificateResponse struct {
+	Certificate []byte `json:"certificate"` // PEM-encoded
+	ServerCA    []byte `json:"serverCA"`    // PEM-encoded
+}
+
+// ProfileDiff describes the rules added and removed between two generations of the profile, it's returned by the
+// query API of manager.
+type ProfileDiff struct {
+	FromGeneration int64    `json:"fromGeneration"`
+	FromHash       string   `json:"fromHash"`
+	ToGeneration   int64    `json:"toGeneration"`
+	ToHash         string   `json:"toHash"`
+	Added          []string `json:"added,omitempty"`
+	Removed        []string `json:"removed,omitempty"`
+}
+
+// PolicyEnforcement describes a policy and the enforcement of its profile, it's returned by the query API of manager.
+type PolicyEnforcement struct {
+	Namespace           string                   `json:"namespace,omitempty"`
+	Name                string                   `json:"name"`
+	ClusterScope        bool                     `json:"clusterScope"`
+	Target              varmor.Target            `json:"target"`
+	ArmorProfile        string                   `json:"armorProfile"`
+	Enforcer            string                   `json:"enforcer"`
+	Mode                string                   `json:"mode"`
+	Phase               varmor.VarmorPolicyPhase `json:"phase,omitempty"`
+	Ready               bool                     `json:"ready"`
+	DesiredNumberLoaded int                      `json:"desiredNumberLoaded"`
+	CurrentNumberLoaded int                      `json:"currentNumberLoaded"`
+	ViolationCount      int64                    `json:"violationCount"`
+}
+
+// ViolationItem describes a violation record of an ArmorProfile, it's returned by the query API of manager.
+type ViolationItem struct {
+	ProfileNamespace string `json:"profileNamespace"`
+	ArmorProfile     string `json:"armorProfile"`
+	varmor.ViolationRecord
+}
+
+// ConversionResult describes the VarmorPolicy object converted from the policy of other enforcement engines,
+// it's returned by the convert API of manager.
+type ConversionResult struct {
+	Policy   *varmor.VarmorPolicy `json:"policy"`
+	Warnings []string             `json:"warnings,omitempty"`
+}
+
+// GatekeeperProviderRequest is the request sent by the external data feature of OPA Gatekeeper.
+// Each key has the format "<namespace>/<kind>/<name>:<labels>", the labels are optional and have the
+// format "key1=value1,key2=value2".
+type GatekeeperProviderRequest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Request    struct {
+		Keys []string `json:"keys"`
+	} `json:"request"`
+}
+
+// GatekeeperItem is the external data of a key, its value is the list of the policies matching the workload.
+type GatekeeperItem struct {
+	Key   string        `json:"key"`
+	Value []PolicyMatch `json:"value,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// GatekeeperProviderResponse is the response returned to the external data feature of OPA Gatekeeper.
+type GatekeeperProviderResponse struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Response   struct {
+		Idempotent  bool             `json:"idempotent"`
+		Items       []GatekeeperItem `json:"items"`
+		SystemError string           `json:"systemError,omitempty"`
+	} `json:"response"`
+}
+
+// PolicyMatch describes a policy matching a workload, it's returned by the Gatekeeper provider API of manager.
+type PolicyMatch struct {
+	Namespace    string                  `json:"namespace,omitempty"`
+	Name         string                  `json:"name"`
+	ClusterScope bool                    `json:"clusterScope"`
+	Enforcer     string                  `json:"enforcer"`
+	Mode         varmor.VarmorPolicyMode `json:"mode"`
+	Ready        bool                    `json:"ready"`
 }
 
 // ModelingStatus used to cache the status of ArmorProfileModel objects.
@@ -169,6 +438,13 @@ var enforcerMap = map[string]Enforcer{
 	"bpfseccompapparmor": AppArmor | BPF | Seccomp,
 	"seccompbpfapparmor": AppArmor | BPF | Seccomp,
 	"seccompapparmorbpf": AppArmor | BPF | Seccomp,
+	"bestavailable":      AppArmor | BPF | Seccomp | BestAvailable,
+	"landlock":           Landlock,
+	"landlockseccomp":    Landlock | Seccomp,
+	"seccomplandlock":    Landlock | Seccomp,
+	"selinux":            SELinux,
+	"selinuxseccomp":     SELinux | Seccomp,
+	"seccompselinux":     SELinux | Seccomp,
 }
 
 func GetEnforcerType(enforcer string) Enforcer {
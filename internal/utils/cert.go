@@ -0,0 +1,179 @@
+// Copyright 2022-2023 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	varmortls "github.com/bytedance/vArmor/internal/tls"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+const certRotationCheckInterval = time.Minute
+
+var (
+	agentCert      *tls.Certificate
+	serverCAPool   *x509.CertPool
+	certMu         sync.RWMutex
+	certUpdateChan chan bool
+)
+
+// InitAndStartCertRotation requests the client certificate of the agent from the status service, and renews it
+// when two-thirds of its validity period have elapsed. The subsequent requests to the status service use the
+// latest certificate, so it's reloaded without restarting the agent.
+func InitAndStartCertRotation(nodeName string, debug bool, address string, port int, logger logr.Logger) {
+	certUpdateChan = make(chan bool, 1)
+	if err := updateCert(nodeName, debug, address, port); err != nil {
+		logger.Error(err, "failed to request the client certificate of agent, retry later")
+	}
+	go startCertRotation(nodeName, debug, address, port, logger, certUpdateChan)
+}
+
+func startCertRotation(nodeName string, debug bool, address string, port int, logger logr.Logger, update chan bool) {
+	ticker := time.NewTicker(certRotationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !certNeedsRenewal(time.Now()) {
+				continue
+			}
+		case <-update:
+		}
+
+		if err := updateCert(nodeName, debug, address, port); err != nil {
+			logger.Error(err, "failed to renew the client certificate of agent, retry later")
+		} else {
+			logger.Info("the client certificate of agent was renewed")
+		}
+	}
+}
+
+// certNeedsRenewal returns true if there is no client certificate, or two-thirds of its validity period have elapsed
+func certNeedsRenewal(now time.Time) bool {
+	certMu.RLock()
+	defer certMu.RUnlock()
+
+	if agentCert == nil || agentCert.Leaf == nil {
+		return true
+	}
+	lifetime := agentCert.Leaf.NotAfter.Sub(agentCert.Leaf.NotBefore)
+	return now.After(agentCert.Leaf.NotBefore.Add(lifetime * 2 / 3))
+}
+
+func updateCert(nodeName string, debug bool, address string, port int) error {
+	csr, key, err := varmortls.GenerateAgentCSR(nodeName)
+	if err != nil {
+		return err
+	}
+
+	reqBody, err := json.Marshal(varmortypes.AgentCertificateRequest{
+		NodeName: nodeName,
+		CSR:      csr,
+	})
+	if err != nil {
+		return err
+	}
+
+	rspBody, err := httpsPostAndGetResponseWithToken(reqBody, debug, varmorconfig.StatusServiceName, varmorconfig.Namespace, address, port, varmorconfig.CertificatePath, retryTimes)
+	if err != nil {
+		return err
+	}
+
+	var rsp varmortypes.AgentCertificateResponse
+	if err = json.Unmarshal(rspBody, &rsp); err != nil {
+		return err
+	}
+
+	return setCert(rsp.Certificate, varmortls.PrivateKeyToPem(key), rsp.ServerCA)
+}
+
+func setCert(certPEM []byte, keyPEM []byte, serverCA []byte) error {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(serverCA) {
+		return fmt.Errorf("failed to parse the CA of status service")
+	}
+
+	certMu.Lock()
+	agentCert = &cert
+	serverCAPool = pool
+	certMu.Unlock()
+	return nil
+}
+
+// resetCert discards the client certificate, so the agent bootstraps it again. It's used when the status
+// service can't be verified with the CA, e.g. the CA was renewed.
+func resetCert() {
+	certMu.Lock()
+	agentCert = nil
+	serverCAPool = nil
+	certMu.Unlock()
+}
+
+// requestCertUpdate triggers the renewal of the client certificate if the mutual TLS is enabled
+func requestCertUpdate() {
+	if certUpdateChan == nil {
+		return
+	}
+	select {
+	case certUpdateChan <- true:
+	default:
+	}
+}
+
+// isCertVerificationError returns true if the certificate of status service failed to be verified
+func isCertVerificationError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	return errors.As(err, &verificationErr)
+}
+
+// clientTLSConfig returns the TLS config with the latest client certificate of the agent. The certificate
+// of status service is not verified before the agent gets the CA from the status service.
+func clientTLSConfig(debug bool) *tls.Config {
+	certMu.RLock()
+	defer certMu.RUnlock()
+
+	if agentCert == nil {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{*agentCert},
+		RootCAs:      serverCAPool,
+	}
+	if !debug {
+		// The certificate of status service is issued for the in-cluster service name
+		config.ServerName = fmt.Sprintf("%s.%s.svc", varmorconfig.StatusServiceName, varmorconfig.Namespace)
+	}
+	return config
+}
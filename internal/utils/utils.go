@@ -17,12 +17,12 @@ package utils
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
@@ -51,7 +51,7 @@ func httpsPostWithRetryAndToken(reqBody []byte, debug bool, service string, name
 		url = fmt.Sprintf(httpsServerURL, service, namespace, port, path)
 	}
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig: clientTLSConfig(debug),
 	}
 	client := &http.Client{Timeout: httpTimeout, Transport: tr}
 	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
@@ -74,9 +74,16 @@ func httpsPostWithRetryAndToken(reqBody []byte, debug bool, service string, name
 					// try update token
 					updateChan <- true
 				}
+				// try update the client certificate
+				requestCertUpdate()
 			default:
 				err = fmt.Errorf(fmt.Sprintf("http error code %d", httpRsp.StatusCode))
 			}
+		} else if isCertVerificationError(err) {
+			// bootstrap the client certificate and the CA of status service again
+			resetCert()
+			requestCertUpdate()
+			return err
 		}
 		r := rand.Intn(60) + 20
 		time.Sleep(time.Duration(r) * time.Millisecond)
@@ -85,6 +92,57 @@ func httpsPostWithRetryAndToken(reqBody []byte, debug bool, service string, name
 	return err
 }
 
+func httpsPostAndGetResponseWithToken(reqBody []byte, debug bool, service string, namespace string, address string, port int, path string, retryTimes int) ([]byte, error) {
+	var url string
+	if debug {
+		url = fmt.Sprintf(httpsDebugURL, address, port, path)
+	} else {
+		url = fmt.Sprintf(httpsServerURL, service, namespace, port, path)
+	}
+	tr := &http.Transport{
+		TLSClientConfig: clientTLSConfig(debug),
+	}
+	client := &http.Client{Timeout: httpTimeout, Transport: tr}
+
+	var err error
+	for i := 0; i < retryTimes; i++ {
+		var httpReq *http.Request
+		httpReq, err = http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Token", GetToken())
+
+		var httpRsp *http.Response
+		httpRsp, err = client.Do(httpReq)
+		if err == nil {
+			var rspBody []byte
+			rspBody, err = io.ReadAll(httpRsp.Body)
+			httpRsp.Body.Close()
+			switch {
+			case err != nil:
+			case httpRsp.StatusCode == http.StatusOK:
+				return rspBody, nil
+			case httpRsp.StatusCode == http.StatusUnauthorized && !debug:
+				// try update token
+				updateChan <- true
+				err = fmt.Errorf("http error code %d", httpRsp.StatusCode)
+			default:
+				err = fmt.Errorf("http error code %d", httpRsp.StatusCode)
+			}
+		} else if isCertVerificationError(err) {
+			// fall back to the bootstrap
+			resetCert()
+			tr.TLSClientConfig = clientTLSConfig(debug)
+		}
+		r := rand.Intn(60) + 20
+		time.Sleep(time.Duration(r) * time.Millisecond)
+	}
+
+	return nil, err
+}
+
 func httpPostWithRetry(reqBody []byte, debug bool, service string, namespace string, address string, port int, path string, retryTimes int) error {
 	var url string
 	if debug {
@@ -165,6 +223,26 @@ func PostDataToStatusService(reqBody []byte, debug bool, address string, port in
 	return httpsPostWithRetryAndToken(reqBody, debug, varmorconfig.StatusServiceName, varmorconfig.Namespace, address, port, varmorconfig.DataSyncPath, retryTimes)
 }
 
+func PostViolationToStatusService(reqBody []byte, debug bool, address string, port int) error {
+	return httpsPostWithRetryAndToken(reqBody, debug, varmorconfig.StatusServiceName, varmorconfig.Namespace, address, port, varmorconfig.ViolationSyncPath, retryTimes)
+}
+
+func PostCoverageToStatusService(reqBody []byte, debug bool, address string, port int) error {
+	return httpsPostWithRetryAndToken(reqBody, debug, varmorconfig.StatusServiceName, varmorconfig.Namespace, address, port, varmorconfig.CoverageSyncPath, retryTimes)
+}
+
+func PostInventoryToStatusService(reqBody []byte, debug bool, address string, port int) error {
+	return httpsPostWithRetryAndToken(reqBody, debug, varmorconfig.StatusServiceName, varmorconfig.Namespace, address, port, varmorconfig.InventorySyncPath, retryTimes)
+}
+
+func PostTamperToStatusService(reqBody []byte, debug bool, address string, port int) error {
+	return httpsPostWithRetryAndToken(reqBody, debug, varmorconfig.StatusServiceName, varmorconfig.Namespace, address, port, varmorconfig.TamperSyncPath, retryTimes)
+}
+
+func PostSuspensionToStatusService(reqBody []byte, debug bool, address string, port int) error {
+	return httpsPostWithRetryAndToken(reqBody, debug, varmorconfig.StatusServiceName, varmorconfig.Namespace, address, port, varmorconfig.SuspensionSyncPath, retryTimes)
+}
+
 func TagLeaderPod(podInterface corev1.PodInterface) error {
 	jsonPatch := `[{"op": "add", "path": "/metadata/labels/identity", "value": "leader"}]`
 	_, err := podInterface.Patch(context.Background(), os.Getenv("HOSTNAME"), types.JSONPatchType, []byte(jsonPatch), metav1.PatchOptions{})
@@ -218,3 +296,39 @@ func InUint32Array(i uint32, array []uint32) bool {
 	}
 	return false
 }
+
+// MatchServiceAccount returns whether the service account of the pods is one of the service accounts.
+// The pods that don't specify the service account run as the "default" one. It always matches if the service
+// accounts are empty.
+func MatchServiceAccount(serviceAccount string, serviceAccounts []string) bool {
+	if len(serviceAccounts) == 0 {
+		return true
+	}
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	return InStringArray(serviceAccount, serviceAccounts)
+}
+
+// WrapLandlockCommand wraps the command of the container with the launcher of the Landlock enforcer. The command
+// that has been wrapped is rewrapped, so the profile of it is replaced.
+func WrapLandlockCommand(command []string, profileName string) []string {
+	launcher := filepath.Join(varmorconfig.LandlockContainerDir, varmorconfig.LandlockLauncherName)
+	profile := filepath.Join(varmorconfig.LandlockContainerDir, profileName)
+	return append([]string{launcher, "--profile", profile, "--"}, UnwrapLandlockCommand(command)...)
+}
+
+// UnwrapLandlockCommand returns the original command of the container which is wrapped by the launcher of the
+// Landlock enforcer.
+func UnwrapLandlockCommand(command []string) []string {
+	launcher := filepath.Join(varmorconfig.LandlockContainerDir, varmorconfig.LandlockLauncherName)
+	if len(command) == 0 || command[0] != launcher {
+		return command
+	}
+	for i, arg := range command {
+		if arg == "--" {
+			return command[i+1:]
+		}
+	}
+	return command
+}
@@ -44,6 +44,7 @@ import (
 type Register struct {
 	clientConfig         *rest.Config
 	mutateInterface      admissionv1.MutatingWebhookConfigurationInterface
+	validateInterface    admissionv1.ValidatingWebhookConfigurationInterface
 	secretInterface      corev1.SecretInterface
 	deploymentInterface  appsv1.DeploymentInterface
 	leaseInterface       coordinationv1.LeaseInterface
@@ -61,6 +62,7 @@ type Register struct {
 func NewRegister(
 	clientConfig *rest.Config,
 	mutateInterface admissionv1.MutatingWebhookConfigurationInterface,
+	validateInterface admissionv1.ValidatingWebhookConfigurationInterface,
 	secretInterface corev1.SecretInterface,
 	deploymentInterface appsv1.DeploymentInterface,
 	leaseInterface coordinationv1.LeaseInterface,
@@ -76,6 +78,7 @@ func NewRegister(
 	register := &Register{
 		clientConfig:         clientConfig,
 		mutateInterface:      mutateInterface,
+		validateInterface:    validateInterface,
 		secretInterface:      secretInterface,
 		deploymentInterface:  deploymentInterface,
 		leaseInterface:       leaseInterface,
@@ -101,10 +104,19 @@ func (wrc *Register) removeWebhookConfigurations() {
 		if !k8errors.IsNotFound(err) {
 			logger.Error(err, "failed to delete MutatingWebhookConfiguration", "name", configName)
 		}
-		return
+	} else {
+		logger.Info("MutatingWebhookConfiguration deleted")
 	}
 
-	logger.Info("MutatingWebhookConfiguration deleted")
+	configName = getPolicyValidatingWebhookConfigName(wrc.debug)
+	err = wrc.validateInterface.Delete(context.Background(), configName, metav1.DeleteOptions{})
+	if err != nil {
+		if !k8errors.IsNotFound(err) {
+			logger.Error(err, "failed to delete ValidatingWebhookConfiguration", "name", configName)
+		}
+	} else {
+		logger.Info("ValidatingWebhookConfiguration deleted")
+	}
 }
 
 func (wrc *Register) workloadResourceWebhookRule() admissionregistrationapi.Rule {
@@ -115,6 +127,14 @@ func (wrc *Register) workloadResourceWebhookRule() admissionregistrationapi.Rule
 	}
 }
 
+func (wrc *Register) batchWorkloadResourceWebhookRule() admissionregistrationapi.Rule {
+	return admissionregistrationapi.Rule{
+		Resources:   []string{"cronjobs", "jobs"},
+		APIGroups:   []string{"batch"},
+		APIVersions: []string{"v1"},
+	}
+}
+
 func (wrc *Register) podResourceWebhookRule() admissionregistrationapi.Rule {
 	return admissionregistrationapi.Rule{
 		Resources:   []string{"pods"},
@@ -123,6 +143,14 @@ func (wrc *Register) podResourceWebhookRule() admissionregistrationapi.Rule {
 	}
 }
 
+func (wrc *Register) policyResourceWebhookRule() admissionregistrationapi.Rule {
+	return admissionregistrationapi.Rule{
+		Resources:   []string{"varmorpolicies", "varmorclusterpolicies", "armorprofiles", "armorprofilemodels"},
+		APIGroups:   []string{"crd.varmor.org"},
+		APIVersions: []string{"v1beta1"},
+	}
+}
+
 func (wrc *Register) generateDefaultDebugMutatingWebhookConfig(caData []byte) *admissionregistrationapi.MutatingWebhookConfiguration {
 	logger := wrc.log
 	url := fmt.Sprintf("https://%s:%d%s", wrc.managerIP, config.WebhookServicePort, config.MutatingWebhookServicePath)
@@ -142,13 +170,22 @@ func (wrc *Register) generateDefaultDebugMutatingWebhookConfig(caData []byte) *a
 				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
 				admissionregistrationapi.Ignore,
 			),
+			generateDebugMutatingWebhook(
+				config.MutatingBatchWorkloadWebhookName,
+				url,
+				caData,
+				wrc.timeoutSeconds,
+				wrc.batchWorkloadResourceWebhookRule(),
+				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
+				admissionregistrationapi.Ignore,
+			),
 			generateDebugMutatingWebhook(
 				config.MutatingPodWebhookName,
 				url,
 				caData,
 				wrc.timeoutSeconds,
 				wrc.podResourceWebhookRule(),
-				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create},
+				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
 				admissionregistrationapi.Ignore,
 			),
 		},
@@ -170,13 +207,22 @@ func (wrc *Register) generateDefaultMutatingWebhookConfig(caData []byte) *admiss
 				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
 				admissionregistrationapi.Ignore,
 			),
+			generateMutatingWebhook(
+				config.MutatingBatchWorkloadWebhookName,
+				config.MutatingWebhookServicePath,
+				caData,
+				wrc.timeoutSeconds,
+				wrc.batchWorkloadResourceWebhookRule(),
+				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
+				admissionregistrationapi.Ignore,
+			),
 			generateMutatingWebhook(
 				config.MutatingPodWebhookName,
 				config.MutatingWebhookServicePath,
 				caData,
 				wrc.timeoutSeconds,
 				wrc.podResourceWebhookRule(),
-				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create},
+				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
 				admissionregistrationapi.Ignore,
 			),
 		},
@@ -207,6 +253,72 @@ func (wrc *Register) createResourceMutatingWebhookConfiguration(caData []byte) e
 	return nil
 }
 
+func (wrc *Register) generateDefaultDebugValidatingWebhookConfig(caData []byte) *admissionregistrationapi.ValidatingWebhookConfiguration {
+	logger := wrc.log
+	url := fmt.Sprintf("https://%s:%d%s", wrc.managerIP, config.WebhookServicePort, config.ValidatingWebhookServicePath)
+	logger.Info("Debug ValidatingWebhookConfiguration generated", "url", url)
+
+	return &admissionregistrationapi.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: config.ValidatingWebhookConfigurationDebugName,
+		},
+		Webhooks: []admissionregistrationapi.ValidatingWebhook{
+			generateDebugValidatingWebhook(
+				config.ValidatingPolicyWebhookName,
+				url,
+				caData,
+				wrc.timeoutSeconds,
+				wrc.policyResourceWebhookRule(),
+				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
+				admissionregistrationapi.Ignore,
+			),
+		},
+	}
+}
+
+func (wrc *Register) generateDefaultValidatingWebhookConfig(caData []byte) *admissionregistrationapi.ValidatingWebhookConfiguration {
+	return &admissionregistrationapi.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: config.ValidatingWebhookConfigurationName,
+		},
+		Webhooks: []admissionregistrationapi.ValidatingWebhook{
+			generateValidatingWebhook(
+				config.ValidatingPolicyWebhookName,
+				config.ValidatingWebhookServicePath,
+				caData,
+				wrc.timeoutSeconds,
+				wrc.policyResourceWebhookRule(),
+				[]admissionregistrationapi.OperationType{admissionregistrationapi.Create, admissionregistrationapi.Update},
+				admissionregistrationapi.Ignore,
+			),
+		},
+	}
+}
+
+func (wrc *Register) createPolicyValidatingWebhookConfiguration(caData []byte) error {
+	logger := wrc.log
+
+	var cfg *admissionregistrationapi.ValidatingWebhookConfiguration
+	if wrc.debug {
+		cfg = wrc.generateDefaultDebugValidatingWebhookConfig(caData)
+	} else {
+		cfg = wrc.generateDefaultValidatingWebhookConfig(caData)
+	}
+
+	_, err := wrc.validateInterface.Create(context.Background(), cfg, metav1.CreateOptions{})
+	if err != nil {
+		if k8errors.IsAlreadyExists(err) {
+			logger.Info("ValidatingWebhookConfiguration already exists", "name", cfg.Name)
+			return nil
+		}
+		logger.Error(err, "failed to create ValidatingWebhookConfiguration", "name", cfg.Name)
+		return err
+	}
+
+	logger.Info("ValidatingWebhookConfiguration created", "name", cfg.Name)
+	return nil
+}
+
 // Register clean up the old webhooks and re-creates admission webhooks configs on cluster
 func (wrc *Register) Register() error {
 	wrc.removeWebhookConfigurations()
@@ -226,6 +338,11 @@ func (wrc *Register) Register() error {
 		return err
 	}
 
+	err = wrc.createPolicyValidatingWebhookConfiguration(caData)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -31,6 +31,14 @@ func getResourceMutatingWebhookConfigName(debug bool) string {
 	return config.MutatingWebhookConfigurationName
 }
 
+// getPolicyValidatingWebhookConfigName returns the webhook configuration name.
+func getPolicyValidatingWebhookConfigName(debug bool) string {
+	if debug {
+		return config.ValidatingWebhookConfigurationDebugName
+	}
+	return config.ValidatingWebhookConfigurationName
+}
+
 // debug mutating webhook
 func generateDebugMutatingWebhook(
 	name,
@@ -119,3 +127,80 @@ func generateMutatingWebhook(
 	}
 	return w
 }
+
+// debug validating webhook
+func generateDebugValidatingWebhook(
+	name,
+	url string,
+	caData []byte,
+	timeoutSeconds int32,
+	rule admissionregistrationapi.Rule,
+	operationTypes []admissionregistrationapi.OperationType,
+	failurePolicy admissionregistrationapi.FailurePolicyType,
+) admissionregistrationapi.ValidatingWebhook {
+
+	sideEffect := admissionregistrationapi.SideEffectClassNone
+
+	w := admissionregistrationapi.ValidatingWebhook{
+		Name: name,
+		ClientConfig: admissionregistrationapi.WebhookClientConfig{
+			URL:      &url,
+			CABundle: caData,
+		},
+		SideEffects:             &sideEffect,
+		AdmissionReviewVersions: []string{"v1"},
+		TimeoutSeconds:          &timeoutSeconds,
+		FailurePolicy:           &failurePolicy,
+	}
+
+	if !reflect.DeepEqual(rule, admissionregistrationapi.Rule{}) {
+		w.Rules = []admissionregistrationapi.RuleWithOperations{
+			{
+				Operations: operationTypes,
+				Rule:       rule,
+			},
+		}
+	}
+
+	return w
+}
+
+// validating webhook
+func generateValidatingWebhook(
+	name,
+	servicePath string,
+	caData []byte,
+	timeoutSeconds int32,
+	rule admissionregistrationapi.Rule,
+	operationTypes []admissionregistrationapi.OperationType,
+	failurePolicy admissionregistrationapi.FailurePolicyType,
+) admissionregistrationapi.ValidatingWebhook {
+
+	sideEffect := admissionregistrationapi.SideEffectClassNone
+
+	w := admissionregistrationapi.ValidatingWebhook{
+		Name: name,
+		ClientConfig: admissionregistrationapi.WebhookClientConfig{
+			Service: &admissionregistrationapi.ServiceReference{
+				Namespace: config.Namespace,
+				Name:      config.WebhookServiceName,
+				Path:      &servicePath,
+			},
+			CABundle: caData,
+		},
+		SideEffects:             &sideEffect,
+		AdmissionReviewVersions: []string{"v1"},
+		TimeoutSeconds:          &timeoutSeconds,
+		FailurePolicy:           &failurePolicy,
+	}
+
+	if !reflect.DeepEqual(rule, admissionregistrationapi.Rule{}) {
+		w.Rules = []admissionregistrationapi.RuleWithOperations{
+			{
+				Operations: operationTypes,
+				Rule:       rule,
+			},
+		}
+	}
+	return w
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+)
+
+// podSpec returns the spec of the pod or the pod template of the workload
+func podSpec(obj interface{}) *corev1.PodSpec {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &o.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template.Spec
+	case *batchv1.Job:
+		return &o.Spec.Template.Spec
+	case *batchv1.CronJob:
+		return &o.Spec.JobTemplate.Spec.Template.Spec
+	case *corev1.Pod:
+		return &o.Spec
+	}
+	return nil
+}
+
+// privilegedConflicts returns the target containers that are privileged or share the host namespaces, several rules
+// are ineffective or misleading for them. Each of them is described as "<container>: privileged, hostPID".
+func privilegedConflicts(spec *corev1.PodSpec, target varmor.Target) []string {
+	if spec == nil {
+		return nil
+	}
+
+	var hostNamespaces []string
+	if spec.HostPID {
+		hostNamespaces = append(hostNamespaces, "hostPID")
+	}
+	if spec.HostIPC {
+		hostNamespaces = append(hostNamespaces, "hostIPC")
+	}
+	if spec.HostNetwork {
+		hostNamespaces = append(hostNamespaces, "hostNetwork")
+	}
+
+	var conflicts []string
+	for _, container := range spec.Containers {
+		if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
+			continue
+		}
+
+		var reasons []string
+		if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			reasons = append(reasons, "privileged")
+		}
+		reasons = append(reasons, hostNamespaces...)
+		if len(reasons) != 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%s: %s", container.Name, strings.Join(reasons, ", ")))
+		}
+	}
+	return conflicts
+}
+
+// landlockCommandConflicts returns the target containers of the Landlock enforcer that don't specify the command.
+// The launcher can't wrap the entrypoint of their images, which is unknown to the webhook, so they would run
+// without the protection. The containers opted out of the Landlock enforcer are skipped.
+func landlockCommandConflicts(obj interface{}, enforcer string, target varmor.Target) []string {
+	if (varmortypes.GetEnforcerType(enforcer) & varmortypes.Landlock) == 0 {
+		return nil
+	}
+
+	annotations, spec, _ := podTemplate(obj)
+	if spec == nil {
+		return nil
+	}
+
+	var conflicts []string
+	for _, container := range spec.Containers {
+		if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
+			continue
+		}
+		if annotations[fmt.Sprintf("container.landlock.security.beta.varmor.org/%s", container.Name)] == "unconfined" {
+			continue
+		}
+		if len(container.Command) == 0 {
+			conflicts = append(conflicts, container.Name)
+		}
+	}
+	return conflicts
+}
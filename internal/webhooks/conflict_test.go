@@ -0,0 +1,68 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_privilegedConflicts(t *testing.T) {
+	privileged := true
+	deploy := &appsv1.Deployment{}
+	deploy.Spec.Template.Spec = corev1.PodSpec{
+		HostNetwork: true,
+		Containers: []corev1.Container{
+			{Name: "c0", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}},
+			{Name: "c1"},
+		},
+	}
+
+	conflicts := privilegedConflicts(podSpec(deploy), varmor.Target{Kind: "Deployment"})
+	assert.DeepEqual(t, conflicts, []string{"c0: privileged, hostNetwork", "c1: hostNetwork"})
+
+	conflicts = privilegedConflicts(podSpec(deploy), varmor.Target{Kind: "Deployment", Containers: []string{"c0"}})
+	assert.DeepEqual(t, conflicts, []string{"c0: privileged, hostNetwork"})
+
+	deploy.Spec.Template.Spec.HostNetwork = false
+	conflicts = privilegedConflicts(podSpec(deploy), varmor.Target{Kind: "Deployment", Containers: []string{"c1"}})
+	assert.Assert(t, conflicts == nil)
+}
+
+func Test_landlockCommandConflicts(t *testing.T) {
+	deploy := &appsv1.Deployment{}
+	deploy.Spec.Template.Spec = corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "c0", Command: []string{"/bin/app"}},
+			{Name: "c1"},
+			{Name: "c2"},
+		},
+	}
+	deploy.Spec.Template.Annotations = map[string]string{"container.landlock.security.beta.varmor.org/c2": "unconfined"}
+
+	conflicts := landlockCommandConflicts(deploy, "LandlockSeccomp", varmor.Target{Kind: "Deployment"})
+	assert.DeepEqual(t, conflicts, []string{"c1"})
+
+	conflicts = landlockCommandConflicts(deploy, "Landlock", varmor.Target{Kind: "Deployment", Containers: []string{"c0"}})
+	assert.Assert(t, conflicts == nil)
+
+	conflicts = landlockCommandConflicts(deploy, "BPF", varmor.Target{Kind: "Deployment"})
+	assert.Assert(t, conflicts == nil)
+}
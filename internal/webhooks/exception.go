@@ -0,0 +1,147 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// builtInControllers maps the usernames of the built-in controllers to the workloads that they create the objects
+// for. The objects inherit the rule exceptions from the pod templates which were vetted when the workloads were
+// admitted, so they're exempted only if one of the workloads owns them.
+var builtInControllers = map[string]schema.GroupKind{
+	"system:serviceaccount:kube-system:replicaset-controller":  {Group: "apps", Kind: "Deployment"},
+	"system:serviceaccount:kube-system:job-controller":         {Group: "batch", Kind: "Job"},
+	"system:serviceaccount:kube-system:daemon-set-controller":  {Group: "apps", Kind: "DaemonSet"},
+	"system:serviceaccount:kube-system:statefulset-controller": {Group: "apps", Kind: "StatefulSet"},
+	"system:serviceaccount:kube-system:cronjob-controller":     {Group: "batch", Kind: "CronJob"},
+}
+
+// createdByBuiltInController reports whether the object is created by a built-in controller for its workload
+func createdByBuiltInController(request *admissionv1.AdmissionRequest, obj interface{}, owners func(metav1.Object) []owner) bool {
+	workload, ok := builtInControllers[request.UserInfo.Username]
+	if !ok {
+		return false
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	for _, o := range owners(m) {
+		if o.gvk.GroupKind() == workload {
+			return true
+		}
+	}
+	return false
+}
+
+// podAnnotations returns the annotations of the pod or the pod template of the workload
+func podAnnotations(obj interface{}) map[string]string {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return o.Spec.Template.Annotations
+	case *appsv1.StatefulSet:
+		return o.Spec.Template.Annotations
+	case *appsv1.DaemonSet:
+		return o.Spec.Template.Annotations
+	case *batchv1.Job:
+		return o.Spec.Template.Annotations
+	case *batchv1.CronJob:
+		return o.Spec.JobTemplate.Spec.Template.Annotations
+	case *corev1.Pod:
+		return o.Annotations
+	}
+	return nil
+}
+
+// validateRuleExceptions checks the rule exceptions of the pod or the pod template of the workload. The excepted
+// rules must be in the allow-list, the policy must use the BPF enforcer, and the requester must be allowed to
+// create the exceptions subresource of the VarmorPolicy objects in the namespace, unless the object is created by
+// a built-in controller for its workload.
+func (ws *WebhookServer) validateRuleExceptions(request *admissionv1.AdmissionRequest, obj interface{}, enforcer string, owners func(metav1.Object) []owner) error {
+	value, ok := podAnnotations(obj)[varmortypes.RuleExceptionsAnnotation]
+	if !ok {
+		return nil
+	}
+
+	if !strings.Contains(enforcer, "BPF") && !strings.EqualFold(enforcer, "BestAvailable") {
+		return fmt.Errorf("the rule exceptions only work with the BPF enforcer")
+	}
+
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if !ws.ruleExceptionAllowList[rule] {
+			return fmt.Errorf("the rule '%s' isn't allowed to be excepted", rule)
+		}
+	}
+
+	if createdByBuiltInController(request, obj, owners) {
+		return nil
+	}
+
+	allowed, err := ws.authorize(request, "exceptions")
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("the user '%s' isn't allowed to create the rule exceptions in the namespace", request.UserInfo.Username)
+	}
+	return nil
+}
+
+// authorize reports whether the requester is allowed to create the subresource of the VarmorPolicy objects in the
+// namespace of the admission request
+func (ws *WebhookServer) authorize(request *admissionv1.AdmissionRequest, subresource string) (bool, error) {
+	extra := make(map[string]authzv1.ExtraValue, len(request.UserInfo.Extra))
+	for k, v := range request.UserInfo.Extra {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	sar := &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			User:   request.UserInfo.Username,
+			Groups: request.UserInfo.Groups,
+			UID:    request.UserInfo.UID,
+			Extra:  extra,
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Namespace:   request.Namespace,
+				Group:       "crd.varmor.org",
+				Resource:    "varmorpolicies",
+				Subresource: subresource,
+				Verb:        "create",
+			},
+		},
+	}
+	review, err := ws.authzInterface.SubjectAccessReviews().Create(context.Background(), sar, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to authorize the requester: %w", err)
+	}
+	return review.Status.Allowed, nil
+}
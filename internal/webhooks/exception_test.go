@@ -0,0 +1,130 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_validateRuleExceptions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		sar.Status.Allowed = sar.Spec.User == "admin"
+		return true, sar, nil
+	})
+
+	ws := &WebhookServer{
+		authzInterface:         client.AuthorizationV1(),
+		ruleExceptionAllowList: map[string]bool{"disable-shell": true, "disallow-mount": true},
+	}
+
+	testCases := []struct {
+		name        string
+		user        string
+		enforcer    string
+		exceptions  string
+		owners      []owner
+		expectedErr bool
+	}{
+		{
+			name:       "allowed",
+			user:       "admin",
+			enforcer:   "AppArmorBPF",
+			exceptions: "disable-shell,disallow-mount",
+		},
+		{
+			name:        "ruleNotInAllowList",
+			user:        "admin",
+			enforcer:    "BPF",
+			exceptions:  "disable-shell,disallow-umount",
+			expectedErr: true,
+		},
+		{
+			name:        "requesterNotAllowed",
+			user:        "developer",
+			enforcer:    "BPF",
+			exceptions:  "disable-shell",
+			expectedErr: true,
+		},
+		{
+			name:       "builtInController",
+			user:       "system:serviceaccount:kube-system:replicaset-controller",
+			enforcer:   "BPF",
+			exceptions: "disable-shell",
+			owners: []owner{
+				{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}},
+				{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+			},
+		},
+		{
+			name:        "builtInControllerWithoutWorkload",
+			user:        "system:serviceaccount:kube-system:replicaset-controller",
+			enforcer:    "BPF",
+			exceptions:  "disable-shell",
+			owners:      []owner{{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "otherKubeSystemServiceAccount",
+			user:        "system:serviceaccount:kube-system:default",
+			enforcer:    "BPF",
+			exceptions:  "disable-shell",
+			owners:      []owner{{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "enforcerWithoutBPF",
+			user:        "admin",
+			enforcer:    "AppArmor",
+			exceptions:  "disable-shell",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{varmortypes.RuleExceptionsAnnotation: tc.exceptions},
+				},
+			}
+			request := &admissionv1.AdmissionRequest{
+				Namespace: "test",
+				UserInfo:  authnv1.UserInfo{Username: tc.user},
+			}
+			owners := func(metav1.Object) []owner { return tc.owners }
+			err := ws.validateRuleExceptions(request, pod, tc.enforcer, owners)
+			assert.Equal(t, err != nil, tc.expectedErr, err)
+		})
+	}
+
+	// The resources without the annotation are always allowed
+	err := ws.validateRuleExceptions(&admissionv1.AdmissionRequest{}, &corev1.Pod{}, "AppArmor", nil)
+	assert.NilError(t, err)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// podTemplate returns the metadata and spec of the pod or the pod template of the workload, and the JSON patch
+// path of it
+func podTemplate(obj interface{}) (map[string]string, *corev1.PodSpec, string) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return o.Spec.Template.Annotations, &o.Spec.Template.Spec, "/spec/template"
+	case *appsv1.StatefulSet:
+		return o.Spec.Template.Annotations, &o.Spec.Template.Spec, "/spec/template"
+	case *appsv1.DaemonSet:
+		return o.Spec.Template.Annotations, &o.Spec.Template.Spec, "/spec/template"
+	case *batchv1.Job:
+		return o.Spec.Template.Annotations, &o.Spec.Template.Spec, "/spec/template"
+	case *batchv1.CronJob:
+		return o.Spec.JobTemplate.Spec.Template.Annotations, &o.Spec.JobTemplate.Spec.Template.Spec, "/spec/jobTemplate/spec/template"
+	case *corev1.Pod:
+		return o.Annotations, &o.Spec, ""
+	}
+	return nil, nil, ""
+}
+
+// buildBaseProfilePatch binds the target containers to the base profile which is layered under their BPF profiles
+// by the agent. The containers opted out of the BPF enforcer are skipped.
+func buildBaseProfilePatch(obj interface{}, target varmor.Target, baseProfile string) string {
+	var jsonPatch string
+
+	annotations, spec, path := podTemplate(obj)
+	if spec == nil {
+		return ""
+	}
+
+	for _, container := range spec.Containers {
+		if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
+			continue
+		}
+		if annotations[fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)] == "unconfined" {
+			continue
+		}
+		key := strings.ReplaceAll(pkgtypes.BaseBpfAnnotationPrefix+container.Name, "/", "~1")
+		jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/%s", "value": "localhost/%s"},`, path, key, baseProfile)
+	}
+
+	return jsonPatch
+}
+
+// appendPatch appends the operations to the JSON patch built by buildPatch
+func appendPatch(patch string, operations string) string {
+	operations = strings.TrimSuffix(operations, ",")
+	if patch == "" || operations == "" {
+		return patch
+	}
+	return fmt.Sprintf("%s,%s]", strings.TrimSuffix(patch, "]"), operations)
+}
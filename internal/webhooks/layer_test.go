@@ -0,0 +1,66 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_buildBaseProfilePatch(t *testing.T) {
+	deploy := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"container.bpf.security.beta.varmor.org/sidecar": "unconfined"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}, {Name: "debug"}},
+				},
+			},
+		},
+	}
+	target := varmor.Target{Kind: "Deployment", Containers: []string{"app", "sidecar"}}
+
+	patch := buildBaseProfilePatch(deploy, target, "varmor-cluster-varmor-base")
+	assert.Equal(t, patch, `{"op": "replace", "path": "/spec/template/metadata/annotations/base.bpf.security.beta.varmor.org~1app", "value": "localhost/varmor-cluster-varmor-base"},`)
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}}
+	patch = buildBaseProfilePatch(pod, varmor.Target{Kind: "Pod"}, "varmor-cluster-varmor-base")
+	assert.Equal(t, patch, `{"op": "replace", "path": "/metadata/annotations/base.bpf.security.beta.varmor.org~1app", "value": "localhost/varmor-cluster-varmor-base"},`)
+
+	assert.Equal(t, appendPatch(`[{"op": "add"}]`, patch), `[{"op": "add"},{"op": "replace", "path": "/metadata/annotations/base.bpf.security.beta.varmor.org~1app", "value": "localhost/varmor-cluster-varmor-base"}]`)
+	assert.Equal(t, appendPatch("", patch), "")
+	assert.Equal(t, appendPatch(`[{"op": "add"}]`, ""), `[{"op": "add"}]`)
+}
+
+func Test_layerable(t *testing.T) {
+	cluster := &policyMatch{enforcer: "BPF", target: varmor.Target{Containers: []string{"app", "web"}}}
+	namespaced := &policyMatch{enforcer: "BPF", target: varmor.Target{Containers: []string{"web", "app"}}}
+	assert.Assert(t, layerable(cluster, namespaced))
+
+	namespaced.target.Containers = []string{"app"}
+	assert.Assert(t, !layerable(cluster, namespaced))
+
+	namespaced.target.Containers = []string{"web", "app"}
+	namespaced.enforcer = "AppArmorBPF"
+	assert.Assert(t, !layerable(cluster, namespaced))
+}
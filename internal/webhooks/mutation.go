@@ -24,13 +24,17 @@ import (
 	"github.com/go-logr/logr"
 	admissionv1 "k8s.io/api/admission/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorconfig "github.com/bytedance/vArmor/internal/config"
+	selinuxprofile "github.com/bytedance/vArmor/internal/profile/selinux"
 	varmortypes "github.com/bytedance/vArmor/internal/types"
 	varmorutils "github.com/bytedance/vArmor/internal/utils"
+	pkgtypes "github.com/bytedance/vArmor/pkg/types"
 )
 
 // bodyToAdmissionReview creates AdmissionReview object from request body.
@@ -119,61 +123,182 @@ func failureResponse(uid types.UID, message string) *admissionv1.AdmissionRespon
 	}
 }
 
-func buildPatch(obj interface{}, enforcer string, target varmor.Target, profileName string, bpfExclusiveMode bool) (patch string, err error) {
+// buildLandlockPatch builds the patch operations which mount the LandlockProfileDir into the container and wrap
+// its command with the launcher. The container without the command is skipped, since the entrypoint of its image
+// is unknown to the webhook. Such containers have been rejected by landlockCommandConflicts before patching.
+func buildLandlockPatch(spec *corev1.PodSpec, path string, index int, profileName string, volumeAdded *bool) string {
 	var jsonPatch string
+	container := spec.Containers[index]
 
-	switch target.Kind {
-	case "Deployment":
-		deploy := obj.(*appsv1.Deployment)
+	if len(container.Command) == 0 {
+		return ""
+	}
 
-		if deploy.Annotations == nil {
-			jsonPatch += `{"op": "add", "path": "/metadata/annotations", "value": {}},`
+	for _, volume := range spec.Volumes {
+		if volume.Name == varmorconfig.LandlockVolumeName {
+			*volumeAdded = true
+		}
+	}
+	if !*volumeAdded {
+		if spec.Volumes == nil {
+			jsonPatch += fmt.Sprintf(`{"op": "add", "path": "%s/spec/volumes", "value": []},`, path)
 		}
+		jsonPatch += fmt.Sprintf(`{"op": "add", "path": "%s/spec/volumes/-", "value": {"name": "%s", "hostPath": {"path": "%s", "type": "Directory"}}},`,
+			path, varmorconfig.LandlockVolumeName, varmorconfig.LandlockProfileDir)
+		*volumeAdded = true
+	}
 
-		if deploy.Spec.Template.Annotations == nil {
-			jsonPatch += `{"op": "add", "path": "/spec/template/metadata/annotations", "value": {}},`
+	mounted := false
+	for _, volumeMount := range container.VolumeMounts {
+		if volumeMount.Name == varmorconfig.LandlockVolumeName {
+			mounted = true
 		}
+	}
+	if !mounted {
+		if container.VolumeMounts == nil {
+			jsonPatch += fmt.Sprintf(`{"op": "add", "path": "%s/spec/containers/%d/volumeMounts", "value": []},`, path, index)
+		}
+		jsonPatch += fmt.Sprintf(`{"op": "add", "path": "%s/spec/containers/%d/volumeMounts/-", "value": {"name": "%s", "mountPath": "%s", "readOnly": true}},`,
+			path, index, varmorconfig.LandlockVolumeName, varmorconfig.LandlockContainerDir)
+	}
 
-		for index, container := range deploy.Spec.Template.Spec.Containers {
-			if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
-				continue
-			}
+	command, _ := json.Marshal(varmorutils.WrapLandlockCommand(container.Command, profileName))
+	jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/spec/containers/%d/command", "value": %s},`, path, index, command)
+	jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/container.landlock.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, path, container.Name, profileName)
 
-			e := varmortypes.GetEnforcerType(enforcer)
+	return jsonPatch
+}
 
-			// BPF
-			if (e & varmortypes.BPF) != 0 {
-				key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
-				if value, ok := deploy.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.bpf.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-				if bpfExclusiveMode {
-					jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "unconfined"},`, container.Name)
-				}
+// buildSELinuxPatch builds the patch operations which run the container in the SELinux type of the profile. The
+// privileged container and the container with the SELinux options are skipped. The SecurityContext of the container
+// is initialized if it's added by the patch, so it won't be added again by the Seccomp enforcer.
+func buildSELinuxPatch(container *corev1.Container, podSecurityContext *corev1.PodSecurityContext, path string, index int, profileName string) string {
+	var jsonPatch string
+
+	if (container.SecurityContext != nil && container.SecurityContext.SELinuxOptions != nil) ||
+		(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
+		(podSecurityContext != nil && podSecurityContext.SELinuxOptions != nil) {
+		return ""
+	}
+
+	jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/container.selinux.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, path, container.Name, profileName)
+	if container.SecurityContext == nil {
+		jsonPatch += fmt.Sprintf(`{"op": "add", "path": "%s/spec/containers/%d/securityContext", "value": {}},`, path, index)
+		container.SecurityContext = &corev1.SecurityContext{}
+	}
+	jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/spec/containers/%d/securityContext/seLinuxOptions", "value": {"type": "%s"}},`, path, index, selinuxprofile.ProcessType(profileName))
+
+	return jsonPatch
+}
+
+// buildSandboxPatch builds the patch operation which confines the sandbox container of the pod with the built-in
+// sandbox profile. It's only supported by the BPF enforcer, and the sandbox container opted out is skipped.
+func buildSandboxPatch(annotations map[string]string, path string, enforcer string) string {
+	if (varmortypes.GetEnforcerType(enforcer)&varmortypes.BPF) == 0 || annotations[pkgtypes.SandboxBpfAnnotation] == "unconfined" {
+		return ""
+	}
+	return fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/%s", "value": "localhost/%s"},`, path, pkgtypes.SandboxBpfAnnotation, pkgtypes.SandboxProfileName)
+}
+
+// buildPodTemplatePatch builds the patch operations of the pod template which is located at the path of the workload
+func buildPodTemplatePatch(template *corev1.PodTemplateSpec, path string, enforcer string, target varmor.Target, profileName string, bpfExclusiveMode bool, confineSandbox bool) string {
+	var jsonPatch string
+
+	if template.Annotations == nil {
+		jsonPatch += fmt.Sprintf(`{"op": "add", "path": "%s/metadata/annotations", "value": {}},`, path)
+	}
+
+	landlockVolumeAdded := false
+	for index, container := range template.Spec.Containers {
+		if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
+			continue
+		}
+
+		e := varmortypes.GetEnforcerType(enforcer)
+
+		// Each enforcer is skipped independently, so the container opted out of one enforcer is still
+		// protected by the others.
+
+		// BPF
+		bpfKey := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
+		bpfUnconfined := template.Annotations[bpfKey] == "unconfined"
+		if (e&varmortypes.BPF) != 0 && !bpfUnconfined {
+			jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/container.bpf.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, path, container.Name, profileName)
+			if bpfExclusiveMode {
+				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "unconfined"},`, path, container.Name)
 			}
-			// AppArmor
-			if (e & varmortypes.AppArmor) != 0 {
-				key := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-				if value, ok := deploy.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "localhost/%s"},`, container.Name, profileName)
+		}
+		// AppArmor
+		appArmorKey := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
+		appArmorUnconfined := template.Annotations[appArmorKey] == "unconfined"
+		if (e&varmortypes.AppArmor) != 0 && !appArmorUnconfined {
+			jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "localhost/%s"},`, path, container.Name, profileName)
+		}
+		// Landlock
+		landlockKey := fmt.Sprintf("container.landlock.security.beta.varmor.org/%s", container.Name)
+		landlockUnconfined := template.Annotations[landlockKey] == "unconfined"
+		if (e&varmortypes.Landlock) != 0 && !landlockUnconfined {
+			jsonPatch += buildLandlockPatch(&template.Spec, path, index, profileName, &landlockVolumeAdded)
+		}
+		// SELinux
+		selinuxKey := fmt.Sprintf("container.selinux.security.beta.varmor.org/%s", container.Name)
+		selinuxUnconfined := template.Annotations[selinuxKey] == "unconfined"
+		if (e&varmortypes.SELinux) != 0 && !selinuxUnconfined {
+			jsonPatch += buildSELinuxPatch(&container, template.Spec.SecurityContext, path, index, profileName)
+		}
+		// Seccomp
+		if (e & varmortypes.Seccomp) != 0 {
+			if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
+				(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
+				(template.Spec.SecurityContext != nil && template.Spec.SecurityContext.SeccompProfile != nil) {
+				continue
 			}
-			// Seccomp
-			if (e & varmortypes.Seccomp) != 0 {
-				if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
-					(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
-					(deploy.Spec.Template.Spec.SecurityContext != nil && deploy.Spec.Template.Spec.SecurityContext.SeccompProfile != nil) {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.seccomp.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-				if container.SecurityContext == nil {
-					jsonPatch += fmt.Sprintf(`{"op": "add", "path": "/spec/template/spec/containers/%d/securityContext", "value": {}},`, index)
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/spec/containers/%d/securityContext/seccompProfile", "value": {"type": "Localhost", "localhostProfile": "%s"}},`, index, profileName)
+			jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/metadata/annotations/container.seccomp.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, path, container.Name, profileName)
+			if container.SecurityContext == nil {
+				jsonPatch += fmt.Sprintf(`{"op": "add", "path": "%s/spec/containers/%d/securityContext", "value": {}},`, path, index)
 			}
+			jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "%s/spec/containers/%d/securityContext/seccompProfile", "value": {"type": "Localhost", "localhostProfile": "%s"}},`, path, index, profileName)
+		}
+	}
+
+	if confineSandbox {
+		jsonPatch += buildSandboxPatch(template.Annotations, path, enforcer)
+	}
+
+	return jsonPatch
+}
+
+// podServiceAccount returns the service account of the pods of the workload
+func podServiceAccount(obj interface{}) string {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return o.Spec.Template.Spec.ServiceAccountName
+	case *appsv1.StatefulSet:
+		return o.Spec.Template.Spec.ServiceAccountName
+	case *appsv1.DaemonSet:
+		return o.Spec.Template.Spec.ServiceAccountName
+	case *batchv1.Job:
+		return o.Spec.Template.Spec.ServiceAccountName
+	case *batchv1.CronJob:
+		return o.Spec.JobTemplate.Spec.Template.Spec.ServiceAccountName
+	case *corev1.Pod:
+		return o.Spec.ServiceAccountName
+	}
+	return ""
+}
+
+func buildPatch(obj interface{}, enforcer string, target varmor.Target, profileName string, bpfExclusiveMode bool, confineSandbox bool) (patch string, err error) {
+	var jsonPatch string
+
+	switch target.Kind {
+	case "Deployment":
+		deploy := obj.(*appsv1.Deployment)
+
+		if deploy.Annotations == nil {
+			jsonPatch += `{"op": "add", "path": "/metadata/annotations", "value": {}},`
 		}
+
+		jsonPatch += buildPodTemplatePatch(&deploy.Spec.Template, "/spec/template", enforcer, target, profileName, bpfExclusiveMode, confineSandbox)
 	case "StatefulSet":
 		statefulSet := obj.(*appsv1.StatefulSet)
 
@@ -181,50 +306,7 @@ func buildPatch(obj interface{}, enforcer string, target varmor.Target, profileN
 			jsonPatch += `{"op": "add", "path": "/metadata/annotations", "value": {}},`
 		}
 
-		if statefulSet.Spec.Template.Annotations == nil {
-			jsonPatch += `{"op": "add", "path": "/spec/template/metadata/annotations", "value": {}},`
-		}
-
-		for index, container := range statefulSet.Spec.Template.Spec.Containers {
-			if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
-				continue
-			}
-
-			e := varmortypes.GetEnforcerType(enforcer)
-
-			// BPF
-			if (e & varmortypes.BPF) != 0 {
-				key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
-				if value, ok := statefulSet.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.bpf.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-				if bpfExclusiveMode {
-					jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "unconfined"},`, container.Name)
-				}
-			}
-			// AppArmor
-			if (e & varmortypes.AppArmor) != 0 {
-				key := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-				if value, ok := statefulSet.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-			}
-			// Seccomp
-			if (e & varmortypes.Seccomp) != 0 {
-				if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
-					(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
-					(statefulSet.Spec.Template.Spec.SecurityContext != nil && statefulSet.Spec.Template.Spec.SecurityContext.SeccompProfile != nil) {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.seccomp.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-				if container.SecurityContext == nil {
-					jsonPatch += fmt.Sprintf(`{"op": "add", "path": "/spec/template/spec/containers/%d/securityContext", "value": {}},`, index)
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/spec/containers/%d/securityContext/seccompProfile", "value": {"type": "Localhost", "localhostProfile": "%s"}},`, index, profileName)
-			}
-		}
+		jsonPatch += buildPodTemplatePatch(&statefulSet.Spec.Template, "/spec/template", enforcer, target, profileName, bpfExclusiveMode, confineSandbox)
 	case "DaemonSet":
 		daemonSet := obj.(*appsv1.DaemonSet)
 
@@ -232,50 +314,24 @@ func buildPatch(obj interface{}, enforcer string, target varmor.Target, profileN
 			jsonPatch += `{"op": "add", "path": "/metadata/annotations", "value": {}},`
 		}
 
-		if daemonSet.Spec.Template.Annotations == nil {
-			jsonPatch += `{"op": "add", "path": "/spec/template/metadata/annotations", "value": {}},`
-		}
+		jsonPatch += buildPodTemplatePatch(&daemonSet.Spec.Template, "/spec/template", enforcer, target, profileName, bpfExclusiveMode, confineSandbox)
+	case "Job":
+		job := obj.(*batchv1.Job)
 
-		for index, container := range daemonSet.Spec.Template.Spec.Containers {
-			if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
-				continue
-			}
+		if job.Annotations == nil {
+			jsonPatch += `{"op": "add", "path": "/metadata/annotations", "value": {}},`
+		}
 
-			e := varmortypes.GetEnforcerType(enforcer)
+		jsonPatch += buildPodTemplatePatch(&job.Spec.Template, "/spec/template", enforcer, target, profileName, bpfExclusiveMode, confineSandbox)
+	case "CronJob":
+		cronJob := obj.(*batchv1.CronJob)
 
-			// BPF
-			if (e & varmortypes.BPF) != 0 {
-				key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
-				if value, ok := daemonSet.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.bpf.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-				if bpfExclusiveMode {
-					jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "unconfined"},`, container.Name)
-				}
-			}
-			// AppArmor
-			if (e & varmortypes.AppArmor) != 0 {
-				key := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-				if value, ok := daemonSet.Spec.Template.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-			}
-			// Seccomp
-			if (e & varmortypes.Seccomp) != 0 {
-				if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
-					(container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged) ||
-					(daemonSet.Spec.Template.Spec.SecurityContext != nil && daemonSet.Spec.Template.Spec.SecurityContext.SeccompProfile != nil) {
-					continue
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/metadata/annotations/container.seccomp.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, container.Name, profileName)
-				if container.SecurityContext == nil {
-					jsonPatch += fmt.Sprintf(`{"op": "add", "path": "/spec/template/spec/containers/%d/securityContext", "value": {}},`, index)
-				}
-				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/template/spec/containers/%d/securityContext/seccompProfile", "value": {"type": "Localhost", "localhostProfile": "%s"}},`, index, profileName)
-			}
+		if cronJob.Annotations == nil {
+			jsonPatch += `{"op": "add", "path": "/metadata/annotations", "value": {}},`
 		}
+
+		// The Jobs created by the CronJob inherit the pod template, so the protection takes effect on the next run.
+		jsonPatch += buildPodTemplatePatch(&cronJob.Spec.JobTemplate.Spec.Template, "/spec/jobTemplate/spec/template", enforcer, target, profileName, bpfExclusiveMode, confineSandbox)
 	case "Pod":
 		pod := obj.(*corev1.Pod)
 
@@ -283,6 +339,7 @@ func buildPatch(obj interface{}, enforcer string, target varmor.Target, profileN
 			jsonPatch += `{"op": "add", "path": "/metadata/annotations", "value": {}},`
 		}
 
+		landlockVolumeAdded := false
 		for index, container := range pod.Spec.Containers {
 			if len(target.Containers) != 0 && !varmorutils.InStringArray(container.Name, target.Containers) {
 				continue
@@ -291,24 +348,32 @@ func buildPatch(obj interface{}, enforcer string, target varmor.Target, profileN
 			e := varmortypes.GetEnforcerType(enforcer)
 
 			// BPF
-			if (e & varmortypes.BPF) != 0 {
-				key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
-				if value, ok := pod.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
+			bpfKey := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", container.Name)
+			bpfUnconfined := pod.Annotations[bpfKey] == "unconfined"
+			if (e&varmortypes.BPF) != 0 && !bpfUnconfined {
 				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/metadata/annotations/container.bpf.security.beta.varmor.org~1%s", "value": "localhost/%s"},`, container.Name, profileName)
 				if bpfExclusiveMode {
 					jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "unconfined"},`, container.Name)
 				}
 			}
 			// AppArmor
-			if (e & varmortypes.AppArmor) != 0 {
-				key := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
-				if value, ok := pod.Annotations[key]; ok && value == "unconfined" {
-					continue
-				}
+			appArmorKey := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", container.Name)
+			appArmorUnconfined := pod.Annotations[appArmorKey] == "unconfined"
+			if (e&varmortypes.AppArmor) != 0 && !appArmorUnconfined {
 				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1%s", "value": "localhost/%s"},`, container.Name, profileName)
 			}
+			// Landlock
+			landlockKey := fmt.Sprintf("container.landlock.security.beta.varmor.org/%s", container.Name)
+			landlockUnconfined := pod.Annotations[landlockKey] == "unconfined"
+			if (e&varmortypes.Landlock) != 0 && !landlockUnconfined {
+				jsonPatch += buildLandlockPatch(&pod.Spec, "", index, profileName, &landlockVolumeAdded)
+			}
+			// SELinux
+			selinuxKey := fmt.Sprintf("container.selinux.security.beta.varmor.org/%s", container.Name)
+			selinuxUnconfined := pod.Annotations[selinuxKey] == "unconfined"
+			if (e&varmortypes.SELinux) != 0 && !selinuxUnconfined {
+				jsonPatch += buildSELinuxPatch(&container, pod.Spec.SecurityContext, "", index, profileName)
+			}
 			// Seccomp
 			if (e & varmortypes.Seccomp) != 0 {
 				if (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil) ||
@@ -323,6 +388,10 @@ func buildPatch(obj interface{}, enforcer string, target varmor.Target, profileN
 				jsonPatch += fmt.Sprintf(`{"op": "replace", "path": "/spec/containers/%d/securityContext/seccompProfile", "value": {"type": "Localhost", "localhostProfile": "%s"}},`, index, profileName)
 			}
 		}
+
+		if confineSandbox {
+			jsonPatch += buildSandboxPatch(pod.Annotations, "", enforcer)
+		}
 	}
 
 	if len(jsonPatch) > 0 {
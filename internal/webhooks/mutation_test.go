@@ -21,6 +21,7 @@ import (
 	yaml "gopkg.in/yaml.v3"
 	"gotest.tools/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 
@@ -524,6 +525,110 @@ func Test_buildPatch(t *testing.T) {
               command: ["/bin/sh", "-c", "sleep infinity", "1"]
               securityContext:
                 runAsUser: 1001
+      `),
+		},
+		{
+			name:             "patchCronJobBPF",
+			kind:             "CronJob",
+			enforcer:         "BPF",
+			bpfExclusiveMode: true,
+			expectedResult:   `[{"op": "add", "path": "/spec/jobTemplate/spec/template/metadata/annotations", "value": {}},{"op": "replace", "path": "/spec/jobTemplate/spec/template/metadata/annotations/container.bpf.security.beta.varmor.org~1test", "value": "localhost/varmor-testns-test"},{"op": "replace", "path": "/spec/jobTemplate/spec/template/metadata/annotations/container.apparmor.security.beta.kubernetes.io~1test", "value": "unconfined"},{"op": "replace", "path": "/metadata/annotations/webhook.varmor.org~1mutatedAt", "value": "TIME_STRING"}]`,
+			rawTarget: []byte(`
+    kind: CronJob
+    selector:
+      matchLabels:
+        app: backup`),
+			rawResource: []byte(`
+    apiVersion: batch/v1
+    kind: CronJob
+    metadata:
+      name: backup
+      namespace: test
+      labels:
+        app: backup
+      annotations:
+        a: v
+    spec:
+      schedule: "*/5 * * * *"
+      jobTemplate:
+        spec:
+          template:
+            spec:
+              restartPolicy: OnFailure
+              containers:
+              - name: test
+                image: debian:10
+                command: ["/bin/sh", "-c", "date"]`),
+		},
+		{
+			name:             "patchPodAppArmorUnconfinedSeccompConfined",
+			kind:             "Pod",
+			enforcer:         "AppArmorSeccomp",
+			bpfExclusiveMode: false,
+			expectedResult:   `[{"op": "replace", "path": "/metadata/annotations/container.seccomp.security.beta.varmor.org~1test", "value": "localhost/varmor-testns-test"},{"op": "add", "path": "/spec/containers/0/securityContext", "value": {}},{"op": "replace", "path": "/spec/containers/0/securityContext/seccompProfile", "value": {"type": "Localhost", "localhostProfile": "varmor-testns-test"}},{"op": "replace", "path": "/metadata/annotations/webhook.varmor.org~1mutatedAt", "value": "TIME_STRING"}]`,
+			rawTarget: []byte(`
+    kind: Pod
+    name: 4.1-test`),
+			rawResource: []byte(`
+      apiVersion: v1
+      kind: Pod
+      metadata:
+        name: 4.1-test
+        namespace: test
+        annotations:
+          container.apparmor.security.beta.kubernetes.io/test: unconfined
+      spec:
+        containers:
+        - name: test
+          image: debian:10
+          command: ["/bin/sh", "-c", "sleep infinity", "1"]
+      `),
+		},
+		{
+			name:             "patchPodLandlockConfined",
+			kind:             "Pod",
+			enforcer:         "Landlock",
+			bpfExclusiveMode: false,
+			expectedResult:   `[{"op": "add", "path": "/metadata/annotations", "value": {}},{"op": "add", "path": "/spec/volumes", "value": []},{"op": "add", "path": "/spec/volumes/-", "value": {"name": "varmor-landlock", "hostPath": {"path": "/var/lib/varmor/landlock", "type": "Directory"}}},{"op": "add", "path": "/spec/containers/0/volumeMounts", "value": []},{"op": "add", "path": "/spec/containers/0/volumeMounts/-", "value": {"name": "varmor-landlock", "mountPath": "/var/run/varmor/landlock", "readOnly": true}},{"op": "replace", "path": "/spec/containers/0/command", "value": ["/var/run/varmor/landlock/varmor-landlock","--profile","/var/run/varmor/landlock/varmor-testns-test","--","/bin/sh","-c","sleep infinity"]},{"op": "replace", "path": "/metadata/annotations/container.landlock.security.beta.varmor.org~1test", "value": "localhost/varmor-testns-test"},{"op": "replace", "path": "/metadata/annotations/webhook.varmor.org~1mutatedAt", "value": "TIME_STRING"}]`,
+			rawTarget: []byte(`
+    kind: Pod
+    name: 4.2-test`),
+			rawResource: []byte(`
+      apiVersion: v1
+      kind: Pod
+      metadata:
+        name: 4.2-test
+        namespace: test
+      spec:
+        containers:
+        - name: test
+          image: debian:10
+          command: ["/var/run/varmor/landlock/varmor-landlock", "--profile", "/var/run/varmor/landlock/varmor-testns-old", "--", "/bin/sh", "-c", "sleep infinity"]
+        - name: sidecar
+          image: debian:10
+      `),
+		},
+		{
+			name:             "patchPodSELinuxSeccompConfined",
+			kind:             "Pod",
+			enforcer:         "SELinuxSeccomp",
+			bpfExclusiveMode: false,
+			expectedResult:   `[{"op": "add", "path": "/metadata/annotations", "value": {}},{"op": "replace", "path": "/metadata/annotations/container.selinux.security.beta.varmor.org~1test", "value": "localhost/varmor-testns-test"},{"op": "add", "path": "/spec/containers/0/securityContext", "value": {}},{"op": "replace", "path": "/spec/containers/0/securityContext/seLinuxOptions", "value": {"type": "varmor_testns_test.process"}},{"op": "replace", "path": "/metadata/annotations/container.seccomp.security.beta.varmor.org~1test", "value": "localhost/varmor-testns-test"},{"op": "replace", "path": "/spec/containers/0/securityContext/seccompProfile", "value": {"type": "Localhost", "localhostProfile": "varmor-testns-test"}},{"op": "replace", "path": "/metadata/annotations/webhook.varmor.org~1mutatedAt", "value": "TIME_STRING"}]`,
+			rawTarget: []byte(`
+    kind: Pod
+    name: 4.2-test
+    containers:
+    - test`),
+			rawResource: []byte(`
+      apiVersion: v1
+      kind: Pod
+      metadata:
+        name: 4.2-test
+        namespace: test
+      spec:
+        containers:
+        - name: test
+          image: debian:10
       `),
 		},
 	}
@@ -543,7 +648,23 @@ func Test_buildPatch(t *testing.T) {
 				assert.NilError(t, err)
 
 				deploy := obj.(*appsv1.Deployment)
-				patch, err := buildPatch(deploy, tc.enforcer, target, profileName, tc.bpfExclusiveMode)
+				patch, err := buildPatch(deploy, tc.enforcer, target, profileName, tc.bpfExclusiveMode, false)
+				if err != nil {
+					assert.Assert(t, err != nil)
+				}
+
+				if strings.Contains(patch, "1mutatedAt") {
+					index := strings.Index(patch, `1mutatedAt", "value": `)
+					patch = patch[:index+len(`1mutatedAt", "value": `)] + `"TIME_STRING"}]`
+				}
+				assert.Equal(t, patch, tc.expectedResult)
+			case "CronJob":
+				decode := scheme.Codecs.UniversalDeserializer().Decode
+				obj, _, err := decode(tc.rawResource, nil, nil)
+				assert.NilError(t, err)
+
+				cronJob := obj.(*batchv1.CronJob)
+				patch, err := buildPatch(cronJob, tc.enforcer, target, profileName, tc.bpfExclusiveMode, false)
 				if err != nil {
 					assert.Assert(t, err != nil)
 				}
@@ -559,7 +680,7 @@ func Test_buildPatch(t *testing.T) {
 				assert.NilError(t, err)
 
 				pod := obj.(*corev1.Pod)
-				patch, err := buildPatch(pod, tc.enforcer, target, profileName, tc.bpfExclusiveMode)
+				patch, err := buildPatch(pod, tc.enforcer, target, profileName, tc.bpfExclusiveMode, false)
 				if err != nil {
 					assert.Assert(t, err != nil)
 				}
@@ -573,3 +694,19 @@ func Test_buildPatch(t *testing.T) {
 		})
 	}
 }
+
+func Test_buildSandboxPatch(t *testing.T) {
+	patch := buildSandboxPatch(nil, "/spec/template", "BPF")
+	assert.Equal(t, patch, `{"op": "replace", "path": "/spec/template/metadata/annotations/sandbox.bpf.security.beta.varmor.org", "value": "localhost/varmor-sandbox"},`)
+
+	patch = buildSandboxPatch(nil, "", "AppArmorBPF")
+	assert.Equal(t, patch, `{"op": "replace", "path": "/metadata/annotations/sandbox.bpf.security.beta.varmor.org", "value": "localhost/varmor-sandbox"},`)
+
+	// Only the BPF enforcer supports the sandbox containers
+	patch = buildSandboxPatch(nil, "", "AppArmor")
+	assert.Equal(t, patch, "")
+
+	// The sandbox container opted out is skipped
+	patch = buildSandboxPatch(map[string]string{"sandbox.bpf.security.beta.varmor.org": "unconfined"}, "", "BPF")
+	assert.Equal(t, patch, "")
+}
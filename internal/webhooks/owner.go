@@ -0,0 +1,105 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// maxOwnerDepth is the max depth of the owner chain to resolve, e.g. Pod -> ReplicaSet -> Deployment -> Revision
+const maxOwnerDepth = 5
+
+// owner is a controller in the owner chain of a pod
+type owner struct {
+	gvk  schema.GroupVersionKind
+	meta metav1.Object
+}
+
+// ownerResolver resolves the controller owner chain of the pods with the metadata API, so the pods created by
+// the custom controllers (e.g. Argo Rollouts, Knative) can be matched with the owner kinds of the policies.
+type ownerResolver struct {
+	mapper meta.RESTMapper
+	client metadata.Interface
+}
+
+// isOwnerTarget returns true if the target specifies an owner kind of the pods instead of a built-in workload type
+func isOwnerTarget(target varmor.Target) bool {
+	return target.APIVersion != ""
+}
+
+// resolve returns the controller owner chain of the object, from the nearest owner to the farthest one.
+// The resolution stops at the first owner which can't be retrieved.
+func (r *ownerResolver) resolve(ctx context.Context, namespace string, obj metav1.Object) ([]owner, error) {
+	var owners []owner
+
+	for i := 0; i < maxOwnerDepth; i++ {
+		ref := metav1.GetControllerOfNoCopy(obj)
+		if ref == nil {
+			break
+		}
+
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return owners, err
+		}
+		gvk := gv.WithKind(ref.Kind)
+
+		mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				// The CRD may be installed after the cache of discovery was built
+				if resettable, ok := r.mapper.(meta.ResettableRESTMapper); ok {
+					resettable.Reset()
+					mapping, err = r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+				}
+			}
+			if err != nil {
+				return owners, err
+			}
+		}
+
+		o, err := r.client.Resource(mapping.Resource).Namespace(namespace).Get(ctx, ref.Name, metav1.GetOptions{ResourceVersion: "0"})
+		if err != nil {
+			return owners, err
+		}
+
+		owners = append(owners, owner{gvk: gvk, meta: o})
+		obj = o
+	}
+
+	return owners, nil
+}
+
+// findOwner returns the first owner which matches the group and kind of the target
+func findOwner(owners []owner, target varmor.Target) metav1.Object {
+	gv, err := schema.ParseGroupVersion(target.APIVersion)
+	if err != nil {
+		return nil
+	}
+
+	for _, o := range owners {
+		if o.gvk.Group == gv.Group && o.gvk.Kind == target.Kind {
+			return o.meta
+		}
+	}
+	return nil
+}
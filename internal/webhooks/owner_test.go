@@ -0,0 +1,108 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata/fake"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func newPartialObject(apiVersion, kind, name string, labels map[string]string, controller *metav1.OwnerReference) *metav1.PartialObjectMetadata {
+	o := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "demo",
+			Labels:    labels,
+		},
+	}
+	if controller != nil {
+		o.OwnerReferences = []metav1.OwnerReference{*controller}
+	}
+	return o
+}
+
+func controllerRef(apiVersion, kind, name string) *metav1.OwnerReference {
+	isController := true
+	return &metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, Controller: &isController}
+}
+
+func Test_resolveOwners(t *testing.T) {
+	scheme := fake.NewTestScheme()
+	assert.NilError(t, metav1.AddMetaToScheme(scheme))
+
+	client := fake.NewSimpleMetadataClient(scheme,
+		newPartialObject("argoproj.io/v1alpha1", "Rollout", "demo", map[string]string{"app": "demo"}, nil),
+		newPartialObject("apps/v1", "ReplicaSet", "demo-5d4f8", nil, controllerRef("argoproj.io/v1alpha1", "Rollout", "demo")),
+	)
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}, meta.RESTScopeNamespace)
+
+	r := &ownerResolver{mapper: mapper, client: client}
+	pod := newPartialObject("v1", "Pod", "", nil, controllerRef("apps/v1", "ReplicaSet", "demo-5d4f8"))
+
+	owners, err := r.resolve(context.Background(), "demo", pod)
+	assert.NilError(t, err)
+	assert.Equal(t, len(owners), 2)
+	assert.Equal(t, owners[0].gvk.Kind, "ReplicaSet")
+	assert.Equal(t, owners[1].gvk.Kind, "Rollout")
+
+	testCases := []struct {
+		name     string
+		target   varmor.Target
+		expected string
+	}{
+		{
+			name:     "rollout",
+			target:   varmor.Target{Kind: "Rollout", APIVersion: "argoproj.io/v1alpha1"},
+			expected: "demo",
+		},
+		{
+			name:     "anotherVersion",
+			target:   varmor.Target{Kind: "Rollout", APIVersion: "argoproj.io/v1"},
+			expected: "demo",
+		},
+		{
+			name:   "anotherGroup",
+			target: varmor.Target{Kind: "Rollout", APIVersion: "example.com/v1"},
+		},
+		{
+			name:   "anotherKind",
+			target: varmor.Target{Kind: "Revision", APIVersion: "serving.knative.dev/v1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := findOwner(owners, tc.target)
+			if tc.expected == "" {
+				assert.Assert(t, o == nil)
+				return
+			}
+			assert.Assert(t, o != nil)
+			assert.Equal(t, o.GetName(), tc.expected)
+		})
+	}
+}
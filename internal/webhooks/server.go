@@ -19,18 +19,23 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel/attribute"
 	admissionv1 "k8s.io/api/admission/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	labels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	authzclientv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/tools/cache"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
@@ -38,6 +43,9 @@ import (
 	"github.com/bytedance/vArmor/internal/policycacher"
 	varmorprofile "github.com/bytedance/vArmor/internal/profile"
 	varmortls "github.com/bytedance/vArmor/internal/tls"
+	varmortracing "github.com/bytedance/vArmor/internal/tracing"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+	varmorutils "github.com/bytedance/vArmor/internal/utils"
 	"github.com/bytedance/vArmor/internal/webhookconfig"
 )
 
@@ -47,25 +55,44 @@ type WebhookServer struct {
 	webhookRegister  *webhookconfig.Register
 	policyCacher     *policycacher.PolicyCacher
 	deserializer     runtime.Decoder
+	ownerResolver    *ownerResolver
+	authzInterface   authzclientv1.AuthorizationV1Interface
 	bpfExclusiveMode bool
-	log              logr.Logger
+	// bpfProfileLayering layers the profile of the VarmorClusterPolicy object under the one of the VarmorPolicy
+	// object when both of them match a workload
+	bpfProfileLayering bool
+	// ruleExceptionAllowList is the set of the built-in rules which can be excepted for pods
+	ruleExceptionAllowList map[string]bool
+	log                    logr.Logger
 }
 
 func NewWebhookServer(
 	webhookRegister *webhookconfig.Register,
 	policyCacher *policycacher.PolicyCacher,
+	metadataInterface metadata.Interface,
+	mapper meta.RESTMapper,
+	authzInterface authzclientv1.AuthorizationV1Interface,
 	tlsPair *varmortls.PemPair,
 	addr string,
 	port int,
 	bpfExclusiveMode bool,
+	bpfProfileLayering bool,
+	ruleExceptionAllowList []string,
 	log logr.Logger,
 ) (*WebhookServer, error) {
 
 	ws := &WebhookServer{
-		webhookRegister:  webhookRegister,
-		policyCacher:     policyCacher,
-		bpfExclusiveMode: bpfExclusiveMode,
-		log:              log,
+		webhookRegister:        webhookRegister,
+		policyCacher:           policyCacher,
+		ownerResolver:          &ownerResolver{mapper: mapper, client: metadataInterface},
+		authzInterface:         authzInterface,
+		bpfExclusiveMode:       bpfExclusiveMode,
+		bpfProfileLayering:     bpfProfileLayering,
+		ruleExceptionAllowList: make(map[string]bool, len(ruleExceptionAllowList)),
+		log:                    log,
+	}
+	for _, rule := range ruleExceptionAllowList {
+		ws.ruleExceptionAllowList[rule] = true
 	}
 
 	scheme := runtime.NewScheme()
@@ -74,6 +101,7 @@ func NewWebhookServer(
 
 	mux := httprouter.New()
 	mux.HandlerFunc("POST", varmorconfig.MutatingWebhookServicePath, ws.handlerFunc(ws.resourceMutation))
+	mux.HandlerFunc("POST", varmorconfig.ValidatingWebhookServicePath, ws.handlerFunc(ws.resourceValidation))
 
 	// Patch Liveness responds to a Kubernetes Liveness probe.
 	// Fail this request if Kubernetes should restart this instance.
@@ -135,7 +163,14 @@ func (ws *WebhookServer) handlerFunc(handler func(request *admissionv1.Admission
 			"namespace", request.Namespace, "name", request.Name,
 			"operation", request.Operation)
 
+		_, span := varmortracing.StartSpan(varmortracing.ExtractFromHTTPHeader(r.Context(), r.Header), "WebhookServer.admission",
+			attribute.String("kind", request.Kind.String()),
+			attribute.String("namespace", request.Namespace),
+			attribute.String("name", request.Name),
+			attribute.String("operation", string(request.Operation)))
 		admissionReview.Response = handler(request)
+		span.SetAttributes(attribute.Bool("allowed", admissionReview.Response.Allowed))
+		span.End()
 		writeResponse(rw, admissionReview)
 
 		logger.V(3).Info("AdmissionRequest processed", "time", time.Since(startTime).String())
@@ -156,6 +191,14 @@ func (ws *WebhookServer) deserializeWorkload(request *admissionv1.AdmissionReque
 		daemon := appsv1.DaemonSet{}
 		_, _, err := ws.deserializer.Decode(request.Object.Raw, nil, &daemon)
 		return &daemon, err
+	case "Job":
+		job := batchv1.Job{}
+		_, _, err := ws.deserializer.Decode(request.Object.Raw, nil, &job)
+		return &job, err
+	case "CronJob":
+		cronJob := batchv1.CronJob{}
+		_, _, err := ws.deserializer.Decode(request.Object.Raw, nil, &cronJob)
+		return &cronJob, err
 	case "Pod":
 		pod := corev1.Pod{}
 		_, _, err := ws.deserializer.Decode(request.Object.Raw, nil, &pod)
@@ -164,7 +207,21 @@ func (ws *WebhookServer) deserializeWorkload(request *admissionv1.AdmissionReque
 	return nil, fmt.Errorf("unsupported kind")
 }
 
-func (ws *WebhookServer) matchAndPatch(request *admissionv1.AdmissionRequest, key string, target varmor.Target, logger logr.Logger) *admissionv1.AdmissionResponse {
+// policyMatch describes the policy that matches the resource of the admission request
+type policyMatch struct {
+	obj              interface{}
+	enforcer         string
+	target           varmor.Target
+	apName           string
+	rejectPrivileged bool
+	confineSandbox   bool
+	owners           func(metav1.Object) []owner
+	// baseProfile is the profile of the VarmorClusterPolicy object that is layered under the profile
+	baseProfile string
+}
+
+// match returns the policy of the key if it matches the resource of the admission request, or nil if it doesn't
+func (ws *WebhookServer) match(request *admissionv1.AdmissionRequest, key string, target varmor.Target, owners func(metav1.Object) []owner, logger logr.Logger) *policyMatch {
 	policyNamespace, policyName, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
 		return nil
@@ -176,15 +233,31 @@ func (ws *WebhookServer) matchAndPatch(request *admissionv1.AdmissionRequest, ke
 		return nil
 	}
 
-	if request.Kind.Kind != target.Kind {
+	if isOwnerTarget(target) {
+		// The target is a custom owner kind, so the pods created by the owners are mutated.
+		if request.Kind.Kind != "Pod" {
+			return nil
+		}
+	} else if request.Kind.Kind != target.Kind {
+		return nil
+	}
+
+	// The pod template of Job is immutable, so only the Job being created can be mutated.
+	if request.Kind.Kind == "Job" && request.Operation != admissionv1.Create {
 		return nil
 	}
 
 	enforcer := ""
+	rejectPrivileged := false
+	confineSandbox := false
 	if clusterScope {
 		enforcer = ws.policyCacher.ClusterPolicyEnforcer[key]
+		rejectPrivileged = ws.policyCacher.ClusterPolicyRejectPrivileged[key]
+		confineSandbox = ws.policyCacher.ClusterPolicyConfineSandbox[key]
 	} else {
 		enforcer = ws.policyCacher.PolicyEnforcer[key]
+		rejectPrivileged = ws.policyCacher.PolicyRejectPrivileged[key]
+		confineSandbox = ws.policyCacher.PolicyConfineSandbox[key]
 	}
 
 	obj, err := ws.deserializeWorkload(request)
@@ -199,53 +272,170 @@ func (ws *WebhookServer) matchAndPatch(request *admissionv1.AdmissionRequest, ke
 		return nil
 	}
 
-	apName := varmorprofile.GenerateArmorProfileName(policyNamespace, policyName, clusterScope)
-	if target.Name != "" && target.Name == m.GetName() {
-		logger.Info("mutating resource", "resource kind", request.Kind.Kind, "resource namespace", request.Namespace, "resource name", request.Name, "profile", apName)
-		patch, err := buildPatch(obj, enforcer, target, apName, ws.bpfExclusiveMode)
-		if err != nil {
-			logger.Error(err, "ws.buildPatch()")
+	if isOwnerTarget(target) {
+		// Match the owner of the pod with the name or selector of the target, and patch the pod
+		m = findOwner(owners(m), target)
+		if m == nil {
 			return nil
 		}
-		return successResponse(request.UID, []byte(patch))
+		target.Kind = "Pod"
+	}
+
+	if !varmorutils.MatchServiceAccount(podServiceAccount(obj), target.ServiceAccounts) {
+		return nil
+	}
+
+	matched := false
+	if target.Name != "" && target.Name == m.GetName() {
+		matched = true
 	} else if target.Selector != nil {
 		selector, err := metav1.LabelSelectorAsSelector(target.Selector)
 		if err != nil {
 			return nil
 		}
-		if selector.Matches(labels.Set(m.GetLabels())) {
-			logger.Info("mutating resource", "resource kind", request.Kind.Kind, "resource namespace", request.Namespace, "resource name", request.Name, "profile", apName)
-			patch, err := buildPatch(obj, enforcer, target, apName, ws.bpfExclusiveMode)
-			if err != nil {
-				logger.Error(err, "ws.buildPatch()")
-				return nil
-			}
-			return successResponse(request.UID, []byte(patch))
+		matched = selector.Matches(labels.Set(m.GetLabels()))
+	} else if target.Name == "" && len(target.ServiceAccounts) != 0 {
+		// The target is only specified by the service accounts
+		matched = true
+	}
+	if !matched {
+		return nil
+	}
+
+	return &policyMatch{
+		obj:              obj,
+		enforcer:         enforcer,
+		target:           target,
+		apName:           varmorprofile.GenerateArmorProfileName(policyNamespace, policyName, clusterScope),
+		rejectPrivileged: rejectPrivileged,
+		confineSandbox:   confineSandbox,
+		owners:           owners,
+	}
+}
+
+// layerable reports whether the profile of the VarmorClusterPolicy object can be layered under the one of the
+// VarmorPolicy object. It's only supported by the BPF enforcer, and they must target the same containers.
+func layerable(cluster *policyMatch, namespaced *policyMatch) bool {
+	if varmortypes.GetEnforcerType(cluster.enforcer) != varmortypes.BPF || varmortypes.GetEnforcerType(namespaced.enforcer) != varmortypes.BPF {
+		return false
+	}
+	if len(cluster.target.Containers) != len(namespaced.target.Containers) {
+		return false
+	}
+	for _, container := range cluster.target.Containers {
+		if !varmorutils.InStringArray(container, namespaced.target.Containers) {
+			return false
 		}
 	}
+	return true
+}
+
+// patch vets the rule exceptions and the privileged containers of the matched resource and mutates it with the
+// profile. The privileged containers and the ones that share the host namespaces are rejected if rejectPrivileged
+// is true, otherwise they are admitted with the warnings. The target containers of the Landlock enforcer without
+// the command are always rejected. The sandbox container is confined if confineSandbox is true.
+func (ws *WebhookServer) patch(request *admissionv1.AdmissionRequest, match *policyMatch, logger logr.Logger) *admissionv1.AdmissionResponse {
+	obj, enforcer, target, apName := match.obj, match.enforcer, match.target, match.apName
+	err := ws.validateRuleExceptions(request, obj, enforcer, match.owners)
+	if err != nil {
+		logger.Info("the rule exceptions are denied", "resource kind", request.Kind.Kind, "resource namespace", request.Namespace, "resource name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "invalid rule exceptions")
+	}
+
+	conflicts := privilegedConflicts(podSpec(obj), target)
+	if len(conflicts) != 0 && match.rejectPrivileged {
+		logger.Info("the privileged containers are rejected", "resource kind", request.Kind.Kind, "resource namespace", request.Namespace, "resource name", request.Name, "conflicts", conflicts)
+		return failureResponse(request.UID, fmt.Sprintf("the policy rejects the privileged containers and the ones that share the host namespaces (%s)", strings.Join(conflicts, "; ")))
+	}
 
-	return nil
+	missing := landlockCommandConflicts(obj, enforcer, target)
+	if len(missing) != 0 {
+		logger.Info("the containers without the command are rejected", "resource kind", request.Kind.Kind, "resource namespace", request.Namespace, "resource name", request.Name, "containers", missing)
+		return failureResponse(request.UID, fmt.Sprintf("the Landlock enforcer requires the target containers to specify the command, since the launcher can't wrap the entrypoint of the image (%s)", strings.Join(missing, ", ")))
+	}
+
+	logger.Info("mutating resource", "resource kind", request.Kind.Kind, "resource namespace", request.Namespace, "resource name", request.Name, "profile", apName)
+	patch, err := buildPatch(obj, enforcer, target, apName, ws.bpfExclusiveMode, match.confineSandbox)
+	if err != nil {
+		logger.Error(err, "ws.buildPatch()")
+		return nil
+	}
+	if match.baseProfile != "" {
+		logger.Info("layering the profile", "resource kind", request.Kind.Kind, "resource namespace", request.Namespace, "resource name", request.Name, "profile", apName, "base profile", match.baseProfile)
+		patch = appendPatch(patch, buildBaseProfilePatch(obj, target, match.baseProfile))
+	}
+
+	response := successResponse(request.UID, []byte(patch))
+	for _, conflict := range conflicts {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("vArmor: the container is only partially enforceable, since several rules are ineffective for it (%s)", conflict))
+	}
+	return response
 }
 
 // resourceMutation mutates workloads that meet the .spec.target condition of either VarmorClusterPolicy or VarmorPolicy.
 // VarmorClusterPolicy objects have higher priority than VarmorPolicy objects. When both a VarmorClusterPolicy object and
 // a VarmorPolicy object match a workload, VarmorClusterPolicy will be used to secure the workload. When multiple
 // VarmorClusterPolicy/VarmorPolicy objects match a Workload, one will be randomly selected to secure the workload.
+//
+// If the BPF profile layering is enabled, and both of them only use the BPF enforcer, the workload is secured with the
+// profile of the VarmorPolicy object, and the profile of the VarmorClusterPolicy object is layered under it.
 func (ws *WebhookServer) resourceMutation(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
 	logger := ws.log.WithName("resourceMutation()")
 
-	for key, target := range ws.policyCacher.ClusterPolicyTargets {
-		response := ws.matchAndPatch(request, key, target, logger)
-		if response != nil {
+	if request.Kind.Kind == "Pod" {
+		if response := ws.suspensionValidation(request, logger); response != nil {
 			return response
 		}
+		// The pod updates are only received for vetting the suspension annotations added to the running pods
+		if request.Operation != admissionv1.Create {
+			return successResponse(request.UID, nil)
+		}
+	}
+
+	// Resolve the owner chain of the pod at most once, and only when it's required by the targets
+	var ownerChain []owner
+	resolved := false
+	owners := func(pod metav1.Object) []owner {
+		if !resolved {
+			resolved = true
+			var err error
+			ownerChain, err = ws.ownerResolver.resolve(context.Background(), request.Namespace, pod)
+			if err != nil {
+				logger.Error(err, "failed to resolve the owners of the pod", "namespace", request.Namespace, "name", request.Name)
+			}
+		}
+		return ownerChain
+	}
+
+	var clusterMatch *policyMatch
+	for key, target := range ws.policyCacher.ClusterPolicyTargets {
+		clusterMatch = ws.match(request, key, target, owners, logger)
+		if clusterMatch != nil {
+			break
+		}
+	}
+	if clusterMatch != nil && !ws.bpfProfileLayering {
+		return ws.patch(request, clusterMatch, logger)
 	}
 
 	for key, target := range ws.policyCacher.PolicyTargets {
-		response := ws.matchAndPatch(request, key, target, logger)
-		if response != nil {
-			return response
+		match := ws.match(request, key, target, owners, logger)
+		if match == nil {
+			continue
 		}
+		if clusterMatch == nil {
+			return ws.patch(request, match, logger)
+		}
+		if layerable(clusterMatch, match) {
+			match.baseProfile = clusterMatch.apName
+			match.rejectPrivileged = match.rejectPrivileged || clusterMatch.rejectPrivileged
+			match.confineSandbox = match.confineSandbox || clusterMatch.confineSandbox
+			return ws.patch(request, match, logger)
+		}
+		break
+	}
+	if clusterMatch != nil {
+		return ws.patch(request, clusterMatch, logger)
 	}
 
 	logger.V(3).Info("no mutation required")
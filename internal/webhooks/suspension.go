@@ -0,0 +1,90 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// validateSuspensions checks the suspension annotations that are added or changed by the pod creation or update.
+// Their values must be the time in RFC 3339, and the requester must be allowed to create the suspensions
+// subresource of the VarmorPolicy objects in the namespace. The pods created by the controllers are vetted too,
+// so the suspension annotations can't be set with the pod templates of the workloads. Removing the annotations
+// is always allowed, since it resumes the enforcement.
+func (ws *WebhookServer) validateSuspensions(request *admissionv1.AdmissionRequest, pod *corev1.Pod, oldPod *corev1.Pod) error {
+	changed := false
+	for k, v := range pod.Annotations {
+		if !strings.HasPrefix(k, varmortypes.SuspendAnnotationPrefix) {
+			continue
+		}
+		if oldPod != nil {
+			if old, ok := oldPod.Annotations[k]; ok && old == v {
+				continue
+			}
+		}
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("the value of the annotation %s must be the time in RFC 3339", k)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	allowed, err := ws.authorize(request, "suspensions")
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("the user '%s' isn't allowed to suspend the enforcement in the namespace", request.UserInfo.Username)
+	}
+	return nil
+}
+
+// suspensionValidation vets the suspension annotations of the pod of the admission request. It returns the response
+// that denies the request, or nil if it's allowed.
+func (ws *WebhookServer) suspensionValidation(request *admissionv1.AdmissionRequest, logger logr.Logger) *admissionv1.AdmissionResponse {
+	pod := corev1.Pod{}
+	_, _, err := ws.deserializer.Decode(request.Object.Raw, nil, &pod)
+	if err != nil {
+		logger.Error(err, "failed to deserialize the pod")
+		return errorResponse(request.UID, err, "failed to deserialize the pod")
+	}
+
+	var oldPod *corev1.Pod
+	if request.Operation == admissionv1.Update {
+		oldPod = &corev1.Pod{}
+		_, _, err = ws.deserializer.Decode(request.OldObject.Raw, nil, oldPod)
+		if err != nil {
+			logger.Error(err, "failed to deserialize the old pod")
+			return errorResponse(request.UID, err, "failed to deserialize the pod")
+		}
+	}
+
+	err = ws.validateSuspensions(request, &pod, oldPod)
+	if err != nil {
+		logger.Info("the suspension annotations are denied", "resource namespace", request.Namespace, "resource name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "invalid suspension annotations")
+	}
+	return nil
+}
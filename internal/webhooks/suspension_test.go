@@ -0,0 +1,109 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_validateSuspensions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		sar.Status.Allowed = sar.Spec.User == "admin" && sar.Spec.ResourceAttributes.Subresource == "suspensions"
+		return true, sar, nil
+	})
+
+	ws := &WebhookServer{
+		authzInterface: client.AuthorizationV1(),
+	}
+
+	testCases := []struct {
+		name           string
+		user           string
+		annotations    map[string]string
+		oldAnnotations map[string]string
+		expectedErr    bool
+	}{
+		{
+			name:        "allowed",
+			user:        "admin",
+			annotations: map[string]string{"suspend.varmor.org/nginx": "2024-06-01T08:30:00Z"},
+		},
+		{
+			name:        "requesterNotAllowed",
+			user:        "developer",
+			annotations: map[string]string{"suspend.varmor.org/nginx": "2024-06-01T08:30:00Z"},
+			expectedErr: true,
+		},
+		{
+			name:        "builtInController",
+			user:        "system:serviceaccount:kube-system:replicaset-controller",
+			annotations: map[string]string{"suspend.varmor.org/nginx": "2024-06-01T08:30:00Z"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalidTime",
+			user:        "admin",
+			annotations: map[string]string{"suspend.varmor.org/nginx": "30m"},
+			expectedErr: true,
+		},
+		{
+			name:           "renewed",
+			user:           "developer",
+			annotations:    map[string]string{"suspend.varmor.org/nginx": "2024-06-01T09:30:00Z"},
+			oldAnnotations: map[string]string{"suspend.varmor.org/nginx": "2024-06-01T08:30:00Z"},
+			expectedErr:    true,
+		},
+		{
+			name:           "unchanged",
+			user:           "developer",
+			annotations:    map[string]string{"suspend.varmor.org/nginx": "2024-06-01T08:30:00Z", "app": "web"},
+			oldAnnotations: map[string]string{"suspend.varmor.org/nginx": "2024-06-01T08:30:00Z"},
+		},
+		{
+			name:           "removed",
+			user:           "developer",
+			annotations:    map[string]string{},
+			oldAnnotations: map[string]string{"suspend.varmor.org/nginx": "2024-06-01T08:30:00Z"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			var oldPod *corev1.Pod
+			if tc.oldAnnotations != nil {
+				oldPod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tc.oldAnnotations}}
+			}
+			request := &admissionv1.AdmissionRequest{
+				Namespace: "test",
+				UserInfo:  authnv1.UserInfo{Username: tc.user},
+			}
+			err := ws.validateSuspensions(request, pod, oldPod)
+			assert.Equal(t, err != nil, tc.expectedErr, err)
+		})
+	}
+}
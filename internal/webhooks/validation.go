@@ -0,0 +1,178 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	admissionv1 "k8s.io/api/admission/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorprofile "github.com/bytedance/vArmor/internal/profile"
+	bpfprofile "github.com/bytedance/vArmor/internal/profile/bpf"
+	varmortypes "github.com/bytedance/vArmor/internal/types"
+)
+
+// deserializePolicy returns the .spec.policy of VarmorPolicy or VarmorClusterPolicy object
+func deserializePolicy(request *admissionv1.AdmissionRequest) (*varmor.Policy, bool, error) {
+	switch request.Kind.Kind {
+	case "VarmorPolicy":
+		vp := varmor.VarmorPolicy{}
+		err := json.Unmarshal(request.Object.Raw, &vp)
+		return &vp.Spec.Policy, true, err
+	case "VarmorClusterPolicy":
+		vcp := varmor.VarmorClusterPolicy{}
+		err := json.Unmarshal(request.Object.Raw, &vcp)
+		return &vcp.Spec.Policy, true, err
+	}
+	return nil, false, nil
+}
+
+// deserializeProfile returns the profile of ArmorProfile or ArmorProfileModel object
+func deserializeProfile(request *admissionv1.AdmissionRequest) (*varmor.Profile, bool, error) {
+	switch request.Kind.Kind {
+	case "ArmorProfile":
+		ap := varmor.ArmorProfile{}
+		err := json.Unmarshal(request.Object.Raw, &ap)
+		return &ap.Spec.Profile, true, err
+	case "ArmorProfileModel":
+		apm := varmor.ArmorProfileModel{}
+		err := json.Unmarshal(request.Object.Raw, &apm)
+		return &apm.Data.Profile, true, err
+	}
+	return nil, false, nil
+}
+
+// validateLifecycleHooks returns an error if the lifecycle hooks of the policy can't be invoked
+func validateLifecycleHooks(hooks []varmor.LifecycleHook) error {
+	for _, hook := range hooks {
+		u, err := url.Parse(hook.URL)
+		if err != nil {
+			return fmt.Errorf("the URL of the lifecycle hook is invalid: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("the URL of the lifecycle hook must be an absolute http or https URL (%s)", hook.URL)
+		}
+		for _, event := range hook.Events {
+			switch varmortypes.LifecycleEventType(event) {
+			case varmortypes.PreEnforceEvent, varmortypes.PostEnforceEvent, varmortypes.ModeChangedEvent, varmortypes.EnforcementFailedEvent:
+			default:
+				return fmt.Errorf("the event %s of the lifecycle hook is unsupported", event)
+			}
+		}
+		if hook.TimeoutSeconds < 0 {
+			return fmt.Errorf("the timeout of the lifecycle hook can't be negative")
+		}
+	}
+	return nil
+}
+
+// resourceValidation validates the policies and the profiles
+func (ws *WebhookServer) resourceValidation(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	switch request.Kind.Kind {
+	case "ArmorProfile", "ArmorProfileModel":
+		return ws.profileValidation(request)
+	}
+	return ws.policyValidation(request)
+}
+
+// profileValidation rejects the ArmorProfile and ArmorProfileModel objects whose BPF content can't be loaded by
+// the BPF enforcer, e.g. the profiles imported into the ArmorProfileModel objects for the DefenseInDepth mode.
+func (ws *WebhookServer) profileValidation(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	logger := ws.log.WithName("profileValidation()")
+
+	profile, ok, err := deserializeProfile(request)
+	if !ok {
+		return successResponse(request.UID, nil)
+	}
+	if err != nil {
+		logger.Error(err, "deserializeProfile()")
+		return errorResponse(request.UID, err, "failed to deserialize the profile")
+	}
+
+	if profile.BpfContent == nil {
+		return successResponse(request.UID, nil)
+	}
+
+	err = bpfprofile.ValidateBpfContent(profile.BpfContent)
+	if err != nil {
+		logger.Info("the profile is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "the BPF content of the profile is invalid")
+	}
+
+	return successResponse(request.UID, nil)
+}
+
+// policyValidation rejects the VarmorPolicy and VarmorClusterPolicy objects whose profiles can't be applied by the enforcers.
+func (ws *WebhookServer) policyValidation(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	logger := ws.log.WithName("policyValidation()")
+
+	policy, ok, err := deserializePolicy(request)
+	if !ok {
+		return successResponse(request.UID, nil)
+	}
+	if err != nil {
+		logger.Error(err, "deserializePolicy()")
+		return errorResponse(request.UID, err, "failed to deserialize the policy")
+	}
+
+	err = varmorprofile.ValidateEnforcerRules(*policy)
+	if err != nil {
+		logger.Info("the policy is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "the built-in rules of the policy are invalid")
+	}
+
+	err = varmorprofile.ValidateBpfProfile(*policy)
+	if err != nil {
+		logger.Info("the policy is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "the BPF profile of the policy is invalid")
+	}
+
+	err = varmorprofile.ValidateLandlockProfile(*policy)
+	if err != nil {
+		logger.Info("the policy is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "the Landlock profile of the policy is invalid")
+	}
+
+	err = varmorprofile.ValidateSELinuxProfile(*policy)
+	if err != nil {
+		logger.Info("the policy is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "the SELinux profile of the policy is invalid")
+	}
+
+	if request.Kind.Kind == "VarmorClusterPolicy" {
+		err = varmorprofile.ValidateClusterNetworkPeers(*policy)
+		if err != nil {
+			logger.Info("the policy is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+			return errorResponse(request.UID, err, "the network rules of the policy are invalid")
+		}
+	}
+
+	err = varmorprofile.ValidateAppArmorProfile(*policy)
+	if err != nil {
+		logger.Info("the policy is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "the AppArmor profile of the policy is invalid")
+	}
+
+	err = validateLifecycleHooks(policy.LifecycleHooks)
+	if err != nil {
+		logger.Info("the policy is denied", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "reason", err.Error())
+		return errorResponse(request.UID, err, "the lifecycle hooks of the policy are invalid")
+	}
+
+	return successResponse(request.UID, nil)
+}
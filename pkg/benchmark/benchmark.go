@@ -0,0 +1,267 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package benchmark generates the synthetic load of the BPF enforcer on a single node for capacity planning. It
+// creates the synthetic mnt namespaces which stand for the containers, applies N profiles × M file rules to them,
+// and measures the throughput of applying and deleting the profiles, the memory of the inner maps, and the latency
+// of the file opens with and without the profiles. The BPF LSM must be enabled, and it must be run as root.
+//
+//	result, err := benchmark.Run(benchmark.Config{Profiles: 100, Rules: 50, NamespacesPerProfile: 10})
+//	if err != nil { ... }
+//	fmt.Println(result.ApplyThroughput, result.MapMemoryBytes)
+package benchmark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// filePreciseMatch and filePrefixMatch are the flags of the path patterns of the BPF file rules
+	filePreciseMatch = 0x00000001
+	filePrefixMatch  = 0x00000004
+	// fileMayRead and fileMayWrite are the permissions of the BPF file rules
+	fileMayRead  = 0x00000004
+	fileMayWrite = 0x00000002
+	// defaultOperations is the default count of the operations run in each mnt ns per phase
+	defaultOperations = 1000
+)
+
+// Config configures the shape of the synthetic load
+type Config struct {
+	// Profiles is the count of the BPF profiles
+	Profiles int
+	// Rules is the count of the file rules of each profile, it's capped by the maximum number of BPF file rules
+	Rules int
+	// NamespacesPerProfile is the count of the synthetic mnt namespaces that each profile is applied to. Each mnt
+	// ns holds an OS thread during the benchmark.
+	NamespacesPerProfile int
+	// Operations is the count of the file opens run in each mnt ns to measure the latencies. The
+	// defaultOperations is used if it's zero, and the measurement is skipped if it's negative.
+	Operations int
+	// Options are the options of the BPF enforcer. The profiles are applied and deleted by the Options.Workers goroutines concurrently, like the workers of the
+	// enforcer do.
+	Options bpfenforcer.Options
+}
+
+// OpenLatency is the mean latency of the file opens without and with the profiles
+type OpenLatency struct {
+	Baseline   time.Duration
+	Enforced   time.Duration
+	Operations int
+}
+
+// Delta returns the latency added by the profiles
+func (l OpenLatency) Delta() time.Duration {
+	return l.Enforced - l.Baseline
+}
+
+// Result is the result of the benchmark
+type Result struct {
+	Namespaces int
+	// Workers is the count of the goroutines that applied and deleted the profiles
+	Workers int
+	// ApplyDuration is the time of applying the profiles to all the mnt namespaces
+	ApplyDuration time.Duration
+	// ApplyThroughput is the count of the mnt namespaces that the profiles are applied to per second
+	ApplyThroughput float64
+	// DeleteDuration is the time of deleting the profiles from all the mnt namespaces
+	DeleteDuration time.Duration
+	// DeleteThroughput is the count of the mnt namespaces that the profiles are deleted from per second
+	DeleteThroughput float64
+	// InnerMaps and MapMemoryBytes are the count and the memory of the inner maps after the profiles are applied
+	InnerMaps      int
+	MapMemoryBytes uint64
+	// OpenLatency is the latency of the file opens measured in the user space, which includes the LSM hooks invoked
+	// by them. It's nil if the measurement is skipped. It's affected by the load of the whole node, so it should be
+	// run on an idle node.
+	OpenLatency *OpenLatency
+}
+
+// syntheticBpfContent generates the BPF profile with the file rules that never match the operations of the
+// benchmark, so the LSM programs evaluate all of them in the worst case
+func syntheticBpfContent(profile int, rules int) varmor.BpfContent {
+	if rules > varmortypes.MaxBpfFileRuleCount {
+		rules = varmortypes.MaxBpfFileRuleCount
+	}
+
+	var bpfContent varmor.BpfContent
+	for i := 0; i < rules; i++ {
+		bpfContent.Files = append(bpfContent.Files, varmor.FileContent{
+			Permissions: fileMayRead | fileMayWrite,
+			Pattern: varmor.PathPattern{
+				Flags:  filePreciseMatch | filePrefixMatch,
+				Prefix: fmt.Sprintf("/varmor-benchmark/profile-%d/rule-%d", profile, i),
+			},
+			RuleID: fmt.Sprintf("benchmark/profile-%d/rule-%d", profile, i),
+		})
+	}
+	return bpfContent
+}
+
+func throughput(count int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(count) / d.Seconds()
+}
+
+// runOperations opens the file in each mnt ns for the count of times, and returns the mean latency of the opens
+func runOperations(namespaces []*namespace, path string, count int) time.Duration {
+	var total time.Duration
+	for _, ns := range namespaces {
+		ns.do(func() {
+			start := time.Now()
+			for i := 0; i < count; i++ {
+				f, err := os.Open(path)
+				if err == nil {
+					f.Close()
+				}
+			}
+			total += time.Since(start)
+		})
+	}
+	return total / time.Duration(len(namespaces)*count)
+}
+
+// parallelize runs the task for each mnt ns with the workers, and returns the first error
+func parallelize(namespaces []*namespace, workers int, task func(i int, ns *namespace) error) error {
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	indexes := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := task(i, namespaces[i]); err != nil {
+					once.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	for i := range namespaces {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return firstErr
+}
+
+// Run generates the synthetic load with the config and returns the result
+func Run(config Config) (*Result, error) {
+	if config.Profiles <= 0 || config.NamespacesPerProfile <= 0 {
+		return nil, fmt.Errorf("the count of the profiles and the mnt namespaces per profile must be positive")
+	}
+	if config.Operations == 0 {
+		config.Operations = defaultOperations
+	}
+	opts := config.Options
+	if opts.Log.GetSink() == nil {
+		opts.Log = logr.Discard()
+	}
+
+	enforcer, err := bpfenforcer.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the BPF enforcer: %w", err)
+	}
+	defer enforcer.Close()
+
+	// The file opened by the operations
+	dir, err := os.MkdirTemp("", "varmor-benchmark-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "target")
+	err = os.WriteFile(path, nil, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaces []*namespace
+	defer func() {
+		for _, ns := range namespaces {
+			ns.close()
+		}
+	}()
+	for i := 0; i < config.Profiles*config.NamespacesPerProfile; i++ {
+		ns, err := newNamespace()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the synthetic mnt ns: %w", err)
+		}
+		namespaces = append(namespaces, ns)
+	}
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	result := Result{Namespaces: len(namespaces), Workers: workers}
+
+	// Measure the latency without the profiles
+	if config.Operations > 0 {
+		result.OpenLatency = &OpenLatency{
+			Baseline:   runOperations(namespaces, path, config.Operations),
+			Operations: len(namespaces) * config.Operations,
+		}
+	}
+
+	// Apply the profiles
+	profiles := make([]varmor.BpfContent, config.Profiles)
+	for i := range profiles {
+		profiles[i] = syntheticBpfContent(i, config.Rules)
+	}
+	start := time.Now()
+	err = parallelize(namespaces, workers, func(i int, ns *namespace) error {
+		var err error
+		ns.mntNsID, err = enforcer.ApplyBpfProfileToProcess(ns.tid, profiles[i/config.NamespacesPerProfile])
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply the BPF profile to the synthetic mnt ns: %w", err)
+	}
+	result.ApplyDuration = time.Since(start)
+	result.ApplyThroughput = throughput(len(namespaces), result.ApplyDuration)
+	result.InnerMaps, result.MapMemoryBytes = enforcer.MapMemory()
+
+	// Measure the latency with the profiles
+	if result.OpenLatency != nil {
+		result.OpenLatency.Enforced = runOperations(namespaces, path, config.Operations)
+	}
+
+	// Delete the profiles
+	start = time.Now()
+	err = parallelize(namespaces, workers, func(i int, ns *namespace) error {
+		return enforcer.DeleteBpfProfileOfMntNs(ns.mntNsID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete the profiles: %w", err)
+	}
+	result.DeleteDuration = time.Since(start)
+	result.DeleteThroughput = throughput(len(namespaces), result.DeleteDuration)
+
+	return &result, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_syntheticBpfContent(t *testing.T) {
+	bpfContent := syntheticBpfContent(3, 2)
+	assert.Equal(t, len(bpfContent.Files), 2)
+	assert.Equal(t, bpfContent.Files[1].Pattern.Prefix, "/varmor-benchmark/profile-3/rule-1")
+
+	bpfContent = syntheticBpfContent(0, varmortypes.MaxBpfFileRuleCount+1)
+	assert.Equal(t, len(bpfContent.Files), varmortypes.MaxBpfFileRuleCount)
+}
+
+func Test_OpenLatency(t *testing.T) {
+	l := OpenLatency{Baseline: 2 * time.Microsecond, Enforced: 5 * time.Microsecond}
+	assert.Equal(t, l.Delta(), 3*time.Microsecond)
+}
+
+func Test_throughput(t *testing.T) {
+	assert.Equal(t, throughput(100, 2*time.Second), 50.0)
+	assert.Equal(t, throughput(100, 0), 0.0)
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// namespace is a synthetic container, which is a dedicated thread moved into a new mnt ns. The BPF profile
+// applied to it only affects the operations run by it.
+type namespace struct {
+	tid     uint32
+	mntNsID uint32
+	taskCh  chan func()
+}
+
+func newNamespace() (*namespace, error) {
+	ns := namespace{
+		taskCh: make(chan func()),
+	}
+
+	errCh := make(chan error)
+	go ns.run(errCh)
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+	return &ns, nil
+}
+
+func (ns *namespace) run(errCh chan<- error) {
+	// The thread is never unlocked, so it's terminated along with the goroutine instead of being reused
+	runtime.LockOSThread()
+
+	if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+		errCh <- err
+		return
+	}
+	ns.tid = uint32(unix.Gettid())
+	errCh <- nil
+
+	for task := range ns.taskCh {
+		task()
+	}
+}
+
+// do runs the function in the mnt ns and waits for it to return
+func (ns *namespace) do(f func()) {
+	done := make(chan struct{})
+	ns.taskCh <- func() {
+		f()
+		close(done)
+	}
+	<-done
+}
+
+func (ns *namespace) close() {
+	close(ns.taskCh)
+}
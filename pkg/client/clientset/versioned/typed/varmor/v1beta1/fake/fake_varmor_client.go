@@ -44,6 +44,10 @@ func (c *FakeCrdV1beta1) VarmorPolicies(namespace string) v1beta1.VarmorPolicyIn
 	return &FakeVarmorPolicies{c, namespace}
 }
 
+func (c *FakeCrdV1beta1) VarmorViolations(namespace string) v1beta1.VarmorViolationInterface {
+	return &FakeVarmorViolations{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeCrdV1beta1) RESTClient() rest.Interface {
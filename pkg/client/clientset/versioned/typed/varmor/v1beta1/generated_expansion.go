@@ -25,3 +25,5 @@ type ArmorProfileModelExpansion interface{}
 type VarmorClusterPolicyExpansion interface{}
 
 type VarmorPolicyExpansion interface{}
+
+type VarmorViolationExpansion interface{}
@@ -32,6 +32,7 @@ type CrdV1beta1Interface interface {
 	ArmorProfileModelsGetter
 	VarmorClusterPoliciesGetter
 	VarmorPoliciesGetter
+	VarmorViolationsGetter
 }
 
 // CrdV1beta1Client is used to interact with features provided by the crd.varmor.org group.
@@ -55,6 +56,10 @@ func (c *CrdV1beta1Client) VarmorPolicies(namespace string) VarmorPolicyInterfac
 	return newVarmorPolicies(c, namespace)
 }
 
+func (c *CrdV1beta1Client) VarmorViolations(namespace string) VarmorViolationInterface {
+	return newVarmorViolations(c, namespace)
+}
+
 // NewForConfig creates a new CrdV1beta1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
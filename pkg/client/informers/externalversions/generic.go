@@ -61,6 +61,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Crd().V1beta1().VarmorClusterPolicies().Informer()}, nil
 	case v1beta1.SchemeGroupVersion.WithResource("varmorpolicies"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Crd().V1beta1().VarmorPolicies().Informer()}, nil
+	case v1beta1.SchemeGroupVersion.WithResource("varmorviolations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Crd().V1beta1().VarmorViolations().Informer()}, nil
 
 	}
 
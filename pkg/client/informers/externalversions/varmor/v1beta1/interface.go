@@ -32,6 +32,8 @@ type Interface interface {
 	VarmorClusterPolicies() VarmorClusterPolicyInformer
 	// VarmorPolicies returns a VarmorPolicyInformer.
 	VarmorPolicies() VarmorPolicyInformer
+	// VarmorViolations returns a VarmorViolationInformer.
+	VarmorViolations() VarmorViolationInformer
 }
 
 type version struct {
@@ -64,3 +66,8 @@ func (v *version) VarmorClusterPolicies() VarmorClusterPolicyInformer {
 func (v *version) VarmorPolicies() VarmorPolicyInformer {
 	return &varmorPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// VarmorViolations returns a VarmorViolationInformer.
+func (v *version) VarmorViolations() VarmorViolationInformer {
+	return &varmorViolationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -45,3 +45,11 @@ type VarmorPolicyListerExpansion interface{}
 // VarmorPolicyNamespaceListerExpansion allows custom methods to be added to
 // VarmorPolicyNamespaceLister.
 type VarmorPolicyNamespaceListerExpansion interface{}
+
+// VarmorViolationListerExpansion allows custom methods to be added to
+// VarmorViolationLister.
+type VarmorViolationListerExpansion interface{}
+
+// VarmorViolationNamespaceListerExpansion allows custom methods to be added to
+// VarmorViolationNamespaceLister.
+type VarmorViolationNamespaceListerExpansion interface{}
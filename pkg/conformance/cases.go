@@ -0,0 +1,238 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	// runLabel selects the test policy and the canary pods of a run
+	runLabel = "conformance.varmor.org/run"
+	// classLabel is the rule class exercised by the canary pod
+	classLabel = "conformance.varmor.org/class"
+	// canaryContainer is the name of the container of the canary pods
+	canaryContainer = "canary"
+	// deniedPattern matches the messages of the operations denied by the BPF enforcer, i.e. EPERM and EACCES
+	deniedPattern = "not permitted|permission denied"
+)
+
+// The exit codes of the canary pods
+const (
+	exitDenied       = 0
+	exitAllowed      = 1
+	exitSetupFailure = 2
+)
+
+// The rule classes exercised by the canary pods
+const (
+	FileClass       = "file"
+	BprmClass       = "bprm"
+	CapabilityClass = "capability"
+	NetworkClass    = "network"
+	MountClass      = "mount"
+	PtraceClass     = "ptrace"
+)
+
+// testCase is a rule class of the test policy, and the operation that the rule must deny in the canary pod
+type testCase struct {
+	class string
+	// ruleType and ruleID identify the violation records of the denied operations. The ruleID is empty if any
+	// rule of the type matches, e.g. the capability rules.
+	ruleType string
+	ruleID   string
+	// privileged runs the canary container as a privileged one, since the operation needs all the capabilities
+	privileged bool
+	// setup prepares the operation, it must succeed
+	setup string
+	// probe performs the operation. It's retried until it's denied, since the profile is applied to the canary
+	// container asynchronously after the container starts.
+	probe string
+}
+
+// testCases are the rule classes that the conformance suite covers, they match the rules of newPolicy
+var testCases = []testCase{
+	{
+		class:    FileClass,
+		ruleType: "file",
+		ruleID:   "bpfRawRules.files/0",
+		setup:    "true",
+		probe:    "echo varmor > /tmp/varmor-conformance-file",
+	},
+	{
+		class:    BprmClass,
+		ruleType: "bprm",
+		ruleID:   "bpfRawRules.processes/0",
+		setup:    "cp /bin/true /tmp/varmor-conformance-exec && chmod +x /tmp/varmor-conformance-exec",
+		probe:    "/tmp/varmor-conformance-exec",
+	},
+	{
+		class:    CapabilityClass,
+		ruleType: "capability",
+		setup:    "touch /tmp/varmor-conformance-chown",
+		probe:    "chown 1000:1000 /tmp/varmor-conformance-chown",
+	},
+	{
+		class:    NetworkClass,
+		ruleType: "network",
+		ruleID:   "bpfRawRules.network.egresses/0",
+		setup:    "true",
+		// The address is reserved for documentation (TEST-NET-1), so the connection times out if it's allowed
+		probe: "nc -w 1 192.0.2.1 80 < /dev/null",
+	},
+	{
+		class:      MountClass,
+		ruleType:   "mount",
+		ruleID:     "bpfRawRules.mounts/0",
+		privileged: true,
+		setup:      "mkdir -p /tmp/varmor-conformance-mnt",
+		probe:      "mount -t tmpfs varmor-conformance /tmp/varmor-conformance-mnt && umount /tmp/varmor-conformance-mnt",
+	},
+	{
+		class:    PtraceClass,
+		ruleType: "ptrace",
+		ruleID:   "bpfRawRules.ptrace",
+		setup:    "true",
+		// The process 1 of the container is the shell that runs the canary script
+		probe: "cat /proc/1/environ",
+	},
+}
+
+// newPolicy generates the test policy, it denies one operation of each rule class in the canary pods of the run
+func newPolicy(namespace, name, runID string) *varmor.VarmorPolicy {
+	return &varmor.VarmorPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{runLabel: runID},
+		},
+		Spec: varmor.VarmorPolicySpec{
+			Target: varmor.Target{
+				Kind:     "Pod",
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{runLabel: runID}},
+			},
+			Policy: varmor.Policy{
+				Enforcer: "BPF",
+				Mode:     "EnhanceProtect",
+				EnhanceProtect: varmor.EnhanceProtect{
+					HardeningRules: []string{"disable-cap-chown"},
+					BpfRawRules: varmor.BpfRawRules{
+						Files: []varmor.FileRule{
+							{Pattern: "/tmp/varmor-conformance-file", Permissions: []string{"write"}},
+						},
+						Processes: []varmor.FileRule{
+							{Pattern: "/tmp/varmor-conformance-exec", Permissions: []string{"exec"}},
+						},
+						Network: varmor.NetworkRule{
+							Egresses: []varmor.NetworkEgressRule{{IP: "192.0.2.1", Port: 80}},
+						},
+						Ptrace: varmor.PtraceRule{
+							StrictMode:  true,
+							Permissions: []string{"read"},
+						},
+						Mounts: []varmor.MountRule{
+							{SourcePattern: "varmor-conformance", Fstype: "tmpfs", Flags: []string{"all"}},
+						},
+					},
+					// The mount rules are only generated for the privileged containers
+					Privileged: true,
+				},
+			},
+		},
+	}
+}
+
+// canaryScript prepares the operation of the case, and performs it until it's denied or the timeout expires.
+// The result is written to the termination log of the container.
+func canaryScript(c *testCase, timeout time.Duration) string {
+	return fmt.Sprintf(`report() { echo "$1" | head -c 1024 > /dev/termination-log; exit "$2"; }
+{ %s; } > /dev/null 2>&1 || report "the setup failed" %d
+deadline=$(( $(date +%%s) + %d ))
+while true; do
+  output=$({ %s; } 2>&1)
+  if echo "$output" | grep -qiE '%s'; then report "$output" %d; fi
+  if [ "$(date +%%s)" -ge "$deadline" ]; then report "the operation wasn't denied: $output" %d; fi
+  sleep 1
+done
+`, c.setup, exitSetupFailure, int(timeout.Seconds()), c.probe, deniedPattern, exitDenied, exitAllowed)
+}
+
+// newCanaryPod generates the pod that runs the canary script of the case on the node. Its labels select it as
+// the target of the test policy, and let the webhook of vArmor mutate it.
+func newCanaryPod(config *Config, c *testCase, runID string) (*corev1.Pod, error) {
+	key, value, found := strings.Cut(config.WebhookMatchLabel, "=")
+	if !found || key == "" {
+		return nil, fmt.Errorf("the match label of the webhook should be like key=value")
+	}
+
+	automount := false
+	privileged := c.privileged
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: config.Namespace,
+			Name:      fmt.Sprintf("varmor-conformance-%s-%s", runID, c.class),
+			Labels: map[string]string{
+				runLabel:   runID,
+				classLabel: c.class,
+				key:        value,
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:                     config.NodeName,
+			RestartPolicy:                corev1.RestartPolicyNever,
+			AutomountServiceAccountToken: &automount,
+			Tolerations:                  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{
+				{
+					Name:            canaryContainer,
+					Image:           config.Image,
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Command:         []string{"/bin/sh", "-c", canaryScript(c, config.Timeout)},
+					SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				},
+			},
+		},
+	}, nil
+}
+
+// selectCases returns the cases of the classes, or all of them if the classes are empty
+func selectCases(classes []string) ([]testCase, error) {
+	if len(classes) == 0 {
+		return testCases, nil
+	}
+
+	var cases []testCase
+	for _, class := range classes {
+		found := false
+		for _, c := range testCases {
+			if c.class == class {
+				cases = append(cases, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the rule class '%s' is unknown", class)
+		}
+	}
+	return cases, nil
+}
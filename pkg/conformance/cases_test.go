@@ -0,0 +1,104 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/profilebuilder"
+)
+
+func Test_newPolicy(t *testing.T) {
+	policy := newPolicy("demo", "varmor-conformance-abcde", "abcde")
+	assert.DeepEqual(t, policy.Spec.Target.Selector.MatchLabels, map[string]string{runLabel: "abcde"})
+
+	var bpfContent varmor.BpfContent
+	err := profilebuilder.Build(&policy.Spec.Policy.EnhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+
+	// The rules of the cases are generated with the rule IDs that the violations are matched with
+	ruleIDs := map[string]bool{}
+	for _, f := range bpfContent.Files {
+		ruleIDs[f.RuleID] = true
+	}
+	for _, p := range bpfContent.Processes {
+		ruleIDs[p.RuleID] = true
+	}
+	for _, n := range bpfContent.Networks {
+		ruleIDs[n.RuleID] = true
+	}
+	for _, m := range bpfContent.Mounts {
+		ruleIDs[m.RuleID] = true
+	}
+	ruleIDs[bpfContent.Ptrace.RuleID] = true
+
+	for _, c := range testCases {
+		if c.ruleID != "" {
+			assert.Assert(t, ruleIDs[c.ruleID], c.class)
+		}
+	}
+	assert.Equal(t, bpfContent.Capabilities, uint64(1))
+}
+
+func Test_newCanaryPod(t *testing.T) {
+	config := Config{NodeName: "node-1", Namespace: "demo"}
+	config.setDefaults()
+
+	pod, err := newCanaryPod(&config, &testCases[4], "abcde")
+	assert.NilError(t, err)
+	assert.Equal(t, pod.Name, "varmor-conformance-abcde-mount")
+	assert.Equal(t, pod.Spec.NodeName, "node-1")
+	assert.DeepEqual(t, pod.Labels, map[string]string{
+		runLabel:                    "abcde",
+		classLabel:                  MountClass,
+		"sandbox.varmor.org/enable": "true",
+	})
+	assert.Assert(t, *pod.Spec.Containers[0].SecurityContext.Privileged)
+	assert.Equal(t, pod.Spec.Containers[0].Image, defaultImage)
+
+	script := pod.Spec.Containers[0].Command[2]
+	assert.Assert(t, strings.Contains(script, "deadline=$(( $(date +%s) + 120 ))"))
+	assert.Assert(t, strings.Contains(script, "output=$({ mount -t tmpfs varmor-conformance"))
+
+	config.WebhookMatchLabel = "nil"
+	_, err = newCanaryPod(&config, &testCases[0], "abcde")
+	assert.ErrorContains(t, err, "key=value")
+}
+
+func Test_canaryScript(t *testing.T) {
+	script := canaryScript(&testCases[0], 90*time.Second)
+	assert.Assert(t, strings.Contains(script, "{ true; } > /dev/null 2>&1 || report \"the setup failed\" 2"))
+	assert.Assert(t, strings.Contains(script, "grep -qiE 'not permitted|permission denied'; then report \"$output\" 0"))
+	assert.Assert(t, strings.Contains(script, "+ 90 ))"))
+}
+
+func Test_selectCases(t *testing.T) {
+	cases, err := selectCases(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(cases), 6)
+
+	cases, err = selectCases([]string{PtraceClass, FileClass})
+	assert.NilError(t, err)
+	assert.Equal(t, cases[0].class, PtraceClass)
+	assert.Equal(t, cases[1].class, FileClass)
+
+	_, err = selectCases([]string{"signal"})
+	assert.ErrorContains(t, err, "is unknown")
+}
@@ -0,0 +1,415 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance certifies that a node of a live cluster enforces the BPF profiles of vArmor, e.g. before
+// rolling out a new kernel or OS image. It creates a test policy with one rule of each rule class (file, bprm,
+// capability, network, mount and ptrace), deploys the canary pods that perform the denied operations to the node,
+// and validates that the operations are denied and the violations are reported. The violations are only
+// validated if the BPF enforcer of the agent on the node emits the violation events, otherwise the rule classes
+// are skipped, which doesn't pass. vArmor must be installed with the BPF enforcer, and the objects created by the
+// run are deleted when it finishes.
+//
+//	report, err := conformance.Run(ctx, kubeClient, varmorClient, conformance.Config{
+//		NodeName:  "node-1",
+//		Namespace: "varmor-conformance",
+//	})
+//	if err != nil { ... }
+//	fmt.Println(report.Passed())
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorinterface "github.com/bytedance/vArmor/pkg/client/clientset/versioned"
+	"github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// defaultImage provides the busybox applets that the canary scripts use
+	defaultImage = "busybox:1.36"
+	// defaultTimeout is the default timeout of each phase of the run
+	defaultTimeout = 2 * time.Minute
+	// defaultWebhookMatchLabel is the default matchLabel of the webhook of vArmor
+	defaultWebhookMatchLabel = "sandbox.varmor.org/enable=true"
+	// pollInterval is the interval of checking the states of the objects
+	pollInterval = 2 * time.Second
+)
+
+// Config configures the conformance run
+type Config struct {
+	// NodeName is the name of the node to certify, the canary pods are pinned to it
+	NodeName string
+	// Namespace is the namespace of the test policy and the canary pods, it must exist
+	Namespace string
+	// Image is the image of the canary pods. It must provide the busybox-compatible sh, cat, cp, chown, mount and
+	// nc. The defaultImage is used if it's empty.
+	Image string
+	// WebhookMatchLabel is the --webhookMatchLabel of the manager, the canary pods are labeled with it. The
+	// defaultWebhookMatchLabel is used if it's empty.
+	WebhookMatchLabel string
+	// Classes are the rule classes to certify, all of them are certified if it's empty
+	Classes []string
+	// Timeout is the timeout of each phase, i.e. waiting for the profile, the denials and the violations. The
+	// defaultTimeout is used if it's zero.
+	Timeout time.Duration
+	// SkipViolations skips validating the violations, e.g. if the agents don't report them to the manager. The
+	// rule classes whose operations are denied are skipped then.
+	SkipViolations bool
+	// Features are the optional features of the BPF enforcer of the agent on the node, i.e. the BpfFeatures of
+	// the inventory reported by the agent. The violations are only validated if the BPF enforcer emits the
+	// violation events. If it's nil, the features are resolved from the compatibility of the test policy which
+	// the manager evaluates with the inventory.
+	Features map[string]bool
+}
+
+func (config *Config) setDefaults() {
+	if config.Image == "" {
+		config.Image = defaultImage
+	}
+	if config.WebhookMatchLabel == "" {
+		config.WebhookMatchLabel = defaultWebhookMatchLabel
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+}
+
+// skipUnsupportedViolations skips validating the violations if the BPF enforcer of the agent on the node doesn't
+// emit the violation events. It returns true if they are skipped for that reason. The violations are validated if
+// the features of the agent are unknown, i.e. the compatibility of the test policy hasn't been evaluated.
+func (config *Config) skipUnsupportedViolations(compatibility *varmor.PolicyCompatibility) bool {
+	if config.SkipViolations {
+		return false
+	}
+
+	unsupported := false
+	if config.Features != nil {
+		unsupported = !config.Features[bpfenforcer.FeatureViolationEvents]
+	} else if compatibility != nil {
+		for _, node := range compatibility.PartialNodes {
+			if node.NodeName != config.NodeName {
+				continue
+			}
+			for _, reason := range node.Reasons {
+				if reason == varmortypes.ViolationEventsUnsupportedReason {
+					unsupported = true
+				}
+			}
+		}
+	}
+
+	if unsupported {
+		config.SkipViolations = true
+	}
+	return unsupported
+}
+
+// Result is the result of a rule class
+type Result struct {
+	Class string
+	Pod   string
+	// Denied is true if the operation was denied in the canary pod
+	Denied bool
+	// Reported is true if the violation of the denied operation was found in the VarmorViolation object
+	Reported bool
+	// Skipped is true if the operation was denied, but its violation wasn't validated. The rule class doesn't
+	// pass then.
+	Skipped bool
+	// Passed is true if the operation was denied, and its violation was reported
+	Passed bool
+	// Message is the output of the denied operation, or why the rule class failed
+	Message string
+}
+
+// Report is the report of the conformance run
+type Report struct {
+	NodeName      string
+	KernelVersion string
+	OSImage       string
+	// ProfileName is the name of the BPF profile of the test policy
+	ProfileName string
+	// ViolationsUnsupported is true if the violations weren't validated since the BPF enforcer of the agent on
+	// the node doesn't emit the violation events
+	ViolationsUnsupported bool
+	Results               []Result
+}
+
+// Passed returns true if all the rule classes passed, i.e. none of them failed or was skipped
+func (r *Report) Passed() bool {
+	for _, result := range r.Results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return len(r.Results) != 0
+}
+
+// Run certifies the node with the canary pods of the rule classes. It returns an error if the run can't be
+// performed, e.g. the test policy isn't ready. The failures of the rule classes are recorded in the report. If
+// the objects of the run failed to be deleted, the report is returned along with the error.
+func Run(ctx context.Context, kubeClient kubernetes.Interface, varmorClient varmorinterface.Interface, config Config) (_ *Report, err error) {
+	config.setDefaults()
+	if config.NodeName == "" || config.Namespace == "" {
+		return nil, fmt.Errorf("the node name and the namespace are required")
+	}
+
+	cases, err := selectCases(config.Classes)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := kubeClient.CoreV1().Nodes().Get(ctx, config.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the node: %w", err)
+	}
+	report := Report{
+		NodeName:      node.Name,
+		KernelVersion: node.Status.NodeInfo.KernelVersion,
+		OSImage:       node.Status.NodeInfo.OSImage,
+	}
+
+	runID := utilrand.String(5)
+	policy := newPolicy(config.Namespace, "varmor-conformance-"+runID, runID)
+	_, err = varmorClient.CrdV1beta1().VarmorPolicies(config.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the test policy: %w", err)
+	}
+	defer func() {
+		cleanUpErr := cleanUp(kubeClient, varmorClient, config.Namespace, policy.Name, runID)
+		if err == nil {
+			err = cleanUpErr
+		}
+	}()
+
+	var compatibility *varmor.PolicyCompatibility
+	report.ProfileName, compatibility, err = waitForPolicy(ctx, varmorClient, &config, policy.Name)
+	if err != nil {
+		return nil, err
+	}
+	report.ViolationsUnsupported = config.skipUnsupportedViolations(compatibility)
+
+	results := make([]Result, len(cases))
+	for i := range cases {
+		results[i] = createCanaryPod(ctx, kubeClient, &config, &cases[i], runID, report.ProfileName)
+	}
+
+	waitForCanaryPods(ctx, kubeClient, &config, results)
+
+	if !config.SkipViolations {
+		waitForViolations(ctx, varmorClient, &config, report.ProfileName, cases, results)
+	}
+
+	for i := range results {
+		results[i].Skipped = results[i].Denied && config.SkipViolations
+		results[i].Passed = results[i].Denied && results[i].Reported
+	}
+	report.Results = results
+	return &report, nil
+}
+
+// waitForPolicy waits for the BPF profile of the test policy to be loaded, and returns the name of the profile and
+// the compatibility of the policy
+func waitForPolicy(ctx context.Context, varmorClient varmorinterface.Interface, config *Config, name string) (string, *varmor.PolicyCompatibility, error) {
+	var profileName string
+	var compatibility *varmor.PolicyCompatibility
+	err := wait.PollUntilContextTimeout(ctx, pollInterval, config.Timeout, true, func(ctx context.Context) (bool, error) {
+		vp, err := varmorClient.CrdV1beta1().VarmorPolicies(config.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		if vp.Status.Phase == "Error" {
+			message := ""
+			for _, c := range vp.Status.Conditions {
+				if c.Status == corev1.ConditionFalse && c.Message != "" {
+					message = c.Message
+				}
+			}
+			return false, fmt.Errorf("the test policy failed: %s", message)
+		}
+
+		if vp.Status.Compatibility != nil {
+			for _, n := range vp.Status.Compatibility.UnsupportedNodes {
+				if n.NodeName == config.NodeName {
+					return false, fmt.Errorf("the node can't enforce the test policy: %v", n.Reasons)
+				}
+			}
+		}
+
+		profileName = vp.Status.ProfileName
+		compatibility = vp.Status.Compatibility
+		return vp.Status.Ready, nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to wait for the test policy: %w", err)
+	}
+	return profileName, compatibility, nil
+}
+
+// createCanaryPod creates the canary pod of the case, and checks that the webhook of vArmor mutated it with
+// the profile of the test policy
+func createCanaryPod(ctx context.Context, kubeClient kubernetes.Interface, config *Config, c *testCase, runID string, profileName string) Result {
+	result := Result{Class: c.class}
+
+	pod, err := newCanaryPod(config, c, runID)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.Pod = pod.Name
+
+	pod, err = kubeClient.CoreV1().Pods(config.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		result.Message = fmt.Sprintf("failed to create the canary pod: %v", err)
+		return result
+	}
+
+	key := "container.bpf.security.beta.varmor.org/" + canaryContainer
+	if pod.Annotations[key] != "localhost/"+profileName {
+		result.Message = "the canary pod wasn't mutated by the webhook of vArmor"
+	}
+	return result
+}
+
+// canaryPodResult returns whether the operation was denied in the canary pod, and the output of it. It returns
+// false if the canary pod hasn't finished.
+func canaryPodResult(pod *corev1.Pod) (finished bool, denied bool, message string) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != canaryContainer {
+			continue
+		}
+
+		if status.State.Terminated == nil {
+			if status.State.Waiting != nil {
+				message = fmt.Sprintf("the canary container is waiting (%s)", status.State.Waiting.Reason)
+			}
+			return false, false, message
+		}
+
+		terminated := status.State.Terminated
+		switch terminated.ExitCode {
+		case exitDenied:
+			return true, true, terminated.Message
+		case exitAllowed:
+			return true, false, terminated.Message
+		case exitSetupFailure:
+			return true, false, "the setup of the operation failed, the image may not provide the required commands"
+		default:
+			return true, false, fmt.Sprintf("the canary container exited with %d (%s)", terminated.ExitCode, terminated.Reason)
+		}
+	}
+	return false, false, "the canary container hasn't started"
+}
+
+// waitForCanaryPods waits for the canary pods to finish, and records the results of them. Each canary pod
+// performs the operation for the timeout at most, so the startup of the pods is also given the timeout.
+func waitForCanaryPods(ctx context.Context, kubeClient kubernetes.Interface, config *Config, results []Result) {
+	pending := make(map[int]bool)
+	for i := range results {
+		if results[i].Message == "" {
+			pending[i] = true
+		}
+	}
+
+	_ = wait.PollUntilContextTimeout(ctx, pollInterval, 2*config.Timeout, true, func(ctx context.Context) (bool, error) {
+		for i := range pending {
+			pod, err := kubeClient.CoreV1().Pods(config.Namespace).Get(ctx, results[i].Pod, metav1.GetOptions{})
+			if err != nil {
+				results[i].Message = fmt.Sprintf("failed to get the canary pod: %v", err)
+				continue
+			}
+
+			var finished bool
+			finished, results[i].Denied, results[i].Message = canaryPodResult(pod)
+			if finished {
+				delete(pending, i)
+			}
+		}
+		return len(pending) == 0, nil
+	})
+
+	for i := range pending {
+		results[i].Message = fmt.Sprintf("timed out waiting for the canary pod: %s", results[i].Message)
+	}
+}
+
+// matchViolation checks whether the violation of the case in the canary pod is in the records
+func matchViolation(records []varmor.ViolationRecord, c *testCase, namespace string, podName string) bool {
+	for _, r := range records {
+		if r.RuleType != c.ruleType || r.Namespace != namespace || r.Workload != "Pod/"+podName {
+			continue
+		}
+		if c.ruleID == "" || r.RuleID == c.ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForViolations waits for the violations of the denied operations to be reported to the VarmorViolation
+// object of the test policy
+func waitForViolations(ctx context.Context, varmorClient varmorinterface.Interface, config *Config, profileName string, cases []testCase, results []Result) {
+	_ = wait.PollUntilContextTimeout(ctx, pollInterval, config.Timeout, true, func(ctx context.Context) (bool, error) {
+		vv, err := varmorClient.CrdV1beta1().VarmorViolations(config.Namespace).Get(ctx, profileName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		done := true
+		for i := range results {
+			if !results[i].Denied || results[i].Reported {
+				continue
+			}
+			results[i].Reported = matchViolation(vv.Records, &cases[i], config.Namespace, results[i].Pod)
+			done = done && results[i].Reported
+		}
+		return done, nil
+	})
+
+	for i := range results {
+		if results[i].Denied && !results[i].Reported {
+			results[i].Message = "the violation of the denied operation wasn't reported"
+		}
+	}
+}
+
+// cleanUp deletes the canary pods and the test policy of the run
+func cleanUp(kubeClient kubernetes.Interface, varmorClient varmorinterface.Interface, namespace string, policyName string, runID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := kubeClient.CoreV1().Pods(namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: runLabel + "=" + runID,
+	})
+	if err != nil && !k8errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the canary pods: %w", err)
+	}
+
+	err = varmorClient.CrdV1beta1().VarmorPolicies(namespace).Delete(ctx, policyName, metav1.DeleteOptions{})
+	if err != nil && !k8errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the test policy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorfake "github.com/bytedance/vArmor/pkg/client/clientset/versioned/fake"
+	"github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_canaryPodResult(t *testing.T) {
+	pod := corev1.Pod{}
+	finished, _, message := canaryPodResult(&pod)
+	assert.Assert(t, !finished)
+	assert.Equal(t, message, "the canary container hasn't started")
+
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:  canaryContainer,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"}},
+		},
+	}
+	finished, _, message = canaryPodResult(&pod)
+	assert.Assert(t, !finished)
+	assert.Equal(t, message, "the canary container is waiting (ErrImagePull)")
+
+	terminated := &corev1.ContainerStateTerminated{ExitCode: exitDenied, Message: "sh: can't create /tmp/varmor-conformance-file: Operation not permitted"}
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Terminated: terminated}
+	finished, denied, message := canaryPodResult(&pod)
+	assert.Assert(t, finished && denied)
+	assert.Equal(t, message, terminated.Message)
+
+	terminated.ExitCode = exitAllowed
+	_, denied, _ = canaryPodResult(&pod)
+	assert.Assert(t, !denied)
+
+	terminated.ExitCode = exitSetupFailure
+	_, denied, message = canaryPodResult(&pod)
+	assert.Assert(t, !denied)
+	assert.Assert(t, strings.HasPrefix(message, "the setup of the operation failed"))
+}
+
+func Test_matchViolation(t *testing.T) {
+	records := []varmor.ViolationRecord{
+		{RuleID: "bpfRawRules.files/0", RuleType: "file", Namespace: "demo", Workload: "Pod/varmor-conformance-abcde-file"},
+		{RuleType: "capability", Capability: "chown", Namespace: "demo", Workload: "Pod/varmor-conformance-abcde-capability"},
+	}
+
+	assert.Assert(t, matchViolation(records, &testCases[0], "demo", "varmor-conformance-abcde-file"))
+	assert.Assert(t, !matchViolation(records, &testCases[0], "demo", "varmor-conformance-fghij-file"))
+	assert.Assert(t, !matchViolation(records, &testCases[0], "other", "varmor-conformance-abcde-file"))
+	assert.Assert(t, matchViolation(records, &testCases[2], "demo", "varmor-conformance-abcde-capability"))
+	assert.Assert(t, !matchViolation(records, &testCases[1], "demo", "varmor-conformance-abcde-file"))
+}
+
+func Test_skipUnsupportedViolations(t *testing.T) {
+	config := Config{NodeName: "node-1", Features: map[string]bool{bpfenforcer.FeatureViolationEvents: true}}
+	assert.Assert(t, !config.skipUnsupportedViolations(nil))
+	assert.Assert(t, !config.SkipViolations)
+
+	config = Config{NodeName: "node-1", Features: map[string]bool{}}
+	assert.Assert(t, config.skipUnsupportedViolations(nil))
+	assert.Assert(t, config.SkipViolations)
+
+	// The features of the agent are resolved from the compatibility of the test policy
+	compatibility := &varmor.PolicyCompatibility{
+		PartialNodes: []varmor.NodeCompatibility{
+			{NodeName: "node-1", Reasons: []string{varmortypes.ViolationEventsUnsupportedReason}},
+		},
+	}
+	config = Config{NodeName: "node-1"}
+	assert.Assert(t, config.skipUnsupportedViolations(compatibility))
+	assert.Assert(t, config.SkipViolations)
+
+	config = Config{NodeName: "node-2"}
+	assert.Assert(t, !config.skipUnsupportedViolations(compatibility))
+	assert.Assert(t, !config.SkipViolations)
+
+	// The violations are validated if the features of the agent are unknown
+	config = Config{NodeName: "node-1"}
+	assert.Assert(t, !config.skipUnsupportedViolations(nil))
+	assert.Assert(t, !config.SkipViolations)
+
+	// The violations skipped by the user aren't reported as unsupported
+	config = Config{NodeName: "node-1", SkipViolations: true, Features: map[string]bool{}}
+	assert.Assert(t, !config.skipUnsupportedViolations(nil))
+	assert.Assert(t, config.SkipViolations)
+}
+
+func Test_ReportPassed(t *testing.T) {
+	report := Report{}
+	assert.Assert(t, !report.Passed())
+
+	report.Results = []Result{{Class: FileClass, Passed: true}, {Class: MountClass, Passed: true}}
+	assert.Assert(t, report.Passed())
+
+	report.Results[1].Passed = false
+	assert.Assert(t, !report.Passed())
+
+	// The skipped rule classes don't pass
+	report.Results[1].Skipped = true
+	assert.Assert(t, !report.Passed())
+}
+
+func Test_waitForPolicy(t *testing.T) {
+	vp := newPolicy("demo", "varmor-conformance-abcde", "abcde")
+	vp.Status.Ready = true
+	vp.Status.ProfileName = "varmor-demo-varmor-conformance-abcde"
+	client := varmorfake.NewSimpleClientset(vp)
+	config := Config{NodeName: "node-1", Namespace: "demo", Timeout: time.Second}
+
+	profileName, compatibility, err := waitForPolicy(context.Background(), client, &config, vp.Name)
+	assert.NilError(t, err)
+	assert.Equal(t, profileName, "varmor-demo-varmor-conformance-abcde")
+	assert.Assert(t, compatibility == nil)
+
+	vp.Status.Ready = false
+	vp.Status.Compatibility = &varmor.PolicyCompatibility{
+		UnsupportedNodes: []varmor.NodeCompatibility{{NodeName: "node-1", Reasons: []string{"the BPF LSM is disabled"}}},
+	}
+	_, err = client.CrdV1beta1().VarmorPolicies("demo").Update(context.Background(), vp, metav1.UpdateOptions{})
+	assert.NilError(t, err)
+	_, _, err = waitForPolicy(context.Background(), client, &config, vp.Name)
+	assert.ErrorContains(t, err, "the node can't enforce the test policy")
+
+	vp.Status.Compatibility = nil
+	vp.Status.Phase = "Error"
+	vp.Status.Conditions = []varmor.VarmorPolicyCondition{
+		{Type: "Ready", Status: corev1.ConditionFalse, Message: "the profile failed to load"},
+	}
+	_, err = client.CrdV1beta1().VarmorPolicies("demo").Update(context.Background(), vp, metav1.UpdateOptions{})
+	assert.NilError(t, err)
+	_, _, err = waitForPolicy(context.Background(), client, &config, vp.Name)
+	assert.ErrorContains(t, err, "the profile failed to load")
+}
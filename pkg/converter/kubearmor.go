@@ -0,0 +1,306 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package converter translates the policies of other enforcement engines and the security standards into
+// the vArmor policies.
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	kubeArmorPolicyKind = "KubeArmorPolicy"
+	kubeArmorBlock      = "Block"
+)
+
+// KubeArmorSource is the fromSource field of the KubeArmor rules
+type KubeArmorSource struct {
+	Path string `json:"path,omitempty"`
+}
+
+// KubeArmorMatchPath matches the specific path of the KubeArmor process or file rules
+type KubeArmorMatchPath struct {
+	Path       string            `json:"path"`
+	ReadOnly   bool              `json:"readOnly,omitempty"`
+	OwnerOnly  bool              `json:"ownerOnly,omitempty"`
+	FromSource []KubeArmorSource `json:"fromSource,omitempty"`
+	Action     string            `json:"action,omitempty"`
+}
+
+// KubeArmorMatchDirectory matches the directory of the KubeArmor process or file rules
+type KubeArmorMatchDirectory struct {
+	Dir        string            `json:"dir"`
+	Recursive  bool              `json:"recursive,omitempty"`
+	ReadOnly   bool              `json:"readOnly,omitempty"`
+	OwnerOnly  bool              `json:"ownerOnly,omitempty"`
+	FromSource []KubeArmorSource `json:"fromSource,omitempty"`
+	Action     string            `json:"action,omitempty"`
+}
+
+// KubeArmorMatchPattern matches the glob pattern of the KubeArmor process or file rules
+type KubeArmorMatchPattern struct {
+	Pattern   string `json:"pattern"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+	OwnerOnly bool   `json:"ownerOnly,omitempty"`
+	Action    string `json:"action,omitempty"`
+}
+
+// KubeArmorPathRules are the process or file rules of KubeArmor
+type KubeArmorPathRules struct {
+	MatchPaths       []KubeArmorMatchPath      `json:"matchPaths,omitempty"`
+	MatchDirectories []KubeArmorMatchDirectory `json:"matchDirectories,omitempty"`
+	MatchPatterns    []KubeArmorMatchPattern   `json:"matchPatterns,omitempty"`
+	Action           string                    `json:"action,omitempty"`
+}
+
+// KubeArmorMatchProtocol matches the protocol of the KubeArmor network rules
+type KubeArmorMatchProtocol struct {
+	Protocol   string            `json:"protocol"`
+	FromSource []KubeArmorSource `json:"fromSource,omitempty"`
+	Action     string            `json:"action,omitempty"`
+}
+
+// KubeArmorNetworkRules are the network rules of KubeArmor
+type KubeArmorNetworkRules struct {
+	MatchProtocols []KubeArmorMatchProtocol `json:"matchProtocols,omitempty"`
+	Action         string                   `json:"action,omitempty"`
+}
+
+// KubeArmorMatchCapability matches the capability of the KubeArmor capabilities rules
+type KubeArmorMatchCapability struct {
+	Capability string            `json:"capability"`
+	FromSource []KubeArmorSource `json:"fromSource,omitempty"`
+	Action     string            `json:"action,omitempty"`
+}
+
+// KubeArmorCapabilitiesRules are the capabilities rules of KubeArmor
+type KubeArmorCapabilitiesRules struct {
+	MatchCapabilities []KubeArmorMatchCapability `json:"matchCapabilities,omitempty"`
+	Action            string                     `json:"action,omitempty"`
+}
+
+// KubeArmorPolicySpec is the subset of the spec of KubeArmorPolicy (security.kubearmor.com/v1) that the
+// converter understands
+type KubeArmorPolicySpec struct {
+	Selector     metav1.LabelSelector       `json:"selector"`
+	Process      KubeArmorPathRules         `json:"process,omitempty"`
+	File         KubeArmorPathRules         `json:"file,omitempty"`
+	Network      KubeArmorNetworkRules      `json:"network,omitempty"`
+	Capabilities KubeArmorCapabilitiesRules `json:"capabilities,omitempty"`
+	Syscalls     json.RawMessage            `json:"syscalls,omitempty"`
+	Action       string                     `json:"action,omitempty"`
+}
+
+// KubeArmorPolicy is the KubeArmorPolicy object of KubeArmor
+type KubeArmorPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              KubeArmorPolicySpec `json:"spec"`
+}
+
+// ParseKubeArmorPolicy parses the KubeArmorPolicy object in YAML or JSON format
+func ParseKubeArmorPolicy(data []byte) (*KubeArmorPolicy, error) {
+	var kp KubeArmorPolicy
+	err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096).Decode(&kp)
+	if err != nil {
+		return nil, err
+	}
+	if kp.Kind != kubeArmorPolicyKind {
+		return nil, fmt.Errorf("the kind of the object is '%s' rather than %s", kp.Kind, kubeArmorPolicyKind)
+	}
+	return &kp, nil
+}
+
+// resolveAction returns the action of the rule, which inherits the action of its section and the policy
+func resolveAction(actions ...string) string {
+	for _, action := range actions {
+		if action != "" {
+			return action
+		}
+	}
+	return kubeArmorBlock
+}
+
+type converter struct {
+	enhanceProtect *varmor.EnhanceProtect
+	warnings       []string
+}
+
+func (c *converter) warn(format string, a ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, a...))
+}
+
+func (c *converter) addHardeningRule(rule string) {
+	for _, r := range c.enhanceProtect.HardeningRules {
+		if r == rule {
+			return
+		}
+	}
+	c.enhanceProtect.HardeningRules = append(c.enhanceProtect.HardeningRules, rule)
+}
+
+// skipped returns true and records the warning if the rule can't be converted
+func (c *converter) skipped(section, match, action string, ownerOnly bool, fromSource []KubeArmorSource) bool {
+	switch {
+	case action != kubeArmorBlock:
+		c.warn("%s rule '%s' is skipped: the %s action isn't supported, only the Block action can be converted", section, match, action)
+	case ownerOnly:
+		c.warn("%s rule '%s' is skipped: ownerOnly isn't supported", section, match)
+	case len(fromSource) != 0:
+		c.warn("%s rule '%s' is skipped: fromSource isn't supported", section, match)
+	default:
+		return false
+	}
+	return true
+}
+
+// directoryPattern converts the directory of KubeArmor into the pattern of vArmor
+func directoryPattern(dir string, recursive bool) string {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	if recursive {
+		return dir + "**"
+	}
+	return dir + "*"
+}
+
+func (c *converter) convertPathRules(section string, rules *KubeArmorPathRules, policyAction string, convert func(pattern string, readOnly bool)) {
+	for _, m := range rules.MatchPaths {
+		if !c.skipped(section, m.Path, resolveAction(m.Action, rules.Action, policyAction), m.OwnerOnly, m.FromSource) {
+			convert(m.Path, m.ReadOnly)
+		}
+	}
+	for _, m := range rules.MatchDirectories {
+		if !c.skipped(section, m.Dir, resolveAction(m.Action, rules.Action, policyAction), m.OwnerOnly, m.FromSource) {
+			convert(directoryPattern(m.Dir, m.Recursive), m.ReadOnly)
+		}
+	}
+	for _, m := range rules.MatchPatterns {
+		if !c.skipped(section, m.Pattern, resolveAction(m.Action, rules.Action, policyAction), m.OwnerOnly, nil) {
+			convert(m.Pattern, m.ReadOnly)
+		}
+	}
+}
+
+func (c *converter) convertProcessRules(spec *KubeArmorPolicySpec) {
+	c.convertPathRules("process", &spec.Process, spec.Action, func(pattern string, readOnly bool) {
+		c.enhanceProtect.BpfRawRules.Processes = append(c.enhanceProtect.BpfRawRules.Processes, varmor.FileRule{
+			Pattern:     pattern,
+			Permissions: []string{"exec"},
+		})
+	})
+}
+
+func (c *converter) convertFileRules(spec *KubeArmorPolicySpec) {
+	c.convertPathRules("file", &spec.File, spec.Action, func(pattern string, readOnly bool) {
+		permissions := []string{"read", "write"}
+		if readOnly {
+			// The read-only rule of KubeArmor only blocks the writes
+			permissions = []string{"write"}
+		}
+		c.enhanceProtect.BpfRawRules.Files = append(c.enhanceProtect.BpfRawRules.Files, varmor.FileRule{
+			Pattern:     pattern,
+			Permissions: permissions,
+		})
+	})
+}
+
+func (c *converter) convertNetworkRules(spec *KubeArmorPolicySpec) {
+	for _, m := range spec.Network.MatchProtocols {
+		if c.skipped("network", m.Protocol, resolveAction(m.Action, spec.Network.Action, spec.Action), false, m.FromSource) {
+			continue
+		}
+
+		switch strings.ToLower(m.Protocol) {
+		case "raw":
+			// The raw sockets can only be created with CAP_NET_RAW
+			c.addHardeningRule("disable-cap-net-raw")
+			c.warn("network rule 'raw' is converted approximately: CAP_NET_RAW is disabled instead")
+		default:
+			c.warn("network rule '%s' is skipped: only the raw protocol can be converted", m.Protocol)
+		}
+	}
+}
+
+func (c *converter) convertCapabilitiesRules(spec *KubeArmorPolicySpec) {
+	for _, m := range spec.Capabilities.MatchCapabilities {
+		if c.skipped("capabilities", m.Capability, resolveAction(m.Action, spec.Capabilities.Action, spec.Action), false, m.FromSource) {
+			continue
+		}
+
+		capability := strings.ToLower(m.Capability)
+		capability = strings.TrimPrefix(capability, "cap_")
+		capability = strings.ReplaceAll(capability, "_", "-")
+		c.addHardeningRule("disable-cap-" + capability)
+	}
+}
+
+// ConvertKubeArmorPolicy converts the KubeArmorPolicy object into the VarmorPolicy object, which uses the BPF
+// enforcer and the EnhanceProtect mode. The pods selected by the KubeArmorPolicy object are matched with the
+// workloads of the kind.
+//
+// Only the rules whose semantics overlap with vArmor are converted. KubeArmor allows the behaviors that aren't
+// blocked by default, so only the rules with the Block action can be converted into the deny rules of vArmor.
+// The returned warnings describe the rules that are skipped or converted approximately.
+func ConvertKubeArmorPolicy(kp *KubeArmorPolicy, kind string) (*varmor.VarmorPolicy, []string) {
+	c := converter{
+		enhanceProtect: &varmor.EnhanceProtect{},
+	}
+
+	c.convertProcessRules(&kp.Spec)
+	c.convertFileRules(&kp.Spec)
+	c.convertNetworkRules(&kp.Spec)
+	c.convertCapabilitiesRules(&kp.Spec)
+	if len(kp.Spec.Syscalls) != 0 {
+		c.warn("syscalls rules are skipped: they only audit the syscalls in KubeArmor")
+	}
+	if len(kp.Spec.Selector.MatchExpressions) != 0 {
+		c.warn("the matchExpressions of the selector are converted as they are, please make sure they match the labels of the %s objects", kind)
+	}
+
+	selector := kp.Spec.Selector.DeepCopy()
+	vp := &varmor.VarmorPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: varmor.GroupVersion.String(),
+			Kind:       "VarmorPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kp.Name,
+			Namespace: kp.Namespace,
+		},
+		Spec: varmor.VarmorPolicySpec{
+			Target: varmor.Target{
+				Kind:     kind,
+				Selector: selector,
+			},
+			Policy: varmor.Policy{
+				Enforcer:       "BPF",
+				Mode:           "EnhanceProtect",
+				EnhanceProtect: *c.enhanceProtect,
+			},
+		},
+	}
+
+	return vp, c.warnings
+}
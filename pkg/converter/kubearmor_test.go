@@ -0,0 +1,138 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_ConvertKubeArmorPolicy(t *testing.T) {
+	testCases := []struct {
+		name             string
+		policy           string
+		expectedRules    varmor.EnhanceProtect
+		expectedWarnings int
+	}{
+		{
+			name: "block",
+			policy: `
+apiVersion: security.kubearmor.com/v1
+kind: KubeArmorPolicy
+metadata:
+  name: block-all
+  namespace: demo
+spec:
+  selector:
+    matchLabels:
+      app: nginx
+  process:
+    matchPaths:
+    - path: /usr/bin/apt
+    matchDirectories:
+    - dir: /tmp/
+      recursive: true
+  file:
+    matchPaths:
+    - path: /etc/shadow
+    - path: /etc/passwd
+      readOnly: true
+    matchDirectories:
+    - dir: /root
+  capabilities:
+    matchCapabilities:
+    - capability: sys_admin
+    - capability: CAP_SYS_ADMIN
+  network:
+    matchProtocols:
+    - protocol: raw
+  action: Block
+`,
+			expectedRules: varmor.EnhanceProtect{
+				HardeningRules: []string{"disable-cap-net-raw", "disable-cap-sys-admin"},
+				BpfRawRules: varmor.BpfRawRules{
+					Processes: []varmor.FileRule{
+						{Pattern: "/usr/bin/apt", Permissions: []string{"exec"}},
+						{Pattern: "/tmp/**", Permissions: []string{"exec"}},
+					},
+					Files: []varmor.FileRule{
+						{Pattern: "/etc/shadow", Permissions: []string{"read", "write"}},
+						{Pattern: "/etc/passwd", Permissions: []string{"write"}},
+						{Pattern: "/root/*", Permissions: []string{"read", "write"}},
+					},
+				},
+			},
+			expectedWarnings: 1,
+		},
+		{
+			name: "unsupported",
+			policy: `
+apiVersion: security.kubearmor.com/v1
+kind: KubeArmorPolicy
+metadata:
+  name: mixed
+spec:
+  selector:
+    matchLabels:
+      app: nginx
+  process:
+    matchPaths:
+    - path: /bin/sleep
+      action: Allow
+    - path: /bin/bash
+      fromSource:
+      - path: /bin/sh
+  file:
+    matchPaths:
+    - path: /etc/hosts
+      ownerOnly: true
+    action: Audit
+  network:
+    matchProtocols:
+    - protocol: tcp
+  syscalls:
+    matchSyscalls:
+    - syscall:
+      - unlink
+  action: Block
+`,
+			expectedRules:    varmor.EnhanceProtect{},
+			expectedWarnings: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kp, err := ParseKubeArmorPolicy([]byte(tc.policy))
+			assert.NilError(t, err)
+
+			vp, warnings := ConvertKubeArmorPolicy(kp, "Deployment")
+			assert.Equal(t, vp.Name, kp.Name)
+			assert.Equal(t, vp.Spec.Target.Kind, "Deployment")
+			assert.DeepEqual(t, vp.Spec.Target.Selector.MatchLabels, map[string]string{"app": "nginx"})
+			assert.Equal(t, vp.Spec.Policy.Enforcer, "BPF")
+			assert.DeepEqual(t, vp.Spec.Policy.EnhanceProtect, tc.expectedRules)
+			assert.Equal(t, len(warnings), tc.expectedWarnings, warnings)
+		})
+	}
+}
+
+func Test_ParseKubeArmorPolicy(t *testing.T) {
+	_, err := ParseKubeArmorPolicy([]byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "test"}}`))
+	assert.Assert(t, err != nil)
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	// PSSBaseline is the baseline level of Pod Security Standards
+	PSSBaseline = "baseline"
+	// PSSRestricted is the restricted level of Pod Security Standards
+	PSSRestricted = "restricted"
+)
+
+// defaultCapabilities are the capabilities that the container runtime grants containers by default,
+// they are the only capabilities allowed by the baseline level.
+var defaultCapabilities = []string{
+	"audit-write", "chown", "dac-override", "fowner", "fsetid", "kill", "mknod",
+	"net-bind-service", "setfcap", "setgid", "setpcap", "setuid", "sys-chroot",
+}
+
+// pssBaselineHardeningRules enforce the baseline level at runtime:
+//   - Privileged containers and added capabilities: only the default capabilities are allowed.
+//   - Host namespaces and /proc mount type: the procfs of the host and the masked paths can't be accessed or mounted.
+//   - HostPath volumes: the host disks can't be mounted or written directly.
+var pssBaselineHardeningRules = []string{
+	"disable-cap-privileged",
+	"disallow-write-core-pattern",
+	"disallow-mount-securityfs",
+	"disallow-mount-procfs",
+	"disallow-write-release-agent",
+	"disallow-mount-cgroupfs",
+	"disallow-debug-disk-device",
+	"disallow-mount-disk-device",
+	"disallow-access-procfs-root",
+	"disallow-insmod",
+	"disallow-load-ebpf",
+}
+
+// pssRestrictedHardeningRules enforce the restricted level at runtime in addition to the baseline level:
+//   - Seccomp: the mount operations and the user namespaces blocked by the RuntimeDefault profile are disallowed.
+var pssRestrictedHardeningRules = []string{
+	"disallow-mount",
+	"disallow-umount",
+	"disallow-abuse-user-ns",
+}
+
+// pssRestrictedAttackProtectionRules enforce the restricted level at runtime:
+//   - Privilege escalation: the setuid programs used for escalating privileges can't be executed.
+var pssRestrictedAttackProtectionRules = []string{
+	"disable-su-sudo",
+}
+
+// GeneratePSSPolicy generates the VarmorClusterPolicy object which enforces the requirements of the Pod Security
+// Standards level (baseline or restricted) at runtime with the built-in rules of vArmor. The policy uses the
+// EnhanceProtect mode and the enforcer, and it protects the workloads of the target.
+func GeneratePSSPolicy(level string, enforcer string, target varmor.Target) (*varmor.VarmorClusterPolicy, error) {
+	var enhanceProtect varmor.EnhanceProtect
+
+	switch level {
+	case PSSBaseline:
+		enhanceProtect.HardeningRules = append(enhanceProtect.HardeningRules, pssBaselineHardeningRules...)
+	case PSSRestricted:
+		enhanceProtect.HardeningRules = append(enhanceProtect.HardeningRules, pssBaselineHardeningRules...)
+		enhanceProtect.HardeningRules = append(enhanceProtect.HardeningRules, pssRestrictedHardeningRules...)
+		// The restricted level drops all capabilities except NET_BIND_SERVICE
+		for _, capability := range defaultCapabilities {
+			if capability != "net-bind-service" {
+				enhanceProtect.HardeningRules = append(enhanceProtect.HardeningRules, "disable-cap-"+capability)
+			}
+		}
+		enhanceProtect.AttackProtectionRules = []varmor.AttackProtectionRules{
+			{
+				Rules: append([]string{}, pssRestrictedAttackProtectionRules...),
+			},
+		}
+	default:
+		return nil, fmt.Errorf("the level '%s' of Pod Security Standards is invalid, available values: %s, %s", level, PSSBaseline, PSSRestricted)
+	}
+
+	if !strings.Contains(enforcer, "AppArmor") && !strings.Contains(enforcer, "BPF") {
+		return nil, fmt.Errorf("the enforcer '%s' is invalid, the built-in rules of Pod Security Standards require AppArmor or BPF", enforcer)
+	}
+
+	vcp := &varmor.VarmorClusterPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: varmor.GroupVersion.String(),
+			Kind:       "VarmorClusterPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pss-" + level,
+		},
+		Spec: varmor.VarmorPolicySpec{
+			Target: target,
+			Policy: varmor.Policy{
+				Enforcer:       enforcer,
+				Mode:           "EnhanceProtect",
+				EnhanceProtect: enhanceProtect,
+			},
+		},
+	}
+
+	return vcp, nil
+}
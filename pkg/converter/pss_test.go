@@ -0,0 +1,75 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_GeneratePSSPolicy(t *testing.T) {
+	testCases := []struct {
+		name                       string
+		level                      string
+		enforcer                   string
+		expectedErr                bool
+		expectedHardeningRules     int
+		expectedAttackProtectRules int
+	}{
+		{
+			name:                   "baseline",
+			level:                  PSSBaseline,
+			enforcer:               "AppArmor",
+			expectedHardeningRules: len(pssBaselineHardeningRules),
+		},
+		{
+			name:                       "restricted",
+			level:                      PSSRestricted,
+			enforcer:                   "BPF",
+			expectedHardeningRules:     len(pssBaselineHardeningRules) + len(pssRestrictedHardeningRules) + len(defaultCapabilities) - 1,
+			expectedAttackProtectRules: 1,
+		},
+		{
+			name:        "invalidLevel",
+			level:       "privileged",
+			enforcer:    "BPF",
+			expectedErr: true,
+		},
+		{
+			name:        "invalidEnforcer",
+			level:       PSSBaseline,
+			enforcer:    "Seccomp",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vcp, err := GeneratePSSPolicy(tc.level, tc.enforcer, varmor.Target{Kind: "Pod"})
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, vcp.Name, "pss-"+tc.level)
+			assert.Equal(t, vcp.Spec.Policy.Enforcer, tc.enforcer)
+			assert.Equal(t, len(vcp.Spec.Policy.EnhanceProtect.HardeningRules), tc.expectedHardeningRules)
+			assert.Equal(t, len(vcp.Spec.Policy.EnhanceProtect.AttackProtectionRules), tc.expectedAttackProtectRules)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"expvar"
+	"time"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// defaultViolationAggregationWindow is the default window of aggregating the identical violations
+	defaultViolationAggregationWindow = 10 * time.Second
+	// maxViolationAggregates caps the violations being aggregated, the others are reported without aggregation
+	maxViolationAggregates = 4096
+)
+
+var aggregatedViolationCount = new(expvar.Int)
+
+func init() {
+	metrics.Set("aggregated_violations_total", aggregatedViolationCount)
+}
+
+// violationAggregateKey identifies the identical violations, they are of the same mnt ns, rule and operation
+type violationAggregateKey struct {
+	mntNsID     uint32
+	ruleType    string
+	ruleID      string
+	permissions uint32
+	capability  int32
+	syscall     int32
+}
+
+// violationAggregate is the identical violations that occurred after the first one in the window
+type violationAggregate struct {
+	violation *varmortypes.Violation
+	deadline  time.Time
+}
+
+func newViolationAggregateKey(v *varmortypes.Violation) violationAggregateKey {
+	return violationAggregateKey{
+		mntNsID:     v.MntNsID,
+		ruleType:    v.RuleType,
+		ruleID:      v.RuleID,
+		permissions: v.Permissions,
+		capability:  v.Capability,
+		syscall:     v.Syscall,
+	}
+}
+
+// reportViolation aggregates the identical violations before sending them to the sink. The first violation is
+// sent immediately, and the identical ones that occur in the window are sent as one violation with the count and
+// the timestamps of the first and the last ones when the window ends.
+func (enforcer *BpfEnforcer) reportViolation(violation varmortypes.Violation) {
+	if enforcer.opts.ViolationAggregationWindow <= 0 {
+		enforcer.emitViolation(violation)
+		return
+	}
+
+	if enforcer.violationAggregates == nil {
+		enforcer.violationAggregates = make(map[violationAggregateKey]*violationAggregate)
+	}
+
+	key := newViolationAggregateKey(&violation)
+	aggregate, ok := enforcer.violationAggregates[key]
+	if !ok {
+		if len(enforcer.violationAggregates) < maxViolationAggregates {
+			enforcer.violationAggregates[key] = &violationAggregate{
+				deadline: violation.Timestamp.Add(enforcer.opts.ViolationAggregationWindow),
+			}
+		}
+		enforcer.emitViolation(violation)
+		return
+	}
+
+	aggregatedViolationCount.Add(1)
+	if aggregate.violation == nil {
+		aggregate.violation = &violation
+		return
+	}
+	aggregate.violation.Count += violation.Count
+	aggregate.violation.LastTimestamp = violation.LastTimestamp
+}
+
+// flushViolationAggregates sends the aggregated violations whose window ended, or all of them if flush is true
+func (enforcer *BpfEnforcer) flushViolationAggregates(now time.Time, flush bool) {
+	for key, aggregate := range enforcer.violationAggregates {
+		if !flush && now.Before(aggregate.deadline) {
+			continue
+		}
+		delete(enforcer.violationAggregates, key)
+		if aggregate.violation != nil {
+			enforcer.emitViolation(*aggregate.violation)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_reportViolation(t *testing.T) {
+	var violations []varmortypes.Violation
+	enforcer := newEnrichTestEnforcer(&violations)
+	enforcer.opts.ViolationAggregationWindow = 10 * time.Second
+	now := time.Now()
+
+	// The first violation is reported immediately
+	event := bpfViolationEvent{MntNsID: 1, RuleType: fileRuleType, Permissions: 2}
+	enforcer.reportViolation(newViolation(&event, "rule-1", now, nil))
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Count, int64(1))
+
+	// The identical ones are aggregated, the others aren't
+	for i := 1; i <= 3; i++ {
+		enforcer.reportViolation(newViolation(&event, "rule-1", now.Add(time.Duration(i)*time.Second), nil))
+	}
+	enforcer.reportViolation(newViolation(&event, "rule-2", now.Add(time.Second), nil))
+	assert.Equal(t, len(violations), 2)
+	assert.Equal(t, violations[1].RuleID, "rule-2")
+
+	// The aggregated violations are reported when the window ends
+	enforcer.flushViolationAggregates(now.Add(5*time.Second), false)
+	assert.Equal(t, len(violations), 2)
+	enforcer.flushViolationAggregates(now.Add(10*time.Second), false)
+	assert.Equal(t, len(violations), 3)
+	assert.Equal(t, violations[2].RuleID, "rule-1")
+	assert.Equal(t, violations[2].Count, int64(3))
+	assert.Equal(t, violations[2].Timestamp, now.Add(time.Second))
+	assert.Equal(t, violations[2].LastTimestamp, now.Add(3*time.Second))
+
+	// A new window starts after the previous one ended
+	enforcer.reportViolation(newViolation(&event, "rule-1", now.Add(11*time.Second), nil))
+	assert.Equal(t, len(violations), 4)
+	enforcer.flushViolationAggregates(now.Add(11*time.Second), true)
+	assert.Equal(t, len(violations), 4)
+	assert.Equal(t, len(enforcer.violationAggregates), 0)
+
+	// The aggregation is disabled
+	enforcer.opts.ViolationAggregationWindow = -1
+	enforcer.reportViolation(newViolation(&event, "rule-1", now, nil))
+	enforcer.reportViolation(newViolation(&event, "rule-1", now, nil))
+	assert.Equal(t, len(violations), 6)
+}
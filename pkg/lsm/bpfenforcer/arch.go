@@ -0,0 +1,58 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// bpf2goTargets maps GOARCH to the architecture name that bpf2go uses in the names of the generated files,
+// e.g. bpf_x86_bpfel.o and bpf_arm64_bpfel.o.
+var bpf2goTargets = map[string]string{
+	"386":      "x86",
+	"amd64":    "x86",
+	"arm":      "arm",
+	"arm64":    "arm64",
+	"loong64":  "loongarch",
+	"mips64le": "mips",
+	"mipsle":   "mips",
+	"ppc64le":  "powerpc",
+	"riscv64":  "riscv",
+}
+
+// resolveObjectPath returns the BPF object file to load. If the path is a directory, the object compiled for
+// the architecture is selected from it, because the CO-RE relocations of the BPF program depend on the
+// architecture-specific kernel types.
+func resolveObjectPath(path string, goarch string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+
+	target, ok := bpf2goTargets[goarch]
+	if !ok {
+		return "", fmt.Errorf("the architecture %s is not supported by the BPF enforcer", goarch)
+	}
+	objectPath := filepath.Join(path, fmt.Sprintf("bpf_%s_bpfel.o", target))
+	if _, err := os.Stat(objectPath); err != nil {
+		return "", fmt.Errorf("no BPF object for the architecture %s: %w", goarch, err)
+	}
+	return objectPath, nil
+}
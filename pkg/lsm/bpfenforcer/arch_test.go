@@ -0,0 +1,117 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"gotest.tools/assert"
+)
+
+func Test_resolveObjectPath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"bpf_x86_bpfel.o", "bpf_arm64_bpfel.o"} {
+		err := os.WriteFile(filepath.Join(dir, name), nil, 0600)
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		goarch       string
+		expectedPath string
+		expectedErr  bool
+	}{
+		{
+			name:         "file",
+			path:         filepath.Join(dir, "bpf_x86_bpfel.o"),
+			goarch:       "arm64",
+			expectedPath: filepath.Join(dir, "bpf_x86_bpfel.o"),
+		},
+		{
+			name:         "amd64",
+			path:         dir,
+			goarch:       "amd64",
+			expectedPath: filepath.Join(dir, "bpf_x86_bpfel.o"),
+		},
+		{
+			name:         "arm64",
+			path:         dir,
+			goarch:       "arm64",
+			expectedPath: filepath.Join(dir, "bpf_arm64_bpfel.o"),
+		},
+		{
+			name:        "missing object",
+			path:        dir,
+			goarch:      "riscv64",
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported architecture",
+			path:        dir,
+			goarch:      "s390x",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := resolveObjectPath(tc.path, tc.goarch)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, path, tc.expectedPath)
+		})
+	}
+}
+
+// Test_loadBpf validates that the CO-RE relocations of the embedded BPF object can be applied to the kernel of the
+// node that runs the test.
+func Test_loadBpf(t *testing.T) {
+	spec, err := loadBpf()
+	assert.NilError(t, err)
+	assert.Assert(t, len(spec.Programs) != 0)
+
+	kernelTypes, err := btf.LoadKernelSpec()
+	if err != nil {
+		t.Skipf("the BTF of the kernel is unavailable: %v", err)
+	}
+
+	// The inner maps are created per profile, mock them to load the programs
+	for _, m := range spec.Maps {
+		if (m.Type == ebpf.HashOfMaps || m.Type == ebpf.ArrayOfMaps) && m.InnerMap == nil {
+			m.InnerMap = &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 4, ValueSize: 4, MaxEntries: 1}
+		}
+	}
+
+	coll, err := ebpf.NewCollectionWithOptions(spec, ebpf.CollectionOptions{
+		Programs: ebpf.ProgramOptions{KernelTypes: kernelTypes},
+	})
+	if err != nil {
+		// The instructions of the impossible relocations are poisoned with the call of 0xbad2310
+		if strings.Contains(err.Error(), "CO-RE") || strings.Contains(err.Error(), "unknown#195896080") {
+			t.Fatalf("failed to relocate the BPF object: %v", err)
+		}
+		t.Skipf("the BPF object can't be loaded: %v", err)
+	}
+	coll.Close()
+}
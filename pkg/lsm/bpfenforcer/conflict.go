@@ -0,0 +1,104 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	varmorutils "github.com/bytedance/vArmor/pkg/utils"
+)
+
+// processState is the state of a process that decides whether the BPF rules are fully enforceable for it
+type processState struct {
+	capEff  uint64
+	pidNsID uint32
+	ipcNsID uint32
+	netNsID uint32
+}
+
+// readCapEff reads the effective capabilities of the process from /proc/<pid>/status
+func readCapEff(pid uint32) (uint64, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if ok {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("CapEff not found in the status of the process %d", pid)
+}
+
+func readProcessState(pid uint32) (state processState, err error) {
+	state.capEff, err = readCapEff(pid)
+	if err != nil {
+		return state, err
+	}
+	state.pidNsID, err = varmorutils.ReadPidNsID(pid)
+	if err != nil {
+		return state, err
+	}
+	state.ipcNsID, err = varmorutils.ReadIpcNsID(pid)
+	if err != nil {
+		return state, err
+	}
+	state.netNsID, err = varmorutils.ReadNetNsID(pid)
+	return state, err
+}
+
+// conflictsOf returns the settings of the container that make several BPF rules ineffective or misleading. The
+// container is privileged if it has all the effective capabilities of the init process of the host.
+func conflictsOf(container processState, host processState) []string {
+	var conflicts []string
+	if container.capEff&host.capEff == host.capEff {
+		conflicts = append(conflicts, "privileged")
+	}
+	if container.pidNsID == host.pidNsID {
+		conflicts = append(conflicts, "hostPID")
+	}
+	if container.ipcNsID == host.ipcNsID {
+		conflicts = append(conflicts, "hostIPC")
+	}
+	if container.netNsID == host.netNsID {
+		conflicts = append(conflicts, "hostNetwork")
+	}
+	return conflicts
+}
+
+// readConflicts returns the settings of the container process that make it partially enforceable, i.e. it's
+// privileged or shares the namespaces with the host.
+func readConflicts(pid uint32) ([]string, error) {
+	container, err := readProcessState(pid)
+	if err != nil {
+		return nil, err
+	}
+	host, err := readProcessState(1)
+	if err != nil {
+		return nil, err
+	}
+	return conflictsOf(container, host), nil
+}
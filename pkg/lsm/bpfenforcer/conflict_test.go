@@ -0,0 +1,38 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_conflictsOf(t *testing.T) {
+	host := processState{capEff: 0x1ffffffffff, pidNsID: 1, ipcNsID: 2, netNsID: 3}
+
+	// The default capabilities of the container runtimes
+	container := processState{capEff: 0xa80425fb, pidNsID: 11, ipcNsID: 12, netNsID: 13}
+	assert.Assert(t, conflictsOf(container, host) == nil)
+
+	container = processState{capEff: 0x1ffffffffff, pidNsID: 1, ipcNsID: 12, netNsID: 3}
+	assert.DeepEqual(t, conflictsOf(container, host), []string{"privileged", "hostPID", "hostNetwork"})
+}
+
+func Test_readCapEff(t *testing.T) {
+	_, err := readCapEff(uint32(os.Getpid()))
+	assert.NilError(t, err)
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxFailureReasons caps the failure reasons of a profile, the others are omitted
+	maxFailureReasons = 5
+	// coverageRefreshInterval is the interval for refreshing the snapshot of the coverages
+	coverageRefreshInterval = 30 * time.Second
+)
+
+// Coverage describes how many target pods of a BPF profile are protected on the node
+type Coverage struct {
+	// DesiredPods is the number of the target pods observed
+	DesiredPods int
+	// ReadyPods is the number of the target pods whose containers are all protected
+	ReadyPods int
+	// FailedPods is the number of the target pods that the profile failed to apply to
+	FailedPods int
+	// FailureReasons describe why the profile failed to apply to the containers
+	FailureReasons []string
+	// PartialPods is the number of the ready pods that are only partially enforceable, since some of their
+	// containers are privileged or share the namespaces with the host
+	PartialPods int
+	// PartialReasons describe why the containers are only partially enforceable
+	PartialReasons []string
+}
+
+type podCoverageState int
+
+const (
+	podReady podCoverageState = iota
+	podPending
+	podFailed
+)
+
+// Coverage returns the enforcement coverage of the BPF profile on the node. It's read from the snapshot that
+// the event handler refreshes periodically, so it's safe to call it from other goroutines.
+func (enforcer *BpfEnforcer) Coverage(profileName string) Coverage {
+	enforcer.coveragesLock.RLock()
+	defer enforcer.coveragesLock.RUnlock()
+	return enforcer.coverages[profileName]
+}
+
+// refreshCoverages takes the snapshot of the coverages of all BPF profiles
+func (enforcer *BpfEnforcer) refreshCoverages() {
+	coverages := make(map[string]Coverage, len(enforcer.bpfProfileCache))
+	for profileName := range enforcer.bpfProfileCache {
+		coverages[profileName] = enforcer.computeCoverage(profileName)
+	}
+
+	enforcer.coveragesLock.Lock()
+	defer enforcer.coveragesLock.Unlock()
+	enforcer.coverages = coverages
+}
+
+// computeCoverage computes the enforcement coverage of the BPF profile. The target containers are grouped
+// by pod, a pod is failed if the profile failed to apply to any of its containers, and it's ready if all
+// of its containers are protected.
+func (enforcer *BpfEnforcer) computeCoverage(profileName string) Coverage {
+	var coverage Coverage
+
+	profile, ok := enforcer.bpfProfileCache[profileName]
+	if !ok {
+		return coverage
+	}
+	letters := enforcer.deadLettersOfProfile(profileName)
+
+	pods := make(map[string]podCoverageState)
+	partialPods := make(map[string]bool)
+	var reasons []string
+	var partialReasons []string
+	for containerID, info := range enforcer.containerInfos {
+		if name, ok := enforcer.opts.resolveProfile(info); !ok || name != profileName {
+			continue
+		}
+
+		pod := info.PodUID
+		if pod == "" {
+			pod = info.PodNamespace + "/" + info.PodName
+		}
+
+		state := podReady
+		if letter, ok := letters[containerID]; ok {
+			state = podFailed
+			reasons = append(reasons, fmt.Sprintf("%s/%s/%s: %s", info.PodNamespace, info.PodName, info.ContainerName, letter.err))
+		} else if _, ok := profile.containerCache[containerID]; !ok {
+			state = podPending
+		}
+
+		if s, ok := pods[pod]; !ok || state > s {
+			pods[pod] = state
+		}
+
+		if conflicts, ok := enforcer.conflicts[containerID]; ok {
+			partialPods[pod] = true
+			partialReasons = append(partialReasons, fmt.Sprintf("%s/%s/%s: %s", info.PodNamespace, info.PodName, info.ContainerName, strings.Join(conflicts, ", ")))
+		}
+	}
+
+	coverage.DesiredPods = len(pods)
+	for pod, state := range pods {
+		switch state {
+		case podReady:
+			coverage.ReadyPods++
+			if partialPods[pod] {
+				coverage.PartialPods++
+			}
+		case podFailed:
+			coverage.FailedPods++
+		}
+	}
+
+	sort.Strings(reasons)
+	if len(reasons) > maxFailureReasons {
+		reasons = append(reasons[:maxFailureReasons], fmt.Sprintf("and %d more", len(reasons)-maxFailureReasons))
+	}
+	coverage.FailureReasons = reasons
+
+	sort.Strings(partialReasons)
+	if len(partialReasons) > maxFailureReasons {
+		partialReasons = append(partialReasons[:maxFailureReasons], fmt.Sprintf("and %d more", len(partialReasons)-maxFailureReasons))
+	}
+	coverage.PartialReasons = partialReasons
+
+	return coverage
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_Coverage(t *testing.T) {
+	var violations []varmortypes.Violation
+	enforcer := newEnrichTestEnforcer(&violations)
+	enforcer.opts.ProfileResolver = resolveProfileFromAnnotations
+	enforcer.deadLetters = make(map[string]deadLetter)
+
+	annotations := map[string]string{
+		"container.bpf.security.beta.varmor.org/c": "localhost/p1",
+	}
+	newInfo := func(containerID, podUID string) varmortypes.ContainerInfo {
+		return varmortypes.ContainerInfo{
+			ContainerID:    containerID,
+			ContainerName:  "c",
+			PodUID:         podUID,
+			PodNamespace:   "default",
+			PodName:        podUID,
+			PodAnnotations: annotations,
+		}
+	}
+
+	// pod-1 is protected
+	enforcer.addTestContainer("p1", newInfo("c1", "pod-1"), 1)
+	// pod-2 has a protected container and a pending one
+	enforcer.addTestContainer("p1", newInfo("c2", "pod-2"), 2)
+	enforcer.containerInfos["c3"] = newInfo("c3", "pod-2")
+	// pod-3 failed
+	enforcer.containerInfos["c4"] = newInfo("c4", "pod-3")
+	enforcer.deadLetters["c4"] = deadLetter{profileName: "p1", err: "no space left on device"}
+	// pod-4 is protected, but it's partially enforceable
+	enforcer.addTestContainer("p1", newInfo("c5", "pod-4"), 5)
+	enforcer.conflicts = map[string][]string{"c5": {"privileged", "hostPID"}}
+
+	enforcer.refreshCoverages()
+	coverage := enforcer.Coverage("p1")
+	assert.Equal(t, coverage.DesiredPods, 4)
+	assert.Equal(t, coverage.ReadyPods, 2)
+	assert.Equal(t, coverage.FailedPods, 1)
+	assert.Equal(t, coverage.PartialPods, 1)
+	assert.DeepEqual(t, coverage.FailureReasons, []string{"default/pod-3/c: no space left on device"})
+	assert.DeepEqual(t, coverage.PartialReasons, []string{"default/pod-4/c: privileged, hostPID"})
+
+	assert.DeepEqual(t, enforcer.Coverage("p2"), Coverage{})
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"sort"
+)
+
+// Enforcement describes the BPF profile enforced for a target container on the node
+type Enforcement struct {
+	ContainerID   string
+	ContainerName string
+	PodNamespace  string
+	PodName       string
+	PodUID        string
+	ProfileName   string
+	MntNsID       uint32
+	// Failed means the latest BPF profile failed to apply to the container. The previous rules are still
+	// enforced if the container was protected before.
+	Failed bool
+	// Error is the reason why the BPF profile failed to apply
+	Error string
+}
+
+// Enforcements returns the BPF profiles enforced for the target containers on the node, sorted by the container
+// id. It's read from the snapshot that the event handler refreshes periodically, so it's safe to call it from
+// other goroutines.
+func (enforcer *BpfEnforcer) Enforcements() []Enforcement {
+	enforcer.enforcementsLock.RLock()
+	defer enforcer.enforcementsLock.RUnlock()
+	return append([]Enforcement(nil), enforcer.enforcements...)
+}
+
+// refreshEnforcements takes the snapshot of the BPF profiles enforced for the target containers
+func (enforcer *BpfEnforcer) refreshEnforcements() {
+	enforcements := enforcer.computeEnforcements()
+
+	enforcer.enforcementsLock.Lock()
+	defer enforcer.enforcementsLock.Unlock()
+	enforcer.enforcements = enforcements
+}
+
+func (enforcer *BpfEnforcer) newEnforcement(profileName string, containerID string, mntNsID uint32) Enforcement {
+	info := enforcer.containerInfos[containerID]
+	return Enforcement{
+		ContainerID:   containerID,
+		ContainerName: info.ContainerName,
+		PodNamespace:  info.PodNamespace,
+		PodName:       info.PodName,
+		PodUID:        info.PodUID,
+		ProfileName:   profileName,
+		MntNsID:       mntNsID,
+	}
+}
+
+// computeEnforcements lists the containers that the BPF profiles were applied to, and the ones that they failed
+// to apply to
+func (enforcer *BpfEnforcer) computeEnforcements() []Enforcement {
+	var enforcements []Enforcement
+	for profileName, profile := range enforcer.bpfProfileCache {
+		letters := enforcer.deadLettersOfProfile(profileName)
+
+		for containerID, id := range profile.containerCache {
+			enforcement := enforcer.newEnforcement(profileName, containerID, id.mntNsID)
+			if letter, ok := letters[containerID]; ok {
+				enforcement.Failed = true
+				enforcement.Error = letter.err
+				delete(letters, containerID)
+			}
+			enforcements = append(enforcements, enforcement)
+		}
+
+		// The new containers that the profile failed to apply to
+		for containerID, letter := range letters {
+			enforcement := enforcer.newEnforcement(profileName, containerID, letter.id.mntNsID)
+			enforcement.Failed = true
+			enforcement.Error = letter.err
+			enforcements = append(enforcements, enforcement)
+		}
+	}
+
+	sort.Slice(enforcements, func(i, j int) bool { return enforcements[i].ContainerID < enforcements[j].ContainerID })
+	return enforcements
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_Enforcements(t *testing.T) {
+	var violations []varmortypes.Violation
+	enforcer := newEnrichTestEnforcer(&violations)
+	enforcer.deadLetters = make(map[string]deadLetter)
+
+	newInfo := func(containerID string) varmortypes.ContainerInfo {
+		return varmortypes.ContainerInfo{
+			ContainerID:   containerID,
+			ContainerName: "c",
+			PodUID:        "uid-" + containerID,
+			PodNamespace:  "default",
+			PodName:       "pod-" + containerID,
+		}
+	}
+
+	// c1 is protected, c2 keeps the previous rules, c3 isn't protected
+	enforcer.addTestContainer("p1", newInfo("c1"), 1)
+	enforcer.addTestContainer("p1", newInfo("c2"), 2)
+	enforcer.deadLetters["c2"] = deadLetter{profileName: "p1", id: enforceID{mntNsID: 2}, err: "no space left on device"}
+	enforcer.containerInfos["c3"] = newInfo("c3")
+	enforcer.deadLetters["c3"] = deadLetter{profileName: "p1", id: enforceID{mntNsID: 3}, err: "argument list too long"}
+
+	assert.Assert(t, enforcer.Enforcements() == nil)
+	enforcer.refreshEnforcements()
+	assert.DeepEqual(t, enforcer.Enforcements(), []Enforcement{
+		{ContainerID: "c1", ContainerName: "c", PodNamespace: "default", PodName: "pod-c1", PodUID: "uid-c1", ProfileName: "p1", MntNsID: 1},
+		{ContainerID: "c2", ContainerName: "c", PodNamespace: "default", PodName: "pod-c2", PodUID: "uid-c2", ProfileName: "p1", MntNsID: 2, Failed: true, Error: "no space left on device"},
+		{ContainerID: "c3", ContainerName: "c", PodNamespace: "default", PodName: "pod-c3", PodUID: "uid-c3", ProfileName: "p1", MntNsID: 3, Failed: true, Error: "argument list too long"},
+	})
+}
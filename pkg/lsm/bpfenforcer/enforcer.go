@@ -15,14 +15,25 @@
 package bpfenforcer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
 	"github.com/cilium/ebpf/rlimit"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
 	lsmutils "github.com/bytedance/vArmor/pkg/lsm/utils"
@@ -30,6 +41,8 @@ import (
 	varmorutils "github.com/bytedance/vArmor/pkg/utils"
 )
 
+var tracer = otel.Tracer("github.com/bytedance/vArmor/pkg/lsm/bpfenforcer")
+
 type enforceID struct {
 	pid     uint32
 	mntNsID uint32
@@ -37,47 +50,90 @@ type enforceID struct {
 
 type bpfProfile struct {
 	bpfContent     varmor.BpfContent
+	hash           string
 	containerCache map[string]enforceID // local cache <containerID: enforceID>
+	hostProcess    *varmor.HostProcessTarget
 }
 
 type BpfEnforcer struct {
-	TaskCreateCh     chan varmortypes.ContainerInfo
-	TaskDeleteCh     chan varmortypes.ContainerInfo
-	TaskDeleteSyncCh chan bool
-	objs             bpfObjects
-	capableLink      link.Link
-	openFileLink     link.Link
-	pathSymlinkLink  link.Link
-	pathLinkLink     link.Link
-	pathRenameLink   link.Link
-	bprmLink         link.Link
-	sockConnLink     link.Link
-	ptraceLink       link.Link
-	mountLink        link.Link
-	moveMountLink    link.Link
-	umountLink       link.Link
-	bpfProfileCache  map[string]bpfProfile // <profileName: bpfProfile>
-	containerCache   map[string]enforceID  // global cache <containerID: enforceID>
-	log              logr.Logger
+	TaskCreateCh        chan varmortypes.ContainerInfo
+	TaskDeleteCh        chan varmortypes.ContainerInfo
+	TaskDeleteSyncCh    chan bool
+	DeadLetterCh        chan string
+	retryCh             chan string
+	ViolationCh         chan varmortypes.Violation
+	TamperCh            chan varmortypes.Tamper
+	SuspensionCh        chan varmortypes.Suspension
+	enforceCh           chan enforceRequest
+	suspendCh           chan suspendRequest
+	releaseCh           chan chan error
+	opts                Options
+	objs                bpfObjects
+	violations          *ebpf.Map
+	violationReader     *perf.Reader
+	violationCh         chan bpfViolationEvent
+	ruleIDs             *ruleIDStore
+	mapMemory           *mapMemoryStore
+	fingerprints        *fingerprintStore
+	warmUps             *warmUpStore
+	leaks               *leakStore
+	mapOps              *mapOpLogger
+	hashes              *hashCache
+	networkMacros       map[string][]*net.IPNet
+	selfTestErr         error
+	regexWatcher        *regexWatcher
+	capableLink         link.Link
+	openFileLink        link.Link
+	pathSymlinkLink     link.Link
+	pathLinkLink        link.Link
+	pathRenameLink      link.Link
+	bprmLink            link.Link
+	sockConnLink        link.Link
+	ptraceLink          link.Link
+	mountLink           link.Link
+	moveMountLink       link.Link
+	umountLink          link.Link
+	previousLinks       []link.Link
+	bpfProfileCache     map[string]bpfProfile                // <profileName: bpfProfile>
+	containerCache      map[string]enforceID                 // global cache <containerID: enforceID>
+	containerInfos      map[string]varmortypes.ContainerInfo // <containerID: ContainerInfo>
+	conflicts           map[string][]string                  // <containerID: conflicts>
+	deadLetters         map[string]deadLetter                // <containerID: deadLetter>
+	exitedContainers    map[uint32]violationContainer        // <mntNsID: violationContainer>
+	suspensions         map[string]suspension                // <containerID: suspension>
+	layers              map[string]string                    // <containerID: base profile name>
+	pendings            map[string]pendingContainer          // <containerID: pendingContainer>
+	pressure            *pressureMonitor
+	pendingViolations   []pendingViolation
+	violationAggregates map[violationAggregateKey]*violationAggregate
+	coverages           map[string]Coverage // <profileName: Coverage>
+	coveragesLock       sync.RWMutex
+	enforcements        []Enforcement
+	enforcementsLock    sync.RWMutex
+	deadLettersLock     sync.Mutex
+	lifecycleLock       sync.RWMutex
+	// handlerLock is held by the workers shared, and by the other operations exclusively
+	handlerLock sync.RWMutex
+	// cacheLock guards the caches of the containers and the profiles among the workers
+	cacheLock sync.Mutex
+	workers   *workerPool
+	journal   *journal
+	recovery  *journalRecovery
+	closed    bool
+	running   atomic.Bool
+	done      chan struct{}
+	log       logr.Logger
 }
 
-// NewBpfEnforcer create a BpfEnforcer, and initialize the BPF settings and resources
-func NewBpfEnforcer(log logr.Logger) (*BpfEnforcer, error) {
-	enforcer := BpfEnforcer{
-		TaskCreateCh:     make(chan varmortypes.ContainerInfo, 100),
-		TaskDeleteCh:     make(chan varmortypes.ContainerInfo, 100),
-		TaskDeleteSyncCh: make(chan bool, 1),
-		objs:             bpfObjects{},
-		bpfProfileCache:  make(map[string]bpfProfile),
-		containerCache:   make(map[string]enforceID),
-		log:              log,
-	}
-
-	err := enforcer.initBPF()
-	if err != nil {
-		return nil, err
-	}
-	return &enforcer, nil
+// NewBpfEnforcer create a BpfEnforcer with the default options of vArmor agent, and initialize the BPF settings and resources.
+// The taskChCapacity is the capacity of the channels which receive the task events.
+// The mapMemoryLimit caps the memory in bytes consumed by the inner maps on the node, no limit if zero.
+func NewBpfEnforcer(taskChCapacity int, mapMemoryLimit uint64, log logr.Logger) (*BpfEnforcer, error) {
+	return New(Options{
+		TaskChannelCapacity: taskChCapacity,
+		MapMemoryLimit:      mapMemoryLimit,
+		Log:                 log,
+	})
 }
 
 // initBPF initialize the BPF settings and resources
@@ -91,7 +147,7 @@ func (enforcer *BpfEnforcer) initBPF() error {
 
 	// Parse the ebpf program
 	enforcer.log.Info("parses the ebpf program into a CollectionSpec")
-	collectionSpec, err := loadBpf()
+	collectionSpec, err := enforcer.loadCollectionSpec()
 	if err != nil {
 		return err
 	}
@@ -135,6 +191,21 @@ func (enforcer *BpfEnforcer) initBPF() error {
 	}
 	collectionSpec.Maps["v_mount_outer"].InnerMap = &mountInnerMap
 
+	opts := ebpf.CollectionOptions{
+		MapReplacements: make(map[string]*ebpf.Map),
+	}
+
+	// Create the map for the violation events if the BPF program supports it
+	if violationsMap, ok := collectionSpec.Maps["v_violations"]; ok {
+		enforcer.violations, err = ebpf.NewMap(violationsMap)
+		if err != nil {
+			return err
+		}
+		opts.MapReplacements["v_violations"] = enforcer.violations
+	} else {
+		enforcer.log.Info("the violation events are not supported by the BPF program")
+	}
+
 	// Set the mnt ns id to the BPF program
 	initMntNsId, err := varmorutils.ReadMntNsID(1)
 	if err != nil {
@@ -146,7 +217,7 @@ func (enforcer *BpfEnforcer) initBPF() error {
 
 	// Load pre-compiled programs and maps into the kernel.
 	enforcer.log.Info("load ebpf program and maps into the kernel")
-	err = collectionSpec.LoadAndAssign(&enforcer.objs, nil)
+	err = collectionSpec.LoadAndAssign(&enforcer.objs, &opts)
 	if err != nil {
 		return err
 	}
@@ -251,237 +322,688 @@ func (enforcer *BpfEnforcer) initBPF() error {
 	}
 	enforcer.umountLink = umountLink
 
+	// The BPF programs pinned by the previous enforcer are taken over after the new ones are attached,
+	// so the enforcement isn't interrupted during the upgrade.
+	err = enforcer.adoptPreviousGeneration()
+	if err != nil {
+		enforcer.log.Error(err, "failed to take over the BPF programs pinned by the previous enforcer")
+	}
+
 	return nil
 }
 
-// Close close the BPF resources
+// Close waits for the pending map operations, then releases the BPF resources. The enforcement is left in place
+// if the KeepEnforcementOnShutdown option is set. It's safe to call it more than once.
 func (enforcer *BpfEnforcer) Close() {
+	enforcer.lifecycleLock.Lock()
+	defer enforcer.lifecycleLock.Unlock()
+
+	if enforcer.closed {
+		return
+	}
+	enforcer.closed = true
+
+	err := enforcer.journal.close()
+	if err != nil {
+		enforcer.log.Error(err, "failed to close the journal")
+	}
+
+	if enforcer.opts.KeepEnforcementOnShutdown {
+		err := enforcer.pinLinks()
+		if err != nil {
+			enforcer.log.Error(err, "failed to pin the BPF programs, the enforcement will be removed")
+		}
+	}
+
 	enforcer.log.Info("unload the bpf resources")
-	enforcer.capableLink.Close()
-	enforcer.openFileLink.Close()
-	enforcer.pathSymlinkLink.Close()
-	enforcer.pathLinkLink.Close()
-	enforcer.pathRenameLink.Close()
-	enforcer.bprmLink.Close()
-	enforcer.sockConnLink.Close()
-	enforcer.ptraceLink.Close()
-	enforcer.mountLink.Close()
-	enforcer.moveMountLink.Close()
-	enforcer.umountLink.Close()
+	enforcer.warmUps.discard(func(string, *warmUp) bool { return true })
+	enforcer.closePreviousLinks()
+	for _, l := range enforcer.links() {
+		if l.link != nil {
+			l.link.Close()
+		}
+	}
 	enforcer.objs.Close()
+	if enforcer.violationReader != nil {
+		enforcer.violationReader.Close()
+	}
+	if enforcer.violations != nil {
+		enforcer.violations.Close()
+	}
+}
+
+// handleTaskCreate applies the BPF profile to the target container which was created
+func (enforcer *BpfEnforcer) handleTaskCreate(info varmortypes.ContainerInfo) {
+	err := enforcer.enforceContainer(info)
+	// the profile will be applied to the container once it's created, or the pressure of the node subsides
+	if err != nil && !errors.Is(err, errProfileNotExist) && !errors.Is(err, errNodePressure) {
+		enforcer.log.Error(err, "enforceContainer() failed", "container id", info.ContainerID)
+	}
+}
+
+// enforceContainer applies the BPF profile to the container if it's a target container. The base profile that the
+// container is bound to is layered under the profile, and the container is enforced with the base profile alone if
+// it has no profile.
+func (enforcer *BpfEnforcer) enforceContainer(info varmortypes.ContainerInfo) error {
+	profileName, baseName, ok := enforcer.opts.resolveProfiles(info)
+	if !ok {
+		return nil
+	}
+
+	enforcer.cacheLock.Lock()
+	_, protected := enforcer.containerCache[info.ContainerID]
+	enforcer.cacheLock.Unlock()
+	changed := enforcer.bindBaseProfile(info.ContainerID, baseName)
+
+	err := enforcer.enforceContainerWithProfile(info, profileName)
+	if err == nil && changed && protected {
+		// The profile was applied to the container without the base profile, e.g. it was enforced again from the
+		// journal after the previous enforcer crashed
+		err = enforcer.reapplyContainer(context.Background(), profileName, info.ContainerID)
+	}
+	return err
+}
+
+// enforceContainerWithProfile applies the BPF profile to the container
+func (enforcer *BpfEnforcer) enforceContainerWithProfile(info varmortypes.ContainerInfo, profileName string) error {
+	enforcer.cacheLock.Lock()
+	profile, ok := enforcer.bpfProfileCache[profileName]
+	enforcer.cacheLock.Unlock()
+	if !ok {
+		return fmt.Errorf("%w (profile name: %s)", errProfileNotExist, profileName)
+	}
+
+	// the profile is applied to the container when the suspension ends
+	if enforcer.isSuspended(info.ContainerID) {
+		return nil
+	}
+
+	enforcer.log.Info("target container was created",
+		"profile name", profileName,
+		"pod namespace", info.PodNamespace,
+		"pod name", info.PodName,
+		"container name", info.ContainerName,
+		"container id", info.ContainerID,
+		"pid", info.PID)
+	enforcer.cacheLock.Lock()
+	oldEnforceID, protected := enforcer.containerCache[info.ContainerID]
+	enforcer.cacheLock.Unlock()
+
+	// the new containers aren't onboarded when the node is under memory pressure
+	if !protected {
+		if err := enforcer.deferContainer(info, profileName); err != nil {
+			return err
+		}
+	}
+
+	enforcer.cacheLock.Lock()
+	enforcer.containerInfos[info.ContainerID] = info
+	enforcer.cacheLock.Unlock()
+
+	// create an enforceID
+	enforceID, err := enforcer.newEnforceID(info.PID)
+	if err != nil {
+		return fmt.Errorf("newEnforceID() failed: %w", err)
+	}
+
+	// nothing needs to change when the container was been protected
+	if protected && reflect.DeepEqual(oldEnforceID, enforceID) {
+		return nil
+	}
+
+	// apply the BPF profile for the target container
+	enforcer.journalApply(journalPhaseBegin, info.ContainerID, enforceID, profileName, profile.hash, nil)
+	err = enforcer.applyProfileWithSpan(context.Background(), profileName, info.ContainerID, enforceID, profile.bpfContent)
+	if err != nil {
+		enforcer.journalApply(journalPhaseFailed, info.ContainerID, enforceID, profileName, profile.hash, err)
+		enforcer.addDeadLetter(info.ContainerID, profileName, enforceID, err)
+		return fmt.Errorf("applyProfile() failed: %w", err)
+	}
+	enforcer.journalApply(journalPhaseDone, info.ContainerID, enforceID, profileName, profile.hash, nil)
+	enforcer.removeDeadLetter(info.ContainerID)
+	enforcer.removePending(info.ContainerID)
+	enforcer.observeApplyLatency(info.ContainerID, info.CreatedAt)
+
+	// cache the enforceID
+	enforcer.cacheLock.Lock()
+	enforcer.containerCache[info.ContainerID] = enforceID
+	profile.containerCache[info.ContainerID] = enforceID
+	enforcer.bpfProfileCache[profileName] = profile
+	enforcer.cacheLock.Unlock()
+
+	// Several rules are ineffective or misleading for the privileged containers and the ones that share the
+	// namespaces with the host, so they are reported as partially enforceable.
+	conflicts, err := readConflicts(info.PID)
+	if err != nil {
+		enforcer.log.Error(err, "readConflicts() failed", "container id", info.ContainerID, "pid", info.PID)
+	} else if len(conflicts) != 0 {
+		enforcer.log.Info("the target container is partially enforceable", "container id", info.ContainerID, "conflicts", conflicts)
+		enforcer.cacheLock.Lock()
+		enforcer.conflicts[info.ContainerID] = conflicts
+		enforcer.cacheLock.Unlock()
+	}
+	return nil
+}
+
+// handleTaskDelete unloads the BPF profile of the target container which was deleted
+func (enforcer *BpfEnforcer) handleTaskDelete(info varmortypes.ContainerInfo) {
+	enforcer.removeDeadLetter(info.ContainerID)
+	enforcer.removeSuspension(info.ContainerID)
+	enforcer.removePending(info.ContainerID)
+	defer func() {
+		enforcer.cacheLock.Lock()
+		delete(enforcer.containerInfos, info.ContainerID)
+		delete(enforcer.layers, info.ContainerID)
+		delete(enforcer.conflicts, info.ContainerID)
+		enforcer.cacheLock.Unlock()
+	}()
+
+	enforcer.cacheLock.Lock()
+	enforceID, ok := enforcer.containerCache[info.ContainerID]
+	if ok {
+		// Keep the metadata for the violation events that arrive after the container exits
+		enforcer.rememberExitedContainer(info.ContainerID, enforceID, time.Now())
+	}
+	enforcer.cacheLock.Unlock()
+
+	if ok {
+		enforcer.log.Info("target container was deleted",
+			"container id", info.ContainerID,
+			"pid", info.PID)
+
+		// delete the BPF profile of the container
+		err := enforcer.deleteProfileWithRetry(enforceID.mntNsID)
+		if err != nil {
+			enforcer.log.Error(err, "deleteProfile() failed, the entries will be deleted by the garbage collection",
+				"container id", info.ContainerID, "mnt ns id", enforceID.mntNsID)
+		}
+		enforcer.regexWatcher.unwatch(info.ContainerID)
+		enforcer.journalDelete(info.ContainerID, enforceID)
+
+		enforcer.cacheLock.Lock()
+		defer enforcer.cacheLock.Unlock()
+
+		// delete the container from the global cache
+		delete(enforcer.containerCache, info.ContainerID)
+
+		// delete the container from the local cache
+		for profileName, profile := range enforcer.bpfProfileCache {
+			if _, ok := profile.containerCache[info.ContainerID]; ok {
+				delete(profile.containerCache, info.ContainerID)
+				enforcer.bpfProfileCache[profileName] = profile
+				break
+			}
+		}
+	}
 }
 
 func (enforcer *BpfEnforcer) eventHandler(stopCh <-chan struct{}) {
 	logger := enforcer.log.WithName("eventHandler()")
 	logger.Info("start handle the containerd events")
 
+	gcTicker := time.NewTicker(mntNsGCInterval)
+	defer gcTicker.Stop()
+	hostProcessTicker := time.NewTicker(hostProcessScanInterval)
+	defer hostProcessTicker.Stop()
+	enrichTicker := time.NewTicker(violationEnrichInterval)
+	defer enrichTicker.Stop()
+	coverageTicker := time.NewTicker(coverageRefreshInterval)
+	defer coverageTicker.Stop()
+	tamperTicker := time.NewTicker(tamperCheckInterval)
+	defer tamperTicker.Stop()
+	suspensionTicker := time.NewTicker(suspensionCheckInterval)
+	defer suspensionTicker.Stop()
+	pressureTicker := time.NewTicker(pressureCheckInterval)
+	defer pressureTicker.Stop()
+	warmUpTicker := time.NewTicker(warmUpCheckInterval)
+	defer warmUpTicker.Stop()
+
+	defer close(enforcer.done)
+
 	for {
 		select {
 		case info := <-enforcer.TaskCreateCh:
-			key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", info.ContainerName)
-			value := info.PodAnnotations[key]
+			enforcer.handleTaskEvent(info, enforcer.handleTaskCreate)
+			enforcer.exclusive(func() { enforcer.enrichPendingViolations(time.Now(), false) })
 
-			if !strings.HasPrefix(value, "localhost/") {
-				break
-			}
+		case info := <-enforcer.TaskDeleteCh:
+			enforcer.handleTaskEvent(info, enforcer.handleTaskDelete)
 
-			profileName := value[len("localhost/"):]
-			if profile, ok := enforcer.bpfProfileCache[profileName]; ok {
-				logger.Info("target container was created",
-					"profile name", profileName,
-					"pod namespace", info.PodNamespace,
-					"pod name", info.PodName,
-					"container name", info.ContainerName,
-					"container id", info.ContainerID,
-					"pid", info.PID)
-
-				// create an enforceID
-				enforceID, err := enforcer.newEnforceID(info.PID)
-				if err != nil {
-					logger.Error(err, "newEnforceID() failed")
-					break
-				}
+		case request := <-enforcer.enforceCh:
+			enforcer.handleEnforceRequest(request)
+
+		case request := <-enforcer.suspendCh:
+			enforcer.handleSuspendRequest(request)
+
+		case result := <-enforcer.releaseCh:
+			enforcer.handleReleaseRequest(result)
 
-				// nothing needs to change when the container was been protected
-				if oldEnforceID, ok := enforcer.containerCache[info.ContainerID]; ok {
-					if reflect.DeepEqual(oldEnforceID, enforceID) {
-						break
+		case <-enforcer.TaskDeleteSyncCh:
+			enforcer.do(func() {
+				// Handle those containers that exit while the monitor was offline
+				for profileName, profile := range enforcer.bpfProfileCache {
+					for containerID, enforceID := range profile.containerCache {
+						_, err := enforcer.newEnforceID(enforceID.pid)
+						if err != nil {
+							// maybe the container had already exited
+							logger.Info("the target container exited while the monitor was offline",
+								"container id", containerID,
+								"pid", enforceID.pid)
+
+							// delete the BPF profile of the container
+							err = enforcer.deleteProfileWithRetry(enforceID.mntNsID)
+							if err != nil {
+								logger.Error(err, "deleteProfile() failed, the entries will be deleted by the garbage collection",
+									"container id", containerID, "mnt ns id", enforceID.mntNsID)
+							}
+							enforcer.regexWatcher.unwatch(containerID)
+							enforcer.journalDelete(containerID, enforceID)
+
+							// delete the container from the global cache
+							delete(enforcer.containerCache, containerID)
+							delete(enforcer.containerInfos, containerID)
+							delete(enforcer.layers, containerID)
+							delete(enforcer.conflicts, containerID)
+
+							// delete the container from the local cache
+							delete(profile.containerCache, containerID)
+							enforcer.bpfProfileCache[profileName] = profile
+						}
 					}
+					enforcer.retryDeadLetters(profileName)
 				}
+				enforcer.prunePendings()
+
+				// Replay the operations of the previous enforcer which crashed
+				enforcer.replayJournal("")
+			})
 
-				// apply the BPF profile for the target container
-				err = enforcer.applyProfile(enforceID.mntNsID, profile.bpfContent)
+		case containerID := <-enforcer.retryCh:
+			// The retry timer of the container whose BPF profile failed to apply fired
+			enforcer.do(func() { enforcer.retryContainer(containerID) })
+
+		case containerID := <-enforcer.regexWatcher.refreshCh:
+			// The entries of the directories which the regular expressions were expanded against have changed
+			logger.V(3).Info("refresh the file rules with regular expression", "container id", containerID)
+			enforcer.do(func() {
+				err := enforcer.refreshProfile(containerID)
 				if err != nil {
-					logger.Error(err, "applyProfile() failed")
-					break
+					logger.Error(err, "refreshProfile() failed", "container id", containerID)
 				}
+			})
 
-				// cache the enforceID
-				enforcer.containerCache[info.ContainerID] = enforceID
-				profile.containerCache[info.ContainerID] = enforceID
-				enforcer.bpfProfileCache[profileName] = profile
-			}
+		case event := <-enforcer.violationCh:
+			enforcer.exclusive(func() { enforcer.handleViolation(&event) })
 
-		case info := <-enforcer.TaskDeleteCh:
-			if enforceID, ok := enforcer.containerCache[info.ContainerID]; ok {
-				logger.Info("target container was deleted",
-					"container id", info.ContainerID,
-					"pid", info.PID)
+		case <-enrichTicker.C:
+			enforcer.exclusive(func() {
+				enforcer.enrichPendingViolations(time.Now(), false)
+				enforcer.flushViolationAggregates(time.Now(), false)
+			})
 
-				// delete the BPF profile of the container
-				enforcer.deleteProfile(enforceID.mntNsID)
+		case <-coverageTicker.C:
+			enforcer.do(func() {
+				enforcer.refreshCoverages()
+				enforcer.refreshEnforcements()
+			})
 
-				// delete the container from the global cache
-				delete(enforcer.containerCache, info.ContainerID)
+		case <-tamperTicker.C:
+			enforcer.do(enforcer.checkTampers)
 
-				// delete the container from the local cache
-				for profileName, profile := range enforcer.bpfProfileCache {
-					if _, ok := profile.containerCache[info.ContainerID]; ok {
-						delete(profile.containerCache, info.ContainerID)
-						enforcer.bpfProfileCache[profileName] = profile
-						break
-					}
+		case <-suspensionTicker.C:
+			enforcer.do(func() { enforcer.resumeExpiredSuspensions(time.Now()) })
+
+		case <-pressureTicker.C:
+			enforcer.do(func() { enforcer.checkPressure(time.Now()) })
+
+		case <-warmUpTicker.C:
+			enforcer.warmUps.expire(time.Now())
+
+		case <-hostProcessTicker.C:
+			enforcer.do(enforcer.scanHostProcesses)
+
+		case <-gcTicker.C:
+			enforcer.do(func() {
+				count, err := enforcer.collectStaleMntNs()
+				if err != nil {
+					logger.Error(err, "collectStaleMntNs() failed")
+				} else if count != 0 {
+					logger.Info("the stale mnt ns were collected", "count", count)
 				}
-			}
 
-		case <-enforcer.TaskDeleteSyncCh:
-			// Handle those containers that exit while the monitor was offline
-			for profileName, profile := range enforcer.bpfProfileCache {
-				for containerID, enforceID := range profile.containerCache {
-					_, err := enforcer.newEnforceID(enforceID.pid)
-					if err != nil {
-						// maybe the container had already exited
-						logger.Info("the target container exited while the monitor was offline",
-							"container id", containerID,
-							"pid", enforceID.pid)
-
-						// delete the BPF profile of the container
-						enforcer.deleteProfile(enforceID.mntNsID)
-
-						// delete the container from the global cache
-						delete(enforcer.containerCache, containerID)
-
-						// delete the container from the local cache
-						delete(profile.containerCache, containerID)
-						enforcer.bpfProfileCache[profileName] = profile
-					}
+				count = enforcer.collectLeakedMntNs()
+				if count != 0 {
+					logger.Info("the leaked mnt ns were collected", "count", count)
 				}
-			}
+			})
 
 		case <-stopCh:
-			logger.Info("stop handle the containerd events")
+			logger.Info("stop handle the containerd events, drain the pending events")
+			enforcer.drainEvents()
+			if enforcer.workers != nil {
+				enforcer.workers.stop()
+			}
+			enforcer.enrichPendingViolations(time.Now(), true)
+			enforcer.flushViolationAggregates(time.Now(), true)
 			return
 		}
 	}
 }
 
+// Run handles the container events and the violation events until the stopCh is closed.
+// Call Shutdown after the stopCh is closed to release the BPF resources gracefully.
 func (enforcer *BpfEnforcer) Run(stopCh <-chan struct{}) {
+	enforcer.running.Store(true)
+	if enforcer.opts.Workers > 1 {
+		enforcer.workers = newWorkerPool(enforcer.opts.Workers, enforcer.opts.TaskChannelCapacity)
+	}
+	go enforcer.regexWatcher.run(stopCh)
+	if enforcer.violationReader != nil {
+		go enforcer.readViolations()
+	}
 	enforcer.eventHandler(stopCh)
 }
 
 func (enforcer *BpfEnforcer) pretreatment(bpfContent *varmor.BpfContent) {
 	// Disk Device
-	for index, file := range bpfContent.Files {
-		if file.Pattern.Prefix == "{{.DiskDevices}}" {
-			bpfContent.Files = append(bpfContent.Files[:index], bpfContent.Files[index+1:]...)
+	// The placeholder is replaced in place to preserve the order of rules, which is used as the priority
+	// when the rules need to be truncated.
+	files := make([]varmor.FileContent, 0, len(bpfContent.Files))
+	for _, file := range bpfContent.Files {
+		if file.Pattern.Prefix != "{{.DiskDevices}}" {
+			files = append(files, file)
+			continue
+		}
 
-			devices, err := lsmutils.RetrieveDiskDeviceList()
-			if err != nil {
-				enforcer.log.Error(err, "lsmutils.RetrieveDiskDeviceList()")
-				break
-			}
+		devices, err := lsmutils.RetrieveDiskDeviceList()
+		if err != nil {
+			enforcer.log.Error(err, "lsmutils.RetrieveDiskDeviceList()")
+			continue
+		}
 
-			for _, device := range devices {
-				content := varmor.FileContent{
-					Permissions: file.Permissions,
-					Pattern: varmor.PathPattern{
-						Flags:  file.Pattern.Flags,
-						Prefix: "/dev/" + device,
-					},
-				}
-				bpfContent.Files = append(bpfContent.Files, content)
+		for _, device := range devices {
+			content := varmor.FileContent{
+				Permissions: file.Permissions,
+				Pattern: varmor.PathPattern{
+					Flags:  file.Pattern.Flags,
+					Prefix: "/dev/" + device,
+				},
+				RuleID: file.RuleID,
 			}
+			files = append(files, content)
 		}
 	}
+	bpfContent.Files = files
 
-	for index, mount := range bpfContent.Mounts {
-		if mount.Pattern.Prefix == "{{.DiskDevices}}" {
-			bpfContent.Mounts = append(bpfContent.Mounts[:index], bpfContent.Mounts[index+1:]...)
+	mounts := make([]varmor.MountContent, 0, len(bpfContent.Mounts))
+	for _, mount := range bpfContent.Mounts {
+		if mount.Pattern.Prefix != "{{.DiskDevices}}" {
+			mounts = append(mounts, mount)
+			continue
+		}
 
-			devices, err := lsmutils.RetrieveDiskDeviceList()
-			if err != nil {
-				enforcer.log.Error(err, "lsmutils.RetrieveDiskDeviceList()")
-				break
-			}
+		devices, err := lsmutils.RetrieveDiskDeviceList()
+		if err != nil {
+			enforcer.log.Error(err, "lsmutils.RetrieveDiskDeviceList()")
+			continue
+		}
 
-			for _, device := range devices {
-				content := varmor.MountContent{
-					MountFlags:        mount.MountFlags,
-					ReverseMountflags: mount.ReverseMountflags,
-					Fstype:            mount.Fstype,
-					Pattern: varmor.PathPattern{
-						Flags:  mount.Pattern.Flags,
-						Prefix: "/dev/" + device,
-					},
-				}
-				bpfContent.Mounts = append(bpfContent.Mounts, content)
+		for _, device := range devices {
+			content := varmor.MountContent{
+				MountFlags:        mount.MountFlags,
+				ReverseMountflags: mount.ReverseMountflags,
+				Fstype:            mount.Fstype,
+				Pattern: varmor.PathPattern{
+					Flags:  mount.Pattern.Flags,
+					Prefix: "/dev/" + device,
+				},
+				RuleID: mount.RuleID,
 			}
-			break
+			mounts = append(mounts, content)
 		}
 	}
+	bpfContent.Mounts = mounts
+
+	// Network Macros
+	networks, unresolved := expandNetworkMacros(bpfContent.Networks, enforcer.networkMacros)
+	if len(unresolved) != 0 {
+		enforcer.log.Info("the network macros aren't configured, their rules are ignored", "macros", unresolved)
+	}
+	bpfContent.Networks = networks
+
+	// Services and Pods
+	expandNetworkPeers(bpfContent)
 }
 
-// SaveAndApplyBpfProfile save the BPF profile to the cache, and update it to the kernel for the existing BPF profile
-func (enforcer *BpfEnforcer) SaveAndApplyBpfProfile(profileName string, bpfContent varmor.BpfContent) error {
+// truncateBpfContent drops the rules that exceed the limits of the BPF enforcer. The rules are kept in the order
+// they were generated, so the built-in rules take precedence over the custom rules. It returns the description of
+// the dropped rules.
+func truncateBpfContent(bpfContent *varmor.BpfContent) []string {
+	var dropped []string
+
+	if len(bpfContent.Files) > varmortypes.MaxBpfFileRuleCount {
+		dropped = append(dropped, fmt.Sprintf("%d file rules", len(bpfContent.Files)-varmortypes.MaxBpfFileRuleCount))
+		bpfContent.Files = bpfContent.Files[:varmortypes.MaxBpfFileRuleCount]
+	}
+
+	if len(bpfContent.Processes) > varmortypes.MaxBpfBprmRuleCount {
+		dropped = append(dropped, fmt.Sprintf("%d bprm rules", len(bpfContent.Processes)-varmortypes.MaxBpfBprmRuleCount))
+		bpfContent.Processes = bpfContent.Processes[:varmortypes.MaxBpfBprmRuleCount]
+	}
+
+	if len(bpfContent.Networks) > varmortypes.MaxBpfNetworkRuleCount {
+		dropped = append(dropped, fmt.Sprintf("%d network rules", len(bpfContent.Networks)-varmortypes.MaxBpfNetworkRuleCount))
+		bpfContent.Networks = bpfContent.Networks[:varmortypes.MaxBpfNetworkRuleCount]
+	}
+
+	if len(bpfContent.Mounts) > varmortypes.MaxBpfMountRuleCount {
+		dropped = append(dropped, fmt.Sprintf("%d mount rules", len(bpfContent.Mounts)-varmortypes.MaxBpfMountRuleCount))
+		bpfContent.Mounts = bpfContent.Mounts[:varmortypes.MaxBpfMountRuleCount]
+	}
+
+	return dropped
+}
+
+// SaveAndApplyBpfProfile save the BPF profile to the cache, and update it to the kernel for the existing BPF profile.
+// The rules that exceed the limits of the BPF enforcer are dropped, and a warning describing them is returned.
+func (enforcer *BpfEnforcer) SaveAndApplyBpfProfile(ctx context.Context, profileName string, bpfContent varmor.BpfContent) (warning string, err error) {
+	ctx, span := tracer.Start(ctx, "BpfEnforcer.SaveAndApplyBpfProfile", trace.WithAttributes(attribute.String("profile.name", profileName)))
+	defer func() { endSpan(span, err) }()
+
+	if !enforcer.acquire() {
+		return "", errEnforcerClosed
+	}
+	defer enforcer.release()
+
 	enforcer.pretreatment(&bpfContent)
 
+	if dropped := truncateBpfContent(&bpfContent); len(dropped) != 0 {
+		warning = fmt.Sprintf("the maximum number of BPF rules exceeded, %s are dropped", strings.Join(dropped, ", "))
+		enforcer.log.Info("the BPF profile is truncated", "profile", profileName, "dropped", dropped)
+	}
+
 	// save/update the BPF profile to the cache
+	networksOnly := false
 	if profile, ok := enforcer.bpfProfileCache[profileName]; ok {
 		if reflect.DeepEqual(bpfContent, profile.bpfContent) {
 			// nothing need to update
 			enforcer.log.V(3).Info("the BPF profile is not changed, nothing need to update", "profile", profileName, "old", profile.bpfContent)
-			return nil
+			return warning, nil
 		}
+		// Only the network rules are reprogrammed when the addresses of the Services and Pods change
+		networksOnly = onlyNetworksChanged(profile.bpfContent, bpfContent)
 		enforcer.log.V(3).Info("update the BPF profile", "profile", profileName, "new", bpfContent)
 		profile.bpfContent = bpfContent
+		profile.hash = profileHash(&bpfContent)
 		enforcer.bpfProfileCache[profileName] = profile
 	} else {
 		enforcer.log.V(3).Info("save the BPF profile", "profile", profileName, "new", bpfContent)
 		profile := bpfProfile{
 			bpfContent:     bpfContent,
+			hash:           profileHash(&bpfContent),
 			containerCache: make(map[string]enforceID),
 		}
 		enforcer.bpfProfileCache[profileName] = profile
 	}
+	// the maps staged from the previous profile are outdated
+	enforcer.warmUps.discardProfile(profileName)
 
 	// apply the BPF profile to the kernel for the existing containers
 	profile := enforcer.bpfProfileCache[profileName]
-	for _, enforceID := range profile.containerCache {
-		enforcer.log.V(3).Info("apply the BPF profile", "profile", profileName, "new", profile.bpfContent)
-		err := enforcer.applyProfile(enforceID.mntNsID, profile.bpfContent)
+	var failed []string
+	for containerID, enforceID := range profile.containerCache {
+		enforcer.log.V(3).Info("apply the BPF profile", "profile", profileName, "new", profile.bpfContent, "networks only", networksOnly)
+		var err error
+		enforcer.journalApply(journalPhaseBegin, containerID, enforceID, profileName, profile.hash, nil)
+		if networksOnly {
+			err = enforcer.applyNetworkRules(enforceID.mntNsID, enforcer.expandProfile(containerID, enforceID, profile.bpfContent))
+		} else {
+			err = enforcer.applyProfileWithSpan(ctx, profileName, containerID, enforceID, profile.bpfContent)
+		}
 		if err != nil {
-			return err
+			// The previous rules are still enforced for the container
+			enforcer.journalApply(journalPhaseFailed, containerID, enforceID, profileName, profile.hash, err)
+			enforcer.log.Error(err, "applyProfile() failed", "profile name", profileName, "container id", containerID)
+			enforcer.addDeadLetter(containerID, profileName, enforceID, err)
+			failed = append(failed, containerID)
+			continue
 		}
+		enforcer.journalApply(journalPhaseDone, containerID, enforceID, profileName, profile.hash, nil)
+		enforcer.removeDeadLetter(containerID)
 	}
-	return nil
+
+	// apply the BPF profile again for the containers that it failed to apply to
+	enforcer.retryDeadLetters(profileName)
+
+	// enforce the containers recorded by the previous enforcer which crashed
+	enforcer.replayJournal(profileName)
+
+	// apply the BPF profiles again for the containers layered on the profile
+	failed = append(failed, enforcer.reapplyLayeredContainers(ctx, profileName)...)
+
+	if len(failed) != 0 {
+		return warning, fmt.Errorf("failed to apply the BPF profile to the containers: %s", strings.Join(failed, ", "))
+	}
+	return warning, nil
 }
 
 // DeleteBpfProfile unload the BPF profile from kernel, then delete it from the cache
-func (enforcer *BpfEnforcer) DeleteBpfProfile(profileName string) error {
+func (enforcer *BpfEnforcer) DeleteBpfProfile(ctx context.Context, profileName string) (err error) {
+	_, span := tracer.Start(ctx, "BpfEnforcer.DeleteBpfProfile", trace.WithAttributes(attribute.String("profile.name", profileName)))
+	defer func() { endSpan(span, err) }()
+
+	if !enforcer.acquire() {
+		return errEnforcerClosed
+	}
+	defer enforcer.release()
+
+	var failed []string
 	if profile, ok := enforcer.bpfProfileCache[profileName]; ok {
 		for containerID, enforceID := range profile.containerCache {
-			// unload the BPF profile from the kernel
-			enforcer.deleteProfile(enforceID.mntNsID)
+			// unload the BPF profile from the kernel, the entries that failed to be deleted are left to the
+			// garbage collection
+			err := enforcer.deleteProfileWithRetry(enforceID.mntNsID)
+			if err != nil {
+				enforcer.log.Error(err, "deleteProfile() failed", "profile name", profileName, "container id", containerID)
+				failed = append(failed, containerID)
+			}
+			enforcer.regexWatcher.unwatch(containerID)
+			enforcer.journalDelete(containerID, enforceID)
 
 			// delete the container from the global cache
 			delete(enforcer.containerCache, containerID)
+			delete(enforcer.containerInfos, containerID)
+			delete(enforcer.conflicts, containerID)
+			delete(enforcer.layers, containerID)
 		}
 		// delete the profile from the bpfProfileCache
 		delete(enforcer.bpfProfileCache, profileName)
+		enforcer.removeDeadLettersOfProfile(profileName)
+		enforcer.removePendingOfProfile(profileName)
+		enforcer.warmUps.discardProfile(profileName)
+
+		// the containers layered on the profile are enforced with their own profiles alone
+		failed = append(failed, enforcer.reapplyLayeredContainers(ctx, profileName)...)
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("failed to delete the BPF profile of the containers: %s", strings.Join(failed, ", "))
 	}
 	return nil
 }
 
+// applyProfileWithSpan expands and applies the BPF profile for the container in a child span of ctx
+func (enforcer *BpfEnforcer) applyProfileWithSpan(ctx context.Context, profileName string, containerID string, id enforceID, bpfContent varmor.BpfContent) error {
+	_, span := tracer.Start(ctx, "BpfEnforcer.applyProfile", trace.WithAttributes(
+		attribute.String("profile.name", profileName),
+		attribute.String("container.id", containerID),
+		attribute.Int64("mnt_ns.id", int64(id.mntNsID))))
+
+	var err error
+	bpfContent = enforcer.expandProfile(containerID, id, bpfContent)
+	if !enforcer.applyWarmedProfile(containerID, id, bpfContent) {
+		err = enforcer.tryApplyProfile(id.mntNsID, bpfContent)
+	}
+	if err == nil {
+		enforcer.mapMemory.setProfile(id.mntNsID, profileName)
+	}
+	endSpan(span, err)
+	return err
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ApplyBpfProfileToProcess applies the BPF profile to the mnt ns of the process (or thread) directly without caching it,
+// and returns the mnt ns id. It's used to test the BPF profiles outside the cluster, the regular expressions of the file
+// rules are expanded once and won't be refreshed.
+func (enforcer *BpfEnforcer) ApplyBpfProfileToProcess(pid uint32, bpfContent varmor.BpfContent) (uint32, error) {
+	// The caches aren't touched, so it can be called concurrently
+	if !enforcer.acquireShared() {
+		return 0, errEnforcerClosed
+	}
+	defer enforcer.releaseShared()
+
+	id, err := enforcer.newEnforceID(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	enforcer.pretreatment(&bpfContent)
+	truncateBpfContent(&bpfContent)
+
+	if len(bpfContent.RegexFiles) != 0 {
+		files, processes, _, overlong, err := expandRegexFileRules(pid, bpfContent.RegexFiles)
+		if err != nil {
+			return 0, err
+		}
+		if len(overlong) != 0 {
+			enforcer.log.Info("WARNING: the paths matched by the regular expressions exceed the maximum length of the rules, they are ignored",
+				"pid", pid, "paths", overlong, "max length", varmortypes.MaxFilePathPatternLength-1)
+		}
+		bpfContent.Files = append(bpfContent.Files, files...)
+		bpfContent.Processes = append(bpfContent.Processes, processes...)
+		truncateBpfContent(&bpfContent)
+	}
+
+	return id.mntNsID, enforcer.applyProfile(id.mntNsID, bpfContent)
+}
+
+// DeleteBpfProfileOfMntNs unloads the BPF profile applied by ApplyBpfProfileToProcess from the kernel
+func (enforcer *BpfEnforcer) DeleteBpfProfileOfMntNs(mntNsID uint32) error {
+	if !enforcer.acquireShared() {
+		return errEnforcerClosed
+	}
+	defer enforcer.releaseShared()
+
+	return enforcer.deleteProfileWithRetry(mntNsID)
+}
+
 func (enforcer *BpfEnforcer) IsBpfProfileExist(profileName string) bool {
 	_, ok := enforcer.bpfProfileCache[profileName]
 	return ok
@@ -0,0 +1,111 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func newFileContents(count int) []varmor.FileContent {
+	files := make([]varmor.FileContent, 0, count)
+	for i := 0; i < count; i++ {
+		file := varmor.FileContent{
+			Permissions: aaMayExec,
+			Pattern:     varmor.PathPattern{Flags: preciseMatch | prefixMatch, Prefix: fmt.Sprintf("/bin/%d", i)},
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func newMountContents(count int) []varmor.MountContent {
+	mounts := make([]varmor.MountContent, 0, count)
+	for i := 0; i < count; i++ {
+		mount := varmor.MountContent{
+			Fstype:  "tmpfs",
+			Pattern: varmor.PathPattern{Flags: preciseMatch | prefixMatch, Prefix: fmt.Sprintf("/mnt/%d", i)},
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+func Test_truncateBpfContent(t *testing.T) {
+	testCases := []struct {
+		name              string
+		bpfContent        varmor.BpfContent
+		expectedDropped   []string
+		expectedFiles     int
+		expectedProcesses int
+		expectedNetworks  int
+		expectedMounts    int
+	}{
+		{
+			name: "within the limits",
+			bpfContent: varmor.BpfContent{
+				Files:     newFileContents(varmortypes.MaxBpfFileRuleCount),
+				Processes: newFileContents(3),
+				Mounts:    newMountContents(1),
+			},
+			expectedFiles:     varmortypes.MaxBpfFileRuleCount,
+			expectedProcesses: 3,
+			expectedMounts:    1,
+		},
+		{
+			name: "files, processes and networks",
+			bpfContent: varmor.BpfContent{
+				Files:     newFileContents(varmortypes.MaxBpfFileRuleCount + 1),
+				Processes: newFileContents(varmortypes.MaxBpfBprmRuleCount + 2),
+				Networks:  make([]varmor.NetworkContent, varmortypes.MaxBpfNetworkRuleCount+3),
+			},
+			expectedDropped: []string{
+				"1 file rules",
+				"2 bprm rules",
+				"3 network rules",
+			},
+			expectedFiles:     varmortypes.MaxBpfFileRuleCount,
+			expectedProcesses: varmortypes.MaxBpfBprmRuleCount,
+			expectedNetworks:  varmortypes.MaxBpfNetworkRuleCount,
+		},
+		{
+			name: "mounts",
+			bpfContent: varmor.BpfContent{
+				Mounts: newMountContents(varmortypes.MaxBpfMountRuleCount + 1),
+			},
+			expectedDropped: []string{
+				"1 mount rules",
+			},
+			expectedMounts: varmortypes.MaxBpfMountRuleCount,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dropped := truncateBpfContent(&tc.bpfContent)
+			assert.DeepEqual(t, dropped, tc.expectedDropped)
+
+			assert.Equal(t, len(tc.bpfContent.Files), tc.expectedFiles)
+			assert.Equal(t, len(tc.bpfContent.Processes), tc.expectedProcesses)
+			assert.Equal(t, len(tc.bpfContent.Networks), tc.expectedNetworks)
+			assert.Equal(t, len(tc.bpfContent.Mounts), tc.expectedMounts)
+		})
+	}
+}
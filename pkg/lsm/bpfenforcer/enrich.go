@@ -0,0 +1,205 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"expvar"
+	"time"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// violationEnrichTimeout is the maximum time to wait for the container of a violation event to be cached.
+	// The event is reported without the Kubernetes metadata after it.
+	violationEnrichTimeout = 3 * time.Second
+	// violationEnrichInterval is the interval for enriching the pending violation events again
+	violationEnrichInterval = time.Second
+	// maxPendingViolations caps the violation events that wait for the container to be cached
+	maxPendingViolations = 1024
+	// exitedContainerRetention is the period to keep the metadata of the exited containers, so the violation
+	// events that arrive after the containers exit can still be enriched
+	exitedContainerRetention = 30 * time.Second
+)
+
+var (
+	pendingViolationCount    = new(expvar.Int)
+	unenrichedViolationCount = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("pending_violations", pendingViolationCount)
+	metrics.Set("unenriched_violations_total", unenrichedViolationCount)
+}
+
+// violationContainer is the metadata used to enrich the violation events of a mnt ns
+type violationContainer struct {
+	profileName string
+	info        varmortypes.ContainerInfo
+	expiration  time.Time
+}
+
+// pendingViolation is a violation event whose container hasn't been cached yet
+type pendingViolation struct {
+	event     bpfViolationEvent
+	ruleID    string
+	timestamp time.Time
+}
+
+// lookupViolationContainer finds the container of the mnt ns from the caches, including the containers
+// that exited recently
+func (enforcer *BpfEnforcer) lookupViolationContainer(mntNsID uint32, now time.Time) (violationContainer, bool) {
+	for profileName, profile := range enforcer.bpfProfileCache {
+		for containerID, enforceID := range profile.containerCache {
+			if enforceID.mntNsID != mntNsID {
+				continue
+			}
+			info, ok := enforcer.containerInfos[containerID]
+			if !ok {
+				info = varmortypes.ContainerInfo{ContainerID: containerID, PID: enforceID.pid}
+			}
+			return violationContainer{profileName: profileName, info: info}, true
+		}
+	}
+
+	if container, ok := enforcer.exitedContainers[mntNsID]; ok && now.Before(container.expiration) {
+		return container, true
+	}
+	return violationContainer{}, false
+}
+
+// rememberExitedContainer keeps the metadata of the container for a while after it exits
+func (enforcer *BpfEnforcer) rememberExitedContainer(containerID string, id enforceID, now time.Time) {
+	info, ok := enforcer.containerInfos[containerID]
+	if !ok {
+		return
+	}
+
+	for profileName, profile := range enforcer.bpfProfileCache {
+		if _, ok := profile.containerCache[containerID]; ok {
+			if enforcer.exitedContainers == nil {
+				enforcer.exitedContainers = make(map[uint32]violationContainer)
+			}
+			enforcer.exitedContainers[id.mntNsID] = violationContainer{
+				profileName: profileName,
+				info:        info,
+				expiration:  now.Add(exitedContainerRetention),
+			}
+			return
+		}
+	}
+}
+
+// newViolation builds the violation of the event, the Kubernetes metadata is filled if the container is known
+func newViolation(event *bpfViolationEvent, ruleID string, timestamp time.Time, container *violationContainer) varmortypes.Violation {
+	violation := varmortypes.Violation{
+		RuleType:      ruleTypeNames[event.RuleType],
+		RuleID:        ruleID,
+		Permissions:   event.Permissions,
+		Capability:    -1,
+		Syscall:       -1,
+		PID:           event.Tgid,
+		MntNsID:       event.MntNsID,
+		Timestamp:     timestamp,
+		LastTimestamp: timestamp,
+		Count:         1,
+	}
+	if event.RuleType == capabilityRuleType && event.Capability != unknownContext {
+		violation.Capability = int32(event.Capability)
+	}
+	if event.Syscall != unknownContext {
+		violation.Syscall = int32(event.Syscall)
+	}
+
+	if container != nil {
+		violation.Enriched = true
+		violation.ProfileName = container.profileName
+		violation.ContainerID = container.info.ContainerID
+		violation.ContainerName = container.info.ContainerName
+		violation.Image = container.info.Image
+		violation.PodNamespace = container.info.PodNamespace
+		violation.PodName = container.info.PodName
+		violation.PodUID = container.info.PodUID
+		violation.PodLabels = container.info.PodLabels
+		violation.ServiceAccount = container.info.ServiceAccount
+	}
+
+	// The violations of the rules layered from the base profile are attributed to it
+	if baseName, id := splitLayeredRuleID(ruleID); baseName != "" {
+		violation.RuleID = id
+		if container != nil {
+			violation.ProfileName = baseName
+		}
+	}
+	return violation
+}
+
+// enrichViolation reports the violation event with the Kubernetes metadata. The event waits for a while
+// if its container hasn't been cached, e.g. the violation occurs before the task create event is handled.
+func (enforcer *BpfEnforcer) enrichViolation(event *bpfViolationEvent, ruleID string, now time.Time) {
+	if container, ok := enforcer.lookupViolationContainer(event.MntNsID, now); ok {
+		enforcer.reportViolation(newViolation(event, ruleID, now, &container))
+		return
+	}
+
+	if len(enforcer.pendingViolations) >= maxPendingViolations {
+		// Report the oldest one without waiting to make room
+		oldest := enforcer.pendingViolations[0]
+		enforcer.pendingViolations = enforcer.pendingViolations[1:]
+		unenrichedViolationCount.Add(1)
+		enforcer.reportViolation(newViolation(&oldest.event, oldest.ruleID, oldest.timestamp, nil))
+	}
+	enforcer.pendingViolations = append(enforcer.pendingViolations, pendingViolation{
+		event:     *event,
+		ruleID:    ruleID,
+		timestamp: now,
+	})
+	pendingViolationCount.Set(int64(len(enforcer.pendingViolations)))
+}
+
+// enrichPendingViolations enriches the pending violation events again. The events that time out are reported
+// without the Kubernetes metadata, or all of them if flush is true. It also drops the expired exited containers.
+func (enforcer *BpfEnforcer) enrichPendingViolations(now time.Time, flush bool) {
+	for mntNsID, container := range enforcer.exitedContainers {
+		if !now.Before(container.expiration) {
+			delete(enforcer.exitedContainers, mntNsID)
+		}
+	}
+
+	if len(enforcer.pendingViolations) == 0 {
+		return
+	}
+
+	pending := enforcer.pendingViolations[:0]
+	for _, p := range enforcer.pendingViolations {
+		if p.ruleID == "" {
+			p.ruleID = enforcer.ruleIDs.resolve(p.event.MntNsID, p.event.RuleType, p.event.RuleIndex)
+		}
+
+		if container, ok := enforcer.lookupViolationContainer(p.event.MntNsID, now); ok {
+			enforcer.reportViolation(newViolation(&p.event, p.ruleID, p.timestamp, &container))
+			continue
+		}
+
+		if flush || now.Sub(p.timestamp) >= violationEnrichTimeout {
+			unenrichedViolationCount.Add(1)
+			enforcer.reportViolation(newViolation(&p.event, p.ruleID, p.timestamp, nil))
+			continue
+		}
+		pending = append(pending, p)
+	}
+	enforcer.pendingViolations = pending
+	pendingViolationCount.Set(int64(len(enforcer.pendingViolations)))
+}
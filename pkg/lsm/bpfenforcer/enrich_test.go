@@ -0,0 +1,129 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func newEnrichTestEnforcer(sink *[]varmortypes.Violation) *BpfEnforcer {
+	return &BpfEnforcer{
+		opts: Options{
+			ViolationSink: func(v varmortypes.Violation) { *sink = append(*sink, v) },
+		},
+		bpfProfileCache:  make(map[string]bpfProfile),
+		containerCache:   make(map[string]enforceID),
+		containerInfos:   make(map[string]varmortypes.ContainerInfo),
+		exitedContainers: make(map[uint32]violationContainer),
+		ruleIDs:          newRuleIDStore(),
+	}
+}
+
+func (enforcer *BpfEnforcer) addTestContainer(profileName string, info varmortypes.ContainerInfo, mntNsID uint32) {
+	id := enforceID{pid: info.PID, mntNsID: mntNsID}
+	profile, ok := enforcer.bpfProfileCache[profileName]
+	if !ok {
+		profile = bpfProfile{containerCache: make(map[string]enforceID)}
+	}
+	profile.containerCache[info.ContainerID] = id
+	enforcer.bpfProfileCache[profileName] = profile
+	enforcer.containerCache[info.ContainerID] = id
+	enforcer.containerInfos[info.ContainerID] = info
+}
+
+func Test_enrichViolation(t *testing.T) {
+	var violations []varmortypes.Violation
+	enforcer := newEnrichTestEnforcer(&violations)
+	info := varmortypes.ContainerInfo{
+		PID:           100,
+		ContainerID:   "c1",
+		ContainerName: "nginx",
+		Image:         "docker.io/library/nginx:latest",
+		PodName:       "nginx-0",
+		PodNamespace:  "default",
+		PodUID:        "uid",
+		PodLabels:     map[string]string{"app": "nginx"},
+	}
+	now := time.Now()
+
+	// The container is cached
+	enforcer.addTestContainer("p1", info, 1)
+	enforcer.enrichViolation(&bpfViolationEvent{MntNsID: 1, Tgid: 101, RuleType: fileRuleType}, "rule-1", now)
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Enriched, true)
+	assert.Equal(t, violations[0].ProfileName, "p1")
+	assert.Equal(t, violations[0].Image, info.Image)
+	assert.DeepEqual(t, violations[0].PodLabels, info.PodLabels)
+	assert.Equal(t, violations[0].PID, uint32(101))
+	assert.Equal(t, violations[0].RuleType, "file")
+
+	// The event arrives before the container is cached
+	enforcer.enrichViolation(&bpfViolationEvent{MntNsID: 2, RuleType: bprmRuleType}, "", now)
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, len(enforcer.pendingViolations), 1)
+
+	info2 := info
+	info2.ContainerID = "c2"
+	enforcer.addTestContainer("p1", info2, 2)
+	enforcer.enrichPendingViolations(now.Add(time.Second), false)
+	assert.Equal(t, len(violations), 2)
+	assert.Equal(t, violations[1].ContainerID, "c2")
+	assert.Equal(t, violations[1].Timestamp, now)
+	assert.Equal(t, len(enforcer.pendingViolations), 0)
+
+	// The container is never cached
+	enforcer.enrichViolation(&bpfViolationEvent{MntNsID: 3}, "", now)
+	enforcer.enrichPendingViolations(now.Add(time.Second), false)
+	assert.Equal(t, len(violations), 2)
+	enforcer.enrichPendingViolations(now.Add(violationEnrichTimeout), false)
+	assert.Equal(t, len(violations), 3)
+	assert.Equal(t, violations[2].Enriched, false)
+	assert.Equal(t, violations[2].MntNsID, uint32(3))
+
+	// Flush the pending events
+	enforcer.enrichViolation(&bpfViolationEvent{MntNsID: 4}, "", now)
+	enforcer.enrichPendingViolations(now, true)
+	assert.Equal(t, len(violations), 4)
+	assert.Equal(t, len(enforcer.pendingViolations), 0)
+}
+
+func Test_rememberExitedContainer(t *testing.T) {
+	var violations []varmortypes.Violation
+	enforcer := newEnrichTestEnforcer(&violations)
+	info := varmortypes.ContainerInfo{PID: 100, ContainerID: "c1", PodName: "nginx-0", PodNamespace: "default"}
+	enforcer.addTestContainer("p1", info, 1)
+	now := time.Now()
+
+	enforcer.rememberExitedContainer("c1", enforcer.containerCache["c1"], now)
+	delete(enforcer.containerCache, "c1")
+	delete(enforcer.containerInfos, "c1")
+	delete(enforcer.bpfProfileCache["p1"].containerCache, "c1")
+
+	container, ok := enforcer.lookupViolationContainer(1, now.Add(time.Second))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, container.profileName, "p1")
+	assert.Equal(t, container.info.PodName, "nginx-0")
+
+	_, ok = enforcer.lookupViolationContainer(1, now.Add(exitedContainerRetention))
+	assert.Equal(t, ok, false)
+
+	enforcer.enrichPendingViolations(now.Add(exitedContainerRetention), false)
+	assert.Equal(t, len(enforcer.exitedContainers), 0)
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// builtInRuleIDPrefixes are the prefixes of the rule IDs which are generated by the built-in rules
+var builtInRuleIDPrefixes = []string{"hardeningRules/", "attackProtectionRules/", "vulMitigationRules/"}
+
+// parseRuleExceptions returns the built-in rules excepted by the annotation of the pod
+func parseRuleExceptions(annotations map[string]string) map[string]bool {
+	value := annotations[varmortypes.RuleExceptionsAnnotation]
+	if value == "" {
+		return nil
+	}
+
+	exceptions := make(map[string]bool)
+	for _, rule := range strings.Split(value, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			exceptions[rule] = true
+		}
+	}
+	return exceptions
+}
+
+// isExcepted returns true if the rule ID is generated by one of the excepted built-in rules
+func isExcepted(ruleID string, exceptions map[string]bool) bool {
+	for _, prefix := range builtInRuleIDPrefixes {
+		if strings.HasPrefix(ruleID, prefix) {
+			return exceptions[ruleID[len(prefix):]]
+		}
+	}
+	return false
+}
+
+// exceptRules removes the rules generated by the built-in rules which are excepted by the annotation of the pod.
+// The capabilities can't be attributed to the rules, so the rules implemented by disabling capabilities are
+// still enforced.
+func exceptRules(bpfContent varmor.BpfContent, annotations map[string]string) varmor.BpfContent {
+	exceptions := parseRuleExceptions(annotations)
+	if len(exceptions) == 0 {
+		return bpfContent
+	}
+
+	// Don't modify the cached profile
+	filterFiles := func(files []varmor.FileContent) []varmor.FileContent {
+		var result []varmor.FileContent
+		for _, file := range files {
+			if !isExcepted(file.RuleID, exceptions) {
+				result = append(result, file)
+			}
+		}
+		return result
+	}
+	bpfContent.Files = filterFiles(bpfContent.Files)
+	bpfContent.Processes = filterFiles(bpfContent.Processes)
+
+	var networks []varmor.NetworkContent
+	for _, network := range bpfContent.Networks {
+		if !isExcepted(network.RuleID, exceptions) {
+			networks = append(networks, network)
+		}
+	}
+	bpfContent.Networks = networks
+
+	var mounts []varmor.MountContent
+	for _, mount := range bpfContent.Mounts {
+		if !isExcepted(mount.RuleID, exceptions) {
+			mounts = append(mounts, mount)
+		}
+	}
+	bpfContent.Mounts = mounts
+
+	var regexFiles []varmor.RegexFileContent
+	for _, regexFile := range bpfContent.RegexFiles {
+		if !isExcepted(regexFile.RuleID, exceptions) {
+			regexFiles = append(regexFiles, regexFile)
+		}
+	}
+	bpfContent.RegexFiles = regexFiles
+
+	// The ptrace rule is merged from multiple rules, it's only removed when all of them are excepted
+	if bpfContent.Ptrace != nil {
+		excepted := true
+		for _, ruleID := range strings.Split(bpfContent.Ptrace.RuleID, ",") {
+			if !isExcepted(ruleID, exceptions) {
+				excepted = false
+				break
+			}
+		}
+		if excepted {
+			bpfContent.Ptrace = nil
+		}
+	}
+
+	return bpfContent
+}
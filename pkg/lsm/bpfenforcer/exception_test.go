@@ -0,0 +1,96 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_exceptRules(t *testing.T) {
+	bpfContent := varmor.BpfContent{
+		Capabilities: 1,
+		Files: []varmor.FileContent{
+			{RuleID: "hardeningRules/disallow-write-core-pattern"},
+			{RuleID: "attackProtectionRules/disable-write-etc"},
+			{RuleID: "bpfRawRules.files/0"},
+		},
+		Processes: []varmor.FileContent{
+			{RuleID: "attackProtectionRules/disable-shell"},
+		},
+		Mounts: []varmor.MountContent{
+			{RuleID: "hardeningRules/disallow-mount"},
+		},
+		Ptrace: &varmor.PtraceContent{
+			RuleID: "runtimeDefault,hardeningRules/disallow-access-procfs-root",
+		},
+	}
+
+	testCases := []struct {
+		name              string
+		annotations       map[string]string
+		expectedFiles     int
+		expectedProcesses int
+		expectedMounts    int
+		expectedPtrace    bool
+	}{
+		{
+			name:              "noException",
+			annotations:       map[string]string{},
+			expectedFiles:     3,
+			expectedProcesses: 1,
+			expectedMounts:    1,
+			expectedPtrace:    true,
+		},
+		{
+			name: "exceptBuiltInRules",
+			annotations: map[string]string{
+				varmortypes.RuleExceptionsAnnotation: "disable-shell, disallow-mount,disallow-access-procfs-root",
+			},
+			expectedFiles:     3,
+			expectedProcesses: 0,
+			expectedMounts:    0,
+			expectedPtrace:    true,
+		},
+		{
+			name: "rawRulesCannotBeExcepted",
+			annotations: map[string]string{
+				varmortypes.RuleExceptionsAnnotation: "disable-write-etc,bpfRawRules.files/0,0",
+			},
+			expectedFiles:     2,
+			expectedProcesses: 1,
+			expectedMounts:    1,
+			expectedPtrace:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := exceptRules(bpfContent, tc.annotations)
+			assert.Equal(t, len(result.Files), tc.expectedFiles)
+			assert.Equal(t, len(result.Processes), tc.expectedProcesses)
+			assert.Equal(t, len(result.Mounts), tc.expectedMounts)
+			assert.Equal(t, result.Ptrace != nil, tc.expectedPtrace)
+			assert.Equal(t, result.Capabilities, uint64(1))
+		})
+	}
+
+	// The cached profile is not modified
+	assert.Equal(t, len(bpfContent.Files), 3)
+}
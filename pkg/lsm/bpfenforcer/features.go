@@ -0,0 +1,80 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/cilium/ebpf"
+)
+
+// The optional features of the BPF enforcer
+const (
+	// FeatureViolationEvents means the BPF program emits the violation events
+	FeatureViolationEvents = "violationEvents"
+	// FeatureSelfTest means the self-test of the enforcement passed
+	FeatureSelfTest = "selfTest"
+)
+
+// Features returns whether the optional features of the BPF enforcer work on the node. The result of the
+// self-test is only meaningful after SelfTest is called.
+func (enforcer *BpfEnforcer) Features() map[string]bool {
+	return map[string]bool{
+		FeatureViolationEvents: enforcer.violations != nil,
+		FeatureSelfTest:        enforcer.selfTestErr == nil,
+	}
+}
+
+// ProgramFeatures returns the optional features that the BPF program supports without loading it into the kernel,
+// the embedded BPF program is inspected if the objectPath is empty. Unlike Features, it doesn't tell whether the
+// features work on a node, so the self-test isn't included.
+func ProgramFeatures(objectPath string) (map[string]bool, error) {
+	var collectionSpec *ebpf.CollectionSpec
+	var err error
+	if objectPath != "" {
+		objectPath, err = resolveObjectPath(objectPath, runtime.GOARCH)
+		if err != nil {
+			return nil, err
+		}
+		collectionSpec, err = ebpf.LoadCollectionSpec(objectPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the BPF object %s: %w", objectPath, err)
+		}
+	} else {
+		collectionSpec, err = loadBpf()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return specFeatures(collectionSpec), nil
+}
+
+// specFeatures returns the optional features that the BPF program supports with the same checks as initBPF
+func specFeatures(collectionSpec *ebpf.CollectionSpec) map[string]bool {
+	hasMaps := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := collectionSpec.Maps[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	return map[string]bool{
+		FeatureViolationEvents: hasMaps("v_violations"),
+	}
+}
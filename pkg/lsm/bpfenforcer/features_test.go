@@ -0,0 +1,65 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"gotest.tools/assert"
+)
+
+func Test_ProgramFeatures(t *testing.T) {
+	features, err := ProgramFeatures("")
+	assert.NilError(t, err)
+	expected, err := loadBpf()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, features, specFeatures(expected))
+
+	_, err = ProgramFeatures(filepath.Join(t.TempDir(), "bpf_bpfel.o"))
+	assert.Assert(t, err != nil)
+}
+
+func Test_specFeatures(t *testing.T) {
+	testCases := []struct {
+		name     string
+		maps     []string
+		feature  string
+		expected bool
+	}{
+		{
+			name:     "violation events",
+			maps:     []string{"v_violations"},
+			feature:  FeatureViolationEvents,
+			expected: true,
+		},
+		{
+			name:    "violation events unsupported",
+			maps:    []string{"v_file_outer"},
+			feature: FeatureViolationEvents,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := ebpf.CollectionSpec{Maps: make(map[string]*ebpf.MapSpec)}
+			for _, name := range tc.maps {
+				spec.Maps[name] = &ebpf.MapSpec{Name: name}
+			}
+			assert.Equal(t, specFeatures(&spec)[tc.feature], tc.expected)
+		})
+	}
+}
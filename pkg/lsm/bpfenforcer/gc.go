@@ -0,0 +1,141 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"expvar"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	ebpf "github.com/cilium/ebpf"
+
+	varmorutils "github.com/bytedance/vArmor/pkg/utils"
+)
+
+// mntNsGCInterval is the interval of collecting the stale mnt ns entries of the maps
+const mntNsGCInterval = 10 * time.Minute
+
+var staleMntNsCollected = new(expvar.Int)
+
+func init() {
+	metrics.Set("stale_mnt_ns_collected_total", staleMntNsCollected)
+}
+
+// liveMntNsIDs scans the procfs and returns the mnt ns ids of all live processes
+func liveMntNsIDs() (map[uint32]bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[uint32]bool)
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		// The process may have exited
+		id, err := varmorutils.ReadMntNsID(uint32(pid))
+		if err != nil {
+			continue
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// enforcedMntNsIDs returns the mnt ns ids which have entries in the maps used by the BPF program
+func (enforcer *BpfEnforcer) enforcedMntNsIDs() (map[uint32]bool, error) {
+	ids := make(map[uint32]bool)
+	for _, m := range enforcer.enforcementMaps() {
+		var key, nextKey uint32
+		var err error
+		for err = m.m.NextKey(nil, &nextKey); err == nil; err = m.m.NextKey(&key, &nextKey) {
+			key = nextKey
+			ids[key] = true
+		}
+		if !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// staleMntNsIDs returns the enforced mnt ns ids that no live process has
+func staleMntNsIDs(enforced map[uint32]bool, live map[uint32]bool) []uint32 {
+	var ids []uint32
+	for id := range enforced {
+		if !live[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// collectStaleMntNs removes the entries of the mnt ns that no live process has from the maps. They may linger
+// if the delete events of the containers were missed. It returns the count of the collected mnt ns.
+func (enforcer *BpfEnforcer) collectStaleMntNs() (int, error) {
+	enforced, err := enforcer.enforcedMntNsIDs()
+	if err != nil {
+		return 0, err
+	}
+	if len(enforced) == 0 {
+		return 0, nil
+	}
+
+	live, err := liveMntNsIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, nsID := range staleMntNsIDs(enforced, live) {
+		enforcer.log.Info("collect the stale mnt ns", "mnt ns id", nsID)
+		err = enforcer.deleteProfileWithRetry(nsID)
+		if err != nil {
+			// The entries are deleted again along with the leaked ones
+			enforcer.log.Error(err, "deleteProfile() failed", "mnt ns id", nsID)
+		} else {
+			count++
+		}
+
+		// delete the exited containers from the caches
+		for containerID, enforceID := range enforcer.containerCache {
+			if enforceID.mntNsID != nsID {
+				continue
+			}
+			enforcer.removeDeadLetter(containerID)
+			enforcer.regexWatcher.unwatch(containerID)
+			enforcer.journalDelete(containerID, enforceID)
+			delete(enforcer.containerCache, containerID)
+			delete(enforcer.containerInfos, containerID)
+			delete(enforcer.layers, containerID)
+			delete(enforcer.conflicts, containerID)
+			for profileName, profile := range enforcer.bpfProfileCache {
+				if _, ok := profile.containerCache[containerID]; ok {
+					delete(profile.containerCache, containerID)
+					enforcer.bpfProfileCache[profileName] = profile
+				}
+			}
+		}
+	}
+
+	staleMntNsCollected.Add(int64(count))
+	return count, nil
+}
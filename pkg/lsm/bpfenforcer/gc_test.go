@@ -0,0 +1,29 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_staleMntNsIDs(t *testing.T) {
+	enforced := map[uint32]bool{4026532001: true, 4026532002: true, 4026532003: true}
+	live := map[uint32]bool{4026531840: true, 4026532002: true}
+
+	assert.DeepEqual(t, staleMntNsIDs(enforced, live), []uint32{4026532001, 4026532003})
+	assert.Assert(t, staleMntNsIDs(map[uint32]bool{}, live) == nil)
+}
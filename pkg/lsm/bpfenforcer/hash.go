@@ -0,0 +1,185 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	// maxHashFileSize is the max size of the executable to compute the SHA256, the larger ones are regarded as
+	// mismatched
+	maxHashFileSize = 512 << 20
+	// maxHashCacheEntries is the max count of the SHA256 cached, the cache is reset once it's exceeded
+	maxHashCacheEntries = 4096
+)
+
+// fileIdentity identifies the content of a file, the SHA256 of it is computed again once the identity changes
+type fileIdentity struct {
+	dev   uint64
+	ino   uint64
+	mtime int64
+	ctime int64
+	size  int64
+}
+
+// hashCache caches the SHA256 of the executables with their identities
+type hashCache struct {
+	lock    sync.Mutex
+	digests map[fileIdentity]string
+}
+
+func newHashCache() *hashCache {
+	return &hashCache{
+		digests: make(map[fileIdentity]string),
+	}
+}
+
+// openInRoot opens the file with the path resolved in the root, so the absolute symbolic links in the container
+// are resolved against its root. It falls back to opening the path under the root on the old kernels.
+func openInRoot(root string, path string) (*os.File, error) {
+	rootFd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: root, Err: err}
+	}
+	defer unix.Close(rootFd)
+
+	fd, err := unix.Openat2(rootFd, path, &unix.OpenHow{
+		Flags:   unix.O_RDONLY | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_IN_ROOT,
+	})
+	if errors.Is(err, unix.ENOSYS) {
+		return os.Open(filepath.Join(root, path))
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "openat2", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// sum returns the hex-encoded SHA256 of the file in the root
+func (c *hashCache) sum(root string, path string) (string, error) {
+	f, err := openInRoot(root, path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var st unix.Stat_t
+	err = unix.Fstat(int(f.Fd()), &st)
+	if err != nil {
+		return "", err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	if st.Size > maxHashFileSize {
+		return "", fmt.Errorf("the size of %s exceeds the maximum (%d bytes)", path, maxHashFileSize)
+	}
+
+	id := fileIdentity{
+		dev:   uint64(st.Dev),
+		ino:   st.Ino,
+		mtime: st.Mtim.Nano(),
+		ctime: st.Ctim.Nano(),
+		size:  st.Size,
+	}
+
+	c.lock.Lock()
+	digest, ok := c.digests[id]
+	c.lock.Unlock()
+	if ok {
+		return digest, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	digest = hex.EncodeToString(h.Sum(nil))
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.digests) >= maxHashCacheEntries {
+		c.digests = make(map[fileIdentity]string)
+	}
+	c.digests[id] = digest
+
+	return digest, nil
+}
+
+// expandHashProcessRules computes the SHA256 of the executables in the container, and creates the precise bprm
+// rules for the ones whose SHA256 isn't allowed. The executables that can't be hashed are denied as well. The
+// parent directories of the executables are returned for watching.
+func expandHashProcessRules(pid uint32, hashProcesses []varmor.HashProcessContent, cache *hashCache) ([]varmor.FileContent, []string, error) {
+	var processes []varmor.FileContent
+	var dirs []string
+	watchedDirs := make(map[string]struct{})
+
+	root := fmt.Sprintf("/proc/%d/root", pid)
+	if _, err := os.Stat(root); err != nil {
+		return nil, nil, err
+	}
+
+	for _, hashProcess := range hashProcesses {
+		dir := filepath.Dir(hashProcess.Path)
+		if _, ok := watchedDirs[dir]; !ok {
+			watchedDirs[dir] = struct{}{}
+			dirs = append(dirs, dir)
+		}
+
+		digest, err := cache.sum(root, hashProcess.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Nothing can be executed, the rule is refreshed once the file is created
+			continue
+		}
+
+		allowed := false
+		if err == nil {
+			for _, d := range hashProcess.SHA256 {
+				if d == digest {
+					allowed = true
+					break
+				}
+			}
+		}
+		if allowed {
+			continue
+		}
+
+		processes = append(processes, varmor.FileContent{
+			Permissions: aaMayExec,
+			Pattern: varmor.PathPattern{
+				Flags:  preciseMatch | prefixMatch,
+				Prefix: hashProcess.Path,
+			},
+			RuleID: hashProcess.RuleID,
+		})
+	}
+
+	return processes, dirs, nil
+}
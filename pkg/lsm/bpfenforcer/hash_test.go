@@ -0,0 +1,64 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_expandHashProcessRules(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	replaced := filepath.Join(dir, "replaced")
+	assert.NilError(t, os.WriteFile(allowed, []byte("allowed"), 0755))
+	assert.NilError(t, os.WriteFile(replaced, []byte("malicious"), 0755))
+
+	sum := sha256.Sum256([]byte("allowed"))
+	digest := hex.EncodeToString(sum[:])
+
+	hashProcesses := []varmor.HashProcessContent{
+		{Path: allowed, SHA256: []string{digest}, RuleID: "allowed"},
+		{Path: replaced, SHA256: []string{digest}, RuleID: "replaced"},
+		{Path: filepath.Join(dir, "missing"), SHA256: []string{digest}, RuleID: "missing"},
+	}
+
+	// The root of the current process is the root of the filesystem
+	cache := newHashCache()
+	processes, dirs, err := expandHashProcessRules(uint32(os.Getpid()), hashProcesses, cache)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, dirs, []string{dir})
+	assert.DeepEqual(t, processes, []varmor.FileContent{
+		{
+			Permissions: aaMayExec,
+			Pattern:     varmor.PathPattern{Flags: preciseMatch | prefixMatch, Prefix: replaced},
+			RuleID:      "replaced",
+		},
+	})
+	assert.Equal(t, len(cache.digests), 2)
+
+	// The digest is computed again once the file is modified
+	assert.NilError(t, os.WriteFile(replaced, []byte("allowed"), 0755))
+	processes, _, err = expandHashProcessRules(uint32(os.Getpid()), hashProcesses, cache)
+	assert.NilError(t, err)
+	assert.Equal(t, len(processes), 0)
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmorutils "github.com/bytedance/vArmor/pkg/utils"
+)
+
+const (
+	// hostProcessScanInterval is the interval of scanning the host processes that the BPF profiles target
+	hostProcessScanInterval = time.Minute
+	// hostProcessIDPrefix is the prefix of the IDs that the mnt ns of host processes are cached with
+	hostProcessIDPrefix = "hostprocess://"
+)
+
+// normalizeSystemdUnit appends the ".service" suffix to the unit name if it's omitted
+func normalizeSystemdUnit(unit string) string {
+	if strings.Contains(unit, ".") {
+		return unit
+	}
+	return unit + ".service"
+}
+
+// systemdUnitOf parses the content of /proc/<pid>/cgroup and returns the systemd unit of the process
+func systemdUnitOf(cgroup string) string {
+	identity, err := varmorutils.ParseCgroup(cgroup)
+	if err != nil {
+		return ""
+	}
+	return identity.SystemdUnit
+}
+
+// matchHostProcesses scans the procfs and returns the PIDs of the processes matched by the target
+func matchHostProcesses(target *varmor.HostProcessTarget) ([]uint32, error) {
+	executables := make(map[string]bool, len(target.Executables))
+	for _, executable := range target.Executables {
+		executables[executable] = true
+	}
+	units := make(map[string]bool, len(target.SystemdUnits))
+	for _, unit := range target.SystemdUnits {
+		units[normalizeSystemdUnit(unit)] = true
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []uint32
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		// The kernel threads have no executable, and the process may have exited
+		exe, err := os.Readlink(filepath.Join("/proc", entry.Name(), "exe"))
+		if err != nil {
+			continue
+		}
+		if executables[exe] {
+			pids = append(pids, uint32(pid))
+			continue
+		}
+
+		if len(units) != 0 {
+			cgroup, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cgroup"))
+			if err != nil {
+				continue
+			}
+			if units[systemdUnitOf(string(cgroup))] {
+				pids = append(pids, uint32(pid))
+			}
+		}
+	}
+	return pids, nil
+}
+
+// SetHostProcessTarget sets the host processes that the BPF profile targets, and applies the profile to their mnt ns.
+// It returns an error if some of the matched processes run in the host mnt ns or the profile failed to apply to them.
+func (enforcer *BpfEnforcer) SetHostProcessTarget(profileName string, target *varmor.HostProcessTarget) error {
+	if !enforcer.acquire() {
+		return errEnforcerClosed
+	}
+	defer enforcer.release()
+
+	profile, ok := enforcer.bpfProfileCache[profileName]
+	if !ok {
+		return fmt.Errorf("the BPF profile %s doesn't exist", profileName)
+	}
+	profile.hostProcess = target
+	enforcer.bpfProfileCache[profileName] = profile
+
+	return enforcer.applyHostProcessProfile(profileName)
+}
+
+// applyHostProcessProfile applies the BPF profile to the mnt ns of the host processes that haven't been protected.
+// The processes in the host mnt ns are rejected, since the BPF program doesn't enforce the rules on it.
+func (enforcer *BpfEnforcer) applyHostProcessProfile(profileName string) error {
+	profile, ok := enforcer.bpfProfileCache[profileName]
+	if !ok || profile.hostProcess == nil {
+		return nil
+	}
+
+	pids, err := matchHostProcesses(profile.hostProcess)
+	if err != nil {
+		return err
+	}
+
+	initMntNsID, err := varmorutils.ReadMntNsID(1)
+	if err != nil {
+		return err
+	}
+
+	var rejected []string
+	var failed []string
+	for _, pid := range pids {
+		id, err := enforcer.newEnforceID(pid)
+		if err != nil {
+			// maybe the process had already exited
+			continue
+		}
+
+		if id.mntNsID == initMntNsID {
+			rejected = append(rejected, strconv.FormatUint(uint64(pid), 10))
+			continue
+		}
+
+		// The processes in the same mnt ns share the rules
+		containerID := hostProcessIDPrefix + strconv.FormatUint(uint64(id.mntNsID), 10)
+		if _, ok := enforcer.containerCache[containerID]; ok {
+			continue
+		}
+
+		enforcer.log.Info("target host process was found", "profile name", profileName, "pid", pid, "mnt ns id", id.mntNsID)
+		err = enforcer.applyProfileWithSpan(context.Background(), profileName, containerID, id, profile.bpfContent)
+		if err != nil {
+			enforcer.log.Error(err, "applyProfile() failed", "profile name", profileName, "pid", pid)
+			enforcer.addDeadLetter(containerID, profileName, id, err)
+			failed = append(failed, containerID)
+			continue
+		}
+		enforcer.removeDeadLetter(containerID)
+
+		enforcer.containerCache[containerID] = id
+		profile.containerCache[containerID] = id
+	}
+	enforcer.bpfProfileCache[profileName] = profile
+
+	return hostProcessError(rejected, failed)
+}
+
+// hostProcessError returns the error of the host processes which run in the host mnt ns, and the ones that the BPF
+// profile failed to apply to
+func hostProcessError(rejected []string, failed []string) error {
+	var messages []string
+	if len(rejected) != 0 {
+		messages = append(messages, fmt.Sprintf("the host processes (%s) run in the host mnt ns and can't be protected", strings.Join(rejected, ", ")))
+	}
+	if len(failed) != 0 {
+		messages = append(messages, fmt.Sprintf("failed to apply the BPF profile to the host processes: %s", strings.Join(failed, ", ")))
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// scanHostProcesses applies the BPF profiles to the host processes started since the last scan
+func (enforcer *BpfEnforcer) scanHostProcesses() {
+	for profileName, profile := range enforcer.bpfProfileCache {
+		if profile.hostProcess == nil {
+			continue
+		}
+		err := enforcer.applyHostProcessProfile(profileName)
+		if err != nil {
+			enforcer.log.Error(err, "applyHostProcessProfile() failed", "profile name", profileName)
+		}
+	}
+}
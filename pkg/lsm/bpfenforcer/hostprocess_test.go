@@ -0,0 +1,93 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_systemdUnitOf(t *testing.T) {
+	testCases := []struct {
+		name         string
+		cgroup       string
+		expectedUnit string
+	}{
+		{
+			name:         "cgroupV2",
+			cgroup:       "0::/system.slice/containerd.service\n",
+			expectedUnit: "containerd.service",
+		},
+		{
+			name:         "cgroupV1",
+			cgroup:       "12:cpu,cpuacct:/system.slice/kubelet.service\n1:name=systemd:/system.slice/kubelet.service\n",
+			expectedUnit: "kubelet.service",
+		},
+		{
+			name:         "container",
+			cgroup:       "0::/kubepods.slice/kubepods-burstable.slice/cri-containerd-0123.scope\n",
+			expectedUnit: "cri-containerd-0123.scope",
+		},
+		{
+			name:   "noUnit",
+			cgroup: "0::/\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, systemdUnitOf(tc.cgroup), tc.expectedUnit)
+		})
+	}
+
+	assert.Equal(t, normalizeSystemdUnit("containerd"), "containerd.service")
+	assert.Equal(t, normalizeSystemdUnit("session-1.scope"), "session-1.scope")
+}
+
+func Test_hostProcessError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		rejected      []string
+		failed        []string
+		expectedError string
+	}{
+		{
+			name: "protected",
+		},
+		{
+			name:          "rejected",
+			rejected:      []string{"1", "42"},
+			expectedError: "the host processes (1, 42) run in the host mnt ns and can't be protected",
+		},
+		{
+			name:          "rejectedAndFailed",
+			rejected:      []string{"42"},
+			failed:        []string{"hostprocess://4026532000"},
+			expectedError: "the host processes (42) run in the host mnt ns and can't be protected; failed to apply the BPF profile to the host processes: hostprocess://4026532000",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := hostProcessError(tc.rejected, tc.failed)
+			if tc.expectedError == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.expectedError)
+			}
+		})
+	}
+}
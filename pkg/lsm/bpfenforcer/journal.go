@@ -0,0 +1,384 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	journalOpApply  = "apply"
+	journalOpDelete = "delete"
+	journalOpClose  = "close"
+
+	journalPhaseBegin  = "begin"
+	journalPhaseDone   = "done"
+	journalPhaseFailed = "failed"
+
+	// journalCompactThreshold is the count of the records that triggers the compaction of the journal
+	journalCompactThreshold = 4096
+)
+
+var journalReplayed = new(expvar.Int)
+
+func init() {
+	metrics.Set("journal_replayed_total", journalReplayed)
+}
+
+// journalRecord is a record of the journal of the profile operations. The records are ordered by the sequence
+// number, and the records of a container are always written in the order the operations were performed.
+type journalRecord struct {
+	Seq         uint64    `json:"seq"`
+	Timestamp   time.Time `json:"timestamp"`
+	Op          string    `json:"op"`
+	Phase       string    `json:"phase,omitempty"`
+	ContainerID string    `json:"containerID,omitempty"`
+	PID         uint32    `json:"pid,omitempty"`
+	MntNsID     uint32    `json:"mntNsID,omitempty"`
+	Profile     string    `json:"profile,omitempty"`
+	// Hash is the SHA256 of the BPF profile that was applied
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// journal is an append-only file of the profile operations. The enforcer writes a close record when it's closed,
+// so the journal without it means the previous enforcer crashed, and the last operations are replayed and verified
+// after the new enforcer starts instead of relying solely on the resync of the runtime monitor.
+//
+// Note:
+// The records are written without fsync, so they survive the crashes of the agent but not the ones of the node,
+// which drop the BPF maps anyway.
+type journal struct {
+	lock    sync.Mutex
+	path    string
+	file    *os.File
+	seq     uint64
+	records int
+	// enforced are the last apply records of the containers that are enforced, they're kept by the compaction
+	enforced map[string]journalRecord
+}
+
+// journalRecovery is the state of the containers recorded by the previous enforcer which crashed
+type journalRecovery struct {
+	// enforced are the containers that were enforced
+	enforced map[string]journalRecord
+	// interrupted are the containers whose apply was interrupted by the crash
+	interrupted map[string]journalRecord
+}
+
+// profileHash returns the SHA256 of the BPF profile
+func profileHash(bpfContent *varmor.BpfContent) string {
+	content, _ := json.Marshal(bpfContent)
+	digest := sha256.Sum256(content)
+	return hex.EncodeToString(digest[:])
+}
+
+// readJournal reads the records of the journal, the torn record of a crash is skipped
+func readJournal(path string) ([]journalRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []journalRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+	for scanner.Scan() {
+		var record journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// replayRecords rebuilds the state of the containers from the records. It returns the recovery if the last record
+// isn't a close record, i.e. the enforcer that wrote them crashed.
+func replayRecords(records []journalRecord) (map[string]journalRecord, *journalRecovery) {
+	enforced := make(map[string]journalRecord)
+	interrupted := make(map[string]journalRecord)
+	for _, record := range records {
+		switch record.Op {
+		case journalOpApply:
+			switch record.Phase {
+			case journalPhaseBegin:
+				interrupted[record.ContainerID] = record
+			case journalPhaseDone:
+				delete(interrupted, record.ContainerID)
+				enforced[record.ContainerID] = record
+			case journalPhaseFailed:
+				// The previous rules are still enforced if it failed
+				delete(interrupted, record.ContainerID)
+			}
+		case journalOpDelete:
+			delete(interrupted, record.ContainerID)
+			delete(enforced, record.ContainerID)
+		}
+	}
+
+	if len(records) == 0 || records[len(records)-1].Op == journalOpClose {
+		return enforced, nil
+	}
+	for containerID := range interrupted {
+		delete(enforced, containerID)
+	}
+	return enforced, &journalRecovery{enforced: enforced, interrupted: interrupted}
+}
+
+// openJournal opens the journal and compacts it. It returns the recovery if the previous enforcer crashed.
+func openJournal(path string) (*journal, *journalRecovery, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := readJournal(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the journal: %w", err)
+	}
+	enforced, recovery := replayRecords(records)
+
+	j := &journal{path: path, enforced: enforced}
+	if len(records) != 0 {
+		j.seq = records[len(records)-1].Seq
+	}
+	if recovery != nil {
+		// Keep the interrupted operations until they're replayed
+		for containerID, record := range recovery.interrupted {
+			j.enforced[containerID] = record
+		}
+	}
+
+	err = j.compact()
+	if err != nil {
+		return nil, nil, err
+	}
+	return j, recovery, nil
+}
+
+// compact rewrites the journal with the last apply records of the containers
+func (j *journal) compact() error {
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+
+	snapshot := make([]journalRecord, 0, len(j.enforced))
+	for _, record := range j.enforced {
+		snapshot = append(snapshot, record)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Seq < snapshot[j].Seq })
+
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, record := range snapshot {
+		line, _ := json.Marshal(&record)
+		w.Write(append(line, '\n'))
+	}
+	err = errors.Join(w.Flush(), f.Sync(), f.Close())
+	if err != nil {
+		return fmt.Errorf("failed to compact the journal: %w", err)
+	}
+	err = os.Rename(tmp, j.path)
+	if err != nil {
+		return fmt.Errorf("failed to compact the journal: %w", err)
+	}
+
+	j.file, err = os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0600)
+	j.records = len(snapshot)
+	return err
+}
+
+// write appends the record to the journal with the next sequence number. It's a no-op if the journal is disabled.
+func (j *journal) write(record journalRecord) error {
+	if j == nil {
+		return nil
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file == nil {
+		return errEnforcerClosed
+	}
+
+	j.seq++
+	record.Seq = j.seq
+	record.Timestamp = time.Now()
+	line, err := json.Marshal(&record)
+	if err != nil {
+		return err
+	}
+	// The record is written with one syscall, so it's never interleaved with the others
+	_, err = j.file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	j.records++
+
+	switch record.Op {
+	case journalOpApply:
+		if record.Phase == journalPhaseDone {
+			j.enforced[record.ContainerID] = record
+		}
+	case journalOpDelete:
+		delete(j.enforced, record.ContainerID)
+	}
+
+	if j.records > journalCompactThreshold && j.records > 2*len(j.enforced) {
+		return j.compact()
+	}
+	return nil
+}
+
+// close writes the close record and closes the journal
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+
+	err := j.write(journalRecord{Op: journalOpClose})
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.file != nil {
+		err = errors.Join(err, j.file.Close())
+		j.file = nil
+	}
+	return err
+}
+
+// journalApply records the phase of applying the BPF profile to the container
+func (enforcer *BpfEnforcer) journalApply(phase string, containerID string, id enforceID, profileName string, hash string, applyErr error) {
+	record := journalRecord{
+		Op:          journalOpApply,
+		Phase:       phase,
+		ContainerID: containerID,
+		PID:         id.pid,
+		MntNsID:     id.mntNsID,
+		Profile:     profileName,
+		Hash:        hash,
+	}
+	if applyErr != nil {
+		record.Error = applyErr.Error()
+	}
+	err := enforcer.journal.write(record)
+	if err != nil {
+		enforcer.log.Error(err, "failed to write the journal", "container id", containerID)
+	}
+}
+
+// journalDelete records that the BPF profile of the container was deleted
+func (enforcer *BpfEnforcer) journalDelete(containerID string, id enforceID) {
+	err := enforcer.journal.write(journalRecord{
+		Op:          journalOpDelete,
+		ContainerID: containerID,
+		PID:         id.pid,
+		MntNsID:     id.mntNsID,
+	})
+	if err != nil {
+		enforcer.log.Error(err, "failed to write the journal", "container id", containerID)
+	}
+}
+
+// replayJournal replays the operations recorded by the previous enforcer which crashed. The containers that are
+// still alive with the same mnt ns are enforced with their profiles again, including the ones whose apply was
+// interrupted. The containers whose profile was changed after the crash are enforced with the current one. The
+// containers whose profile hasn't been saved are kept until the profile is saved. All of them are replayed if the
+// profileName is empty.
+func (enforcer *BpfEnforcer) replayJournal(profileName string) {
+	recovery := enforcer.recovery
+	if recovery == nil {
+		return
+	}
+
+	var records []journalRecord
+	for _, m := range []map[string]journalRecord{recovery.enforced, recovery.interrupted} {
+		for _, record := range m {
+			if profileName == "" || record.Profile == profileName {
+				records = append(records, record)
+			}
+		}
+	}
+	// Replay them in the order they were performed
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+
+	for _, record := range records {
+		profile, ok := enforcer.bpfProfileCache[record.Profile]
+		if !ok {
+			continue
+		}
+		delete(recovery.enforced, record.ContainerID)
+		delete(recovery.interrupted, record.ContainerID)
+
+		id, err := enforcer.newEnforceID(record.PID)
+		if err != nil || id.mntNsID != record.MntNsID {
+			// The container exited while the agent was offline
+			enforcer.journalDelete(record.ContainerID, enforceID{pid: record.PID, mntNsID: record.MntNsID})
+			continue
+		}
+
+		if record.Phase == journalPhaseBegin {
+			enforcer.log.Info("replay the apply that was interrupted by the crash", "container id", record.ContainerID, "profile name", record.Profile)
+		} else if record.Hash != profile.hash {
+			enforcer.log.Info("the BPF profile was changed after the crash, the container is enforced with the current one",
+				"container id", record.ContainerID, "profile name", record.Profile)
+		}
+
+		// Keep the information of the container if it has been resynced by the runtime monitor
+		info, ok := enforcer.containerInfos[record.ContainerID]
+		if !ok {
+			info = varmortypes.ContainerInfo{ContainerID: record.ContainerID, PID: record.PID}
+		}
+		err = enforcer.enforceContainerWithProfile(info, record.Profile)
+		if errors.Is(err, errNodePressure) {
+			// The container is enforced when the pressure of the node subsides
+			continue
+		}
+		if err != nil {
+			enforcer.log.Error(err, "failed to replay the journal", "container id", record.ContainerID, "profile name", record.Profile)
+			continue
+		}
+		journalReplayed.Add(1)
+	}
+
+	if len(recovery.enforced) == 0 && len(recovery.interrupted) == 0 {
+		enforcer.log.Info("the journal of the previous enforcer was replayed")
+		enforcer.recovery = nil
+	}
+}
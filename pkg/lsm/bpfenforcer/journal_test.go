@@ -0,0 +1,91 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_replayRecords(t *testing.T) {
+	records := []journalRecord{
+		{Seq: 1, Op: journalOpApply, Phase: journalPhaseBegin, ContainerID: "c1", Profile: "p1"},
+		{Seq: 2, Op: journalOpApply, Phase: journalPhaseDone, ContainerID: "c1", Profile: "p1"},
+		{Seq: 3, Op: journalOpApply, Phase: journalPhaseBegin, ContainerID: "c2", Profile: "p1"},
+		{Seq: 4, Op: journalOpApply, Phase: journalPhaseFailed, ContainerID: "c2", Profile: "p1"},
+		{Seq: 5, Op: journalOpApply, Phase: journalPhaseDone, ContainerID: "c3", Profile: "p2"},
+		{Seq: 6, Op: journalOpDelete, ContainerID: "c3"},
+		{Seq: 7, Op: journalOpApply, Phase: journalPhaseBegin, ContainerID: "c4", Profile: "p2"},
+	}
+
+	enforced, recovery := replayRecords(records)
+	assert.Assert(t, recovery != nil)
+	assert.DeepEqual(t, len(enforced), 1)
+	assert.Equal(t, enforced["c1"].Seq, uint64(2))
+	assert.DeepEqual(t, len(recovery.interrupted), 1)
+	assert.Equal(t, recovery.interrupted["c4"].Profile, "p2")
+
+	records = append(records, journalRecord{Seq: 8, Op: journalOpClose})
+	enforced, recovery = replayRecords(records)
+	assert.Assert(t, recovery == nil)
+	assert.DeepEqual(t, len(enforced), 1)
+
+	_, recovery = replayRecords(nil)
+	assert.Assert(t, recovery == nil)
+}
+
+func Test_journal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j, recovery, err := openJournal(path)
+	assert.NilError(t, err)
+	assert.Assert(t, recovery == nil)
+
+	hash := profileHash(&varmor.BpfContent{})
+	assert.NilError(t, j.write(journalRecord{Op: journalOpApply, Phase: journalPhaseBegin, ContainerID: "c1", Profile: "p1", Hash: hash}))
+	assert.NilError(t, j.write(journalRecord{Op: journalOpApply, Phase: journalPhaseDone, ContainerID: "c1", Profile: "p1", Hash: hash}))
+	assert.NilError(t, j.write(journalRecord{Op: journalOpApply, Phase: journalPhaseBegin, ContainerID: "c2", Profile: "p1", Hash: hash}))
+
+	// The agent crashed while applying the profile to c2, and the last record was torn
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NilError(t, err)
+	_, err = f.WriteString(`{"seq":4,"op":"ap`)
+	assert.NilError(t, err)
+	f.Close()
+
+	j, recovery, err = openJournal(path)
+	assert.NilError(t, err)
+	assert.Assert(t, recovery != nil)
+	assert.Equal(t, recovery.enforced["c1"].Hash, hash)
+	assert.Equal(t, recovery.interrupted["c2"].Profile, "p1")
+	assert.Equal(t, j.seq, uint64(3))
+
+	// The sequence numbers continue after the compaction
+	assert.NilError(t, j.write(journalRecord{Op: journalOpDelete, ContainerID: "c2"}))
+	records, err := readJournal(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, len(records), 3)
+	assert.Equal(t, records[2].Seq, uint64(4))
+
+	assert.NilError(t, j.close())
+	_, recovery, err = openJournal(path)
+	assert.NilError(t, err)
+	assert.Assert(t, recovery == nil)
+}
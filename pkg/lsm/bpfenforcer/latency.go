@@ -0,0 +1,111 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultApplyLatencySLO is the default objective of the time from the container task creation to the BPF profile
+// being enforced
+const defaultApplyLatencySLO = time.Second
+
+// applyLatencyBuckets are the upper bounds in seconds of the buckets of the apply latency histogram
+var applyLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	applyLatency           = newLatencyHistogram(applyLatencyBuckets)
+	applyLatencySLOBreach  = new(expvar.Int)
+	applyLatencySLOSeconds = new(expvar.Float)
+)
+
+func init() {
+	metrics.Set("apply_latency_seconds", applyLatency)
+	metrics.Set("apply_latency_slo_breaches_total", applyLatencySLOBreach)
+	metrics.Set("apply_latency_slo_seconds", applyLatencySLOSeconds)
+}
+
+// latencyHistogram is a histogram of latencies which is published with expvar. The counts of the buckets are
+// cumulative, which is the same as the histogram of Prometheus.
+type latencyHistogram struct {
+	lock    sync.Mutex
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func newLatencyHistogram(bounds []float64) *latencyHistogram {
+	return &latencyHistogram{
+		bounds:  bounds,
+		buckets: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// String implements expvar.Var
+func (h *latencyHistogram) String() string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	buckets := make(map[string]uint64, len(h.bounds)+1)
+	for i, bound := range h.bounds {
+		buckets[strconv.FormatFloat(bound, 'f', -1, 64)] = h.buckets[i]
+	}
+	buckets["+Inf"] = h.count
+
+	data, _ := json.Marshal(struct {
+		Buckets map[string]uint64 `json:"buckets"`
+		Count   uint64            `json:"count"`
+		Sum     float64           `json:"sum"`
+	}{buckets, h.count, h.sum})
+	return string(data)
+}
+
+// observeApplyLatency records the time from the container task creation to the BPF profile being enforced.
+// The unprotected window of the container is reported if it exceeds the objective.
+func (enforcer *BpfEnforcer) observeApplyLatency(containerID string, createdAt time.Time) {
+	if createdAt.IsZero() {
+		// The creation time is unknown for the existing containers
+		return
+	}
+
+	latency := time.Since(createdAt)
+	applyLatency.observe(latency)
+
+	if latency > enforcer.opts.ApplyLatencySLO {
+		applyLatencySLOBreach.Add(1)
+		enforcer.log.Info("the BPF profile was enforced later than the objective after the container was created",
+			"container id", containerID, "latency", latency, "objective", enforcer.opts.ApplyLatencySLO)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func Test_latencyHistogram(t *testing.T) {
+	h := newLatencyHistogram([]float64{0.1, 1})
+	h.observe(50 * time.Millisecond)
+	h.observe(500 * time.Millisecond)
+	h.observe(2 * time.Second)
+
+	var data struct {
+		Buckets map[string]uint64 `json:"buckets"`
+		Count   uint64            `json:"count"`
+		Sum     float64           `json:"sum"`
+	}
+	err := json.Unmarshal([]byte(h.String()), &data)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data.Buckets, map[string]uint64{"0.1": 1, "1": 2, "+Inf": 3})
+	assert.Equal(t, data.Count, uint64(3))
+	assert.Equal(t, data.Sum, 2.55)
+}
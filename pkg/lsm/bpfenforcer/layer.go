@@ -0,0 +1,208 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// layeredRuleIDSeparator separates the name of the base profile from the IDs of the rules layered from it, so the
+// violations of them are attributed to the base profile
+const layeredRuleIDSeparator = "#"
+
+func layeredRuleID(baseName string, ruleID string) string {
+	return baseName + layeredRuleIDSeparator + ruleID
+}
+
+// splitLayeredRuleID returns the name of the base profile and the rule ID of the rule layered from it. The name is
+// empty if the rule is from the profile of the container.
+func splitLayeredRuleID(ruleID string) (string, string) {
+	if i := strings.Index(ruleID, layeredRuleIDSeparator); i >= 0 {
+		return ruleID[:i], ruleID[i+len(layeredRuleIDSeparator):]
+	}
+	return "", ruleID
+}
+
+// layeredRuleKey returns the key of the rule regardless of its ID, the rules with the same key match the
+// same operations
+func layeredRuleKey(rule reflect.Value) string {
+	key := reflect.New(rule.Type()).Elem()
+	key.Set(rule)
+	key.FieldByName("RuleID").SetString("")
+	data, _ := json.Marshal(key.Interface())
+	return string(data)
+}
+
+// layerRules appends the rules of the workload profile to the ones of the base profile, both are slices of the same
+// rule type. The IDs of the base rules are prefixed with the name of the base profile. The workload rules that match
+// the same operations as the base rules are dropped, so the modes of the base rules win.
+func layerRules(baseName string, base interface{}, workload interface{}) interface{} {
+	b := reflect.ValueOf(base)
+	w := reflect.ValueOf(workload)
+	if b.Len()+w.Len() == 0 {
+		return reflect.Zero(b.Type()).Interface()
+	}
+
+	rules := reflect.MakeSlice(b.Type(), 0, b.Len()+w.Len())
+	seen := make(map[string]bool, b.Len())
+	for i := 0; i < b.Len(); i++ {
+		rule := reflect.New(b.Type().Elem()).Elem()
+		rule.Set(b.Index(i))
+		seen[layeredRuleKey(rule)] = true
+		id := rule.FieldByName("RuleID")
+		id.SetString(layeredRuleID(baseName, id.String()))
+		rules = reflect.Append(rules, rule)
+	}
+	for i := 0; i < w.Len(); i++ {
+		if !seen[layeredRuleKey(w.Index(i))] {
+			rules = reflect.Append(rules, w.Index(i))
+		}
+	}
+	return rules.Interface()
+}
+
+// layerBpfContent merges the base profile and the workload profile of a container into one, the rules of the base
+// profile take precedence:
+//   - The rules are merged with the base rules first, so they're kept if the merged rules exceed the limits.
+//   - The capabilities are merged.
+//   - The ptrace rule of the base profile replaces the one of the workload profile.
+//
+// It returns the merged profile, and the classes of the workload rules that were dropped.
+func layerBpfContent(baseName string, base *varmor.BpfContent, workload *varmor.BpfContent) (varmor.BpfContent, []string) {
+	var content varmor.BpfContent
+	var dropped []string
+
+	content.Capabilities = base.Capabilities | workload.Capabilities
+
+	content.Processes = layerRules(baseName, base.Processes, workload.Processes).([]varmor.FileContent)
+	content.HashProcesses = layerRules(baseName, base.HashProcesses, workload.HashProcesses).([]varmor.HashProcessContent)
+	content.Mounts = layerRules(baseName, base.Mounts, workload.Mounts).([]varmor.MountContent)
+	content.Files = layerRules(baseName, base.Files, workload.Files).([]varmor.FileContent)
+	content.RegexFiles = layerRules(baseName, base.RegexFiles, workload.RegexFiles).([]varmor.RegexFileContent)
+	content.Networks = layerRules(baseName, base.Networks, workload.Networks).([]varmor.NetworkContent)
+	content.NetworkPeers = layerRules(baseName, base.NetworkPeers, workload.NetworkPeers).([]varmor.NetworkPeerContent)
+
+	if base.Ptrace != nil {
+		ptrace := *base.Ptrace
+		ptrace.RuleID = layeredRuleID(baseName, ptrace.RuleID)
+		content.Ptrace = &ptrace
+		if workload.Ptrace != nil {
+			dropped = append(dropped, "ptrace")
+		}
+	} else if workload.Ptrace != nil {
+		ptrace := *workload.Ptrace
+		content.Ptrace = &ptrace
+	}
+
+	return content, dropped
+}
+
+// layerProfile layers the base profile of the container under the BPF profile if the container is bound to one
+// that has been saved. The profile is returned as is otherwise.
+func (enforcer *BpfEnforcer) layerProfile(containerID string, bpfContent varmor.BpfContent) varmor.BpfContent {
+	enforcer.cacheLock.Lock()
+	baseName := enforcer.layers[containerID]
+	enforcer.cacheLock.Unlock()
+
+	content, dropped := enforcer.layerBaseProfile(baseName, bpfContent)
+	if len(dropped) != 0 {
+		enforcer.log.Info("the rules of the BPF profile are dropped by the base profile", "container id", containerID,
+			"base profile", baseName, "dropped", dropped)
+	}
+	return content
+}
+
+// layerBaseProfile layers the base profile under the BPF profile if it has been saved, and returns the classes of
+// the rules that were dropped. The profile is returned as is otherwise.
+func (enforcer *BpfEnforcer) layerBaseProfile(baseName string, bpfContent varmor.BpfContent) (varmor.BpfContent, []string) {
+	if baseName == "" {
+		return bpfContent, nil
+	}
+
+	enforcer.cacheLock.Lock()
+	base, saved := enforcer.bpfProfileCache[baseName]
+	enforcer.cacheLock.Unlock()
+	if !saved {
+		return bpfContent, nil
+	}
+
+	content, dropped := layerBpfContent(baseName, &base.bpfContent, &bpfContent)
+	dropped = append(dropped, truncateBpfContent(&content)...)
+	return content, dropped
+}
+
+// bindBaseProfile binds the container to the base profile, or unbinds it if the name is empty. It returns true
+// if the binding changed.
+func (enforcer *BpfEnforcer) bindBaseProfile(containerID string, baseName string) bool {
+	enforcer.cacheLock.Lock()
+	defer enforcer.cacheLock.Unlock()
+
+	previous := enforcer.layers[containerID]
+	if baseName == "" {
+		delete(enforcer.layers, containerID)
+	} else {
+		if enforcer.layers == nil {
+			enforcer.layers = make(map[string]string)
+		}
+		enforcer.layers[containerID] = baseName
+	}
+	return previous != baseName
+}
+
+// reapplyContainer applies the BPF profile to the protected container again, e.g. after its base profile changed
+func (enforcer *BpfEnforcer) reapplyContainer(ctx context.Context, profileName string, containerID string) error {
+	enforcer.cacheLock.Lock()
+	profile, ok := enforcer.bpfProfileCache[profileName]
+	id, protected := profile.containerCache[containerID]
+	enforcer.cacheLock.Unlock()
+	if !ok || !protected {
+		return nil
+	}
+
+	enforcer.journalApply(journalPhaseBegin, containerID, id, profileName, profile.hash, nil)
+	err := enforcer.applyProfileWithSpan(ctx, profileName, containerID, id, profile.bpfContent)
+	if err != nil {
+		enforcer.journalApply(journalPhaseFailed, containerID, id, profileName, profile.hash, err)
+		enforcer.addDeadLetter(containerID, profileName, id, err)
+		return err
+	}
+	enforcer.journalApply(journalPhaseDone, containerID, id, profileName, profile.hash, nil)
+	enforcer.removeDeadLetter(containerID)
+	return nil
+}
+
+// reapplyLayeredContainers applies the BPF profiles again to the containers layered on the base profile, after
+// the base profile was saved or deleted. It returns the containers that failed.
+func (enforcer *BpfEnforcer) reapplyLayeredContainers(ctx context.Context, baseName string) []string {
+	var failed []string
+	for profileName, profile := range enforcer.bpfProfileCache {
+		for containerID := range profile.containerCache {
+			if enforcer.layers[containerID] != baseName {
+				continue
+			}
+			err := enforcer.reapplyContainer(ctx, profileName, containerID)
+			if err != nil {
+				enforcer.log.Error(err, "applyProfile() failed", "profile name", profileName, "base profile", baseName, "container id", containerID)
+				failed = append(failed, containerID)
+			}
+		}
+	}
+	return failed
+}
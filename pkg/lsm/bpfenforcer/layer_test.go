@@ -0,0 +1,108 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_layerBpfContent(t *testing.T) {
+	base := varmor.BpfContent{
+		Capabilities: 1<<21 | 1<<19,
+		Files: []varmor.FileContent{
+			{Permissions: 2, Pattern: varmor.PathPattern{Flags: 1, Prefix: "/etc/"}, RuleID: "disable-write-etc"},
+		},
+		Networks: []varmor.NetworkContent{
+			{Flags: 1, CIDR: "169.254.169.254/32", RuleID: "block-metadata"},
+		},
+	}
+	workload := varmor.BpfContent{
+		Capabilities: 1<<19 | 1<<12,
+		Files: []varmor.FileContent{
+			{Permissions: 2, Pattern: varmor.PathPattern{Flags: 1, Prefix: "/etc/"}, RuleID: "bpfRawRules.files/0"},
+			{Permissions: 4, Pattern: varmor.PathPattern{Flags: 1, Prefix: "/tmp/"}, RuleID: "bpfRawRules.files/1"},
+		},
+		Networks: []varmor.NetworkContent{
+			{Flags: 1, CIDR: "10.0.0.0/8", RuleID: "bpfRawRules.network/0"},
+		},
+		Ptrace: &varmor.PtraceContent{Permissions: 1, RuleID: "disable-ptrace"},
+	}
+
+	content, dropped := layerBpfContent("varmor-cluster-base", &base, &workload)
+	assert.Equal(t, content.Capabilities, uint64(1<<21|1<<19|1<<12))
+
+	// The identical workload rule is dropped
+	assert.Equal(t, len(content.Files), 2)
+	assert.Equal(t, content.Files[0].RuleID, "varmor-cluster-base#disable-write-etc")
+	assert.Equal(t, content.Files[1].RuleID, "bpfRawRules.files/1")
+
+	assert.Equal(t, len(content.Networks), 2)
+	assert.Equal(t, content.Networks[0].RuleID, "varmor-cluster-base#block-metadata")
+	assert.Equal(t, content.Networks[1].RuleID, "bpfRawRules.network/0")
+	assert.Equal(t, len(dropped), 0)
+
+	assert.Equal(t, content.Ptrace.RuleID, "disable-ptrace")
+	assert.Assert(t, content.Processes == nil)
+
+	// The cached profiles aren't modified
+	assert.Equal(t, base.Files[0].RuleID, "disable-write-etc")
+	assert.Equal(t, len(workload.Files), 2)
+}
+
+func Test_layerProfile(t *testing.T) {
+	enforcer := &BpfEnforcer{
+		bpfProfileCache: map[string]bpfProfile{
+			"varmor-cluster-base": {bpfContent: varmor.BpfContent{Capabilities: 1 << 21}},
+		},
+		log: logr.Discard(),
+	}
+	workload := varmor.BpfContent{Capabilities: 1 << 12}
+
+	assert.DeepEqual(t, enforcer.layerProfile("c1", workload), workload)
+
+	assert.Assert(t, enforcer.bindBaseProfile("c1", "varmor-cluster-base"))
+	assert.Assert(t, !enforcer.bindBaseProfile("c1", "varmor-cluster-base"))
+	assert.Equal(t, enforcer.layerProfile("c1", workload).Capabilities, uint64(1<<21|1<<12))
+
+	// The container is enforced with its own profile until the base profile is saved
+	assert.Assert(t, enforcer.bindBaseProfile("c1", "varmor-cluster-other"))
+	assert.DeepEqual(t, enforcer.layerProfile("c1", workload), workload)
+
+	assert.Assert(t, enforcer.bindBaseProfile("c1", ""))
+	assert.Equal(t, len(enforcer.layers), 0)
+}
+
+func Test_newViolationLayered(t *testing.T) {
+	event := bpfViolationEvent{RuleType: fileRuleType, MntNsID: 4026532001}
+	container := violationContainer{
+		profileName: "varmor-demo-web",
+		info:        varmortypes.ContainerInfo{ContainerID: "c1", PodNamespace: "demo", PodName: "web-0"},
+	}
+
+	violation := newViolation(&event, "varmor-cluster-base#disable-write-etc", time.Now(), &container)
+	assert.Equal(t, violation.ProfileName, "varmor-cluster-base")
+	assert.Equal(t, violation.RuleID, "disable-write-etc")
+
+	violation = newViolation(&event, "bpfRawRules.files/1", time.Now(), &container)
+	assert.Equal(t, violation.ProfileName, "varmor-demo-web")
+	assert.Equal(t, violation.RuleID, "bpfRawRules.files/1")
+}
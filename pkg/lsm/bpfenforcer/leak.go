@@ -0,0 +1,127 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	deleteRetries  = new(expvar.Int)
+	deleteFailures = new(expvar.Int)
+	leakedMntNs    = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("delete_retries_total", deleteRetries)
+	metrics.Set("delete_failures_total", deleteFailures)
+	metrics.Set("leaked_mnt_ns", leakedMntNs)
+}
+
+// leakStore saves the mnt ns whose entries failed to be deleted from the maps. The inner maps of them are leaked
+// until they're deleted by the garbage collection.
+type leakStore struct {
+	lock   sync.Mutex
+	errors map[uint32]string // <mntNsID: error>
+}
+
+func newLeakStore() *leakStore {
+	return &leakStore{
+		errors: make(map[uint32]string),
+	}
+}
+
+func (s *leakStore) save(nsID uint32, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errors[nsID] = err.Error()
+	leakedMntNs.Set(int64(len(s.errors)))
+}
+
+func (s *leakStore) delete(nsID uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.errors, nsID)
+	leakedMntNs.Set(int64(len(s.errors)))
+}
+
+func (s *leakStore) has(nsID uint32) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.errors[nsID]
+	return ok
+}
+
+// list returns the leaked mnt ns in order
+func (s *leakStore) list() []uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ids := make([]uint32, 0, len(s.errors))
+	for nsID := range s.errors {
+		ids = append(ids, nsID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// deleteProfileWithRetry deletes the BPF profile of the mnt ns, and retries with exponential backoff if it failed
+// transiently. The mnt ns is recorded as leaked if it still fails, so the garbage collection deletes it later.
+func (enforcer *BpfEnforcer) deleteProfileWithRetry(nsID uint32) error {
+	backoff := applyBackoff
+	for {
+		err := enforcer.deleteProfile(nsID)
+		if err == nil {
+			enforcer.leaks.delete(nsID)
+			return nil
+		}
+
+		if !isTransientError(err) || backoff.Steps <= 1 {
+			deleteFailures.Add(1)
+			enforcer.leaks.save(nsID, err)
+			return err
+		}
+
+		deleteRetries.Add(1)
+		delay := backoff.Step()
+		enforcer.log.V(3).Info("failed to delete the BPF profile, retry later", "mnt ns id", nsID, "delay", delay, "error", err)
+		time.Sleep(delay)
+	}
+}
+
+// collectLeakedMntNs deletes the entries of the leaked mnt ns from the maps again. The mnt ns that has been
+// enforced again since then is skipped, since its mnt ns id was reused. It returns the count of the collected ones.
+func (enforcer *BpfEnforcer) collectLeakedMntNs() int {
+	count := 0
+	for _, nsID := range enforcer.leaks.list() {
+		if _, ok := enforcer.fingerprints.get(nsID); ok {
+			enforcer.leaks.delete(nsID)
+			continue
+		}
+
+		err := enforcer.deleteProfile(nsID)
+		if err != nil {
+			enforcer.log.Error(err, "failed to delete the leaked BPF profile", "mnt ns id", nsID)
+			enforcer.leaks.save(nsID, err)
+			continue
+		}
+		enforcer.leaks.delete(nsID)
+		count++
+	}
+	return count
+}
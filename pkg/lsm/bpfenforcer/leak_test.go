@@ -0,0 +1,41 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_leakStore(t *testing.T) {
+	s := newLeakStore()
+	s.save(4026532003, errors.New("V_fileOuter.Delete() failed"))
+	s.save(4026532001, errors.New("V_netOuter.Delete() failed"))
+	s.save(4026532003, errors.New("V_bprmOuter.Delete() failed"))
+
+	assert.DeepEqual(t, s.list(), []uint32{4026532001, 4026532003})
+	assert.Equal(t, s.errors[4026532003], "V_bprmOuter.Delete() failed")
+	assert.Equal(t, leakedMntNs.Value(), int64(2))
+
+	s.delete(4026532001)
+	assert.Assert(t, !s.has(4026532001))
+	assert.Assert(t, s.has(4026532003))
+	assert.Equal(t, leakedMntNs.Value(), int64(1))
+
+	s.delete(4026532003)
+	assert.Equal(t, len(s.list()), 0)
+}
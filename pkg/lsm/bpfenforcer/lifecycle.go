@@ -0,0 +1,175 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/ebpf/link"
+)
+
+// defaultPinPath is the directory in the BPF filesystem that the BPF programs are pinned to by default
+const defaultPinPath = "/sys/fs/bpf/varmor"
+
+// errEnforcerClosed is returned by the operations called after the enforcer was closed
+var errEnforcerClosed = errors.New("the BPF enforcer is closed")
+
+type namedLink struct {
+	name string
+	link link.Link
+}
+
+func (enforcer *BpfEnforcer) links() []namedLink {
+	return []namedLink{
+		{"capable", enforcer.capableLink},
+		{"file_open", enforcer.openFileLink},
+		{"path_symlink", enforcer.pathSymlinkLink},
+		{"path_link", enforcer.pathLinkLink},
+		{"path_rename", enforcer.pathRenameLink},
+		{"bprm_check_security", enforcer.bprmLink},
+		{"socket_connect", enforcer.sockConnLink},
+		{"ptrace_access_check", enforcer.ptraceLink},
+		{"sb_mount", enforcer.mountLink},
+		{"move_mount", enforcer.moveMountLink},
+		{"sb_umount", enforcer.umountLink},
+	}
+}
+
+// pinLinks pins the links of the BPF programs, so they stay attached after the enforcer exits
+func (enforcer *BpfEnforcer) pinLinks() error {
+	err := os.MkdirAll(enforcer.opts.PinPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range enforcer.links() {
+		if l.link == nil {
+			continue
+		}
+		err = l.link.Pin(filepath.Join(enforcer.opts.PinPath, l.name))
+		if err != nil {
+			return err
+		}
+	}
+	enforcer.log.Info("the BPF programs were pinned, the enforcement is left in place", "path", enforcer.opts.PinPath)
+	return nil
+}
+
+// acquire prevents the enforcer from being closed during the map operations, and waits for the workers to finish
+// handling the container events, so the operation has exclusive access to the caches. It returns false if the
+// enforcer has been closed.
+func (enforcer *BpfEnforcer) acquire() bool {
+	enforcer.lifecycleLock.RLock()
+	if enforcer.closed {
+		enforcer.lifecycleLock.RUnlock()
+		return false
+	}
+	enforcer.handlerLock.Lock()
+	return true
+}
+
+func (enforcer *BpfEnforcer) release() {
+	enforcer.handlerLock.Unlock()
+	enforcer.lifecycleLock.RUnlock()
+}
+
+// acquireShared is the same as acquire, except that the workers hold it concurrently. The caches must be
+// accessed with the cacheLock held.
+func (enforcer *BpfEnforcer) acquireShared() bool {
+	enforcer.lifecycleLock.RLock()
+	if enforcer.closed {
+		enforcer.lifecycleLock.RUnlock()
+		return false
+	}
+	enforcer.handlerLock.RLock()
+	return true
+}
+
+func (enforcer *BpfEnforcer) releaseShared() {
+	enforcer.handlerLock.RUnlock()
+	enforcer.lifecycleLock.RUnlock()
+}
+
+// do runs the operation if the enforcer hasn't been closed
+func (enforcer *BpfEnforcer) do(operation func()) {
+	if !enforcer.acquire() {
+		return
+	}
+	defer enforcer.release()
+	operation()
+}
+
+// doShared runs the operation of the workers if the enforcer hasn't been closed
+func (enforcer *BpfEnforcer) doShared(operation func()) {
+	if !enforcer.acquireShared() {
+		return
+	}
+	defer enforcer.releaseShared()
+	operation()
+}
+
+// exclusive runs the operation which reads the caches while no worker is handling the container events
+func (enforcer *BpfEnforcer) exclusive(operation func()) {
+	enforcer.handlerLock.Lock()
+	defer enforcer.handlerLock.Unlock()
+	operation()
+}
+
+// drainEvents handles the pending container events and violation events without blocking
+func (enforcer *BpfEnforcer) drainEvents() {
+	for {
+		select {
+		case info := <-enforcer.TaskCreateCh:
+			enforcer.handleTaskEvent(info, enforcer.handleTaskCreate)
+		case info := <-enforcer.TaskDeleteCh:
+			enforcer.handleTaskEvent(info, enforcer.handleTaskDelete)
+		case request := <-enforcer.enforceCh:
+			enforcer.handleEnforceRequest(request)
+		case request := <-enforcer.suspendCh:
+			enforcer.handleSuspendRequest(request)
+		case result := <-enforcer.releaseCh:
+			enforcer.handleReleaseRequest(result)
+		case event := <-enforcer.violationCh:
+			enforcer.exclusive(func() { enforcer.handleViolation(&event) })
+		default:
+			return
+		}
+	}
+}
+
+// RunContext runs the enforcer until the context is canceled
+func (enforcer *BpfEnforcer) RunContext(ctx context.Context) {
+	enforcer.Run(ctx.Done())
+}
+
+// Shutdown stops the enforcer gracefully. It waits for the event handler to drain the pending events if the
+// enforcer is running, then waits for the pending map operations and releases the BPF resources with Close.
+// The stopCh of Run (or the context of RunContext) must be closed first. It returns the error of the context
+// if the context is done before the event handler exits, the BPF resources are released anyway.
+func (enforcer *BpfEnforcer) Shutdown(ctx context.Context) error {
+	var err error
+	if enforcer.running.Load() {
+		select {
+		case <-enforcer.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	enforcer.Close()
+	return err
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_do(t *testing.T) {
+	enforcer := &BpfEnforcer{}
+
+	called := false
+	enforcer.do(func() { called = true })
+	assert.Equal(t, called, true)
+
+	enforcer.closed = true
+	called = false
+	enforcer.do(func() { called = true })
+	assert.Equal(t, called, false)
+	assert.Equal(t, enforcer.acquire(), false)
+}
+
+func Test_publicOperationsAfterClosed(t *testing.T) {
+	enforcer := &BpfEnforcer{closed: true}
+
+	err := enforcer.DeleteBpfProfile(context.Background(), "test")
+	assert.Equal(t, err, errEnforcerClosed)
+
+	_, err = enforcer.ApplyBpfProfileToProcess(1, varmor.BpfContent{})
+	assert.Equal(t, err, errEnforcerClosed)
+}
+
+func Test_drainEvents(t *testing.T) {
+	enforcer := &BpfEnforcer{
+		TaskCreateCh: make(chan varmortypes.ContainerInfo, 2),
+		TaskDeleteCh: make(chan varmortypes.ContainerInfo, 2),
+		closed:       true,
+	}
+	enforcer.TaskCreateCh <- varmortypes.ContainerInfo{ContainerID: "a"}
+	enforcer.TaskCreateCh <- varmortypes.ContainerInfo{ContainerID: "b"}
+	enforcer.TaskDeleteCh <- varmortypes.ContainerInfo{ContainerID: "a"}
+
+	enforcer.drainEvents()
+	assert.Equal(t, len(enforcer.TaskCreateCh), 0)
+	assert.Equal(t, len(enforcer.TaskDeleteCh), 0)
+}
+
+func Test_Shutdown(t *testing.T) {
+	enforcer := &BpfEnforcer{closed: true, done: make(chan struct{})}
+	enforcer.running.Store(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, enforcer.Shutdown(ctx), context.Canceled)
+
+	close(enforcer.done)
+	assert.NilError(t, enforcer.Shutdown(context.Background()))
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"net"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// privateRanges are the CIDRs of the PrivateRangesMacro
+var privateRanges = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"}
+
+// newNetworkMacros returns the CIDRs of the network macros with the configuration of the cluster and the node
+func newNetworkMacros(opts *Options) (map[string][]*net.IPNet, error) {
+	parse := func(cidrs []string) ([]*net.IPNet, error) {
+		var ipNets []*net.IPNet
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, err
+			}
+			ipNets = append(ipNets, ipNet)
+		}
+		return ipNets, nil
+	}
+
+	macros := make(map[string][]*net.IPNet)
+	var err error
+	macros[varmortypes.ClusterPodsMacro], err = parse(opts.ClusterPodCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR of the cluster pods: %w", err)
+	}
+	macros[varmortypes.ClusterServicesMacro], err = parse(opts.ClusterServiceCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR of the cluster services: %w", err)
+	}
+	macros[varmortypes.PrivateRangesMacro], err = parse(privateRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range opts.NodeAddresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address of the node: %s", address)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		macros[varmortypes.NodeLocalMacro] = append(macros[varmortypes.NodeLocalMacro], &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	return macros, nil
+}
+
+// expandNetworkMacros replaces the network rules of the macros with the rules of their CIDRs in place to preserve
+// the order of rules. It returns the macros which aren't configured, their rules are dropped.
+func expandNetworkMacros(networks []varmor.NetworkContent, macros map[string][]*net.IPNet) ([]varmor.NetworkContent, []string) {
+	var unresolved []string
+	expanded := make([]varmor.NetworkContent, 0, len(networks))
+	for _, network := range networks {
+		if network.Flags&cidrMatch == 0 || !varmortypes.IsNetworkMacro(network.CIDR) {
+			expanded = append(expanded, network)
+			continue
+		}
+
+		ipNets := macros[network.CIDR]
+		if len(ipNets) == 0 {
+			unresolved = append(unresolved, network.CIDR)
+			continue
+		}
+		for _, ipNet := range ipNets {
+			content := network
+			content.Address = ipNet.IP.String()
+			content.CIDR = ipNet.String()
+			if ipNet.IP.To4() != nil {
+				content.Flags |= ipv4Match
+			} else {
+				content.Flags |= ipv6Match
+			}
+			expanded = append(expanded, content)
+		}
+	}
+	return expanded, unresolved
+}
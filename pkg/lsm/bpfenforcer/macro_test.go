@@ -0,0 +1,53 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_expandNetworkMacros(t *testing.T) {
+	macros, err := newNetworkMacros(&Options{
+		ClusterPodCIDRs: []string{"10.244.0.0/16", "fd00:10:244::/56"},
+		NodeAddresses:   []string{"192.168.0.10"},
+	})
+	assert.NilError(t, err)
+
+	networks := []varmor.NetworkContent{
+		{Flags: preciseMatch | ipv4Match, Address: "10.0.0.1", RuleID: "bpfRawRules.network.egresses/0"},
+		{Flags: cidrMatch | portMatch, CIDR: varmortypes.ClusterPodsMacro, Port: 6379, RuleID: "bpfRawRules.network.egresses/1"},
+		{Flags: cidrMatch, CIDR: varmortypes.ClusterServicesMacro, RuleID: "bpfRawRules.network.egresses/2"},
+		{Flags: cidrMatch, CIDR: varmortypes.NodeLocalMacro, RuleID: "bpfRawRules.network.egresses/3"},
+	}
+	expanded, unresolved := expandNetworkMacros(networks, macros)
+	assert.DeepEqual(t, unresolved, []string{varmortypes.ClusterServicesMacro})
+	assert.DeepEqual(t, expanded, []varmor.NetworkContent{
+		{Flags: preciseMatch | ipv4Match, Address: "10.0.0.1", RuleID: "bpfRawRules.network.egresses/0"},
+		{Flags: cidrMatch | portMatch | ipv4Match, Address: "10.244.0.0", CIDR: "10.244.0.0/16", Port: 6379, RuleID: "bpfRawRules.network.egresses/1"},
+		{Flags: cidrMatch | portMatch | ipv6Match, Address: "fd00:10:244::", CIDR: "fd00:10:244::/56", Port: 6379, RuleID: "bpfRawRules.network.egresses/1"},
+		{Flags: cidrMatch | ipv4Match, Address: "192.168.0.10", CIDR: "192.168.0.10/32", RuleID: "bpfRawRules.network.egresses/3"},
+	})
+
+	expanded, _ = expandNetworkMacros([]varmor.NetworkContent{{Flags: cidrMatch, CIDR: varmortypes.PrivateRangesMacro}}, macros)
+	assert.Equal(t, len(expanded), len(privateRanges))
+
+	_, err = newNetworkMacros(&Options{ClusterServiceCIDRs: []string{"10.96.0.0"}})
+	assert.ErrorContains(t, err, "invalid CIDR of the cluster services")
+}
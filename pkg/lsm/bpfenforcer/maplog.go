@@ -0,0 +1,136 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	mapOpInsert   = "insert"
+	mapOpDelete   = "delete"
+	mapOpRollback = "rollback"
+)
+
+// mapOpLogger logs the individual operations on the entries of the maps for debugging. The operations are sampled,
+// and the logs are rate limited per second, so it can be enabled on the busy nodes. It's disabled if it's nil.
+type mapOpLogger struct {
+	lock     sync.Mutex
+	log      logr.Logger
+	limit    int
+	sampling uint64
+	seen     uint64
+	// window is the start of the current second, logged and suppressed are counted in it
+	window     time.Time
+	logged     int
+	suppressed int
+	now        func() time.Time
+}
+
+// newMapOpLogger creates the logger which logs at most limit operations per second, and one of every sampling
+// operations. It returns nil if the limit isn't positive.
+func newMapOpLogger(log logr.Logger, limit int, sampling int) *mapOpLogger {
+	if limit <= 0 {
+		return nil
+	}
+	if sampling < 1 {
+		sampling = 1
+	}
+	return &mapOpLogger{
+		log:      log.WithName("mapOps"),
+		limit:    limit,
+		sampling: uint64(sampling),
+		now:      time.Now,
+	}
+}
+
+// allow reports whether the operation is sampled and within the limit. It returns the count of the operations
+// that were sampled but suppressed by the limit in the previous window when a new window starts.
+func (l *mapOpLogger) allow() (bool, int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.seen++
+	if (l.seen-1)%l.sampling != 0 {
+		return false, 0
+	}
+
+	suppressed := 0
+	now := l.now()
+	if now.Sub(l.window) >= time.Second {
+		suppressed = l.suppressed
+		l.window = now
+		l.logged = 0
+		l.suppressed = 0
+	}
+
+	if l.logged >= l.limit {
+		l.suppressed++
+		return false, suppressed
+	}
+	l.logged++
+	return true, suppressed
+}
+
+// ruleClassOf returns the class of the rules saved in the map, e.g. "file" for V_fileOuter
+func ruleClassOf(mapName string) string {
+	class := strings.TrimPrefix(mapName, "V_")
+	class = strings.TrimSuffix(class, "Outer")
+	return class
+}
+
+// logOp logs the operation on the entry of the mnt ns. The entries is the count of the rules in the inner map.
+func (l *mapOpLogger) logOp(op string, mapName string, nsID uint32, entries int) {
+	if l == nil {
+		return
+	}
+
+	ok, suppressed := l.allow()
+	if suppressed != 0 {
+		l.log.Info("the map operations were suppressed by the rate limit", "count", suppressed)
+	}
+	if !ok {
+		return
+	}
+
+	l.log.Info("map operation", "op", op, "map", mapName, "class", ruleClassOf(mapName),
+		"mnt ns id", nsID, "entries", entries)
+}
+
+// logChange logs the committed or rolled back change of the mnt ns entry
+func (l *mapOpLogger) logChange(c *mapChange, nsID uint32, rollback bool) {
+	if l == nil {
+		return
+	}
+
+	op := mapOpInsert
+	value := c.value
+	if rollback {
+		op = mapOpRollback
+		value = c.previous
+	} else if value == nil {
+		op = mapOpDelete
+	}
+
+	entries := 0
+	if value != nil && !rollback {
+		entries = c.entries
+	}
+	l.logOp(op, c.name, nsID, entries)
+}
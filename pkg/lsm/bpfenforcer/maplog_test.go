@@ -0,0 +1,55 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+)
+
+func Test_mapOpLogger(t *testing.T) {
+	assert.Assert(t, newMapOpLogger(logr.Discard(), 0, 1) == nil)
+	// The nil logger is disabled
+	var disabled *mapOpLogger
+	disabled.logOp(mapOpInsert, "V_fileOuter", 4026532001, 3)
+
+	now := time.Unix(1700000000, 0)
+	l := newMapOpLogger(logr.Discard(), 2, 2)
+	l.now = func() time.Time { return now }
+
+	// One of every two operations is sampled, and two of them are logged per second
+	var allowed []bool
+	for i := 0; i < 8; i++ {
+		ok, suppressed := l.allow()
+		assert.Equal(t, suppressed, 0)
+		allowed = append(allowed, ok)
+	}
+	assert.DeepEqual(t, allowed, []bool{true, false, true, false, false, false, false, false})
+
+	// The suppressed ones are reported when the next window starts
+	now = now.Add(time.Second)
+	ok, suppressed := l.allow()
+	assert.Assert(t, ok)
+	assert.Equal(t, suppressed, 2)
+}
+
+func Test_ruleClassOf(t *testing.T) {
+	assert.Equal(t, ruleClassOf("V_fileOuter"), "file")
+	assert.Equal(t, ruleClassOf("V_netOuter"), "net")
+	assert.Equal(t, ruleClassOf("V_capable"), "capable")
+}
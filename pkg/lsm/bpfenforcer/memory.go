@@ -0,0 +1,222 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+
+	ebpf "github.com/cilium/ebpf"
+)
+
+// mapMemoryPressureRatio is the ratio of the limit above which the memory of inner maps is under pressure
+const mapMemoryPressureRatio = 0.9
+
+// metrics of the memory consumed by the inner maps, they are published with expvar
+var (
+	innerMapCount        = new(expvar.Int)
+	innerMapBytes        = new(expvar.Int)
+	innerMapBytesLimit   = new(expvar.Int)
+	mapMemoryRejections  = new(expvar.Int)
+	profileInnerMapBytes = new(expvar.Map)
+)
+
+func init() {
+	metrics.Set("inner_maps", innerMapCount)
+	metrics.Set("inner_map_bytes", innerMapBytes)
+	metrics.Set("inner_map_bytes_limit", innerMapBytesLimit)
+	metrics.Set("map_memory_rejections_total", mapMemoryRejections)
+	metrics.Set("profile_inner_map_bytes", profileInnerMapBytes)
+}
+
+// mapMemoryUsage is the memory consumed by the inner maps of a mnt ns
+type mapMemoryUsage struct {
+	maps        int
+	bytes       uint64
+	profileName string
+}
+
+// innerMapMemory estimates the memory consumed by the inner map with its key size, value size and max entries
+func innerMapMemory(m *ebpf.Map) uint64 {
+	return uint64(m.KeySize()+m.ValueSize()) * uint64(m.MaxEntries())
+}
+
+// stagedMapMemory returns the count and the memory of the inner maps staged for a mnt ns
+func stagedMapMemory(changes []*mapChange) mapMemoryUsage {
+	var usage mapMemoryUsage
+	for _, change := range changes {
+		if innerMap, ok := change.value.(*ebpf.Map); ok {
+			usage.maps++
+			usage.bytes += innerMapMemory(innerMap)
+		}
+	}
+	return usage
+}
+
+// previousMapMemory returns the count and the memory of the inner maps saved by the snapshots of the changes
+func previousMapMemory(changes []*mapChange) mapMemoryUsage {
+	var usage mapMemoryUsage
+	for _, change := range changes {
+		if innerMap, ok := change.previous.(*ebpf.Map); ok {
+			usage.maps++
+			usage.bytes += innerMapMemory(innerMap)
+		}
+	}
+	return usage
+}
+
+// mapMemoryStore accounts the memory consumed by the inner maps per mnt ns, and caps the total of the node
+type mapMemoryStore struct {
+	lock   sync.Mutex
+	limit  uint64 // no limit if zero
+	maps   int
+	bytes  uint64
+	usages map[uint32]mapMemoryUsage // <mntNsID: mapMemoryUsage>
+}
+
+func newMapMemoryStore(limit uint64) *mapMemoryStore {
+	innerMapBytesLimit.Set(int64(limit))
+	return &mapMemoryStore{
+		limit:  limit,
+		usages: make(map[uint32]mapMemoryUsage),
+	}
+}
+
+// check returns an error if replacing the inner maps of the mnt ns with the staged ones exceeds the limit
+func (s *mapMemoryStore) check(nsID uint32, usage mapMemoryUsage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.limit == 0 {
+		return nil
+	}
+
+	bytes := s.bytes - s.usages[nsID].bytes + usage.bytes
+	if bytes > s.limit {
+		mapMemoryRejections.Add(1)
+		return fmt.Errorf("the memory of BPF inner maps on the node would exceed the limit (%d/%d bytes)", bytes, s.limit)
+	}
+	return nil
+}
+
+// save records the inner maps committed for the mnt ns
+func (s *mapMemoryStore) save(nsID uint32, usage mapMemoryUsage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old := s.usages[nsID]
+	usage.profileName = old.profileName
+	s.maps = s.maps - old.maps + usage.maps
+	s.bytes = s.bytes - old.bytes + usage.bytes
+	s.usages[nsID] = usage
+
+	s.publish(usage.profileName)
+}
+
+// replace returns the usage of the mnt ns after the inner maps of the previous usage are replaced by the staged ones
+func (s *mapMemoryStore) replace(nsID uint32, previous mapMemoryUsage, staged mapMemoryUsage) mapMemoryUsage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	usage := s.usages[nsID]
+	usage.maps = usage.maps - previous.maps + staged.maps
+	usage.bytes = usage.bytes - previous.bytes + staged.bytes
+	return usage
+}
+
+// setProfile associates the mnt ns with the profile that is applied to it
+func (s *mapMemoryStore) setProfile(nsID uint32, profileName string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	usage, ok := s.usages[nsID]
+	if !ok || usage.profileName == profileName {
+		return
+	}
+
+	oldProfileName := usage.profileName
+	usage.profileName = profileName
+	s.usages[nsID] = usage
+
+	s.publish(oldProfileName)
+	s.publish(profileName)
+}
+
+func (s *mapMemoryStore) delete(nsID uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	usage, ok := s.usages[nsID]
+	if !ok {
+		return
+	}
+	s.maps -= usage.maps
+	s.bytes -= usage.bytes
+	delete(s.usages, nsID)
+
+	s.publish(usage.profileName)
+}
+
+// publish updates the metrics of the node and the profile, the caller must hold the lock
+func (s *mapMemoryStore) publish(profileName string) {
+	innerMapCount.Set(int64(s.maps))
+	innerMapBytes.Set(int64(s.bytes))
+
+	if profileName == "" {
+		return
+	}
+
+	var bytes uint64
+	for _, usage := range s.usages {
+		if usage.profileName == profileName {
+			bytes += usage.bytes
+		}
+	}
+	if bytes == 0 {
+		profileInnerMapBytes.Delete(profileName)
+		return
+	}
+	v := new(expvar.Int)
+	v.Set(int64(bytes))
+	profileInnerMapBytes.Set(profileName, v)
+}
+
+// pressure returns the memory of the inner maps on the node and the limit, and whether it's under pressure
+func (s *mapMemoryStore) pressure() (uint64, uint64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.limit == 0 {
+		return s.bytes, s.limit, false
+	}
+	return s.bytes, s.limit, float64(s.bytes) >= float64(s.limit)*mapMemoryPressureRatio
+}
+
+// MapMemory returns the count and the memory in bytes of the inner maps on the node
+func (enforcer *BpfEnforcer) MapMemory() (int, uint64) {
+	enforcer.mapMemory.lock.Lock()
+	defer enforcer.mapMemory.lock.Unlock()
+	return enforcer.mapMemory.maps, enforcer.mapMemory.bytes
+}
+
+// MapMemoryPressure returns a message if the memory of the inner maps on the node is close to the limit
+func (enforcer *BpfEnforcer) MapMemoryPressure() string {
+	bytes, limit, high := enforcer.mapMemory.pressure()
+	if !high {
+		return ""
+	}
+	return fmt.Sprintf("the memory of BPF inner maps on the node is under pressure (%d/%d bytes)", bytes, limit)
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_mapMemoryStore(t *testing.T) {
+	s := newMapMemoryStore(1000)
+
+	assert.NilError(t, s.check(1, mapMemoryUsage{maps: 2, bytes: 600}))
+	s.save(1, mapMemoryUsage{maps: 2, bytes: 600})
+	s.setProfile(1, "varmor-default-test")
+
+	// The usage of the other mnt ns counts against the limit
+	assert.Assert(t, s.check(2, mapMemoryUsage{maps: 1, bytes: 500}) != nil)
+
+	// The inner maps of the same mnt ns are replaced
+	assert.NilError(t, s.check(1, mapMemoryUsage{maps: 3, bytes: 950}))
+	s.save(1, mapMemoryUsage{maps: 3, bytes: 950})
+	assert.Equal(t, s.usages[1].profileName, "varmor-default-test")
+
+	bytes, limit, high := s.pressure()
+	assert.Equal(t, bytes, uint64(950))
+	assert.Equal(t, limit, uint64(1000))
+	assert.Equal(t, high, true)
+
+	s.delete(1)
+	assert.Equal(t, s.maps, 0)
+	assert.Equal(t, s.bytes, uint64(0))
+	assert.NilError(t, s.check(2, mapMemoryUsage{maps: 1, bytes: 500}))
+
+	// No limit
+	s = newMapMemoryStore(0)
+	assert.NilError(t, s.check(1, mapMemoryUsage{maps: 1, bytes: 1 << 40}))
+}
@@ -0,0 +1,340 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bpfenforcer enforces the BPF profiles of vArmor on containers with the BPF LSM. It can be embedded
+// by other programs:
+//
+//	enforcer, err := bpfenforcer.New(bpfenforcer.Options{
+//		ProfileResolver: func(info varmortypes.ContainerInfo) (string, bool) { ... },
+//		ViolationSink:   func(violation varmortypes.Violation) { ... },
+//	})
+//	if err != nil { ... }
+//	defer enforcer.Close()
+//	_, err = enforcer.SaveAndApplyBpfProfile(ctx, "profile", bpfContent)
+//	go enforcer.RunContext(ctx)
+//	enforcer.TaskCreateCh <- varmortypes.ContainerInfo{ContainerID: "id", PID: pid}
+//
+// The container events are sent to the TaskCreateCh and TaskDeleteCh by the caller, so it's not bound to a
+// specific container runtime. The caller that participates in the container creation can use EnforceContainer
+// to enforce the container synchronously instead.
+package bpfenforcer
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/go-logr/logr"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// outerMapNames are the maps of the BPF program which are keyed by the mnt ns id
+var outerMapNames = []string{
+	"v_capable",
+	"v_file_outer",
+	"v_bprm_outer",
+	"v_net_outer",
+	"v_ptrace",
+	"v_mount_outer",
+}
+
+// Options configures the BpfEnforcer, so it can be embedded by other programs. The zero value uses the defaults
+// of vArmor agent.
+type Options struct {
+	// TaskChannelCapacity is the capacity of the channels which receive the container events.
+	// The varmortypes.DefaultTaskChannelCapacity is used if it's zero.
+	TaskChannelCapacity int
+	// MapMemoryLimit caps the memory in bytes consumed by the inner maps, no limit if zero.
+	MapMemoryLimit uint64
+	// ObjectPath is the path of a custom BPF object file compiled from the BPF program of vArmor.
+	// If it's a directory, the object of the node's architecture named by bpf2go (e.g. bpf_x86_bpfel.o
+	// or bpf_arm64_bpfel.o) is selected from it. The embedded one is used if it's empty.
+	ObjectPath string
+	// MaxMntNsCount overrides the max entries of the maps keyed by the mnt ns id, i.e. the max count of
+	// the containers that can be enforced. The one of the BPF object is used if it's zero.
+	MaxMntNsCount uint32
+	// ProfileResolver returns the name of the BPF profile that the container should be enforced with.
+	// By default, the profile is resolved from the pod annotations set by the webhook of vArmor. The sandbox
+	// (pause) containers are marked by the Sandbox field of the ContainerInfo.
+	ProfileResolver func(info varmortypes.ContainerInfo) (string, bool)
+	// BaseProfileResolver returns the name of the base BPF profile that is layered under the profile of the
+	// container, e.g. the profile of a cluster-wide policy. The rules of the base profile take precedence over the
+	// ones of the container's profile. By default, it's resolved from the BaseBpfAnnotationPrefix annotations of
+	// the pod set by the webhook of vArmor.
+	BaseProfileResolver func(info varmortypes.ContainerInfo) (string, bool)
+	// ViolationSink receives the violations. They are sent to the ViolationCh if it's nil.
+	// It's called by the event handler of the enforcer, so it must not block.
+	ViolationSink func(violation varmortypes.Violation)
+	// DeadLetterSink is called with the profile name when the profile persistently failed to apply to
+	// a container, or the failure was recovered, or the containers pending to be enforced under the memory
+	// pressure changed. They are sent to the DeadLetterCh if it's nil.
+	// It must not block.
+	DeadLetterSink func(profileName string)
+	// TamperSink receives the entries of the maps that were modified by anything other than the enforcer. They are
+	// sent to the TamperCh if it's nil. It's called by the event handler of the enforcer, so it must not block.
+	TamperSink func(tamper varmortypes.Tamper)
+	// SuspensionSink receives the containers whose enforcement was suspended or resumed. They are sent to the
+	// SuspensionCh if it's nil. It's called by the event handler of the enforcer, so it must not block.
+	SuspensionSink func(suspension varmortypes.Suspension)
+	// KeepEnforcementOnShutdown leaves the enforcement in place when the enforcer is closed. The BPF programs are
+	// pinned to the PinPath, and they're taken over when a new enforcer is created with the same PinPath. They're
+	// detached after the new enforcer calls ReleasePreviousGeneration.
+	KeepEnforcementOnShutdown bool
+	// PinPath is the directory in the BPF filesystem that the BPF programs are pinned to.
+	// The defaultPinPath is used if it's empty.
+	PinPath string
+	// ApplyLatencySLO is the objective of the time from the container task creation to the BPF profile being
+	// enforced. The breaches are counted and logged. The defaultApplyLatencySLO is used if it's zero.
+	ApplyLatencySLO time.Duration
+	// ViolationAggregationWindow is the window of aggregating the identical violations, which are of the same mnt
+	// ns, rule and operation. The first one is reported immediately, and the others in the window are reported as
+	// one violation with the count when the window ends. The defaultViolationAggregationWindow is used if it's zero,
+	// and the aggregation is disabled if it's negative.
+	ViolationAggregationWindow time.Duration
+	// ClusterPodCIDRs are the CIDRs of the pods in the cluster, they are matched by the "@cluster-pods" macro of the
+	// network rules. The rules of the macro are dropped if it's empty.
+	ClusterPodCIDRs []string
+	// ClusterServiceCIDRs are the CIDRs of the services in the cluster, they are matched by the "@cluster-services"
+	// macro of the network rules. The rules of the macro are dropped if it's empty.
+	ClusterServiceCIDRs []string
+	// NodeAddresses are the IP addresses of the node, they are matched by the "@node-local" macro of the network
+	// rules. The rules of the macro are dropped if it's empty.
+	NodeAddresses []string
+	// DefaultProfile is the name of the BPF profile that the containers are enforced with if the ProfileResolver
+	// doesn't resolve a profile for them, i.e. the node runs in the default-deny mode. The containers without any
+	// profile run unrestricted if it's empty. It's never enforced on the sandbox containers.
+	DefaultProfile string
+	// DefaultProfileExcludedNamespaces are the namespaces of the pods that the DefaultProfile isn't enforced on,
+	// e.g. the namespaces of the system components.
+	DefaultProfileExcludedNamespaces []string
+	// Workers is the count of the workers that handle the container events in parallel, which are dispatched by the
+	// container id, so the events of a container are handled in order. It's useful on the nodes with thousands of
+	// containers. The events are handled by the event handler one by one if it's less than 2.
+	Workers int
+	// JournalPath is the path of the journal of the profile operations. If the previous enforcer with the same path
+	// crashed, the containers it enforced are enforced again once their profiles are saved, instead of waiting for
+	// the resync of the containers. It should be on the host to survive the restarts. It's disabled if empty.
+	JournalPath string
+	// MapOpLogRate is the maximum count of the operations on the entries of the maps that are logged per second,
+	// i.e. the insertions and the deletions with the rule class, the key and the mnt ns of the entries. It's
+	// disabled if zero. MapOpLogSampling logs one of every MapOpLogSampling operations, all of them if less than 2.
+	MapOpLogRate     int
+	MapOpLogSampling int
+	// PressureStallThreshold enables the backoff of the enforcement under the memory pressure of the node. When the
+	// percentage of the time that all the tasks stalled on the memory in the last 10 seconds (the "full avg10" of
+	// the memory PSI) reaches it, or the allocations of the maps failed with ENOMEM repeatedly, the new containers
+	// aren't enforced. They're reported as pending by Pending, and enforced when the pressure subsides. The enforced
+	// containers are kept. It's disabled if zero.
+	PressureStallThreshold float64
+	// Log is the logger of the enforcer. The logs are discarded if it's not set.
+	Log logr.Logger
+}
+
+// resolveProfileFromAnnotations resolves the BPF profile of the container from the pod annotations set by the webhook
+func resolveProfileFromAnnotations(info varmortypes.ContainerInfo) (string, bool) {
+	key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", info.ContainerName)
+	if info.Sandbox {
+		// The sandbox container never matches the annotations of the application containers
+		key = varmortypes.SandboxBpfAnnotation
+	}
+	value := info.PodAnnotations[key]
+	if !strings.HasPrefix(value, "localhost/") {
+		return "", false
+	}
+	return value[len("localhost/"):], true
+}
+
+// resolveBaseProfileFromAnnotations resolves the base BPF profile of the container from the pod annotations
+func resolveBaseProfileFromAnnotations(info varmortypes.ContainerInfo) (string, bool) {
+	if info.Sandbox {
+		return "", false
+	}
+	value := info.PodAnnotations[varmortypes.BaseBpfAnnotationPrefix+info.ContainerName]
+	if !strings.HasPrefix(value, "localhost/") {
+		return "", false
+	}
+	return value[len("localhost/"):], true
+}
+
+// resolveBaseProfile resolves the base BPF profile of the container with the BaseProfileResolver
+func (opts *Options) resolveBaseProfile(info varmortypes.ContainerInfo) (string, bool) {
+	if opts.BaseProfileResolver == nil {
+		return "", false
+	}
+	return opts.BaseProfileResolver(info)
+}
+
+// resolveProfile resolves the BPF profile of the container with the ProfileResolver. The DefaultProfile is consulted
+// if no profile is resolved, unless the container is a sandbox container or the pod is in the excluded namespaces.
+func (opts *Options) resolveProfile(info varmortypes.ContainerInfo) (string, bool) {
+	if profileName, ok := opts.ProfileResolver(info); ok {
+		return profileName, true
+	}
+	if opts.DefaultProfile == "" || info.Sandbox {
+		return "", false
+	}
+	for _, namespace := range opts.DefaultProfileExcludedNamespaces {
+		if info.PodNamespace == namespace {
+			return "", false
+		}
+	}
+	return opts.DefaultProfile, true
+}
+
+// resolveProfiles resolves the BPF profile of the container and the base profile layered under it. The container is
+// enforced with the base profile alone if it has no profile, and the base name is empty if it isn't layered.
+func (opts *Options) resolveProfiles(info varmortypes.ContainerInfo) (string, string, bool) {
+	profileName, ok := opts.resolveProfile(info)
+	baseName, layered := opts.resolveBaseProfile(info)
+	if !ok {
+		if !layered {
+			return "", "", false
+		}
+		profileName = baseName
+	}
+	if baseName == profileName {
+		baseName = ""
+	}
+	return profileName, baseName, true
+}
+
+// New create a BpfEnforcer with the options, and initialize the BPF settings and resources.
+func New(opts Options) (*BpfEnforcer, error) {
+	if opts.TaskChannelCapacity <= 0 {
+		opts.TaskChannelCapacity = varmortypes.DefaultTaskChannelCapacity
+	}
+	if opts.ProfileResolver == nil {
+		opts.ProfileResolver = resolveProfileFromAnnotations
+	}
+	if opts.BaseProfileResolver == nil {
+		opts.BaseProfileResolver = resolveBaseProfileFromAnnotations
+	}
+	if opts.PinPath == "" {
+		opts.PinPath = defaultPinPath
+	}
+	if opts.ApplyLatencySLO <= 0 {
+		opts.ApplyLatencySLO = defaultApplyLatencySLO
+	}
+	applyLatencySLOSeconds.Set(opts.ApplyLatencySLO.Seconds())
+	if opts.ViolationAggregationWindow == 0 {
+		opts.ViolationAggregationWindow = defaultViolationAggregationWindow
+	}
+	if opts.Log.GetSink() == nil {
+		opts.Log = logr.Discard()
+	}
+	networkMacros, err := newNetworkMacros(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	enforcer := BpfEnforcer{
+		TaskCreateCh:     make(chan varmortypes.ContainerInfo, opts.TaskChannelCapacity),
+		TaskDeleteCh:     make(chan varmortypes.ContainerInfo, opts.TaskChannelCapacity),
+		TaskDeleteSyncCh: make(chan bool, 1),
+		DeadLetterCh:     make(chan string, 100),
+		retryCh:          make(chan string, 100),
+		ViolationCh:      make(chan varmortypes.Violation, 500),
+		TamperCh:         make(chan varmortypes.Tamper, 100),
+		SuspensionCh:     make(chan varmortypes.Suspension, 100),
+		enforceCh:        make(chan enforceRequest),
+		suspendCh:        make(chan suspendRequest),
+		releaseCh:        make(chan chan error),
+		opts:             opts,
+		objs:             bpfObjects{},
+		bpfProfileCache:  make(map[string]bpfProfile),
+		containerCache:   make(map[string]enforceID),
+		containerInfos:   make(map[string]varmortypes.ContainerInfo),
+		conflicts:        make(map[string][]string),
+		deadLetters:      make(map[string]deadLetter),
+		exitedContainers: make(map[uint32]violationContainer),
+		suspensions:      make(map[string]suspension),
+		layers:           make(map[string]string),
+		pendings:         make(map[string]pendingContainer),
+		pressure:         newPressureMonitor(opts.PressureStallThreshold),
+		violationCh:      make(chan bpfViolationEvent, 500),
+		done:             make(chan struct{}),
+		ruleIDs:          newRuleIDStore(),
+		mapMemory:        newMapMemoryStore(opts.MapMemoryLimit),
+		fingerprints:     newFingerprintStore(),
+		warmUps:          newWarmUpStore(),
+		leaks:            newLeakStore(),
+		mapOps:           newMapOpLogger(opts.Log, opts.MapOpLogRate, opts.MapOpLogSampling),
+		hashes:           newHashCache(),
+		networkMacros:    networkMacros,
+		log:              opts.Log,
+	}
+
+	err = enforcer.initBPF()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.JournalPath != "" {
+		enforcer.journal, enforcer.recovery, err = openJournal(opts.JournalPath)
+		if err != nil {
+			enforcer.Close()
+			return nil, err
+		}
+		if enforcer.recovery != nil {
+			enforcer.log.Info("the previous enforcer crashed, its operations will be replayed",
+				"enforced", len(enforcer.recovery.enforced), "interrupted", len(enforcer.recovery.interrupted))
+		}
+	}
+
+	enforcer.regexWatcher, err = newRegexWatcher(opts.Log.WithName("regexWatcher"))
+	if err != nil {
+		enforcer.Close()
+		return nil, err
+	}
+
+	err = enforcer.createViolationReader()
+	if err != nil {
+		enforcer.Close()
+		return nil, err
+	}
+	return &enforcer, nil
+}
+
+// loadCollectionSpec parses the BPF object into a CollectionSpec, and applies the options to it
+func (enforcer *BpfEnforcer) loadCollectionSpec() (*ebpf.CollectionSpec, error) {
+	var collectionSpec *ebpf.CollectionSpec
+	var err error
+	if enforcer.opts.ObjectPath != "" {
+		objectPath, err := resolveObjectPath(enforcer.opts.ObjectPath, runtime.GOARCH)
+		if err != nil {
+			return nil, err
+		}
+		collectionSpec, err = ebpf.LoadCollectionSpec(objectPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the BPF object %s: %w", objectPath, err)
+		}
+	} else {
+		collectionSpec, err = loadBpf()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if enforcer.opts.MaxMntNsCount != 0 {
+		for _, name := range outerMapNames {
+			if m, ok := collectionSpec.Maps[name]; ok {
+				m.MaxEntries = enforcer.opts.MaxMntNsCount
+			}
+		}
+	}
+	return collectionSpec, nil
+}
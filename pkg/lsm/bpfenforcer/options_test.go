@@ -0,0 +1,101 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_resolveProfileFromAnnotations(t *testing.T) {
+	info := varmortypes.ContainerInfo{
+		ContainerName: "c0",
+		PodAnnotations: map[string]string{
+			"container.bpf.security.beta.varmor.org/c0": "localhost/varmor-demo-test",
+			"container.bpf.security.beta.varmor.org/c1": "unconfined",
+		},
+	}
+
+	profileName, ok := resolveProfileFromAnnotations(info)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, profileName, "varmor-demo-test")
+
+	info.ContainerName = "c1"
+	_, ok = resolveProfileFromAnnotations(info)
+	assert.Equal(t, ok, false)
+
+	info.ContainerName = "c2"
+	_, ok = resolveProfileFromAnnotations(info)
+	assert.Equal(t, ok, false)
+
+	// The sandbox container is only resolved from the sandbox annotation
+	info = varmortypes.ContainerInfo{
+		Sandbox: true,
+		PodAnnotations: map[string]string{
+			"container.bpf.security.beta.varmor.org/": "localhost/varmor-demo-test",
+		},
+	}
+	_, ok = resolveProfileFromAnnotations(info)
+	assert.Equal(t, ok, false)
+
+	info.PodAnnotations[varmortypes.SandboxBpfAnnotation] = "localhost/" + varmortypes.SandboxProfileName
+	profileName, ok = resolveProfileFromAnnotations(info)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, profileName, varmortypes.SandboxProfileName)
+}
+
+func Test_resolveProfile(t *testing.T) {
+	opts := Options{
+		ProfileResolver:                  resolveProfileFromAnnotations,
+		DefaultProfile:                   "varmor-cluster-varmor-baseline",
+		DefaultProfileExcludedNamespaces: []string{"kube-system", "varmor"},
+	}
+	info := varmortypes.ContainerInfo{
+		ContainerName: "c0",
+		PodNamespace:  "demo",
+		PodAnnotations: map[string]string{
+			"container.bpf.security.beta.varmor.org/c0": "localhost/varmor-demo-test",
+		},
+	}
+
+	// The resolved profile takes precedence over the default one
+	profileName, ok := opts.resolveProfile(info)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, profileName, "varmor-demo-test")
+
+	info.ContainerName = "c1"
+	profileName, ok = opts.resolveProfile(info)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, profileName, "varmor-cluster-varmor-baseline")
+
+	info.PodNamespace = "kube-system"
+	_, ok = opts.resolveProfile(info)
+	assert.Equal(t, ok, false)
+
+	// The default profile isn't enforced on the sandbox containers
+	info.PodNamespace = "demo"
+	info.Sandbox = true
+	_, ok = opts.resolveProfile(info)
+	assert.Equal(t, ok, false)
+	info.Sandbox = false
+
+	opts.DefaultProfile = ""
+	info.PodNamespace = "demo"
+	_, ok = opts.resolveProfile(info)
+	assert.Equal(t, ok, false)
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	cidrMatch = 0x00000020
+	ipv4Match = 0x00000040
+	ipv6Match = 0x00000080
+	portMatch = 0x00000100
+)
+
+// expandNetworkPeers appends the network rules of the addresses resolved for the Services and Pods. The network
+// peers are kept in the profile, so the changes of the addresses are still detected.
+func expandNetworkPeers(bpfContent *varmor.BpfContent) {
+	for _, peer := range bpfContent.NetworkPeers {
+		for _, address := range peer.Addresses {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				continue
+			}
+
+			network := varmor.NetworkContent{
+				Flags:   preciseMatch,
+				Address: ip.String(),
+				RuleID:  peer.RuleID,
+			}
+			if ip.To4() != nil {
+				network.Flags |= ipv4Match
+			} else {
+				network.Flags |= ipv6Match
+			}
+			if peer.Port != 0 {
+				network.Flags |= portMatch
+				network.Port = peer.Port
+			}
+			bpfContent.Networks = append(bpfContent.Networks, network)
+		}
+	}
+}
+
+// onlyNetworksChanged returns whether the BPF profiles only differ in the network rules, e.g. the endpoints of
+// a Service referenced by the profile changed
+func onlyNetworksChanged(oldContent varmor.BpfContent, newContent varmor.BpfContent) bool {
+	oldContent.Networks, oldContent.NetworkPeers = nil, nil
+	newContent.Networks, newContent.NetworkPeers = nil, nil
+	return reflect.DeepEqual(oldContent, newContent)
+}
+
+// applyNetworkRules only replaces the network rules of the mnt ns with the ones of the BPF profile, the other
+// rules are kept as they are
+func (enforcer *BpfEnforcer) applyNetworkRules(nsID uint32, bpfContent varmor.BpfContent) error {
+	innerMap, err := newNetInnerMap(nsID, bpfContent.Networks)
+	if err != nil {
+		return fmt.Errorf("failed to stage the network rules: %w", err)
+	}
+	changes := []*mapChange{newOuterMapChange("V_netOuter", enforcer.objs.V_netOuter, innerMap, len(bpfContent.Networks))}
+	defer closeMapChanges(changes)
+
+	return enforcer.commitChanges(nsID, &bpfContent, changes, true)
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_expandNetworkPeers(t *testing.T) {
+	bpfContent := varmor.BpfContent{
+		Capabilities: 1,
+		Networks: []varmor.NetworkContent{
+			{Flags: preciseMatch | ipv4Match, Address: "10.0.0.1", RuleID: "bpfRawRules.network.egresses/0"},
+		},
+		NetworkPeers: []varmor.NetworkPeerContent{
+			{Namespace: "demo", ServiceName: "redis", Port: 6379, Addresses: []string{"10.96.0.10", "fd00::a"}, RuleID: "bpfRawRules.network.egresses/1"},
+			{Namespace: "demo", ServiceName: "unresolved", RuleID: "bpfRawRules.network.egresses/2"},
+		},
+	}
+	oldContent := *bpfContent.DeepCopy()
+
+	expandNetworkPeers(&bpfContent)
+	assert.DeepEqual(t, bpfContent.Networks, []varmor.NetworkContent{
+		{Flags: preciseMatch | ipv4Match, Address: "10.0.0.1", RuleID: "bpfRawRules.network.egresses/0"},
+		{Flags: preciseMatch | ipv4Match | portMatch, Address: "10.96.0.10", Port: 6379, RuleID: "bpfRawRules.network.egresses/1"},
+		{Flags: preciseMatch | ipv6Match | portMatch, Address: "fd00::a", Port: 6379, RuleID: "bpfRawRules.network.egresses/1"},
+	})
+	assert.Equal(t, len(bpfContent.NetworkPeers), 2)
+
+	// Only the network rules are reprogrammed when the addresses change
+	assert.Assert(t, onlyNetworksChanged(oldContent, bpfContent))
+	bpfContent.Capabilities = 2
+	assert.Assert(t, !onlyNetworksChanged(oldContent, bpfContent))
+}
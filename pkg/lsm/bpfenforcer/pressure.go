@@ -0,0 +1,319 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"bufio"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// pressureCheckInterval is the interval of checking whether the node is under memory pressure
+	pressureCheckInterval = 5 * time.Second
+	// pressureMapFailureWindow and pressureMapFailureThreshold: the node is under pressure if the map operations
+	// failed with ENOMEM for the threshold times in the window
+	pressureMapFailureWindow    = time.Minute
+	pressureMapFailureThreshold = 3
+	// pressureMinBackoff is the minimum time to stop onboarding the new containers, so it doesn't flap
+	pressureMinBackoff = 30 * time.Second
+	// pressureResumeRatio is the ratio of the stall threshold below which the pressure subsides
+	pressureResumeRatio = 0.5
+	// memoryPressurePath is the PSI (Pressure Stall Information) of the memory of the node
+	memoryPressurePath = "/proc/pressure/memory"
+)
+
+// errNodePressure is returned when the profile isn't applied to the new container, since the node is under memory
+// pressure. The container is pending, and the profile is applied to it when the pressure subsides.
+var errNodePressure = errors.New("the node is under memory pressure")
+
+var (
+	nodePressure      = new(expvar.Int)
+	pendingContainers = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("node_pressure", nodePressure)
+	metrics.Set("pending_containers", pendingContainers)
+}
+
+// pendingContainer is a new container that waits for the pressure subsiding to be enforced
+type pendingContainer struct {
+	info        varmortypes.ContainerInfo
+	profileName string
+	since       time.Time
+}
+
+// pressureMonitor decides whether the node is under memory pressure with the memory stall of the node and the
+// failures of the map allocations
+type pressureMonitor struct {
+	lock      sync.Mutex
+	threshold float64
+	path      string
+	failures  []time.Time
+	active    bool
+	since     time.Time
+	reason    string
+}
+
+// newPressureMonitor returns nil if the threshold isn't positive, i.e. the backoff is disabled
+func newPressureMonitor(threshold float64) *pressureMonitor {
+	if threshold <= 0 {
+		return nil
+	}
+	return &pressureMonitor{
+		threshold: threshold,
+		path:      memoryPressurePath,
+	}
+}
+
+// readMemoryStall returns the "full avg10" of the memory PSI, i.e. the percentage of the time that all the non-idle
+// tasks stalled on the memory in the last 10 seconds
+func readMemoryStall(path string) (float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "full" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if value, ok := strings.CutPrefix(field, "avg10="); ok {
+				return strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("the full avg10 isn't found in %s", path)
+}
+
+// recordMapFailure records the failure of the map operations that ran out of the memory
+func (m *pressureMonitor) recordMapFailure(now time.Time) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.failures = append(m.failures, now)
+}
+
+// underPressure returns whether the node is under pressure, and the reason
+func (m *pressureMonitor) underPressure() (bool, string) {
+	if m == nil {
+		return false, ""
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.active, m.reason
+}
+
+// evaluate updates the state with the memory stall of the node. The stall is ignored if it failed to read, e.g.
+// PSI isn't enabled in the kernel. It returns true if the state changed.
+func (m *pressureMonitor) evaluate(now time.Time, stall float64, stallErr error) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	kept := m.failures[:0]
+	for _, failure := range m.failures {
+		if now.Sub(failure) < pressureMapFailureWindow {
+			kept = append(kept, failure)
+		}
+	}
+	m.failures = kept
+
+	var reason string
+	switch {
+	case len(m.failures) >= pressureMapFailureThreshold:
+		reason = fmt.Sprintf("%d BPF map allocations failed with ENOMEM in %s", len(m.failures), pressureMapFailureWindow)
+	case stallErr == nil && stall >= m.threshold:
+		reason = fmt.Sprintf("the memory stall of the node is %.2f%% (threshold: %.2f%%)", stall, m.threshold)
+	}
+
+	if reason != "" {
+		m.reason = reason
+		if m.active {
+			return false
+		}
+		m.active = true
+		m.since = now
+		return true
+	}
+
+	if !m.active || now.Sub(m.since) < pressureMinBackoff || len(m.failures) != 0 ||
+		(stallErr == nil && stall >= m.threshold*pressureResumeRatio) {
+		return false
+	}
+	m.active = false
+	m.reason = ""
+	return true
+}
+
+// NodePressure returns a message if the enforcer stopped onboarding the new containers, since the node is under
+// memory pressure
+func (enforcer *BpfEnforcer) NodePressure() string {
+	active, reason := enforcer.pressure.underPressure()
+	if !active {
+		return ""
+	}
+	return "the node is under memory pressure, the new containers are pending to be enforced: " + reason
+}
+
+// Pending returns the containers that the BPF profile is pending to apply to, sorted by the container id
+func (enforcer *BpfEnforcer) Pending(profileName string) []string {
+	enforcer.cacheLock.Lock()
+	defer enforcer.cacheLock.Unlock()
+
+	var pending []string
+	for containerID, p := range enforcer.pendings {
+		if p.profileName == profileName {
+			pending = append(pending, containerID)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// deferContainer records the new container as pending if the node is under pressure. It returns errNodePressure
+// if the container is deferred.
+func (enforcer *BpfEnforcer) deferContainer(info varmortypes.ContainerInfo, profileName string) error {
+	active, reason := enforcer.pressure.underPressure()
+	if !active {
+		return nil
+	}
+
+	enforcer.cacheLock.Lock()
+	p, exist := enforcer.pendings[info.ContainerID]
+	if !exist {
+		p.since = time.Now()
+	}
+	previous := p.profileName
+	p.info = info
+	p.profileName = profileName
+	enforcer.pendings[info.ContainerID] = p
+	pendingContainers.Set(int64(len(enforcer.pendings)))
+	enforcer.cacheLock.Unlock()
+
+	if !exist || previous != profileName {
+		enforcer.log.Info("the node is under memory pressure, the container is pending to be enforced",
+			"profile name", profileName,
+			"pod namespace", info.PodNamespace,
+			"pod name", info.PodName,
+			"container name", info.ContainerName,
+			"container id", info.ContainerID,
+			"reason", reason)
+		enforcer.notifyDeadLetter(profileName)
+		if exist {
+			enforcer.notifyDeadLetter(previous)
+		}
+	}
+	return fmt.Errorf("%w (container id: %s)", errNodePressure, info.ContainerID)
+}
+
+// removePending forgets the pending container, it returns false if the container isn't pending
+func (enforcer *BpfEnforcer) removePending(containerID string) (pendingContainer, bool) {
+	enforcer.cacheLock.Lock()
+	p, ok := enforcer.pendings[containerID]
+	if ok {
+		delete(enforcer.pendings, containerID)
+		pendingContainers.Set(int64(len(enforcer.pendings)))
+	}
+	enforcer.cacheLock.Unlock()
+
+	if ok {
+		enforcer.notifyDeadLetter(p.profileName)
+	}
+	return p, ok
+}
+
+// removePendingOfProfile forgets the pending containers of the profile
+func (enforcer *BpfEnforcer) removePendingOfProfile(profileName string) {
+	for _, containerID := range enforcer.Pending(profileName) {
+		enforcer.removePending(containerID)
+	}
+}
+
+// checkPressure updates whether the node is under memory pressure, and enforces the pending containers after the
+// pressure subsided
+func (enforcer *BpfEnforcer) checkPressure(now time.Time) {
+	if enforcer.pressure == nil {
+		return
+	}
+
+	stall, err := readMemoryStall(enforcer.pressure.path)
+	if !enforcer.pressure.evaluate(now, stall, err) {
+		return
+	}
+
+	active, reason := enforcer.pressure.underPressure()
+	if active {
+		nodePressure.Set(1)
+		enforcer.log.Info("the node is under memory pressure, stop onboarding the new containers", "reason", reason)
+		return
+	}
+	nodePressure.Set(0)
+
+	enforcer.cacheLock.Lock()
+	ids := make([]string, 0, len(enforcer.pendings))
+	for containerID := range enforcer.pendings {
+		ids = append(ids, containerID)
+	}
+	enforcer.cacheLock.Unlock()
+	sort.Strings(ids)
+
+	enforcer.log.Info("the memory pressure of the node subsided, enforce the pending containers", "count", len(ids))
+	for _, containerID := range ids {
+		p, ok := enforcer.removePending(containerID)
+		if !ok {
+			continue
+		}
+		err := enforcer.enforceContainerWithProfile(p.info, p.profileName)
+		if err != nil && !errors.Is(err, errProfileNotExist) && !errors.Is(err, errNodePressure) {
+			enforcer.log.Error(err, "enforceContainerWithProfile() failed", "container id", containerID)
+		}
+	}
+}
+
+// prunePendings forgets the pending containers that have exited, e.g. while the monitor was offline
+func (enforcer *BpfEnforcer) prunePendings() {
+	enforcer.cacheLock.Lock()
+	pendings := make(map[string]uint32, len(enforcer.pendings))
+	for containerID, p := range enforcer.pendings {
+		pendings[containerID] = p.info.PID
+	}
+	enforcer.cacheLock.Unlock()
+
+	for containerID, pid := range pendings {
+		if _, err := enforcer.newEnforceID(pid); err != nil {
+			enforcer.log.Info("the pending container exited", "container id", containerID, "pid", pid)
+			enforcer.removePending(containerID)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_readMemoryStall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory")
+	err := os.WriteFile(path, []byte("some avg10=31.50 avg60=12.00 avg300=3.10 total=1234\nfull avg10=12.25 avg60=4.00 avg300=1.00 total=567\n"), 0600)
+	assert.NilError(t, err)
+
+	stall, err := readMemoryStall(path)
+	assert.NilError(t, err)
+	assert.Equal(t, stall, 12.25)
+
+	err = os.WriteFile(path, []byte("some avg10=31.50 avg60=12.00 avg300=3.10 total=1234\n"), 0600)
+	assert.NilError(t, err)
+	_, err = readMemoryStall(path)
+	assert.ErrorContains(t, err, "isn't found")
+}
+
+func Test_pressureMonitor(t *testing.T) {
+	assert.Assert(t, newPressureMonitor(0) == nil)
+	var disabled *pressureMonitor
+	disabled.recordMapFailure(time.Now())
+	active, _ := disabled.underPressure()
+	assert.Assert(t, !active)
+
+	m := newPressureMonitor(10)
+	now := time.Unix(1700000000, 0)
+	assert.Assert(t, !m.evaluate(now, 5, nil))
+
+	// The stall reaches the threshold
+	assert.Assert(t, m.evaluate(now, 10, nil))
+	active, reason := m.underPressure()
+	assert.Assert(t, active)
+	assert.Equal(t, reason, "the memory stall of the node is 10.00% (threshold: 10.00%)")
+
+	// It doesn't resume before the minimum backoff, or until the stall is low enough
+	assert.Assert(t, !m.evaluate(now.Add(10*time.Second), 1, nil))
+	assert.Assert(t, !m.evaluate(now.Add(pressureMinBackoff), 6, nil))
+	assert.Assert(t, m.evaluate(now.Add(pressureMinBackoff), 4, nil))
+
+	// The failures of the map allocations trigger it without PSI
+	start := now.Add(time.Hour)
+	for i := 0; i < pressureMapFailureThreshold; i++ {
+		m.recordMapFailure(start)
+	}
+	assert.Assert(t, m.evaluate(start, 0, errors.New("no PSI")))
+	assert.Assert(t, !m.evaluate(start.Add(pressureMinBackoff), 0, errors.New("no PSI")))
+	assert.Assert(t, m.evaluate(start.Add(pressureMapFailureWindow), 0, errors.New("no PSI")))
+	active, _ = m.underPressure()
+	assert.Assert(t, !active)
+}
+
+func Test_deferContainer(t *testing.T) {
+	var notified []string
+	enforcer := &BpfEnforcer{
+		opts: Options{
+			DeadLetterSink: func(profileName string) { notified = append(notified, profileName) },
+		},
+		bpfProfileCache: map[string]bpfProfile{"test-profile": {containerCache: make(map[string]enforceID)}},
+		containerCache:  make(map[string]enforceID),
+		containerInfos:  make(map[string]varmortypes.ContainerInfo),
+		suspensions:     make(map[string]suspension),
+		pendings:        make(map[string]pendingContainer),
+		pressure:        newPressureMonitor(10),
+		log:             logr.Discard(),
+	}
+	info := varmortypes.ContainerInfo{ContainerID: "c1", ContainerName: "app", PodNamespace: "demo", PodName: "web"}
+
+	assert.NilError(t, enforcer.deferContainer(info, "test-profile"))
+	assert.Equal(t, len(enforcer.Pending("test-profile")), 0)
+	assert.Equal(t, enforcer.NodePressure(), "")
+
+	enforcer.pressure.evaluate(time.Now(), 50, nil)
+	err := enforcer.enforceContainerWithProfile(info, "test-profile")
+	assert.Assert(t, errors.Is(err, errNodePressure))
+	assert.DeepEqual(t, enforcer.Pending("test-profile"), []string{"c1"})
+	assert.Equal(t, len(enforcer.containerInfos), 0)
+	assert.Assert(t, enforcer.NodePressure() != "")
+
+	// The status of the profile is only reported again when the pending containers change
+	err = enforcer.deferContainer(info, "test-profile")
+	assert.Assert(t, errors.Is(err, errNodePressure))
+	assert.DeepEqual(t, notified, []string{"test-profile"})
+
+	enforcer.removePendingOfProfile("test-profile")
+	assert.Equal(t, len(enforcer.pendings), 0)
+	assert.Equal(t, pendingContainers.Value(), int64(0))
+	assert.DeepEqual(t, notified, []string{"test-profile", "test-profile"})
+}
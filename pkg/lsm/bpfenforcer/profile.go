@@ -40,305 +40,305 @@ func (enforcer *BpfEnforcer) newEnforceID(pid uint32) (enforceID, error) {
 	return id, nil
 }
 
-func (enforcer *BpfEnforcer) applyCapabilityRule(nsID uint32, caps uint64) error {
-	if caps != 0 {
-		err := enforcer.objs.V_capable.Put(&nsID, &caps)
-		if err != nil {
-			return err
-		}
-	} else {
-		err := enforcer.objs.V_capable.Delete(&nsID)
-		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-			enforcer.log.Error(err, "V_capable.Delete()")
-		}
+// newPathInnerMap creates the inner map of the rules with path patterns, it returns nil if there is no rule
+func newPathInnerMap(mapName string, maxEntries int, files []varmor.FileContent) (*ebpf.Map, error) {
+	if len(files) == 0 {
+		return nil, nil
 	}
-	return nil
-}
 
-func (enforcer *BpfEnforcer) applyFileRules(nsID uint32, files []varmor.FileContent) error {
-	if len(files) != 0 {
-		mapName := fmt.Sprintf("v_file_inner_%d", nsID)
-		innerMapSpec := ebpf.MapSpec{
-			Name:       mapName,
-			Type:       ebpf.Hash,
-			KeySize:    4,
-			ValueSize:  4*2 + uint32(varmortypes.MaxFilePathPatternLength)*2,
-			MaxEntries: uint32(varmortypes.MaxBpfFileRuleCount),
-		}
-		innerMap, err := ebpf.NewMap(&innerMapSpec)
-		if err != nil {
-			return err
-		}
-		defer innerMap.Close()
-
-		for i, file := range files {
-			var prefix, suffix [varmortypes.MaxFilePathPatternLength]byte
-			copy(prefix[:], file.Pattern.Prefix)
-			copy(suffix[:], file.Pattern.Suffix)
-
-			var rule bpfPathRule
-			rule.Permissions = file.Permissions
-			rule.Pattern.Flags = file.Pattern.Flags
-			rule.Pattern.Prefix = prefix
-			rule.Pattern.Suffix = suffix
-			var index uint32 = uint32(i)
-			err = innerMap.Put(&index, &rule)
-			if err != nil {
-				return err
-			}
-		}
-		err = enforcer.objs.V_fileOuter.Put(&nsID, innerMap)
+	innerMapSpec := ebpf.MapSpec{
+		Name:       mapName,
+		Type:       ebpf.Hash,
+		KeySize:    4,
+		ValueSize:  4*2 + uint32(varmortypes.MaxFilePathPatternLength)*2,
+		MaxEntries: uint32(maxEntries),
+	}
+	innerMap, err := ebpf.NewMap(&innerMapSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, file := range files {
+		var prefix, suffix [varmortypes.MaxFilePathPatternLength]byte
+		copy(prefix[:], file.Pattern.Prefix)
+		copy(suffix[:], file.Pattern.Suffix)
+
+		var rule bpfPathRule
+		rule.Permissions = file.Permissions
+		rule.Pattern.Flags = file.Pattern.Flags
+		rule.Pattern.Prefix = prefix
+		rule.Pattern.Suffix = suffix
+		var index uint32 = uint32(i)
+		err = innerMap.Put(&index, &rule)
 		if err != nil {
-			return err
-		}
-	} else {
-		err := enforcer.objs.V_fileOuter.Delete(&nsID)
-		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-			enforcer.log.Error(err, "V_fileOuter.Delete()")
+			innerMap.Close()
+			return nil, err
 		}
 	}
 
-	return nil
+	return innerMap, nil
 }
 
-func (enforcer *BpfEnforcer) applyProcessRules(nsID uint32, processes []varmor.FileContent) error {
-	if len(processes) != 0 {
-		mapName := fmt.Sprintf("v_bprm_inner_%d", nsID)
-		innerMapSpec := ebpf.MapSpec{
-			Name:       mapName,
-			Type:       ebpf.Hash,
-			KeySize:    4,
-			ValueSize:  4*2 + uint32(varmortypes.MaxFilePathPatternLength)*2,
-			MaxEntries: uint32(varmortypes.MaxBpfBprmRuleCount),
-		}
-		innerMap, err := ebpf.NewMap(&innerMapSpec)
-		if err != nil {
-			return err
+// newFileInnerMap creates the inner map of the file rules, it returns nil if there is no rule
+func newFileInnerMap(nsID uint32, files []varmor.FileContent) (*ebpf.Map, error) {
+	return newPathInnerMap(fmt.Sprintf("v_file_inner_%d", nsID), varmortypes.MaxBpfFileRuleCount, files)
+}
+
+// newBprmInnerMap creates the inner map of the bprm rules, it returns nil if there is no rule
+func newBprmInnerMap(nsID uint32, processes []varmor.FileContent) (*ebpf.Map, error) {
+	return newPathInnerMap(fmt.Sprintf("v_bprm_inner_%d", nsID), varmortypes.MaxBpfBprmRuleCount, processes)
+}
+
+// newNetInnerMap creates the inner map of the network rules, it returns nil if there is no rule
+func newNetInnerMap(nsID uint32, networks []varmor.NetworkContent) (*ebpf.Map, error) {
+	if len(networks) == 0 {
+		return nil, nil
+	}
+
+	mapName := fmt.Sprintf("v_net_inner_%d", nsID)
+	innerMapSpec := ebpf.MapSpec{
+		Name:       mapName,
+		Type:       ebpf.Hash,
+		KeySize:    4,
+		ValueSize:  4*2 + 16*2,
+		MaxEntries: uint32(varmortypes.MaxBpfNetworkRuleCount),
+	}
+	innerMap, err := ebpf.NewMap(&innerMapSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, network := range networks {
+		var rule bpfNetworkRule
+
+		rule.Flags = network.Flags
+		rule.Port = network.Port
+		ip := net.ParseIP(network.Address)
+		if ip.To4() != nil {
+			copy(rule.Address[:], ip.To4())
+		} else {
+			copy(rule.Address[:], ip.To16())
 		}
-		defer innerMap.Close()
-
-		for i, file := range processes {
-			var prefix, suffix [varmortypes.MaxFilePathPatternLength]byte
-			copy(prefix[:], file.Pattern.Prefix)
-			copy(suffix[:], file.Pattern.Suffix)
-
-			var rule bpfPathRule
-			rule.Permissions = file.Permissions
-			rule.Pattern.Flags = file.Pattern.Flags
-			rule.Pattern.Prefix = prefix
-			rule.Pattern.Suffix = suffix
-			var index uint32 = uint32(i)
-			err = innerMap.Put(&index, &rule)
+
+		if network.CIDR != "" {
+			_, ipNet, err := net.ParseCIDR(network.CIDR)
 			if err != nil {
-				return err
+				innerMap.Close()
+				return nil, err
 			}
+			copy(rule.Mask[:], ipNet.Mask)
 		}
-		err = enforcer.objs.V_bprmOuter.Put(&nsID, innerMap)
+
+		var index uint32 = uint32(i)
+		err = innerMap.Put(&index, &rule)
 		if err != nil {
-			return err
-		}
-	} else {
-		err := enforcer.objs.V_bprmOuter.Delete(&nsID)
-		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-			enforcer.log.Error(err, "V_bprmOuter.Delete()")
+			innerMap.Close()
+			return nil, err
 		}
 	}
 
-	return nil
+	return innerMap, nil
 }
 
-func (enforcer *BpfEnforcer) applyNetworkRules(nsID uint32, networks []varmor.NetworkContent) error {
-	if len(networks) != 0 {
-		mapName := fmt.Sprintf("v_net_inner_%d", nsID)
-		innerMapSpec := ebpf.MapSpec{
-			Name:       mapName,
-			Type:       ebpf.Hash,
-			KeySize:    4,
-			ValueSize:  4*2 + 16*2,
-			MaxEntries: uint32(varmortypes.MaxBpfNetworkRuleCount),
-		}
-		innerMap, err := ebpf.NewMap(&innerMapSpec)
-		if err != nil {
-			return err
-		}
-		defer innerMap.Close()
-
-		for i, network := range networks {
-			var rule bpfNetworkRule
-
-			rule.Flags = network.Flags
-			rule.Port = network.Port
-			ip := net.ParseIP(network.Address)
-			if ip.To4() != nil {
-				copy(rule.Address[:], ip.To4())
-			} else {
-				copy(rule.Address[:], ip.To16())
-			}
+// newMountInnerMap creates the inner map of the mount rules, it returns nil if there is no rule
+func newMountInnerMap(nsID uint32, mounts []varmor.MountContent) (*ebpf.Map, error) {
+	if len(mounts) == 0 {
+		return nil, nil
+	}
 
-			if network.CIDR != "" {
-				_, ipNet, err := net.ParseCIDR(network.CIDR)
-				if err != nil {
-					return err
-				}
-				copy(rule.Mask[:], ipNet.Mask)
-			}
+	mapName := fmt.Sprintf("v_mount_inner_%d", nsID)
+	innerMapSpec := ebpf.MapSpec{
+		Name:       mapName,
+		Type:       ebpf.Hash,
+		KeySize:    4,
+		ValueSize:  4*3 + uint32(varmortypes.MaxFileSystemTypeLength) + uint32(varmortypes.MaxFilePathPatternLength)*2,
+		MaxEntries: uint32(varmortypes.MaxBpfMountRuleCount),
+	}
+	innerMap, err := ebpf.NewMap(&innerMapSpec)
+	if err != nil {
+		return nil, err
+	}
 
-			var index uint32 = uint32(i)
-			err = innerMap.Put(&index, &rule)
-			if err != nil {
-				return err
-			}
-		}
-		err = enforcer.objs.V_netOuter.Put(&nsID, innerMap)
+	for i, mount := range mounts {
+		var fstype [varmortypes.MaxFileSystemTypeLength]byte
+		var prefix, suffix [varmortypes.MaxFilePathPatternLength]byte
+		copy(fstype[:], mount.Fstype)
+		copy(prefix[:], mount.Pattern.Prefix)
+		copy(suffix[:], mount.Pattern.Suffix)
+
+		var rule bpfMountRule
+		rule.MountFlags = mount.MountFlags
+		rule.ReverseMountFlags = mount.ReverseMountflags
+		rule.Fstype = fstype
+		rule.Pattern.Flags = mount.Pattern.Flags
+		rule.Pattern.Prefix = prefix
+		rule.Pattern.Suffix = suffix
+		var index uint32 = uint32(i)
+		err = innerMap.Put(&index, &rule)
 		if err != nil {
-			return err
-		}
-	} else {
-		err := enforcer.objs.V_netOuter.Delete(&nsID)
-		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-			enforcer.log.Error(err, "V_netOuter.Delete()")
+			innerMap.Close()
+			return nil, err
 		}
 	}
 
-	return nil
+	return innerMap, nil
 }
 
-func (enforcer *BpfEnforcer) applyPtraceRule(nsID uint32, ptrace varmor.PtraceContent) error {
-	if ptrace.Permissions != 0 && ptrace.Flags != 0 {
-		rule := uint64(ptrace.Permissions)<<32 + uint64(ptrace.Flags)
-		err := enforcer.objs.V_ptrace.Put(&nsID, &rule)
+// stageProfile creates the inner maps and the values of the BPF profile without touching the maps that
+// are used by the BPF program. Nothing needs to be cleaned up from the kernel if it fails.
+func (enforcer *BpfEnforcer) stageProfile(nsID uint32, bpfContent varmor.BpfContent) (changes []*mapChange, err error) {
+	defer func() {
 		if err != nil {
-			return err
-		}
-	} else {
-		err := enforcer.objs.V_ptrace.Delete(&nsID)
-		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-			enforcer.log.Error(err, "V_ptrace.Delete()")
+			closeMapChanges(changes)
+			changes = nil
 		}
+	}()
+
+	// capability rule
+	change := mapChange{name: "V_capable", m: enforcer.objs.V_capable}
+	if bpfContent.Capabilities != 0 {
+		caps := bpfContent.Capabilities
+		change.value = &caps
 	}
-	return nil
-}
+	changes = append(changes, &change)
 
-func (enforcer *BpfEnforcer) applyMountRules(nsID uint32, mounts []varmor.MountContent) error {
-	if len(mounts) != 0 {
-		mapName := fmt.Sprintf("v_mount_inner_%d", nsID)
-		innerMapSpec := ebpf.MapSpec{
-			Name:       mapName,
-			Type:       ebpf.Hash,
-			KeySize:    4,
-			ValueSize:  4*3 + uint32(varmortypes.MaxFileSystemTypeLength) + uint32(varmortypes.MaxFilePathPatternLength)*2,
-			MaxEntries: uint32(varmortypes.MaxBpfMountRuleCount),
-		}
-		innerMap, err := ebpf.NewMap(&innerMapSpec)
-		if err != nil {
-			return err
-		}
-		defer innerMap.Close()
-
-		for i, mount := range mounts {
-			var fstype [varmortypes.MaxFileSystemTypeLength]byte
-			var prefix, suffix [varmortypes.MaxFilePathPatternLength]byte
-			copy(fstype[:], mount.Fstype)
-			copy(prefix[:], mount.Pattern.Prefix)
-			copy(suffix[:], mount.Pattern.Suffix)
-
-			var rule bpfMountRule
-			rule.MountFlags = mount.MountFlags
-			rule.ReverseMountFlags = mount.ReverseMountflags
-			rule.Fstype = fstype
-			rule.Pattern.Flags = mount.Pattern.Flags
-			rule.Pattern.Prefix = prefix
-			rule.Pattern.Suffix = suffix
-			var index uint32 = uint32(i)
-			err = innerMap.Put(&index, &rule)
-			if err != nil {
-				return err
-			}
-		}
-		err = enforcer.objs.V_mountOuter.Put(&nsID, innerMap)
-		if err != nil {
-			return err
-		}
-	} else {
-		err := enforcer.objs.V_mountOuter.Delete(&nsID)
-		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-			enforcer.log.Error(err, "V_mountOuter.Delete()")
+	// ptrace rule, it is kept unchanged if the profile doesn't contain it
+	if bpfContent.Ptrace != nil {
+		change := mapChange{name: "V_ptrace", m: enforcer.objs.V_ptrace}
+		if bpfContent.Ptrace.Permissions != 0 && bpfContent.Ptrace.Flags != 0 {
+			rule := uint64(bpfContent.Ptrace.Permissions)<<32 + uint64(bpfContent.Ptrace.Flags)
+			change.value = &rule
 		}
+		changes = append(changes, &change)
 	}
 
-	return nil
-}
+	// file rules
+	innerMap, err := newFileInnerMap(nsID, bpfContent.Files)
+	if err != nil {
+		return changes, err
+	}
+	changes = append(changes, newOuterMapChange("V_fileOuter", enforcer.objs.V_fileOuter, innerMap, len(bpfContent.Files)))
 
-func (enforcer *BpfEnforcer) applyProfile(nsID uint32, bpfContent varmor.BpfContent) (err error) {
-	err = enforcer.applyCapabilityRule(nsID, bpfContent.Capabilities)
+	// process rules
+	innerMap, err = newBprmInnerMap(nsID, bpfContent.Processes)
 	if err != nil {
-		return err
+		return changes, err
 	}
+	changes = append(changes, newOuterMapChange("V_bprmOuter", enforcer.objs.V_bprmOuter, innerMap, len(bpfContent.Processes)))
 
-	err = enforcer.applyFileRules(nsID, bpfContent.Files)
+	// network rules
+	innerMap, err = newNetInnerMap(nsID, bpfContent.Networks)
 	if err != nil {
-		return err
+		return changes, err
 	}
+	changes = append(changes, newOuterMapChange("V_netOuter", enforcer.objs.V_netOuter, innerMap, len(bpfContent.Networks)))
 
-	err = enforcer.applyProcessRules(nsID, bpfContent.Processes)
+	// mount rules
+	innerMap, err = newMountInnerMap(nsID, bpfContent.Mounts)
 	if err != nil {
-		return err
+		return changes, err
 	}
+	changes = append(changes, newOuterMapChange("V_mountOuter", enforcer.objs.V_mountOuter, innerMap, len(bpfContent.Mounts)))
+
+	return changes, nil
+}
 
-	err = enforcer.applyNetworkRules(nsID, bpfContent.Networks)
+// applyProfile applies the BPF profile for the mnt ns transactionally. All inner maps are staged and verified first,
+// then they are committed to the outer maps. If any of the commits fails, the rules of the mnt ns are restored to
+// the previous state, so the container won't be left half-enforced.
+func (enforcer *BpfEnforcer) applyProfile(nsID uint32, bpfContent varmor.BpfContent) error {
+	changes, err := enforcer.stageProfile(nsID, bpfContent)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to stage the BPF profile: %w", err)
 	}
+	defer closeMapChanges(changes)
 
-	if bpfContent.Ptrace != nil {
-		err = enforcer.applyPtraceRule(nsID, *bpfContent.Ptrace)
+	return enforcer.commitChanges(nsID, &bpfContent, changes, false)
+}
+
+// commitChanges verifies the staged changes of the mnt ns and commits them. If partial is true, the changes only
+// cover some of the maps, and the inner maps of the others are kept.
+func (enforcer *BpfEnforcer) commitChanges(nsID uint32, bpfContent *varmor.BpfContent, changes []*mapChange, partial bool) error {
+	for _, change := range changes {
+		err := change.verify()
+		if err != nil {
+			return fmt.Errorf("failed to verify the staged rules of %s: %w", change.name, err)
+		}
+
+		// The frozen inner maps can't be modified by the syscalls, the other modifications are detected by checkTampers
+		err = change.freeze()
 		if err != nil {
-			return err
+			enforcer.log.V(3).Info("failed to freeze the staged inner map", "map", change.name, "error", err.Error())
 		}
 	}
 
-	err = enforcer.applyMountRules(nsID, bpfContent.Mounts)
+	for _, change := range changes {
+		err := change.snapshot(nsID)
+		if err != nil {
+			return fmt.Errorf("failed to snapshot the rules of %s: %w", change.name, err)
+		}
+	}
+
+	usage := stagedMapMemory(changes)
+	if partial {
+		usage = enforcer.mapMemory.replace(nsID, previousMapMemory(changes), usage)
+	}
+	err := enforcer.mapMemory.check(nsID, usage)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	// Record the entries of the mnt ns whether the changes were committed or rolled back
+	defer enforcer.recordFingerprint(nsID)
 
-func (enforcer *BpfEnforcer) deleteProfile(nsID uint32) {
-	// capability rule
-	err := enforcer.objs.V_capable.Delete(&nsID)
-	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-		enforcer.log.Error(err, "V_capable.Delete()")
-	}
+	for i, change := range changes {
+		err = change.commit(nsID)
+		if err == nil {
+			enforcer.mapOps.logChange(change, nsID, false)
+			continue
+		}
 
-	// file rules
-	err = enforcer.objs.V_fileOuter.Delete(&nsID)
-	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-		enforcer.log.Error(err, "V_fileOuter.Delete()")
-	}
+		err = fmt.Errorf("failed to commit the rules of %s: %w", change.name, err)
 
-	// process rules
-	err = enforcer.objs.V_bprmOuter.Delete(&nsID)
-	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-		enforcer.log.Error(err, "V_bprmOuter.Delete()")
-	}
+		// Restore the previous state of the committed changes
+		for j := i; j >= 0; j-- {
+			rollbackErr := changes[j].rollback(nsID)
+			if rollbackErr != nil {
+				enforcer.log.Error(rollbackErr, "failed to roll back the rules", "map", changes[j].name, "mnt ns id", nsID)
+				return fmt.Errorf("%w, and the rollback failed: %v", err, rollbackErr)
+			}
+			enforcer.mapOps.logChange(changes[j], nsID, true)
+		}
 
-	// network rules
-	err = enforcer.objs.V_netOuter.Delete(&nsID)
-	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-		enforcer.log.Error(err, "V_netOuter.Delete()")
+		return fmt.Errorf("%w, the previous rules were restored", err)
 	}
 
-	// ptrace rule
-	err = enforcer.objs.V_ptrace.Delete(&nsID)
-	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-		enforcer.log.Error(err, "V_ptrace.Delete()")
-	}
+	enforcer.ruleIDs.save(nsID, bpfContent)
+	enforcer.mapMemory.save(nsID, usage)
 
-	// mount rules
-	err = enforcer.objs.V_mountOuter.Delete(&nsID)
-	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-		enforcer.log.Error(err, "V_mountOuter.Delete()")
+	return nil
+}
+
+// deleteProfile deletes the entries of the mnt ns from the maps. It tries all maps and returns the joined errors
+// of the ones that failed, the entries that don't exist are ignored.
+func (enforcer *BpfEnforcer) deleteProfile(nsID uint32) error {
+	var errs []error
+	enforcer.ruleIDs.delete(nsID)
+	enforcer.mapMemory.delete(nsID)
+	enforcer.fingerprints.delete(nsID)
+
+	// The maps checked for tampering are all the maps keyed by the mnt ns
+	for _, m := range enforcer.enforcementMaps() {
+		err := m.m.Delete(&nsID)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.Delete() failed: %w", m.name, err))
+			continue
+		}
+		enforcer.mapOps.logOp(mapOpDelete, m.name, nsID, 0)
 	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,454 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+	"github.com/bytedance/vArmor/pkg/utils"
+)
+
+const (
+	preciseMatch = 0x00000001
+	prefixMatch  = 0x00000004
+	aaMayExec    = 0x00000001
+
+	// maxRegexWalkDepth is the max depth of the directories to walk from the literal prefix of the regular expression
+	maxRegexWalkDepth = 8
+	// maxRegexWalkEntries is the max count of the entries to walk for a regular expression
+	maxRegexWalkEntries = 10000
+
+	// The writes are watched for the executables of the rules with SHA256
+	regexWatchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB
+)
+
+// walkInRoot walks the directory tree from the dir inside the root in lexical order, and calls the fn for each
+// entry including the dir. The directories are resolved inside the root, so the symlinks of the container can't
+// lead the walk onto the host paths. The symlinks are never followed, the same as filepath.WalkDir. The entries
+// removed during the walk are skipped, and the fn can return filepath.SkipDir or filepath.SkipAll like the
+// filepath.WalkDirFunc.
+func walkInRoot(root *utils.Root, dir string, fn func(path string, isDir bool) error) error {
+	entries, err := readDirInRoot(root, dir)
+	if err != nil {
+		return err
+	}
+
+	err = fn(dir, true)
+	if err == nil {
+		err = walkEntriesInRoot(root, dir, entries, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// readDirInRoot reads the entries of the dir inside the root in lexical order
+func readDirInRoot(root *utils.Root, dir string) ([]fs.DirEntry, error) {
+	file, err := root.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries, err := file.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func walkEntriesInRoot(root *utils.Root, dir string, entries []fs.DirEntry, fn func(path string, isDir bool) error) error {
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		var subEntries []fs.DirEntry
+		if entry.IsDir() {
+			var err error
+			subEntries, err = readDirInRoot(root, path)
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, unix.ENOTDIR) {
+				// The directory was removed or replaced during the walk
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		err := fn(path, entry.IsDir())
+		if err == filepath.SkipDir {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			err = walkEntriesInRoot(root, path, subEntries, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandRegexFileRules walks the filesystem of the container from the literal directory prefix of the regular
+// expressions, and creates the precise rules for the matched paths. The walked directories are returned for watching,
+// and the matched paths that exceed the maximum length of the rules are returned for reporting.
+func expandRegexFileRules(pid uint32, regexFiles []varmor.RegexFileContent) ([]varmor.FileContent, []varmor.FileContent, []string, []string, error) {
+	return expandRegexFileRulesInRoot(fmt.Sprintf("/proc/%d/root", pid), regexFiles)
+}
+
+func expandRegexFileRulesInRoot(rootPath string, regexFiles []varmor.RegexFileContent) ([]varmor.FileContent, []varmor.FileContent, []string, []string, error) {
+	var files, processes []varmor.FileContent
+	var dirs, overlong []string
+	walkedDirs := make(map[string]struct{})
+
+	root, err := utils.OpenRoot(rootPath)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	defer root.Close()
+
+	for _, regexFile := range regexFiles {
+		re, err := regexp.Compile("^(?:" + regexFile.Regex + ")$")
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+
+		prefix, _ := regexp.MustCompile(regexFile.Regex).LiteralPrefix()
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, nil, nil, nil, fmt.Errorf("the regular expression '%s' must start with an absolute directory", regexFile.Regex)
+		}
+		startDir := prefix[:strings.LastIndex(prefix, "/")+1]
+		startDepth := strings.Count(startDir, "/")
+
+		entries := 0
+		err = walkInRoot(root, filepath.Clean(startDir), func(path string, isDir bool) error {
+			entries++
+			if entries > maxRegexWalkEntries {
+				return filepath.SkipAll
+			}
+
+			if isDir {
+				if strings.Count(path, "/")-startDepth >= maxRegexWalkDepth {
+					return filepath.SkipDir
+				}
+				if _, ok := walkedDirs[path]; !ok {
+					walkedDirs[path] = struct{}{}
+					dirs = append(dirs, path)
+				}
+			}
+
+			if !re.MatchString(path) {
+				return nil
+			}
+
+			if len(path) >= varmortypes.MaxFilePathPatternLength {
+				overlong = append(overlong, path)
+				return nil
+			}
+
+			content := varmor.FileContent{
+				Permissions: regexFile.Permissions &^ aaMayExec,
+				Pattern: varmor.PathPattern{
+					Flags:  preciseMatch | prefixMatch,
+					Prefix: path,
+				},
+				RuleID: regexFile.RuleID,
+			}
+
+			if content.Permissions != 0 {
+				files = append(files, content)
+			}
+
+			if regexFile.Permissions&aaMayExec != 0 {
+				content.Permissions = aaMayExec
+				processes = append(processes, content)
+			}
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, unix.ENOTDIR) {
+			// Nothing matches the regular expression until the directory is created
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("failed to walk the directory '%s' for the regular expression '%s': %w", startDir, regexFile.Regex, err)
+		}
+	}
+
+	return files, processes, dirs, overlong, nil
+}
+
+// regexWatcher watches the directories which the regular expressions of file rules were expanded against,
+// and notifies the enforcer to refresh the rules of the container when the entries of them changed.
+type regexWatcher struct {
+	inotifyFd   int
+	inotifyFile *os.File
+	lock        sync.Mutex
+	watches     map[int]map[string]struct{} // <wd: containerIDs>
+	containers  map[string][]int            // <containerID: wds>
+	pending     map[string]struct{}         // the containers waiting to be refreshed
+	refreshCh   chan string
+	log         logr.Logger
+}
+
+func newRegexWatcher(log logr.Logger) (*regexWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("unix.InotifyInit1() failed: %v", err)
+	}
+
+	w := regexWatcher{
+		// Keep the raw fd for adding watches, calling File.Fd() would put the file into blocking mode.
+		inotifyFd:   fd,
+		inotifyFile: os.NewFile(uintptr(fd), "inotify"),
+		watches:     make(map[int]map[string]struct{}),
+		containers:  make(map[string][]int),
+		pending:     make(map[string]struct{}),
+		refreshCh:   make(chan string, varmortypes.MaxTargetContainerCountForBpfLsm),
+		log:         log,
+	}
+	return &w, nil
+}
+
+// watch replaces the watched directories of the container
+func (w *regexWatcher) watch(containerID string, pid uint32, dirs []string) {
+	w.watchInRoot(containerID, fmt.Sprintf("/proc/%d/root", pid), dirs)
+}
+
+// watchInRoot replaces the watched directories of the container. The directories are resolved inside the root, so
+// the symlinks of the container can't redirect the watches onto the host paths.
+func (w *regexWatcher) watchInRoot(containerID string, rootPath string, dirs []string) {
+	w.unwatch(containerID)
+
+	root, err := utils.OpenRoot(rootPath)
+	if err != nil {
+		w.log.V(3).Info("utils.OpenRoot() failed", "container id", containerID, "error", err)
+		return
+	}
+	defer root.Close()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, dir := range dirs {
+		wd, err := w.addWatch(root, dir)
+		if err != nil {
+			w.log.V(3).Info("failed to watch the directory", "container id", containerID, "dir", dir, "error", err)
+			continue
+		}
+
+		if _, ok := w.watches[wd]; !ok {
+			w.watches[wd] = make(map[string]struct{})
+		}
+		w.watches[wd][containerID] = struct{}{}
+		w.containers[containerID] = append(w.containers[containerID], wd)
+	}
+}
+
+// addWatch watches the directory inside the root through the file descriptor of the resolved directory
+func (w *regexWatcher) addWatch(root *utils.Root, dir string) (int, error) {
+	file, err := root.Open(dir, unix.O_PATH|unix.O_DIRECTORY)
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+
+	wd, err := unix.InotifyAddWatch(w.inotifyFd, utils.ProcPath(file), regexWatchMask)
+	if err != nil {
+		return -1, fmt.Errorf("unix.InotifyAddWatch() failed: %w", err)
+	}
+	return wd, nil
+}
+
+// unwatch removes the watched directories of the container
+func (w *regexWatcher) unwatch(containerID string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, wd := range w.containers[containerID] {
+		delete(w.watches[wd], containerID)
+		if len(w.watches[wd]) == 0 {
+			unix.InotifyRmWatch(w.inotifyFd, uint32(wd))
+			delete(w.watches, wd)
+		}
+	}
+	delete(w.containers, containerID)
+	delete(w.pending, containerID)
+}
+
+// done marks the container as refreshed
+func (w *regexWatcher) done(containerID string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.pending, containerID)
+}
+
+// handleInotifyEvent notifies the enforcer to refresh the containers whose directories changed. The containers are
+// sent after the lock is released, since the enforcer takes the lock to mark them as refreshed.
+func (w *regexWatcher) handleInotifyEvent(event *unix.InotifyEvent) {
+	for _, containerID := range w.changedContainers(event) {
+		w.refreshCh <- containerID
+	}
+}
+
+// changedContainers returns the containers that need to be refreshed for the event, and marks them as pending. The
+// pending containers are skipped until they're refreshed.
+func (w *regexWatcher) changedContainers(event *unix.InotifyEvent) []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if event.Mask&unix.IN_IGNORED != 0 {
+		for containerID := range w.watches[int(event.Wd)] {
+			wds := w.containers[containerID]
+			for i, wd := range wds {
+				if wd == int(event.Wd) {
+					w.containers[containerID] = append(wds[:i], wds[i+1:]...)
+					break
+				}
+			}
+		}
+		delete(w.watches, int(event.Wd))
+		return nil
+	}
+
+	var containerIDs []string
+	for containerID := range w.watches[int(event.Wd)] {
+		if _, ok := w.pending[containerID]; ok {
+			continue
+		}
+		w.pending[containerID] = struct{}{}
+		containerIDs = append(containerIDs, containerID)
+	}
+	return containerIDs
+}
+
+// run reads the inotify events until the stopCh is closed
+func (w *regexWatcher) run(stopCh <-chan struct{}) {
+	go func() {
+		<-stopCh
+		w.inotifyFile.Close()
+	}()
+
+	var buf [unix.SizeofInotifyEvent * 4096]byte
+	for {
+		n, err := w.inotifyFile.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.log.Error(err, "failed to read the inotify events")
+			}
+			return
+		}
+
+		offset := 0
+		for offset+unix.SizeofInotifyEvent <= n {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			w.handleInotifyEvent(event)
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+		}
+	}
+}
+
+// expandProfile removes the rules excepted by the annotation of the pod, expands the regular expressions of the
+// file rules and the rules with SHA256 against the filesystem of the container, and watches the walked directories
+// to refresh the rules when their entries change.
+func (enforcer *BpfEnforcer) expandProfile(containerID string, id enforceID, bpfContent varmor.BpfContent) varmor.BpfContent {
+	enforcer.cacheLock.Lock()
+	annotations := enforcer.containerInfos[containerID].PodAnnotations
+	enforcer.cacheLock.Unlock()
+	// The rules of the base profile can't be excepted
+	bpfContent = exceptRules(enforcer.layerProfile(containerID, bpfContent), annotations)
+
+	if len(bpfContent.RegexFiles) == 0 && len(bpfContent.HashProcesses) == 0 {
+		enforcer.regexWatcher.unwatch(containerID)
+		return bpfContent
+	}
+
+	files, processes, dirs, overlong, err := expandRegexFileRules(id.pid, bpfContent.RegexFiles)
+	if err != nil {
+		enforcer.log.Error(err, "expandRegexFileRules() failed", "container id", containerID)
+		return bpfContent
+	}
+	if len(overlong) != 0 {
+		enforcer.log.Info("WARNING: the paths matched by the regular expressions exceed the maximum length of the rules, they are ignored",
+			"container id", containerID, "paths", overlong, "max length", varmortypes.MaxFilePathPatternLength-1)
+	}
+
+	// The rules with SHA256 take precedence over the rules expanded from the regular expressions
+	hashProcesses, hashDirs, err := expandHashProcessRules(id.pid, bpfContent.HashProcesses, enforcer.hashes)
+	if err != nil {
+		enforcer.log.Error(err, "expandHashProcessRules() failed", "container id", containerID)
+		return bpfContent
+	}
+	processes = append(hashProcesses, processes...)
+	dirs = append(dirs, hashDirs...)
+
+	if count := varmortypes.MaxBpfFileRuleCount - len(bpfContent.Files); len(files) > count {
+		enforcer.log.Info("the expanded file rules exceed the maximum, the redundant ones are ignored",
+			"container id", containerID, "expanded", len(files), "max count", varmortypes.MaxBpfFileRuleCount)
+		if count < 0 {
+			count = 0
+		}
+		files = files[:count]
+	}
+
+	if count := varmortypes.MaxBpfBprmRuleCount - len(bpfContent.Processes); len(processes) > count {
+		enforcer.log.Info("the expanded bprm rules exceed the maximum, the redundant ones are ignored",
+			"container id", containerID, "expanded", len(processes), "max count", varmortypes.MaxBpfBprmRuleCount)
+		if count < 0 {
+			count = 0
+		}
+		processes = processes[:count]
+	}
+
+	// Don't modify the cached profile
+	bpfContent.Files = append(append([]varmor.FileContent{}, bpfContent.Files...), files...)
+	bpfContent.Processes = append(append([]varmor.FileContent{}, bpfContent.Processes...), processes...)
+
+	enforcer.regexWatcher.watch(containerID, id.pid, dirs)
+
+	return bpfContent
+}
+
+// refreshProfile re-expands the regular expressions of the file rules and the rules with SHA256, and applies the
+// profile for the container
+func (enforcer *BpfEnforcer) refreshProfile(containerID string) error {
+	enforcer.regexWatcher.done(containerID)
+
+	for _, profile := range enforcer.bpfProfileCache {
+		if id, ok := profile.containerCache[containerID]; ok {
+			return enforcer.applyProfile(id.mntNsID, enforcer.expandProfile(containerID, id, profile.bpfContent))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func newRegexTestRoot(t *testing.T) string {
+	root := t.TempDir()
+	for _, dir := range []string{"etc/app/conf.d", "usr/bin"} {
+		assert.NilError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	for _, file := range []string{"etc/app/a.conf", "etc/app/b.txt", "etc/app/conf.d/c.conf", "usr/bin/app-1", "usr/bin/tool"} {
+		assert.NilError(t, os.WriteFile(filepath.Join(root, file), []byte("varmor"), 0755))
+	}
+	return root
+}
+
+func Test_expandRegexFileRulesInRoot(t *testing.T) {
+	testCases := []struct {
+		name              string
+		regexFiles        []varmor.RegexFileContent
+		expectedFiles     []string
+		expectedProcesses []string
+		expectedDirs      []string
+		expectedErr       string
+	}{
+		{
+			name:          "files",
+			regexFiles:    []varmor.RegexFileContent{{Permissions: 0x2, Regex: `/etc/app/.*\.conf`}},
+			expectedFiles: []string{"/etc/app/a.conf", "/etc/app/conf.d/c.conf"},
+			expectedDirs:  []string{"/etc/app", "/etc/app/conf.d"},
+		},
+		{
+			name:              "processes",
+			regexFiles:        []varmor.RegexFileContent{{Permissions: aaMayExec, Regex: `/usr/bin/app-[0-9]+`}},
+			expectedProcesses: []string{"/usr/bin/app-1"},
+			expectedDirs:      []string{"/usr/bin"},
+		},
+		{
+			name:       "missing directory",
+			regexFiles: []varmor.RegexFileContent{{Permissions: 0x2, Regex: `/opt/app/.*`}},
+		},
+		{
+			name:        "relative regular expression",
+			regexFiles:  []varmor.RegexFileContent{{Permissions: 0x2, Regex: `.*\.conf`}},
+			expectedErr: "must start with an absolute directory",
+		},
+	}
+
+	root := newRegexTestRoot(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files, processes, dirs, overlong, err := expandRegexFileRulesInRoot(root, tc.regexFiles)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, len(overlong), 0)
+
+			var filePaths, processPaths []string
+			for _, file := range files {
+				assert.Equal(t, file.Permissions, uint32(0x2))
+				filePaths = append(filePaths, file.Pattern.Prefix)
+			}
+			for _, process := range processes {
+				assert.Equal(t, process.Permissions, uint32(aaMayExec))
+				processPaths = append(processPaths, process.Pattern.Prefix)
+			}
+			assert.DeepEqual(t, filePaths, tc.expectedFiles)
+			assert.DeepEqual(t, processPaths, tc.expectedProcesses)
+			assert.DeepEqual(t, dirs, tc.expectedDirs)
+		})
+	}
+}
+
+func Test_expandRegexFileRulesInRoot_symlink(t *testing.T) {
+	root := newRegexTestRoot(t)
+	host := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(host, "host.conf"), []byte("host"), 0644))
+
+	// The absolute symlink of the container must be resolved inside the root instead of the host
+	assert.NilError(t, os.Symlink(host, filepath.Join(root, "data")))
+	files, _, dirs, _, err := expandRegexFileRulesInRoot(root, []varmor.RegexFileContent{
+		{Permissions: 0x2, Regex: "/data/.*"},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 0)
+	assert.Equal(t, len(dirs), 0)
+
+	// The symlinks inside the walked directories are matched but never followed
+	assert.NilError(t, os.Symlink(host, filepath.Join(root, "etc/app/link")))
+	files, _, dirs, _, err = expandRegexFileRulesInRoot(root, []varmor.RegexFileContent{
+		{Permissions: 0x2, Regex: "/etc/app/link.*"},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 1)
+	assert.Equal(t, files[0].Pattern.Prefix, "/etc/app/link")
+	assert.DeepEqual(t, dirs, []string{"/etc/app", "/etc/app/conf.d"})
+}
+
+func Test_expandRegexFileRulesInRoot_overlong(t *testing.T) {
+	root := newRegexTestRoot(t)
+	name := strings.Repeat("a", varmortypes.MaxFilePathPatternLength)
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "etc/app", name), []byte("varmor"), 0644))
+
+	files, _, _, overlong, err := expandRegexFileRulesInRoot(root, []varmor.RegexFileContent{
+		{Permissions: 0x2, Regex: "/etc/app/a.*"},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 1)
+	assert.Equal(t, files[0].Pattern.Prefix, "/etc/app/a.conf")
+	assert.DeepEqual(t, overlong, []string{"/etc/app/" + name})
+}
+
+func Test_regexWatcher(t *testing.T) {
+	w, err := newRegexWatcher(logr.Discard())
+	assert.NilError(t, err)
+	defer w.inotifyFile.Close()
+
+	root := newRegexTestRoot(t)
+	w.watchInRoot("container-1", root, []string{"/etc/app", "/missing"})
+	w.watchInRoot("container-2", root, []string{"/etc/app"})
+	assert.Equal(t, len(w.containers["container-1"]), 1)
+	assert.Equal(t, len(w.containers["container-2"]), 1)
+	wd := w.containers["container-1"][0]
+
+	// The refreshes are sent outside the lock, so the consumer can mark the containers done while they're sent
+	w.refreshCh = make(chan string)
+	sent := make(chan struct{})
+	go func() {
+		w.handleInotifyEvent(&unix.InotifyEvent{Wd: int32(wd), Mask: unix.IN_CREATE})
+		close(sent)
+	}()
+
+	var refreshed []string
+	for i := 0; i < 2; i++ {
+		select {
+		case containerID := <-w.refreshCh:
+			w.done(containerID)
+			refreshed = append(refreshed, containerID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("handleInotifyEvent() blocked")
+		}
+	}
+	<-sent
+	assert.Equal(t, len(refreshed), 2)
+
+	// The pending containers are refreshed only once
+	w.lock.Lock()
+	w.pending["container-1"] = struct{}{}
+	w.lock.Unlock()
+	assert.DeepEqual(t, w.changedContainers(&unix.InotifyEvent{Wd: int32(wd), Mask: unix.IN_CREATE}), []string{"container-2"})
+
+	w.unwatch("container-1")
+	w.unwatch("container-2")
+	assert.Equal(t, len(w.watches), 0)
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// applyBackoff is the retry policy for applying the BPF profiles. The map operations may fail
+// transiently when the kernel is under memory pressure. The retries are scheduled with timers,
+// so the event handler and the workers aren't blocked while waiting.
+var applyBackoff = wait.Backoff{
+	Duration: 100 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+// metrics of the BPF enforcer, they are published with expvar
+var (
+	metrics         = expvar.NewMap("varmor_bpf_enforcer")
+	applyRetries    = new(expvar.Int)
+	applyFailures   = new(expvar.Int)
+	deadLetterCount = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("apply_retries_total", applyRetries)
+	metrics.Set("apply_failures_total", applyFailures)
+	metrics.Set("dead_letter_containers", deadLetterCount)
+}
+
+// deadLetter records a container whose BPF profile failed to apply
+type deadLetter struct {
+	profileName string
+	id          enforceID
+	err         string
+	// attempts is the count of the failed applies
+	attempts int
+	// retry fires the next apply, it's nil if the error isn't transient or the retries were exhausted
+	retry *time.Timer
+}
+
+// isTransientError checks whether the error of map operations is worth retrying
+func isTransientError(err error) bool {
+	return errors.Is(err, unix.ENOMEM) ||
+		errors.Is(err, unix.E2BIG) ||
+		errors.Is(err, unix.EAGAIN) ||
+		errors.Is(err, unix.EBUSY) ||
+		errors.Is(err, unix.EINTR)
+}
+
+// tryApplyProfile applies the BPF profile once. The containers that it failed to apply to are recorded as dead
+// letters by the callers, and the transient failures are retried later with backoff.
+func (enforcer *BpfEnforcer) tryApplyProfile(nsID uint32, bpfContent varmor.BpfContent) error {
+	err := enforcer.applyProfile(nsID, bpfContent)
+	if err != nil {
+		if errors.Is(err, unix.ENOMEM) {
+			enforcer.pressure.recordMapFailure(time.Now())
+		}
+		applyFailures.Add(1)
+	}
+	return err
+}
+
+// retryDelay returns the delay before the next apply of the container that failed to apply for the attempts
+func retryDelay(attempts int) time.Duration {
+	backoff := applyBackoff
+	var delay time.Duration
+	for i := 0; i < attempts; i++ {
+		delay = backoff.Step()
+	}
+	return delay
+}
+
+// addDeadLetter records the container whose BPF profile failed to apply, and notifies the agent to report it.
+// The apply is retried with backoff if it failed transiently.
+func (enforcer *BpfEnforcer) addDeadLetter(containerID string, profileName string, id enforceID, err error) {
+	enforcer.deadLettersLock.Lock()
+	defer enforcer.deadLettersLock.Unlock()
+
+	previous, exist := enforcer.deadLetters[containerID]
+	if exist && previous.retry != nil {
+		previous.retry.Stop()
+	}
+
+	letter := deadLetter{
+		profileName: profileName,
+		id:          id,
+		err:         err.Error(),
+		attempts:    1,
+	}
+	if exist && previous.profileName == profileName && previous.id == id {
+		letter.attempts = previous.attempts + 1
+	}
+	if isTransientError(err) && letter.attempts < applyBackoff.Steps {
+		delay := retryDelay(letter.attempts)
+		enforcer.log.V(3).Info("failed to apply the BPF profile, retry later", "container id", containerID, "delay", delay, "error", err)
+		letter.retry = time.AfterFunc(delay, func() { enforcer.requestRetry(containerID) })
+	}
+	enforcer.deadLetters[containerID] = letter
+	deadLetterCount.Set(int64(len(enforcer.deadLetters)))
+
+	if !exist {
+		enforcer.notifyDeadLetter(profileName)
+	}
+}
+
+// requestRetry notifies the event handler to apply the BPF profile to the container again
+func (enforcer *BpfEnforcer) requestRetry(containerID string) {
+	select {
+	case enforcer.retryCh <- containerID:
+	default:
+		enforcer.log.Info("the retry channel is full, the container will be retried when its profile is updated or the events are resynced",
+			"container id", containerID)
+	}
+}
+
+// removeDeadLetter removes the record of the container if it exists
+func (enforcer *BpfEnforcer) removeDeadLetter(containerID string) {
+	enforcer.deadLettersLock.Lock()
+	defer enforcer.deadLettersLock.Unlock()
+
+	if letter, ok := enforcer.deadLetters[containerID]; ok {
+		if letter.retry != nil {
+			letter.retry.Stop()
+		}
+		delete(enforcer.deadLetters, containerID)
+		deadLetterCount.Set(int64(len(enforcer.deadLetters)))
+		enforcer.notifyDeadLetter(letter.profileName)
+	}
+}
+
+func (enforcer *BpfEnforcer) notifyDeadLetter(profileName string) {
+	if enforcer.opts.DeadLetterSink != nil {
+		enforcer.opts.DeadLetterSink(profileName)
+		return
+	}
+
+	select {
+	case enforcer.DeadLetterCh <- profileName:
+	default:
+		enforcer.log.Info("the dead letter channel is full, drop the notification", "profile name", profileName)
+	}
+}
+
+// deadLettersOfProfile returns a copy of the dead letters of the profile
+func (enforcer *BpfEnforcer) deadLettersOfProfile(profileName string) map[string]deadLetter {
+	enforcer.deadLettersLock.Lock()
+	defer enforcer.deadLettersLock.Unlock()
+
+	letters := make(map[string]deadLetter)
+	for containerID, letter := range enforcer.deadLetters {
+		if letter.profileName == profileName {
+			letters[containerID] = letter
+		}
+	}
+	return letters
+}
+
+// DeadLetters returns the description of the containers that the BPF profile failed to apply to
+func (enforcer *BpfEnforcer) DeadLetters(profileName string) []string {
+	var failed []string
+	for containerID, letter := range enforcer.deadLettersOfProfile(profileName) {
+		failed = append(failed, fmt.Sprintf("%s (%s)", containerID, letter.err))
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// retryDeadLetters applies the BPF profile again for the new containers that it failed to apply to. The containers
+// which are already protected keep the previous rules, and they will be retried when the profile is updated.
+func (enforcer *BpfEnforcer) retryDeadLetters(profileName string) {
+	for containerID, letter := range enforcer.deadLettersOfProfile(profileName) {
+		enforcer.retryDeadLetter(containerID, letter)
+	}
+}
+
+// retryContainer applies the BPF profile again for the container when the retry timer of its dead letter fires
+func (enforcer *BpfEnforcer) retryContainer(containerID string) {
+	enforcer.deadLettersLock.Lock()
+	letter, ok := enforcer.deadLetters[containerID]
+	enforcer.deadLettersLock.Unlock()
+
+	if ok {
+		enforcer.retryDeadLetter(containerID, letter)
+	}
+}
+
+func (enforcer *BpfEnforcer) retryDeadLetter(containerID string, letter deadLetter) {
+	profile, ok := enforcer.bpfProfileCache[letter.profileName]
+	if !ok {
+		return
+	}
+	if _, ok := profile.containerCache[containerID]; ok {
+		return
+	}
+
+	id, err := enforcer.newEnforceID(letter.id.pid)
+	if err != nil || id != letter.id {
+		// the container had already exited
+		enforcer.removeDeadLetter(containerID)
+		return
+	}
+
+	applyRetries.Add(1)
+	err = enforcer.tryApplyProfile(id.mntNsID, enforcer.expandProfile(containerID, id, profile.bpfContent))
+	if err != nil {
+		enforcer.log.Error(err, "failed to apply the BPF profile again", "profile name", letter.profileName, "container id", containerID)
+		enforcer.addDeadLetter(containerID, letter.profileName, id, err)
+		return
+	}
+
+	enforcer.containerCache[containerID] = id
+	profile.containerCache[containerID] = id
+	enforcer.journalApply(journalPhaseDone, containerID, id, letter.profileName, profile.hash, nil)
+	enforcer.removeDeadLetter(containerID)
+}
+
+// removeDeadLettersOfProfile removes the records of all containers of the profile
+func (enforcer *BpfEnforcer) removeDeadLettersOfProfile(profileName string) {
+	for containerID := range enforcer.deadLettersOfProfile(profileName) {
+		enforcer.removeDeadLetter(containerID)
+	}
+}
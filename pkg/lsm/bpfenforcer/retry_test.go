@@ -0,0 +1,126 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+)
+
+func newRetryTestEnforcer() *BpfEnforcer {
+	return &BpfEnforcer{
+		DeadLetterCh: make(chan string, 10),
+		retryCh:      make(chan string, 1),
+		deadLetters:  make(map[string]deadLetter),
+		log:          logr.Discard(),
+	}
+}
+
+func Test_isTransientError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: unix.ENOMEM, expected: true},
+		{err: fmt.Errorf("V_fileOuter.Put() failed: %w", unix.E2BIG), expected: true},
+		{err: unix.EAGAIN, expected: true},
+		{err: unix.EINVAL},
+		{err: fmt.Errorf("newEnforceID() failed")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, isTransientError(tc.err), tc.expected)
+		})
+	}
+}
+
+func Test_retryDelay(t *testing.T) {
+	previous := time.Duration(0)
+	for attempts := 1; attempts < applyBackoff.Steps; attempts++ {
+		delay := retryDelay(attempts)
+		expected := float64(applyBackoff.Duration) * float64(int(1)<<(attempts-1))
+		assert.Assert(t, float64(delay) >= expected && float64(delay) <= expected*(1+applyBackoff.Jitter),
+			"attempts: %d, delay: %s", attempts, delay)
+		assert.Assert(t, delay > previous)
+		previous = delay
+	}
+}
+
+func Test_addDeadLetter(t *testing.T) {
+	backoff := applyBackoff
+	defer func() { applyBackoff = backoff }()
+	applyBackoff.Duration = time.Millisecond
+	applyBackoff.Steps = 3
+
+	enforcer := newRetryTestEnforcer()
+	id := enforceID{pid: 1, mntNsID: 1}
+
+	// The transient failures are retried with the timers
+	enforcer.addDeadLetter("c1", "p1", id, unix.ENOMEM)
+	assert.Equal(t, <-enforcer.DeadLetterCh, "p1")
+	select {
+	case containerID := <-enforcer.retryCh:
+		assert.Equal(t, containerID, "c1")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the apply wasn't retried")
+	}
+	assert.Equal(t, enforcer.deadLetters["c1"].attempts, 1)
+
+	// The agent is only notified when the container failed for the first time
+	enforcer.addDeadLetter("c1", "p1", id, unix.ENOMEM)
+	assert.Equal(t, len(enforcer.DeadLetterCh), 0)
+	assert.Equal(t, enforcer.deadLetters["c1"].attempts, 2)
+	assert.Equal(t, <-enforcer.retryCh, "c1")
+
+	// The retries are exhausted
+	enforcer.addDeadLetter("c1", "p1", id, unix.ENOMEM)
+	assert.Equal(t, enforcer.deadLetters["c1"].attempts, 3)
+	assert.Assert(t, enforcer.deadLetters["c1"].retry == nil)
+	assert.DeepEqual(t, enforcer.DeadLetters("p1"), []string{"c1 (cannot allocate memory)"})
+
+	// The attempts restart for the new container of the same id
+	applyBackoff.Duration = time.Hour
+	enforcer.addDeadLetter("c1", "p1", enforceID{pid: 2, mntNsID: 2}, unix.ENOMEM)
+	assert.Equal(t, enforcer.deadLetters["c1"].attempts, 1)
+	timer := enforcer.deadLetters["c1"].retry
+	assert.Assert(t, timer != nil)
+
+	// The retry is canceled once the record is removed
+	enforcer.removeDeadLetter("c1")
+	assert.Equal(t, <-enforcer.DeadLetterCh, "p1")
+	assert.Equal(t, len(enforcer.deadLetters), 0)
+	assert.Assert(t, !timer.Stop())
+
+	// The other failures aren't retried
+	enforcer.addDeadLetter("c2", "p1", id, unix.EINVAL)
+	assert.Assert(t, enforcer.deadLetters["c2"].retry == nil)
+	assert.Equal(t, len(enforcer.retryCh), 0)
+}
+
+func Test_requestRetry(t *testing.T) {
+	enforcer := newRetryTestEnforcer()
+
+	// The retries are dropped instead of blocking the timers when the channel is full
+	enforcer.requestRetry("c1")
+	enforcer.requestRetry("c2")
+	assert.Equal(t, <-enforcer.retryCh, "c1")
+	assert.Equal(t, len(enforcer.retryCh), 0)
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+const (
+	// selfTestHelperEnv is set to the path of the canary file when the agent binary is re-executed as the
+	// helper process of the self-test
+	selfTestHelperEnv = "VARMOR_BPF_SELF_TEST_CANARY"
+	selfTestRuleID    = "selfTest/canary"
+	selfTestTimeout   = 5 * time.Second
+
+	// the exit codes of the helper process
+	selfTestBlocked = 0
+	selfTestAllowed = 1
+	selfTestError   = 2
+
+	// the flags and the permissions of the canary rule, they're the same as the ones used by the profile generator
+	selfTestPreciseMatch = 0x00000001
+	selfTestPrefixMatch  = 0x00000004
+	selfTestMayRead      = 0x00000004
+)
+
+var selfTestPassed = new(expvar.Int)
+
+func init() {
+	metrics.Set("self_test_passed", selfTestPassed)
+}
+
+// RunSelfTestHelper runs as the helper process of the self-test if the agent binary was re-executed by
+// SelfTest. It waits for the canary rule to be applied, then tries to read the canary file and exits.
+// It returns immediately if the process isn't the helper.
+func RunSelfTestHelper() {
+	canaryPath := os.Getenv(selfTestHelperEnv)
+	if canaryPath == "" {
+		return
+	}
+
+	buf := make([]byte, 1)
+	if _, err := os.Stdin.Read(buf); err != nil {
+		os.Exit(selfTestError)
+	}
+
+	file, err := os.Open(canaryPath)
+	switch {
+	case err == nil:
+		file.Close()
+		os.Exit(selfTestAllowed)
+	case errors.Is(err, os.ErrPermission):
+		os.Exit(selfTestBlocked)
+	default:
+		os.Exit(selfTestError)
+	}
+}
+
+// SelfTest validates that the enforcement is live on the node. It spawns a helper process in a scratch mnt ns,
+// applies a canary rule which denies reading a temporary file to it, and verifies that the read is blocked and
+// the violation event is emitted. It must be called before Run, and the agent binary must call RunSelfTestHelper
+// at startup.
+func (enforcer *BpfEnforcer) SelfTest() error {
+	err := enforcer.selfTest()
+	if err != nil {
+		selfTestPassed.Set(0)
+		enforcer.selfTestErr = err
+		return err
+	}
+	selfTestPassed.Set(1)
+	return nil
+}
+
+// SelfTestError returns the error of the self-test, it's nil if the self-test passed or hasn't been run
+func (enforcer *BpfEnforcer) SelfTestError() error {
+	return enforcer.selfTestErr
+}
+
+func (enforcer *BpfEnforcer) selfTest() error {
+	canary, err := os.CreateTemp("", "varmor-self-test-")
+	if err != nil {
+		return fmt.Errorf("failed to create the canary file: %w", err)
+	}
+	canary.Close()
+	defer os.Remove(canary.Name())
+
+	cmd := exec.Command("/proc/self/exe")
+	cmd.Env = append(os.Environ(), selfTestHelperEnv+"="+canary.Name())
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNS}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start the helper process: %w", err)
+	}
+	defer func() {
+		if cmd.ProcessState == nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+	}()
+
+	id, err := enforcer.newEnforceID(uint32(cmd.Process.Pid))
+	if err != nil {
+		return err
+	}
+
+	bpfContent := varmor.BpfContent{
+		Files: []varmor.FileContent{
+			{
+				RuleID:      selfTestRuleID,
+				Permissions: selfTestMayRead,
+				Pattern: varmor.PathPattern{
+					Flags:  selfTestPreciseMatch | selfTestPrefixMatch,
+					Prefix: canary.Name(),
+				},
+			},
+		},
+	}
+	err = enforcer.applyProfile(id.mntNsID, bpfContent)
+	if err != nil {
+		return fmt.Errorf("failed to apply the canary rule: %w", err)
+	}
+	defer enforcer.deleteProfile(id.mntNsID)
+
+	// Notify the helper process to read the canary file
+	stdin.Write([]byte{1})
+	stdin.Close()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("failed to wait for the helper process: %w", err)
+	}
+	switch cmd.ProcessState.ExitCode() {
+	case selfTestBlocked:
+	case selfTestAllowed:
+		return fmt.Errorf("the canary rule didn't block the operation")
+	default:
+		return fmt.Errorf("the helper process failed with exit code %d", cmd.ProcessState.ExitCode())
+	}
+
+	return enforcer.waitSelfTestViolation(id.mntNsID)
+}
+
+// waitSelfTestViolation reads the violation events until the one of the canary rule is received
+func (enforcer *BpfEnforcer) waitSelfTestViolation(nsID uint32) error {
+	if enforcer.violationReader == nil {
+		enforcer.log.Info("skip verifying the violation event of the self-test, the violation events are not supported by the BPF program")
+		return nil
+	}
+
+	enforcer.violationReader.SetDeadline(time.Now().Add(selfTestTimeout))
+	defer enforcer.violationReader.SetDeadline(time.Time{})
+
+	var event bpfViolationEvent
+	for {
+		record, err := enforcer.violationReader.Read()
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return fmt.Errorf("the violation event of the canary rule wasn't emitted")
+			}
+			return fmt.Errorf("failed to read the violation events: %w", err)
+		}
+
+		if record.LostSamples != 0 {
+			continue
+		}
+
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			continue
+		}
+
+		if event.MntNsID == nsID && event.RuleType == fileRuleType &&
+			enforcer.ruleIDs.resolve(nsID, event.RuleType, event.RuleIndex) == selfTestRuleID {
+			return nil
+		}
+	}
+}
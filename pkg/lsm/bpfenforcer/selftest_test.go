@@ -0,0 +1,111 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+)
+
+// Test_selfTestHelper runs as the helper process of the self-test when it's re-executed by Test_RunSelfTestHelper,
+// otherwise it does nothing.
+func Test_selfTestHelper(t *testing.T) {
+	RunSelfTestHelper()
+}
+
+func Test_RunSelfTestHelper(t *testing.T) {
+	dir := t.TempDir()
+	readable := filepath.Join(dir, "readable")
+	assert.NilError(t, os.WriteFile(readable, []byte("varmor"), 0644))
+	unreadable := filepath.Join(dir, "unreadable")
+	assert.NilError(t, os.WriteFile(unreadable, []byte("varmor"), 0000))
+
+	testCases := []struct {
+		name     string
+		path     string
+		notify   bool
+		expected int
+		// root bypasses the permission check of the unreadable file
+		nonRoot bool
+	}{
+		{
+			name:     "allowed",
+			path:     readable,
+			notify:   true,
+			expected: selfTestAllowed,
+		},
+		{
+			name:     "blocked",
+			path:     unreadable,
+			notify:   true,
+			expected: selfTestBlocked,
+			nonRoot:  true,
+		},
+		{
+			name:     "missing canary file",
+			path:     filepath.Join(dir, "missing"),
+			notify:   true,
+			expected: selfTestError,
+		},
+		{
+			name:     "not notified",
+			path:     readable,
+			expected: selfTestError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.nonRoot && os.Geteuid() == 0 {
+				t.Skip("the permission check is bypassed by root")
+			}
+
+			cmd := exec.Command(os.Args[0], "-test.run=^Test_selfTestHelper$")
+			cmd.Env = append(os.Environ(), selfTestHelperEnv+"="+tc.path)
+			if tc.notify {
+				cmd.Stdin = bytes.NewReader([]byte{1})
+			} else {
+				cmd.Stdin = bytes.NewReader(nil)
+			}
+
+			err := cmd.Run()
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				t.Fatal(err)
+			}
+			assert.Equal(t, cmd.ProcessState.ExitCode(), tc.expected)
+		})
+	}
+}
+
+func Test_SelfTestError(t *testing.T) {
+	enforcer := &BpfEnforcer{log: logr.Discard()}
+	assert.NilError(t, enforcer.SelfTestError())
+	assert.Equal(t, enforcer.Features()[FeatureSelfTest], true)
+
+	enforcer.selfTestErr = errors.New("the canary rule didn't block the operation")
+	assert.Error(t, enforcer.SelfTestError(), "the canary rule didn't block the operation")
+	assert.Equal(t, enforcer.Features()[FeatureSelfTest], false)
+
+	// The violation event isn't verified if the BPF program doesn't emit the violation events
+	assert.NilError(t, enforcer.waitSelfTestViolation(1))
+}
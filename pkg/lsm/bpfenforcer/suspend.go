@@ -0,0 +1,292 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sort"
+	"time"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// suspensionCheckInterval is the interval of resuming the enforcement of the containers whose suspensions expired
+const suspensionCheckInterval = 5 * time.Second
+
+var (
+	// errNotEnforced is returned when suspending a container that isn't enforced by the enforcer
+	errNotEnforced = errors.New("the container isn't enforced")
+	// errNotSuspended is returned when resuming a container whose enforcement isn't suspended
+	errNotSuspended = errors.New("the enforcement of the container isn't suspended")
+)
+
+var suspendedContainers = new(expvar.Int)
+
+func init() {
+	metrics.Set("suspended_containers", suspendedContainers)
+}
+
+// suspension describes the container whose enforcement is suspended. The info and the profile name are kept to
+// apply the profile to the container again when the suspension ends.
+type suspension struct {
+	info        varmortypes.ContainerInfo
+	profileName string
+	until       time.Time
+	reason      string
+}
+
+// suspendRequest asks the event handler to suspend the enforcement of the container until the time, or to resume
+// it if the time is zero
+type suspendRequest struct {
+	containerID string
+	until       time.Time
+	reason      string
+	result      chan error
+}
+
+// handleSuspendRequest suspends or resumes the container of the request and sends back the result
+func (enforcer *BpfEnforcer) handleSuspendRequest(request suspendRequest) {
+	err := errEnforcerClosed
+	enforcer.do(func() {
+		if request.until.IsZero() {
+			err = enforcer.resumeContainer(request.containerID, request.reason)
+		} else {
+			err = enforcer.suspendContainer(request.containerID, request.until, request.reason)
+		}
+	})
+	request.result <- err
+}
+
+func (enforcer *BpfEnforcer) sendSuspendRequest(ctx context.Context, request suspendRequest) error {
+	select {
+	case enforcer.suspendCh <- request:
+	case <-enforcer.done:
+		return errEnforcerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-request.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SuspendContainer suspends the enforcement of the container until the time, e.g. for debugging an incident. The
+// entries of the container are removed from the maps, and the BPF profile is applied to the container again when
+// the time passes or ResumeContainer is called. Suspending a suspended container changes the time and the reason.
+//
+// It returns an error if the container isn't enforced. It blocks until the event handler of the enforcer handles
+// the request, or the context is done.
+func (enforcer *BpfEnforcer) SuspendContainer(ctx context.Context, containerID string, until time.Time, reason string) error {
+	if !until.After(time.Now()) {
+		return fmt.Errorf("the suspension must end in the future")
+	}
+	return enforcer.sendSuspendRequest(ctx, suspendRequest{
+		containerID: containerID,
+		until:       until,
+		reason:      reason,
+		result:      make(chan error, 1),
+	})
+}
+
+// ResumeContainer applies the BPF profile to the container whose enforcement was suspended before the suspension
+// expires. It returns an error if the enforcement of the container isn't suspended.
+func (enforcer *BpfEnforcer) ResumeContainer(ctx context.Context, containerID string, reason string) error {
+	return enforcer.sendSuspendRequest(ctx, suspendRequest{
+		containerID: containerID,
+		reason:      reason,
+		result:      make(chan error, 1),
+	})
+}
+
+func newSuspension(containerID string, s suspension, resumed bool, now time.Time) varmortypes.Suspension {
+	return varmortypes.Suspension{
+		ProfileName:   s.profileName,
+		PodNamespace:  s.info.PodNamespace,
+		PodName:       s.info.PodName,
+		ContainerID:   containerID,
+		ContainerName: s.info.ContainerName,
+		Reason:        s.reason,
+		Until:         s.until,
+		Resumed:       resumed,
+		Timestamp:     now,
+	}
+}
+
+// Suspensions returns the containers whose enforcement is suspended, sorted by the container id
+func (enforcer *BpfEnforcer) Suspensions() []varmortypes.Suspension {
+	enforcer.cacheLock.Lock()
+	defer enforcer.cacheLock.Unlock()
+
+	suspensions := make([]varmortypes.Suspension, 0, len(enforcer.suspensions))
+	for containerID, s := range enforcer.suspensions {
+		suspensions = append(suspensions, newSuspension(containerID, s, false, time.Time{}))
+	}
+	sort.Slice(suspensions, func(i, j int) bool { return suspensions[i].ContainerID < suspensions[j].ContainerID })
+	return suspensions
+}
+
+func (enforcer *BpfEnforcer) isSuspended(containerID string) bool {
+	enforcer.cacheLock.Lock()
+	defer enforcer.cacheLock.Unlock()
+	_, ok := enforcer.suspensions[containerID]
+	return ok
+}
+
+// suspendContainer removes the entries of the container from the maps, and moves it from the caches to the
+// suspensions
+func (enforcer *BpfEnforcer) suspendContainer(containerID string, until time.Time, reason string) error {
+	enforcer.cacheLock.Lock()
+	if s, ok := enforcer.suspensions[containerID]; ok {
+		s.until = until
+		s.reason = reason
+		enforcer.suspensions[containerID] = s
+		enforcer.cacheLock.Unlock()
+
+		enforcer.log.Info("the suspension of the enforcement was changed", "container id", containerID, "until", until, "reason", reason)
+		enforcer.notifySuspension(newSuspension(containerID, s, false, time.Now()))
+		return nil
+	}
+
+	id, ok := enforcer.containerCache[containerID]
+	profileName := ""
+	for name, profile := range enforcer.bpfProfileCache {
+		if _, found := profile.containerCache[containerID]; found {
+			profileName = name
+			break
+		}
+	}
+	info := enforcer.containerInfos[containerID]
+	enforcer.cacheLock.Unlock()
+
+	if !ok || profileName == "" {
+		return fmt.Errorf("%w (container id: %s)", errNotEnforced, containerID)
+	}
+
+	err := enforcer.deleteProfileWithRetry(id.mntNsID)
+	if err != nil {
+		return fmt.Errorf("deleteProfile() failed: %w", err)
+	}
+	enforcer.regexWatcher.unwatch(containerID)
+	enforcer.journalDelete(containerID, id)
+
+	s := suspension{
+		info:        info,
+		profileName: profileName,
+		until:       until,
+		reason:      reason,
+	}
+
+	enforcer.cacheLock.Lock()
+	delete(enforcer.containerCache, containerID)
+	delete(enforcer.conflicts, containerID)
+	profile := enforcer.bpfProfileCache[profileName]
+	delete(profile.containerCache, containerID)
+	enforcer.bpfProfileCache[profileName] = profile
+	enforcer.suspensions[containerID] = s
+	enforcer.cacheLock.Unlock()
+	suspendedContainers.Add(1)
+
+	enforcer.log.Info("the enforcement of the container was suspended",
+		"profile name", profileName,
+		"pod namespace", info.PodNamespace,
+		"pod name", info.PodName,
+		"container name", info.ContainerName,
+		"container id", containerID,
+		"until", until,
+		"reason", reason)
+	enforcer.notifySuspension(newSuspension(containerID, s, false, time.Now()))
+	return nil
+}
+
+// removeSuspension forgets the suspension of the container, it returns false if the container isn't suspended
+func (enforcer *BpfEnforcer) removeSuspension(containerID string) (suspension, bool) {
+	enforcer.cacheLock.Lock()
+	defer enforcer.cacheLock.Unlock()
+
+	s, ok := enforcer.suspensions[containerID]
+	if ok {
+		delete(enforcer.suspensions, containerID)
+		suspendedContainers.Add(-1)
+	}
+	return s, ok
+}
+
+// resumeContainer applies the BPF profile to the container whose enforcement was suspended
+func (enforcer *BpfEnforcer) resumeContainer(containerID string, reason string) error {
+	s, ok := enforcer.removeSuspension(containerID)
+	if !ok {
+		return fmt.Errorf("%w (container id: %s)", errNotSuspended, containerID)
+	}
+	s.reason = reason
+
+	enforcer.log.Info("resume the enforcement of the container", "profile name", s.profileName, "container id", containerID, "reason", reason)
+	enforcer.notifySuspension(newSuspension(containerID, s, true, time.Now()))
+
+	err := enforcer.enforceContainerWithProfile(s.info, s.profileName)
+	if errors.Is(err, errProfileNotExist) {
+		// The profile was deleted during the suspension, so there is nothing to enforce
+		return nil
+	}
+	if errors.Is(err, errNodePressure) {
+		// The container is enforced when the pressure of the node subsides
+		return nil
+	}
+	return err
+}
+
+// expiredSuspensions returns the containers whose suspensions expired, sorted by the container id
+func (enforcer *BpfEnforcer) expiredSuspensions(now time.Time) []string {
+	enforcer.cacheLock.Lock()
+	defer enforcer.cacheLock.Unlock()
+
+	var ids []string
+	for containerID, s := range enforcer.suspensions {
+		if !now.Before(s.until) {
+			ids = append(ids, containerID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// resumeExpiredSuspensions resumes the enforcement of the containers whose suspensions expired
+func (enforcer *BpfEnforcer) resumeExpiredSuspensions(now time.Time) {
+	for _, containerID := range enforcer.expiredSuspensions(now) {
+		err := enforcer.resumeContainer(containerID, "the suspension expired")
+		if err != nil {
+			enforcer.log.Error(err, "resumeContainer() failed", "container id", containerID)
+		}
+	}
+}
+
+func (enforcer *BpfEnforcer) notifySuspension(suspension varmortypes.Suspension) {
+	if enforcer.opts.SuspensionSink != nil {
+		enforcer.opts.SuspensionSink(suspension)
+		return
+	}
+
+	select {
+	case enforcer.SuspensionCh <- suspension:
+	default:
+		enforcer.log.Info("the suspension channel is full, drop the notification", "container id", suspension.ContainerID)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_suspensions(t *testing.T) {
+	var notified []varmortypes.Suspension
+	enforcer := &BpfEnforcer{
+		opts: Options{
+			SuspensionSink: func(suspension varmortypes.Suspension) { notified = append(notified, suspension) },
+		},
+		bpfProfileCache: map[string]bpfProfile{"test-profile": {containerCache: make(map[string]enforceID)}},
+		containerCache:  make(map[string]enforceID),
+		containerInfos:  make(map[string]varmortypes.ContainerInfo),
+		suspensions:     make(map[string]suspension),
+		log:             logr.Discard(),
+	}
+
+	until := time.Unix(1700000600, 0)
+	err := enforcer.suspendContainer("c1", until, "debugging")
+	assert.Assert(t, errors.Is(err, errNotEnforced))
+
+	info := varmortypes.ContainerInfo{ContainerID: "c1", ContainerName: "app", PodNamespace: "demo", PodName: "web"}
+	enforcer.suspensions["c1"] = suspension{info: info, profileName: "test-profile", until: until, reason: "debugging"}
+	enforcer.suspensions["c2"] = suspension{profileName: "test-profile", until: until.Add(time.Hour)}
+	suspendedContainers.Set(2)
+
+	suspensions := enforcer.Suspensions()
+	assert.Equal(t, len(suspensions), 2)
+	assert.Equal(t, suspensions[0].ContainerID, "c1")
+	assert.Equal(t, suspensions[0].PodName, "web")
+	assert.Equal(t, suspensions[0].Until, until)
+
+	// The profile isn't applied to the suspended container
+	err = enforcer.enforceContainerWithProfile(info, "test-profile")
+	assert.NilError(t, err)
+	assert.Equal(t, len(enforcer.containerInfos), 0)
+
+	// Suspending the suspended container changes the time
+	err = enforcer.suspendContainer("c2", until, "extended")
+	assert.NilError(t, err)
+	assert.Equal(t, enforcer.suspensions["c2"].until, until)
+	assert.Equal(t, len(notified), 1)
+
+	assert.Equal(t, len(enforcer.expiredSuspensions(until.Add(-time.Second))), 0)
+	assert.DeepEqual(t, enforcer.expiredSuspensions(until), []string{"c1", "c2"})
+
+	// The profile was deleted during the suspension
+	delete(enforcer.bpfProfileCache, "test-profile")
+	enforcer.resumeExpiredSuspensions(until)
+	assert.Equal(t, len(enforcer.suspensions), 0)
+	assert.Equal(t, suspendedContainers.Value(), int64(0))
+	assert.Equal(t, len(notified), 3)
+	assert.Assert(t, notified[1].Resumed)
+	assert.Equal(t, notified[1].Reason, "the suspension expired")
+
+	err = enforcer.resumeContainer("c1", "")
+	assert.Assert(t, errors.Is(err, errNotSuspended))
+}
+
+func Test_SuspendContainer(t *testing.T) {
+	enforcer := &BpfEnforcer{
+		suspendCh: make(chan suspendRequest),
+		done:      make(chan struct{}),
+	}
+
+	err := enforcer.SuspendContainer(context.Background(), "c1", time.Now().Add(-time.Minute), "")
+	assert.ErrorContains(t, err, "in the future")
+
+	close(enforcer.done)
+	err = enforcer.SuspendContainer(context.Background(), "c1", time.Now().Add(time.Minute), "")
+	assert.Equal(t, err, errEnforcerClosed)
+	err = enforcer.ResumeContainer(context.Background(), "c1", "")
+	assert.Equal(t, err, errEnforcerClosed)
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+	varmorutils "github.com/bytedance/vArmor/pkg/utils"
+)
+
+// errProfileNotExist is returned when the BPF profile of a target container hasn't been saved to the enforcer
+var errProfileNotExist = errors.New("the BPF profile doesn't exist")
+
+type enforceRequest struct {
+	info   varmortypes.ContainerInfo
+	result chan error
+}
+
+// handleEnforceRequest enforces the container of the request and sends back the result
+func (enforcer *BpfEnforcer) handleEnforceRequest(request enforceRequest) {
+	err := errEnforcerClosed
+	enforcer.do(func() { err = enforcer.enforceContainer(request.info) })
+	request.result <- err
+}
+
+// EnforceContainer applies the BPF profile to the container synchronously, instead of reacting to the
+// TaskCreateCh asynchronously. It's designed for the hooks that participate in the container creation,
+// e.g. the StartContainer hook of an NRI (Node Resource Interface) plugin, so the profile is enforced
+// before the entrypoint of the container runs. The info.PID must be the init process of the container,
+// whose mnt ns has been created.
+//
+// It returns nil if the container isn't a target container, and an error if the profile of a target
+// container can't be applied, then the caller can fail the container creation. The error is also returned
+// if the container is pending to be enforced since the node is under memory pressure. It blocks until the
+// event handler of the enforcer handles the request, or the context is done.
+//
+// The info.ContainerID and info.PodUID are resolved from the cgroups of the info.PID if they're empty, e.g. the
+// hook only knows the PID. It supports the layouts of containerd and CRI-O on the nodes in any cgroup mode.
+func (enforcer *BpfEnforcer) EnforceContainer(ctx context.Context, info varmortypes.ContainerInfo) error {
+	err := resolveContainerIdentity(&info)
+	if err != nil {
+		return err
+	}
+
+	request := enforceRequest{
+		info:   info,
+		result: make(chan error, 1),
+	}
+
+	select {
+	case enforcer.enforceCh <- request:
+	case <-enforcer.done:
+		return errEnforcerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-request.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolveContainerIdentity fills the container id and the pod uid of the container from the cgroups of its init
+// process if they're empty
+func resolveContainerIdentity(info *varmortypes.ContainerInfo) error {
+	if info.PID == 0 || (info.ContainerID != "" && info.PodUID != "") {
+		return nil
+	}
+
+	identity, err := varmorutils.ReadCgroupIdentity(info.PID)
+	if err != nil {
+		if info.ContainerID != "" {
+			// The pod uid is only used to enrich the violations
+			return nil
+		}
+		return fmt.Errorf("failed to resolve the container of the process %d: %w", info.PID, err)
+	}
+	if info.ContainerID == "" {
+		if identity.ContainerID == "" {
+			return fmt.Errorf("the process %d doesn't run in a container (cgroup: %s)", info.PID, identity.Path)
+		}
+		info.ContainerID = identity.ContainerID
+	}
+	if info.PodUID == "" {
+		info.PodUID = identity.PodUID
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_EnforceContainer(t *testing.T) {
+	enforcer := &BpfEnforcer{
+		enforceCh: make(chan enforceRequest),
+		done:      make(chan struct{}),
+		opts: Options{
+			ProfileResolver: func(info varmortypes.ContainerInfo) (string, bool) {
+				return "test-profile", info.ContainerName == "target"
+			},
+		},
+		bpfProfileCache: make(map[string]bpfProfile),
+	}
+	go func() {
+		for request := range enforcer.enforceCh {
+			enforcer.handleEnforceRequest(request)
+		}
+	}()
+	defer close(enforcer.enforceCh)
+
+	err := enforcer.EnforceContainer(context.Background(), varmortypes.ContainerInfo{ContainerName: "other"})
+	assert.NilError(t, err)
+
+	err = enforcer.EnforceContainer(context.Background(), varmortypes.ContainerInfo{ContainerName: "target"})
+	assert.Equal(t, errors.Is(err, errProfileNotExist), true)
+
+	enforcer.closed = true
+	err = enforcer.EnforceContainer(context.Background(), varmortypes.ContainerInfo{ContainerName: "target"})
+	assert.Equal(t, err, errEnforcerClosed)
+}
+
+func Test_EnforceContainerNotRunning(t *testing.T) {
+	enforcer := &BpfEnforcer{
+		enforceCh: make(chan enforceRequest),
+		done:      make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := enforcer.EnforceContainer(ctx, varmortypes.ContainerInfo{})
+	assert.Equal(t, err, context.DeadlineExceeded)
+
+	close(enforcer.done)
+	err = enforcer.EnforceContainer(context.Background(), varmortypes.ContainerInfo{})
+	assert.Equal(t, err, errEnforcerClosed)
+}
@@ -0,0 +1,308 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"crypto/sha256"
+	"errors"
+	"expvar"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	ebpf "github.com/cilium/ebpf"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// tamperCheckInterval is the interval of checking whether the maps were modified by anything other than the enforcer
+const tamperCheckInterval = time.Minute
+
+var tamperDetected = new(expvar.Int)
+
+func init() {
+	metrics.Set("map_tamper_detected_total", tamperDetected)
+}
+
+// enforcementMap is a map keyed by the mnt ns id that is used by the BPF program
+type enforcementMap struct {
+	name string
+	m    *ebpf.Map
+	// outer indicates whether the map is an outer map (map-in-map)
+	outer bool
+}
+
+// enforcementMaps returns the maps keyed by the mnt ns id, the optional maps that the BPF program doesn't
+// support are skipped
+func (enforcer *BpfEnforcer) enforcementMaps() []enforcementMap {
+	maps := []enforcementMap{
+		{name: "V_capable", m: enforcer.objs.V_capable},
+		{name: "V_ptrace", m: enforcer.objs.V_ptrace},
+		{name: "V_fileOuter", m: enforcer.objs.V_fileOuter, outer: true},
+		{name: "V_bprmOuter", m: enforcer.objs.V_bprmOuter, outer: true},
+		{name: "V_netOuter", m: enforcer.objs.V_netOuter, outer: true},
+		{name: "V_mountOuter", m: enforcer.objs.V_mountOuter, outer: true},
+	}
+
+	supported := maps[:0]
+	for _, m := range maps {
+		if m.m != nil {
+			supported = append(supported, m)
+		}
+	}
+	return supported
+}
+
+// innerMapChecksum returns the checksum of the entries of the inner map
+func innerMapChecksum(innerMap *ebpf.Map) (string, error) {
+	var entries [][]byte
+	var key, value []byte
+	iter := innerMap.Iterate()
+	for iter.Next(&key, &value) {
+		entries = append(entries, append(append([]byte{}, key...), value...))
+	}
+	if err := iter.Err(); err != nil {
+		return "", err
+	}
+
+	// The order of iteration isn't stable across the maps
+	sort.Slice(entries, func(i, j int) bool { return string(entries[i]) < string(entries[j]) })
+	h := sha256.New()
+	for _, entry := range entries {
+		h.Write(entry)
+	}
+	return string(h.Sum(nil)), nil
+}
+
+// mntNsFingerprint returns the values of the entries of the mnt ns in the maps. The values of the outer maps are
+// the checksums of their inner maps.
+func (enforcer *BpfEnforcer) mntNsFingerprint(nsID uint32) (map[string]string, error) {
+	fingerprint := make(map[string]string)
+	for _, m := range enforcer.enforcementMaps() {
+		if !m.outer {
+			value, err := m.m.LookupBytes(&nsID)
+			if err != nil {
+				return nil, fmt.Errorf("%s.LookupBytes(): %w", m.name, err)
+			}
+			if value != nil {
+				fingerprint[m.name] = string(value)
+			}
+			continue
+		}
+
+		var innerMap *ebpf.Map
+		err := m.m.Lookup(&nsID, &innerMap)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s.Lookup(): %w", m.name, err)
+		}
+		checksum, err := innerMapChecksum(innerMap)
+		innerMap.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.name, err)
+		}
+		fingerprint[m.name] = checksum
+	}
+	return fingerprint, nil
+}
+
+// diffFingerprints returns the sorted names of the maps whose entries differ
+func diffFingerprints(expected map[string]string, current map[string]string) []string {
+	var names []string
+	for name, value := range expected {
+		if v, ok := current[name]; !ok || v != value {
+			names = append(names, name)
+		}
+	}
+	for name := range current {
+		if _, ok := expected[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fingerprintStore saves the fingerprints of the mnt ns after the enforcer changed their entries in the maps
+type fingerprintStore struct {
+	lock         sync.Mutex
+	fingerprints map[uint32]map[string]string // <mntNsID: fingerprint>
+}
+
+func newFingerprintStore() *fingerprintStore {
+	return &fingerprintStore{
+		fingerprints: make(map[uint32]map[string]string),
+	}
+}
+
+// save saves the fingerprint of the mnt ns. The mnt ns is skipped by the check if the fingerprint is nil.
+func (s *fingerprintStore) save(nsID uint32, fingerprint map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.fingerprints[nsID] = fingerprint
+}
+
+func (s *fingerprintStore) delete(nsID uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.fingerprints, nsID)
+}
+
+// get returns the fingerprint of the mnt ns and whether it has been saved
+func (s *fingerprintStore) get(nsID uint32) (map[string]string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	fingerprint, ok := s.fingerprints[nsID]
+	return fingerprint, ok
+}
+
+func (s *fingerprintStore) mntNsIDs() []uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ids := make([]uint32, 0, len(s.fingerprints))
+	for id := range s.fingerprints {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// recordFingerprint saves the entries of the mnt ns after the enforcer changed them
+func (enforcer *BpfEnforcer) recordFingerprint(nsID uint32) {
+	fingerprint, err := enforcer.mntNsFingerprint(nsID)
+	if err != nil {
+		// Skip checking the mnt ns rather than raising a false alarm
+		enforcer.log.Error(err, "mntNsFingerprint() failed, the mnt ns won't be checked for tampering", "mnt ns id", nsID)
+		enforcer.fingerprints.save(nsID, nil)
+		return
+	}
+	enforcer.fingerprints.save(nsID, fingerprint)
+}
+
+// detectTampers compares the entries of the maps with the ones recorded after the enforcer changed them.
+// The entries of the mnt ns that the enforcer never changed are regarded as injected.
+func (enforcer *BpfEnforcer) detectTampers() ([]varmortypes.Tamper, error) {
+	ids, err := enforcer.enforcedMntNsIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range enforcer.fingerprints.mntNsIDs() {
+		ids[id] = true
+	}
+
+	var tampers []varmortypes.Tamper
+	for id := range ids {
+		// The entries of the leaked mnt ns are left over by the enforcer, they're deleted by the garbage collection
+		if enforcer.leaks.has(id) {
+			continue
+		}
+
+		expected, ok := enforcer.fingerprints.get(id)
+		if ok && expected == nil {
+			continue
+		}
+
+		current, err := enforcer.mntNsFingerprint(id)
+		if err != nil {
+			return nil, err
+		}
+		if maps := diffFingerprints(expected, current); len(maps) != 0 {
+			tampers = append(tampers, varmortypes.Tamper{MntNsID: id, Maps: maps})
+		}
+	}
+
+	sort.Slice(tampers, func(i, j int) bool { return tampers[i].MntNsID < tampers[j].MntNsID })
+	return tampers, nil
+}
+
+// restoreTamperedMntNs reapplies the profile to the container of the tampered mnt ns, or removes the entries
+// if the enforcer never changed them. It returns false if the mnt ns can't be restored.
+func (enforcer *BpfEnforcer) restoreTamperedMntNs(tamper *varmortypes.Tamper) bool {
+	for profileName, profile := range enforcer.bpfProfileCache {
+		for containerID, id := range profile.containerCache {
+			if id.mntNsID != tamper.MntNsID {
+				continue
+			}
+
+			info := enforcer.containerInfos[containerID]
+			tamper.ProfileName = profileName
+			tamper.ContainerID = containerID
+			tamper.PodNamespace = info.PodNamespace
+			tamper.PodName = info.PodName
+
+			err := enforcer.applyProfile(id.mntNsID, enforcer.expandProfile(containerID, id, profile.bpfContent))
+			if err != nil {
+				enforcer.log.Error(err, "failed to reapply the profile to the tampered mnt ns",
+					"profile name", profileName, "container id", containerID, "mnt ns id", tamper.MntNsID)
+				return false
+			}
+			return true
+		}
+	}
+
+	// The profiles applied by ApplyBpfProfileToProcess aren't cached, so they can't be reapplied
+	if _, ok := enforcer.fingerprints.get(tamper.MntNsID); ok {
+		return false
+	}
+
+	err := enforcer.deleteProfile(tamper.MntNsID)
+	if err != nil {
+		enforcer.log.Error(err, "failed to remove the injected entries", "mnt ns id", tamper.MntNsID)
+		return false
+	}
+	return true
+}
+
+// checkTampers detects the entries of the maps that were modified by anything other than the enforcer, e.g. by an
+// attacker with CAP_BPF on the node, restores them and notifies the tampers.
+func (enforcer *BpfEnforcer) checkTampers() {
+	tampers, err := enforcer.detectTampers()
+	if err != nil {
+		enforcer.log.Error(err, "detectTampers() failed")
+		return
+	}
+
+	now := time.Now()
+	for i := range tampers {
+		tamper := &tampers[i]
+		tamper.Timestamp = now
+		tamper.Restored = enforcer.restoreTamperedMntNs(tamper)
+		if !tamper.Restored {
+			// Report it only once
+			enforcer.recordFingerprint(tamper.MntNsID)
+		}
+		tamperDetected.Add(1)
+
+		enforcer.log.Error(fmt.Errorf("the maps of the BPF enforcer were modified externally"), "tamper detected",
+			"mnt ns id", tamper.MntNsID, "maps", tamper.Maps, "profile name", tamper.ProfileName,
+			"pod namespace", tamper.PodNamespace, "pod name", tamper.PodName, "restored", tamper.Restored)
+		enforcer.notifyTamper(*tamper)
+	}
+}
+
+func (enforcer *BpfEnforcer) notifyTamper(tamper varmortypes.Tamper) {
+	if enforcer.opts.TamperSink != nil {
+		enforcer.opts.TamperSink(tamper)
+		return
+	}
+
+	select {
+	case enforcer.TamperCh <- tamper:
+	default:
+		enforcer.log.Info("the tamper channel is full, drop the notification", "mnt ns id", tamper.MntNsID)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_diffFingerprints(t *testing.T) {
+	expected := map[string]string{"V_capable": "a", "V_fileOuter": "b", "V_netOuter": "c"}
+
+	assert.Assert(t, diffFingerprints(expected, map[string]string{"V_capable": "a", "V_fileOuter": "b", "V_netOuter": "c"}) == nil)
+	assert.DeepEqual(t, diffFingerprints(expected, map[string]string{"V_capable": "a", "V_fileOuter": "x", "V_ptrace": "d"}),
+		[]string{"V_fileOuter", "V_netOuter", "V_ptrace"})
+	// The entries of the mnt ns that the enforcer never changed are injected
+	assert.DeepEqual(t, diffFingerprints(nil, map[string]string{"V_capable": "a"}), []string{"V_capable"})
+}
+
+func Test_fingerprintStore(t *testing.T) {
+	s := newFingerprintStore()
+	s.save(4026532001, map[string]string{"V_capable": "a"})
+	s.save(4026532002, nil)
+
+	fingerprint, ok := s.get(4026532001)
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, fingerprint, map[string]string{"V_capable": "a"})
+
+	// The mnt ns whose fingerprint is nil is skipped
+	fingerprint, ok = s.get(4026532002)
+	assert.Assert(t, ok && fingerprint == nil)
+
+	s.delete(4026532001)
+	_, ok = s.get(4026532001)
+	assert.Assert(t, !ok)
+	assert.DeepEqual(t, s.mntNsIDs(), []uint32{4026532002})
+}
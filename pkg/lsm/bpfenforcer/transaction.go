@@ -0,0 +1,147 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"fmt"
+
+	ebpf "github.com/cilium/ebpf"
+)
+
+// mapChange describes the change of a mnt ns entry in one of the maps used by the BPF program.
+// The value is an inner map for the outer maps, or an integer for the others. The entry is
+// deleted when the value is nil.
+type mapChange struct {
+	name string
+	m    *ebpf.Map
+	// outer indicates whether the map is an outer map (map-in-map)
+	outer bool
+	// value is the staged value, it's nil if the entry needs to be deleted
+	value interface{}
+	// entries is the expected entry count of the staged inner map
+	entries int
+	// previous is the value before the change, it's nil if the entry didn't exist
+	previous interface{}
+}
+
+func newOuterMapChange(name string, m *ebpf.Map, innerMap *ebpf.Map, entries int) *mapChange {
+	change := mapChange{
+		name:    name,
+		m:       m,
+		outer:   true,
+		entries: entries,
+	}
+	if innerMap != nil {
+		change.value = innerMap
+	}
+	return &change
+}
+
+// verify checks whether all the rules were written into the staged inner map
+func (c *mapChange) verify() error {
+	innerMap, ok := c.value.(*ebpf.Map)
+	if !ok {
+		return nil
+	}
+
+	count := 0
+	var key, nextKey uint32
+	var err error
+	for err = innerMap.NextKey(nil, &nextKey); err == nil; err = innerMap.NextKey(&key, &nextKey) {
+		key = nextKey
+		count++
+	}
+	if !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return err
+	}
+
+	if count != c.entries {
+		return fmt.Errorf("the inner map has %d entries, expected %d", count, c.entries)
+	}
+	return nil
+}
+
+// freeze makes the staged inner map read-only for the syscalls, so its rules can't be modified after it's committed
+func (c *mapChange) freeze() error {
+	innerMap, ok := c.value.(*ebpf.Map)
+	if !ok {
+		return nil
+	}
+	return innerMap.Freeze()
+}
+
+// snapshot saves the current value of the mnt ns entry for rollback
+func (c *mapChange) snapshot(nsID uint32) error {
+	var err error
+
+	if c.outer {
+		var innerMap *ebpf.Map
+		err = c.m.Lookup(&nsID, &innerMap)
+		if err == nil {
+			c.previous = innerMap
+		}
+	} else {
+		// The raw value is saved since the sizes of the values differ across the maps
+		var value []byte
+		value, err = c.m.LookupBytes(&nsID)
+		if err == nil && value != nil {
+			c.previous = value
+		}
+	}
+
+	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (c *mapChange) update(nsID uint32, value interface{}) error {
+	if value == nil {
+		err := c.m.Delete(&nsID)
+		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+		return nil
+	}
+	return c.m.Put(&nsID, value)
+}
+
+// commit writes the staged value into the map
+func (c *mapChange) commit(nsID uint32) error {
+	return c.update(nsID, c.value)
+}
+
+// rollback restores the value saved by snapshot
+func (c *mapChange) rollback(nsID uint32) error {
+	return c.update(nsID, c.previous)
+}
+
+// close releases the file descriptors of the staged and the saved inner maps. The inner maps
+// that have been inserted into the outer maps are still held by the kernel.
+func (c *mapChange) close() {
+	if innerMap, ok := c.value.(*ebpf.Map); ok {
+		innerMap.Close()
+	}
+	if innerMap, ok := c.previous.(*ebpf.Map); ok {
+		innerMap.Close()
+	}
+}
+
+func closeMapChanges(changes []*mapChange) {
+	for _, change := range changes {
+		change.close()
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"testing"
+
+	ebpf "github.com/cilium/ebpf"
+	"gotest.tools/assert"
+)
+
+var testInnerMapSpec = ebpf.MapSpec{
+	Type:       ebpf.Hash,
+	KeySize:    4,
+	ValueSize:  4,
+	MaxEntries: 4,
+}
+
+// newTestMap creates the map for the tests, they're skipped if the BPF maps can't be created, e.g. without privileges
+func newTestMap(t *testing.T, spec *ebpf.MapSpec) *ebpf.Map {
+	m, err := ebpf.NewMap(spec)
+	if err != nil {
+		t.Skipf("failed to create the BPF map: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func newTestOuterMap(t *testing.T) *ebpf.Map {
+	return newTestMap(t, &ebpf.MapSpec{
+		Type:       ebpf.HashOfMaps,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 4,
+		InnerMap:   &testInnerMapSpec,
+	})
+}
+
+func newTestInnerMap(t *testing.T, entries int) *ebpf.Map {
+	m, err := ebpf.NewMap(&testInnerMapSpec)
+	if err != nil {
+		t.Skipf("failed to create the BPF map: %v", err)
+	}
+	for i := 0; i < entries; i++ {
+		assert.NilError(t, m.Put(uint32(i), uint32(i)))
+	}
+	return m
+}
+
+func Test_mapChange(t *testing.T) {
+	nsID := uint32(4026531840)
+	m := newTestMap(t, &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 4, ValueSize: 4, MaxEntries: 4})
+
+	// The entry is created and deleted when it didn't exist
+	change := mapChange{name: "test", m: m, value: uint32(1)}
+	assert.NilError(t, change.snapshot(nsID))
+	assert.Assert(t, change.previous == nil)
+	assert.NilError(t, change.commit(nsID))
+	var value uint32
+	assert.NilError(t, m.Lookup(&nsID, &value))
+	assert.Equal(t, value, uint32(1))
+	assert.NilError(t, change.rollback(nsID))
+	assert.Assert(t, errors.Is(m.Lookup(&nsID, &value), ebpf.ErrKeyNotExist))
+
+	// The previous value is restored
+	assert.NilError(t, m.Put(&nsID, uint32(2)))
+	change = mapChange{name: "test", m: m, value: uint32(3)}
+	assert.NilError(t, change.snapshot(nsID))
+	assert.NilError(t, change.commit(nsID))
+	assert.NilError(t, m.Lookup(&nsID, &value))
+	assert.Equal(t, value, uint32(3))
+	assert.NilError(t, change.rollback(nsID))
+	assert.NilError(t, m.Lookup(&nsID, &value))
+	assert.Equal(t, value, uint32(2))
+
+	// The entry is deleted when the staged value is nil, and deleting a missing entry succeeds
+	change = mapChange{name: "test", m: m}
+	assert.NilError(t, change.snapshot(nsID))
+	assert.NilError(t, change.commit(nsID))
+	assert.Assert(t, errors.Is(m.Lookup(&nsID, &value), ebpf.ErrKeyNotExist))
+	assert.NilError(t, change.commit(nsID))
+	assert.NilError(t, change.rollback(nsID))
+	assert.NilError(t, m.Lookup(&nsID, &value))
+	assert.Equal(t, value, uint32(2))
+}
+
+func Test_mapChange_outer(t *testing.T) {
+	nsID := uint32(4026531840)
+	outer := newTestOuterMap(t)
+
+	previous := newTestInnerMap(t, 1)
+	assert.NilError(t, outer.Put(&nsID, previous))
+	previous.Close()
+
+	staged := newTestInnerMap(t, 2)
+	change := newOuterMapChange("test", outer, staged, 2)
+	defer change.close()
+	assert.NilError(t, change.verify())
+	assert.NilError(t, change.snapshot(nsID))
+	_, ok := change.previous.(*ebpf.Map)
+	assert.Assert(t, ok)
+
+	assert.NilError(t, change.freeze())
+	assert.Assert(t, staged.Put(uint32(3), uint32(3)) != nil)
+
+	assert.NilError(t, change.commit(nsID))
+	var innerMap *ebpf.Map
+	assert.NilError(t, outer.Lookup(&nsID, &innerMap))
+	info, err := innerMap.Info()
+	innerMap.Close()
+	assert.NilError(t, err)
+	stagedInfo, err := staged.Info()
+	assert.NilError(t, err)
+	id, _ := info.ID()
+	stagedID, _ := stagedInfo.ID()
+	assert.Equal(t, id, stagedID)
+
+	// The previous inner map is restored
+	assert.NilError(t, change.rollback(nsID))
+	assert.NilError(t, outer.Lookup(&nsID, &innerMap))
+	info, err = innerMap.Info()
+	innerMap.Close()
+	assert.NilError(t, err)
+	id, _ = info.ID()
+	assert.Assert(t, id != stagedID)
+}
+
+func Test_mapChange_verify(t *testing.T) {
+	testCases := []struct {
+		name        string
+		entries     int
+		expected    int
+		expectedErr string
+	}{
+		{
+			name:     "complete",
+			entries:  3,
+			expected: 3,
+		},
+		{
+			name:        "missing rules",
+			entries:     2,
+			expected:    3,
+			expectedErr: "the inner map has 2 entries, expected 3",
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	outer := newTestOuterMap(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			change := newOuterMapChange("test", outer, newTestInnerMap(t, tc.entries), tc.expected)
+			defer change.close()
+
+			err := change.verify()
+			if tc.expectedErr != "" {
+				assert.Error(t, err, tc.expectedErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+
+	// The change that deletes the entry has nothing to verify
+	change := newOuterMapChange("test", outer, nil, 0)
+	assert.Assert(t, change.value == nil)
+	assert.NilError(t, change.verify())
+	assert.NilError(t, change.freeze())
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/ebpf/link"
+)
+
+// adoptPreviousGeneration takes over the BPF programs pinned by the previous enforcer and unpins them. They stay
+// attached to the hook points with the maps of the previous enforcer until ReleasePreviousGeneration is called, so
+// the existing containers are still protected before the profiles are reapplied to them. It must be called after
+// the BPF programs of the current enforcer are attached.
+func (enforcer *BpfEnforcer) adoptPreviousGeneration() error {
+	entries, err := os.ReadDir(enforcer.opts.PinPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(enforcer.opts.PinPath, entry.Name())
+		l, err := link.LoadPinnedLink(path, nil)
+		if err != nil {
+			enforcer.log.Error(err, "failed to load the pinned BPF program, it will be detached", "path", path)
+			continue
+		}
+		enforcer.previousLinks = append(enforcer.previousLinks, l)
+	}
+
+	enforcer.log.Info("take over the BPF programs pinned by the previous enforcer",
+		"path", enforcer.opts.PinPath, "count", len(enforcer.previousLinks))
+	return os.RemoveAll(enforcer.opts.PinPath)
+}
+
+// closePreviousLinks detaches the BPF programs of the previous enforcer
+func (enforcer *BpfEnforcer) closePreviousLinks() {
+	if len(enforcer.previousLinks) == 0 {
+		return
+	}
+
+	enforcer.log.Info("detach the BPF programs of the previous enforcer", "count", len(enforcer.previousLinks))
+	for _, l := range enforcer.previousLinks {
+		l.Close()
+	}
+	enforcer.previousLinks = nil
+}
+
+// handleReleaseRequest handles the pending container events first, so the existing containers are enforced by
+// the current enforcer before the BPF programs of the previous one are detached
+func (enforcer *BpfEnforcer) handleReleaseRequest(result chan error) {
+	for pending := true; pending; {
+		select {
+		case info := <-enforcer.TaskCreateCh:
+			enforcer.handleTaskEvent(info, enforcer.handleTaskCreate)
+		default:
+			pending = false
+		}
+	}
+	enforcer.waitTaskEvents()
+
+	err := errEnforcerClosed
+	enforcer.do(func() {
+		enforcer.closePreviousLinks()
+		err = nil
+	})
+	result <- err
+}
+
+// ReleasePreviousGeneration detaches the BPF programs taken over from the previous enforcer whose enforcement was
+// kept on shutdown, e.g. during the upgrade of vArmor agent. It should be called after the profiles are saved
+// and the existing containers are sent to the TaskCreateCh. The pending container events are handled before the
+// programs are detached, so the protected containers never experience an enforcement gap. It blocks until the
+// event handler of the enforcer handles the request, or the context is done. It's a no-op if nothing was taken over.
+func (enforcer *BpfEnforcer) ReleasePreviousGeneration(ctx context.Context) error {
+	result := make(chan error, 1)
+
+	select {
+	case enforcer.releaseCh <- result:
+	case <-enforcer.done:
+		return errEnforcerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_adoptPreviousGeneration(t *testing.T) {
+	pinPath := filepath.Join(t.TempDir(), "varmor")
+	enforcer := &BpfEnforcer{
+		opts: Options{PinPath: pinPath},
+		log:  logr.Discard(),
+	}
+
+	// Nothing was pinned by the previous enforcer
+	assert.NilError(t, enforcer.adoptPreviousGeneration())
+	assert.Equal(t, len(enforcer.previousLinks), 0)
+
+	// The pins that can't be loaded are removed
+	assert.NilError(t, os.MkdirAll(pinPath, 0700))
+	assert.NilError(t, os.WriteFile(filepath.Join(pinPath, "capable"), nil, 0600))
+	assert.NilError(t, enforcer.adoptPreviousGeneration())
+	assert.Equal(t, len(enforcer.previousLinks), 0)
+	_, err := os.Stat(pinPath)
+	assert.Equal(t, os.IsNotExist(err), true)
+}
+
+func Test_ReleasePreviousGeneration(t *testing.T) {
+	var handled []string
+	enforcer := &BpfEnforcer{
+		TaskCreateCh: make(chan varmortypes.ContainerInfo, 2),
+		releaseCh:    make(chan chan error),
+		done:         make(chan struct{}),
+		opts: Options{
+			ProfileResolver: func(info varmortypes.ContainerInfo) (string, bool) {
+				handled = append(handled, info.ContainerID)
+				return "", false
+			},
+		},
+		log: logr.Discard(),
+	}
+	enforcer.TaskCreateCh <- varmortypes.ContainerInfo{ContainerID: "a"}
+	enforcer.TaskCreateCh <- varmortypes.ContainerInfo{ContainerID: "b"}
+
+	go func() {
+		result := <-enforcer.releaseCh
+		enforcer.handleReleaseRequest(result)
+	}()
+	assert.NilError(t, enforcer.ReleasePreviousGeneration(context.Background()))
+	assert.DeepEqual(t, handled, []string{"a", "b"})
+
+	// The enforcer was closed
+	close(enforcer.done)
+	assert.Equal(t, enforcer.ReleasePreviousGeneration(context.Background()), errEnforcerClosed)
+}
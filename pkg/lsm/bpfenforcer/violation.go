@@ -0,0 +1,263 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/perf"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// The types of the rules which are reported in the violation events
+const (
+	capabilityRuleType uint32 = iota + 1
+	fileRuleType
+	bprmRuleType
+	networkRuleType
+	ptraceRuleType
+	mountRuleType
+)
+
+// noRuleIndex means the violation isn't matched with a rule of the inner maps, e.g. the capability rule
+const noRuleIndex uint32 = 0xFFFFFFFF
+
+var ruleTypeNames = map[uint32]string{
+	capabilityRuleType: "capability",
+	fileRuleType:       "file",
+	bprmRuleType:       "bprm",
+	networkRuleType:    "network",
+	ptraceRuleType:     "ptrace",
+	mountRuleType:      "mount",
+}
+
+// bpfViolationEvent is emitted by the BPF programs when an operation is denied.
+// RuleIndex is the index of the matched rule in the inner map of the rule type.
+type bpfViolationEvent struct {
+	MntNsID     uint32
+	Tgid        uint32
+	RuleType    uint32
+	RuleIndex   uint32
+	Permissions uint32
+	// The fields below are the context of the violation. They are only emitted by the BPF programs that support
+	// it, otherwise Capability and Syscall are unknownContext.
+	//
+	// Capability is the number of the requested capability, it's only set for the capability rule
+	Capability uint32
+	// Syscall is the number of the syscall that requested the operation
+	Syscall uint32
+}
+
+// unknownContext means the context of the violation isn't reported by the BPF program
+const unknownContext uint32 = 0xFFFFFFFF
+
+// violationHeaderSize is the size of the violation event without the context
+const violationHeaderSize = 4 * 5
+
+// parseViolationEvent parses the violation event emitted by the BPF program, the context is unknown if the
+// BPF program doesn't support it.
+func parseViolationEvent(sample []byte, event *bpfViolationEvent) error {
+	if len(sample) >= binary.Size(event) {
+		return binary.Read(bytes.NewReader(sample), binary.LittleEndian, event)
+	}
+
+	if len(sample) < violationHeaderSize {
+		return fmt.Errorf("the violation event is too short (%d bytes)", len(sample))
+	}
+	var header [5]uint32
+	err := binary.Read(bytes.NewReader(sample[:violationHeaderSize]), binary.LittleEndian, &header)
+	if err != nil {
+		return err
+	}
+	*event = bpfViolationEvent{
+		MntNsID:     header[0],
+		Tgid:        header[1],
+		RuleType:    header[2],
+		RuleIndex:   header[3],
+		Permissions: header[4],
+		Capability:  unknownContext,
+		Syscall:     unknownContext,
+	}
+	return nil
+}
+
+// appliedRuleIDs holds the rule IDs in the order they were written into the inner maps of a mnt ns
+type appliedRuleIDs struct {
+	files     []string
+	processes []string
+	networks  []string
+	mounts    []string
+	ptrace    string
+}
+
+// ruleIDStore is used to resolve the rule index of violation events back to the policy rules
+type ruleIDStore struct {
+	lock sync.RWMutex
+	ids  map[uint32]*appliedRuleIDs // <mntNsID: appliedRuleIDs>
+}
+
+func newRuleIDStore() *ruleIDStore {
+	return &ruleIDStore{
+		ids: make(map[uint32]*appliedRuleIDs),
+	}
+}
+
+func (s *ruleIDStore) save(nsID uint32, bpfContent *varmor.BpfContent) {
+	ids := appliedRuleIDs{}
+	for _, file := range bpfContent.Files {
+		ids.files = append(ids.files, file.RuleID)
+	}
+	for _, process := range bpfContent.Processes {
+		ids.processes = append(ids.processes, process.RuleID)
+	}
+	for _, network := range bpfContent.Networks {
+		ids.networks = append(ids.networks, network.RuleID)
+	}
+	for _, mount := range bpfContent.Mounts {
+		ids.mounts = append(ids.mounts, mount.RuleID)
+	}
+	if bpfContent.Ptrace != nil {
+		ids.ptrace = bpfContent.Ptrace.RuleID
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ids[nsID] = &ids
+}
+
+func (s *ruleIDStore) delete(nsID uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.ids, nsID)
+}
+
+// resolve returns the rule ID of the rule that matched the violation, it returns an empty string if unknown
+func (s *ruleIDStore) resolve(nsID uint32, ruleType uint32, index uint32) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ids, ok := s.ids[nsID]
+	if !ok {
+		return ""
+	}
+
+	var list []string
+	switch ruleType {
+	case ptraceRuleType:
+		return ids.ptrace
+	case fileRuleType:
+		list = ids.files
+	case bprmRuleType:
+		list = ids.processes
+	case networkRuleType:
+		list = ids.networks
+	case mountRuleType:
+		list = ids.mounts
+	}
+
+	if index == noRuleIndex || int(index) >= len(list) {
+		return ""
+	}
+	return list[index]
+}
+
+// createViolationReader opens a perf event reader on the violation map if the BPF program supports it
+func (enforcer *BpfEnforcer) createViolationReader() error {
+	if enforcer.violations == nil {
+		return nil
+	}
+
+	reader, err := perf.NewReader(enforcer.violations, 4096*16)
+	if err != nil {
+		return fmt.Errorf("perf.NewReader() failed: %v", err)
+	}
+	enforcer.violationReader = reader
+	return nil
+}
+
+// readViolations reads the violation events and sends them to the event handler until the reader is closed
+func (enforcer *BpfEnforcer) readViolations() {
+	var event bpfViolationEvent
+	for {
+		record, err := enforcer.violationReader.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				enforcer.log.V(3).Info("violation reader is closed")
+				return
+			}
+			enforcer.log.Error(err, "reading from perf buffer failed")
+			continue
+		}
+
+		if record.LostSamples != 0 {
+			enforcer.log.Error(fmt.Errorf("perf buffer is full, some violation events were dropped"), "dropped count", record.LostSamples)
+			continue
+		}
+
+		if err := parseViolationEvent(record.RawSample, &event); err != nil {
+			enforcer.log.Error(err, "parsing violation event failed")
+			continue
+		}
+
+		select {
+		case enforcer.violationCh <- event:
+		default:
+			enforcer.log.V(3).Info("the violation channel is full, drop the event", "mnt ns id", event.MntNsID)
+		}
+	}
+}
+
+// handleViolation resolves the violation event back to the policy rule, and enriches it with the Kubernetes
+// metadata of the container before reporting it.
+func (enforcer *BpfEnforcer) handleViolation(event *bpfViolationEvent) {
+	ruleID := enforcer.ruleIDs.resolve(event.MntNsID, event.RuleType, event.RuleIndex)
+	enforcer.enrichViolation(event, ruleID, time.Now())
+}
+
+// emitViolation sends the violation to the sink, or to the agent for aggregation
+func (enforcer *BpfEnforcer) emitViolation(violation varmortypes.Violation) {
+	enforcer.log.Info("violation event, the operation was denied",
+		"profile name", violation.ProfileName,
+		"pod namespace", violation.PodNamespace,
+		"pod name", violation.PodName,
+		"container name", violation.ContainerName,
+		"container id", violation.ContainerID,
+		"rule type", violation.RuleType,
+		"rule id", violation.RuleID,
+		"permissions", violation.Permissions,
+		"capability", CapabilityName(violation.Capability),
+		"syscall", violation.Syscall,
+		"pid", violation.PID,
+		"mnt ns id", violation.MntNsID,
+		"count", violation.Count)
+
+	if enforcer.opts.ViolationSink != nil {
+		enforcer.opts.ViolationSink(violation)
+		return
+	}
+
+	select {
+	case enforcer.ViolationCh <- violation:
+	default:
+		enforcer.log.V(3).Info("the violation report channel is full, drop the violation", "profile name", violation.ProfileName)
+	}
+}
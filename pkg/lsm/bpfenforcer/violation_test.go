@@ -0,0 +1,58 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func Test_parseViolationEvent(t *testing.T) {
+	// The event with the context
+	var buf bytes.Buffer
+	err := binary.Write(&buf, binary.LittleEndian, bpfViolationEvent{
+		MntNsID:    1,
+		Tgid:       100,
+		RuleType:   capabilityRuleType,
+		RuleIndex:  noRuleIndex,
+		Capability: 13,
+		Syscall:    41,
+	})
+	assert.NilError(t, err)
+
+	var event bpfViolationEvent
+	err = parseViolationEvent(buf.Bytes(), &event)
+	assert.NilError(t, err)
+	violation := newViolation(&event, "", time.Now(), nil)
+	assert.Equal(t, violation.Capability, int32(13))
+	assert.Equal(t, CapabilityName(violation.Capability), "net_raw")
+	assert.Equal(t, violation.Syscall, int32(41))
+
+	// The event without the context
+	err = parseViolationEvent(buf.Bytes()[:violationHeaderSize], &event)
+	assert.NilError(t, err)
+	assert.Equal(t, event.Tgid, uint32(100))
+	violation = newViolation(&event, "", time.Now(), nil)
+	assert.Equal(t, violation.Capability, int32(-1))
+	assert.Equal(t, CapabilityName(violation.Capability), "")
+	assert.Equal(t, violation.Syscall, int32(-1))
+
+	err = parseViolationEvent(buf.Bytes()[:8], &event)
+	assert.ErrorContains(t, err, "too short")
+}
@@ -0,0 +1,282 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// warmUpTTL is the maximum time that the staged inner maps of a container wait for its task to be created
+	warmUpTTL = 10 * time.Minute
+	// warmUpCheckInterval is the interval of releasing the staged inner maps that expired
+	warmUpCheckInterval = 30 * time.Second
+	// maxWarmUps caps the containers whose inner maps are staged, since they consume the memory of the node
+	maxWarmUps = 256
+)
+
+// errTooManyWarmUps is returned when the inner maps of too many containers have been staged
+var errTooManyWarmUps = errors.New("too many containers are warmed up")
+
+var (
+	warmedContainers = new(expvar.Int)
+	warmUpHits       = new(expvar.Int)
+	warmUpMisses     = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("warmed_containers", warmedContainers)
+	metrics.Set("warm_up_hits_total", warmUpHits)
+	metrics.Set("warm_up_misses_total", warmUpMisses)
+}
+
+// warmUp is the BPF profile staged for a container whose task hasn't been created yet. The content is the profile
+// that the container would be enforced with, it's compared with the one when the task is created, so the staged
+// inner maps are only used if nothing changed in between.
+type warmUp struct {
+	profileName string
+	baseName    string
+	// content is the layered and excepted profile that was staged
+	content  varmor.BpfContent
+	changes  []*mapChange
+	stagedAt time.Time
+}
+
+// warmUpStore keeps the staged BPF profiles of the containers, keyed by the pod UID and the container name.
+// The nil store keeps nothing.
+type warmUpStore struct {
+	lock    sync.Mutex
+	warmUps map[string]*warmUp // <podUID/containerName: warmUp>
+}
+
+func newWarmUpStore() *warmUpStore {
+	return &warmUpStore{
+		warmUps: make(map[string]*warmUp),
+	}
+}
+
+func warmUpKey(podUID string, containerName string) string {
+	return podUID + "/" + containerName
+}
+
+func (s *warmUpStore) has(key string) bool {
+	if s == nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.warmUps[key]
+	return ok
+}
+
+// put saves the staged profile of the container, it replaces and releases the previous one
+func (s *warmUpStore) put(key string, w *warmUp) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if previous, ok := s.warmUps[key]; ok {
+		closeMapChanges(previous.changes)
+	} else if len(s.warmUps) >= maxWarmUps {
+		return errTooManyWarmUps
+	}
+	s.warmUps[key] = w
+	warmedContainers.Set(int64(len(s.warmUps)))
+	return nil
+}
+
+// take removes the staged profile of the container and returns it, the caller must release its changes
+func (s *warmUpStore) take(key string) *warmUp {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	w, ok := s.warmUps[key]
+	if !ok {
+		return nil
+	}
+	delete(s.warmUps, key)
+	warmedContainers.Set(int64(len(s.warmUps)))
+	return w
+}
+
+// discard releases the staged profiles that match the filter
+func (s *warmUpStore) discard(match func(key string, w *warmUp) bool) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, w := range s.warmUps {
+		if match(key, w) {
+			closeMapChanges(w.changes)
+			delete(s.warmUps, key)
+		}
+	}
+	warmedContainers.Set(int64(len(s.warmUps)))
+}
+
+// discardPod releases the staged profiles of the containers of the pod
+func (s *warmUpStore) discardPod(podUID string) {
+	s.discard(func(key string, _ *warmUp) bool { return strings.HasPrefix(key, podUID+"/") })
+}
+
+// discardProfile releases the staged profiles that were built from the profile, either as the profile of the
+// containers or as the base profile
+func (s *warmUpStore) discardProfile(profileName string) {
+	s.discard(func(_ string, w *warmUp) bool { return w.profileName == profileName || w.baseName == profileName })
+}
+
+// expire releases the staged profiles whose containers weren't created in time
+func (s *warmUpStore) expire(now time.Time) {
+	s.discard(func(_ string, w *warmUp) bool { return now.Sub(w.stagedAt) >= warmUpTTL })
+}
+
+// WarmUp stages the inner maps of the BPF profile for the container whose task hasn't been created yet, e.g. the
+// image of the pod is being pulled. When the task is created, only the entries of the outer maps are inserted if the
+// profile hasn't changed in between, which shrinks the window that the container runs unconfined. The PodUID and the
+// ContainerName of the info are required, and the profile is resolved with the pod annotations and labels of it.
+//
+// The profiles with the regular expressions or the SHA256 of the files are skipped, since they are expanded against
+// the filesystem of the container. The staged maps are released when the pod is deleted with DiscardWarmUps, the
+// profile changes, or the task isn't created within 10 minutes. It can be called concurrently with the container
+// events.
+func (enforcer *BpfEnforcer) WarmUp(info varmortypes.ContainerInfo) error {
+	if info.PodUID == "" || info.ContainerName == "" || info.Sandbox {
+		return fmt.Errorf("the pod uid and the container name are required")
+	}
+
+	if !enforcer.acquireShared() {
+		return errEnforcerClosed
+	}
+	defer enforcer.releaseShared()
+
+	profileName, baseName, ok := enforcer.opts.resolveProfiles(info)
+	if !ok {
+		return nil
+	}
+
+	key := warmUpKey(info.PodUID, info.ContainerName)
+	if enforcer.warmUps.has(key) || enforcer.isContainerEnforced(info.PodUID, info.ContainerName) {
+		return nil
+	}
+
+	// The maps aren't allocated ahead of time when the node is under memory pressure
+	if pressure, _ := enforcer.pressure.underPressure(); pressure {
+		return errNodePressure
+	}
+
+	enforcer.cacheLock.Lock()
+	profile, ok := enforcer.bpfProfileCache[profileName]
+	enforcer.cacheLock.Unlock()
+	if !ok {
+		return fmt.Errorf("%w (profile name: %s)", errProfileNotExist, profileName)
+	}
+
+	// The same as expandProfile, except that nothing is expanded against the filesystem of the container
+	content, _ := enforcer.layerBaseProfile(baseName, profile.bpfContent)
+	content = exceptRules(content, info.PodAnnotations)
+	if len(content.RegexFiles) != 0 || len(content.HashProcesses) != 0 {
+		enforcer.log.V(3).Info("the BPF profile can't be staged before the container is created", "profile name", profileName,
+			"pod uid", info.PodUID, "container name", info.ContainerName)
+		return nil
+	}
+
+	// The mnt ns of the container is unknown, it only names the inner maps
+	changes, err := enforcer.stageProfile(0, content)
+	if err != nil {
+		return fmt.Errorf("failed to stage the BPF profile: %w", err)
+	}
+
+	err = enforcer.warmUps.put(key, &warmUp{
+		profileName: profileName,
+		baseName:    baseName,
+		content:     content,
+		changes:     changes,
+		stagedAt:    time.Now(),
+	})
+	if err != nil {
+		closeMapChanges(changes)
+		return err
+	}
+
+	enforcer.log.V(3).Info("the BPF profile was staged for the container", "profile name", profileName,
+		"pod namespace", info.PodNamespace, "pod name", info.PodName, "container name", info.ContainerName)
+	return nil
+}
+
+// DiscardWarmUps releases the inner maps staged for the containers of the pod, e.g. the pod was deleted before its
+// containers were created
+func (enforcer *BpfEnforcer) DiscardWarmUps(podUID string) {
+	enforcer.warmUps.discardPod(podUID)
+}
+
+// isContainerEnforced checks whether a task of the container of the pod has been created
+func (enforcer *BpfEnforcer) isContainerEnforced(podUID string, containerName string) bool {
+	enforcer.cacheLock.Lock()
+	defer enforcer.cacheLock.Unlock()
+
+	for _, info := range enforcer.containerInfos {
+		if info.PodUID == podUID && info.ContainerName == containerName && !info.Sandbox {
+			return true
+		}
+	}
+	return false
+}
+
+// applyWarmedProfile commits the inner maps staged for the container if the profile that it's enforced with is the
+// same as the staged one. It returns false if nothing was staged, or the staged maps can't be used, then the profile
+// needs to be applied as usual.
+func (enforcer *BpfEnforcer) applyWarmedProfile(containerID string, id enforceID, bpfContent varmor.BpfContent) bool {
+	enforcer.cacheLock.Lock()
+	info, ok := enforcer.containerInfos[containerID]
+	enforcer.cacheLock.Unlock()
+	if !ok || info.PodUID == "" || info.ContainerName == "" || info.Sandbox {
+		return false
+	}
+
+	w := enforcer.warmUps.take(warmUpKey(info.PodUID, info.ContainerName))
+	if w == nil {
+		return false
+	}
+	defer closeMapChanges(w.changes)
+
+	if !reflect.DeepEqual(w.content, bpfContent) {
+		warmUpMisses.Add(1)
+		enforcer.log.V(3).Info("the staged BPF profile is outdated", "container id", containerID, "profile name", w.profileName)
+		return false
+	}
+
+	err := enforcer.commitChanges(id.mntNsID, &w.content, w.changes, false)
+	if err != nil {
+		warmUpMisses.Add(1)
+		enforcer.log.Error(err, "failed to commit the staged BPF profile, apply it again", "container id", containerID)
+		return false
+	}
+
+	warmUpHits.Add(1)
+	return true
+}
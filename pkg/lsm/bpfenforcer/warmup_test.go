@@ -0,0 +1,120 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_warmUpStore(t *testing.T) {
+	store := newWarmUpStore()
+	now := time.Unix(1700000000, 0)
+
+	assert.NilError(t, store.put(warmUpKey("uid-1", "app"), &warmUp{profileName: "varmor-demo-web", stagedAt: now}))
+	assert.NilError(t, store.put(warmUpKey("uid-1", "sidecar"), &warmUp{profileName: "varmor-demo-sidecar", baseName: "varmor-cluster-base", stagedAt: now}))
+	assert.NilError(t, store.put(warmUpKey("uid-2", "app"), &warmUp{profileName: "varmor-demo-web", stagedAt: now.Add(warmUpTTL)}))
+	assert.Equal(t, warmedContainers.Value(), int64(3))
+
+	// The staged profile is taken once
+	assert.Equal(t, store.take(warmUpKey("uid-1", "app")).profileName, "varmor-demo-web")
+	assert.Assert(t, store.take(warmUpKey("uid-1", "app")) == nil)
+
+	// The staged profiles layered on the base profile are outdated when the base profile changes
+	store.discardProfile("varmor-cluster-base")
+	assert.Assert(t, !store.has(warmUpKey("uid-1", "sidecar")))
+
+	store.expire(now.Add(warmUpTTL))
+	assert.Assert(t, store.has(warmUpKey("uid-2", "app")))
+	store.discardPod("uid-2")
+	assert.Equal(t, len(store.warmUps), 0)
+	assert.Equal(t, warmedContainers.Value(), int64(0))
+
+	for i := 0; i < maxWarmUps; i++ {
+		assert.NilError(t, store.put(warmUpKey(fmt.Sprintf("uid-%d", i), "app"), &warmUp{}))
+	}
+	err := store.put(warmUpKey("uid-new", "app"), &warmUp{})
+	assert.Assert(t, errors.Is(err, errTooManyWarmUps))
+	// The staged profile of the container can be replaced anyway
+	assert.NilError(t, store.put(warmUpKey("uid-0", "app"), &warmUp{}))
+	store.discard(func(string, *warmUp) bool { return true })
+
+	var nilStore *warmUpStore
+	assert.Assert(t, nilStore.take(warmUpKey("uid-1", "app")) == nil)
+}
+
+func Test_applyWarmedProfileOutdated(t *testing.T) {
+	enforcer := &BpfEnforcer{
+		containerInfos: map[string]varmortypes.ContainerInfo{
+			"c1": {ContainerID: "c1", ContainerName: "app", PodUID: "uid-1"},
+		},
+		warmUps: newWarmUpStore(),
+		log:     logr.Discard(),
+	}
+	staged := varmor.BpfContent{Capabilities: 1 << 21}
+	assert.NilError(t, enforcer.warmUps.put(warmUpKey("uid-1", "app"), &warmUp{content: staged}))
+
+	// Nothing was staged for the container
+	assert.Assert(t, !enforcer.applyWarmedProfile("c2", enforceID{mntNsID: 4026532002}, staged))
+
+	// The profile changed after it was staged
+	misses := warmUpMisses.Value()
+	assert.Assert(t, !enforcer.applyWarmedProfile("c1", enforceID{mntNsID: 4026532001}, varmor.BpfContent{Capabilities: 1 << 12}))
+	assert.Equal(t, warmUpMisses.Value(), misses+1)
+	assert.Assert(t, !enforcer.warmUps.has(warmUpKey("uid-1", "app")))
+}
+
+func Test_WarmUp(t *testing.T) {
+	enforcer := &BpfEnforcer{
+		opts:            Options{ProfileResolver: resolveProfileFromAnnotations},
+		bpfProfileCache: map[string]bpfProfile{},
+		containerInfos: map[string]varmortypes.ContainerInfo{
+			"c1": {ContainerID: "c1", ContainerName: "app", PodUID: "uid-1"},
+		},
+		warmUps: newWarmUpStore(),
+		log:     logr.Discard(),
+	}
+	annotations := map[string]string{"container.bpf.security.beta.varmor.org/app": "localhost/varmor-demo-web"}
+
+	err := enforcer.WarmUp(varmortypes.ContainerInfo{PodUID: "uid-2"})
+	assert.ErrorContains(t, err, "required")
+
+	// The task of the container has been created
+	err = enforcer.WarmUp(varmortypes.ContainerInfo{PodUID: "uid-1", ContainerName: "app", PodAnnotations: annotations})
+	assert.NilError(t, err)
+
+	err = enforcer.WarmUp(varmortypes.ContainerInfo{PodUID: "uid-2", ContainerName: "app", PodAnnotations: annotations})
+	assert.Assert(t, errors.Is(err, errProfileNotExist))
+
+	// The rules with regular expressions are expanded when the container is created
+	enforcer.bpfProfileCache["varmor-demo-web"] = bpfProfile{bpfContent: varmor.BpfContent{
+		RegexFiles: []varmor.RegexFileContent{{}},
+	}}
+	err = enforcer.WarmUp(varmortypes.ContainerInfo{PodUID: "uid-2", ContainerName: "app", PodAnnotations: annotations})
+	assert.NilError(t, err)
+	assert.Equal(t, len(enforcer.warmUps.warmUps), 0)
+
+	enforcer.closed = true
+	err = enforcer.WarmUp(varmortypes.ContainerInfo{PodUID: "uid-2", ContainerName: "app"})
+	assert.Equal(t, err, errEnforcerClosed)
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"expvar"
+	"hash/fnv"
+	"sync"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+var workerQueueLength = new(expvar.Int)
+
+func init() {
+	metrics.Set("worker_queue_length", workerQueueLength)
+}
+
+// workerPool handles the container events with several workers on the nodes with lots of containers. The events
+// of a container are always dispatched to the same worker, so they're handled in the order they were received.
+type workerPool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// newWorkerPool starts the workers, the capacity is shared by the queues of them
+func newWorkerPool(workers int, capacity int) *workerPool {
+	capacity /= workers
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	p := &workerPool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), capacity)
+		p.wg.Add(1)
+		go func(queue chan func()) {
+			defer p.wg.Done()
+			for task := range queue {
+				workerQueueLength.Add(-1)
+				task()
+			}
+		}(p.queues[i])
+	}
+	return p
+}
+
+// dispatch queues the task to the worker of the key, it blocks if the queue of the worker is full
+func (p *workerPool) dispatch(key string, task func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	workerQueueLength.Add(1)
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- task
+}
+
+// wait blocks until the tasks queued before it are handled
+func (p *workerPool) wait() {
+	var wg sync.WaitGroup
+	wg.Add(len(p.queues))
+	for _, queue := range p.queues {
+		workerQueueLength.Add(1)
+		queue <- wg.Done
+	}
+	wg.Wait()
+}
+
+// stop handles the queued tasks and stops the workers
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// handleTaskEvent handles the container event with the workers if they're enabled, or with the event handler
+func (enforcer *BpfEnforcer) handleTaskEvent(info varmortypes.ContainerInfo, handler func(varmortypes.ContainerInfo)) {
+	if enforcer.workers == nil {
+		enforcer.do(func() { handler(info) })
+		return
+	}
+	enforcer.workers.dispatch(info.ContainerID, func() {
+		enforcer.doShared(func() { handler(info) })
+	})
+}
+
+// waitTaskEvents blocks until the container events dispatched to the workers are handled
+func (enforcer *BpfEnforcer) waitTaskEvents() {
+	if enforcer.workers != nil {
+		enforcer.workers.wait()
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfenforcer
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_workerPool(t *testing.T) {
+	p := newWorkerPool(4, 16)
+
+	var lock sync.Mutex
+	events := make(map[string][]int)
+	for i := 0; i < 100; i++ {
+		for c := 0; c < 10; c++ {
+			key := fmt.Sprintf("container-%d", c)
+			seq := i
+			p.dispatch(key, func() {
+				lock.Lock()
+				events[key] = append(events[key], seq)
+				lock.Unlock()
+			})
+		}
+	}
+
+	// The events of each container are handled in order
+	p.wait()
+	lock.Lock()
+	for key, seqs := range events {
+		assert.Equal(t, len(seqs), 100, key)
+		for i, seq := range seqs {
+			assert.Equal(t, seq, i, key)
+		}
+	}
+	lock.Unlock()
+
+	var handled atomic.Int32
+	p.dispatch("container-0", func() { handled.Add(1) })
+	p.stop()
+	assert.Equal(t, handled.Load(), int32(1))
+}
+
+func Test_handleTaskEventWithWorkers(t *testing.T) {
+	enforcer := &BpfEnforcer{workers: newWorkerPool(2, 8)}
+
+	var handled atomic.Int32
+	handler := func(info varmortypes.ContainerInfo) { handled.Add(1) }
+	for i := 0; i < 10; i++ {
+		enforcer.handleTaskEvent(varmortypes.ContainerInfo{ContainerID: fmt.Sprintf("%d", i)}, handler)
+	}
+	enforcer.waitTaskEvents()
+	assert.Equal(t, handled.Load(), int32(10))
+
+	// The events are skipped after the enforcer was closed
+	enforcer.closed = true
+	enforcer.handleTaskEvent(varmortypes.ContainerInfo{ContainerID: "0"}, handler)
+	enforcer.workers.stop()
+	assert.Equal(t, handled.Load(), int32(10))
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package landlock implements the Landlock enforcer. Landlock is a stackable LSM that can be used by unprivileged
+// processes to restrict themselves, so the rulesets are applied by the launcher which wraps the entrypoint of the
+// target containers, instead of being loaded into the kernel by the agent.
+package landlock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// AccessFile is the set of the access rights that can be granted to the regular files
+	AccessFile = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE
+
+	// accessABIv1 is the set of the access rights supported by the first ABI (Linux 5.13)
+	accessABIv1 = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+)
+
+// Rule denies the access rights on the path, and all the files beneath it if it's a directory
+type Rule struct {
+	Path   string `json:"path"`
+	Access uint64 `json:"access"`
+}
+
+// Ruleset is the content of the Landlock profile. Landlock only supports the allow rules, so the launcher
+// expands the deny rules into the allow rules of their sibling paths before restricting itself.
+type Ruleset struct {
+	// Rules are the deny rules of the profile, the profile that has no rule allows everything.
+	Rules []Rule `json:"rules,omitempty"`
+	// Exceptions grant the access rights on the paths and all the files beneath them, even if they are denied
+	// by the rules.
+	Exceptions []Rule `json:"exceptions,omitempty"`
+}
+
+// HandledAccess returns the access rights denied by any rule of the ruleset
+func (r *Ruleset) HandledAccess() uint64 {
+	var access uint64
+	for _, rule := range r.Rules {
+		access |= rule.Access
+	}
+	return access
+}
+
+// ABIVersion returns the version of the Landlock ABI supported by the kernel. It returns an error if the
+// Landlock LSM isn't supported or enabled.
+func ABIVersion() (int, error) {
+	version, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(version), nil
+}
+
+// SupportedAccess returns the access rights supported by the ABI version. The rights that aren't supported are
+// dropped from the ruleset by the launcher, so the rules are enforced as far as possible on the old kernels.
+func SupportedAccess(abi int) uint64 {
+	switch {
+	case abi <= 0:
+		return 0
+	case abi == 1:
+		return accessABIv1
+	case abi == 2:
+		return accessABIv1 | unix.LANDLOCK_ACCESS_FS_REFER
+	default:
+		return accessABIv1 | unix.LANDLOCK_ACCESS_FS_REFER | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+}
+
+// EncodeRuleset encodes the ruleset into the content of the Landlock profile
+func EncodeRuleset(ruleset *Ruleset) (string, error) {
+	r, err := json.Marshal(ruleset)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(r), nil
+}
+
+// LoadRuleset reads the ruleset saved by the agent
+func LoadRuleset(fileName string) (*Ruleset, error) {
+	c, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var ruleset Ruleset
+	err = json.Unmarshal(c, &ruleset)
+	if err != nil {
+		return nil, err
+	}
+	return &ruleset, nil
+}
+
+func SaveLandlockProfile(fileName string, content string) error {
+	c, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return err
+	}
+
+	// The profile is replaced atomically, so the launchers never read a partial one
+	tmpFileName := fileName + ".tmp"
+	err = os.WriteFile(tmpFileName, c, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFileName, fileName)
+}
+
+func LandlockProfileExist(profilePath string) bool {
+	_, err := os.Stat(profilePath)
+	return !os.IsNotExist(err)
+}
+
+func RemoveLandlockProfile(profilePath string) error {
+	return os.Remove(profilePath)
+}
+
+func RemoveAllLandlockProfiles(profileDir string) {
+	prefix := filepath.Join(profileDir, "varmor-")
+
+	filepath.WalkDir(profileDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && len(path) > len(prefix) && path[:len(prefix)] == prefix {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+func createRuleset(handledAccess uint64) (int, error) {
+	attr := unix.LandlockRulesetAttr{Access_fs: handledAccess}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func addPathBeneathRule(rulesetFd int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func restrictSelf(rulesetFd int) error {
+	_, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/sys/unix"
+)
+
+// ancestors returns the ancestor directories of the absolute path, from the root directory to the parent
+func ancestors(path string) []string {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "/" {
+			break
+		}
+	}
+	return dirs
+}
+
+// expandRules expands the deny rules into the allow rules of the paths in the filesystem located at root.
+// For each access right, the access right is granted to the entries of the ancestor directories of the denied
+// paths, unless the entry is denied or is an ancestor of the denied paths itself.
+//
+// Note:
+// The ancestor directories aren't granted the access rights, so the entries created in them after the
+// restriction are denied too.
+func expandRules(root string, rules []Rule) map[string]uint64 {
+	denied := make(map[string]uint64)
+	dirs := make(map[string]uint64)
+
+	for _, rule := range rules {
+		path := filepath.Clean("/" + rule.Path)
+		denied[path] |= rule.Access
+		if path == "/" {
+			continue
+		}
+		for _, dir := range ancestors(path) {
+			dirs[dir] |= rule.Access
+		}
+	}
+
+	allowed := make(map[string]uint64)
+	for dir, access := range dirs {
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			// The ancestor directory that doesn't exist is denied by its own ancestor
+			continue
+		}
+
+		for _, entry := range entries {
+			// The symlinks are skipped, since the rule would be attached to the target of the symlink which
+			// may be denied. The targets are covered by the rules of their own ancestors.
+			if (entry.Type() & os.ModeSymlink) != 0 {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			grant := access &^ (denied[path] | dirs[path])
+			if !entry.IsDir() {
+				grant &= AccessFile
+			}
+			if grant != 0 {
+				allowed[filepath.Join(root, path)] |= grant
+			}
+		}
+	}
+	return allowed
+}
+
+// Restrict restricts the calling thread with the ruleset. The Landlock domain and the no_new_privs flag are set on
+// the calling thread only, so the caller must lock the OS thread and execute the target program on it.
+//
+// The access rights that aren't supported by the kernel are dropped, so the rules are enforced as far as possible.
+// It returns an error if the Landlock LSM isn't supported.
+func Restrict(ruleset *Ruleset) error {
+	abi, err := ABIVersion()
+	if err != nil {
+		return fmt.Errorf("the Landlock LSM isn't supported: %w", err)
+	}
+
+	supported := SupportedAccess(abi)
+	handled := ruleset.HandledAccess() & supported
+	if handled == 0 {
+		return nil
+	}
+
+	var rules []Rule
+	for _, rule := range ruleset.Rules {
+		if access := rule.Access & supported; access != 0 {
+			rules = append(rules, Rule{Path: rule.Path, Access: access})
+		}
+	}
+	allowed := expandRules("/", rules)
+
+	for _, exception := range ruleset.Exceptions {
+		// The exceptions that don't exist are skipped, the symlinks are resolved since the rules are attached
+		// to the inodes
+		path, err := filepath.EvalSymlinks(exception.Path)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		access := exception.Access & handled
+		if !info.IsDir() {
+			access &= AccessFile
+		}
+		if access != 0 {
+			allowed[path] |= access
+		}
+	}
+
+	// The reparenting of the files is always denied if the REFER access right isn't handled, so it's handled and
+	// granted to the whole filesystem on the kernels that support it.
+	if (supported & unix.LANDLOCK_ACCESS_FS_REFER) != 0 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+		allowed["/"] |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+
+	rulesetFd, err := createRuleset(handled)
+	if err != nil {
+		return fmt.Errorf("landlock_create_ruleset() failed: %w", err)
+	}
+	defer unix.Close(rulesetFd)
+
+	paths := make([]string, 0, len(allowed))
+	for path := range allowed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		err = addPathBeneathRule(rulesetFd, path, allowed[path])
+		if err != nil {
+			// The entries removed during the expansion are skipped
+			if errors.Is(err, unix.ENOENT) {
+				continue
+			}
+			return fmt.Errorf("landlock_add_rule() failed (path: %s): %w", path, err)
+		}
+	}
+
+	err = unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+	if err != nil {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS) failed: %w", err)
+	}
+
+	err = restrictSelf(rulesetFd)
+	if err != nil {
+		return fmt.Errorf("landlock_restrict_self() failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landlock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+)
+
+func Test_expandRules(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"etc/cron.d", "usr/bin", "var/log"} {
+		assert.NilError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	for _, file := range []string{"etc/shadow", "etc/passwd", "usr/bin/sh", "usr/bin/curl"} {
+		assert.NilError(t, os.WriteFile(filepath.Join(root, file), nil, 0644))
+	}
+	assert.NilError(t, os.Symlink("/etc/shadow", filepath.Join(root, "shadow")))
+
+	write := uint64(unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE)
+	mkdir := uint64(unix.LANDLOCK_ACCESS_FS_MAKE_DIR)
+	exec := uint64(unix.LANDLOCK_ACCESS_FS_EXECUTE)
+
+	allowed := expandRules(root, []Rule{
+		{Path: "/etc/shadow", Access: write},
+		{Path: "/etc/cron.d", Access: write | mkdir},
+		{Path: "/usr/bin/curl", Access: exec},
+	})
+
+	expected := map[string]uint64{
+		// The entries of the root directory
+		filepath.Join(root, "usr"): write | mkdir,
+		filepath.Join(root, "var"): write | mkdir | exec,
+		filepath.Join(root, "etc"): exec,
+		// The entries of /etc
+		filepath.Join(root, "etc/passwd"): write,
+		// The entries of /usr and /usr/bin
+		filepath.Join(root, "usr/bin/sh"): exec,
+	}
+	assert.DeepEqual(t, allowed, expected)
+}
+
+func Test_ancestors(t *testing.T) {
+	assert.DeepEqual(t, ancestors("/etc/cron.d/job"), []string{"/etc/cron.d", "/etc", "/"})
+	assert.DeepEqual(t, ancestors("/etc"), []string{"/"})
+}
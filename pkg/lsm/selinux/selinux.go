@@ -0,0 +1,87 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selinux manages the CIL policy modules of the SELinux enforcer on the nodes with semodule.
+package selinux
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// IsSELinuxEnabled checks whether the SELinux LSM is enabled. The policy modules are only enforced in the
+// enforcing mode, but they can also be installed in the permissive mode.
+func IsSELinuxEnabled() bool {
+	_, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil
+}
+
+func semodule(args ...string) (string, error) {
+	out, err := exec.Command("semodule", args...).CombinedOutput()
+	return string(out), err
+}
+
+// SaveSELinuxProfile decodes the CIL policy module and saves it into the file, the file name must end with ".cil"
+func SaveSELinuxProfile(fileName string, content string) error {
+	c, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, c, 0600)
+}
+
+// InstallSELinuxProfile installs or updates the policy module, the name of the module is the base name of the file
+func InstallSELinuxProfile(fileName string) (string, error) {
+	return semodule("-i", fileName)
+}
+
+// UninstallSELinuxProfile removes the policy module with the name
+func UninstallSELinuxProfile(moduleName string) (string, error) {
+	return semodule("-r", moduleName)
+}
+
+// IsSELinuxProfileInstalled checks whether the policy module with the name is installed
+func IsSELinuxProfileInstalled(moduleName string) (bool, error) {
+	out, err := exec.Command("semodule", "-l").Output()
+	if err != nil {
+		return false, err
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 0 && fields[0] == moduleName {
+			return true, nil
+		}
+	}
+	return false, s.Err()
+}
+
+func RemoveSELinuxProfile(fileName string) error {
+	return os.Remove(fileName)
+}
+
+// UninstallAllSELinuxProfiles removes the policy modules saved in the directory, along with the files
+func UninstallAllSELinuxProfiles(profileDir string) {
+	files, _ := filepath.Glob(filepath.Join(profileDir, "varmor_*.cil"))
+	for _, file := range files {
+		UninstallSELinuxProfile(strings.TrimSuffix(filepath.Base(file), ".cil"))
+		os.Remove(file)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policytester loads a BPF profile into a scratch BPF enforcer on a dev machine, and evaluates
+// synthetic operations against it. It lets the policy authors write Go tests to assert that their rules
+// behave as intended. The BPF LSM must be enabled, and the tests must be run as root.
+//
+//	tester, err := policytester.NewTester()
+//	if err != nil {
+//		t.Skip(err)
+//	}
+//	defer tester.Close()
+//
+//	err = tester.Load(bpfContent)
+//	assert.NilError(t, err)
+//	assert.Assert(t, policytester.IsDenied(tester.Open("/etc/shadow", unix.O_RDONLY)))
+//	assert.NilError(t, tester.Connect("tcp", "10.0.0.1:80"))
+package policytester
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	"github.com/bytedance/vArmor/pkg/lsm/bpfenforcer"
+)
+
+// connectTimeout is the timeout of the synthetic connections
+const connectTimeout = time.Second
+
+// Tester runs the synthetic operations on a dedicated thread which is moved into a scratch mnt ns,
+// so the BPF profile loaded by it only affects the operations.
+type Tester struct {
+	enforcer *bpfenforcer.BpfEnforcer
+	tid      uint32
+	mntNsID  uint32
+	loaded   bool
+	taskCh   chan func()
+}
+
+// NewTester loads the BPF programs of vArmor into the kernel, and creates the scratch mnt ns
+func NewTester() (*Tester, error) {
+	enforcer, err := bpfenforcer.NewBpfEnforcer(0, 0, logr.Discard())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the BPF enforcer: %w", err)
+	}
+
+	t := Tester{
+		enforcer: enforcer,
+		taskCh:   make(chan func()),
+	}
+
+	errCh := make(chan error)
+	go t.run(errCh)
+	if err := <-errCh; err != nil {
+		enforcer.Close()
+		return nil, fmt.Errorf("failed to create the scratch mnt ns: %w", err)
+	}
+
+	return &t, nil
+}
+
+func (t *Tester) run(errCh chan<- error) {
+	// The thread is never unlocked, so it's terminated along with the goroutine instead of being reused
+	runtime.LockOSThread()
+
+	if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+		errCh <- err
+		return
+	}
+	t.tid = uint32(unix.Gettid())
+	errCh <- nil
+
+	for task := range t.taskCh {
+		task()
+	}
+}
+
+// Load applies the BPF profile to the scratch mnt ns, the previous one is replaced
+func (t *Tester) Load(bpfContent varmor.BpfContent) error {
+	mntNsID, err := t.enforcer.ApplyBpfProfileToProcess(t.tid, bpfContent)
+	if err != nil {
+		return err
+	}
+	t.mntNsID = mntNsID
+	t.loaded = true
+	return nil
+}
+
+// Unload removes the BPF profile from the scratch mnt ns
+func (t *Tester) Unload() error {
+	if !t.loaded {
+		return nil
+	}
+	err := t.enforcer.DeleteBpfProfileOfMntNs(t.mntNsID)
+	if err != nil {
+		return err
+	}
+	t.loaded = false
+	return nil
+}
+
+// Run runs the function in the scratch mnt ns. The function must not start new goroutines to perform
+// the operations, since they may be scheduled to the other threads.
+func (t *Tester) Run(f func() error) error {
+	errCh := make(chan error, 1)
+	t.taskCh <- func() {
+		errCh <- f()
+	}
+	return <-errCh
+}
+
+// Open opens the file with the flags, e.g. unix.O_RDONLY and unix.O_WRONLY
+func (t *Tester) Open(path string, flags int) error {
+	return t.Run(func() error {
+		fd, err := unix.Open(path, flags, 0644)
+		if err != nil {
+			return err
+		}
+		return unix.Close(fd)
+	})
+}
+
+// Exec executes the program with the arguments, and waits for it to exit
+func (t *Tester) Exec(path string, args ...string) error {
+	return t.Run(func() error {
+		return exec.Command(path, args...).Run()
+	})
+}
+
+// Connect connects to the address on the named network, e.g. Connect("tcp", "10.0.0.1:80")
+func (t *Tester) Connect(network, address string) error {
+	return t.Run(func() error {
+		conn, err := net.DialTimeout(network, address, connectTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// Symlink creates the link which points to the target
+func (t *Tester) Symlink(target, link string) error {
+	return t.Run(func() error {
+		return unix.Symlink(target, link)
+	})
+}
+
+// Close unloads the BPF profile and the BPF programs, and destroys the scratch mnt ns
+func (t *Tester) Close() {
+	t.Unload()
+	close(t.taskCh)
+	t.enforcer.Close()
+}
+
+// IsDenied reports whether the error is caused by the denial of the BPF enforcer
+func IsDenied(err error) bool {
+	return errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES)
+}
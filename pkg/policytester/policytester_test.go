@@ -0,0 +1,107 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policytester
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_IsDenied(t *testing.T) {
+	assert.Equal(t, IsDenied(unix.EPERM), true)
+	assert.Equal(t, IsDenied(unix.EACCES), true)
+	assert.Equal(t, IsDenied(&os.PathError{Op: "open", Path: "/etc/shadow", Err: unix.EACCES}), true)
+	assert.Equal(t, IsDenied(fmt.Errorf("dial: %w", unix.EPERM)), true)
+	assert.Equal(t, IsDenied(unix.ENOENT), false)
+	assert.Equal(t, IsDenied(nil), false)
+}
+
+func Test_Run(t *testing.T) {
+	// The scratch mnt ns is created without loading the BPF enforcer
+	tester := &Tester{taskCh: make(chan func())}
+	errCh := make(chan error)
+	go tester.run(errCh)
+	if err := <-errCh; err != nil {
+		t.Skipf("failed to create the scratch mnt ns: %v", err)
+	}
+	defer close(tester.taskCh)
+
+	ns, err := os.Readlink("/proc/thread-self/ns/mnt")
+	assert.NilError(t, err)
+
+	// The operations are performed on the thread of the scratch mnt ns
+	err = tester.Run(func() error {
+		if tid := uint32(unix.Gettid()); tid != tester.tid {
+			return fmt.Errorf("the operation is performed on the thread %d instead of %d", tid, tester.tid)
+		}
+		scratchNs, err := os.Readlink("/proc/thread-self/ns/mnt")
+		if err != nil {
+			return err
+		}
+		if scratchNs == ns {
+			return fmt.Errorf("the operation is performed in the mnt ns of the test")
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	assert.NilError(t, tester.Open(path, unix.O_WRONLY|unix.O_CREAT))
+	assert.NilError(t, tester.Symlink(path, filepath.Join(dir, "link")))
+	assert.ErrorContains(t, tester.Open(filepath.Join(dir, "missing"), unix.O_RDONLY), "no such file")
+
+	// Unloading without a profile is a no-op
+	assert.NilError(t, tester.Unload())
+}
+
+func Test_Tester(t *testing.T) {
+	tester, err := NewTester()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer tester.Close()
+
+	dir := t.TempDir()
+	denied := filepath.Join(dir, "denied")
+	allowed := filepath.Join(dir, "allowed")
+	for _, path := range []string{denied, allowed} {
+		assert.NilError(t, os.WriteFile(path, []byte("varmor"), 0644))
+	}
+
+	// Deny reading the file, the flags and the permissions are the same as the ones used by the profile generator
+	bpfContent := varmor.BpfContent{
+		Files: []varmor.FileContent{
+			{
+				Permissions: 0x00000004,
+				Pattern:     varmor.PathPattern{Flags: 0x00000001 | 0x00000004, Prefix: denied},
+			},
+		},
+	}
+	assert.NilError(t, tester.Load(bpfContent))
+
+	assert.Assert(t, IsDenied(tester.Open(denied, unix.O_RDONLY)))
+	assert.NilError(t, tester.Open(allowed, unix.O_RDONLY))
+
+	assert.NilError(t, tester.Unload())
+	assert.NilError(t, tester.Open(denied, unix.O_RDONLY))
+}
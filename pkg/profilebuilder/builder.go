@@ -0,0 +1,293 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profilebuilder builds the BPF profiles of vArmor from the rules of the policies. The platform teams can
+// register their own built-in rules with plugins, e.g. the rules that protect the paths, the CIDRs and the
+// capabilities specific to their organization, and reference them in the policies like the built-in rules of vArmor:
+//
+//	plugin, err := profilebuilder.NewRuleGroupPlugin(profilebuilder.RuleGroup{
+//		Name:  "acme-protect-vault-token",
+//		Files: []varmor.FileRule{{Pattern: "/var/run/vault/**", Permissions: []string{"read", "write"}}},
+//	})
+//	if err != nil { ... }
+//	err = profilebuilder.Register(plugin)
+//	if err != nil { ... }
+//
+//	var bpfContent varmor.BpfContent
+//	err = profilebuilder.Build(&policy.EnhanceProtect, &bpfContent)
+//
+// The rule groups can also be listed in a YAML file without any code, the manager loads them at startup with the
+// --ruleGroupsFile argument:
+//
+//	# rule-groups.yaml
+//	- name: acme-protect-vault-token
+//	  files:
+//	  - pattern: /var/run/vault/**
+//	    permissions: ["read", "write"]
+//	  capabilities: ["sys-admin"]
+//
+// The rules of the plugins only take effect in the BPF enforcer.
+package profilebuilder
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// Plugin provides the built-in rules of an organization. They are referenced by their names in the hardeningRules,
+// attackProtectionRules and vulMitigationRules of the policies.
+type Plugin interface {
+	// Rules returns the names of the rules that the plugin provides. The names are matched case-insensitively, and
+	// the underscores are treated as hyphens.
+	Rules() []string
+	// Generate appends the BPF rules of the rule to the profile. The rule is one of the names returned by Rules
+	// in lower case and with hyphens.
+	Generate(rule string, bpfContent *varmor.BpfContent) error
+}
+
+// Builder builds the BPF profiles with the built-in rules of vArmor and the ones provided by the plugins
+type Builder struct {
+	lock    sync.RWMutex
+	plugins map[string]Plugin // <rule name: Plugin>
+}
+
+// NewBuilder creates a Builder without any plugin
+func NewBuilder() *Builder {
+	return &Builder{
+		plugins: make(map[string]Plugin),
+	}
+}
+
+// defaultBuilder is used by vArmor to build the BPF profiles of the policies
+var defaultBuilder = NewBuilder()
+
+// Register registers the plugin to the builder used by vArmor. It should be called before the manager starts.
+func Register(plugin Plugin) error {
+	return defaultBuilder.Register(plugin)
+}
+
+// Build generates the BPF rules of the policy with the builder used by vArmor, and appends them to the profile
+func Build(enhanceProtect *varmor.EnhanceProtect, bpfContent *varmor.BpfContent) error {
+	return defaultBuilder.Build(enhanceProtect, bpfContent)
+}
+
+func normalizeRuleName(rule string) string {
+	return strings.ReplaceAll(strings.ToLower(rule), "_", "-")
+}
+
+// isBuiltinRule checks whether the rule is one of the built-in rules of vArmor, i.e. any of the generators of the
+// built-in rules generates something for it. The names of the disable-cap-* rules are reserved.
+func isBuiltinRule(rule string) bool {
+	if strings.HasPrefix(rule, "disable-cap-") {
+		return true
+	}
+
+	generators := []func(string, *varmor.BpfContent) error{
+		func(rule string, content *varmor.BpfContent) error {
+			return generateHardeningRules(rule, content, true)
+		},
+		generateVulMitigationRules,
+		generateAttackProtectionRules,
+	}
+	for _, generate := range generators {
+		var content varmor.BpfContent
+		if generate(rule, &content) != nil || !reflect.DeepEqual(content, varmor.BpfContent{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Register registers the rules of the plugin. It returns an error if any of them is a built-in rule of vArmor or
+// has been registered, and none of them is registered then.
+func (b *Builder) Register(plugin Plugin) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	rules := plugin.Rules()
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		name := normalizeRuleName(rule)
+		if name == "" {
+			return fmt.Errorf("the name of the rule cannot be empty")
+		}
+		if isBuiltinRule(name) {
+			return fmt.Errorf("the rule '%s' conflicts with the built-in rule of vArmor", rule)
+		}
+		if _, ok := b.plugins[name]; ok || seen[name] {
+			return fmt.Errorf("the rule '%s' has been registered", rule)
+		}
+		seen[name] = true
+	}
+
+	for name := range seen {
+		b.plugins[name] = plugin
+	}
+	return nil
+}
+
+// generateRule generates the rule with the plugin that provides it, or with the generator of the built-in rules
+func (b *Builder) generateRule(rule string, bpfContent *varmor.BpfContent, builtin func(string, *varmor.BpfContent) error) error {
+	name := normalizeRuleName(rule)
+
+	b.lock.RLock()
+	plugin, ok := b.plugins[name]
+	b.lock.RUnlock()
+	if !ok {
+		return builtin(rule, bpfContent)
+	}
+
+	err := plugin.Generate(name, bpfContent)
+	if err != nil {
+		return fmt.Errorf("failed to generate the rule '%s' with the plugin: %w", rule, err)
+	}
+	return nil
+}
+
+// Build generates the BPF rules of the policy and appends them to the profile. The rules provided by the plugins
+// are generated by them, and their IDs are tagged the same as the built-in rules.
+func (b *Builder) Build(enhanceProtect *varmor.EnhanceProtect, bpfContent *varmor.BpfContent) error {
+	var err error
+
+	// Add default rules for unprivileged containers based on the rules of the RuntimeDefault mode
+	if !enhanceProtect.Privileged {
+		err = GenerateRuntimeDefaultProfile(bpfContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Hardening
+	for _, rule := range enhanceProtect.HardeningRules {
+		counts := countRules(bpfContent)
+		err = b.generateRule(rule, bpfContent, func(rule string, content *varmor.BpfContent) error {
+			return generateHardeningRules(rule, content, enhanceProtect.Privileged)
+		})
+		if err != nil {
+			return err
+		}
+		tagRuleID(bpfContent, counts, "hardeningRules/"+rule)
+	}
+
+	// Vulnerability Mitigation
+	for _, rule := range enhanceProtect.VulMitigationRules {
+		counts := countRules(bpfContent)
+		err = b.generateRule(rule, bpfContent, generateVulMitigationRules)
+		if err != nil {
+			return err
+		}
+		tagRuleID(bpfContent, counts, "vulMitigationRules/"+rule)
+	}
+
+	// Attack Protection
+	for _, attackProtectionRule := range enhanceProtect.AttackProtectionRules {
+		if len(attackProtectionRule.Targets) == 0 {
+			for _, rule := range attackProtectionRule.Rules {
+				counts := countRules(bpfContent)
+				err = b.generateRule(rule, bpfContent, generateAttackProtectionRules)
+				if err != nil {
+					return err
+				}
+				tagRuleID(bpfContent, counts, "attackProtectionRules/"+rule)
+			}
+		}
+	}
+
+	// Custom
+	for i, rule := range enhanceProtect.BpfRawRules.Files {
+		counts := countRules(bpfContent)
+		err := generateRawFileRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+
+		err = generateRawProcessRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+		tagRuleID(bpfContent, counts, fmt.Sprintf("bpfRawRules.files/%d", i))
+	}
+
+	for i, rule := range enhanceProtect.BpfRawRules.Processes {
+		counts := countRules(bpfContent)
+		err := generateRawFileRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+
+		err = generateRawProcessRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+		tagRuleID(bpfContent, counts, fmt.Sprintf("bpfRawRules.processes/%d", i))
+	}
+
+	for i, egressRule := range enhanceProtect.BpfRawRules.Network.Egresses {
+		counts := countRules(bpfContent)
+		err := generateRawNetworkRules(egressRule, bpfContent)
+		if err != nil {
+			return err
+		}
+		tagRuleID(bpfContent, counts, fmt.Sprintf("bpfRawRules.network.egresses/%d", i))
+	}
+
+	if len(enhanceProtect.BpfRawRules.Ptrace.Permissions) != 0 {
+		counts := countRules(bpfContent)
+		err = generateRawPtraceRule(enhanceProtect.BpfRawRules.Ptrace, bpfContent)
+		if err != nil {
+			return err
+		}
+		tagRuleID(bpfContent, counts, "bpfRawRules.ptrace")
+	}
+
+	// File Integrity
+	for i, rule := range enhanceProtect.FileIntegrityRules {
+		counts := countRules(bpfContent)
+		err = generateFileIntegrityRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+		tagRuleID(bpfContent, counts, fmt.Sprintf("fileIntegrityRules/%d", i))
+	}
+
+	if enhanceProtect.Privileged {
+		for i, rule := range enhanceProtect.BpfRawRules.Mounts {
+			counts := countRules(bpfContent)
+			err := generateRawMountRule(rule, bpfContent)
+			if err != nil {
+				return err
+			}
+			tagRuleID(bpfContent, counts, fmt.Sprintf("bpfRawRules.mounts/%d", i))
+		}
+	}
+
+	// Overlayfs
+	if enhanceProtect.MatchOverlayfsPaths {
+		bpfContent.Files, err = generateOverlayfsRules(bpfContent.Files)
+		if err != nil {
+			return err
+		}
+
+		bpfContent.Processes, err = generateOverlayfsRules(bpfContent.Processes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return validateRuleCounts(bpfContent)
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profilebuilder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+type testPlugin struct {
+	rules []string
+}
+
+func (p *testPlugin) Rules() []string {
+	return p.rules
+}
+
+func (p *testPlugin) Generate(rule string, bpfContent *varmor.BpfContent) error {
+	if rule == "acme-broken" {
+		return fmt.Errorf("broken")
+	}
+	bpfContent.Capabilities |= 1 << 13
+	return nil
+}
+
+func Test_BuilderRegister(t *testing.T) {
+	b := NewBuilder()
+
+	err := b.Register(&testPlugin{rules: []string{"disallow-mount"}})
+	assert.ErrorContains(t, err, "conflicts with the built-in rule")
+	err = b.Register(&testPlugin{rules: []string{"Disable_Cap_Net_Raw"}})
+	assert.ErrorContains(t, err, "conflicts with the built-in rule")
+	err = b.Register(&testPlugin{rules: []string{""}})
+	assert.ErrorContains(t, err, "cannot be empty")
+
+	err = b.Register(&testPlugin{rules: []string{"acme-a", "ACME_A"}})
+	assert.ErrorContains(t, err, "has been registered")
+	assert.Equal(t, len(b.plugins), 0)
+
+	err = b.Register(&testPlugin{rules: []string{"acme-a", "acme-broken"}})
+	assert.NilError(t, err)
+	err = b.Register(&testPlugin{rules: []string{"acme-b", "acme-a"}})
+	assert.ErrorContains(t, err, "has been registered")
+	assert.Equal(t, len(b.plugins), 2)
+}
+
+func Test_BuilderBuild(t *testing.T) {
+	plugin, err := NewRuleGroupPlugin(RuleGroup{
+		Name:         "acme-protect-vault-token",
+		Files:        []varmor.FileRule{{Pattern: "/var/run/vault/**", Permissions: []string{"read", "write"}}},
+		Egresses:     []varmor.NetworkEgressRule{{IPBlock: "10.10.0.0/16"}},
+		Capabilities: []string{"net-raw"},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, plugin.Rules(), []string{"acme-protect-vault-token"})
+
+	b := NewBuilder()
+	assert.NilError(t, b.Register(plugin))
+	assert.NilError(t, b.Register(&testPlugin{rules: []string{"acme-broken"}}))
+
+	enhanceProtect := varmor.EnhanceProtect{
+		HardeningRules: []string{"ACME_PROTECT_VAULT_TOKEN", "disallow-mount"},
+		Privileged:     true,
+	}
+	var bpfContent varmor.BpfContent
+	err = b.Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+	assert.Equal(t, len(bpfContent.Files), 1)
+	assert.Equal(t, bpfContent.Files[0].RuleID, "hardeningRules/ACME_PROTECT_VAULT_TOKEN")
+	assert.Equal(t, len(bpfContent.Networks), 1)
+	assert.Equal(t, bpfContent.Networks[0].RuleID, "hardeningRules/ACME_PROTECT_VAULT_TOKEN")
+	assert.Equal(t, bpfContent.Capabilities&(1<<13), uint64(1<<13))
+	assert.Equal(t, bpfContent.Mounts[0].RuleID, "hardeningRules/disallow-mount")
+
+	// The rules of the plugins are unknown to the default builder
+	err = Build(&enhanceProtect, &varmor.BpfContent{})
+	assert.NilError(t, err)
+
+	enhanceProtect.VulMitigationRules = []string{"acme-broken"}
+	err = b.Build(&enhanceProtect, &varmor.BpfContent{})
+	assert.ErrorContains(t, err, "failed to generate the rule 'acme-broken' with the plugin")
+
+	_, err = NewRuleGroupPlugin(RuleGroup{Name: "acme-a", Capabilities: []string{"net-foo"}})
+	assert.ErrorContains(t, err, "is unknown")
+	_, err = NewRuleGroupPlugin(RuleGroup{Name: "acme-a"}, RuleGroup{Name: "ACME-A"})
+	assert.ErrorContains(t, err, "is duplicated")
+}
+
+func Test_LoadRuleGroupPlugin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rule-groups.yaml")
+	err := os.WriteFile(path, []byte(`
+- name: acme-protect-vault-token
+  files:
+  - pattern: /var/run/vault/**
+    permissions: ["read", "write"]
+  capabilities: ["sys-admin"]
+- name: acme-disallow-metadata
+  egresses:
+  - ipBlock: 169.254.169.254/32
+`), 0600)
+	assert.NilError(t, err)
+
+	plugin, err := LoadRuleGroupPlugin(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, plugin.Rules(), []string{"acme-disallow-metadata", "acme-protect-vault-token"})
+
+	var bpfContent varmor.BpfContent
+	err = plugin.Generate("acme-protect-vault-token", &bpfContent)
+	assert.NilError(t, err)
+	assert.Equal(t, len(bpfContent.Files), 1)
+	assert.Equal(t, bpfContent.Capabilities, uint64(1<<21))
+
+	err = os.WriteFile(path, []byte(`- name: acme-a
+  capabilities: ["net-foo"]
+`), 0600)
+	assert.NilError(t, err)
+	_, err = LoadRuleGroupPlugin(path)
+	assert.ErrorContains(t, err, "is unknown")
+
+	err = os.WriteFile(path, []byte(`name: acme-a`), 0600)
+	assert.NilError(t, err)
+	_, err = LoadRuleGroupPlugin(path)
+	assert.ErrorContains(t, err, "failed to parse the rule groups")
+
+	_, err = LoadRuleGroupPlugin(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Assert(t, err != nil)
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profilebuilder
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// newBpfNetworkPeerRule converts the egress rule with a Service or Pods into the network peer rule. The addresses
+// of the peer are resolved by the manager later.
+func newBpfNetworkPeerRule(rule varmor.NetworkEgressRule) (*varmor.NetworkPeerContent, error) {
+	if rule.IPBlock != "" || rule.IP != "" || (rule.Service != nil && rule.Pods != nil) {
+		return nil, fmt.Errorf("only one of the ipBlock, ip, service and pods can be set")
+	}
+
+	if rule.Port < 0 || rule.Port > 65535 {
+		return nil, fmt.Errorf("invalid network port")
+	}
+
+	peer := varmor.NetworkPeerContent{
+		Port: uint32(rule.Port),
+	}
+
+	if rule.Service != nil {
+		if rule.Service.Name == "" {
+			return nil, fmt.Errorf("the name of the service cannot be empty")
+		}
+		peer.Namespace = rule.Service.Namespace
+		peer.ServiceName = rule.Service.Name
+	} else {
+		_, err := metav1.LabelSelectorAsSelector(&rule.Pods.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod selector: %v", err)
+		}
+		peer.Namespace = rule.Pods.Namespace
+		peer.PodSelector = rule.Pods.Selector.DeepCopy()
+	}
+
+	return &peer, nil
+}
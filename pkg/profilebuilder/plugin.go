@@ -0,0 +1,142 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profilebuilder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// RuleGroup describes a built-in rule by the files, the network destinations and the capabilities that it denies.
+// It's a plugin that needs no code, e.g. loaded from the configuration of the platform.
+type RuleGroup struct {
+	// Name is the name of the rule that the policies reference
+	Name string `json:"name"`
+	// Files are the files to deny, and the processes to deny with the exec permission. They have the same syntax
+	// as the bpfRawRules.files of the policies.
+	Files []varmor.FileRule `json:"files,omitempty"`
+	// Egresses are the network destinations to deny, they have the same syntax as the bpfRawRules.network.egresses
+	// of the policies
+	Egresses []varmor.NetworkEgressRule `json:"egresses,omitempty"`
+	// Capabilities are the capabilities to deny, e.g. net-raw and sys-admin
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// generate appends the BPF rules of the group to the profile
+func (group *RuleGroup) generate(bpfContent *varmor.BpfContent) error {
+	for _, rule := range group.Files {
+		err := generateRawFileRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+
+		err = generateRawProcessRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range group.Egresses {
+		err := generateRawNetworkRules(rule, bpfContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range group.Capabilities {
+		n, ok := CapabilityNumbers[strings.ReplaceAll(strings.ToLower(name), "-", "_")]
+		if !ok {
+			return fmt.Errorf("the capability '%s' is unknown, it should be like net-raw", name)
+		}
+		bpfContent.Capabilities |= 1 << n
+	}
+	return nil
+}
+
+type ruleGroupPlugin struct {
+	groups map[string]RuleGroup // <rule name: RuleGroup>
+}
+
+// NewRuleGroupPlugin creates a plugin that provides the rule groups. It returns an error if the names of them are
+// duplicated, or any of them is invalid.
+func NewRuleGroupPlugin(groups ...RuleGroup) (Plugin, error) {
+	plugin := ruleGroupPlugin{
+		groups: make(map[string]RuleGroup, len(groups)),
+	}
+
+	for _, group := range groups {
+		name := normalizeRuleName(group.Name)
+		if _, ok := plugin.groups[name]; ok {
+			return nil, fmt.Errorf("the rule group '%s' is duplicated", group.Name)
+		}
+
+		var bpfContent varmor.BpfContent
+		err := group.generate(&bpfContent)
+		if err != nil {
+			return nil, fmt.Errorf("the rule group '%s' is invalid: %w", group.Name, err)
+		}
+		plugin.groups[name] = group
+	}
+	return &plugin, nil
+}
+
+// ParseRuleGroups parses the list of the rule groups in YAML or JSON format
+func ParseRuleGroups(data []byte) ([]RuleGroup, error) {
+	var groups []RuleGroup
+	err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096).Decode(&groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// LoadRuleGroupPlugin creates the plugin that provides the rule groups listed in the file, e.g. the one mounted
+// from a ConfigMap. So the platform teams can add their built-in rules without building the manager.
+func LoadRuleGroupPlugin(path string) (Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := ParseRuleGroups(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the rule groups of %s: %w", path, err)
+	}
+	return NewRuleGroupPlugin(groups...)
+}
+
+func (p *ruleGroupPlugin) Rules() []string {
+	rules := make([]string, 0, len(p.groups))
+	for name := range p.groups {
+		rules = append(rules, name)
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+func (p *ruleGroupPlugin) Generate(rule string, bpfContent *varmor.BpfContent) error {
+	group, ok := p.groups[rule]
+	if !ok {
+		return fmt.Errorf("the rule group '%s' doesn't exist", rule)
+	}
+	return group.generate(bpfContent)
+}
@@ -12,11 +12,14 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package bpf
+package profilebuilder
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"regexp"
 	"strings"
 
 	"github.com/dlclark/regexp2"
@@ -67,46 +70,111 @@ func regexp2FindAllString(re *regexp2.Regexp, s string) []string {
 	return matches
 }
 
+// ruleCounts records the count of each type of rules, it's used to find the rules generated by a policy rule
+type ruleCounts struct {
+	files         int
+	processes     int
+	networks      int
+	mounts        int
+	regexFiles    int
+	hashProcesses int
+	networkPeers  int
+	ptrace        varmor.PtraceContent
+}
+
+func countRules(bpfContent *varmor.BpfContent) ruleCounts {
+	counts := ruleCounts{
+		files:         len(bpfContent.Files),
+		processes:     len(bpfContent.Processes),
+		networks:      len(bpfContent.Networks),
+		mounts:        len(bpfContent.Mounts),
+		regexFiles:    len(bpfContent.RegexFiles),
+		hashProcesses: len(bpfContent.HashProcesses),
+		networkPeers:  len(bpfContent.NetworkPeers),
+	}
+	if bpfContent.Ptrace != nil {
+		counts.ptrace = *bpfContent.Ptrace
+	}
+	return counts
+}
+
+// tagRuleID sets the rule ID to the rules which were generated after the counts were recorded.
+// The ptrace rule is shared by the policy rules, so the IDs of them are joined with commas.
+func tagRuleID(bpfContent *varmor.BpfContent, counts ruleCounts, ruleID string) {
+	for i := counts.files; i < len(bpfContent.Files); i++ {
+		bpfContent.Files[i].RuleID = ruleID
+	}
+	for i := counts.processes; i < len(bpfContent.Processes); i++ {
+		bpfContent.Processes[i].RuleID = ruleID
+	}
+	for i := counts.networks; i < len(bpfContent.Networks); i++ {
+		bpfContent.Networks[i].RuleID = ruleID
+	}
+	for i := counts.mounts; i < len(bpfContent.Mounts); i++ {
+		bpfContent.Mounts[i].RuleID = ruleID
+	}
+	for i := counts.regexFiles; i < len(bpfContent.RegexFiles); i++ {
+		bpfContent.RegexFiles[i].RuleID = ruleID
+	}
+	for i := counts.hashProcesses; i < len(bpfContent.HashProcesses); i++ {
+		bpfContent.HashProcesses[i].RuleID = ruleID
+	}
+	for i := counts.networkPeers; i < len(bpfContent.NetworkPeers); i++ {
+		bpfContent.NetworkPeers[i].RuleID = ruleID
+	}
+
+	ptrace := bpfContent.Ptrace
+	if ptrace != nil && (ptrace.Permissions != counts.ptrace.Permissions || ptrace.Flags != counts.ptrace.Flags) {
+		if ptrace.RuleID == "" {
+			ptrace.RuleID = ruleID
+		} else {
+			ptrace.RuleID += "," + ruleID
+		}
+	}
+}
+
 func GenerateRuntimeDefaultProfile(bpfContent *varmor.BpfContent) error {
 	var err error
 
-	fileContent, err := newBpfPathRule("/proc/sysrq-trigger", AaMayRead|AaMayWrite|AaMayAppend)
+	defer tagRuleID(bpfContent, countRules(bpfContent), "runtimeDefault")
+
+	fileContent, err := NewPathRule("/proc/sysrq-trigger", AaMayRead|AaMayWrite|AaMayAppend)
 	if err != nil {
 		return err
 	}
 	bpfContent.Files = append(bpfContent.Files, *fileContent)
 
-	fileContent, err = newBpfPathRule("/proc/**/mem", AaMayRead|AaMayWrite|AaMayAppend)
+	fileContent, err = NewPathRule("/proc/**/mem", AaMayRead|AaMayWrite|AaMayAppend)
 	if err != nil {
 		return err
 	}
 	bpfContent.Files = append(bpfContent.Files, *fileContent)
 
-	fileContent, err = newBpfPathRule("/proc/kmem", AaMayRead|AaMayWrite|AaMayAppend)
+	fileContent, err = NewPathRule("/proc/kmem", AaMayRead|AaMayWrite|AaMayAppend)
 	if err != nil {
 		return err
 	}
 	bpfContent.Files = append(bpfContent.Files, *fileContent)
 
-	fileContent, err = newBpfPathRule("/proc/kcore", AaMayRead|AaMayWrite|AaMayAppend)
+	fileContent, err = NewPathRule("/proc/kcore", AaMayRead|AaMayWrite|AaMayAppend)
 	if err != nil {
 		return err
 	}
 	bpfContent.Files = append(bpfContent.Files, *fileContent)
 
-	fileContent, err = newBpfPathRule("/sys/firmware/**", AaMayRead|AaMayWrite|AaMayAppend)
+	fileContent, err = NewPathRule("/sys/firmware/**", AaMayRead|AaMayWrite|AaMayAppend)
 	if err != nil {
 		return err
 	}
 	bpfContent.Files = append(bpfContent.Files, *fileContent)
 
-	fileContent, err = newBpfPathRule("/sys/kernel/security/**", AaMayRead|AaMayWrite|AaMayAppend)
+	fileContent, err = NewPathRule("/sys/kernel/security/**", AaMayRead|AaMayWrite|AaMayAppend)
 	if err != nil {
 		return err
 	}
 	bpfContent.Files = append(bpfContent.Files, *fileContent)
 
-	mountContent, err := newBpfMountRule("**", "*", 0xFFFFFFFF&^AaMayUmount, 0xFFFFFFFF)
+	mountContent, err := NewMountRule("**", "*", 0xFFFFFFFF&^AaMayUmount, 0xFFFFFFFF)
 	if err != nil {
 		return err
 	}
@@ -121,7 +189,57 @@ func GenerateRuntimeDefaultProfile(bpfContent *varmor.BpfContent) error {
 	return nil
 }
 
-func newBpfPathRule(pattern string, permissions uint32) (*varmor.FileContent, error) {
+// GenerateSandboxProfile generates the minimal BPF profile of the sandbox (pause) containers. The pause process
+// only waits for the signals, so the profile denies all the capabilities, executions, writes, mounts, ptrace and
+// outgoing connections in the sandbox container.
+func GenerateSandboxProfile() (*varmor.BpfContent, error) {
+	bpfContent := &varmor.BpfContent{
+		Capabilities: (1 << (unix.CAP_LAST_CAP + 1)) - 1,
+		Ptrace: &varmor.PtraceContent{
+			Permissions: AaPtraceTrace | AaPtraceRead | AaMayBeTraced | AaMayBeRead,
+			Flags:       GreedyMatch,
+			RuleID:      "sandbox",
+		},
+	}
+
+	defer tagRuleID(bpfContent, countRules(bpfContent), "sandbox")
+
+	processContents, err := NewPathRules("**", AaMayExec)
+	if err != nil {
+		return nil, err
+	}
+	bpfContent.Processes = append(bpfContent.Processes, processContents...)
+
+	fileContents, err := NewPathRules("**", AaMayWrite|AaMayAppend)
+	if err != nil {
+		return nil, err
+	}
+	bpfContent.Files = append(bpfContent.Files, fileContents...)
+
+	for _, cidr := range []string{"0.0.0.0/0", "::/0"} {
+		networkContent, err := NewNetworkRule(cidr, "", 0)
+		if err != nil {
+			return nil, err
+		}
+		bpfContent.Networks = append(bpfContent.Networks, *networkContent)
+	}
+
+	mountContent, err := NewMountRule("**", "*", 0xFFFFFFFF&^AaMayUmount, 0xFFFFFFFF)
+	if err != nil {
+		return nil, err
+	}
+	bpfContent.Mounts = append(bpfContent.Mounts, *mountContent)
+
+	mountContent, err = NewMountRule("**", "none", AaMayUmount, 0)
+	if err != nil {
+		return nil, err
+	}
+	bpfContent.Mounts = append(bpfContent.Mounts, *mountContent)
+
+	return bpfContent, nil
+}
+
+func NewPathRule(pattern string, permissions uint32) (*varmor.FileContent, error) {
 	// Pre-check
 	re, err := regexp2.Compile(`(?<!\*)\*(?!\*)`, regexp2.None)
 	if err != nil {
@@ -190,7 +308,125 @@ func newBpfPathRule(pattern string, permissions uint32) (*varmor.FileContent, er
 	return &pathRule, nil
 }
 
-func newBpfNetworkRule(cidr string, ipAddress string, port uint32) (*varmor.NetworkContent, error) {
+// maxPatternExpansion is the max count of the simple patterns that a path pattern can be expanded into
+const maxPatternExpansion = 16
+
+// expandCharClass returns the characters matched by the character class, e.g. "a-c_" for [a-c_]
+func expandCharClass(class string) ([]string, error) {
+	if class == "" {
+		return nil, fmt.Errorf("the character class is empty")
+	}
+
+	if class[0] == '!' || class[0] == '^' {
+		return nil, fmt.Errorf("the negated character class [%s] is not supported", class)
+	}
+
+	var chars []string
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] > class[i+2] {
+				return nil, fmt.Errorf("the range %s of character class [%s] is invalid", class[i:i+3], class)
+			}
+			for c := class[i]; c <= class[i+2]; c++ {
+				chars = append(chars, string(c))
+			}
+			i += 2
+		} else {
+			chars = append(chars, string(class[i]))
+		}
+	}
+
+	return chars, nil
+}
+
+// expandPathPattern pre-compiles the AppArmor-style path pattern into the simple patterns that can be
+// carried by the BPF enforcer. The character classes (e.g. [a-c]) and the alternations (e.g. {a,b})
+// are expanded into multiple patterns. The ? is approximated with * or ** because the matcher of BPF
+// enforcer can't match a single character, so it may match more paths than expected.
+func expandPathPattern(pattern string) ([]string, error) {
+	patterns := []string{""}
+
+	for i := 0; i < len(pattern); i++ {
+		var alternatives []string
+
+		switch pattern[i] {
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("the character class in the pattern '%s' is not closed", pattern)
+			}
+			chars, err := expandCharClass(pattern[i+1 : i+1+end])
+			if err != nil {
+				return nil, err
+			}
+			alternatives = chars
+			i += end + 1
+		case '{':
+			end := strings.IndexByte(pattern[i+1:], '}')
+			if end == -1 {
+				return nil, fmt.Errorf("the alternation in the pattern '%s' is not closed", pattern)
+			}
+			alternatives = strings.Split(pattern[i+1:i+1+end], ",")
+			i += end + 1
+		default:
+			for j := range patterns {
+				patterns[j] += string(pattern[i])
+			}
+			continue
+		}
+
+		if len(patterns)*len(alternatives) > maxPatternExpansion {
+			return nil, fmt.Errorf("the pattern '%s' is expanded into more than %d patterns", pattern, maxPatternExpansion)
+		}
+
+		var expanded []string
+		for _, p := range patterns {
+			for _, alternative := range alternatives {
+				expanded = append(expanded, p+alternative)
+			}
+		}
+		patterns = expanded
+	}
+
+	for i, p := range patterns {
+		if !strings.Contains(p, "?") {
+			continue
+		}
+
+		if strings.Count(p, "?") > 1 || strings.Contains(p, "*") {
+			return nil, fmt.Errorf("the globbing ? in the pattern '%s' can only be used once and cannot be used with * or **", pattern)
+		}
+
+		if strings.Contains(p, "/") {
+			patterns[i] = strings.Replace(p, "?", "**", 1)
+		} else {
+			patterns[i] = strings.Replace(p, "?", "*", 1)
+		}
+	}
+
+	return patterns, nil
+}
+
+// NewPathRules expands the path pattern and creates the BPF path rules for each of the expanded patterns
+func NewPathRules(pattern string, permissions uint32) ([]varmor.FileContent, error) {
+	patterns, err := expandPathPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []varmor.FileContent
+	for _, p := range patterns {
+		content, err := NewPathRule(p, permissions)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, *content)
+	}
+
+	return contents, nil
+}
+
+func NewNetworkRule(cidr string, ipAddress string, port uint32) (*varmor.NetworkContent, error) {
 	// Pre-check
 	if cidr == "" && ipAddress == "" && port == 0 {
 		return nil, fmt.Errorf("cidr, ipAddress and port cannot be empty at the same time")
@@ -206,7 +442,14 @@ func newBpfNetworkRule(cidr string, ipAddress string, port uint32) (*varmor.Netw
 
 	var networkRule varmor.NetworkContent
 
-	if cidr != "" {
+	if strings.HasPrefix(cidr, "@") {
+		// The macro is expanded into the CIDRs by the agent
+		if !varmortypes.IsNetworkMacro(cidr) {
+			return nil, fmt.Errorf("unknown network macro '%s'", cidr)
+		}
+		networkRule.Flags |= CidrMatch
+		networkRule.CIDR = cidr
+	} else if cidr != "" {
 		networkRule.Flags |= CidrMatch
 
 		_, ipNet, err := net.ParseCIDR(cidr)
@@ -247,7 +490,7 @@ func newBpfNetworkRule(cidr string, ipAddress string, port uint32) (*varmor.Netw
 	return &networkRule, nil
 }
 
-func newBpfMountRule(sourcePattern string, fstype string, mountFlags uint32, reverseMountFlags uint32) (*varmor.MountContent, error) {
+func NewMountRule(sourcePattern string, fstype string, mountFlags uint32, reverseMountFlags uint32) (*varmor.MountContent, error) {
 	// Pre-check
 	if len(fstype) >= varmortypes.MaxFileSystemTypeLength {
 		return nil, fmt.Errorf("the length of fstype '%s' should be less than the maximum (%d)", fstype, varmortypes.MaxFileSystemTypeLength)
@@ -330,7 +573,7 @@ func generateHardeningRules(rule string, content *varmor.BpfContent, privileged
 	//// 1. Blocking escape vectors from privileged container
 	// disallow write core_pattern
 	case "disallow-write-core-pattern":
-		fileContent, err := newBpfPathRule("/proc/sys/kernel/core_pattern", AaMayWrite|AaMayAppend)
+		fileContent, err := NewPathRule("/proc/sys/kernel/core_pattern", AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
@@ -343,7 +586,7 @@ func generateHardeningRules(rule string, content *varmor.BpfContent, privileged
 		// mount new
 		flags := 0xFFFFFFFF &^ unix.MS_REMOUNT &^ unix.MS_BIND &^ unix.MS_SHARED &^
 			unix.MS_PRIVATE &^ unix.MS_SLAVE &^ unix.MS_UNBINDABLE &^ unix.MS_MOVE &^ AaMayUmount
-		mountContent, err := newBpfMountRule("**", "securityfs", uint32(flags), 0xFFFFFFFF)
+		mountContent, err := NewMountRule("**", "securityfs", uint32(flags), 0xFFFFFFFF)
 		if err != nil {
 			return err
 		}
@@ -356,21 +599,21 @@ func generateHardeningRules(rule string, content *varmor.BpfContent, privileged
 		// mount new
 		flags := 0xFFFFFFFF &^ unix.MS_REMOUNT &^ unix.MS_BIND &^ unix.MS_SHARED &^
 			unix.MS_PRIVATE &^ unix.MS_SLAVE &^ unix.MS_UNBINDABLE &^ unix.MS_MOVE &^ AaMayUmount
-		mountContent, err := newBpfMountRule("**", "proc", uint32(flags), 0xFFFFFFFF)
+		mountContent, err := NewMountRule("**", "proc", uint32(flags), 0xFFFFFFFF)
 		if err != nil {
 			return err
 		}
 		content.Mounts = append(content.Mounts, *mountContent)
 		// bind, rbind, remount, move, umount
 		flags = unix.MS_BIND | unix.MS_REC | unix.MS_REMOUNT | unix.MS_MOVE | AaMayUmount
-		mountContent, err = newBpfMountRule("/proc**", "none", uint32(flags), 0)
+		mountContent, err = NewMountRule("/proc**", "none", uint32(flags), 0)
 		if err != nil {
 			return err
 		}
 		content.Mounts = append(content.Mounts, *mountContent)
 	// disallow write release_agent
 	case "disallow-write-release-agent":
-		fileContent, err := newBpfPathRule("/sys/fs/cgroup/**/release_agent", AaMayWrite|AaMayAppend)
+		fileContent, err := NewPathRule("/sys/fs/cgroup/**/release_agent", AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
@@ -383,21 +626,21 @@ func generateHardeningRules(rule string, content *varmor.BpfContent, privileged
 		// mount new
 		flags := 0xFFFFFFFF &^ unix.MS_REMOUNT &^ unix.MS_BIND &^ unix.MS_SHARED &^
 			unix.MS_PRIVATE &^ unix.MS_SLAVE &^ unix.MS_UNBINDABLE &^ unix.MS_MOVE &^ AaMayUmount
-		mountContent, err := newBpfMountRule("**", "cgroup", uint32(flags), 0xFFFFFFFF)
+		mountContent, err := NewMountRule("**", "cgroup", uint32(flags), 0xFFFFFFFF)
 		if err != nil {
 			return err
 		}
 		content.Mounts = append(content.Mounts, *mountContent)
 		// bind, rbind, remount, move, umount
 		flags = unix.MS_BIND | unix.MS_REC | unix.MS_REMOUNT | unix.MS_MOVE | AaMayUmount
-		mountContent, err = newBpfMountRule("/sys**", "none", uint32(flags), 0)
+		mountContent, err = NewMountRule("/sys**", "none", uint32(flags), 0)
 		if err != nil {
 			return err
 		}
 		content.Mounts = append(content.Mounts, *mountContent)
 	// disallow debug disk devices
 	case "disallow-debug-disk-device":
-		fileContent, err := newBpfPathRule("{{.DiskDevices}}", AaMayRead|AaMayWrite|AaMayAppend)
+		fileContent, err := NewPathRule("{{.DiskDevices}}", AaMayRead|AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
@@ -407,7 +650,7 @@ func generateHardeningRules(rule string, content *varmor.BpfContent, privileged
 		if !privileged {
 			break
 		}
-		mountContent, err := newBpfMountRule("{{.DiskDevices}}", "*", 0xFFFFFFFF&^AaMayUmount, 0xFFFFFFFF)
+		mountContent, err := NewMountRule("{{.DiskDevices}}", "*", 0xFFFFFFFF&^AaMayUmount, 0xFFFFFFFF)
 		if err != nil {
 			return err
 		}
@@ -417,14 +660,14 @@ func generateHardeningRules(rule string, content *varmor.BpfContent, privileged
 		if !privileged {
 			break
 		}
-		mountContent, err := newBpfMountRule("**", "*", 0xFFFFFFFF&^AaMayUmount, 0xFFFFFFFF)
+		mountContent, err := NewMountRule("**", "*", 0xFFFFFFFF&^AaMayUmount, 0xFFFFFFFF)
 		if err != nil {
 			return err
 		}
 		content.Mounts = append(content.Mounts, *mountContent)
 	// disallow umount anything
 	case "disallow-umount":
-		mountContent, err := newBpfMountRule("**", "none", AaMayUmount, 0)
+		mountContent, err := NewMountRule("**", "none", AaMayUmount, 0)
 		if err != nil {
 			return err
 		}
@@ -442,6 +685,37 @@ func generateHardeningRules(rule string, content *varmor.BpfContent, privileged
 		}
 		content.Ptrace.Permissions |= AaPtraceRead
 		content.Ptrace.Flags |= PreciseMatch
+	// disallow tampering with the pinned BPF programs, the sockets and the profiles of vArmor
+	case "disallow-tamper-varmor":
+		fileContent, err := NewPathRule("/sys/fs/bpf/varmor**", AaMayRead|AaMayWrite|AaMayAppend)
+		if err != nil {
+			return err
+		}
+		content.Files = append(content.Files, *fileContent)
+
+		fileContent, err = NewPathRule("/run/varmor/**", AaMayRead|AaMayWrite|AaMayAppend)
+		if err != nil {
+			return err
+		}
+		content.Files = append(content.Files, *fileContent)
+
+		fileContent, err = NewPathRule("/var/run/varmor/**", AaMayRead|AaMayWrite|AaMayAppend)
+		if err != nil {
+			return err
+		}
+		content.Files = append(content.Files, *fileContent)
+
+		fileContent, err = NewPathRule("/var/lib/kubelet/seccomp/varmor-**", AaMayWrite|AaMayAppend)
+		if err != nil {
+			return err
+		}
+		content.Files = append(content.Files, *fileContent)
+
+		fileContent, err = NewPathRule("/sys/kernel/security/apparmor/**", AaMayWrite|AaMayAppend)
+		if err != nil {
+			return err
+		}
+		content.Files = append(content.Files, *fileContent)
 
 	//// 2. Disable capabilities
 	// disable all capabilities
@@ -573,25 +847,25 @@ func generateVulMitigationRules(rule string, content *varmor.BpfContent) error {
 
 	switch rule {
 	case "cgroups-lxcfs-escape-mitigation":
-		fileContent, err := newBpfPathRule("/**/release_agent", AaMayWrite|AaMayAppend)
+		fileContent, err := NewPathRule("/**/release_agent", AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 
-		fileContent, err = newBpfPathRule("/**/devices.allow", AaMayWrite|AaMayAppend)
+		fileContent, err = NewPathRule("/**/devices.allow", AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 
-		fileContent, err = newBpfPathRule("/**/cgroup.procs", AaMayWrite|AaMayAppend)
+		fileContent, err = NewPathRule("/**/cgroup.procs", AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 
-		fileContent, err = newBpfPathRule("/**/devices/tasks", AaMayWrite|AaMayAppend)
+		fileContent, err = NewPathRule("/**/devices/tasks", AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
@@ -611,57 +885,57 @@ func generateAttackProtectionRules(rule string, content *varmor.BpfContent) erro
 	switch rule {
 	//// 4. Mitigate container information leakage
 	case "mitigate-sa-leak":
-		fileContent, err = newBpfPathRule("/run/secrets/kubernetes.io/serviceaccount/**", AaMayRead)
+		fileContent, err = NewPathRule("/run/secrets/kubernetes.io/serviceaccount/**", AaMayRead)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 
-		fileContent, err = newBpfPathRule("/var/run/secrets/kubernetes.io/serviceaccount/**", AaMayRead)
+		fileContent, err = NewPathRule("/var/run/secrets/kubernetes.io/serviceaccount/**", AaMayRead)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 	case "mitigate-disk-device-number-leak":
-		fileContent, err = newBpfPathRule("/proc/partitions", AaMayRead)
+		fileContent, err = NewPathRule("/proc/partitions", AaMayRead)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 
-		fileContent, err = newBpfPathRule("/proc/**/mountinfo", AaMayRead)
+		fileContent, err = NewPathRule("/proc/**/mountinfo", AaMayRead)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 	case "mitigate-overlayfs-leak":
-		fileContent, err = newBpfPathRule("/proc/**/mounts", AaMayRead)
+		fileContent, err = NewPathRule("/proc/**/mounts", AaMayRead)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 
-		fileContent, err = newBpfPathRule("/proc/**/mountinfo", AaMayRead)
+		fileContent, err = NewPathRule("/proc/**/mountinfo", AaMayRead)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 	case "mitigate-host-ip-leak":
-		fileContent, err = newBpfPathRule("/proc/**/net/arp", AaMayRead)
+		fileContent, err = NewPathRule("/proc/**/net/arp", AaMayRead)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 	case "disallow-metadata-service":
 		// For Aliyun, Volc Engine, etc.
-		networkContent, err = newBpfNetworkRule("", "100.96.0.96", 0)
+		networkContent, err = NewNetworkRule("", "100.96.0.96", 0)
 		if err != nil {
 			return err
 		}
 		content.Networks = append(content.Networks, *networkContent)
 
 		// For AWS, GCP, Azure, etc.
-		networkContent, err = newBpfNetworkRule("", "169.254.169.254", 0)
+		networkContent, err = NewNetworkRule("", "169.254.169.254", 0)
 		if err != nil {
 			return err
 		}
@@ -669,63 +943,63 @@ func generateAttackProtectionRules(rule string, content *varmor.BpfContent) erro
 
 	//// 5. Restrict the sensitive operations inside the container
 	case "disable-write-etc":
-		fileContent, err = newBpfPathRule("/etc/**", AaMayWrite|AaMayAppend)
+		fileContent, err = NewPathRule("/etc/**", AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
 		content.Files = append(content.Files, *fileContent)
 
 	case "disable-busybox":
-		fileContent, err = newBpfPathRule("/**/busybox", AaMayExec)
+		fileContent, err = NewPathRule("/**/busybox", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 
 	case "disable-shell":
-		fileContent, err = newBpfPathRule("/**/sh", AaMayExec)
+		fileContent, err = NewPathRule("/**/sh", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 
-		fileContent, err = newBpfPathRule("/**/bash", AaMayExec)
+		fileContent, err = NewPathRule("/**/bash", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 
-		fileContent, err = newBpfPathRule("/**/dash", AaMayExec)
+		fileContent, err = NewPathRule("/**/dash", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 	case "disable-wget":
-		fileContent, err = newBpfPathRule("/**/wget", AaMayExec)
+		fileContent, err = NewPathRule("/**/wget", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 	case "disable-curl":
-		fileContent, err = newBpfPathRule("/**/curl", AaMayExec)
+		fileContent, err = NewPathRule("/**/curl", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 	case "disable-chmod":
-		fileContent, err = newBpfPathRule("/**/chmod", AaMayExec)
+		fileContent, err = NewPathRule("/**/chmod", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 	case "disable-su-sudo":
-		fileContent, err = newBpfPathRule("/**/su", AaMayExec)
+		fileContent, err = NewPathRule("/**/su", AaMayExec)
 		if err != nil {
 			return err
 		}
 		content.Processes = append(content.Processes, *fileContent)
 
-		fileContent, err = newBpfPathRule("/**/sudo", AaMayExec)
+		fileContent, err = NewPathRule("/**/sudo", AaMayExec)
 		if err != nil {
 			return err
 		}
@@ -734,9 +1008,55 @@ func generateAttackProtectionRules(rule string, content *varmor.BpfContent) erro
 	return nil
 }
 
+// newBpfRegexRule validates the regular expression of the file rule and creates a rule for the agent to expand
+func newBpfRegexRule(regex string, permissions uint32) (*varmor.RegexFileContent, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, fmt.Errorf("the regular expression '%s' is invalid: %v", regex, err)
+	}
+
+	prefix, _ := re.LiteralPrefix()
+	if !strings.HasPrefix(prefix, "/") {
+		return nil, fmt.Errorf("the regular expression '%s' must start with an absolute directory", regex)
+	}
+
+	regexRule := varmor.RegexFileContent{
+		Permissions: permissions,
+		Regex:       regex,
+	}
+
+	return &regexRule, nil
+}
+
+// NewHashRule creates the process rule which only allows the executables with the SHA256 digests to run
+func NewHashRule(path string, digests []string) (*varmor.HashProcessContent, error) {
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, "*?[{") {
+		return nil, fmt.Errorf("the pattern '%s' of the rule with SHA256 must be an absolute path without globbing", path)
+	}
+	if len(path) >= varmortypes.MaxFilePathPatternLength {
+		return nil, fmt.Errorf("the length of the path '%s' should be less than the maximum (%d)", path, varmortypes.MaxFilePathPatternLength)
+	}
+
+	hashRule := varmor.HashProcessContent{Path: path}
+	for _, digest := range digests {
+		digest = strings.ToLower(digest)
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+			return nil, fmt.Errorf("the SHA256 '%s' of the path '%s' is invalid", digest, path)
+		}
+		hashRule.SHA256 = append(hashRule.SHA256, digest)
+	}
+
+	return &hashRule, nil
+}
+
 func generateRawFileRules(rule varmor.FileRule, bpfContent *varmor.BpfContent) error {
 	var permissions uint32
 
+	// The permissions of the rule with SHA256 are ignored
+	if len(rule.SHA256) != 0 {
+		return nil
+	}
+
 	for _, permission := range rule.Permissions {
 		switch strings.ToLower(permission) {
 		case "read", "r":
@@ -753,11 +1073,20 @@ func generateRawFileRules(rule varmor.FileRule, bpfContent *varmor.BpfContent) e
 		return nil
 	}
 
-	fileContent, err := newBpfPathRule(rule.Pattern, permissions)
+	if rule.Regex {
+		regexContent, err := newBpfRegexRule(rule.Pattern, permissions)
+		if err != nil {
+			return err
+		}
+		bpfContent.RegexFiles = append(bpfContent.RegexFiles, *regexContent)
+		return nil
+	}
+
+	fileContents, err := NewPathRules(rule.Pattern, permissions)
 	if err != nil {
 		return err
 	}
-	bpfContent.Files = append(bpfContent.Files, *fileContent)
+	bpfContent.Files = append(bpfContent.Files, fileContents...)
 
 	return nil
 }
@@ -765,6 +1094,15 @@ func generateRawFileRules(rule varmor.FileRule, bpfContent *varmor.BpfContent) e
 func generateRawProcessRules(rule varmor.FileRule, bpfContent *varmor.BpfContent) error {
 	var permissions uint32
 
+	if len(rule.SHA256) != 0 {
+		hashRule, err := NewHashRule(rule.Pattern, rule.SHA256)
+		if err != nil {
+			return err
+		}
+		bpfContent.HashProcesses = append(bpfContent.HashProcesses, *hashRule)
+		return nil
+	}
+
 	for _, permission := range rule.Permissions {
 		switch strings.ToLower(permission) {
 		case "exec", "x":
@@ -776,17 +1114,35 @@ func generateRawProcessRules(rule varmor.FileRule, bpfContent *varmor.BpfContent
 		return nil
 	}
 
-	fileContent, err := newBpfPathRule(rule.Pattern, permissions)
+	if rule.Regex {
+		regexContent, err := newBpfRegexRule(rule.Pattern, permissions)
+		if err != nil {
+			return err
+		}
+		bpfContent.RegexFiles = append(bpfContent.RegexFiles, *regexContent)
+		return nil
+	}
+
+	fileContents, err := NewPathRules(rule.Pattern, permissions)
 	if err != nil {
 		return err
 	}
-	bpfContent.Processes = append(bpfContent.Processes, *fileContent)
+	bpfContent.Processes = append(bpfContent.Processes, fileContents...)
 
 	return nil
 }
 
 func generateRawNetworkRules(rule varmor.NetworkEgressRule, bpfContent *varmor.BpfContent) error {
-	networkContent, err := newBpfNetworkRule(rule.IPBlock, rule.IP, uint32(rule.Port))
+	if rule.Service != nil || rule.Pods != nil {
+		peerContent, err := newBpfNetworkPeerRule(rule)
+		if err != nil {
+			return err
+		}
+		bpfContent.NetworkPeers = append(bpfContent.NetworkPeers, *peerContent)
+		return nil
+	}
+
+	networkContent, err := NewNetworkRule(rule.IPBlock, rule.IP, uint32(rule.Port))
 	if err != nil {
 		return err
 	}
@@ -927,7 +1283,7 @@ func generateRawMountRule(rule varmor.MountRule, bpfContent *varmor.BpfContent)
 		}
 	}
 
-	mountContent, err := newBpfMountRule(rule.SourcePattern, rule.Fstype, mountFlags, reverseMountFlags)
+	mountContent, err := NewMountRule(rule.SourcePattern, rule.Fstype, mountFlags, reverseMountFlags)
 	if err != nil {
 		return err
 	}
@@ -936,108 +1292,49 @@ func generateRawMountRule(rule varmor.MountRule, bpfContent *varmor.BpfContent)
 	return nil
 }
 
-func GenerateEnhanceProtectProfile(enhanceProtect *varmor.EnhanceProtect, bpfContent *varmor.BpfContent) error {
-	var err error
-
-	// Add default rules for unprivileged containers based on the rules of the RuntimeDefault mode
-	if !enhanceProtect.Privileged {
-		err = GenerateRuntimeDefaultProfile(bpfContent)
-		if err != nil {
-			return err
-		}
+func generateFileIntegrityRules(rule varmor.FileIntegrityRule, bpfContent *varmor.BpfContent) error {
+	if !rule.Block {
+		return nil
 	}
 
-	// Hardening
-	for _, rule := range enhanceProtect.HardeningRules {
-		err = generateHardeningRules(rule, bpfContent, enhanceProtect.Privileged)
-		if err != nil {
-			return err
+	for _, path := range rule.Paths {
+		if strings.HasSuffix(path, "/") {
+			path += "**"
 		}
-	}
 
-	// Vulnerability Mitigation
-	for _, rule := range enhanceProtect.VulMitigationRules {
-		err = generateVulMitigationRules(rule, bpfContent)
+		fileContent, err := NewPathRule(path, AaMayWrite|AaMayAppend)
 		if err != nil {
 			return err
 		}
+		bpfContent.Files = append(bpfContent.Files, *fileContent)
 	}
 
-	// Attack Protection
-	for _, attackProtectionRule := range enhanceProtect.AttackProtectionRules {
-		if len(attackProtectionRule.Targets) == 0 {
-			for _, rule := range attackProtectionRule.Rules {
-				err = generateAttackProtectionRules(rule, bpfContent)
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	// Custom
-	for _, rule := range enhanceProtect.BpfRawRules.Files {
-		err := generateRawFileRules(rule, bpfContent)
-		if err != nil {
-			return err
-		}
-
-		err = generateRawProcessRules(rule, bpfContent)
-		if err != nil {
-			return err
-		}
-	}
+	return nil
+}
 
-	for _, rule := range enhanceProtect.BpfRawRules.Processes {
-		err := generateRawFileRules(rule, bpfContent)
-		if err != nil {
-			return err
-		}
+// overlayfsLayerDirs are the names of the directories which hold the content of the overlayfs layers. "fs" is
+// used by the overlayfs snapshotter of containerd, and "diff" is used by the overlay2 storage driver of docker.
+var overlayfsLayerDirs = []string{"fs", "diff"}
 
-		err = generateRawProcessRules(rule, bpfContent)
-		if err != nil {
-			return err
-		}
-	}
-
-	for _, egressRule := range enhanceProtect.BpfRawRules.Network.Egresses {
-		err := generateRawNetworkRules(egressRule, bpfContent)
-		if err != nil {
-			return err
-		}
-	}
+// generateOverlayfsRules duplicates the rules without globbing to match the paths of the overlayfs layers.
+// For example, the rule of "/etc/shadow" is duplicated into "**/fs/etc/shadow" and "**/diff/etc/shadow".
+func generateOverlayfsRules(contents []varmor.FileContent) ([]varmor.FileContent, error) {
+	var overlayfsContents []varmor.FileContent
 
-	if len(enhanceProtect.BpfRawRules.Ptrace.Permissions) != 0 {
-		err = generateRawPtraceRule(enhanceProtect.BpfRawRules.Ptrace, bpfContent)
-		if err != nil {
-			return err
+	for _, content := range contents {
+		if content.Pattern.Flags != PreciseMatch|PrefixMatch || !strings.HasPrefix(content.Pattern.Prefix, "/") {
+			continue
 		}
-	}
 
-	if enhanceProtect.Privileged {
-		for _, rule := range enhanceProtect.BpfRawRules.Mounts {
-			err := generateRawMountRule(rule, bpfContent)
+		for _, dir := range overlayfsLayerDirs {
+			fileContent, err := NewPathRule("**/"+dir+content.Pattern.Prefix, content.Permissions)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			fileContent.RuleID = content.RuleID
+			overlayfsContents = append(overlayfsContents, *fileContent)
 		}
 	}
 
-	if len(bpfContent.Files) > varmortypes.MaxBpfFileRuleCount {
-		return fmt.Errorf("the maximum number of BPF file rules exceeded(Max Count: %d)", varmortypes.MaxBpfFileRuleCount)
-	}
-
-	if len(bpfContent.Processes) > varmortypes.MaxBpfBprmRuleCount {
-		return fmt.Errorf("the maximum number of BPF bprm rules exceeded(Max Count: %d)", varmortypes.MaxBpfBprmRuleCount)
-	}
-
-	if len(bpfContent.Networks) > varmortypes.MaxBpfNetworkRuleCount {
-		return fmt.Errorf("the maximum number of BPF network rules exceeded(Max Count: %d)", varmortypes.MaxBpfNetworkRuleCount)
-	}
-
-	if len(bpfContent.Mounts) > varmortypes.MaxBpfMountRuleCount {
-		return fmt.Errorf("the maximum number of BPF mount rules exceeded(Max Count: %d)", varmortypes.MaxBpfMountRuleCount)
-	}
-
-	return nil
+	return append(contents, overlayfsContents...), nil
 }
@@ -0,0 +1,250 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profilebuilder
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_expandPathPattern(t *testing.T) {
+	testCases := []struct {
+		name             string
+		pattern          string
+		expectedPatterns []string
+		expectedErr      bool
+	}{
+		{
+			name:             "simple",
+			pattern:          "/etc/shadow",
+			expectedPatterns: []string{"/etc/shadow"},
+		},
+		{
+			name:             "characterClass",
+			pattern:          "/dev/sd[a-c]",
+			expectedPatterns: []string{"/dev/sda", "/dev/sdb", "/dev/sdc"},
+		},
+		{
+			name:             "alternation",
+			pattern:          "/etc/{passwd,shadow}",
+			expectedPatterns: []string{"/etc/passwd", "/etc/shadow"},
+		},
+		{
+			name:             "mixed",
+			pattern:          "/{bin,sbin}/[ab]**",
+			expectedPatterns: []string{"/bin/a**", "/bin/b**", "/sbin/a**", "/sbin/b**"},
+		},
+		{
+			name:             "questionMark",
+			pattern:          "/etc/shado?",
+			expectedPatterns: []string{"/etc/shado**"},
+		},
+		{
+			name:             "questionMarkInFilename",
+			pattern:          "shado?",
+			expectedPatterns: []string{"shado*"},
+		},
+		{
+			name:        "negatedCharacterClass",
+			pattern:     "/dev/sd[!a]",
+			expectedErr: true,
+		},
+		{
+			name:        "unclosedCharacterClass",
+			pattern:     "/dev/sd[a",
+			expectedErr: true,
+		},
+		{
+			name:        "tooManyPatterns",
+			pattern:     "/[a-z]/[a-z]",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patterns, err := expandPathPattern(tc.pattern)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, patterns, tc.expectedPatterns)
+		})
+	}
+}
+
+func Test_GenerateEnhanceProtectProfileRuleID(t *testing.T) {
+	enhanceProtect := varmor.EnhanceProtect{
+		HardeningRules: []string{"disallow-write-core-pattern"},
+		BpfRawRules: varmor.BpfRawRules{
+			Files: []varmor.FileRule{
+				{
+					Pattern:     "/etc/{passwd,shadow}",
+					Permissions: []string{"write"},
+				},
+			},
+		},
+	}
+
+	var bpfContent varmor.BpfContent
+	err := Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+
+	ruleIDs := make(map[string]string)
+	for _, file := range bpfContent.Files {
+		assert.Assert(t, file.RuleID != "")
+		ruleIDs[file.Pattern.Prefix] = file.RuleID
+	}
+	assert.Equal(t, ruleIDs["/proc/sysrq-trigger"], "runtimeDefault")
+	assert.Equal(t, ruleIDs["/proc/sys/kernel/core_pattern"], "hardeningRules/disallow-write-core-pattern")
+	assert.Equal(t, ruleIDs["/etc/passwd"], "bpfRawRules.files/0")
+	assert.Equal(t, ruleIDs["/etc/shadow"], "bpfRawRules.files/0")
+	assert.Equal(t, bpfContent.Ptrace.RuleID, "runtimeDefault")
+}
+
+func Test_GenerateEnhanceProtectProfileHashProcesses(t *testing.T) {
+	digest := "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"
+	enhanceProtect := varmor.EnhanceProtect{
+		BpfRawRules: varmor.BpfRawRules{
+			Processes: []varmor.FileRule{
+				{Pattern: "/usr/bin/app", SHA256: []string{digest}},
+			},
+		},
+	}
+
+	var bpfContent varmor.BpfContent
+	err := Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+	assert.Equal(t, len(bpfContent.Processes), 0)
+	assert.DeepEqual(t, bpfContent.HashProcesses, []varmor.HashProcessContent{
+		{
+			Path:   "/usr/bin/app",
+			SHA256: []string{"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+			RuleID: "bpfRawRules.processes/0",
+		},
+	})
+
+	// The path with globbing isn't supported
+	enhanceProtect.BpfRawRules.Processes[0].Pattern = "/usr/bin/*"
+	err = Build(&enhanceProtect, &varmor.BpfContent{})
+	assert.ErrorContains(t, err, "without globbing")
+}
+
+func Test_GenerateEnhanceProtectProfileNetworkMacros(t *testing.T) {
+	enhanceProtect := varmor.EnhanceProtect{
+		BpfRawRules: varmor.BpfRawRules{
+			Network: varmor.NetworkRule{
+				Egresses: []varmor.NetworkEgressRule{
+					{IPBlock: "@private-ranges"},
+					{IPBlock: "@node-local", Port: 10250},
+				},
+			},
+		},
+	}
+
+	var bpfContent varmor.BpfContent
+	err := Build(&enhanceProtect, &bpfContent)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, bpfContent.Networks, []varmor.NetworkContent{
+		{Flags: CidrMatch, CIDR: "@private-ranges", RuleID: "bpfRawRules.network.egresses/0"},
+		{Flags: CidrMatch | PortMatch, CIDR: "@node-local", Port: 10250, RuleID: "bpfRawRules.network.egresses/1"},
+	})
+
+	enhanceProtect.BpfRawRules.Network.Egresses = []varmor.NetworkEgressRule{{IPBlock: "@public"}}
+	err = Build(&enhanceProtect, &varmor.BpfContent{})
+	assert.ErrorContains(t, err, "unknown network macro '@public'")
+}
+
+func Test_GenerateSandboxProfile(t *testing.T) {
+	bpfContent, err := GenerateSandboxProfile()
+	assert.NilError(t, err)
+
+	assert.Equal(t, bpfContent.Capabilities, uint64((1<<(unix.CAP_LAST_CAP+1))-1))
+	assert.Equal(t, len(bpfContent.Networks), 2)
+	assert.Equal(t, len(bpfContent.Files), 1)
+	assert.Equal(t, bpfContent.Files[0].Permissions, uint32(AaMayWrite|AaMayAppend))
+	assert.Equal(t, len(bpfContent.Processes), 1)
+	assert.Equal(t, bpfContent.Processes[0].Permissions, uint32(AaMayExec))
+	assert.Equal(t, len(bpfContent.Mounts), 2)
+	for _, mount := range bpfContent.Mounts {
+		assert.Equal(t, mount.RuleID, "sandbox")
+	}
+	assert.Equal(t, bpfContent.Processes[0].RuleID, "sandbox")
+	assert.Equal(t, bpfContent.Ptrace.RuleID, "sandbox")
+}
+
+func Test_generateOverlayfsRules(t *testing.T) {
+	newRule := func(pattern string, ruleID string) varmor.FileContent {
+		content, err := NewPathRule(pattern, AaMayWrite)
+		assert.NilError(t, err)
+		content.RuleID = ruleID
+		return *content
+	}
+
+	testCases := []struct {
+		name     string
+		contents []varmor.FileContent
+		expected []varmor.FileContent
+	}{
+		{
+			name:     "precise path",
+			contents: []varmor.FileContent{newRule("/etc/shadow", "bpfRawRules.files/0")},
+			expected: []varmor.FileContent{
+				newRule("/etc/shadow", "bpfRawRules.files/0"),
+				newRule("**/fs/etc/shadow", "bpfRawRules.files/0"),
+				newRule("**/diff/etc/shadow", "bpfRawRules.files/0"),
+			},
+		},
+		{
+			name:     "globbing path",
+			contents: []varmor.FileContent{newRule("/etc/**", "bpfRawRules.files/0")},
+			expected: []varmor.FileContent{newRule("/etc/**", "bpfRawRules.files/0")},
+		},
+		{
+			name:     "filename",
+			contents: []varmor.FileContent{newRule("shadow", "bpfRawRules.files/0")},
+			expected: []varmor.FileContent{newRule("shadow", "bpfRawRules.files/0")},
+		},
+		{
+			name: "mixed",
+			contents: []varmor.FileContent{
+				newRule("/etc/**", "bpfRawRules.files/0"),
+				newRule("/root/.ssh/id_rsa", "bpfRawRules.files/1"),
+			},
+			expected: []varmor.FileContent{
+				newRule("/etc/**", "bpfRawRules.files/0"),
+				newRule("/root/.ssh/id_rsa", "bpfRawRules.files/1"),
+				newRule("**/fs/root/.ssh/id_rsa", "bpfRawRules.files/1"),
+				newRule("**/diff/root/.ssh/id_rsa", "bpfRawRules.files/1"),
+			},
+		},
+		{
+			name: "no rules",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents, err := generateOverlayfsRules(tc.contents)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, contents, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profilebuilder
+
+import (
+	"fmt"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// validateRuleCounts checks whether the count of rules exceeds the capacity of the maps of the BPF enforcer
+func validateRuleCounts(bpfContent *varmor.BpfContent) error {
+	if len(bpfContent.Files) > varmortypes.MaxBpfFileRuleCount {
+		return fmt.Errorf("the maximum number of BPF file rules exceeded(Max Count: %d)", varmortypes.MaxBpfFileRuleCount)
+	}
+
+	// Each rule with SHA256 is expanded into a bprm rule at most
+	if len(bpfContent.Processes)+len(bpfContent.HashProcesses) > varmortypes.MaxBpfBprmRuleCount {
+		return fmt.Errorf("the maximum number of BPF bprm rules exceeded(Max Count: %d)", varmortypes.MaxBpfBprmRuleCount)
+	}
+
+	// Each network peer is expanded into a network rule at least
+	if len(bpfContent.Networks)+len(bpfContent.NetworkPeers) > varmortypes.MaxBpfNetworkRuleCount {
+		return fmt.Errorf("the maximum number of BPF network rules exceeded(Max Count: %d)", varmortypes.MaxBpfNetworkRuleCount)
+	}
+
+	if len(bpfContent.Mounts) > varmortypes.MaxBpfMountRuleCount {
+		return fmt.Errorf("the maximum number of BPF mount rules exceeded(Max Count: %d)", varmortypes.MaxBpfMountRuleCount)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containerd/containerd"
+	"google.golang.org/grpc"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// Endpoint is a containerd instance that the runtime monitor watches
+type Endpoint struct {
+	// Socket is the socket address of the containerd, it serves both the containerd API and the CRI API
+	Socket string
+	// Namespace is the containerd namespace of the containers
+	Namespace string
+}
+
+func (e Endpoint) String() string {
+	return e.Socket + "@" + e.Namespace
+}
+
+// DefaultEndpoints returns the containerd endpoint of Kubernetes on most nodes
+func DefaultEndpoints() []Endpoint {
+	return []Endpoint{{Socket: varmortypes.RuntimeEndpoint, Namespace: varmortypes.K8sCriNamespace}}
+}
+
+// ParseEndpoints parses the comma-separated list of the containerd endpoints in the format of SOCKET[@NAMESPACE].
+// The namespace defaults to k8s.io, and the DefaultEndpoints is returned if the value is empty.
+func ParseEndpoints(value string) ([]Endpoint, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultEndpoints(), nil
+	}
+
+	var endpoints []Endpoint
+	seen := make(map[Endpoint]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		endpoint := Endpoint{Socket: item, Namespace: varmortypes.K8sCriNamespace}
+		if i := strings.LastIndex(item, "@"); i != -1 {
+			endpoint.Socket = item[:i]
+			endpoint.Namespace = item[i+1:]
+		}
+
+		if !strings.HasPrefix(endpoint.Socket, "/") {
+			return nil, fmt.Errorf("the socket of the containerd endpoint %q must be an absolute path", item)
+		}
+		if endpoint.Namespace == "" {
+			return nil, fmt.Errorf("the namespace of the containerd endpoint %q is empty", item)
+		}
+		if seen[endpoint] {
+			continue
+		}
+		seen[endpoint] = true
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if len(endpoints) == 0 {
+		return DefaultEndpoints(), nil
+	}
+	return endpoints, nil
+}
+
+// runtimeEndpoint holds the clients and the status of a containerd endpoint
+type runtimeEndpoint struct {
+	Endpoint
+	containerdClient *containerd.Client
+	runtimeClient    runtimeapi.RuntimeServiceClient
+	runtimeConn      *grpc.ClientConn
+	running          bool
+	status           error
+}
+
+func newRuntimeEndpoint(endpoint Endpoint) (*runtimeEndpoint, error) {
+	var err error
+	e := runtimeEndpoint{Endpoint: endpoint}
+
+	e.containerdClient, err = newContainerdClient(endpoint.Socket, varmortypes.RuntimeTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	e.runtimeClient, e.runtimeConn, err = newRuntimeServiceClient(endpoint.Socket, varmortypes.RuntimeTimeout)
+	if err != nil {
+		e.containerdClient.Close()
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (e *runtimeEndpoint) close() {
+	e.running = false
+	e.containerdClient.Close()
+	e.runtimeConn.Close()
+}
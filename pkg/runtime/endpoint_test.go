@@ -0,0 +1,74 @@
+// Copyright 2023 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_ParseEndpoints(t *testing.T) {
+	testCases := []struct {
+		name              string
+		value             string
+		expectedEndpoints []Endpoint
+		expectedErr       bool
+	}{
+		{
+			name:              "empty",
+			value:             "",
+			expectedEndpoints: DefaultEndpoints(),
+		},
+		{
+			name:  "default namespace",
+			value: "/run/containerd/containerd.sock,/run/k3s/containerd/containerd.sock",
+			expectedEndpoints: []Endpoint{
+				{Socket: "/run/containerd/containerd.sock", Namespace: "k8s.io"},
+				{Socket: "/run/k3s/containerd/containerd.sock", Namespace: "k8s.io"},
+			},
+		},
+		{
+			name:  "custom namespace and duplicates",
+			value: "/run/containerd/containerd.sock@custom, /run/containerd/containerd.sock@custom,/run/containerd/containerd.sock",
+			expectedEndpoints: []Endpoint{
+				{Socket: "/run/containerd/containerd.sock", Namespace: "custom"},
+				{Socket: "/run/containerd/containerd.sock", Namespace: "k8s.io"},
+			},
+		},
+		{
+			name:        "relative socket",
+			value:       "containerd.sock",
+			expectedErr: true,
+		},
+		{
+			name:        "empty namespace",
+			value:       "/run/containerd/containerd.sock@",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoints, err := ParseEndpoints(tc.value)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, endpoints, tc.expectedEndpoints)
+		})
+	}
+}
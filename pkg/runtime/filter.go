@@ -0,0 +1,124 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+// podNamespaceLabel is the label that kubelet adds to the containers, it's used to filter the containers before
+// their pod info is retrieved via CRI
+const podNamespaceLabel = "io.kubernetes.pod.namespace"
+
+// EventFilter selects the containers whose events are handled by the runtime monitor, the others are skipped as
+// early as possible. The zero value selects all the containers.
+type EventFilter struct {
+	// Namespaces are the Kubernetes namespaces of the pods to handle, all namespaces if empty.
+	Namespaces []string
+	// ExcludedNamespaces are the Kubernetes namespaces of the pods to skip.
+	ExcludedNamespaces []string
+	// PodSelector selects the pods to handle with their labels, all pods if nil.
+	PodSelector labels.Selector
+	// AnnotationPrefixes skips the pods that have no annotation whose key has one of the prefixes, e.g.
+	// "container.bpf.security.beta.varmor.org/". All pods are handled if it's empty.
+	AnnotationPrefixes []string
+}
+
+// ParseEventFilter parses the comma-separated lists of the namespaces, the excluded namespaces and the annotation
+// prefixes, and the label selector of the pods into the EventFilter.
+func ParseEventFilter(namespaces, excludedNamespaces, podSelector, annotationPrefixes string) (EventFilter, error) {
+	filter := EventFilter{
+		Namespaces:         splitList(namespaces),
+		ExcludedNamespaces: splitList(excludedNamespaces),
+		AnnotationPrefixes: splitList(annotationPrefixes),
+	}
+
+	if strings.TrimSpace(podSelector) != "" {
+		selector, err := labels.Parse(podSelector)
+		if err != nil {
+			return filter, fmt.Errorf("failed to parse the pod selector %q: %w", podSelector, err)
+		}
+		filter.PodSelector = selector
+	}
+	return filter, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// matchNamespace returns whether the Kubernetes namespace is selected. The empty namespace is selected, since it's
+// unknown before the pod info is retrieved.
+func (f *EventFilter) matchNamespace(namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	for _, ns := range f.ExcludedNamespaces {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(f.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range f.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPod returns whether the pod of the container is selected
+func (f *EventFilter) matchPod(info *varmortypes.ContainerInfo) bool {
+	if !f.matchNamespace(info.PodNamespace) {
+		return false
+	}
+	if f.PodSelector != nil && !f.PodSelector.Matches(labels.Set(info.PodLabels)) {
+		return false
+	}
+	if len(f.AnnotationPrefixes) == 0 {
+		return true
+	}
+	for key := range info.PodAnnotations {
+		for _, prefix := range f.AnnotationPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventFilters returns the filters of the containerd events that the monitor subscribes to. The events are filtered
+// by the containerd namespace on the server side, so the ones of the other clients (e.g. moby and buildkit) aren't
+// sent to the monitor.
+func eventFilters(namespace string) []string {
+	return []string{
+		fmt.Sprintf(`topic=="/tasks/create",namespace==%q`, namespace),
+		fmt.Sprintf(`topic=="/tasks/delete",namespace==%q`, namespace),
+	}
+}
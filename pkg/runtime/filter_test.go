@@ -0,0 +1,60 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_EventFilter(t *testing.T) {
+	filter, err := ParseEventFilter("", "kube-system", "app in (web, api)", "container.bpf.security.beta.varmor.org/")
+	assert.NilError(t, err)
+
+	info := varmortypes.ContainerInfo{
+		PodNamespace:   "demo",
+		PodLabels:      map[string]string{"app": "web"},
+		PodAnnotations: map[string]string{"container.bpf.security.beta.varmor.org/c0": "localhost/varmor-demo-web"},
+	}
+	assert.Equal(t, filter.matchNamespace(""), true)
+	assert.Equal(t, filter.matchNamespace("kube-system"), false)
+	assert.Equal(t, filter.matchPod(&info), true)
+
+	info.PodLabels["app"] = "db"
+	assert.Equal(t, filter.matchPod(&info), false)
+
+	info.PodLabels["app"] = "api"
+	info.PodAnnotations = nil
+	assert.Equal(t, filter.matchPod(&info), false)
+
+	filter, err = ParseEventFilter("demo, prod", "", "", "")
+	assert.NilError(t, err)
+	assert.Equal(t, filter.matchNamespace("demo"), true)
+	assert.Equal(t, filter.matchNamespace("test"), false)
+	assert.Equal(t, filter.matchPod(&info), true)
+
+	_, err = ParseEventFilter("", "", "app in (web", "")
+	assert.Assert(t, err != nil)
+}
+
+func Test_eventFilters(t *testing.T) {
+	assert.DeepEqual(t, eventFilters("k8s.io"), []string{
+		`topic=="/tasks/create",namespace=="k8s.io"`,
+		`topic=="/tasks/delete",namespace=="k8s.io"`,
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// serviceAccountTokenPath is the path of the service account token projected into the containers by kubelet
+const serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// serviceAccountSubjectPrefix is the prefix of the subject of the service account tokens
+const serviceAccountSubjectPrefix = "system:serviceaccount:"
+
+// parseServiceAccountToken returns the namespace and name of the service account from the subject of the token.
+// The signature isn't verified, the token is only used to enrich the events with the identity of the workload.
+func parseServiceAccountToken(token string) (string, string, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("the token isn't a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode the payload of the token: %w", err)
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to unmarshal the claims of the token: %w", err)
+	}
+
+	if !strings.HasPrefix(claims.Subject, serviceAccountSubjectPrefix) {
+		return "", "", fmt.Errorf("the subject %q isn't a service account", claims.Subject)
+	}
+	subject := strings.Split(claims.Subject[len(serviceAccountSubjectPrefix):], ":")
+	if len(subject) != 2 || subject[0] == "" || subject[1] == "" {
+		return "", "", fmt.Errorf("the subject %q isn't a service account", claims.Subject)
+	}
+	return subject[0], subject[1], nil
+}
+
+// retrieveServiceAccount reads the service account of the container from the token projected into it. It's empty
+// if the token isn't mounted, or it doesn't belong to the namespace of the pod.
+func retrieveServiceAccount(pid uint32, podNamespace string) string {
+	if pid == 0 {
+		return ""
+	}
+
+	token, err := os.ReadFile(filepath.Join("/proc", fmt.Sprint(pid), "root", serviceAccountTokenPath))
+	if err != nil {
+		return ""
+	}
+
+	namespace, name, err := parseServiceAccountToken(string(token))
+	if err != nil || namespace != podNamespace {
+		return ""
+	}
+	return name
+}
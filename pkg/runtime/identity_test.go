@@ -0,0 +1,77 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func newTestToken(payload string) string {
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+}
+
+func Test_parseServiceAccountToken(t *testing.T) {
+	testCases := []struct {
+		name              string
+		token             string
+		expectedNamespace string
+		expectedName      string
+		expectedErr       bool
+	}{
+		{
+			name:              "projected token",
+			token:             newTestToken(`{"iss":"https://kubernetes.default.svc","sub":"system:serviceaccount:demo:web","kubernetes.io":{"namespace":"demo"}}`),
+			expectedNamespace: "demo",
+			expectedName:      "web",
+		},
+		{
+			name:              "trailing newline",
+			token:             newTestToken(`{"sub":"system:serviceaccount:demo:default"}`) + "\n",
+			expectedNamespace: "demo",
+			expectedName:      "default",
+		},
+		{
+			name:        "user subject",
+			token:       newTestToken(`{"sub":"alice"}`),
+			expectedErr: true,
+		},
+		{
+			name:        "malformed subject",
+			token:       newTestToken(`{"sub":"system:serviceaccount:demo"}`),
+			expectedErr: true,
+		},
+		{
+			name:        "not a JWT",
+			token:       "token",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			namespace, name, err := parseServiceAccountToken(tc.token)
+			if tc.expectedErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, namespace, tc.expectedNamespace)
+			assert.Equal(t, name, tc.expectedName)
+		})
+	}
+}
@@ -17,51 +17,78 @@ package runtime
 import (
 	"context"
 	"encoding/json"
+	"expvar"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/api/events"
 	tasks "github.com/containerd/containerd/api/services/tasks/v1"
 	"github.com/containerd/typeurl/v2"
 	"github.com/go-logr/logr"
 	runtimespec "github.com/opencontainers/runtime-spec/specs-go"
-	"google.golang.org/grpc"
 	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
 
 	varmortypes "github.com/bytedance/vArmor/pkg/types"
 )
 
+// metrics of the runtime monitor, they are published with expvar
+var (
+	metrics           = expvar.NewMap("varmor_runtime_monitor")
+	taskCreateDropped = new(expvar.Int)
+	taskDeleteDropped = new(expvar.Int)
+	taskResyncs       = new(expvar.Int)
+	taskFiltered      = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("task_create_dropped_total", taskCreateDropped)
+	metrics.Set("task_delete_dropped_total", taskDeleteDropped)
+	metrics.Set("task_resyncs_total", taskResyncs)
+	metrics.Set("task_filtered_total", taskFiltered)
+}
+
 type RuntimeMonitor struct {
-	containerdClient *containerd.Client
-	runtimeClient    runtimeapi.RuntimeServiceClient
-	runtimeConn      *grpc.ClientConn
-	running          bool
-	status           error
+	endpoints        []*runtimeEndpoint
 	taskCreateCh     chan<- varmortypes.ContainerInfo
 	taskDeleteCh     chan<- varmortypes.ContainerInfo
 	taskDeleteSyncCh chan<- bool
+	resyncCh         chan struct{}
 	modellerChs      map[string]chan<- uint32
-	log              logr.Logger
+	detectorChs      map[string]map[string]chan<- varmortypes.ContainerInfo
+	// forwardAll sends all the containers to the enforcer instead of the ones with the BPF profile annotation
+	forwardAll bool
+	// filter selects the containers whose events are handled
+	filter EventFilter
+	log    logr.Logger
 }
 
 func NewRuntimeMonitor(log logr.Logger) (*RuntimeMonitor, error) {
-	var err error
+	return NewRuntimeMonitorWithEndpoints(DefaultEndpoints(), log)
+}
+
+// NewRuntimeMonitorWithEndpoints creates a runtime monitor that watches the containerd endpoints concurrently,
+// their events are merged into the same task notify channels.
+func NewRuntimeMonitorWithEndpoints(endpoints []Endpoint, log logr.Logger) (*RuntimeMonitor, error) {
+	if len(endpoints) == 0 {
+		endpoints = DefaultEndpoints()
+	}
 
 	monitor := RuntimeMonitor{
+		resyncCh:    make(chan struct{}, 1),
 		modellerChs: make(map[string]chan<- uint32),
+		detectorChs: make(map[string]map[string]chan<- varmortypes.ContainerInfo),
 		log:         log,
 	}
 
-	monitor.containerdClient, err = newContainerdClient(varmortypes.RuntimeEndpoint, varmortypes.RuntimeTimeout)
-	if err != nil {
-		return nil, err
-	}
-
-	monitor.runtimeClient, monitor.runtimeConn, err = newRuntimeServiceClient(varmortypes.RuntimeEndpoint, varmortypes.RuntimeTimeout)
-	if err != nil {
-		return nil, err
+	for _, endpoint := range endpoints {
+		e, err := newRuntimeEndpoint(endpoint)
+		if err != nil {
+			monitor.Close()
+			return nil, fmt.Errorf("failed to connect to the containerd endpoint %s: %w", endpoint, err)
+		}
+		monitor.endpoints = append(monitor.endpoints, e)
 	}
 
 	return &monitor, nil
@@ -69,9 +96,9 @@ func NewRuntimeMonitor(log logr.Logger) (*RuntimeMonitor, error) {
 
 func (monitor *RuntimeMonitor) Close() {
 	monitor.log.Info("close the connection between the containerd and agent")
-	monitor.running = false
-	monitor.containerdClient.Close()
-	monitor.runtimeConn.Close()
+	for _, e := range monitor.endpoints {
+		e.close()
+	}
 }
 
 func (monitor *RuntimeMonitor) SetTaskNotifyChs(
@@ -83,6 +110,89 @@ func (monitor *RuntimeMonitor) SetTaskNotifyChs(
 	monitor.taskDeleteSyncCh = deleteSynCh
 }
 
+// SetEventFilter sets the filter that selects the containers whose events are handled, the others are skipped
+// before they're sent to the enforcer and the detectors. It must be called before the monitor runs.
+func (monitor *RuntimeMonitor) SetEventFilter(filter EventFilter) {
+	monitor.filter = filter
+}
+
+// ForwardAllContainers makes the monitor send all the containers to the enforcer, not only the ones with the BPF
+// profile annotation. It's used when the enforcer enforces a default profile on the containers without any profile.
+// It must be called before the monitor runs.
+func (monitor *RuntimeMonitor) ForwardAllContainers() {
+	monitor.forwardAll = true
+}
+
+// sendTaskCreate sends the task create event to the enforcer without blocking the monitor. The event is
+// dropped if the channel is full, and a resync is requested to recover it later.
+func (monitor *RuntimeMonitor) sendTaskCreate(info varmortypes.ContainerInfo) {
+	if monitor.taskCreateCh == nil {
+		return
+	}
+
+	select {
+	case monitor.taskCreateCh <- info:
+	default:
+		taskCreateDropped.Add(1)
+		monitor.log.Info("the task create channel is full, drop the event and request a resync",
+			"container id", info.ContainerID, "pid", info.PID)
+		monitor.requestResync()
+	}
+}
+
+// sendTaskDelete sends the task delete event to the enforcer without blocking the monitor. The event is
+// dropped if the channel is full, and a resync is requested to recover it later.
+func (monitor *RuntimeMonitor) sendTaskDelete(info varmortypes.ContainerInfo) {
+	if monitor.taskDeleteCh == nil {
+		return
+	}
+
+	select {
+	case monitor.taskDeleteCh <- info:
+	default:
+		taskDeleteDropped.Add(1)
+		monitor.log.Info("the task delete channel is full, drop the event and request a resync",
+			"container id", info.ContainerID, "pid", info.PID)
+		monitor.requestResync()
+	}
+}
+
+// requestResync requests to resync the containers with the enforcer, the requests are coalesced
+func (monitor *RuntimeMonitor) requestResync() {
+	select {
+	case monitor.resyncCh <- struct{}{}:
+	default:
+	}
+}
+
+// resyncHandler notifies the enforcer to handle the containers that exited, and collects the existing
+// containers again when the task events were dropped or the monitor was offline
+func (monitor *RuntimeMonitor) resyncHandler(stopCh <-chan struct{}) {
+	logger := monitor.log.WithName("resyncHandler()")
+
+	for {
+		select {
+		case <-monitor.resyncCh:
+			if monitor.taskDeleteSyncCh == nil {
+				continue
+			}
+
+			time.Sleep(varmortypes.TaskResyncDelay)
+
+			logger.Info("notify the enforcer to handle the containers that exit or are created while the events were missed")
+			taskResyncs.Add(1)
+			monitor.taskDeleteSyncCh <- true
+			err := monitor.CollectExistingTargetContainers()
+			if err != nil {
+				logger.Error(err, "CollectExistingTargetContainers() failed")
+			}
+
+		case <-stopCh:
+			return
+		}
+	}
+}
+
 func (monitor *RuntimeMonitor) AddModellerChs(profileName string, ch chan uint32) {
 	monitor.modellerChs[profileName] = ch
 }
@@ -91,11 +201,53 @@ func (monitor *RuntimeMonitor) DeleteModellerChs(profileName string) {
 	delete(monitor.modellerChs, profileName)
 }
 
-func (monitor *RuntimeMonitor) retrieveContainerInfo(containerInfo *varmortypes.ContainerInfo) error {
-	ctx, cancel := appContext(context.Background(), varmortypes.K8sCriNamespace, varmortypes.RuntimeTimeout)
+// AddDetectorChs registers the channel of a named detector that is interested in the containers of the profile
+func (monitor *RuntimeMonitor) AddDetectorChs(name string, profileName string, ch chan varmortypes.ContainerInfo) {
+	if _, ok := monitor.detectorChs[profileName]; !ok {
+		monitor.detectorChs[profileName] = make(map[string]chan<- varmortypes.ContainerInfo)
+	}
+	monitor.detectorChs[profileName][name] = ch
+}
+
+func (monitor *RuntimeMonitor) DeleteDetectorChs(name string, profileName string) {
+	if chs, ok := monitor.detectorChs[profileName]; ok {
+		delete(chs, name)
+		if len(chs) == 0 {
+			delete(monitor.detectorChs, profileName)
+		}
+	}
+}
+
+// notifyDetector sends the target container to the detectors of its profile
+func (monitor *RuntimeMonitor) notifyDetector(info *varmortypes.ContainerInfo) {
+	if len(monitor.detectorChs) == 0 {
+		return
+	}
+
+	for _, key := range []string{
+		fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", info.ContainerName),
+		fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", info.ContainerName),
+		fmt.Sprintf("container.seccomp.security.beta.varmor.org/%s", info.ContainerName),
+	} {
+		if value, ok := info.PodAnnotations[key]; ok {
+			if strings.HasPrefix(value, "localhost/") {
+				profileName := value[len("localhost/"):]
+				if chs, ok := monitor.detectorChs[profileName]; ok {
+					for _, ch := range chs {
+						ch <- *info
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (monitor *RuntimeMonitor) retrieveContainerInfo(e *runtimeEndpoint, containerInfo *varmortypes.ContainerInfo) error {
+	ctx, cancel := appContext(context.Background(), e.Namespace, varmortypes.RuntimeTimeout)
 	defer cancel()
 
-	container, err := monitor.containerdClient.LoadContainer(ctx, containerInfo.ContainerID)
+	container, err := e.containerdClient.LoadContainer(ctx, containerInfo.ContainerID)
 	if err != nil {
 		return err
 	}
@@ -107,6 +259,9 @@ func (monitor *RuntimeMonitor) retrieveContainerInfo(containerInfo *varmortypes.
 		return fmt.Errorf("unsupported runtime type: %s", info.Runtime.Name)
 	}
 
+	containerInfo.Image = info.Image
+	containerInfo.PodNamespace = info.Labels[podNamespaceLabel]
+
 	var spec runtimespec.Spec
 	if info.Spec != nil {
 		err = json.Unmarshal(info.Spec.GetValue(), &spec)
@@ -117,6 +272,9 @@ func (monitor *RuntimeMonitor) retrieveContainerInfo(containerInfo *varmortypes.
 
 	if containerType, ok := spec.Annotations["io.kubernetes.cri.container-type"]; ok {
 		if containerType == "sandbox" {
+			// The ID of the sandbox container is the ID of the pod sandbox
+			containerInfo.Sandbox = true
+			containerInfo.PodID = containerInfo.ContainerID
 			return nil
 		}
 	} else {
@@ -138,14 +296,14 @@ func (monitor *RuntimeMonitor) retrieveContainerInfo(containerInfo *varmortypes.
 	return nil
 }
 
-func (monitor *RuntimeMonitor) retrievePodInfo(containerInfo *varmortypes.ContainerInfo) error {
+func (monitor *RuntimeMonitor) retrievePodInfo(e *runtimeEndpoint, containerInfo *varmortypes.ContainerInfo) error {
 	ctx, cancel := getContextWithTimeout(context.Background(), varmortypes.RuntimeTimeout)
 	defer cancel()
 
 	request := &runtimeapi.PodSandboxStatusRequest{
 		PodSandboxId: containerInfo.PodID,
 	}
-	response, err := monitor.runtimeClient.PodSandboxStatus(ctx, request)
+	response, err := e.runtimeClient.PodSandboxStatus(ctx, request)
 	if err != nil {
 		return err
 	}
@@ -154,22 +312,24 @@ func (monitor *RuntimeMonitor) retrievePodInfo(containerInfo *varmortypes.Contai
 	containerInfo.PodNamespace = response.Status.Metadata.Namespace
 	containerInfo.PodUID = response.Status.Metadata.Uid
 	containerInfo.PodAnnotations = response.Status.Annotations
+	containerInfo.PodLabels = podLabels(response.Status.Labels)
+	containerInfo.ServiceAccount = retrieveServiceAccount(containerInfo.PID, containerInfo.PodNamespace)
 
 	return nil
 }
 
-// eventHandler monitor the create and delete events of containerd and send them to the enforcer to handle
-func (monitor *RuntimeMonitor) eventHandler(stopCh <-chan struct{}) {
-	logger := monitor.log.WithName("eventHandler()")
+// eventHandler monitor the create and delete events of the containerd endpoint and send them to the enforcer to handle
+func (monitor *RuntimeMonitor) eventHandler(endpoint *runtimeEndpoint, stopCh <-chan struct{}) {
+	logger := monitor.log.WithName("eventHandler()").WithValues("endpoint", endpoint.String())
 	logger.Info("start watching the containerd events")
 
-	ctx, cancel := appContext(context.Background(), varmortypes.K8sCriNamespace, 0)
+	ctx, cancel := appContext(context.Background(), endpoint.Namespace, 0)
 	defer cancel()
 
-	eventsFilter := []string{`topic=="/tasks/create"`, `topic=="/tasks/delete"`}
-	eventsService := monitor.containerdClient.EventService()
+	eventsFilter := eventFilters(endpoint.Namespace)
+	eventsService := endpoint.containerdClient.EventService()
 	eventsCh, errCh := eventsService.Subscribe(ctx, eventsFilter...)
-	monitor.running = true
+	endpoint.running = true
 
 	for {
 		select {
@@ -190,30 +350,49 @@ func (monitor *RuntimeMonitor) eventHandler(stopCh <-chan struct{}) {
 				info := varmortypes.ContainerInfo{
 					PID:         createEvent.Pid,
 					ContainerID: createEvent.ContainerID,
+					CreatedAt:   e.Timestamp,
 				}
 
-				err = monitor.retrieveContainerInfo(&info)
+				err = monitor.retrieveContainerInfo(endpoint, &info)
 				if err != nil {
 					logger.Error(err, "monitor.retrieveContainerInfo() failed", "container id", createEvent.ContainerID, "pid", createEvent.Pid)
 					continue
-				} else if info.PodID == "" {
-					logger.V(3).Info("sandbox was created, just ignore it")
+				}
+
+				// Skip the containers that aren't selected before retrieving their pod info via CRI
+				if !monitor.filter.matchNamespace(info.PodNamespace) {
+					taskFiltered.Add(1)
 					continue
 				}
 
-				err = monitor.retrievePodInfo(&info)
+				err = monitor.retrievePodInfo(endpoint, &info)
 				if err != nil {
 					logger.Error(err, "monitor.retrievePodInfo() failed", "pod id", info.PodID)
 					continue
 				}
 
+				if !monitor.filter.matchPod(&info) {
+					taskFiltered.Add(1)
+					continue
+				}
+
+				// The sandbox containers are only sent to the enforcer if the pod specifies their profile
+				if info.Sandbox {
+					if _, ok := info.PodAnnotations[varmortypes.SandboxBpfAnnotation]; ok {
+						monitor.sendTaskCreate(info)
+					} else {
+						logger.V(3).Info("sandbox was created, just ignore it")
+					}
+					continue
+				}
+
 				logger.V(3).Info("/tasks/create event", "info", info)
 
+				monitor.notifyDetector(&info)
+
 				key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", info.ContainerName)
-				if _, ok := info.PodAnnotations[key]; ok {
-					if monitor.taskCreateCh != nil {
-						monitor.taskCreateCh <- info
-					}
+				if _, ok := info.PodAnnotations[key]; ok || monitor.forwardAll {
+					monitor.sendTaskCreate(info)
 				}
 
 				key = fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", info.ContainerName)
@@ -251,36 +430,31 @@ func (monitor *RuntimeMonitor) eventHandler(stopCh <-chan struct{}) {
 				}
 
 				logger.V(3).Info("/tasks/delete event", "info", info)
-				if monitor.taskDeleteCh != nil {
-					monitor.taskDeleteCh <- info
-				}
+				monitor.sendTaskDelete(info)
 			}
 
 		case err := <-errCh:
 			logger.Error(err, "receive an error from the containerd, waiting for it to resume serving")
-			monitor.running = false
-			monitor.status = err
+			endpoint.running = false
+			endpoint.status = err
 
-			serving, err := monitor.containerdClient.IsServing(ctx)
+			serving, err := endpoint.containerdClient.IsServing(ctx)
 			if err != nil {
 				logger.Error(err, "containerdClient.IsServing() failed")
-				monitor.status = err
+				endpoint.status = err
 				return
 			} else if serving {
 				// kindly hold on until containerd is fully initialized
 				time.Sleep(time.Second * 3)
 
 				logger.Info("restart watching the containerd events")
-				eventsService = monitor.containerdClient.EventService()
+				eventsService = endpoint.containerdClient.EventService()
 				eventsCh, errCh = eventsService.Subscribe(ctx, eventsFilter...)
-				monitor.running = true
-				monitor.status = nil
+				endpoint.running = true
+				endpoint.status = nil
 
-				if monitor.taskDeleteSyncCh != nil {
-					logger.V(3).Info("notify the enforcer to handle the containers that exit or are created while the monitor is offline")
-					monitor.taskDeleteSyncCh <- true
-					monitor.CollectExistingTargetContainers()
-				}
+				// handle the containers that exit or are created while the monitor is offline
+				monitor.requestResync()
 			} else {
 				logger.Info("the containerd isn't serving")
 				return
@@ -293,12 +467,30 @@ func (monitor *RuntimeMonitor) eventHandler(stopCh <-chan struct{}) {
 	}
 }
 
+// Run watches the events of all the containerd endpoints until the stopCh is closed
 func (monitor *RuntimeMonitor) Run(stopCh <-chan struct{}) {
-	monitor.eventHandler(stopCh)
+	go monitor.resyncHandler(stopCh)
+
+	var wg sync.WaitGroup
+	for _, e := range monitor.endpoints {
+		wg.Add(1)
+		go func(e *runtimeEndpoint) {
+			defer wg.Done()
+			monitor.eventHandler(e, stopCh)
+		}(e)
+	}
+	wg.Wait()
 }
 
+// IsMonitoring reports whether all the containerd endpoints are being watched, and returns
+// the error of the first endpoint that isn't
 func (monitor *RuntimeMonitor) IsMonitoring() (bool, error) {
-	return monitor.running, monitor.status
+	for _, e := range monitor.endpoints {
+		if !e.running {
+			return false, e.status
+		}
+	}
+	return len(monitor.endpoints) != 0, nil
 }
 
 // CollectExistingTargetContainers collects all existing containers that should be protected
@@ -307,10 +499,41 @@ func (monitor *RuntimeMonitor) CollectExistingTargetContainers() error {
 	logger := monitor.log.WithName("CollectExistingTargetContainers()")
 	logger.Info("start collecting the existing containers")
 
-	ctx, cancel := appContext(context.Background(), varmortypes.K8sCriNamespace, varmortypes.RuntimeTimeout)
+	return monitor.collectTargetContainers("", logger)
+}
+
+// CollectTargetContainersOfProfile collects the running containers that should be protected by the
+// BPF profile and sends them to the enforcer. It's used to protect the containers that were started
+// before the agent received the profile.
+func (monitor *RuntimeMonitor) CollectTargetContainersOfProfile(profileName string) error {
+	logger := monitor.log.WithName("CollectTargetContainersOfProfile()")
+	logger.Info("start collecting the running containers of the profile", "profile name", profileName)
+
+	return monitor.collectTargetContainers(profileName, logger)
+}
+
+// collectTargetContainers lists the running tasks via all the containerd endpoints, and sends the containers which
+// have the BPF profile annotation (or all the containers if forwardAll is set) to the enforcer. Only the containers
+// of the profile are sent if profileName isn't empty. The endpoints that fail are skipped, and the first error is returned after the others are collected.
+func (monitor *RuntimeMonitor) collectTargetContainers(profileName string, logger logr.Logger) error {
+	var firstErr error
+	for _, e := range monitor.endpoints {
+		err := monitor.collectTargetContainersOfEndpoint(e, profileName, logger.WithValues("endpoint", e.String()))
+		if err != nil {
+			logger.Error(err, "failed to collect the containers", "endpoint", e.String())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (monitor *RuntimeMonitor) collectTargetContainersOfEndpoint(e *runtimeEndpoint, profileName string, logger logr.Logger) error {
+	ctx, cancel := appContext(context.Background(), e.Namespace, varmortypes.RuntimeTimeout)
 	defer cancel()
 
-	service := monitor.containerdClient.TaskService()
+	service := e.containerdClient.TaskService()
 	response, err := service.List(ctx, &tasks.ListTasksRequest{})
 	if err != nil {
 		return err
@@ -325,28 +548,46 @@ func (monitor *RuntimeMonitor) CollectExistingTargetContainers() error {
 			PID:         task.Pid,
 			ContainerID: task.ID,
 		}
-		err = monitor.retrieveContainerInfo(&info)
+		err = monitor.retrieveContainerInfo(e, &info)
 		if err != nil {
 			logger.Error(err, "monitor.retrieveContainerInfo() failed", "container id", info.ContainerID, "pid", info.PID)
 			continue
-		} else if info.PodID == "" {
-			logger.V(3).Info("sandbox was created, just ignore it", "container id", info.ContainerID, "pid", info.PID)
+		} else if !monitor.filter.matchNamespace(info.PodNamespace) {
 			continue
 		}
 
-		err = monitor.retrievePodInfo(&info)
+		err = monitor.retrievePodInfo(e, &info)
 		if err != nil {
 			logger.Error(err, "monitor.retrievePodInfo() failed", "pod id", info.PodID)
 			continue
+		} else if !monitor.filter.matchPod(&info) {
+			continue
 		}
 
-		key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", info.ContainerName)
-		if _, ok := info.PodAnnotations[key]; ok {
-			if monitor.taskCreateCh != nil {
-				monitor.taskCreateCh <- info
-			}
+		if !info.Sandbox && profileName == "" {
+			monitor.notifyDetector(&info)
+		}
+
+		if monitor.isCollectedTarget(&info, profileName) && monitor.taskCreateCh != nil {
+			monitor.taskCreateCh <- info
 		}
 	}
 
 	return nil
 }
+
+// isCollectedTarget checks whether the running container is sent to the enforcer when collecting the containers.
+// Only the containers of the profile are sent if profileName isn't empty.
+func (monitor *RuntimeMonitor) isCollectedTarget(info *varmortypes.ContainerInfo, profileName string) bool {
+	if info.Sandbox {
+		_, ok := info.PodAnnotations[varmortypes.SandboxBpfAnnotation]
+		return ok && (profileName == "" || profileName == varmortypes.SandboxProfileName)
+	}
+
+	key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", info.ContainerName)
+	value, ok := info.PodAnnotations[key]
+	if profileName != "" {
+		return ok && value == "localhost/"+profileName
+	}
+	return ok || monitor.forwardAll
+}
@@ -18,6 +18,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
 	"gotest.tools/assert"
 	"k8s.io/klog/v2/klogr"
 	log "sigs.k8s.io/controller-runtime/pkg/log"
@@ -68,8 +69,9 @@ LOOP:
 		case <-syncCh:
 			log.Log.Info("recv syncCh")
 		case <-stopTicker.C:
-			assert.Equal(t, monitor.running, true)
-			assert.Equal(t, monitor.status, nil)
+			monitoring, err := monitor.IsMonitoring()
+			assert.Equal(t, monitoring, true)
+			assert.NilError(t, err)
 			monitor.Close()
 			break LOOP
 		}
@@ -105,8 +107,9 @@ LOOP:
 		case info := <-deleteCh:
 			log.Log.Info("recevie /task/delete event", "info", info)
 		case <-stopTicker.C:
-			assert.Equal(t, monitor.running, true)
-			assert.Equal(t, monitor.status, nil)
+			monitoring, err := monitor.IsMonitoring()
+			assert.Equal(t, monitoring, true)
+			assert.NilError(t, err)
 			monitor.Close()
 			break LOOP
 		}
@@ -115,3 +118,112 @@ LOOP:
 	monitoring, _ := monitor.IsMonitoring()
 	assert.Equal(t, monitoring, false)
 }
+
+func Test_sendTaskEvents(t *testing.T) {
+	createCh := make(chan varmortypes.ContainerInfo, 1)
+	deleteCh := make(chan varmortypes.ContainerInfo, 1)
+	monitor := &RuntimeMonitor{
+		resyncCh: make(chan struct{}, 1),
+		log:      logr.Discard(),
+	}
+
+	// The events are skipped without the channels
+	monitor.sendTaskCreate(varmortypes.ContainerInfo{ContainerID: "c0"})
+	assert.Equal(t, len(monitor.resyncCh), 0)
+
+	monitor.SetTaskNotifyChs(createCh, deleteCh, make(chan bool, 1))
+	created := taskCreateDropped.Value()
+	deleted := taskDeleteDropped.Value()
+
+	monitor.sendTaskCreate(varmortypes.ContainerInfo{ContainerID: "c1"})
+	monitor.sendTaskDelete(varmortypes.ContainerInfo{ContainerID: "c1"})
+	assert.Equal(t, len(monitor.resyncCh), 0)
+
+	// The events are dropped instead of blocking the monitor, and the resyncs are coalesced
+	monitor.sendTaskCreate(varmortypes.ContainerInfo{ContainerID: "c2"})
+	monitor.sendTaskDelete(varmortypes.ContainerInfo{ContainerID: "c2"})
+	assert.Equal(t, taskCreateDropped.Value(), created+1)
+	assert.Equal(t, taskDeleteDropped.Value(), deleted+1)
+	assert.Equal(t, len(monitor.resyncCh), 1)
+
+	assert.Equal(t, (<-createCh).ContainerID, "c1")
+	assert.Equal(t, (<-deleteCh).ContainerID, "c1")
+}
+
+func Test_isCollectedTarget(t *testing.T) {
+	annotations := map[string]string{
+		"container.bpf.security.beta.varmor.org/c1": "localhost/p1",
+	}
+	sandboxAnnotations := map[string]string{
+		varmortypes.SandboxBpfAnnotation: "localhost/" + varmortypes.SandboxProfileName,
+	}
+
+	testCases := []struct {
+		name        string
+		info        varmortypes.ContainerInfo
+		profileName string
+		forwardAll  bool
+		expected    bool
+	}{
+		{
+			name:     "target container",
+			info:     varmortypes.ContainerInfo{ContainerName: "c1", PodAnnotations: annotations},
+			expected: true,
+		},
+		{
+			name: "other container",
+			info: varmortypes.ContainerInfo{ContainerName: "c2", PodAnnotations: annotations},
+		},
+		{
+			name:       "other container forwarded",
+			info:       varmortypes.ContainerInfo{ContainerName: "c2", PodAnnotations: annotations},
+			forwardAll: true,
+			expected:   true,
+		},
+		{
+			name:        "target container of the profile",
+			info:        varmortypes.ContainerInfo{ContainerName: "c1", PodAnnotations: annotations},
+			profileName: "p1",
+			expected:    true,
+		},
+		{
+			name:        "target container of the other profile",
+			info:        varmortypes.ContainerInfo{ContainerName: "c1", PodAnnotations: annotations},
+			profileName: "p2",
+		},
+		{
+			name:        "other container isn't forwarded for the profile",
+			info:        varmortypes.ContainerInfo{ContainerName: "c2", PodAnnotations: annotations},
+			profileName: "p1",
+			forwardAll:  true,
+		},
+		{
+			name:     "sandbox container",
+			info:     varmortypes.ContainerInfo{Sandbox: true, PodAnnotations: sandboxAnnotations},
+			expected: true,
+		},
+		{
+			name:        "sandbox container of the sandbox profile",
+			info:        varmortypes.ContainerInfo{Sandbox: true, PodAnnotations: sandboxAnnotations},
+			profileName: varmortypes.SandboxProfileName,
+			expected:    true,
+		},
+		{
+			name:        "sandbox container of the other profile",
+			info:        varmortypes.ContainerInfo{Sandbox: true, PodAnnotations: sandboxAnnotations},
+			profileName: "p1",
+		},
+		{
+			name:       "sandbox container without the annotation",
+			info:       varmortypes.ContainerInfo{Sandbox: true, PodAnnotations: annotations},
+			forwardAll: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			monitor := &RuntimeMonitor{forwardAll: tc.forwardAll}
+			assert.Equal(t, monitor.isCollectedTarget(&tc.info, tc.profileName), tc.expected)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/containerd/nri/pkg/api"
+	"github.com/containerd/nri/pkg/stub"
+	"github.com/go-logr/logr"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+const (
+	// NriPluginName and NriPluginIndex register the plugin with the runtime, the index orders the plugins
+	NriPluginName  = "varmor"
+	NriPluginIndex = "10"
+	// nriReconnectInterval is the interval of reconnecting to the runtime after the connection was closed
+	nriReconnectInterval = 5 * time.Second
+)
+
+// EnforceFunc applies the BPF profile to the container synchronously, e.g. BpfEnforcer.EnforceContainer
+type EnforceFunc func(ctx context.Context, info varmortypes.ContainerInfo) error
+
+// NriPlugin is an NRI (Node Resource Interface) plugin of the container runtime. It enforces the BPF profiles
+// in the StartContainer hook, which is invoked after the init process of the container is created and before
+// the entrypoint runs. The container fails to start if its profile can't be applied.
+//
+// The runtime monitor still handles the task events, and the containers which have been enforced by the plugin
+// are skipped by the enforcer.
+type NriPlugin struct {
+	socketPath string
+	enforce    EnforceFunc
+	filter     EventFilter
+	// forwardAll enforces all the containers instead of the ones with the BPF profile annotation
+	forwardAll bool
+	log        logr.Logger
+}
+
+// NewNriPlugin creates the NRI plugin that connects to the NRI socket of the runtime, it uses the default socket
+// of the runtime if the socketPath is empty.
+func NewNriPlugin(socketPath string, enforce EnforceFunc, log logr.Logger) *NriPlugin {
+	return &NriPlugin{
+		socketPath: socketPath,
+		enforce:    enforce,
+		log:        log,
+	}
+}
+
+// SetEventFilter sets the filter that selects the containers to enforce. It must be called before the plugin runs.
+func (p *NriPlugin) SetEventFilter(filter EventFilter) {
+	p.filter = filter
+}
+
+// ForwardAllContainers makes the plugin enforce all the containers, not only the ones with the BPF profile
+// annotation. It must be called before the plugin runs.
+func (p *NriPlugin) ForwardAllContainers() {
+	p.forwardAll = true
+}
+
+// StartContainer enforces the BPF profile of the container before its entrypoint runs
+func (p *NriPlugin) StartContainer(ctx context.Context, pod *api.PodSandbox, container *api.Container) error {
+	info, ok := p.containerInfo(pod, container)
+	if !ok {
+		return nil
+	}
+
+	if info.PID == 0 {
+		// The runtime doesn't report the init process, leave the container to the runtime monitor
+		p.log.Info("the pid of the container is unknown, skip it", "container id", info.ContainerID,
+			"pod namespace", info.PodNamespace, "pod name", info.PodName)
+		return nil
+	}
+
+	info.ServiceAccount = retrieveServiceAccount(info.PID, info.PodNamespace)
+	err := p.enforce(ctx, info)
+	if err != nil {
+		p.log.Error(err, "failed to enforce the container", "container id", info.ContainerID,
+			"pod namespace", info.PodNamespace, "pod name", info.PodName, "container name", info.ContainerName)
+		return fmt.Errorf("vArmor failed to apply the BPF profile to the container: %w", err)
+	}
+	return nil
+}
+
+// containerInfo returns the info of the container, and whether it should be enforced by the plugin
+func (p *NriPlugin) containerInfo(pod *api.PodSandbox, container *api.Container) (varmortypes.ContainerInfo, bool) {
+	info := varmortypes.ContainerInfo{
+		PID:            container.GetPid(),
+		ContainerID:    container.GetId(),
+		ContainerName:  container.GetName(),
+		PodID:          pod.GetId(),
+		PodName:        pod.GetName(),
+		PodNamespace:   pod.GetNamespace(),
+		PodUID:         pod.GetUid(),
+		PodAnnotations: pod.GetAnnotations(),
+		PodLabels:      podLabels(pod.GetLabels()),
+		CreatedAt:      time.Now(),
+	}
+
+	if !p.filter.matchPod(&info) {
+		return info, false
+	}
+
+	key := fmt.Sprintf("container.bpf.security.beta.varmor.org/%s", info.ContainerName)
+	if _, ok := info.PodAnnotations[key]; !ok && !p.forwardAll {
+		return info, false
+	}
+	return info, true
+}
+
+// Run registers the plugin with the runtime, and reconnects to it after the connection was closed, e.g. the
+// runtime restarted.
+func (p *NriPlugin) Run(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	opts := []stub.Option{
+		stub.WithPluginName(NriPluginName),
+		stub.WithPluginIdx(NriPluginIndex),
+	}
+	if p.socketPath != "" {
+		opts = append(opts, stub.WithSocketPath(p.socketPath))
+	}
+
+	for {
+		s, err := stub.New(p, opts...)
+		if err != nil {
+			p.log.Error(err, "stub.New() failed")
+			return
+		}
+
+		p.log.Info("register the NRI plugin", "socket", p.socketPath)
+		err = s.Run(ctx)
+		if err != nil {
+			p.log.Error(err, "the NRI plugin stopped", "socket", p.socketPath)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(nriReconnectInterval):
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/containerd/nri/pkg/api"
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+
+	varmortypes "github.com/bytedance/vArmor/pkg/types"
+)
+
+func Test_NriPlugin(t *testing.T) {
+	var enforced []varmortypes.ContainerInfo
+	var enforceErr error
+	plugin := NewNriPlugin("", func(ctx context.Context, info varmortypes.ContainerInfo) error {
+		enforced = append(enforced, info)
+		return enforceErr
+	}, logr.Discard())
+
+	filter, err := ParseEventFilter("", "kube-system", "", "")
+	assert.NilError(t, err)
+	plugin.SetEventFilter(filter)
+
+	pod := &api.PodSandbox{
+		Id:          "p0",
+		Name:        "web-0",
+		Namespace:   "demo",
+		Uid:         "u0",
+		Labels:      map[string]string{"app": "web", "io.kubernetes.pod.name": "web-0"},
+		Annotations: map[string]string{"container.bpf.security.beta.varmor.org/c0": "localhost/varmor-demo-web"},
+	}
+	container := &api.Container{Id: "c0-id", Name: "c0", PodSandboxId: "p0", Pid: 42}
+
+	// The target container is enforced
+	assert.NilError(t, plugin.StartContainer(context.Background(), pod, container))
+	assert.Equal(t, len(enforced), 1)
+	assert.Equal(t, enforced[0].ContainerID, "c0-id")
+	assert.Equal(t, enforced[0].ContainerName, "c0")
+	assert.Equal(t, enforced[0].PID, uint32(42))
+	assert.Equal(t, enforced[0].PodUID, "u0")
+	assert.DeepEqual(t, enforced[0].PodLabels, map[string]string{"app": "web"})
+
+	// The container fails to start if the profile can't be applied
+	enforceErr = errors.New("failed")
+	assert.ErrorContains(t, plugin.StartContainer(context.Background(), pod, container), "failed")
+	enforceErr = nil
+
+	// The containers without the BPF profile annotation are skipped
+	enforced = nil
+	other := &api.Container{Id: "c1-id", Name: "c1", PodSandboxId: "p0", Pid: 43}
+	assert.NilError(t, plugin.StartContainer(context.Background(), pod, other))
+	assert.Equal(t, len(enforced), 0)
+
+	// The containers without the pid are left to the runtime monitor
+	container.Pid = 0
+	assert.NilError(t, plugin.StartContainer(context.Background(), pod, container))
+	assert.Equal(t, len(enforced), 0)
+
+	// The containers of the excluded namespaces are skipped
+	container.Pid = 42
+	pod.Namespace = "kube-system"
+	plugin.ForwardAllContainers()
+	assert.NilError(t, plugin.StartContainer(context.Background(), pod, other))
+	assert.Equal(t, len(enforced), 0)
+
+	// All the containers are enforced in the default-deny mode
+	pod.Namespace = "demo"
+	assert.NilError(t, plugin.StartContainer(context.Background(), pod, other))
+	assert.Equal(t, len(enforced), 1)
+	assert.Equal(t, enforced[0].ContainerID, "c1-id")
+}
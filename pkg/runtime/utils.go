@@ -80,3 +80,22 @@ func newRuntimeServiceClient(endpoint string, timeout time.Duration) (runtimeapi
 	runtimeClient := runtimeapi.NewRuntimeServiceClient(conn)
 	return runtimeClient, conn, nil
 }
+
+// kubeletSandboxLabels are the labels added to the pod sandbox by kubelet, they aren't the labels of the pod
+var kubeletSandboxLabels = []string{
+	"io.kubernetes.pod.name",
+	"io.kubernetes.pod.namespace",
+	"io.kubernetes.pod.uid",
+}
+
+// podLabels returns the labels of the pod from the labels of its sandbox
+func podLabels(sandboxLabels map[string]string) map[string]string {
+	labels := make(map[string]string, len(sandboxLabels))
+	for k, v := range sandboxLabels {
+		labels[k] = v
+	}
+	for _, k := range kubeletSandboxLabels {
+		delete(labels, k)
+	}
+	return labels
+}
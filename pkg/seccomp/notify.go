@@ -0,0 +1,719 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package seccomp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/go-logr/logr"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+// The ioctl requests of the seccomp user notification, see include/uapi/linux/seccomp.h
+const (
+	seccompIoctlNotifRecv    = 0xc0502100
+	seccompIoctlNotifSend    = 0xc0182101
+	seccompIoctlNotifIDValid = 0x40082102
+	// seccompIoctlNotifIDValidWrongDir is the request used by the kernel before v5.17
+	seccompIoctlNotifIDValidWrongDir = 0x80082102
+
+	// maxStringArgSize is the max size of the string argument to read from the memory of the target process
+	maxStringArgSize = 256
+	// maxPathArgSize is the max size of the path argument, i.e. PATH_MAX
+	maxPathArgSize = unix.PathMax
+	// maxDataArgSize is the max size of the data argument of the mount syscall, i.e. a page
+	maxDataArgSize = 4096
+
+	// mountFlagsIgnoringFsType are the mount flags which make the kernel ignore the file system type, e.g. the bind
+	// mounts, the remounts and the propagation changes. They are denied since the rules only allow file system types.
+	mountFlagsIgnoringFsType = unix.MS_REMOUNT | unix.MS_BIND | unix.MS_MOVE | unix.MS_SHARED | unix.MS_PRIVATE | unix.MS_SLAVE | unix.MS_UNBINDABLE
+)
+
+// seccompNotif is the struct seccomp_notif of kernel
+type seccompNotif struct {
+	id    uint64
+	pid   uint32
+	flags uint32
+	nr    int32
+	arch  uint32
+	ip    uint64
+	args  [6]uint64
+}
+
+// seccompNotifResp is the struct seccomp_notif_resp of kernel
+type seccompNotifResp struct {
+	id    uint64
+	val   int64
+	error int32
+	flags uint32
+}
+
+// NotifyMetadata is the listener metadata of the Seccomp profile. It's passed to the listener by the container
+// runtime, so the agent can make decisions for the syscalls of the containers without other lookups.
+type NotifyMetadata struct {
+	Profile string                     `json:"profile"`
+	Rules   []varmor.SyscallNotifyRule `json:"rules"`
+}
+
+// NotifySupportedSyscalls are the syscalls which can be inspected with the seccomp user notification
+var NotifySupportedSyscalls = []string{"mount"}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+		if errno == unix.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
+
+func notifIDValid(fd int, id uint64) bool {
+	err := ioctl(fd, seccompIoctlNotifIDValid, unsafe.Pointer(&id))
+	if err == unix.EINVAL {
+		err = ioctl(fd, seccompIoctlNotifIDValidWrongDir, unsafe.Pointer(&id))
+	}
+	return err == nil
+}
+
+// mountArgs are the arguments of the mount syscall copied from the memory of the target process. The syscall is
+// emulated with the copies, so the other threads of the target process can't modify them after the inspection.
+type mountArgs struct {
+	source string
+	target string
+	fsType string
+	flags  uint64
+	data   string
+}
+
+// targetProcess holds the files of the process which triggered the notification. They're opened before the id of
+// the notification is validated, so they're guaranteed to belong to the process instead of the one reusing its pid.
+type targetProcess struct {
+	mem    *os.File
+	status *os.File
+	attr   *os.File
+	userNs *os.File
+	mntNs  *os.File
+	root   *os.File
+	cwd    *os.File
+}
+
+func openTargetProcess(pid uint32) (*targetProcess, error) {
+	p := &targetProcess{}
+	files := []struct {
+		name string
+		file **os.File
+	}{
+		{"mem", &p.mem},
+		{"status", &p.status},
+		{"attr/current", &p.attr},
+		{"ns/user", &p.userNs},
+		{"ns/mnt", &p.mntNs},
+		{"root", &p.root},
+		{"cwd", &p.cwd},
+	}
+	for _, f := range files {
+		file, err := os.Open(fmt.Sprintf("/proc/%d/%s", pid, f.name))
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		*f.file = file
+	}
+	return p, nil
+}
+
+func (p *targetProcess) close() {
+	for _, f := range []*os.File{p.mem, p.status, p.attr, p.userNs, p.mntNs, p.root, p.cwd} {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// privileged reports whether the process has CAP_SYS_ADMIN in the user namespace of the agent, i.e. it could perform
+// the mount itself. It's the gate of emulating the mount, which is further performed with the credentials and the
+// LSM label of the process, so the emulation doesn't grant it more privileges than it has.
+func (p *targetProcess) privileged(status []byte) (bool, error) {
+	var target, self unix.Stat_t
+	err := unix.Fstat(int(p.userNs.Fd()), &target)
+	if err != nil {
+		return false, err
+	}
+	err = unix.Stat("/proc/self/ns/user", &self)
+	if err != nil {
+		return false, err
+	}
+	if target.Dev != self.Dev || target.Ino != self.Ino {
+		return false, nil
+	}
+	return hasCapability(status, unix.CAP_SYS_ADMIN)
+}
+
+// lsmLabel returns the LSM label of the process, or an empty string if no LSM provides the labels
+func (p *targetProcess) lsmLabel() (string, error) {
+	return readLSMLabel(p.attr)
+}
+
+func readLSMLabelFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readLSMLabel(f)
+}
+
+func readLSMLabel(attr io.Reader) (string, error) {
+	label, err := io.ReadAll(attr)
+	if errors.Is(err, unix.EINVAL) {
+		return "", nil
+	}
+	return strings.TrimRight(string(label), "\x00\n"), err
+}
+
+// hasCapability reports whether the effective capabilities in the content of /proc/<pid>/status have the capability
+func hasCapability(status []byte, capability int) (bool, error) {
+	for _, line := range bytes.Split(status, []byte("\n")) {
+		value, found := bytes.CutPrefix(line, []byte("CapEff:"))
+		if !found {
+			continue
+		}
+		caps, err := strconv.ParseUint(string(bytes.TrimSpace(value)), 16, 64)
+		if err != nil {
+			return false, err
+		}
+		return caps&(1<<capability) != 0, nil
+	}
+	return false, fmt.Errorf("no effective capabilities found")
+}
+
+// credentials are the credentials of the process parsed from /proc/<pid>/status
+type credentials struct {
+	uids   [3]int // real, effective and saved
+	gids   [3]int // real, effective and saved
+	groups []int
+	capInh uint64
+	capPrm uint64
+	capEff uint64
+}
+
+// parseCredentials parses the ids, the supplementary groups and the capabilities in the content of /proc/<pid>/status
+func parseCredentials(status []byte) (*credentials, error) {
+	var c credentials
+	found := 0
+	for _, line := range strings.Split(string(status), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+
+		var err error
+		switch key {
+		case "Uid", "Gid":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("the %s line is illegal", key)
+			}
+			ids := &c.uids
+			if key == "Gid" {
+				ids = &c.gids
+			}
+			for i := range ids {
+				ids[i], err = strconv.Atoi(fields[i])
+				if err != nil {
+					return nil, err
+				}
+			}
+		case "Groups":
+			c.groups = make([]int, len(fields))
+			for i := range fields {
+				c.groups[i], err = strconv.Atoi(fields[i])
+				if err != nil {
+					return nil, err
+				}
+			}
+		case "CapInh", "CapPrm", "CapEff":
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("the %s line is illegal", key)
+			}
+			caps, err := strconv.ParseUint(fields[0], 16, 64)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "CapInh":
+				c.capInh = caps
+			case "CapPrm":
+				c.capPrm = caps
+			case "CapEff":
+				c.capEff = caps
+			}
+		default:
+			continue
+		}
+		found++
+	}
+	if found != 6 {
+		return nil, fmt.Errorf("the credentials are incomplete")
+	}
+	return &c, nil
+}
+
+// setCredentials changes the credentials of the calling thread to the ones of the target process. The raw syscalls
+// are used, since the wrappers of setresuid(2) and setresgid(2) change the credentials of all the threads.
+func setCredentials(c *credentials) error {
+	// Keep the permitted capabilities across the change of the uids, they're narrowed down with capset(2) then
+	err := unix.Prctl(unix.PR_SET_KEEPCAPS, 1, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	err = unix.Setgroups(c.groups)
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.RawSyscall(unix.SYS_SETRESGID, uintptr(c.gids[0]), uintptr(c.gids[1]), uintptr(c.gids[2]))
+	if errno != 0 {
+		return errno
+	}
+	_, _, errno = unix.RawSyscall(unix.SYS_SETRESUID, uintptr(c.uids[0]), uintptr(c.uids[1]), uintptr(c.uids[2]))
+	if errno != 0 {
+		return errno
+	}
+
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{
+		{Effective: uint32(c.capEff), Permitted: uint32(c.capPrm), Inheritable: uint32(c.capInh)},
+		{Effective: uint32(c.capEff >> 32), Permitted: uint32(c.capPrm >> 32), Inheritable: uint32(c.capInh >> 32)},
+	}
+	return unix.Capset(&hdr, &data[0])
+}
+
+// setLSMLabel changes the LSM label of the calling thread with its attr file. The AppArmor label is the name of the
+// profile followed by the mode, so it's changed to the profile. The others, e.g. the SELinux context, are set as is.
+func setLSMLabel(attr io.Writer, label string) error {
+	value := label
+	if i := strings.LastIndex(label, " ("); i > 0 && strings.HasSuffix(label, ")") {
+		value = "changeprofile " + label[:i]
+	}
+	_, err := attr.Write([]byte(value))
+	return err
+}
+
+// readStringArg reads the NUL-terminated string at the address of the target process
+func readStringArg(mem io.ReaderAt, addr uint64, size int) (string, error) {
+	if addr == 0 {
+		return "", nil
+	}
+
+	buf := make([]byte, size)
+	n, err := mem.ReadAt(buf, int64(addr))
+	if n == 0 && err != nil {
+		return "", err
+	}
+	if i := bytes.IndexByte(buf[:n], 0); i >= 0 {
+		return string(buf[:i]), nil
+	}
+	return "", fmt.Errorf("the string argument exceeds %d bytes", size)
+}
+
+// readMountArgs copies the arguments of the mount syscall from the memory of the target process
+func readMountArgs(mem io.ReaderAt, n *seccompNotif) (*mountArgs, error) {
+	args := mountArgs{flags: n.args[3]}
+	strs := []struct {
+		value *string
+		size  int
+	}{
+		{&args.source, maxPathArgSize},
+		{&args.target, maxPathArgSize},
+		{&args.fsType, maxStringArgSize},
+		{&args.data, maxDataArgSize},
+	}
+	for i, addr := range []uint64{n.args[0], n.args[1], n.args[2], n.args[4]} {
+		value, err := readStringArg(mem, addr, strs[i].size)
+		if err != nil {
+			return nil, err
+		}
+		*strs[i].value = value
+	}
+	return &args, nil
+}
+
+// decide returns whether the syscall of the notification is allowed by the rules, and the inspected argument
+func decide(n *seccompNotif, args *mountArgs, rules []varmor.SyscallNotifyRule) (bool, string) {
+	switch n.nr {
+	case unix.SYS_MOUNT:
+		if args.flags&mountFlagsIgnoringFsType != 0 {
+			// The file system type is ignored by the kernel, so the rules can't allow it
+			return false, fmt.Sprintf("flags=%#x", args.flags)
+		}
+		for _, rule := range rules {
+			if rule.Syscall != "mount" {
+				continue
+			}
+			for _, t := range rule.FsTypes {
+				if t == args.fsType {
+					return true, args.fsType
+				}
+			}
+		}
+		return false, args.fsType
+	}
+
+	return false, ""
+}
+
+// emulateMount performs the mount on behalf of the target process, in its mount namespace, with its root and working
+// directory, and with its credentials, capabilities and LSM label. So the mount is subject to the same permission checks
+// and LSM policies as if the target process performed it, e.g. the AppArmor profile of the container. It runs on a
+// dedicated thread which is never unlocked, so the thread is terminated along with the goroutine instead of being reused
+// with the mount namespace and the credentials of the container.
+func emulateMount(p *targetProcess, creds *credentials, label string, args *mountArgs) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		errCh <- func() error {
+			// The attr file of the thread isn't reachable after the chroot, so it's opened beforehand
+			current, err := readLSMLabelFile("/proc/thread-self/attr/current")
+			if err != nil {
+				return err
+			}
+			var attr *os.File
+			if label != current {
+				attr, err = os.OpenFile("/proc/thread-self/attr/current", os.O_WRONLY, 0)
+				if err != nil {
+					return err
+				}
+				defer attr.Close()
+			}
+
+			// setns(CLONE_NEWNS) requires the thread not to share the file system attributes with the others
+			err = unix.Unshare(unix.CLONE_FS)
+			if err != nil {
+				return err
+			}
+			err = unix.Setns(int(p.mntNs.Fd()), unix.CLONE_NEWNS)
+			if err != nil {
+				return err
+			}
+			err = unix.Fchdir(int(p.root.Fd()))
+			if err != nil {
+				return err
+			}
+			err = unix.Chroot(".")
+			if err != nil {
+				return err
+			}
+			err = unix.Fchdir(int(p.cwd.Fd()))
+			if err != nil {
+				return err
+			}
+			err = setCredentials(creds)
+			if err != nil {
+				return fmt.Errorf("failed to change the credentials: %w", err)
+			}
+			if attr != nil {
+				err = setLSMLabel(attr, label)
+				if err != nil {
+					return fmt.Errorf("failed to change the LSM label: %w", err)
+				}
+			}
+			return unix.Mount(args.source, args.target, args.fsType, uintptr(args.flags), args.data)
+		}()
+	}()
+	return <-errCh
+}
+
+// NotifyServer receives the seccomp notify file descriptors of the containers from the container runtime,
+// and makes the allow/deny decisions for the notified syscalls with the rules in the listener metadata.
+//
+// The syscalls aren't continued with SECCOMP_USER_NOTIF_FLAG_CONTINUE, since their pointer arguments may be modified
+// by the other threads of the target process after the inspection. Instead, the allowed syscalls are emulated by the
+// agent with the copies of the arguments, and their results are returned to the target process. The others are denied
+// with EPERM.
+//
+// The trust model: the rules only narrow down what the target process can do, they never grant it a privilege. The
+// emulation is gated by privileged(), i.e. the target process must have CAP_SYS_ADMIN in the user namespace of the
+// agent, and it's performed with the credentials, the capabilities and the LSM label of the target process. So the
+// target process can only get the mounts that it could perform itself, and the agent's own privileges aren't lent.
+type NotifyServer struct {
+	socketPath string
+	listener   *net.UnixListener
+	log        logr.Logger
+}
+
+// NewNotifyServer creates the server listening on the unix socket, which is the listenerPath of the profiles
+func NewNotifyServer(socketPath string, log logr.Logger) (*NotifyServer, error) {
+	err := os.MkdirAll(filepath.Dir(socketPath), 0700)
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotifyServer{
+		socketPath: socketPath,
+		listener:   listener,
+		log:        log,
+	}, nil
+}
+
+// Run accepts the connections from the container runtime until stopCh is closed
+func (s *NotifyServer) Run(stopCh <-chan struct{}) {
+	go func() {
+		<-stopCh
+		s.listener.Close()
+	}()
+
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				os.Remove(s.socketPath)
+				return
+			}
+			s.log.Error(err, "AcceptUnix()")
+			continue
+		}
+		go s.handleConn(conn, stopCh)
+	}
+}
+
+// receiveState reads the container process state and the file descriptors sent by the container runtime
+func receiveState(conn *net.UnixConn) (*specs.ContainerProcessState, []int, error) {
+	var fds []int
+	var content []byte
+	buf := make([]byte, 4096)
+	oob := make([]byte, unix.CmsgSpace(4*8))
+
+	for {
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		content = append(content, buf[:n]...)
+
+		if oobn > 0 {
+			msgs, e := unix.ParseSocketControlMessage(oob[:oobn])
+			if e == nil {
+				for i := range msgs {
+					rights, e := unix.ParseUnixRights(&msgs[i])
+					if e == nil {
+						fds = append(fds, rights...)
+					}
+				}
+			}
+		}
+
+		if err == io.EOF || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			return nil, fds, err
+		}
+	}
+
+	var state specs.ContainerProcessState
+	err := json.Unmarshal(content, &state)
+	return &state, fds, err
+}
+
+func (s *NotifyServer) handleConn(conn *net.UnixConn, stopCh <-chan struct{}) {
+	defer conn.Close()
+
+	state, fds, err := receiveState(conn)
+	if err != nil {
+		s.log.Error(err, "failed to receive the container process state")
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return
+	}
+
+	notifyFd := -1
+	for i, name := range state.Fds {
+		if i >= len(fds) {
+			break
+		}
+		if name == specs.SeccompFdName {
+			notifyFd = fds[i]
+		} else {
+			unix.Close(fds[i])
+		}
+	}
+	if notifyFd < 0 {
+		s.log.Error(fmt.Errorf("no seccomp notify fd found"), "illegal container process state", "container", state.State.ID)
+		return
+	}
+
+	var metadata NotifyMetadata
+	err = json.Unmarshal([]byte(state.Metadata), &metadata)
+	if err != nil {
+		// Deny all the notified syscalls if the rules are unknown
+		s.log.Error(err, "failed to parse the listener metadata", "container", state.State.ID)
+	}
+
+	s.log.Info("start handling the seccomp notifications", "container", state.State.ID, "profile", metadata.Profile)
+	s.handleNotifications(notifyFd, state.State.ID, &metadata, stopCh)
+	s.log.Info("stop handling the seccomp notifications", "container", state.State.ID, "profile", metadata.Profile)
+}
+
+// handleNotifications handles the notifications until all the processes of the filter exited
+func (s *NotifyServer) handleNotifications(fd int, containerID string, metadata *NotifyMetadata, stopCh <-chan struct{}) {
+	defer unix.Close(fd)
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		_, err := unix.Poll(fds, 1000)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			s.log.Error(err, "unix.Poll()")
+			return
+		}
+		if fds[0].Revents&(unix.POLLHUP|unix.POLLERR|unix.POLLNVAL) != 0 {
+			return
+		}
+		if fds[0].Revents&unix.POLLIN == 0 {
+			continue
+		}
+
+		var n seccompNotif
+		err = ioctl(fd, seccompIoctlNotifRecv, unsafe.Pointer(&n))
+		if err != nil {
+			if err == unix.ENOENT {
+				// The target process was interrupted or exited before receiving
+				continue
+			}
+			s.log.Error(err, "failed to receive the seccomp notification")
+			return
+		}
+
+		resp := s.respond(fd, &n, containerID, metadata)
+		if resp == nil {
+			continue
+		}
+
+		err = ioctl(fd, seccompIoctlNotifSend, unsafe.Pointer(resp))
+		if err != nil && err != unix.ENOENT {
+			s.log.Error(err, "failed to send the seccomp notification response")
+		}
+	}
+}
+
+// respond inspects the syscall of the notification, and emulates it if it's allowed. It returns nil if the notification
+// is no longer valid, i.e. the target process was interrupted or exited.
+func (s *NotifyServer) respond(fd int, n *seccompNotif, containerID string, metadata *NotifyMetadata) *seccompNotifResp {
+	resp := &seccompNotifResp{id: n.id, error: -int32(unix.EPERM)}
+	logger := s.log.WithValues("container", containerID, "profile", metadata.Profile, "pid", n.pid, "nr", n.nr)
+
+	if n.pid == 0 {
+		// The target process isn't visible in the PID namespace of the agent
+		logger.Error(fmt.Errorf("the pid of the target process is unknown"), "failed to inspect the syscall")
+		return resp
+	}
+
+	p, err := openTargetProcess(n.pid)
+	// The process may have exited and its PID reused before the files were opened
+	if !notifIDValid(fd, n.id) {
+		if p != nil {
+			p.close()
+		}
+		return nil
+	}
+	if err != nil {
+		logger.Error(err, "failed to inspect the syscall")
+		return resp
+	}
+	defer p.close()
+
+	switch n.nr {
+	case unix.SYS_MOUNT:
+		args, err := readMountArgs(p.mem, n)
+		if err != nil {
+			logger.Error(err, "failed to inspect the syscall")
+			return resp
+		}
+
+		allowed, arg := decide(n, args, metadata.Rules)
+		if !allowed {
+			logger.Info("syscall denied", "argument", arg)
+			return resp
+		}
+
+		status, err := io.ReadAll(p.status)
+		if err != nil {
+			logger.Error(err, "failed to inspect the syscall")
+			return resp
+		}
+
+		privileged, err := p.privileged(status)
+		if err != nil || !privileged {
+			logger.Info("syscall denied, the target process doesn't have CAP_SYS_ADMIN", "argument", arg)
+			return resp
+		}
+
+		creds, err := parseCredentials(status)
+		if err != nil {
+			logger.Error(err, "failed to inspect the credentials of the target process")
+			return resp
+		}
+		label, err := p.lsmLabel()
+		if err != nil {
+			logger.Error(err, "failed to inspect the LSM label of the target process")
+			return resp
+		}
+
+		err = emulateMount(p, creds, label, args)
+		if err != nil {
+			var errno unix.Errno
+			if errors.As(err, &errno) {
+				resp.error = -int32(errno)
+			}
+			logger.Info("failed to emulate the syscall", "argument", arg, "error", err.Error())
+			return resp
+		}
+		resp.error = 0
+		return resp
+	}
+
+	logger.Info("syscall denied")
+	return resp
+}
@@ -0,0 +1,245 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package seccomp
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+
+	varmor "github.com/bytedance/vArmor/apis/varmor/v1beta1"
+)
+
+func Test_decide(t *testing.T) {
+	rules := []varmor.SyscallNotifyRule{
+		{Syscall: "mount", FsTypes: []string{"tmpfs", "proc"}},
+	}
+
+	testCases := []struct {
+		name     string
+		nr       int32
+		args     mountArgs
+		rules    []varmor.SyscallNotifyRule
+		allowed  bool
+		argument string
+	}{
+		{
+			name:     "allowed file system type",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{source: "tmpfs", target: "/mnt", fsType: "tmpfs"},
+			rules:    rules,
+			allowed:  true,
+			argument: "tmpfs",
+		},
+		{
+			name:     "allowed file system type with flags",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{source: "proc", target: "/proc", fsType: "proc", flags: unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC},
+			rules:    rules,
+			allowed:  true,
+			argument: "proc",
+		},
+		{
+			name:     "other file system type",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{source: "/dev/sda1", target: "/mnt", fsType: "ext4"},
+			rules:    rules,
+			argument: "ext4",
+		},
+		{
+			name:     "bind mount",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{source: "/etc", target: "/mnt", fsType: "tmpfs", flags: unix.MS_BIND},
+			rules:    rules,
+			argument: "flags=0x1000",
+		},
+		{
+			name:     "remount",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{target: "/", fsType: "tmpfs", flags: unix.MS_REMOUNT},
+			rules:    rules,
+			argument: "flags=0x20",
+		},
+		{
+			name:     "propagation change",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{target: "/", fsType: "tmpfs", flags: unix.MS_SHARED | unix.MS_REC},
+			rules:    rules,
+			argument: "flags=0x104000",
+		},
+		{
+			name:     "rules of other syscalls",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{source: "tmpfs", target: "/mnt", fsType: "tmpfs"},
+			rules:    []varmor.SyscallNotifyRule{{Syscall: "umount2", FsTypes: []string{"tmpfs"}}},
+			argument: "tmpfs",
+		},
+		{
+			name:     "no rules",
+			nr:       unix.SYS_MOUNT,
+			args:     mountArgs{source: "tmpfs", target: "/mnt", fsType: "tmpfs"},
+			argument: "tmpfs",
+		},
+		{
+			name:  "other syscall",
+			nr:    unix.SYS_UMOUNT2,
+			args:  mountArgs{target: "/mnt"},
+			rules: rules,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			allowed, argument := decide(&seccompNotif{nr: tc.nr}, &tc.args, tc.rules)
+			assert.Equal(t, allowed, tc.allowed)
+			assert.Equal(t, argument, tc.argument)
+		})
+	}
+}
+
+func Test_readMountArgs(t *testing.T) {
+	// The memory of the target process, the strings are placed at the offsets of the arguments
+	mem := make([]byte, 8192)
+	copy(mem[16:], "tmpfs\x00")
+	copy(mem[32:], "/mnt/data\x00")
+	copy(mem[64:], "tmpfs\x00")
+	copy(mem[128:], "size=64m,mode=0755\x00")
+	copy(mem[4096:], strings.Repeat("a", 4096))
+
+	n := seccompNotif{nr: unix.SYS_MOUNT, args: [6]uint64{16, 32, 64, unix.MS_NOSUID, 128}}
+	args, err := readMountArgs(bytes.NewReader(mem), &n)
+	assert.NilError(t, err)
+	assert.Equal(t, *args, mountArgs{
+		source: "tmpfs",
+		target: "/mnt/data",
+		fsType: "tmpfs",
+		flags:  unix.MS_NOSUID,
+		data:   "size=64m,mode=0755",
+	})
+
+	// The NULL pointers are read as empty strings
+	n.args = [6]uint64{0, 32, 64, 0, 0}
+	args, err = readMountArgs(bytes.NewReader(mem), &n)
+	assert.NilError(t, err)
+	assert.Equal(t, *args, mountArgs{target: "/mnt/data", fsType: "tmpfs"})
+
+	// The unterminated string is rejected
+	n.args = [6]uint64{16, 4096, 64, 0, 0}
+	_, err = readMountArgs(bytes.NewReader(mem), &n)
+	assert.ErrorContains(t, err, "exceeds")
+
+	// The file system type is limited to maxStringArgSize
+	n.args = [6]uint64{16, 32, 4096, 0, 0}
+	_, err = readMountArgs(bytes.NewReader(mem), &n)
+	assert.ErrorContains(t, err, "exceeds 256 bytes")
+
+	// The unmapped memory can't be read
+	n.args = [6]uint64{16, 32, 64 + 8192, 0, 0}
+	_, err = readMountArgs(bytes.NewReader(mem), &n)
+	assert.Assert(t, err != nil)
+}
+
+func Test_hasCapability(t *testing.T) {
+	status := "Name:\tsh\nCapInh:\t0000000000000000\nCapPrm:\t00000000a80425fb\nCapEff:\t%s\nCapBnd:\t00000000a80425fb\n"
+
+	privileged, err := hasCapability([]byte(strings.Replace(status, "%s", "000001ffffffffff", 1)), unix.CAP_SYS_ADMIN)
+	assert.NilError(t, err)
+	assert.Equal(t, privileged, true)
+
+	// The default capabilities of the containers don't include CAP_SYS_ADMIN
+	privileged, err = hasCapability([]byte(strings.Replace(status, "%s", "00000000a80425fb", 1)), unix.CAP_SYS_ADMIN)
+	assert.NilError(t, err)
+	assert.Equal(t, privileged, false)
+
+	_, err = hasCapability([]byte("Name:\tsh\n"), unix.CAP_SYS_ADMIN)
+	assert.ErrorContains(t, err, "no effective capabilities")
+}
+
+func Test_parseCredentials(t *testing.T) {
+	status := "Name:\tsh\nUid:\t1000\t1001\t1002\t1001\nGid:\t2000\t2000\t2000\t2000\nGroups:\t10 20 \n" +
+		"CapInh:\t0000000000000000\nCapPrm:\t000001ffffffffff\nCapEff:\t00000000a80425fb\n"
+
+	creds, err := parseCredentials([]byte(status))
+	assert.NilError(t, err)
+	assert.Equal(t, creds.uids, [3]int{1000, 1001, 1002})
+	assert.Equal(t, creds.gids, [3]int{2000, 2000, 2000})
+	assert.DeepEqual(t, creds.groups, []int{10, 20})
+	assert.Equal(t, creds.capInh, uint64(0))
+	assert.Equal(t, creds.capPrm, uint64(0x000001ffffffffff))
+	assert.Equal(t, creds.capEff, uint64(0x00000000a80425fb))
+
+	// The processes without the supplementary groups
+	creds, err = parseCredentials([]byte(strings.Replace(status, "10 20 ", "", 1)))
+	assert.NilError(t, err)
+	assert.Equal(t, len(creds.groups), 0)
+
+	_, err = parseCredentials([]byte("Name:\tsh\nUid:\t0\t0\t0\t0\n"))
+	assert.ErrorContains(t, err, "incomplete")
+
+	_, err = parseCredentials([]byte(strings.Replace(status, "\t1001\t1002\t1001", "", 1)))
+	assert.ErrorContains(t, err, "illegal")
+}
+
+func Test_setLSMLabel(t *testing.T) {
+	testCases := []struct {
+		label    string
+		expected string
+	}{
+		{label: "cri-containerd.apparmor.d (enforce)", expected: "changeprofile cri-containerd.apparmor.d"},
+		{label: "varmor-demo-web (complain)", expected: "changeprofile varmor-demo-web"},
+		{label: "system_u:system_r:container_t:s0:c1,c2", expected: "system_u:system_r:container_t:s0:c1,c2"},
+	}
+
+	for _, tc := range testCases {
+		var attr bytes.Buffer
+		assert.NilError(t, setLSMLabel(&attr, tc.label))
+		assert.Equal(t, attr.String(), tc.expected)
+	}
+
+	label, err := readLSMLabel(strings.NewReader("cri-containerd.apparmor.d (enforce)\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, label, "cri-containerd.apparmor.d (enforce)")
+}
+
+func Test_emulateMount(t *testing.T) {
+	p, err := openTargetProcess(uint32(os.Getpid()))
+	if err != nil {
+		t.Skip(err)
+	}
+	defer p.close()
+
+	status, err := os.ReadFile("/proc/self/status")
+	assert.NilError(t, err)
+	creds, err := parseCredentials(status)
+	assert.NilError(t, err)
+	label, err := p.lsmLabel()
+	assert.NilError(t, err)
+
+	target := t.TempDir()
+	err = emulateMount(p, creds, label, &mountArgs{source: "varmor", target: target, fsType: "tmpfs", data: "size=1m"})
+	if err == unix.EPERM {
+		t.Skip(err)
+	}
+	assert.NilError(t, err)
+	defer unix.Unmount(target, unix.MNT_DETACH)
+
+	var st unix.Statfs_t
+	assert.NilError(t, unix.Statfs(target, &st))
+	assert.Equal(t, st.Type, int64(unix.TMPFS_MAGIC))
+}
@@ -15,6 +15,7 @@
 package types
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -29,6 +30,14 @@ const (
 	// to retrieve container and pod information
 	RuntimeTimeout time.Duration = time.Second * 5
 
+	// DefaultTaskChannelCapacity is the default capacity of the channels which are used to
+	// send the task create and delete events from the runtime monitor to the BPF enforcer
+	DefaultTaskChannelCapacity int = 100
+
+	// TaskResyncDelay is the delay before resyncing the containers with the BPF enforcer after
+	// the task events were dropped, it gives the enforcer a chance to drain the backlog
+	TaskResyncDelay time.Duration = time.Second * 3
+
 	// MaxTargetContainerCountForBpfLsm is the max count of target containers for BPF LSM,
 	// it's equal to the OUTER_MAP_ENTRIES_MAX of BPF code
 	MaxTargetContainerCountForBpfLsm int = 100
@@ -58,6 +67,52 @@ const (
 	MaxFileSystemTypeLength int = 16
 )
 
+// The macros of the destinations in the network rules. They are expanded into the CIDRs by the agent with the
+// configuration of the cluster, so the policies don't hard-code the CIDRs that differ per cluster.
+const (
+	// ClusterPodsMacro matches the CIDRs of the pods in the cluster
+	ClusterPodsMacro string = "@cluster-pods"
+	// ClusterServicesMacro matches the CIDRs of the services in the cluster
+	ClusterServicesMacro string = "@cluster-services"
+	// NodeLocalMacro matches the addresses of the node where the container runs
+	NodeLocalMacro string = "@node-local"
+	// PrivateRangesMacro matches the private address ranges (RFC 1918, RFC 6598 and RFC 4193)
+	PrivateRangesMacro string = "@private-ranges"
+)
+
+// IsNetworkMacro returns whether the destination of the network rule is a macro
+func IsNetworkMacro(destination string) bool {
+	switch destination {
+	case ClusterPodsMacro, ClusterServicesMacro, NodeLocalMacro, PrivateRangesMacro:
+		return true
+	}
+	return false
+}
+
+// RuleExceptionsAnnotation is the annotation of the pods which disables the specified built-in rules
+// for the pods, its value is a comma-separated list of the rule names. It's vetted by the webhook.
+const RuleExceptionsAnnotation string = "exception.varmor.org/rules"
+
+// The sandbox (pause) containers of the pods are never enforced with the profiles of the application containers.
+// They are only confined with the BPF profile specified by the SandboxBpfAnnotation of the pod, which is set by
+// the webhook with the SandboxProfileName if the policy confines the sandbox containers.
+const (
+	// SandboxBpfAnnotation is the annotation of the pods which specifies the BPF profile of the sandbox container
+	SandboxBpfAnnotation string = "sandbox.bpf.security.beta.varmor.org"
+	// SandboxProfileName is the name of the built-in minimal BPF profile of the sandbox containers, it's saved by
+	// the agent when it starts
+	SandboxProfileName string = "varmor-sandbox"
+)
+
+// BaseBpfAnnotationPrefix is the prefix of the annotations of the pods which specify the base BPF profiles of the
+// containers, e.g. "base.bpf.security.beta.varmor.org/nginx: localhost/varmor-cluster-varmor-base". The base
+// profile is layered under the profile of the container, and its rules take precedence.
+const BaseBpfAnnotationPrefix string = "base.bpf.security.beta.varmor.org/"
+
+// ViolationEventsUnsupportedReason is the compatibility reason of the nodes whose BPF enforcer doesn't emit the
+// violation events, so the violations of the BPF profiles aren't reported to the VarmorViolation objects
+const ViolationEventsUnsupportedReason string = "the violation events are unsupported by the BPF enforcer, the violations aren't reported"
+
 // ContainerInfo describes the information collected by the runtime monitor
 type ContainerInfo struct {
 	PID            uint32
@@ -68,4 +123,85 @@ type ContainerInfo struct {
 	PodNamespace   string
 	PodUID         string
 	PodAnnotations map[string]string
+	PodLabels      map[string]string
+	Image          string
+	// ServiceAccount is the service account of the pod, it's read from the projected token of the container.
+	// It's empty if the token isn't mounted.
+	ServiceAccount string
+	// CreatedAt is the time when the task of the container was created, it's zero if unknown
+	CreatedAt time.Time
+	// Sandbox means the container is the sandbox (pause) container of the pod, it has no ContainerName
+	Sandbox bool
+}
+
+// Violation describes an operation that was denied by the BPF enforcer
+type Violation struct {
+	ProfileName   string
+	PodNamespace  string
+	PodName       string
+	PodUID        string
+	PodLabels     map[string]string
+	ContainerID   string
+	ContainerName string
+	Image         string
+	RuleType      string
+	RuleID        string
+	Permissions   uint32
+	PID           uint32
+	MntNsID       uint32
+	Timestamp     time.Time
+	// Enriched is false if the container of the violation is unknown, only the kernel fields are set
+	Enriched bool
+	// Capability is the number of the requested capability. It's -1 if the violation isn't of the capability
+	// rule, or the BPF program doesn't report it.
+	Capability int32
+	// Syscall is the number of the syscall that requested the operation. It's -1 if the BPF program doesn't
+	// report it.
+	Syscall int32
+	// Count is the number of the identical violations aggregated into it, which are of the same mnt ns, rule and
+	// operation. Timestamp is the time of the first one, and LastTimestamp is the time of the last one.
+	Count         int64
+	LastTimestamp time.Time
+	// ServiceAccount is the service account of the pod, it's empty if unknown
+	ServiceAccount string
+}
+
+// SPIFFEID returns the SPIFFE ID of the workload which runs as the service account in the trust domain, following
+// the Kubernetes convention of spiffe://<trust domain>/ns/<namespace>/sa/<service account>. It's empty if the
+// service account is unknown.
+func SPIFFEID(trustDomain, namespace, serviceAccount string) string {
+	if trustDomain == "" || namespace == "" || serviceAccount == "" {
+		return ""
+	}
+	return fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", trustDomain, namespace, serviceAccount)
+}
+
+// Tamper describes the entries of a mnt ns in the maps of the BPF enforcer that were modified, added or removed by
+// anything other than the enforcer
+type Tamper struct {
+	MntNsID      uint32
+	ProfileName  string
+	PodNamespace string
+	PodName      string
+	ContainerID  string
+	Timestamp    time.Time
+	// Maps are the names of the maps whose entries of the mnt ns were modified
+	Maps []string
+	// Restored is true if the profile was reapplied to the mnt ns, or the injected entries were removed
+	Restored bool
+}
+
+// Suspension describes a container whose enforcement was suspended temporarily, or resumed
+type Suspension struct {
+	ProfileName   string
+	PodNamespace  string
+	PodName       string
+	ContainerID   string
+	ContainerName string
+	Reason        string
+	// Until is the time when the enforcement is resumed automatically
+	Until time.Time
+	// Resumed is true if the enforcement was resumed
+	Resumed   bool
+	Timestamp time.Time
 }
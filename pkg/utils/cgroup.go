@@ -0,0 +1,234 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// CgroupMode is the layout of the cgroup hierarchies of the node
+type CgroupMode string
+
+const (
+	// CgroupModeLegacy means only the cgroup v1 hierarchies are mounted
+	CgroupModeLegacy CgroupMode = "legacy"
+	// CgroupModeHybrid means the controllers are in the cgroup v1 hierarchies, and the cgroup v2 hierarchy is mounted
+	// without controllers, e.g. at /sys/fs/cgroup/unified by systemd
+	CgroupModeHybrid CgroupMode = "hybrid"
+	// CgroupModeUnified means only the cgroup v2 hierarchy is mounted
+	CgroupModeUnified CgroupMode = "unified"
+)
+
+// Container runtimes that are recognized from the cgroup paths
+const (
+	RuntimeContainerd = "containerd"
+	RuntimeCRIO       = "cri-o"
+	RuntimeDocker     = "docker"
+)
+
+// CgroupIdentity is the identity of a process resolved from its cgroups, regardless of the container runtime and
+// the cgroup mode of the node
+type CgroupIdentity struct {
+	// UnifiedPath is the path of the process in the cgroup v2 hierarchy. It's empty if the process stays in the root
+	// of it while it's placed in the cgroup v1 hierarchies, i.e. the hierarchy doesn't identify the process.
+	UnifiedPath string
+	// Path is the path that identifies the process. It's the UnifiedPath if it's not empty, otherwise the path in
+	// the named systemd hierarchy or a controller hierarchy of cgroup v1.
+	Path string
+	// Runtime is the container runtime that created the cgroup, it's empty if it can't be recognized
+	Runtime string
+	// ContainerID is the id of the container that the process runs in, it's empty for the host processes
+	ContainerID string
+	// PodUID is the uid of the pod that the container belongs to
+	PodUID string
+	// SystemdUnit is the innermost systemd service or scope of the process
+	SystemdUnit string
+}
+
+// ParseCgroup parses the content of /proc/<pid>/cgroup. The lines are in the format of
+// "hierarchy-ID:controller-list:cgroup-path", the hierarchy ID of cgroup v2 is 0 and its controller list is empty.
+func ParseCgroup(content string) (CgroupIdentity, error) {
+	var identity CgroupIdentity
+	var systemdPath, controllerPath string
+	found := false
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		found = true
+		// The path is relative to the cgroup namespace of the reader
+		if strings.HasPrefix(fields[2], "/..") {
+			return identity, fmt.Errorf("the cgroup of the process is out of the cgroup namespace of the agent")
+		}
+
+		switch {
+		case fields[0] == "0" && fields[1] == "":
+			if fields[2] != "/" {
+				identity.UnifiedPath = fields[2]
+			}
+		case fields[1] == "name=systemd":
+			systemdPath = fields[2]
+		case controllerPath == "" && fields[1] != "" && !strings.HasPrefix(fields[1], "name="):
+			controllerPath = fields[2]
+		}
+	}
+	if !found {
+		return identity, fmt.Errorf("the content of the cgroup is invalid")
+	}
+
+	switch {
+	case identity.UnifiedPath != "":
+		identity.Path = identity.UnifiedPath
+	case systemdPath != "" && systemdPath != "/":
+		identity.Path = systemdPath
+	case controllerPath != "":
+		identity.Path = controllerPath
+	default:
+		identity.Path = "/"
+	}
+
+	parseCgroupPath(identity.Path, &identity)
+	if identity.SystemdUnit == "" && systemdPath != "" {
+		// The unit is only tracked by the named systemd hierarchy on the nodes in the legacy mode
+		var unit CgroupIdentity
+		parseCgroupPath(systemdPath, &unit)
+		identity.SystemdUnit = unit.SystemdUnit
+	}
+	return identity, nil
+}
+
+// isContainerID checks whether the name is the id of a container, i.e. 64 hexadecimal characters
+func isContainerID(name string) bool {
+	if len(name) != 64 {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// containerScopePrefixes are the prefixes of the cgroups of the containers that are created by the container runtimes
+// with the systemd or cgroupfs driver. The conmon of CRI-O is excluded since it isn't the container.
+var containerScopePrefixes = []struct {
+	prefix  string
+	runtime string
+}{
+	{"cri-containerd-", RuntimeContainerd},
+	{"crio-", RuntimeCRIO},
+	{"docker-", RuntimeDocker},
+}
+
+// parseCgroupPath resolves the container, the pod and the systemd unit from the path of the cgroup. It supports the
+// layouts of the systemd driver, e.g.
+//
+//	/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod<uid>.slice/cri-containerd-<id>.scope
+//	/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod<uid>.slice/crio-<id>.scope/container
+//
+// and the ones of the cgroupfs driver, e.g.
+//
+//	/kubepods/besteffort/pod<uid>/<id>
+//	/kubepods/burstable/pod<uid>/crio-<id>
+func parseCgroupPath(path string, identity *CgroupIdentity) {
+	elements := strings.Split(path, "/")
+	for i := len(elements) - 1; i >= 0; i-- {
+		element := elements[i]
+
+		if identity.SystemdUnit == "" && (strings.HasSuffix(element, ".service") || strings.HasSuffix(element, ".scope")) {
+			identity.SystemdUnit = element
+		}
+
+		if identity.ContainerID == "" && identity.PodUID == "" {
+			name := strings.TrimSuffix(element, ".scope")
+			for _, p := range containerScopePrefixes {
+				if id := strings.TrimPrefix(name, p.prefix); id != name && isContainerID(id) {
+					identity.ContainerID = id
+					identity.Runtime = p.runtime
+					break
+				}
+			}
+			if identity.ContainerID == "" && isContainerID(name) {
+				// The cgroupfs driver of containerd and Docker
+				identity.ContainerID = name
+				if i > 0 && elements[i-1] == "docker" {
+					identity.Runtime = RuntimeDocker
+				}
+			}
+			if identity.ContainerID != "" {
+				continue
+			}
+		}
+
+		if identity.PodUID == "" {
+			identity.PodUID = podUIDOf(element)
+		}
+	}
+}
+
+// podUIDOf returns the pod uid of the cgroup of the pod, e.g. "pod<uid>" or "kubepods-besteffort-pod<uid>.slice"
+// whose dashes of the uid are replaced with the underscores by the systemd driver
+func podUIDOf(element string) string {
+	if strings.HasSuffix(element, ".slice") {
+		i := strings.LastIndex(element, "-pod")
+		if i == -1 {
+			return ""
+		}
+		return strings.ReplaceAll(strings.TrimSuffix(element[i+len("-pod"):], ".slice"), "_", "-")
+	}
+	if strings.HasPrefix(element, "pod") && len(element) > len("pod") {
+		return element[len("pod"):]
+	}
+	return ""
+}
+
+// ReadCgroupIdentity returns the identity of the process resolved from its cgroups
+func ReadCgroupIdentity(pid uint32) (CgroupIdentity, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return CgroupIdentity{}, err
+	}
+	return ParseCgroup(string(content))
+}
+
+// isCgroup2 checks whether the path is the mount point of the cgroup v2 hierarchy
+func isCgroup2(path string) bool {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return false
+	}
+	return stat.Type == unix.CGROUP2_SUPER_MAGIC
+}
+
+// DetectCgroupMode detects the cgroup mode of the root of the cgroup filesystem, e.g. /sys/fs/cgroup. It returns
+// the mount point of the cgroup v2 hierarchy, which is empty in the legacy mode.
+func DetectCgroupMode(root string) (CgroupMode, string) {
+	if isCgroup2(root) {
+		return CgroupModeUnified, root
+	}
+	unified := filepath.Join(root, "unified")
+	if isCgroup2(unified) {
+		return CgroupModeHybrid, unified
+	}
+	return CgroupModeLegacy, ""
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+const (
+	testContainerID = "4c1bd2b9a84e3d7e0a0a3f4e4f2a8f5d5c1e9b3b6d9c2e1f0a7b8c9d0e1f2a3b"
+	testPodUID      = "2f6ad4e4-5a1b-4c3d-8e9f-0a1b2c3d4e5f"
+)
+
+func Test_ParseCgroup(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cgroup   string
+		expected CgroupIdentity
+	}{
+		{
+			name:   "containerdSystemdUnified",
+			cgroup: "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope\n",
+			expected: CgroupIdentity{
+				UnifiedPath: "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope",
+				Path:        "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope",
+				Runtime:     RuntimeContainerd,
+				ContainerID: testContainerID,
+				PodUID:      testPodUID,
+				SystemdUnit: "cri-containerd-" + testContainerID + ".scope",
+			},
+		},
+		{
+			name: "containerdCgroupfsLegacy",
+			cgroup: "12:memory:/kubepods/besteffort/pod" + testPodUID + "/" + testContainerID + "\n" +
+				"11:cpu,cpuacct:/kubepods/besteffort/pod" + testPodUID + "/" + testContainerID + "\n" +
+				"1:name=systemd:/kubepods/besteffort/pod" + testPodUID + "/" + testContainerID + "\n" +
+				"0::/\n",
+			expected: CgroupIdentity{
+				Path:        "/kubepods/besteffort/pod" + testPodUID + "/" + testContainerID,
+				ContainerID: testContainerID,
+				PodUID:      testPodUID,
+			},
+		},
+		{
+			name: "containerdSystemdHybrid",
+			cgroup: "12:memory:/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope\n" +
+				"1:name=systemd:/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope\n" +
+				"0::/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope\n",
+			expected: CgroupIdentity{
+				UnifiedPath: "/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope",
+				Path:        "/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/cri-containerd-" + testContainerID + ".scope",
+				Runtime:     RuntimeContainerd,
+				ContainerID: testContainerID,
+				PodUID:      testPodUID,
+				SystemdUnit: "cri-containerd-" + testContainerID + ".scope",
+			},
+		},
+		{
+			name:   "crioSystemdUnified",
+			cgroup: "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/crio-" + testContainerID + ".scope/container\n",
+			expected: CgroupIdentity{
+				UnifiedPath: "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/crio-" + testContainerID + ".scope/container",
+				Path:        "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/crio-" + testContainerID + ".scope/container",
+				Runtime:     RuntimeCRIO,
+				ContainerID: testContainerID,
+				PodUID:      testPodUID,
+				SystemdUnit: "crio-" + testContainerID + ".scope",
+			},
+		},
+		{
+			name: "crioCgroupfsLegacy",
+			cgroup: "4:pids:/kubepods/burstable/pod" + testPodUID + "/crio-" + testContainerID + "\n" +
+				"0::/\n",
+			expected: CgroupIdentity{
+				Path:        "/kubepods/burstable/pod" + testPodUID + "/crio-" + testContainerID,
+				Runtime:     RuntimeCRIO,
+				ContainerID: testContainerID,
+				PodUID:      testPodUID,
+			},
+		},
+		{
+			name:   "crioConmon",
+			cgroup: "0::/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/crio-conmon-" + testContainerID + ".scope\n",
+			expected: CgroupIdentity{
+				UnifiedPath: "/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/crio-conmon-" + testContainerID + ".scope",
+				Path:        "/kubepods.slice/kubepods-pod2f6ad4e4_5a1b_4c3d_8e9f_0a1b2c3d4e5f.slice/crio-conmon-" + testContainerID + ".scope",
+				PodUID:      testPodUID,
+				SystemdUnit: "crio-conmon-" + testContainerID + ".scope",
+			},
+		},
+		{
+			name:   "dockerCgroupfs",
+			cgroup: "3:cpu:/docker/" + testContainerID + "\n",
+			expected: CgroupIdentity{
+				Path:        "/docker/" + testContainerID,
+				Runtime:     RuntimeDocker,
+				ContainerID: testContainerID,
+			},
+		},
+		{
+			name:   "hostServiceLegacy",
+			cgroup: "12:cpu,cpuacct:/\n1:name=systemd:/system.slice/kubelet.service\n0::/\n",
+			expected: CgroupIdentity{
+				Path:        "/system.slice/kubelet.service",
+				SystemdUnit: "kubelet.service",
+			},
+		},
+		{
+			name:     "root",
+			cgroup:   "0::/\n",
+			expected: CgroupIdentity{Path: "/"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			identity, err := ParseCgroup(tc.cgroup)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, identity, tc.expected)
+		})
+	}
+
+	_, err := ParseCgroup("0::/../../kubepods.slice/cri-containerd-0123.scope\n")
+	assert.ErrorContains(t, err, "out of the cgroup namespace")
+
+	_, err = ParseCgroup("")
+	assert.ErrorContains(t, err, "invalid")
+}
+
+func Test_DetectCgroupMode(t *testing.T) {
+	mode, unified := DetectCgroupMode(t.TempDir())
+	assert.Equal(t, mode, CgroupModeLegacy)
+	assert.Equal(t, unified, "")
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// openInRootRetries is the max number of attempts to open a path when the kernel reports a race with the renames
+// inside the root
+const openInRootRetries = 10
+
+// Root is a directory in which the paths are resolved as if it were the root directory of the process, e.g. the
+// root directory of a container accessed through /proc/<pid>/root. The absolute symlinks and the ".." components
+// are resolved inside it, so the paths can never escape onto the files of the host.
+//
+// It relies on openat2(2) with RESOLVE_IN_ROOT, which is available since Linux 5.6.
+type Root struct {
+	fd   int
+	name string
+}
+
+// OpenRoot opens the directory as a root
+func OpenRoot(name string) (*Root, error) {
+	fd, err := unix.Open(name, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &Root{fd: fd, name: name}, nil
+}
+
+// Name returns the name of the directory passed to OpenRoot
+func (r *Root) Name() string {
+	return r.name
+}
+
+// Open opens the path inside the root with the flags of open(2). The magic links of procfs are not followed.
+func (r *Root) Open(path string, flags int) (*os.File, error) {
+	how := unix.OpenHow{
+		Flags:   uint64(flags | unix.O_CLOEXEC),
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	}
+
+	for i := 0; ; i++ {
+		fd, err := unix.Openat2(r.fd, path, &how)
+		if err == nil {
+			return os.NewFile(uintptr(fd), filepath.Join(r.name, path)), nil
+		}
+		if errors.Is(err, unix.EINTR) || (errors.Is(err, unix.EAGAIN) && i < openInRootRetries) {
+			continue
+		}
+		if errors.Is(err, unix.ENOSYS) {
+			err = fmt.Errorf("openat2(2) is not supported by the kernel: %w", err)
+		}
+		return nil, &os.PathError{Op: "openat2", Path: filepath.Join(r.name, path), Err: err}
+	}
+}
+
+// ProcPath returns the path under /proc/self/fd that refers to the file, it's used to pass the file opened inside
+// the root to the syscalls that only accept paths, e.g. inotify_add_watch(2). The file must remain open while the
+// path is used.
+func ProcPath(file *os.File) string {
+	return fmt.Sprintf("/proc/self/fd/%d", file.Fd())
+}
+
+// Close closes the root
+func (r *Root) Close() error {
+	return unix.Close(r.fd)
+}
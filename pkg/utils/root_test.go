@@ -0,0 +1,94 @@
+// Copyright 2024 vArmor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/assert"
+)
+
+func Test_RootOpen(t *testing.T) {
+	// The files outside the root which must never be opened through it
+	host := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(host, "secret"), []byte("host"), 0600))
+
+	dir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "etc"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "etc", "passwd"), []byte("container"), 0600))
+	assert.NilError(t, os.Symlink("/etc/passwd", filepath.Join(dir, "absolute")))
+	assert.NilError(t, os.Symlink("../../../../etc/passwd", filepath.Join(dir, "etc", "relative")))
+	assert.NilError(t, os.Symlink(filepath.Join(host, "secret"), filepath.Join(dir, "escape")))
+
+	root, err := OpenRoot(dir)
+	assert.NilError(t, err)
+	defer root.Close()
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+		notExist bool
+	}{
+		{name: "absolute path", path: "/etc/passwd", expected: "container"},
+		{name: "relative path", path: "etc/passwd", expected: "container"},
+		{name: "dot dot", path: "/../../etc/passwd", expected: "container"},
+		{name: "absolute symlink", path: "/absolute", expected: "container"},
+		{name: "relative symlink", path: "/etc/relative", expected: "container"},
+		{name: "symlink to host", path: "/escape", notExist: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := root.Open(tc.path, unix.O_RDONLY)
+			if errors.Is(err, unix.ENOSYS) {
+				t.Skip("openat2(2) is not supported by the kernel")
+			}
+			if tc.notExist {
+				assert.Assert(t, errors.Is(err, os.ErrNotExist))
+				return
+			}
+			assert.NilError(t, err)
+			defer file.Close()
+
+			data, err := io.ReadAll(file)
+			assert.NilError(t, err)
+			assert.Equal(t, string(data), tc.expected)
+		})
+	}
+}
+
+func Test_ProcPath(t *testing.T) {
+	dir := t.TempDir()
+	root, err := OpenRoot(dir)
+	assert.NilError(t, err)
+	defer root.Close()
+
+	file, err := root.Open("/", unix.O_PATH|unix.O_DIRECTORY)
+	if errors.Is(err, unix.ENOSYS) {
+		t.Skip("openat2(2) is not supported by the kernel")
+	}
+	assert.NilError(t, err)
+	defer file.Close()
+
+	path, err := os.Readlink(ProcPath(file))
+	assert.NilError(t, err)
+	assert.Equal(t, path, dir)
+}
@@ -22,7 +22,26 @@ import (
 )
 
 func ReadMntNsID(pid uint32) (uint32, error) {
-	path := fmt.Sprintf("/proc/%d/ns/mnt", pid)
+	return readNsID(pid, "mnt")
+}
+
+// ReadNetNsID returns the id of the net ns of the process
+func ReadNetNsID(pid uint32) (uint32, error) {
+	return readNsID(pid, "net")
+}
+
+// ReadPidNsID returns the id of the pid ns of the process
+func ReadPidNsID(pid uint32) (uint32, error) {
+	return readNsID(pid, "pid")
+}
+
+// ReadIpcNsID returns the id of the ipc ns of the process
+func ReadIpcNsID(pid uint32) (uint32, error) {
+	return readNsID(pid, "ipc")
+}
+
+func readNsID(pid uint32, ns string) (uint32, error) {
+	path := fmt.Sprintf("/proc/%d/ns/%s", pid, ns)
 	realPath, err := os.Readlink(path)
 	if err != nil {
 		return 0, err